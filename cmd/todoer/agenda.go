@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/inful/todoer/pkg/core"
+)
+
+// AgendaMarkdown is the agenda format meant to be pasted into a daily note
+const AgendaMarkdown = "md"
+
+// agendaBuckets are the age buckets carried tasks are grouped in, by the days
+// they have been open at least
+var agendaBuckets = []struct {
+	Label   string
+	MinDays int
+}{
+	{"over 4 weeks", 28},
+	{"1-4 weeks", 7},
+	{"2-6 days", 2},
+	{"1 day", 1},
+}
+
+// agenda is the dashboard of one day.
+type agenda struct {
+	Date      string           // Today in YYYY-MM-DD format
+	Overdue   []core.TaskAlert // Open tasks past their due date, most overdue first
+	DueToday  []string         // Texts of the open tasks due today
+	Carried   []agendaBucket   // Non-empty age buckets of the carried tasks, oldest first
+	Streak    core.StreakStats // Completion streaks as of today
+	Yesterday []string         // Texts of the tasks completed yesterday, without their date tag
+}
+
+// agendaBucket holds the carried tasks of one age bucket.
+type agendaBucket struct {
+	Label string
+	Tasks []core.TaskAlert
+}
+
+// buildAgenda assembles the agenda of today from the current journal below rootDir and the
+// completions of the whole tree.
+//
+// Overdue and carried tasks are found like notify finds overdue and stale tasks, with
+// no grace days and every top-level task opened before today counting as carried.
+// Tasks due today are not listed as carried as well.
+func buildAgenda(rootDir, indexPath, today string, config *Config, logger *Logger) (agenda, error) {
+	a := agenda{Date: today}
+	store, err := openJournalTree(rootDir, config)
+	if err != nil {
+		return a, withExitCode(ExitConfigError, err)
+	}
+
+	name, err := currentJournalName(store, today, config)
+	if err != nil {
+		return a, err
+	}
+	logger.Debug("Building agenda from %s", store.Location(name))
+
+	journal, err := readTodoJournal(store, name, config)
+	if err != nil {
+		return a, err
+	}
+	journalDate, _ := journalDateFromPath(name)
+	alerts, err := core.FindAlerts(journal, today, 0, 1, journalDate)
+	if err != nil {
+		return a, err
+	}
+
+	for _, loc := range core.FindItems(journal, core.MatchOpen) {
+		if core.ExtractDue(loc.Item.Text) == today {
+			a.DueToday = append(a.DueToday, loc.Item.Text)
+		}
+	}
+
+	byBucket := make([][]core.TaskAlert, len(agendaBuckets))
+	for _, alert := range alerts {
+		if alert.Kind == core.AlertOverdue {
+			a.Overdue = append(a.Overdue, alert)
+			continue
+		}
+		if core.ExtractDue(alert.Text) == today {
+			continue
+		}
+		for i, bucket := range agendaBuckets {
+			if alert.Days >= bucket.MinDays {
+				byBucket[i] = append(byBucket[i], alert)
+				break
+			}
+		}
+	}
+	for i, tasks := range byBucket {
+		if len(tasks) > 0 {
+			a.Carried = append(a.Carried, agendaBucket{Label: agendaBuckets[i].Label, Tasks: tasks})
+		}
+	}
+
+	if a.Streak, err = completionStreaks(store, indexPath, today, config, logger); err != nil {
+		return a, err
+	}
+
+	t, err := time.Parse(core.DateFormat, today)
+	if err != nil {
+		return a, fmt.Errorf("invalid date %q: %w", today, err)
+	}
+	yesterday := t.AddDate(0, 0, -1).Format(core.DateFormat)
+	digest, err := collectDigest(store, yesterday, yesterday, config, logger)
+	if err != nil {
+		return a, err
+	}
+	for _, day := range digest.Days {
+		a.Yesterday = append(a.Yesterday, day.Tasks...)
+	}
+	return a, nil
+}
+
+// cmdAgenda prints the agenda of today for the journals below rootDir on w, as text
+// for the terminal or, with the md format, as markdown to paste into the daily note.
+func cmdAgenda(w io.Writer, rootDir, indexPath, today, format string, config *Config, logger *Logger) error {
+	a, err := buildAgenda(rootDir, indexPath, today, config, logger)
+	if err != nil {
+		return err
+	}
+	if format == AgendaMarkdown {
+		_, err = io.WriteString(w, agendaMarkdown(a))
+	} else {
+		_, err = io.WriteString(w, agendaText(a))
+	}
+	return err
+}
+
+// agendaText renders a for the terminal, in color if standard output is colored.
+func agendaText(a agenda) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Agenda for %s\n", a.Date)
+
+	section := func(title string, count int) bool {
+		if count == 0 {
+			fmt.Fprintf(&b, "\n%s: none\n", title)
+			return false
+		}
+		fmt.Fprintf(&b, "\n%s (%d):\n", title, count)
+		return true
+	}
+
+	if section(stdoutColors.red("Overdue"), len(a.Overdue)) {
+		for _, alert := range a.Overdue {
+			fmt.Fprintf(&b, "  - %s %s\n", alert.Text, stdoutColors.red(fmt.Sprintf("(%d %s)", alert.Days, plural(alert.Days, "day", "days"))))
+		}
+	}
+	if section(stdoutColors.yellow("Due today"), len(a.DueToday)) {
+		for _, text := range a.DueToday {
+			fmt.Fprintf(&b, "  - %s\n", text)
+		}
+	}
+
+	carried := 0
+	for _, bucket := range a.Carried {
+		carried += len(bucket.Tasks)
+	}
+	if section("Carried", carried) {
+		for _, bucket := range a.Carried {
+			fmt.Fprintf(&b, "  %s:\n", bucket.Label)
+			for _, alert := range bucket.Tasks {
+				fmt.Fprintf(&b, "    - %s\n", alert.Text)
+			}
+		}
+	}
+
+	fmt.Fprintf(&b, "\nStreak: %s %s (longest %d)\n", stdoutColors.green(fmt.Sprint(a.Streak.CurrentStreak)),
+		plural(a.Streak.CurrentStreak, "day", "days"), a.Streak.LongestStreak)
+
+	if section(stdoutColors.green("Completed yesterday"), len(a.Yesterday)) {
+		for _, text := range a.Yesterday {
+			fmt.Fprintf(&b, "  - %s\n", text)
+		}
+	}
+	return b.String()
+}
+
+// agendaMarkdown renders a as markdown. Tasks are listed as plain bullets, so that
+// pasting the agenda into a journal does not duplicate its todos.
+func agendaMarkdown(a agenda) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Agenda %s\n", a.Date)
+
+	list := func(title string, items []string) {
+		fmt.Fprintf(&b, "\n### %s\n\n", title)
+		if len(items) == 0 {
+			b.WriteString("_None_\n")
+		}
+		for _, item := range items {
+			fmt.Fprintf(&b, "- %s\n", item)
+		}
+	}
+
+	var overdue []string
+	for _, alert := range a.Overdue {
+		overdue = append(overdue, fmt.Sprintf("%s (%d %s overdue)", alert.Text, alert.Days, plural(alert.Days, "day", "days")))
+	}
+	list("Overdue", overdue)
+	list("Due today", a.DueToday)
+
+	var carried []string
+	for _, bucket := range a.Carried {
+		for _, alert := range bucket.Tasks {
+			carried = append(carried, fmt.Sprintf("%s (%s)", alert.Text, bucket.Label))
+		}
+	}
+	list("Carried", carried)
+
+	fmt.Fprintf(&b, "\n### Streak\n\n%d %s, longest %d\n", a.Streak.CurrentStreak,
+		plural(a.Streak.CurrentStreak, "day", "days"), a.Streak.LongestStreak)
+	list("Completed yesterday", a.Yesterday)
+	return b.String()
+}
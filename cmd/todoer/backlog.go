@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"time"
+
+	"github.com/inful/todoer/pkg/core"
+	"github.com/inful/todoer/pkg/storage"
+)
+
+// journalItems returns the top-level items of all days of journal.
+func journalItems(journal *core.TodoJournal) []*core.TodoItem {
+	var items []*core.TodoItem
+	if journal == nil {
+		return items
+	}
+	for _, day := range journal.Days {
+		if day != nil {
+			items = append(items, day.Items...)
+		}
+	}
+	return items
+}
+
+// mergeBacklog merges the todos of overflow, carried from the journal source, into
+// the todos section of the backlog file in store, keeping their days, and returns
+// the name of the backlog. The file, or its section, is created if needed; todos
+// already in the backlog are not added again. The todos of an encoded journal go
+// into a backlog encoded like it, which is refused if the backlog is plain text.
+func mergeBacklog(store storage.Storage, overflow *core.TodoJournal, source string, config *Config) (string, error) {
+	header := todosHeader(config)
+
+	name := backlogName(store, config)
+	if codecExt(source) != "" && codecExt(name) == "" {
+		if _, err := store.Stat(name); err == nil {
+			return name, fmt.Errorf("cannot move todos of the encoded %s into the plain text backlog", path.Base(source))
+		}
+		name = encodedLike(name, source)
+	}
+
+	content, err := readJournalFile(store, name, config)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		content = []byte("# Backlog\n\n" + header + "\n\n")
+	case err != nil:
+		return name, err
+	case !containsTodosHeader(string(content), header):
+		doc := core.ParseDocument(string(content))
+		doc.AddSection(header)
+		content = []byte(doc.String())
+	}
+
+	backlog, err := journalParser(config).ParseJournal(string(content))
+	if err != nil {
+		return name, err
+	}
+	backlog = core.MergeJournals(backlog, overflow)
+
+	updated, err := journalRenderer(config).ReplaceJournal(string(content), backlog)
+	if err != nil {
+		return name, err
+	}
+	return name, writeJournalFile(store, name, []byte(updated), config)
+}
+
+// backlogName returns the name of the backlog file in store: backlog_file, or the
+// encoded variant of it that exists.
+func backlogName(store storage.Storage, config *Config) string {
+	return resolveEncodedName(store, config.BacklogFile)
+}
+
+// backlogEntry is an open top-level task of the backlog as shown by backlog list.
+type backlogEntry struct {
+	Number int               // Position in the listing, starting at 1
+	Loc    core.ItemLocation // Location of the task in the backlog journal
+}
+
+// backlogEntries returns the open top-level tasks of the backlog journal in
+// document order, numbered from 1.
+func backlogEntries(journal *core.TodoJournal) []backlogEntry {
+	var entries []backlogEntry
+	for _, loc := range core.FindItems(journal, core.MatchOpen) {
+		if loc.Parent == nil {
+			entries = append(entries, backlogEntry{Number: len(entries) + 1, Loc: loc})
+		}
+	}
+	return entries
+}
+
+// readBacklog reads the backlog file from store and parses its todos section.
+// A missing backlog file reads as an empty backlog.
+func readBacklog(store storage.Storage, config *Config) (string, *core.TodoJournal, error) {
+	content, journal, err := readJournalTodos(store, backlogName(store, config), config)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", &core.TodoJournal{}, nil
+	}
+	return content, journal, err
+}
+
+// cmdBacklogList prints the open tasks of the backlog with the numbers used by backlog pull.
+func cmdBacklogList(w io.Writer, rootDir string, config *Config, logger *Logger) error {
+	store, err := openJournalTree(rootDir, config)
+	if err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+
+	_, journal, err := readBacklog(store, config)
+	if err != nil {
+		return err
+	}
+
+	entries := backlogEntries(journal)
+	if len(entries) == 0 {
+		logger.Info("The backlog %s is empty", store.Location(backlogName(store, config)))
+		return nil
+	}
+	for _, entry := range entries {
+		date := entry.Loc.Day.Date
+		if date == "" {
+			date = "undated"
+		}
+		fmt.Fprintf(w, "%3d. [%s] %s\n", entry.Number, date, entry.Loc.Item.Text)
+	}
+	return nil
+}
+
+// cmdBacklogPull moves the backlog tasks with the given numbers, and those tagged with
+// tag, together with their subtasks into the journal for now. The tasks keep their
+// day sections. Both files are written together so a failure leaves neither half-updated.
+func cmdBacklogPull(w io.Writer, rootDir string, numbers []int, tag string, now time.Time, config *Config, logger *Logger) error {
+	if len(numbers) == 0 && tag == "" {
+		return errors.New("give the numbers of the tasks to pull or --tag")
+	}
+
+	store, err := openJournalTree(rootDir, config)
+	if err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+
+	backlogContent, backlog, err := readBacklog(store, config)
+	if err != nil {
+		return err
+	}
+	entries := backlogEntries(backlog)
+
+	selected := make(map[int]bool)
+	for _, n := range numbers {
+		if n < 1 || n > len(entries) {
+			return fmt.Errorf("no task %d in the backlog, it has %d open %s", n, len(entries), plural(len(entries), "task", "tasks"))
+		}
+		selected[n] = true
+	}
+	if tag != "" {
+		match := core.MatchTag(tag)
+		for _, entry := range entries {
+			if match(entry.Loc.Item) {
+				selected[entry.Number] = true
+			}
+		}
+	}
+	if len(selected) == 0 {
+		logger.Info("No backlog tasks tagged %s, nothing to pull", tag)
+		return nil
+	}
+
+	date := effectiveDay(now, config).Format(core.DateFormat)
+	journalPath := resolveJournalName(store, date, config)
+	journalContent, journal, err := readJournalTodos(store, journalPath, config)
+	if err != nil {
+		return fmt.Errorf("journal for %s: %w", date, err)
+	}
+
+	var pulled []string
+	for _, entry := range entries {
+		if !selected[entry.Number] {
+			continue
+		}
+		core.RemoveItem(entry.Loc)
+		day := core.EnsureDay(journal, entry.Loc.Day.Date)
+		day.Items = append(day.Items, entry.Loc.Item)
+		pulled = append(pulled, entry.Loc.Item.Text)
+	}
+	core.RemoveEmptyDays(backlog)
+
+	renderer := journalRenderer(config)
+	newBacklog, err := renderer.ReplaceJournal(backlogContent, backlog)
+	if err != nil {
+		return fmt.Errorf("failed to update backlog: %w", err)
+	}
+	newJournal, err := renderer.ReplaceJournal(journalContent, journal)
+	if err != nil {
+		return fmt.Errorf("failed to update journal for %s: %w", date, err)
+	}
+
+	err = writeJournalFiles(store, []pendingWrite{
+		{name: backlogName(store, config), data: []byte(newBacklog)},
+		{name: journalPath, data: []byte(newJournal)},
+	}, config)
+	if err != nil {
+		return withExitCode(ExitWriteError, err)
+	}
+
+	for _, text := range pulled {
+		fmt.Fprintf(w, "Pulled '%s' into %s\n", text, store.Location(journalPath))
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/inful/todoer/pkg/core"
+)
+
+// BearTagPrefix is the parent tag of the notes exported for Bear; a note is tagged
+// with it, the year and the month of its journal, e.g. #journal/2025/06
+const BearTagPrefix = "journal"
+
+// bearTag returns the tag of the Bear note of the journal for date, without '#'.
+func bearTag(date string) string {
+	t, err := time.Parse(core.DateFormat, date)
+	if err != nil {
+		return BearTagPrefix
+	}
+	return BearTagPrefix + "/" + t.Format("2006/01")
+}
+
+// bearFormat lays out the tasks of a Bear note: a heading per day and checkboxes
+// nested with tabs, as Bear indents lists
+var bearFormat = core.Format{UseTabs: true, DayHeader: "## 2006-01-02"}
+
+// bearTasks returns the tasks of journal as the text of a Bear note.
+func bearTasks(journal *core.TodoJournal) string {
+	return core.JournalToStringFormat(journal, bearFormat)
+}
+
+// bearNote returns the Bear note of the journal for date: the date as title, the
+// tag of the note and the tasks of the journal under their days.
+func bearNote(date string, journal *core.TodoJournal) string {
+	note := "# " + date + "\n#" + bearTag(date) + "\n"
+	if tasks := bearTasks(journal); tasks != "" {
+		note += "\n" + tasks + "\n"
+	}
+	return note
+}
+
+// bearCreateURL returns the x-callback-url that creates the Bear note of the
+// journal for date. Bear adds the title and the tag to the text itself.
+func bearCreateURL(date string, journal *core.TodoJournal) string {
+	return "bear://x-callback-url/create?title=" + uriEscape(date) +
+		"&text=" + uriEscape(bearTasks(journal)) +
+		"&tags=" + uriEscape(bearTag(date))
+}
+
+// exportBear exports the journals below rootDir as Bear notes. With dir set, every
+// journal is written as a note named after its date into dir, ready for Bear's
+// markdown import. Otherwise the note of the current journal is written on w, or
+// with url its x-callback-url, or with push that URL is opened so the note is
+// created in Bear right away.
+func exportBear(w io.Writer, rootDir, dir string, url, push bool, now time.Time, config *Config, logger *Logger) error {
+	store, err := openJournalTree(rootDir, config)
+	if err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+
+	if dir != "" {
+		if url || push {
+			return withExitCode(ExitConfigError, errors.New("--url and --push export the current journal and take no directory"))
+		}
+		journals, err := loadSyncJournals(store, newProgress(logger), config)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(dir, dirMode()); err != nil {
+			return withExitCode(ExitWriteError, fmt.Errorf("failed to create %s: %w", dir, err))
+		}
+		for _, j := range journals {
+			file := filepath.Join(dir, j.date+".md")
+			if err := safeWriteFile(file, []byte(bearNote(j.date, j.journal)), fileMode); err != nil {
+				return withExitCode(ExitWriteError, fmt.Errorf("failed to write note: %w", err))
+			}
+		}
+		logger.Info("Exported %d %s to %s", len(journals), plural(len(journals), "note", "notes"), dir)
+		return nil
+	}
+
+	name, err := currentJournalName(store, effectiveDay(now, config).Format(core.DateFormat), config)
+	if err != nil {
+		return err
+	}
+	date, _ := journalDateFromPath(name)
+	_, journal, err := readJournalTodos(store, name, config)
+	if err != nil {
+		return fmt.Errorf("%s: %w", store.Location(name), err)
+	}
+
+	switch {
+	case push:
+		return openURL(bearCreateURL(date, journal))
+	case url:
+		_, err = fmt.Fprintln(w, bearCreateURL(date, journal))
+	default:
+		_, err = io.WriteString(w, bearNote(date, journal))
+	}
+	return err
+}
+
+// openURL opens uri with the open command of macOS, which hands it to the
+// application registered for its scheme.
+func openURL(uri string) error {
+	if runtime.GOOS != "darwin" {
+		return withExitCode(ExitConfigError, errors.New("--push needs macOS; use --url to print the URL instead"))
+	}
+	if output, err := exec.Command("open", uri).CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(output)); msg != "" {
+			return fmt.Errorf("open failed: %w: %s", err, msg)
+		}
+		return fmt.Errorf("open failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"text/tabwriter"
+	"time"
+
+	"github.com/inful/todoer/pkg/core"
+	"github.com/inful/todoer/pkg/storage"
+)
+
+// Stages of processing timed by bench, in pipeline order
+const (
+	benchParse    = "parse"    // Parsing the todos sections
+	benchSplit    = "split"    // Splitting the parsed journals into completed and open todos
+	benchTag      = "tag"      // Tagging the completed todos with their date
+	benchRender   = "render"   // Writing the journals back as todos sections
+	benchPipeline = "pipeline" // Processing whole journals and rendering the template
+)
+
+// benchStages lists the stages timed by bench in the order they are reported
+var benchStages = []string{benchParse, benchSplit, benchTag, benchRender, benchPipeline}
+
+// benchBudgets is the performance budget of each stage: the longest it may take
+// per MiB of journals, todos sections for the stages before pipeline. They are
+// generous, so a stage over budget points at a regression rather than a slow
+// machine.
+var benchBudgets = map[string]time.Duration{
+	benchParse:    500 * time.Millisecond,
+	benchSplit:    50 * time.Millisecond,
+	benchTag:      100 * time.Millisecond,
+	benchRender:   200 * time.Millisecond,
+	benchPipeline: 1500 * time.Millisecond,
+}
+
+// benchMinBytes is the smallest input a stage is held to its budget on: below
+// it, the fixed cost of a run outweighs the time per MiB.
+const benchMinBytes = 64 << 10
+
+// benchOptions holds the settings of a bench run.
+type benchOptions struct {
+	TemplateFile string // Template rendered by the pipeline stage
+	Runs         int    // Times every stage is run; the fastest run is reported
+	CPUProfile   string // File the CPU profile of all runs is written to, if set
+	MemProfile   string // File a heap profile is written to after the runs, if set
+}
+
+// benchJournal is a journal benchmarked by bench
+type benchJournal struct {
+	name    string // Name of the journal in the store
+	date    string // Date of the journal
+	content string // Content of the journal
+	section string // Body of its todos section
+}
+
+// benchResult is the fastest run of a stage
+type benchResult struct {
+	stage string
+	bytes int           // Input size of the stage
+	time  time.Duration // Time of the fastest run
+}
+
+// perMiB returns the time the stage took per MiB of input.
+func (r benchResult) perMiB() time.Duration {
+	if r.bytes == 0 {
+		return 0
+	}
+	return time.Duration(float64(r.time) * (1 << 20) / float64(r.bytes))
+}
+
+// judged reports whether the input of the stage is large enough to hold it to its budget.
+func (r benchResult) judged() bool {
+	return r.bytes >= benchMinBytes
+}
+
+// overBudget reports whether the stage took longer than its budget.
+func (r benchResult) overBudget() bool {
+	return r.judged() && r.perMiB() > benchBudgets[r.stage]
+}
+
+// cmdBench times the stages of processing on the journals below rootDir and
+// reports the fastest of opts.Runs runs of each on w, against the budget of the
+// stage. The journals are only read. It returns an error if a stage is over
+// budget; stages with less than benchMinBytes of input are not judged.
+func cmdBench(ctx context.Context, w io.Writer, rootDir string, opts benchOptions, config *Config, logger *Logger) error {
+	if opts.Runs < 1 {
+		return withExitCode(ExitConfigError, fmt.Errorf("--runs must be at least 1, got %d", opts.Runs))
+	}
+	store, err := openJournalTree(rootDir, config)
+	if err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+	journals, err := loadBenchJournals(store, config)
+	if err != nil {
+		return err
+	}
+	if len(journals) == 0 {
+		return withExitCode(ExitConfigError, fmt.Errorf("no journals found in %s", rootDir))
+	}
+
+	if opts.CPUProfile != "" {
+		file, err := os.Create(opts.CPUProfile)
+		if err != nil {
+			return withExitCode(ExitWriteError, fmt.Errorf("failed to create CPU profile: %w", err))
+		}
+		defer file.Close()
+		if err := pprof.StartCPUProfile(file); err != nil {
+			return fmt.Errorf("failed to start CPU profile: %w", err)
+		}
+		defer pprof.StopCPUProfile()
+	}
+
+	results := make(map[string]*benchResult)
+	for run := 0; run < opts.Runs; run++ {
+		logger.Debug("Bench run %d of %d", run+1, opts.Runs)
+		timings, err := runBench(ctx, store, journals, opts.TemplateFile, config, logger)
+		if err != nil {
+			return err
+		}
+		for _, timing := range timings {
+			if best, ok := results[timing.stage]; !ok || timing.time < best.time {
+				results[timing.stage] = &timing
+			}
+		}
+	}
+
+	if opts.MemProfile != "" {
+		runtime.GC()
+		if err := writeHeapProfile(opts.MemProfile); err != nil {
+			return withExitCode(ExitWriteError, err)
+		}
+	}
+
+	size := 0
+	for _, j := range journals {
+		size += len(j.content)
+	}
+	fmt.Fprintf(w, "%d %s, %d KiB, fastest of %d %s\n\n", len(journals), plural(len(journals), "journal", "journals"),
+		size>>10, opts.Runs, plural(opts.Runs, "run", "runs"))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STAGE\tTIME\tPER MIB\tBUDGET\tSTATUS")
+	over := 0
+	for _, stage := range benchStages {
+		result := results[stage]
+		// The status is the last column, so its color does not shift the others
+		status := stdoutColors.green("ok")
+		switch {
+		case !result.judged():
+			status = "too small to judge"
+		case result.overBudget():
+			status = stdoutColors.red("over budget")
+			over++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", stage, result.time.Round(time.Microsecond),
+			result.perMiB().Round(time.Microsecond), benchBudgets[stage], status)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if over > 0 {
+		return fmt.Errorf("%d %s over budget", over, plural(over, "stage is", "stages are"))
+	}
+	return nil
+}
+
+// loadBenchJournals reads the journals below store with their todos sections.
+// Journals without a todos section only count for the pipeline stage.
+func loadBenchJournals(store storage.Storage, config *Config) ([]*benchJournal, error) {
+	header := todosHeader(config)
+	var journals []*benchJournal
+	err := storage.Walk(store, "", func(info storage.FileInfo) error {
+		date, ok := journalDateFromPath(info.Name)
+		if !ok {
+			return nil
+		}
+		content, err := readJournalFile(store, info.Name, config)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", store.Location(info.Name), err)
+		}
+		_, section, _, _ := core.ExtractTodosSectionWithHeader(string(content), header)
+		journals = append(journals, &benchJournal{name: info.Name, date: date, content: string(content), section: section})
+		return nil
+	})
+	return journals, err
+}
+
+// runBench runs every stage once over journals and returns their timings.
+func runBench(ctx context.Context, store storage.Storage, journals []*benchJournal, templateFile string, config *Config, logger *Logger) ([]benchResult, error) {
+	parser, renderer := journalParser(config), journalRenderer(config)
+	sections := 0
+	for _, j := range journals {
+		sections += len(j.section)
+	}
+	timings := make([]benchResult, 0, len(benchStages))
+	timed := func(stage string, bytes int, f func() error) error {
+		start := time.Now()
+		err := f()
+		timings = append(timings, benchResult{stage: stage, bytes: bytes, time: time.Since(start)})
+		return err
+	}
+
+	parsed := make([]*core.TodoJournal, len(journals))
+	err := timed(benchParse, sections, func() error {
+		for i, j := range journals {
+			journal, _, err := parser.Parse(ctx, j.section)
+			if err != nil {
+				return withExitCode(ExitParseError, core.WithFile(err, store.Location(j.name)))
+			}
+			parsed[i] = journal
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	completed := make([]*core.TodoJournal, len(journals))
+	open := make([]*core.TodoJournal, len(journals))
+	timed(benchSplit, sections, func() error {
+		for i, journal := range parsed {
+			completed[i], open[i] = core.SplitCarried(journal)
+		}
+		return nil
+	})
+	timed(benchTag, sections, func() error {
+		for i, j := range journals {
+			core.TagCompletedItems(completed[i], j.date)
+			core.TagCompletedSubitems(open[i], j.date)
+		}
+		return nil
+	})
+	timed(benchRender, sections, func() error {
+		for _, journal := range parsed {
+			renderer.Render(journal)
+		}
+		return nil
+	})
+
+	// The generator is created once, as the template is for a real run
+	gen, _, err := getGenerator(store, templateFile, effectiveToday(config), journals[len(journals)-1].content, config, logger)
+	if err != nil {
+		return nil, withExitCode(ExitConfigError, err)
+	}
+	size := 0
+	for _, j := range journals {
+		size += len(j.content)
+	}
+	err = timed(benchPipeline, size, func() error {
+		for _, j := range journals {
+			if _, err := gen.ProcessToContext(ctx, j.content, io.Discard, io.Discard); err != nil {
+				return withExitCode(ExitParseError, core.WithFile(err, store.Location(j.name)))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return timings, nil
+}
+
+// writeHeapProfile writes a heap profile to path.
+func writeHeapProfile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create memory profile: %w", err)
+	}
+	if err := pprof.WriteHeapProfile(file); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write memory profile: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write memory profile: %w", err)
+	}
+	return nil
+}
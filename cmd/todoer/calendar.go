@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/inful/todoer/pkg/core"
+)
+
+// parseHolidays returns the dates of a holidays file: one YYYY-MM-DD date at the
+// start of each line, optionally followed by a description. Blank lines and lines
+// starting with # are ignored.
+func parseHolidays(content []byte) ([]string, error) {
+	var dates []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		date, _, _ := strings.Cut(line, " ")
+		if err := validateDateFormat(date); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		dates = append(dates, date)
+	}
+	return dates, scanner.Err()
+}
+
+// configCalendar returns the calendar of workdays with the holidays of the
+// configured holidays_file.
+func configCalendar(config *Config) (core.Calendar, error) {
+	if config.HolidaysFile == "" {
+		return core.Calendar{}, nil
+	}
+	content, err := os.ReadFile(config.HolidaysFile)
+	if err != nil {
+		return core.Calendar{}, withExitCode(ExitConfigError, fmt.Errorf("failed to read holidays file: %w", err))
+	}
+	dates, err := parseHolidays(content)
+	if err != nil {
+		return core.Calendar{}, withExitCode(ExitConfigError, fmt.Errorf("holidays file %s: %w", config.HolidaysFile, err))
+	}
+	return core.NewCalendar(dates...)
+}
+
+// newJournalDate returns the date new creates the journal for when run on date.
+// With skip_weekends, weekends and holidays give way to the next workday, so the
+// tasks left on Friday land in Monday's journal.
+func newJournalDate(date string, config *Config) (string, error) {
+	if !config.SkipWeekends {
+		return date, nil
+	}
+	t, err := time.Parse(core.DateFormat, date)
+	if err != nil {
+		return "", withExitCode(ExitConfigError, fmt.Errorf("%w: expected format YYYY-MM-DD, got %s", ErrInvalidDate, date))
+	}
+	calendar, err := configCalendar(config)
+	if err != nil {
+		return "", err
+	}
+	if calendar.IsWorkday(t) {
+		return date, nil
+	}
+	return calendar.NextWorkday(t).Format(core.DateFormat), nil
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/inful/todoer/pkg/core"
+	"github.com/inful/todoer/pkg/storage"
+)
+
+// runLedger records the progress of a batch run under the state directory, so
+// that an interrupted run can be resumed without doing its finished steps again.
+type runLedger struct {
+	Command string       `json:"command"`
+	Root    string       `json:"root"`    // Location of the journal tree
+	Dates   []string     `json:"dates"`   // Dates of the journals the run creates, in order
+	Done    []ledgerStep `json:"done"`    // Journals created so far
+	Started time.Time    `json:"started"` // When the run began
+
+	path string // File the ledger is kept in
+}
+
+// ledgerStep is a journal created by a batch run, with the journal carried over from.
+type ledgerStep struct {
+	Date   string `json:"date"`
+	Source string `json:"source,omitempty"`
+	Target string `json:"target"`
+}
+
+// runLedgerPath returns the ledger file of command runs on the journal tree at root.
+func runLedgerPath(command, root string) (string, error) {
+	stateHome, err := getStateDir()
+	if err != nil {
+		return "", fmt.Errorf("could not determine state directory: %w", err)
+	}
+	key := sha256.Sum256([]byte(root))
+	return filepath.Join(stateHome, ConfigDirName, RunsDirName, command+"-"+hex.EncodeToString(key[:8])+".json"), nil
+}
+
+// loadRunLedger reads the ledger at ledgerPath, returning nil if there is none.
+func loadRunLedger(ledgerPath string) (*runLedger, error) {
+	content, err := os.ReadFile(ledgerPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run ledger: %w", err)
+	}
+	var ledger runLedger
+	if err := json.Unmarshal(content, &ledger); err != nil {
+		return nil, fmt.Errorf("failed to decode run ledger %s: %w", ledgerPath, err)
+	}
+	ledger.path = ledgerPath
+	return &ledger, nil
+}
+
+// done reports whether the journal of date was created by the run.
+func (l *runLedger) done(date string) bool {
+	return slices.ContainsFunc(l.Done, func(step ledgerStep) bool { return step.Date == date })
+}
+
+// record adds a created journal to the ledger and writes it, atomically so that
+// a crash leaves either the old or the new ledger behind.
+func (l *runLedger) record(step ledgerStep) error {
+	l.Done = append(l.Done, step)
+	return l.save()
+}
+
+// save writes the ledger to its file.
+func (l *runLedger) save() error {
+	content, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), dirMode()); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := safeWriteFile(l.path, append(content, '\n'), fileMode); err != nil {
+		return fmt.Errorf("failed to write run ledger: %w", err)
+	}
+	return nil
+}
+
+// catchUpJournals creates the journal of every day after the latest journal up
+// to today, each carrying over from the one before, like new run on each of
+// those days. With skip_weekends, only workdays get a journal.
+//
+// The journals created are recorded in a ledger under the state directory. If
+// the run is interrupted, the ledger is left behind and the next run refuses to
+// start unless resume is set; resuming creates the remaining journals of the
+// interrupted run and skips the ones recorded, whose sources were already
+// processed. A journal created but not yet recorded exists and is left alone.
+// The ledger is removed when the run completes. The summary is that of the last
+// journal, with the completed todos and warnings of all of them.
+func catchUpJournals(ctx context.Context, rootDir, templateFile, today string, resume bool, config *Config, logger *Logger) (*resultSummary, error) {
+	summary := newResultSummary("new")
+	if err := validateDateFormat(today); err != nil {
+		return summary, withExitCode(ExitConfigError, err)
+	}
+	store, err := openJournalTree(rootDir, config)
+	if err != nil {
+		return summary, withExitCode(ExitConfigError, err)
+	}
+	root := store.Location("")
+	if config.JournalFile != "" {
+		root = store.Location(config.JournalFile)
+	}
+	ledgerPath, err := runLedgerPath("catch-up", root)
+	if err != nil {
+		return summary, err
+	}
+	ledger, err := loadRunLedger(ledgerPath)
+	if err != nil {
+		return summary, err
+	}
+
+	switch {
+	case ledger != nil && !resume:
+		return summary, withExitCode(ExitConfigError, fmt.Errorf("an interrupted catch-up of %s created %d of %d journals; continue it with --resume, or remove %s to start over",
+			root, len(ledger.Done), len(ledger.Dates), ledgerPath))
+	case ledger == nil:
+		if resume {
+			logger.Debug("No interrupted catch-up of %s to resume, starting a new one", root)
+		}
+		latest, err := latestJournalDate(store, today, config)
+		if err != nil {
+			return summary, err
+		}
+		dates, err := catchUpDates(latest, today, config)
+		if err != nil {
+			return summary, err
+		}
+		ledger = &runLedger{Command: "catch-up", Root: root, Dates: dates, Done: []ledgerStep{}, Started: time.Now(), path: ledgerPath}
+	default:
+		logger.Info("Resuming the catch-up of %s: %d of %d journals already created", root, len(ledger.Done), len(ledger.Dates))
+	}
+
+	// One journal needs no ledger: there is nothing to resume
+	if len(ledger.Dates) == 1 {
+		return createJournal(ctx, rootDir, templateFile, ledger.Dates[0], config, logger)
+	}
+	if err := ledger.save(); err != nil {
+		return summary, withExitCode(ExitWriteError, err)
+	}
+
+	progress := newProgress(logger)
+	progress.Start(len(ledger.Dates))
+	defer progress.Finish()
+	completed, warnings := 0, []string{}
+	for _, date := range ledger.Dates {
+		if ledger.done(date) {
+			logger.Debug("Journal of %s already created by the interrupted run, skipping", date)
+			progress.Done(date)
+			continue
+		}
+		progress.Stage(date, core.StageCreate)
+		day, err := createJournal(ctx, rootDir, templateFile, date, config, logger)
+		if err != nil {
+			day.Warnings = append(warnings, day.Warnings...)
+			return day, fmt.Errorf("catching up on %s: %w", date, err)
+		}
+		completed += day.CompletedTodos
+		warnings = append(warnings, day.Warnings...)
+		if err := ledger.record(ledgerStep{Date: date, Source: day.Source, Target: day.Target}); err != nil {
+			return day, withExitCode(ExitWriteError, err)
+		}
+		progress.Done(date)
+		summary = day
+	}
+
+	if err := os.Remove(ledgerPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Info("Could not remove the run ledger %s: %v", ledgerPath, err)
+	}
+	if summary.Target == "" {
+		// Every journal was created before the run was interrupted
+		summary.Target = ledger.Done[len(ledger.Done)-1].Target
+		summary.AlreadyExists = true
+	}
+	summary.CompletedTodos = completed
+	summary.Warnings = warnings
+	logger.Info("Caught up on %d %s from %s to %s", len(ledger.Dates), plural(len(ledger.Dates), "day", "days"), ledger.Dates[0], ledger.Dates[len(ledger.Dates)-1])
+	return summary, nil
+}
+
+// latestJournalDate returns the date of the latest journal before today in
+// store, or the single-file journal, or "" if there is none.
+func latestJournalDate(store storage.Storage, today string, config *Config) (string, error) {
+	if config.JournalFile != "" {
+		s, err := openSingleFile(store, config.JournalFile, config)
+		if err != nil {
+			return "", err
+		}
+		name, ok := s.latestBefore(today)
+		if !ok {
+			return "", nil
+		}
+		date, _ := s.day(name)
+		return date, nil
+	}
+
+	latest := ""
+	err := storage.Walk(store, "", func(info storage.FileInfo) error {
+		if date, ok := journalDateFromPath(info.Name); ok && date < today && date > latest {
+			latest = date
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to scan journals in %s: %w", store.Location(""), err)
+	}
+	return latest, nil
+}
+
+// catchUpDates returns the dates after latest up to today that get a journal,
+// only workdays besides today with skip_weekends. Without a latest journal only
+// today does.
+func catchUpDates(latest, today string, config *Config) ([]string, error) {
+	if latest == "" {
+		return []string{today}, nil
+	}
+	from, err := time.Parse(core.DateFormat, latest)
+	if err != nil {
+		return nil, err
+	}
+	to, err := time.Parse(core.DateFormat, today)
+	if err != nil {
+		return nil, err
+	}
+	calendar, err := configCalendar(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var dates []string
+	for day := from.AddDate(0, 0, 1); !day.After(to); day = day.AddDate(0, 0, 1) {
+		if config.SkipWeekends && !calendar.IsWorkday(day) && !day.Equal(to) {
+			continue
+		}
+		dates = append(dates, day.Format(core.DateFormat))
+	}
+	return dates, nil
+}
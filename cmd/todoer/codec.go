@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/inful/todoer/pkg/generator"
+	"github.com/inful/todoer/pkg/storage"
+)
+
+// journalCodecs maps the extension of an encoded journal file, e.g. 2025-06-20.md.age,
+// to the constructor of the codec that decodes and encodes it.
+var journalCodecs = map[string]func(*Config) (generator.Codec, error){
+	".age": newAgeCodec,
+	".gpg": newGPGCodec,
+}
+
+// codecForPath returns the codec for a journal file based on its extension,
+// or nil if the file is plain markdown.
+func codecForPath(path string, config *Config) (generator.Codec, error) {
+	newCodec, ok := journalCodecs[filepath.Ext(path)]
+	if !ok {
+		return nil, nil
+	}
+	codec, err := newCodec(config)
+	if err != nil {
+		return nil, fmt.Errorf("cannot handle %s: %w", path, err)
+	}
+	return codec, nil
+}
+
+// readJournalFile reads a journal file from store, decoding it when its extension
+// calls for a codec.
+func readJournalFile(store storage.Storage, name string, config *Config) ([]byte, error) {
+	content, err := store.Read(name)
+	if err != nil {
+		return nil, err
+	}
+	return decodeJournal(name, content, config)
+}
+
+// decodeJournal converts journal content stored at name into plain text.
+func decodeJournal(name string, data []byte, config *Config) ([]byte, error) {
+	codec, err := codecForPath(name, config)
+	if err != nil || codec == nil {
+		return data, err
+	}
+	decoded, err := codec.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+	return decoded, nil
+}
+
+// encodeJournal converts plain journal content into the form stored at name.
+func encodeJournal(name string, data []byte, config *Config) ([]byte, error) {
+	codec, err := codecForPath(name, config)
+	if err != nil || codec == nil {
+		return data, err
+	}
+	encoded, err := codec.Encode(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", name, err)
+	}
+	return encoded, nil
+}
+
+// writeJournalFile writes a journal file to store, encoding it when its
+// extension calls for a codec.
+func writeJournalFile(store storage.Storage, name string, data []byte, config *Config) error {
+	encoded, err := encodeJournal(name, data, config)
+	if err != nil {
+		return err
+	}
+	return store.Write(name, bytes.NewReader(encoded))
+}
+
+// pendingWrite is journal content waiting to be written by writeJournalFiles.
+type pendingWrite struct {
+	name string
+	data []byte
+}
+
+// writeJournalFiles writes several journals as one unit. All contents are encoded
+// before anything is written, and if a write fails the journals already written
+// are restored to their previous content.
+func writeJournalFiles(store storage.Storage, writes []pendingWrite, config *Config) error {
+	tx := storage.NewTransaction(store)
+	for _, w := range writes {
+		data, err := encodeJournal(w.name, w.data, config)
+		if err != nil {
+			return err
+		}
+		tx.Write(w.name, data)
+	}
+	return tx.Commit()
+}
+
+// resolveJournalName returns the name of the existing journal for date in store,
+// which may be stored encoded. If no journal exists, the plain markdown name is returned.
+func resolveJournalName(store storage.Storage, date string, config *Config) string {
+	return resolveEncodedName(store, journalName(date, config))
+}
+
+// resolveEncodedName returns name if it exists in store, or else the name of an
+// existing encoded variant of it, e.g. backlog.md.age. If neither exists, name is
+// returned.
+func resolveEncodedName(store storage.Storage, name string) string {
+	if _, err := store.Stat(name); err == nil {
+		return name
+	}
+
+	extensions := make([]string, 0, len(journalCodecs))
+	for ext := range journalCodecs {
+		extensions = append(extensions, ext)
+	}
+	sort.Strings(extensions)
+	for _, ext := range extensions {
+		if _, err := store.Stat(name + ext); err == nil {
+			return name + ext
+		}
+	}
+
+	return name
+}
+
+// codecExt returns the extension of the codec name is encoded with, e.g. ".age",
+// or an empty string if it is plain markdown.
+func codecExt(name string) string {
+	if _, ok := journalCodecs[filepath.Ext(name)]; ok {
+		return filepath.Ext(name)
+	}
+	return ""
+}
+
+// encodedLike returns the name to write content taken from the journal source
+// to: name with the extension of the codec of source appended, so that an encoded
+// journal's todos are not written out as plain text. A name that calls for a codec
+// of its own, or any name for a plain source, is returned as is.
+func encodedLike(name, source string) string {
+	if codecExt(name) != "" {
+		return name
+	}
+	return name + codecExt(source)
+}
+
+// execCodec is a Codec that pipes content through an external command.
+type execCodec struct {
+	command    string
+	decodeArgs []string
+	encodeArgs []string
+}
+
+// Decode runs the command with the decode arguments.
+func (c *execCodec) Decode(data []byte) ([]byte, error) {
+	return c.run(c.decodeArgs, data)
+}
+
+// Encode runs the command with the encode arguments.
+func (c *execCodec) Encode(data []byte) ([]byte, error) {
+	return c.run(c.encodeArgs, data)
+}
+
+// run feeds data to the command on stdin and returns its stdout.
+func (c *execCodec) run(args []string, data []byte) ([]byte, error) {
+	cmd := exec.Command(c.command, args...)
+	cmd.Stdin = bytes.NewReader(data)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s failed: %w: %s", c.command, err, msg)
+		}
+		return nil, fmt.Errorf("%s failed: %w", c.command, err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// newAgeCodec builds a codec for age-encrypted journals using the age command.
+func newAgeCodec(config *Config) (generator.Codec, error) {
+	enc := config.Encryption
+	if len(enc.AgeRecipients) == 0 {
+		return nil, errors.New("encryption.age_recipients is not configured")
+	}
+	if enc.AgeIdentity == "" {
+		return nil, errors.New("encryption.age_identity is not configured")
+	}
+
+	encodeArgs := []string{"--encrypt"}
+	for _, recipient := range enc.AgeRecipients {
+		encodeArgs = append(encodeArgs, "--recipient", recipient)
+	}
+	return &execCodec{
+		command:    "age",
+		decodeArgs: []string{"--decrypt", "--identity", enc.AgeIdentity},
+		encodeArgs: encodeArgs,
+	}, nil
+}
+
+// newGPGCodec builds a codec for GPG-encrypted journals using the gpg command.
+// Decryption relies on the user's keyring and agent.
+func newGPGCodec(config *Config) (generator.Codec, error) {
+	enc := config.Encryption
+	if len(enc.GPGRecipients) == 0 {
+		return nil, errors.New("encryption.gpg_recipients is not configured")
+	}
+
+	encodeArgs := []string{"--batch", "--yes", "--quiet", "--encrypt"}
+	for _, recipient := range enc.GPGRecipients {
+		encodeArgs = append(encodeArgs, "--recipient", recipient)
+	}
+	return &execCodec{
+		command:    "gpg",
+		decodeArgs: []string{"--batch", "--quiet", "--decrypt"},
+		encodeArgs: encodeArgs,
+	}, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// ANSI escape sequences of the colors todoer writes
+const (
+	ansiReset  = "\x1b[0m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiCyan   = "\x1b[36m"
+)
+
+// palette colors the text written to one output stream, or leaves it alone
+// when disabled.
+type palette struct {
+	enabled bool
+}
+
+// Palettes of standard output and standard error, disabled until setupColors
+// enables them, so output written anywhere but a terminal stays plain
+var (
+	stdoutColors palette
+	stderrColors palette
+)
+
+// setupColors enables the palettes of the standard streams that are terminals,
+// unless noColor is set, the NO_COLOR environment variable is not empty or the
+// terminal is dumb.
+func setupColors(noColor bool) {
+	stdoutColors = palette{enabled: colorEnabled(os.Stdout, noColor)}
+	stderrColors = palette{enabled: colorEnabled(os.Stderr, noColor)}
+}
+
+// colorEnabled reports whether text written to f is colored.
+func colorEnabled(f *os.File, noColor bool) bool {
+	if noColor || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return isTerminal(f)
+}
+
+// isTerminal reports whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// paint returns text in color if the palette is enabled.
+func (p palette) paint(color, text string) string {
+	if !p.enabled || text == "" {
+		return text
+	}
+	return color + text + ansiReset
+}
+
+// green colors successes and completed counts.
+func (p palette) green(text string) string {
+	return p.paint(ansiGreen, text)
+}
+
+// yellow colors warnings.
+func (p palette) yellow(text string) string {
+	return p.paint(ansiYellow, text)
+}
+
+// red colors errors and failures.
+func (p palette) red(text string) string {
+	return p.paint(ansiRed, text)
+}
+
+// diff colors the lines of a unified diff: removed lines red, added lines green
+// and hunk headers cyan.
+func (p palette) diff(diff string) string {
+	if !p.enabled {
+		return diff
+	}
+	lines := strings.SplitAfter(diff, "\n")
+	for i, line := range lines {
+		text := strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "---"), strings.HasPrefix(line, "+++"):
+			continue
+		case strings.HasPrefix(line, "-"):
+			text = p.red(text)
+		case strings.HasPrefix(line, "+"):
+			text = p.green(text)
+		case strings.HasPrefix(line, "@@"):
+			text = p.paint(ansiCyan, text)
+		default:
+			continue
+		}
+		lines[i] = text + line[len(strings.TrimSuffix(line, "\n")):]
+	}
+	return strings.Join(lines, "")
+}
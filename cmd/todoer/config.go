@@ -5,46 +5,266 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
+	"github.com/inful/todoer/pkg/core"
+	"github.com/inful/todoer/pkg/storage"
 )
 
 // Config represents the configuration file structure
 type Config struct {
-	RootDir            string                 `toml:"root_dir"`
-	TemplateFile       string                 `toml:"template_file"`
-	Custom             map[string]interface{} `toml:"custom_variables"`
-	FrontmatterDateKey string                 `toml:"frontmatter_date_key"`
-	TodosHeader        string                 `toml:"todos_header"`
+	RootDir             string                  `toml:"root_dir"`
+	TemplateFile        string                  `toml:"template_file"`
+	Templates           map[string]string       `toml:"templates"`
+	Custom              map[string]interface{}  `toml:"custom_variables"`
+	SecretVars          []string                `toml:"secret_vars"`
+	FrontmatterDateKey  string                  `toml:"frontmatter_date_key"`
+	TodosHeader         string                  `toml:"todos_header"`
+	IndentSpaces        int                     `toml:"indent_spaces"`
+	UseTabs             bool                    `toml:"use_tabs"`
+	Dialect             string                  `toml:"dialect"`
+	DayHeader           string                  `toml:"day_header"`
+	OpenMarkers         string                  `toml:"open_markers"`
+	DoneMarkers         string                  `toml:"done_markers"`
+	DateTag             string                  `toml:"date_tag"`
+	CompletionTime      bool                    `toml:"completion_time"`
+	CarryMarker         string                  `toml:"carry_marker"`
+	MovedMessage        string                  `toml:"moved_message"`
+	OmitMovedMessage    bool                    `toml:"omit_moved_message"`
+	Timezone            string                  `toml:"timezone"`
+	DayRolloverHour     int                     `toml:"day_rollover_hour"`
+	DateLocale          string                  `toml:"date_locale"`
+	WeekStart           string                  `toml:"week_start"`
+	SkipWeekends        bool                    `toml:"skip_weekends"`
+	HolidaysFile        string                  `toml:"holidays_file"`
+	CollapseCarried     bool                    `toml:"collapse_carried"`
+	AnnotateCarried     bool                    `toml:"annotate_carried"`
+	MaxCarry            int                     `toml:"max_carry"`
+	Assignee            string                  `toml:"assignee"`
+	CascadeComplete     bool                    `toml:"cascade_complete"`
+	AutoCompleteParent  bool                    `toml:"auto_complete_parent"`
+	StrictTemplates     bool                    `toml:"strict_templates"`
+	SprigFunctions      bool                    `toml:"sprig_functions"`
+	Deterministic       bool                    `toml:"deterministic_templates"`
+	StampVersion        bool                    `toml:"stamp_version"`
+	BacklogFile         string                  `toml:"backlog_file"`
+	JournalFile         string                  `toml:"journal_file"`
+	PreviousJournal     string                  `toml:"previous_journal"`
+	PreviousJournalDays int                     `toml:"previous_journal_days"`
+	Ignore              []string                `toml:"ignore"`
+	Symlinks            string                  `toml:"symlinks"`
+	Timeout             string                  `toml:"timeout"`
+	ReadOnly            bool                    `toml:"read_only"`
+	FileMode            string                  `toml:"file_mode"`
+	Retry               RetryConfig             `toml:"retry"`
+	DependencyPolicy    string                  `toml:"dependency_policy"`
+	Limits              LimitsConfig            `toml:"limits"`
+	Encryption          EncryptionConfig        `toml:"encryption"`
+	CalDAV              CalDAVConfig            `toml:"caldav"`
+	Taskwarrior         TaskwarriorConfig       `toml:"taskwarrior"`
+	Notify              NotifyConfig            `toml:"notify"`
+	Email               EmailConfig             `toml:"email"`
+	Digest              DigestConfig            `toml:"digest"`
+	Post                PostConfig              `toml:"post"`
+	Open                OpenConfig              `toml:"open"`
+	Outputs             map[string]OutputConfig `toml:"outputs"`
+}
+
+// LimitsConfig bounds the journals todoer parses, see core.Limits. Zero keeps a
+// default, a negative value disables the limit.
+type LimitsConfig struct {
+	MaxLineLength int `toml:"max_line_length"` // Longest line of a todos section in bytes
+	MaxDepth      int `toml:"max_depth"`       // Deepest nesting of todos
+	MaxFileSize   int `toml:"max_file_size"`   // Largest journal in bytes
+}
+
+// RetryConfig configures retries of file operations failing with transient errors,
+// as on network filesystems
+type RetryConfig struct {
+	Attempts int    `toml:"attempts"` // Tries of a file operation; 1 disables retries
+	Backoff  string `toml:"backoff"`  // Wait before the second try, doubled before every further one
+}
+
+// EncryptionConfig configures access to encrypted journals (*.md.age, *.md.gpg)
+type EncryptionConfig struct {
+	AgeRecipients []string `toml:"age_recipients"` // Recipients used when encrypting with age
+	AgeIdentity   string   `toml:"age_identity"`   // Identity file used when decrypting with age
+	GPGRecipients []string `toml:"gpg_recipients"` // Recipients used when encrypting with gpg
+}
+
+// CalDAVConfig configures the CalDAV server used by sync caldav
+type CalDAVConfig struct {
+	URL      string `toml:"url"`      // Calendar home URL, optionally with credentials
+	Calendar string `toml:"calendar"` // Display name or path segment of the task list
+}
+
+// TaskwarriorConfig configures the Taskwarrior program used by sync taskwarrior
+type TaskwarriorConfig struct {
+	Command string `toml:"command"` // Program run for task export and task import, defaults to task
+}
+
+// NotifyConfig configures the thresholds and notifiers used by notify
+type NotifyConfig struct {
+	StaleDays   int      `toml:"stale_days"`   // Days a task may stay open before it is stale; negative disables
+	OverdueDays int      `toml:"overdue_days"` // Grace days after the due date before a task is overdue
+	Notifiers   []string `toml:"notifiers"`    // Notifiers to send to: desktop, webhook, ntfy
+	WebhookURL  string   `toml:"webhook_url"`  // URL the webhook notifier posts to
+	NtfyServer  string   `toml:"ntfy_server"`  // ntfy server, defaults to https://ntfy.sh
+	NtfyTopic   string   `toml:"ntfy_topic"`   // ntfy topic to publish to
+	NtfyToken   string   `toml:"ntfy_token"`   // Optional ntfy access token
+}
+
+// EmailConfig configures the smtp and sendmail notifiers
+type EmailConfig struct {
+	From         string   `toml:"from"`          // Sender address
+	To           []string `toml:"to"`            // Recipient addresses
+	SMTPServer   string   `toml:"smtp_server"`   // host:port of the SMTP server used by the smtp notifier
+	SMTPUsername string   `toml:"smtp_username"` // Optional user for SMTP authentication
+	SMTPPassword string   `toml:"smtp_password"` // Password for SMTP authentication
+	Sendmail     string   `toml:"sendmail"`      // Program used by the sendmail notifier, defaults to /usr/sbin/sendmail
+}
+
+// DigestConfig configures the digest command
+type DigestConfig struct {
+	Since     string   `toml:"since"`     // Period reported, e.g. "7d" or "2w"
+	Template  string   `toml:"template"`  // Digest template, defaults to the built-in one
+	Notifiers []string `toml:"notifiers"` // Notifiers the digest is sent to
+}
+
+// PostConfig configures the post command and posting after new and process
+type PostConfig struct {
+	WebhookURL   string `toml:"webhook_url"`   // Slack or Discord incoming webhook URL
+	Format       string `toml:"format"`        // slack or discord, defaults by the host of the webhook URL
+	Select       string `toml:"select"`        // Tasks posted: open or completed, defaults to open
+	Template     string `toml:"template"`      // Message template, defaults to the built-in one
+	AfterProcess bool   `toml:"after_process"` // Post the open tasks of every journal written by new and process
+}
+
+// OpenConfig configures how the open command opens a journal
+type OpenConfig struct {
+	Mode      string `toml:"mode"`       // print, editor or obsidian, defaults to print
+	Vault     string `toml:"vault"`      // Obsidian vault name, defaults to the name of the journal root
+	VaultPath string `toml:"vault_path"` // Folder of the journal root inside the vault, empty if it is the vault
+}
+
+// OutputConfig configures an extra file written when a journal is processed
+type OutputConfig struct {
+	Path      string `toml:"path"`       // File written, relative to the journal root
+	Template  string `toml:"template"`   // Template file; empty writes only the selected todos
+	Select    string `toml:"select"`     // Todos written: open, completed or stale
+	Append    bool   `toml:"append"`     // Append to the file instead of replacing it
+	StaleDays int    `toml:"stale_days"` // Days a todo is open before it is stale; defaults to notify.stale_days
+
+	SplitByAssignee bool `toml:"split_by_assignee"` // Write a file per assignee, named by {assignee} in Path
+	AnnotateCreated bool `toml:"annotate_created"`  // Annotate todos with created::[[date]]
+}
+
+// Sources of configuration values, see loadedConfig
+const (
+	sourceDefault   = "default"
+	sourceFile      = "config file"
+	sourceLocalFile = "local config file"
+)
+
+// localLookup says where a command looks for a project-local .todoer.toml.
+type localLookup struct {
+	RootDir string // Root directory given on the command line, if any
+	File    string // Journal the command reads, searched upwards from, if any
+}
+
+// loadedConfig is a configuration before validation, with where its values came from.
+type loadedConfig struct {
+	Config    *Config
+	Path      string            // Path of the config file, which may not exist
+	Found     bool              // Whether the config file exists
+	LocalPath string            // Path of the project-local config file, empty if none was found
+	Sources   map[string]string // Source of the values not left at their default, by dotted TOML key
+	Unknown   []string          // Keys of the config file that todoer does not know
 }
 
 // loadConfig loads configuration from file, environment variables, and CLI flags
-// Priority: CLI flags > environment variables > config file > defaults
-func loadConfig() (*Config, error) {
-	config := &Config{}
+// Priority: CLI flags > environment variables > local config file > config file > defaults
+func loadConfig(lookup localLookup) (*Config, error) {
+	configPath, err := configFilePath()
+	if err != nil {
+		return nil, err
+	}
 
-	// Determine config file path
+	loaded, err := readConfig(configPath, lookup)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate the final configuration
+	if err := validateConfig(loaded.Config); err != nil {
+		return nil, fmt.Errorf("configuration validation failed: %w", err)
+	}
+
+	return loaded.Config, nil
+}
+
+// configFilePath returns the path of config.toml in the todoer config directory.
+func configFilePath() (string, error) {
 	configHome, err := getConfigDir()
 	if err != nil {
-		return nil, fmt.Errorf("could not determine config directory: %w", err)
+		return "", fmt.Errorf("could not determine config directory: %w", err)
 	}
-	configDir := filepath.Join(configHome, ConfigDirName)
-	configPath := filepath.Join(configDir, ConfigFileName)
+	return filepath.Join(configHome, ConfigDirName, ConfigFileName), nil
+}
+
+// readConfig reads the config file at configPath if it exists, then the project-local
+// config file found through lookup, applies environment variables and fills in
+// defaults, without validating the result.
+func readConfig(configPath string, lookup localLookup) (*loadedConfig, error) {
+	loaded := &loadedConfig{Config: &Config{}, Path: configPath, Sources: make(map[string]string)}
+	config := loaded.Config
 
 	// Load from config file if it exists
 	if _, err := os.Stat(configPath); err == nil {
+		loaded.Found = true
 		// config loaded from file; logging omitted to keep config package decoupled
-		if err := loadConfigFromFile(configPath, config); err != nil {
+		meta, err := loadConfigFromFile(configPath, config)
+		if err != nil {
 			return nil, err
 		}
+		for _, key := range meta.Keys() {
+			loaded.Sources[key.String()] = sourceFile
+		}
+		for _, key := range meta.Undecoded() {
+			loaded.Unknown = append(loaded.Unknown, key.String())
+		}
+	}
+
+	// Override with the project-local config file
+	rootDir := lookup.RootDir
+	if rootDir == "" {
+		rootDir = getConfigValue(expandPath(os.Getenv("TODOER_ROOT_DIR")), config.RootDir)
+	}
+	if localPath := findLocalConfig(lookup.File, rootDir); localPath != "" {
+		loaded.LocalPath = localPath
+		meta, err := loadConfigFromFile(localPath, config)
+		if err != nil {
+			return nil, err
+		}
+		resolveLocalPaths(config, meta, filepath.Dir(localPath))
+		for _, key := range meta.Keys() {
+			loaded.Sources[key.String()] = sourceLocalFile
+		}
+		for _, key := range meta.Undecoded() {
+			loaded.Unknown = append(loaded.Unknown, fmt.Sprintf("%s in %s", key, localPath))
+		}
 	}
 
 	// Override with environment variables
 	if rootDir := os.Getenv("TODOER_ROOT_DIR"); rootDir != "" {
 		config.RootDir = expandPath(rootDir)
+		loaded.Sources["root_dir"] = "environment TODOER_ROOT_DIR"
 	}
 	if templateFile := os.Getenv("TODOER_TEMPLATE_FILE"); templateFile != "" {
 		config.TemplateFile = expandPath(templateFile)
+		config.Templates = nil // An explicit template overrides the rules
+		loaded.Sources["template_file"] = "environment TODOER_TEMPLATE_FILE"
 	}
 
 	// Set defaults if not specified
@@ -57,24 +277,38 @@ func loadConfig() (*Config, error) {
 	if config.TodosHeader == "" {
 		config.TodosHeader = "## Todos"
 	}
-
-	// Validate the final configuration
-	if err := validateConfig(config); err != nil {
-		return nil, fmt.Errorf("configuration validation failed: %w", err)
+	if config.BacklogFile == "" {
+		config.BacklogFile = BacklogFileName
+	}
+	if config.Notify.StaleDays == 0 {
+		config.Notify.StaleDays = DefaultStaleDays
+	}
+	if config.Digest.Since == "" {
+		config.Digest.Since = DefaultDigestSince
+	}
+	if config.Post.Select == "" {
+		config.Post.Select = PostOpen
+	}
+	if config.Retry.Attempts == 0 {
+		config.Retry.Attempts = DefaultRetryAttempts
+	}
+	if config.Retry.Backoff == "" {
+		config.Retry.Backoff = DefaultRetryBackoff
 	}
 
-	return config, nil
+	return loaded, nil
 }
 
 // loadConfigFromFile loads configuration from a TOML file
-func loadConfigFromFile(configPath string, config *Config) error {
+func loadConfigFromFile(configPath string, config *Config) (toml.MetaData, error) {
 	content, err := os.ReadFile(configPath)
 	if err != nil {
-		return fmt.Errorf("failed to read config file %s: %w", configPath, err)
+		return toml.MetaData{}, fmt.Errorf("failed to read config file %s: %w", configPath, err)
 	}
 
-	if err := toml.Unmarshal(content, config); err != nil {
-		return fmt.Errorf("failed to decode config file %s: %w", configPath, err)
+	meta, err := toml.Decode(string(content), config)
+	if err != nil {
+		return toml.MetaData{}, fmt.Errorf("failed to decode config file %s: %w", configPath, err)
 	}
 
 	// Expand paths in config
@@ -84,8 +318,94 @@ func loadConfigFromFile(configPath string, config *Config) error {
 	if config.TemplateFile != "" {
 		config.TemplateFile = expandPath(config.TemplateFile)
 	}
+	if config.Encryption.AgeIdentity != "" {
+		config.Encryption.AgeIdentity = expandPath(config.Encryption.AgeIdentity)
+	}
+	if config.HolidaysFile != "" {
+		config.HolidaysFile = expandPath(config.HolidaysFile)
+	}
+	// Template rules and outputs name templates next to the config file by default
+	for rule, file := range config.Templates {
+		if meta.IsDefined("templates", rule) {
+			config.Templates[rule] = configRelativeTemplate(configPath, file)
+		}
+	}
+	if meta.IsDefined("digest", "template") {
+		config.Digest.Template = configRelativeTemplate(configPath, config.Digest.Template)
+	}
+	if meta.IsDefined("post", "template") {
+		config.Post.Template = configRelativeTemplate(configPath, config.Post.Template)
+	}
+	for name, output := range config.Outputs {
+		if meta.IsDefined("outputs", name, "template") {
+			output.Template = configRelativeTemplate(configPath, output.Template)
+			config.Outputs[name] = output
+		}
+	}
+
+	return meta, nil
+}
+
+// findLocalConfig returns the path of the project-local config file for a command:
+// the nearest .todoer.toml in the directory of file or above it, else the one in
+// rootDir if that is on local disk. It returns an empty string if there is none.
+func findLocalConfig(file, rootDir string) string {
+	if file != "" {
+		if dir, err := filepath.Abs(filepath.Dir(file)); err == nil {
+			for {
+				path := filepath.Join(dir, LocalConfigFile)
+				if _, err := os.Stat(path); err == nil {
+					return path
+				}
+				parent := filepath.Dir(dir)
+				if parent == dir {
+					break
+				}
+				dir = parent
+			}
+		}
+	}
+
+	if rootDir == "" {
+		rootDir = "."
+	}
+	s, err := storage.Open(rootDir)
+	if err != nil || !storage.IsLocal(s) {
+		return ""
+	}
+	if _, err := s.Stat(LocalConfigFile); err != nil {
+		return ""
+	}
+	return s.Location(LocalConfigFile)
+}
 
-	return nil
+// resolveLocalPaths makes the relative paths set by a project-local config file
+// relative to dir, the directory of that file.
+func resolveLocalPaths(config *Config, meta toml.MetaData, dir string) {
+	resolve := func(path *string, key ...string) {
+		if meta.IsDefined(key...) && *path != "" && !filepath.IsAbs(*path) && !strings.Contains(*path, "://") {
+			*path = filepath.Join(dir, *path)
+		}
+	}
+	resolve(&config.RootDir, "root_dir")
+	if !isTemplateName(config.TemplateFile) {
+		resolve(&config.TemplateFile, "template_file")
+	}
+	resolve(&config.Encryption.AgeIdentity, "encryption", "age_identity")
+}
+
+// configRelativeTemplate expands the template path and makes it relative to the
+// directory of the config file at configPath if it is relative. Named templates
+// and URLs are returned unchanged.
+func configRelativeTemplate(configPath, path string) string {
+	if !isTemplatePath(path) {
+		return path
+	}
+	path = expandPath(path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(configPath), path)
+	}
+	return path
 }
 
 // expandPath expands ~ to the user's home directory
@@ -117,3 +437,123 @@ func getConfigDir() (string, error) {
 	}
 	return filepath.Join(homeDir, ".config"), nil
 }
+
+// getStateDir returns the directory for runtime state based on XDG or default
+func getStateDir() (string, error) {
+	if xdgStateHome := os.Getenv("XDG_STATE_HOME"); xdgStateHome != "" {
+		return xdgStateHome, nil
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".local", "state"), nil
+}
+
+// getCacheDir returns the directory for disposable cached data based on XDG or default
+func getCacheDir() (string, error) {
+	if xdgCacheHome := os.Getenv("XDG_CACHE_HOME"); xdgCacheHome != "" {
+		return xdgCacheHome, nil
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".cache"), nil
+}
+
+// getDataDir returns the directory for persistent application data based on XDG or default
+func getDataDir() (string, error) {
+	if xdgDataHome := os.Getenv("XDG_DATA_HOME"); xdgDataHome != "" {
+		return xdgDataHome, nil
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".local", "share"), nil
+}
+
+// journalFormat returns the configured layout of TODOS sections.
+func journalFormat(config *Config) core.Format {
+	format := core.DefaultFormat
+	if config.IndentSpaces != 0 {
+		format.IndentSpaces = config.IndentSpaces
+	}
+	format.UseTabs = config.UseTabs
+	format.DayHeader = config.DayHeader
+	format.OpenMarkers = config.OpenMarkers
+	format.DoneMarkers = config.DoneMarkers
+	format.DateTag = config.DateTag
+	format.CarryMarker = config.CarryMarker
+	format.MovedMessage = config.MovedMessage
+	format.Limits = core.Limits{
+		MaxLineLength: config.Limits.MaxLineLength,
+		MaxDepth:      config.Limits.MaxDepth,
+		MaxFileSize:   config.Limits.MaxFileSize,
+	}
+	switch config.Dialect {
+	case DialectLogseq:
+		format.UseTabs = true
+		format.Keywords = true
+	case DialectGFM:
+		format.GFM = true
+	}
+	return format
+}
+
+// configLocation returns the configured timezone, or the local one if none is
+// configured or it cannot be loaded.
+func configLocation(config *Config) *time.Location {
+	if config == nil || config.Timezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(config.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// configNow returns the current time in the configured timezone, which decides
+// when one day's journal gives way to the next.
+func configNow(config *Config) time.Time {
+	return time.Now().In(configLocation(config))
+}
+
+// effectiveDay returns the day now belongs to: before day_rollover_hour it is
+// still the previous day, so a journal written after midnight lands on the
+// day it belongs to. Only the date of the result is meaningful.
+func effectiveDay(now time.Time, config *Config) time.Time {
+	if config != nil && now.Hour() < config.DayRolloverHour {
+		return now.AddDate(0, 0, -1)
+	}
+	return now
+}
+
+// effectiveToday returns today's date in the configured timezone, taking
+// day_rollover_hour into account.
+func effectiveToday(config *Config) string {
+	return effectiveDay(configNow(config), config).Format(core.DateFormat)
+}
+
+// journalParser returns the parser of TODOS sections with the configured header and layout.
+func journalParser(config *Config, opts ...core.Option) *core.Parser {
+	return core.NewParser(append([]core.Option{core.WithHeader(todosHeader(config)), core.WithFormat(journalFormat(config))}, opts...)...)
+}
+
+// journalRenderer returns the renderer of TODOS sections with the configured header and layout.
+func journalRenderer(config *Config) *core.Renderer {
+	return core.NewRenderer(core.WithHeader(todosHeader(config)), core.WithFormat(journalFormat(config)))
+}
+
+// todosHeader returns the configured TODOS header, falling back to the default.
+func todosHeader(config *Config) string {
+	if config.TodosHeader == "" {
+		return core.TodosHeader
+	}
+	return config.TodosHeader
+}
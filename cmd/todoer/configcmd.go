@@ -0,0 +1,215 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+//go:embed default_config.toml
+var defaultConfigFile string
+
+// redactedKeys are the config keys whose values config show does not print
+var redactedKeys = map[string]bool{
+	"notify.ntfy_token":   true,
+	"email.smtp_password": true,
+	"post.webhook_url":    true,
+}
+
+// configValue is one setting of the effective configuration.
+type configValue struct {
+	Key    string      // Dotted TOML key
+	Value  interface{} // Effective value
+	Source string      // Where the value came from
+}
+
+// configValues flattens the fields of v, a Config or one of its tables, into
+// settings with the dotted TOML keys below prefix. Tables are returned after the
+// plain values they follow in the file.
+func configValues(v reflect.Value, prefix string, sources map[string]string) []configValue {
+	var values, tables []configValue
+	for i := 0; i < v.NumField(); i++ {
+		tag := strings.Split(v.Type().Field(i).Tag.Get("toml"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		key := prefix + tag
+		field := v.Field(i)
+		switch field.Kind() {
+		case reflect.Struct:
+			tables = append(tables, configValues(field, key+".", sources)...)
+		case reflect.Map:
+			keys := field.MapKeys()
+			sort.Slice(keys, func(a, b int) bool { return keys[a].String() < keys[b].String() })
+			for _, k := range keys {
+				if field.MapIndex(k).Kind() == reflect.Struct {
+					tables = append(tables, configValues(field.MapIndex(k), key+"."+k.String()+".", sources)...)
+					continue
+				}
+				source := sources[key+"."+k.String()]
+				if source == "" {
+					source = sourceFile
+				}
+				tables = append(tables, configValue{Key: key + "." + k.String(), Value: field.MapIndex(k).Interface(), Source: source})
+			}
+		default:
+			source := sources[key]
+			if source == "" {
+				source = sourceDefault
+			}
+			values = append(values, configValue{Key: key, Value: field.Interface(), Source: source})
+		}
+	}
+	return append(values, tables...)
+}
+
+// tomlValue formats v as a TOML value.
+func tomlValue(v interface{}) string {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice && rv.Len() == 0 {
+		return "[]"
+	}
+	var buf strings.Builder
+	if err := toml.NewEncoder(&buf).Encode(map[string]interface{}{"v": v}); err != nil {
+		return fmt.Sprintf("%q", fmt.Sprint(v))
+	}
+	return strings.TrimSpace(strings.TrimPrefix(buf.String(), "v = "))
+}
+
+// redactValue hides secrets in the value of key: tokens entirely and passwords in URLs.
+func redactValue(key string, v interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok || s == "" {
+		return v
+	}
+	if redactedKeys[key] {
+		return "xxxxx"
+	}
+	if u, err := url.Parse(s); err == nil && u.User != nil {
+		return u.Redacted()
+	}
+	return v
+}
+
+// cmdConfigShow prints the effective configuration to w as TOML, with the source of
+// each value as a comment. The rootDir and templateFile flags override the loaded
+// values like they do for other commands. Secrets, including the custom variables
+// named in secret_vars, are redacted.
+func cmdConfigShow(w io.Writer, loaded *loadedConfig, rootDir, templateFile string) error {
+	config := *loaded.Config
+	sources := make(map[string]string, len(loaded.Sources))
+	for key, source := range loaded.Sources {
+		sources[key] = source
+	}
+	if rootDir != "" {
+		config.RootDir = rootDir
+		sources["root_dir"] = "flag --root-dir"
+	}
+	if templateFile != "" {
+		config.TemplateFile = templateFile
+		sources["template_file"] = "flag --template-file"
+	}
+
+	var b strings.Builder
+	if loaded.Found {
+		fmt.Fprintf(&b, "# Config file: %s\n", loaded.Path)
+	} else {
+		fmt.Fprintf(&b, "# Config file: %s (not found)\n", loaded.Path)
+	}
+	if loaded.LocalPath != "" {
+		fmt.Fprintf(&b, "# Local config file: %s\n", loaded.LocalPath)
+	}
+
+	table := ""
+	for _, v := range configValues(reflect.ValueOf(config), "", sources) {
+		name := v.Key
+		if i := strings.LastIndex(v.Key, "."); i >= 0 {
+			if v.Key[:i] != table {
+				table = v.Key[:i]
+				fmt.Fprintf(&b, "\n[%s]\n", table)
+			}
+			name = v.Key[i+1:]
+		}
+		value := redactValue(v.Key, v.Value)
+		if custom, ok := strings.CutPrefix(v.Key, "custom_variables."); ok && slices.Contains(config.SecretVars, custom) {
+			value = "xxxxx"
+		}
+		fmt.Fprintf(&b, "%s = %s # %s\n", name, tomlValue(value), v.Source)
+	}
+	if len(loaded.Unknown) > 0 {
+		b.WriteString("\n")
+	}
+	for _, key := range loaded.Unknown {
+		fmt.Fprintf(&b, "# Unknown key %s is ignored\n", key)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// cmdConfigValidate checks the loaded configuration for unknown keys in the config
+// file and invalid values, and reports the outcome on w.
+func cmdConfigValidate(w io.Writer, loaded *loadedConfig) error {
+	var problems []string
+	for _, key := range loaded.Unknown {
+		problems = append(problems, fmt.Sprintf("unknown key %s", key))
+	}
+	if err := validateConfig(loaded.Config); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	location := loaded.Path
+	if !loaded.Found {
+		location = "defaults (no config file at " + loaded.Path + ")"
+	}
+	if loaded.LocalPath != "" {
+		location += " and " + loaded.LocalPath
+	}
+	if len(problems) == 0 {
+		fmt.Fprintf(w, "Configuration is valid: %s\n", location)
+		return nil
+	}
+
+	fmt.Fprintf(w, "Configuration has %d %s: %s\n", len(problems), plural(len(problems), "problem", "problems"), location)
+	for _, problem := range problems {
+		fmt.Fprintf(w, "  - %s\n", problem)
+	}
+	return withExitCode(ExitConfigError, fmt.Errorf("invalid configuration in %s", location))
+}
+
+// cmdConfigInit writes a commented default config.toml and the default template.md
+// into configDir. Existing files are kept unless force is set.
+func cmdConfigInit(w io.Writer, configDir string, force bool, logger *Logger) error {
+	if err := os.MkdirAll(configDir, dirMode()); err != nil {
+		return withExitCode(ExitWriteError, fmt.Errorf("failed to create config directory: %w", err))
+	}
+
+	files := []struct {
+		name    string
+		content string
+	}{
+		{ConfigFileName, defaultConfigFile},
+		{TemplateFileName, defaultTemplate},
+	}
+	for _, file := range files {
+		path := filepath.Join(configDir, file.name)
+		if _, err := os.Stat(path); err == nil && !force {
+			fmt.Fprintf(w, "Kept existing %s (use --force to overwrite)\n", path)
+			continue
+		}
+		if err := safeWriteFile(path, []byte(file.content), fileMode); err != nil {
+			return withExitCode(ExitWriteError, fmt.Errorf("failed to write %s: %w", path, err))
+		}
+		logger.Debug("Wrote %s", path)
+		fmt.Fprintf(w, "Wrote %s\n", path)
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/inful/todoer/pkg/core"
+	"github.com/inful/todoer/pkg/storage"
+)
+
+// conflictPatterns match the names sync tools give conflicting copies of a file.
+// The first group is the original name without its extension, the second the extension.
+var conflictPatterns = []*regexp.Regexp{
+	// Dropbox: 2025-06-21 (conflicted copy).md, 2025-06-21 (Alice's conflicted copy 2025-06-22).md
+	regexp.MustCompile(`^(.+) \([^()]*conflicted copy[^()]*\)(\.[^.]*)?$`),
+	// Syncthing: 2025-06-21.sync-conflict-20250621-101010-ABCDEFG.md
+	regexp.MustCompile(`^(.+)\.sync-conflict-\d{8}-\d{6}-[A-Z0-9]{7}(\.[^.]*)?$`),
+}
+
+// journalConflict is a journal file together with the conflicting copies a sync tool left next to it.
+type journalConflict struct {
+	Original string   // Name of the journal
+	Copies   []string // Names of the conflicting copies, sorted
+}
+
+// conflictOriginal returns the name of the journal a conflicting copy belongs to.
+// It reports false if name is not a conflicting copy of a journal.
+func conflictOriginal(name string) (string, bool) {
+	dir, base := path.Split(name)
+	for _, pattern := range conflictPatterns {
+		if m := pattern.FindStringSubmatch(base); m != nil {
+			original := dir + m[1] + m[2]
+			if _, ok := journalDateFromPath(original); ok {
+				return original, true
+			}
+		}
+	}
+	return "", false
+}
+
+// groupConflicts groups the conflicting copies among files by the journal they belong to.
+func groupConflicts(files []storage.FileInfo) []journalConflict {
+	copies := make(map[string][]string)
+	for _, file := range files {
+		if file.IsDir {
+			continue
+		}
+		if original, ok := conflictOriginal(file.Name); ok {
+			copies[original] = append(copies[original], file.Name)
+		}
+	}
+
+	conflicts := make([]journalConflict, 0, len(copies))
+	for original, names := range copies {
+		sort.Strings(names)
+		conflicts = append(conflicts, journalConflict{Original: original, Copies: names})
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].Original < conflicts[j].Original
+	})
+	return conflicts
+}
+
+// findConflicts returns the conflicting copies of journals anywhere in store.
+func findConflicts(store storage.Storage) ([]journalConflict, error) {
+	var files []storage.FileInfo
+	err := storage.Walk(store, "", func(info storage.FileInfo) error {
+		files = append(files, info)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return groupConflicts(files), nil
+}
+
+// warnConflicts records a warning for every journal with conflicting copies.
+func warnConflicts(summary *resultSummary, store storage.Storage, conflicts []journalConflict, logger *Logger) {
+	for _, conflict := range conflicts {
+		msg := fmt.Sprintf("%s has %d conflicting cop%s: %s; run 'todoer conflicts resolve' to merge them",
+			store.Location(conflict.Original), len(conflict.Copies), plural(len(conflict.Copies), "y", "ies"),
+			strings.Join(conflict.Copies, ", "))
+		summary.addWarning("%s", msg)
+		logger.Warn("%s", msg)
+	}
+}
+
+// plural returns singular for n == 1 and pluralForm otherwise.
+func plural(n int, singular, pluralForm string) string {
+	if n == 1 {
+		return singular
+	}
+	return pluralForm
+}
+
+// cmdConflictsResolve merges the TODOS sections of conflicting copies into their journals.
+// A copy is removed once merged unless it also differs from the journal outside the TODOS
+// section; such copies are kept for manual review. Progress is reported on w.
+func cmdConflictsResolve(w io.Writer, rootDir string, config *Config, logger *Logger) error {
+	store, err := openJournalTree(rootDir, config)
+	if err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+
+	conflicts, err := findConflicts(store)
+	if err != nil {
+		return fmt.Errorf("failed to scan journals in %s: %w", store.Location(""), err)
+	}
+	if len(conflicts) == 0 {
+		fmt.Fprintln(w, "No conflicting copies found")
+		return nil
+	}
+
+	for _, conflict := range conflicts {
+		if err := resolveConflict(w, store, conflict, config, logger); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveConflict merges the copies of one journal and removes the ones fully merged.
+func resolveConflict(w io.Writer, store storage.Storage, conflict journalConflict, config *Config, logger *Logger) error {
+	parser := journalParser(config)
+	header := parser.Header()
+
+	content, err := readJournalFile(store, conflict.Original, config)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", store.Location(conflict.Original), err)
+	}
+	before, _, after, err := core.ExtractTodosSectionWithHeader(string(content), header)
+	if err != nil {
+		return withExitCode(ExitParseError, core.WithFile(err, store.Location(conflict.Original)))
+	}
+	journal, err := parser.ParseJournal(string(content))
+	if err != nil {
+		return withExitCode(ExitParseError, fmt.Errorf("%s: %w", store.Location(conflict.Original), err))
+	}
+
+	var removable []string
+	parsed := 0
+	for _, name := range conflict.Copies {
+		copyContent, err := readJournalFile(store, name, config)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", store.Location(name), err)
+		}
+		copyJournal, err := parser.ParseJournal(string(copyContent))
+		if err != nil {
+			fmt.Fprintf(w, "Skipped %s: %v\n", name, err)
+			continue
+		}
+		core.MergeJournals(journal, copyJournal)
+		parsed++
+
+		copyBefore, _, copyAfter, _ := core.ExtractTodosSectionWithHeader(string(copyContent), header)
+		if copyBefore != before || copyAfter != after {
+			fmt.Fprintf(w, "Kept %s: it has other changes outside the todos section\n", name)
+			continue
+		}
+		removable = append(removable, name)
+	}
+
+	updated, err := journalRenderer(config).ReplaceJournal(string(content), journal)
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %w", store.Location(conflict.Original), err)
+	}
+	if updated != string(content) {
+		if err := writeJournalFile(store, conflict.Original, []byte(updated), config); err != nil {
+			return withExitCode(ExitWriteError, fmt.Errorf("failed to write %s: %w", store.Location(conflict.Original), err))
+		}
+	}
+
+	for _, name := range removable {
+		if err := store.Remove(name); err != nil {
+			return withExitCode(ExitWriteError, fmt.Errorf("failed to remove %s: %w", store.Location(name), err))
+		}
+	}
+
+	fmt.Fprintf(w, "Merged %d conflicting cop%s into %s\n", parsed, plural(parsed, "y", "ies"), conflict.Original)
+	logger.Debug("Removed %d merged copies of %s", len(removable), conflict.Original)
+	return nil
+}
@@ -2,8 +2,42 @@ package main
 
 // Constants for the application
 const (
-	FilePermissions  = 0644
-	ConfigDirName    = "todoer"
-	ConfigFileName   = "config.toml"
-	TemplateFileName = "template.md"
+	FilePermissions        = 0644
+	ConfigDirName          = "todoer"
+	ConfigFileName         = "config.toml"
+	LocalConfigFile        = ".todoer.toml"
+	IgnoreFile             = ".todoerignore"
+	TemplateFileName       = "template.md"
+	TemplatesDirName       = "templates"
+	TimerStateFile         = "timer.json"
+	RunsDirName            = "runs"
+	CalDAVMappingFile      = "caldav.json"
+	TaskwarriorMappingFile = "taskwarrior.json"
+	BacklogFileName        = "backlog.md"
+	IndexCacheFile         = "index.json"
+	LogseqJournalsDir      = "journals"
+)
+
+// Values of dialect, the flavour of markdown journals are written in
+const (
+	DialectMarkdown = "markdown"
+	DialectLogseq   = "logseq"
+	DialectGFM      = "gfm"
+)
+
+// Defaults of the notify command
+const (
+	DefaultStaleDays  = 14
+	DefaultNtfyServer = "https://ntfy.sh"
+)
+
+// Defaults of retries of file operations failing with transient errors
+const (
+	DefaultRetryAttempts = 3
+	DefaultRetryBackoff  = "200ms"
+)
+
+// Defaults of the digest command
+const (
+	DefaultDigestSince = "7d"
 )
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/inful/todoer/pkg/core"
+)
+
+// dateParser returns the parser of natural language dates in the date_locale of
+// config, with weeks beginning on its week_start or else the locale's first day.
+func dateParser(config *Config) (core.DateParser, error) {
+	parser, err := core.NewDateParser(config.DateLocale)
+	if err != nil {
+		return parser, fmt.Errorf("%w: invalid date_locale: %v", ErrInvalidConfig, err)
+	}
+	if config.WeekStart != "" {
+		if parser.WeekStart, err = core.ParseWeekday(config.WeekStart); err != nil {
+			return parser, fmt.Errorf("%w: week_start must be a weekday such as monday, got %q", ErrInvalidConfig, config.WeekStart)
+		}
+	}
+	return parser, nil
+}
+
+// resolveDate returns the YYYY-MM-DD date value refers to, either a YYYY-MM-DD
+// date or a natural language date such as "tomorrow" or "next friday" relative
+// to today. An empty value stays empty.
+func resolveDate(value string, today time.Time, config *Config) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	parser, err := dateParser(config)
+	if err != nil {
+		return "", withExitCode(ExitConfigError, err)
+	}
+	t, err := parser.Parse(value, today)
+	if err != nil {
+		return "", withExitCode(ExitConfigError, fmt.Errorf("%w: %v", ErrInvalidDate, err))
+	}
+	return t.Format(core.DateFormat), nil
+}
+
+// resolveDateFlags replaces the natural language dates given to the date flags of
+// command with YYYY-MM-DD dates relative to today, so that the commands only see
+// YYYY-MM-DD dates. Values that are no dates at all are left for the command to
+// report, as it knows what the date was for.
+func resolveDateFlags(command string, today time.Time, config *Config) error {
+	var flags []*string
+	switch command {
+	case "new":
+		flags = []*string{&CLI.New.Today}
+	case "process <source-file>", "process <source-file> <target-file>":
+		flags = []*string{&CLI.Process.TemplateDate, &CLI.Process.Today, &CLI.Process.From, &CLI.Process.To}
+	case "preview":
+		flags = []*string{&CLI.Preview.Date}
+	case "move <pattern>":
+		flags = []*string{&CLI.Move.From, &CLI.Move.To}
+	case "open", "open <date>":
+		flags = []*string{&CLI.Open.Date}
+	}
+	for _, flag := range flags {
+		date, err := resolveDate(*flag, today, config)
+		switch {
+		case errors.Is(err, ErrInvalidDate):
+			continue
+		case err != nil:
+			return err
+		}
+		*flag = date
+	}
+	return nil
+}
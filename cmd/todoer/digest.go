@@ -0,0 +1,206 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/inful/todoer/pkg/core"
+	"github.com/inful/todoer/pkg/storage"
+)
+
+//go:embed default_digest.md
+var defaultDigestTemplate string
+
+// digestOptions holds the settings of a digest run.
+type digestOptions struct {
+	Since        string   // Start of the period: days or weeks back (7d, 2w) or a YYYY-MM-DD date
+	TemplateFile string   // Digest template; empty for the built-in one
+	Notifiers    []string // Names of the notifiers to send to
+	DryRun       bool     // Print the digest without sending it
+}
+
+// digestData is the data digest templates are rendered with.
+type digestData struct {
+	Since  string                 // First day of the period
+	Until  string                 // Last day of the period, today
+	Total  int                    // Number of tasks completed in the period
+	Days   []digestDay            // Days with completed tasks, oldest first
+	Custom map[string]interface{} // Custom variables from the configuration
+}
+
+// digestDay holds the tasks completed on one day.
+type digestDay struct {
+	Date    string   // Date in YYYY-MM-DD format
+	Weekday string   // Name of the weekday, e.g. "Monday"
+	Tasks   []string // Texts of the tasks, without their date tag
+}
+
+// parseSince returns the first day of the period given by since, relative to today:
+// a number of days or weeks such as "7d" or "2w", or a date, as YYYY-MM-DD or in
+// natural language such as "2 weeks ago" read by parser. A period of 7 days ending
+// today starts 6 days before it.
+func parseSince(since string, today time.Time, parser core.DateParser) (string, error) {
+	if n, unit, ok := sincePeriod(since); ok {
+		if n < 1 {
+			return "", fmt.Errorf("invalid period %q (use e.g. 7d, 2w, YYYY-MM-DD or 2 weeks ago)", since)
+		}
+		return today.AddDate(0, 0, 1-n*unit).Format(core.DateFormat), nil
+	}
+
+	t, err := parser.Parse(since, today)
+	if err != nil {
+		return "", fmt.Errorf("invalid period %q (use e.g. 7d, 2w, YYYY-MM-DD or 2 weeks ago)", since)
+	}
+	if t.After(today) {
+		return "", fmt.Errorf("period start %s lies in the future", t.Format(core.DateFormat))
+	}
+	return t.Format(core.DateFormat), nil
+}
+
+// sincePeriod returns the number of units and the days per unit of a period
+// such as "7d" or "2w".
+func sincePeriod(since string) (int, int, bool) {
+	unit := 1
+	switch {
+	case strings.HasSuffix(since, "d"):
+	case strings.HasSuffix(since, "w"):
+		unit = 7
+	default:
+		return 0, 0, false
+	}
+	n, err := strconv.Atoi(since[:len(since)-1])
+	return n, unit, err == nil
+}
+
+// collectDigest gathers the tasks completed from since to until in the journals of
+// store. Journals dated in the period are read; completed tasks without a date tag
+// count on the date of their journal. A task appearing in several journals is listed once.
+func collectDigest(store storage.Storage, since, until string, config *Config, logger *Logger) (digestData, error) {
+	data := digestData{Since: since, Until: until, Days: []digestDay{}, Custom: templateVars(config)}
+
+	parser := journalParser(config)
+	var completions []core.Completion
+	err := storage.Walk(store, "", func(info storage.FileInfo) error {
+		date, ok := journalDateFromPath(info.Name)
+		if !ok || date < since || date > until {
+			return nil
+		}
+		content, err := readJournalFile(store, info.Name, config)
+		if err != nil {
+			logger.Info("Skipping %s: %v", store.Location(info.Name), err)
+			return nil
+		}
+		journal, err := parser.ParseJournal(string(content))
+		if err != nil {
+			logger.Info("Skipping %s: %v", store.Location(info.Name), err)
+			return nil
+		}
+		completions = append(completions, core.CollectCompletions(journal, date)...)
+		return nil
+	})
+	if err != nil {
+		return data, fmt.Errorf("failed to scan journals in %s: %w", store.Location(""), err)
+	}
+
+	byDate := make(map[string][]string)
+	seen := make(map[core.Completion]bool)
+	for _, c := range completions {
+		if c.Date < since || c.Date > until || seen[c] {
+			continue
+		}
+		seen[c] = true
+		byDate[c.Date] = append(byDate[c.Date], core.StripDateTags(c.Text))
+		data.Total++
+	}
+
+	dates := make([]string, 0, len(byDate))
+	for date := range byDate {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+	for _, date := range dates {
+		t, _ := time.Parse(core.DateFormat, date)
+		data.Days = append(data.Days, digestDay{Date: date, Weekday: t.Weekday().String(), Tasks: byDate[date]})
+	}
+	return data, nil
+}
+
+// renderDigest renders the digest template content with data and the template
+// functions enabled by config.
+func renderDigest(content string, data digestData, config *Config) (string, error) {
+	if err := checkTemplateSecrets(content, config); err != nil {
+		return "", fmt.Errorf("invalid digest template: %w", err)
+	}
+	tmpl, err := template.New("digest").Funcs(core.TemplateFunctions(config.SprigFunctions)).Parse(content)
+	if err != nil {
+		return "", fmt.Errorf("invalid digest template: %w", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render digest template: %w", err)
+	}
+	return b.String(), nil
+}
+
+// cmdDigest renders the tasks completed in the journals below rootDir during the
+// period of opts and sends the digest to every notifier in opts. The digest is also
+// printed on w. Nothing is sent when no task was completed.
+func cmdDigest(w io.Writer, rootDir string, opts digestOptions, now time.Time, config *Config, logger *Logger) error {
+	// Build the notifiers first so configuration errors surface even without tasks
+	targets, err := newNotifiers(opts.Notifiers, config)
+	if err != nil {
+		return err
+	}
+
+	parser, err := dateParser(config)
+	if err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+	day := effectiveDay(now, config)
+	since, err := parseSince(opts.Since, day, parser)
+	if err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+	content := defaultDigestTemplate
+	if opts.TemplateFile != "" {
+		source := newTemplateStore().load(opts.TemplateFile)
+		if source.err != nil {
+			return withExitCode(ExitConfigError, fmt.Errorf("failed to read digest template: %w", source.err))
+		}
+		content = source.content
+	}
+
+	store, err := openJournalTree(rootDir, config)
+	if err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+	until := day.Format(core.DateFormat)
+	data, err := collectDigest(store, since, until, config, logger)
+	if err != nil {
+		return err
+	}
+	if data.Total == 0 {
+		fmt.Fprintf(w, "No tasks completed from %s to %s\n", since, until)
+		return nil
+	}
+
+	message, err := renderDigest(content, data, config)
+	if err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+	n := notification{
+		Title:   fmt.Sprintf("todoer: %d %s completed from %s to %s", data.Total, plural(data.Total, "task", "tasks"), since, until),
+		Message: strings.TrimSpace(message),
+	}
+	fmt.Fprintf(w, "%s\n\n%s\n", n.Title, n.Message)
+	if opts.DryRun {
+		return nil
+	}
+	return sendToNotifiers(targets, opts.Notifiers, n, logger)
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/inful/todoer/pkg/core"
+	"github.com/inful/todoer/pkg/storage"
+)
+
+// Kinds of problem doctor reports
+const (
+	DoctorMissingDays  = "missing-days"  // Days without a journal between the first and the last one
+	DoctorDateMismatch = "date-mismatch" // Frontmatter date differs from the date in the file name
+	DoctorMisplaced    = "misplaced"     // Journal outside the YYYY/MM folder of its date
+	DoctorDuplicate    = "duplicate"     // Several journals for the same date
+	DoctorMissingTodos = "missing-todos" // Journal without a TODOS section
+	DoctorUnparseable  = "unparseable"   // Journal that cannot be read or whose TODOS section cannot be parsed
+	DoctorOrphanBackup = "orphan-backup" // Backup whose journal no longer exists
+	DoctorTempFile     = "temp-file"     // Temporary file left by an interrupted write
+	DoctorOldFormat    = "old-format"    // Journal written by a release using an older journal format
+	DoctorNewerVersion = "newer-version" // Journal written by a newer release than the running one
+)
+
+// Severities of doctor issues. Only errors make doctor fail.
+const (
+	DoctorError   = "error"
+	DoctorWarning = "warning"
+)
+
+// tempFileRegex matches the temporary files storage.WriteFileAtomic renames into place
+var tempFileRegex = regexp.MustCompile(`\.tmp\.\d+$`)
+
+// doctorIssue is a problem doctor found in the journal tree.
+type doctorIssue struct {
+	Kind     string   `json:"kind"`
+	Severity string   `json:"severity"`
+	File     string   `json:"file,omitempty"`  // Name relative to the journal root
+	Files    []string `json:"files,omitempty"` // All files involved, for duplicates
+	Date     string   `json:"date,omitempty"`
+	Message  string   `json:"message"`
+	Fixable  bool     `json:"fixable"`
+	Fixed    bool     `json:"fixed"`
+
+	fix func() error // Repairs the problem; nil if it needs a human
+}
+
+// doctorReport is the result of a doctor run.
+type doctorReport struct {
+	Root     string        `json:"root"`
+	Journals int           `json:"journals"`
+	Errors   int           `json:"errors"`   // Errors left after fixing
+	Warnings int           `json:"warnings"` // Warnings left after fixing
+	Issues   []doctorIssue `json:"issues"`
+}
+
+// cmdDoctor checks every file below rootDir and reports the problems found on w
+// as text or JSON. With fix, problems that can be repaired safely are: frontmatter
+// dates are set to the date of the file name, misplaced journals moved into their
+// folder, journals in an older format upgraded and orphaned backups and temporary
+// files removed. Rewritten journals are backed up first. It fails with ExitFailure
+// when errors remain.
+func cmdDoctor(w io.Writer, rootDir, format string, fix bool, config *Config, logger *Logger) error {
+	store, err := openJournalTree(rootDir, config)
+	if err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+
+	report, err := diagnoseJournals(store, newProgress(logger), config)
+	if err != nil {
+		return err
+	}
+	report.Root = store.Location("")
+
+	for i := range report.Issues {
+		issue := &report.Issues[i]
+		if fix && issue.fix != nil {
+			if err := issue.fix(); err != nil {
+				logger.Info("Could not fix %s: %v", issue.File, err)
+			} else {
+				issue.Fixed = true
+				logger.Debug("Fixed %s: %s", issue.File, issue.Message)
+			}
+		}
+		if issue.Fixed {
+			continue
+		}
+		if issue.Severity == DoctorError {
+			report.Errors++
+		} else {
+			report.Warnings++
+		}
+	}
+
+	if format == OutputJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		writeDoctorReport(w, report)
+	}
+
+	if report.Errors > 0 {
+		return withExitCode(ExitFailure, fmt.Errorf("%d %s found in %s", report.Errors, plural(report.Errors, "problem", "problems"), report.Root))
+	}
+	return nil
+}
+
+// writeDoctorReport writes the issues of report one per line, followed by a count.
+func writeDoctorReport(w io.Writer, report *doctorReport) {
+	for _, issue := range report.Issues {
+		line := issue.Severity + ":"
+		if issue.Severity == DoctorError {
+			line = stdoutColors.red(line)
+		} else {
+			line = stdoutColors.yellow(line)
+		}
+		line += " "
+		if issue.File != "" {
+			line += issue.File + ": "
+		}
+		line += issue.Message
+		switch {
+		case issue.Fixed:
+			line += " (fixed)"
+		case issue.Fixable:
+			line += " (fixable with --fix)"
+		}
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintf(w, "%d %s checked: %d %s, %d %s\n", report.Journals, plural(report.Journals, "journal", "journals"),
+		report.Errors, plural(report.Errors, "error", "errors"), report.Warnings, plural(report.Warnings, "warning", "warnings"))
+}
+
+// diagnoseJournals walks store and returns the problems found, with the fixes
+// for the ones that can be repaired, reporting the journals checked to progress.
+func diagnoseJournals(store storage.Storage, progress core.Progress, config *Config) (*doctorReport, error) {
+	var names []string
+	exists := make(map[string]bool)
+	journals := 0
+	err := storage.Walk(store, "", func(info storage.FileInfo) error {
+		names = append(names, info.Name)
+		exists[info.Name] = true
+		if _, ok := journalDateFromPath(info.Name); ok {
+			journals++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan journals in %s: %w", store.Location(""), err)
+	}
+
+	report := &doctorReport{Issues: []doctorIssue{}}
+	arena := core.NewArena()
+	byDate := make(map[string][]string)
+	progress.Start(journals)
+	defer progress.Finish()
+	for _, name := range names {
+		switch {
+		case tempFileRegex.MatchString(name):
+			report.Issues = append(report.Issues, doctorIssue{
+				Kind: DoctorTempFile, Severity: DoctorError, File: name, Fixable: true,
+				Message: "temporary file left by an interrupted write",
+				fix:     removeFile(store, name),
+			})
+			continue
+		case strings.HasSuffix(name, ".bak") && !exists[strings.TrimSuffix(name, ".bak")]:
+			report.Issues = append(report.Issues, doctorIssue{
+				Kind: DoctorOrphanBackup, Severity: DoctorError, File: name, Fixable: true,
+				Message: fmt.Sprintf("backup of %s, which does not exist", path.Base(strings.TrimSuffix(name, ".bak"))),
+				fix:     removeFile(store, name),
+			})
+			continue
+		}
+
+		date, ok := journalDateFromPath(name)
+		if !ok {
+			continue
+		}
+		report.Journals++
+		byDate[date] = append(byDate[date], name)
+		progress.Stage(name, core.StageCheck)
+		report.Issues = append(report.Issues, diagnoseJournal(store, name, date, arena, config)...)
+		progress.Done(name)
+	}
+
+	dates := make([]string, 0, len(byDate))
+	for date := range byDate {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+
+	for _, date := range dates {
+		files := byDate[date]
+		if len(files) > 1 {
+			report.Issues = append(report.Issues, doctorIssue{
+				Kind: DoctorDuplicate, Severity: DoctorError, File: files[0], Files: files, Date: date,
+				Message: fmt.Sprintf("%d journals for %s: %s", len(files), date, strings.Join(files, ", ")),
+			})
+			continue
+		}
+		if expected := expectedJournalName(files[0], date, config); files[0] != expected {
+			issue := doctorIssue{
+				Kind: DoctorMisplaced, Severity: DoctorError, File: files[0], Date: date,
+				Message: fmt.Sprintf("journal belongs in %s", expected),
+			}
+			if !exists[expected] {
+				issue.Fixable = true
+				issue.fix = moveFile(store, files[0], expected)
+			}
+			report.Issues = append(report.Issues, issue)
+		}
+	}
+
+	report.Issues = append(report.Issues, missingDays(dates)...)
+	return report, nil
+}
+
+// diagnoseJournal checks the content of the journal name for date, parsing it
+// with the memory of arena.
+func diagnoseJournal(store storage.Storage, name, date string, arena *core.Arena, config *Config) []doctorIssue {
+	content, err := readJournalFile(store, name, config)
+	if err != nil {
+		return []doctorIssue{{
+			Kind: DoctorUnparseable, Severity: DoctorError, File: name, Date: date,
+			Message: fmt.Sprintf("cannot read journal: %v", err),
+		}}
+	}
+
+	var issues []doctorIssue
+	key := config.FrontmatterDateKey
+	if m := core.BuildFrontmatterDateRegex(key).FindSubmatchIndex(content); m != nil {
+		if found := string(content[m[2]:m[3]]); found != date {
+			fixed := append(append(append([]byte{}, content[:m[2]]...), date...), content[m[3]:]...)
+			issues = append(issues, doctorIssue{
+				Kind: DoctorDateMismatch, Severity: DoctorError, File: name, Date: date, Fixable: true,
+				Message: fmt.Sprintf("frontmatter %s is %s, file name says %s", key, found, date),
+				fix:     rewriteJournal(store, name, fixed, config),
+			})
+		}
+	}
+
+	issues = append(issues, diagnoseVersion(store, name, date, string(content), config)...)
+
+	header := todosHeader(config)
+	if !containsTodosHeader(string(content), header) {
+		issues = append(issues, doctorIssue{
+			Kind: DoctorMissingTodos, Severity: DoctorError, File: name, Date: date,
+			Message: fmt.Sprintf("no '%s' section", header),
+		})
+	} else if journal, err := journalParser(config, core.WithArena(arena)).ParseJournal(string(content)); err != nil {
+		issues = append(issues, doctorIssue{
+			Kind: DoctorUnparseable, Severity: DoctorError, File: name, Date: date,
+			Message: err.Error(),
+		})
+	} else {
+		arena.Release(journal)
+	}
+	return issues
+}
+
+// diagnoseVersion checks the version the journal name for date, with the decoded
+// content, is stamped with against the journal format and the running version.
+func diagnoseVersion(store storage.Storage, name, date, content string, config *Config) []doctorIssue {
+	stamped := journalVersion(content)
+	if stamped == "" {
+		return nil
+	}
+	if pending := pendingUpgrades(stamped); len(pending) > 0 {
+		return []doctorIssue{{
+			Kind: DoctorOldFormat, Severity: DoctorError, File: name, Date: date, Fixable: true,
+			Message: fmt.Sprintf("written by todoer %s in an older format: %s", stamped, pending[len(pending)-1].Change),
+			fix:     upgradeJournalFile(store, name, config),
+		}}
+	}
+	running := buildVersion().Version
+	if cmp, ok := compareVersions(stamped, running); ok && cmp > 0 {
+		return []doctorIssue{{
+			Kind: DoctorNewerVersion, Severity: DoctorWarning, File: name, Date: date,
+			Message: fmt.Sprintf("written by todoer %s, newer than this todoer %s, which may not process it correctly", stamped, running),
+		}}
+	}
+	return nil
+}
+
+// missingDays returns a warning for every gap between the sorted journal dates.
+func missingDays(dates []string) []doctorIssue {
+	var issues []doctorIssue
+	for i := 1; i < len(dates); i++ {
+		prev, err1 := time.Parse(core.DateFormat, dates[i-1])
+		next, err2 := time.Parse(core.DateFormat, dates[i])
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		first := prev.AddDate(0, 0, 1)
+		if !first.Before(next) {
+			continue
+		}
+		last := next.AddDate(0, 0, -1)
+		message := "no journal for " + first.Format(core.DateFormat)
+		if n := int(last.Sub(first).Hours()/24) + 1; n > 1 {
+			message = fmt.Sprintf("no journals for the %d days from %s to %s", n, first.Format(core.DateFormat), last.Format(core.DateFormat))
+		}
+		issues = append(issues, doctorIssue{
+			Kind: DoctorMissingDays, Severity: DoctorWarning, Date: first.Format(core.DateFormat),
+			Message: message,
+		})
+	}
+	return issues
+}
+
+// expectedJournalName returns the YYYY/MM name the journal name for date should
+// have, keeping the extension of its codec.
+func expectedJournalName(name, date string, config *Config) string {
+	expected := journalName(date, config)
+	if ext := path.Ext(name); journalCodecs[ext] != nil {
+		expected += ext
+	}
+	return expected
+}
+
+// removeFile returns a fix that removes name from store.
+func removeFile(store storage.Storage, name string) func() error {
+	return func() error {
+		return store.Remove(name)
+	}
+}
+
+// moveFile returns a fix that moves the journal from to the unused name to,
+// together with its backup if it has one.
+func moveFile(store storage.Storage, from, to string) func() error {
+	return func() error {
+		if err := store.MkdirAll(path.Dir(to)); err != nil {
+			return err
+		}
+		if data, err := store.Read(from + ".bak"); err == nil {
+			if err := store.Write(to+".bak", bytes.NewReader(data)); err != nil {
+				return err
+			}
+			_ = store.Remove(from + ".bak")
+		}
+		data, err := store.Read(from)
+		if err != nil {
+			return err
+		}
+		if err := store.Write(to, bytes.NewReader(data)); err != nil {
+			return err
+		}
+		return store.Remove(from)
+	}
+}
+
+// upgradeJournalFile returns a fix that brings the journal name up to the current
+// journal format, reading it when the fix runs so earlier fixes are kept.
+func upgradeJournalFile(store storage.Storage, name string, config *Config) func() error {
+	return func() error {
+		content, err := readJournalFile(store, name, config)
+		if err != nil {
+			return err
+		}
+		upgraded, _, err := upgradeJournal(string(content))
+		if err != nil {
+			return err
+		}
+		return rewriteJournal(store, name, []byte(upgraded), config)()
+	}
+}
+
+// rewriteJournal returns a fix that backs up the journal name and writes content,
+// its new decoded content, in its place.
+func rewriteJournal(store storage.Storage, name string, content []byte, config *Config) func() error {
+	return func() error {
+		original, err := store.Read(name)
+		if err != nil {
+			return err
+		}
+		return writeJournalFiles(store, []pendingWrite{
+			{name: name + ".bak", data: original},
+			{name: name, data: content},
+		}, config)
+	}
+}
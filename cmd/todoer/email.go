@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// DefaultSendmail is the sendmail program used when email.sendmail is not set
+const DefaultSendmail = "/usr/sbin/sendmail"
+
+// emailMessage returns n as a plain text email from from to to, sent at now.
+func emailMessage(from string, to []string, n notification, now time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", n.Title))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(n.Message, "\r\n", "\n"), "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+// emailAddresses checks the sender and recipients of the email notifiers.
+func emailAddresses(config *Config) (string, []string, error) {
+	cfg := config.Email
+	if cfg.From == "" {
+		return "", nil, errors.New("email.from is not configured")
+	}
+	if len(cfg.To) == 0 {
+		return "", nil, errors.New("email.to is not configured")
+	}
+	return cfg.From, cfg.To, nil
+}
+
+// smtpNotifier sends the notification as an email through an SMTP server.
+type smtpNotifier struct {
+	server string
+	auth   smtp.Auth
+	from   string
+	to     []string
+	send   func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func newSMTPNotifier(config *Config) (notifier, error) {
+	from, to, err := emailAddresses(config)
+	if err != nil {
+		return nil, err
+	}
+	cfg := config.Email
+	if cfg.SMTPServer == "" {
+		return nil, errors.New("email.smtp_server is not configured")
+	}
+	host, _, err := net.SplitHostPort(cfg.SMTPServer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid email.smtp_server %q, want host:port: %w", cfg.SMTPServer, err)
+	}
+
+	var auth smtp.Auth
+	if cfg.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, host)
+	}
+	return &smtpNotifier{server: cfg.SMTPServer, auth: auth, from: from, to: to, send: smtp.SendMail}, nil
+}
+
+// Notify sends the email, using STARTTLS when the server offers it.
+func (s *smtpNotifier) Notify(n notification) error {
+	if err := s.send(s.server, s.auth, s.from, s.to, emailMessage(s.from, s.to, n, time.Now())); err != nil {
+		return fmt.Errorf("smtp delivery failed: %w", err)
+	}
+	return nil
+}
+
+// sendmailNotifier sends the notification as an email with a local sendmail program.
+type sendmailNotifier struct {
+	program string
+	from    string
+	to      []string
+}
+
+func newSendmailNotifier(config *Config) (notifier, error) {
+	from, to, err := emailAddresses(config)
+	if err != nil {
+		return nil, err
+	}
+	program := config.Email.Sendmail
+	if program == "" {
+		program = DefaultSendmail
+	}
+	return &sendmailNotifier{program: program, from: from, to: to}, nil
+}
+
+// Notify pipes the email to sendmail -t, which reads the recipients from its headers.
+func (s *sendmailNotifier) Notify(n notification) error {
+	cmd := exec.Command(s.program, "-t", "-i", "-f", s.from)
+	cmd.Stdin = bytes.NewReader(emailMessage(s.from, s.to, n, time.Now()))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", s.program, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+)
+
+// Exit codes returned by the todoer binary. They allow scripts to react to
+// specific failure classes without parsing error messages.
+const (
+	ExitOK             = 0
+	ExitFailure        = 1 // Unclassified failure (invalid arguments, unreadable source, ...)
+	ExitConfigError    = 2 // Configuration or template could not be loaded
+	ExitParseError     = 3 // Source journal could not be parsed or processed
+	ExitNothingToCarry = 4 // Run succeeded but no open todos were carried over (--strict-exit only)
+	ExitWriteError     = 5 // Target, backup, or source file could not be written
+)
+
+// exitError attaches an exit code to an error.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// withExitCode wraps err so that exitCodeFor reports the given code.
+// Returns nil if err is nil.
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code: code, err: err}
+}
+
+// exitCodeFor returns the exit code attached to err, ExitFailure for
+// unclassified errors, and ExitOK for nil.
+func exitCodeFor(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	return ExitFailure
+}
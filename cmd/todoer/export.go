@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// Export and import formats
+const (
+	ExportKanban = "kanban"
+	ExportSQLite = "sqlite"
+	ExportBear   = "bear"
+)
+
+// exportOptions holds the settings of an export run.
+type exportOptions struct {
+	Format  string // ExportKanban or ExportSQLite
+	Columns string // Comma-separated column names for open, blocked and completed tasks (kanban)
+	File    string // File to write the export to instead of w, a directory for bear (optional)
+	URL     bool   // Write the x-callback-url creating the note instead of the note (bear)
+	Push    bool   // Open the x-callback-url, creating the note in Bear (bear)
+}
+
+// cmdExport exports the journals below rootDir in opts.Format, on w or into
+// opts.File: the current journal as a Kanban board, all journals as a SQLite
+// database, or journals as Bear notes.
+func cmdExport(w io.Writer, rootDir string, opts exportOptions, now time.Time, config *Config, logger *Logger) error {
+	switch opts.Format {
+	case ExportKanban:
+		return exportKanban(w, rootDir, opts.Columns, opts.File, now, config, logger)
+	case ExportSQLite:
+		return exportSQLite(w, rootDir, opts.File, config, logger)
+	case ExportBear:
+		return exportBear(w, rootDir, opts.File, opts.URL, opts.Push, now, config, logger)
+	default:
+		return errors.New("--format must be kanban, sqlite or bear")
+	}
+}
@@ -1,45 +1,55 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
+	"time"
+
+	"github.com/inful/todoer/pkg/core"
+	"github.com/inful/todoer/pkg/storage"
 )
 
-// safeWriteFile atomically writes data to filename using a temp file and rename.
+// safeWriteFile atomically writes data to filename using a temp file and rename,
+// trying again on transient errors like fileRetry.
 func safeWriteFile(filename string, data []byte, perm os.FileMode) error {
-	dir := filepath.Dir(filename)
-	tmpFile, err := os.CreateTemp(dir, filepath.Base(filename)+".tmp.*")
-	if err != nil {
-		return fmt.Errorf("failed to create temporary file: %w", err)
-	}
-
-	defer func() {
-		_ = tmpFile.Close()
-		_ = os.Remove(tmpFile.Name())
-	}()
+	return fileRetry.Do(context.Background(), func() error {
+		return safeWriteReader(filename, bytes.NewReader(data), perm)
+	})
+}
 
-	if _, err := tmpFile.Write(data); err != nil {
-		return fmt.Errorf("failed to write to temporary file: %w", err)
-	}
+// safeWriteReader atomically streams the content of r to filename using a temp file and rename.
+func safeWriteReader(filename string, r io.Reader, perm os.FileMode) error {
+	return storage.WriteFileAtomic(filename, r, perm)
+}
 
-	if err := tmpFile.Sync(); err != nil {
-		return fmt.Errorf("failed to sync temporary file: %w", err)
-	}
+// fileRetry retries the file operations of the command that fail with transient
+// errors; main sets it from the configuration
+var fileRetry storage.RetryPolicy
 
-	if err := tmpFile.Close(); err != nil {
-		return fmt.Errorf("failed to close temporary file: %w", err)
+// retryPolicy returns the configured retries of file operations.
+func retryPolicy(config *Config) (storage.RetryPolicy, error) {
+	if config.Retry.Attempts < 0 {
+		return storage.RetryPolicy{}, fmt.Errorf("%w: retry attempts must not be negative, got %d", ErrInvalidConfig, config.Retry.Attempts)
 	}
-
-	if err := os.Rename(tmpFile.Name(), filename); err != nil {
-		return fmt.Errorf("failed to move temporary file to target: %w", err)
-	}
-
-	if err := os.Chmod(filename, perm); err != nil {
-		return fmt.Errorf("failed to set file permissions: %w", err)
+	policy := storage.RetryPolicy{Attempts: config.Retry.Attempts}
+	if config.Retry.Backoff != "" {
+		backoff, err := time.ParseDuration(config.Retry.Backoff)
+		if err != nil || backoff < 0 {
+			return storage.RetryPolicy{}, fmt.Errorf("%w: retry backoff must be a duration such as 200ms, got %q", ErrInvalidConfig, config.Retry.Backoff)
+		}
+		policy.Backoff = backoff
 	}
+	return policy, nil
+}
 
-	return nil
+// localStore returns the local storage below root, retrying like fileRetry and
+// writing files with fileMode.
+func localStore(root string) *storage.Local {
+	return &storage.Local{Root: root, Retry: fileRetry, Mode: fileMode}
 }
 
 // getConfigValue prefers the CLI value over the config value.
@@ -50,8 +60,28 @@ func getConfigValue(cliValue, configValue string) string {
 	return configValue
 }
 
-// fatalError logs an error and exits with code 1.
-func fatalError(format string, args ...interface{}) {
-	fmt.Fprintf(os.Stderr, "ERROR: "+format+"\n", args...)
-	os.Exit(1)
+// fatalError logs an error and exits with the given exit code.
+func fatalError(code int, format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, stderrColors.red("ERROR:")+" "+format+"\n", args...)
+	for _, arg := range args {
+		if err, ok := arg.(error); ok {
+			if context := journalErrorContext(err); context != "" {
+				fmt.Fprintln(os.Stderr, context)
+			}
+			if errors.Is(err, context.DeadlineExceeded) {
+				fmt.Fprintln(os.Stderr, "The command timed out; allow it more time with --timeout or timeout in config.toml")
+			}
+		}
+	}
+	os.Exit(code)
+}
+
+// journalErrorContext returns the offending line of a journal error in err with a
+// caret under the error, or an empty string if err holds none.
+func journalErrorContext(err error) string {
+	var jerr *core.JournalError
+	if !errors.As(err, &jerr) {
+		return ""
+	}
+	return jerr.Context()
 }
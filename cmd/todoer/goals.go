@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/inful/todoer/pkg/core"
+	"github.com/inful/todoer/pkg/storage"
+)
+
+// goalEntry is the JSON form of a goal's progress.
+type goalEntry struct {
+	Goal      string `json:"goal"`
+	Completed int    `json:"completed"`
+	Open      int    `json:"open"`
+	Total     int    `json:"total"`
+	Percent   int    `json:"percent"`
+}
+
+// collectJournals parses the TODOS section of every journal file in store,
+// reporting its progress on standard error. Files that cannot be read or have no
+// TODOS section are skipped.
+func collectJournals(store storage.Storage, config *Config, logger *Logger) ([]*core.TodoJournal, error) {
+	// Carried todos repeat in many journals, which keep their text once
+	parser := journalParser(config, core.WithArena(core.NewArena()))
+	progress := newProgress(logger)
+
+	var journals []*core.TodoJournal
+	err := walkJournals(store, progress, func(info storage.FileInfo, _ string) error {
+		progress.Stage(info.Name, core.StageRead)
+		content, err := readJournalFile(store, info.Name, config)
+		if err != nil {
+			logger.Info("Skipping %s: %v", store.Location(info.Name), err)
+			return nil
+		}
+		progress.Stage(info.Name, core.StageParse)
+		journal, err := parser.ParseJournal(string(content))
+		if err != nil {
+			logger.Debug("Skipping %s: %v", store.Location(info.Name), err)
+			return nil
+		}
+		journals = append(journals, journal)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan journals in %s: %w", store.Location(""), err)
+	}
+
+	return journals, nil
+}
+
+// cmdGoals prints the progress of every goal linked from tasks in the journal tree.
+func cmdGoals(w io.Writer, rootDir, format string, config *Config, logger *Logger) error {
+	store, err := openJournalTree(rootDir, config)
+	if err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+
+	journals, err := collectJournals(store, config, logger)
+	if err != nil {
+		return err
+	}
+	goals := core.CalculateGoalProgress(journals...)
+
+	if format == OutputJSON {
+		entries := make([]goalEntry, len(goals))
+		for i, goal := range goals {
+			entries[i] = goalEntry{
+				Goal:      goal.Goal,
+				Completed: goal.Completed,
+				Open:      goal.Open,
+				Total:     goal.Total(),
+				Percent:   goal.Percent(),
+			}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	}
+
+	if len(goals) == 0 {
+		logger.Info("No goal annotations found in %s", rootDir)
+		return nil
+	}
+	for _, goal := range goals {
+		fmt.Fprintf(w, "%s: %d/%d done (%d%%), %d open\n", goal.Goal, goal.Completed, goal.Total(), goal.Percent(), goal.Open)
+	}
+	return nil
+}
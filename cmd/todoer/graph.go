@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/inful/todoer/pkg/core"
+)
+
+// Dependency graph output formats
+const (
+	GraphMermaid = "mermaid"
+	GraphDOT     = "dot"
+)
+
+// Values of dependency_policy, deciding what process does with a completed task
+// that depends on an open one
+const (
+	DependencyIgnore = "ignore"
+	DependencyWarn   = "warn"
+	DependencyRefuse = "refuse"
+)
+
+// dependencyPolicy returns the configured dependency policy, warning by default.
+func dependencyPolicy(config *Config) string {
+	if config.DependencyPolicy == "" {
+		return DependencyWarn
+	}
+	return config.DependencyPolicy
+}
+
+// checkDependencies applies the dependency policy to the todos section of a journal
+// about to be processed: completed tasks depending on a task that is still open are
+// reported as warnings, or refused. A section that does not parse is left to the
+// processing itself to report.
+func checkDependencies(content string, summary *resultSummary, config *Config, logger *Logger) error {
+	policy := dependencyPolicy(config)
+	if policy == DependencyIgnore {
+		return nil
+	}
+	journal, err := journalParser(config).ParseJournal(content)
+	if err != nil {
+		return nil
+	}
+
+	for _, v := range core.FindDependencyViolations(journal) {
+		msg := fmt.Sprintf("'%s' is completed but depends on open task %s", v.Task, v.Blocker)
+		if policy == DependencyRefuse {
+			return withExitCode(ExitParseError, fmt.Errorf("%s (dependency_policy = %s)", msg, DependencyRefuse))
+		}
+		summary.addWarning("%s", msg)
+		logger.Warn("%s", msg)
+	}
+	return nil
+}
+
+// writeMermaidGraph writes graph as a Mermaid flowchart, blockers pointing at their dependents.
+func writeMermaidGraph(w io.Writer, graph core.DependencyGraph) error {
+	var buf bytes.Buffer
+	buf.WriteString("graph TD\n")
+	for i, task := range graph.Tasks {
+		label := strings.ReplaceAll(graphLabel(task), `"`, "#quot;")
+		if task.Missing {
+			fmt.Fprintf(&buf, "  t%d([\"%s\"])\n", i, label)
+		} else {
+			fmt.Fprintf(&buf, "  t%d[\"%s\"]\n", i, label)
+		}
+	}
+	for _, edge := range graph.Edges {
+		fmt.Fprintf(&buf, "  t%d --> t%d\n", edge.From, edge.To)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeDOTGraph writes graph in the Graphviz DOT language, blockers pointing at their dependents.
+func writeDOTGraph(w io.Writer, graph core.DependencyGraph) error {
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	var buf bytes.Buffer
+	buf.WriteString("digraph todos {\n  rankdir=LR;\n  node [shape=box];\n")
+	for i, task := range graph.Tasks {
+		style := ""
+		if task.Missing {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(&buf, "  t%d [label=\"%s\"%s];\n", i, escape.Replace(graphLabel(task)), style)
+	}
+	for _, edge := range graph.Edges {
+		fmt.Fprintf(&buf, "  t%d -> t%d;\n", edge.From, edge.To)
+	}
+	buf.WriteString("}\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// graphLabel returns the text shown for a task in a dependency graph.
+func graphLabel(task core.DependencyTask) string {
+	if task.Missing {
+		return task.ID + " (missing)"
+	}
+	return core.StripDependencyAnnotations(task.Text)
+}
+
+// cmdGraph writes the dependencies between the open tasks of the current journal
+// below rootDir as a diagram in format.
+func cmdGraph(w io.Writer, rootDir, format string, now time.Time, config *Config, logger *Logger) error {
+	write := writeMermaidGraph
+	switch format {
+	case GraphMermaid:
+	case GraphDOT:
+		write = writeDOTGraph
+	default:
+		return errors.New("--format must be mermaid or dot")
+	}
+
+	store, err := openJournalTree(rootDir, config)
+	if err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+
+	name, err := currentJournalName(store, effectiveDay(now, config).Format(core.DateFormat), config)
+	if err != nil {
+		return err
+	}
+	logger.Debug("Graphing %s", store.Location(name))
+
+	_, journal, err := readJournalTodos(store, name, config)
+	if err != nil {
+		return fmt.Errorf("%s: %w", store.Location(name), err)
+	}
+
+	graph := core.BuildDependencyGraph(journal)
+	if len(graph.Edges) == 0 {
+		logger.Info("No dependencies between open tasks in %s", store.Location(name))
+	}
+	return write(w, graph)
+}
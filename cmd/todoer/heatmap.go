@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/inful/todoer/pkg/core"
+)
+
+// Heatmap output formats
+const (
+	HeatmapASCII = "ascii"
+	HeatmapSVG   = "svg"
+)
+
+// heatmapOptions holds the arguments of the heatmap command.
+type heatmapOptions struct {
+	Year       int    // Year to draw
+	Format     string // HeatmapASCII or HeatmapSVG
+	OutputFile string // File to write the heatmap to instead of w (optional)
+}
+
+// heatmapASCIILevels are the characters for days without completions and the four activity levels
+var heatmapASCIILevels = []string{"·", "░", "▒", "▓", "█"}
+
+// heatmapSVGColors are the fill colors for days without completions and the four activity levels
+var heatmapSVGColors = []string{"#ebedf0", "#9be9a8", "#40c463", "#30a14e", "#216e39"}
+
+// Layout of the SVG heatmap in pixels
+const (
+	heatmapCell   = 10
+	heatmapPitch  = 12
+	heatmapLeft   = 30
+	heatmapTop    = 20
+	heatmapBottom = 10
+)
+
+// heatmapDay is one day of a heatmap grid.
+type heatmapDay struct {
+	Date  string
+	Count int // Completed todos on the day
+	Week  int // Column of the day, counting weeks from the one containing January 1st
+	Row   int // Day of the week, Monday being 0
+}
+
+// heatmapGrid lays out the days of year in weeks starting on Monday, with the number
+// of todos completed on each day taken from counts. It also returns the highest count.
+func heatmapGrid(year int, counts map[string]int) ([]heatmapDay, int) {
+	first := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	offset := (int(first.Weekday()) + 6) % 7
+
+	var days []heatmapDay
+	maxCount := 0
+	for d := first; d.Year() == year; d = d.AddDate(0, 0, 1) {
+		date := d.Format(core.DateFormat)
+		index := d.YearDay() - 1 + offset
+		day := heatmapDay{Date: date, Count: counts[date], Week: index / 7, Row: index % 7}
+		if day.Count > maxCount {
+			maxCount = day.Count
+		}
+		days = append(days, day)
+	}
+	return days, maxCount
+}
+
+// heatmapLevel maps a day's count to one of four activity levels relative to the
+// busiest day, or 0 for a day without completions.
+func heatmapLevel(count, maxCount int) int {
+	if count <= 0 || maxCount <= 0 {
+		return 0
+	}
+	return (count*4 + maxCount - 1) / maxCount
+}
+
+// renderHeatmapASCII draws the heatmap of year as text, one row per day of the week.
+func renderHeatmapASCII(w io.Writer, year int, counts map[string]int) error {
+	days, maxCount := heatmapGrid(year, counts)
+	weeks := days[len(days)-1].Week + 1
+
+	months := []rune(strings.Repeat(" ", weeks+3))
+	rows := make([][]string, 7)
+	for i := range rows {
+		rows[i] = make([]string, weeks)
+		for j := range rows[i] {
+			rows[i][j] = " "
+		}
+	}
+	total := 0
+	for _, day := range days {
+		rows[day.Row][day.Week] = heatmapASCIILevels[heatmapLevel(day.Count, maxCount)]
+		total += day.Count
+		if strings.HasSuffix(day.Date, "-01") {
+			t, _ := time.Parse(core.DateFormat, day.Date)
+			copy(months[day.Week:], []rune(t.Format("Jan")))
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%d: %d %s completed\n\n", year, total, plural(total, "todo", "todos"))
+	fmt.Fprintf(&buf, "    %s\n", strings.TrimRight(string(months), " "))
+	for row, cells := range rows {
+		label := ""
+		if row%2 == 0 {
+			label = time.Weekday((row + 1) % 7).String()[:3]
+		}
+		fmt.Fprintf(&buf, "%-4s%s\n", label, strings.TrimRight(strings.Join(cells, ""), " "))
+	}
+	fmt.Fprintf(&buf, "\nLess %s More\n", strings.Join(heatmapASCIILevels, ""))
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// renderHeatmapSVG draws the heatmap of year as a standalone SVG image with a tooltip per day.
+func renderHeatmapSVG(w io.Writer, year int, counts map[string]int) error {
+	days, maxCount := heatmapGrid(year, counts)
+	weeks := days[len(days)-1].Week + 1
+	width := heatmapLeft + weeks*heatmapPitch
+	height := heatmapTop + 7*heatmapPitch + heatmapBottom
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="9">`+"\n",
+		width, height, width, height)
+	fmt.Fprintf(&buf, "<title>Completed todos in %d</title>\n", year)
+	for row := 0; row < 7; row += 2 {
+		fmt.Fprintf(&buf, `<text x="0" y="%d" fill="#767676">%s</text>`+"\n",
+			heatmapTop+row*heatmapPitch+heatmapCell-1, time.Weekday((row + 1) % 7).String()[:3])
+	}
+	for _, day := range days {
+		x := heatmapLeft + day.Week*heatmapPitch
+		y := heatmapTop + day.Row*heatmapPitch
+		if strings.HasSuffix(day.Date, "-01") {
+			t, _ := time.Parse(core.DateFormat, day.Date)
+			fmt.Fprintf(&buf, `<text x="%d" y="%d" fill="#767676">%s</text>`+"\n", x, heatmapTop-6, t.Format("Jan"))
+		}
+		fmt.Fprintf(&buf, `<rect x="%d" y="%d" width="%d" height="%d" rx="2" fill="%s"><title>%s: %d completed</title></rect>`+"\n",
+			x, y, heatmapCell, heatmapCell, heatmapSVGColors[heatmapLevel(day.Count, maxCount)], html.EscapeString(day.Date), day.Count)
+	}
+	buf.WriteString("</svg>\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// cmdHeatmap draws a heatmap of the todos completed per day of opts.Year across the
+// journal tree, on w or into opts.OutputFile.
+func cmdHeatmap(w io.Writer, rootDir, indexPath string, opts heatmapOptions, config *Config, logger *Logger) error {
+	if opts.Year < 1 || opts.Year > 9999 {
+		return fmt.Errorf("invalid year %d", opts.Year)
+	}
+	render := renderHeatmapASCII
+	switch opts.Format {
+	case HeatmapASCII:
+	case HeatmapSVG:
+		render = renderHeatmapSVG
+	default:
+		return errors.New("--format must be ascii or svg")
+	}
+
+	store, err := openJournalTree(rootDir, config)
+	if err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+
+	counts, err := completionCounts(store, indexPath, config, logger)
+	if err != nil {
+		return err
+	}
+
+	if opts.OutputFile == "" {
+		return render(w, opts.Year, counts)
+	}
+
+	var buf bytes.Buffer
+	if err := render(&buf, opts.Year, counts); err != nil {
+		return err
+	}
+	if err := safeWriteFile(opts.OutputFile, buf.Bytes(), fileMode); err != nil {
+		return withExitCode(ExitWriteError, fmt.Errorf("failed to write heatmap: %w", err))
+	}
+	logger.Info("Heatmap for %d written to %s", opts.Year, opts.OutputFile)
+	return nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/inful/todoer/pkg/core"
+	"github.com/inful/todoer/pkg/storage"
+)
+
+// indexVersion is bumped whenever the cached data changes meaning, discarding older caches
+const indexVersion = 3
+
+// journalIndex caches what commands scanning the whole journal tree derive from
+// each journal, so that only journals changed since the last scan are read again.
+type journalIndex struct {
+	Version int                   `json:"version"`
+	Files   map[string]indexEntry `json:"files"` // Keyed by the journal's storage location
+}
+
+// indexEntry is the cached data of one journal file.
+type indexEntry struct {
+	Size        int64             `json:"size"`
+	ModTime     time.Time         `json:"mod_time"`
+	Completions []core.Completion `json:"completions"` // Texts are hashed so the cache holds no journal content
+}
+
+// indexCachePath returns the location of the journal index cache.
+func indexCachePath() (string, error) {
+	cacheHome, err := getCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("could not determine cache directory: %w", err)
+	}
+	return filepath.Join(cacheHome, ConfigDirName, IndexCacheFile), nil
+}
+
+// loadJournalIndex reads the index cache at indexPath. A missing, unreadable or
+// outdated cache yields an empty index, since it can always be rebuilt.
+func loadJournalIndex(indexPath string) *journalIndex {
+	index := &journalIndex{Version: indexVersion, Files: map[string]indexEntry{}}
+	content, err := os.ReadFile(indexPath)
+	if err != nil {
+		return index
+	}
+
+	var cached journalIndex
+	if err := json.Unmarshal(content, &cached); err != nil || cached.Version != indexVersion || cached.Files == nil {
+		return index
+	}
+	return &cached
+}
+
+// save writes the index cache to indexPath.
+func (idx *journalIndex) save(indexPath string) error {
+	content, err := json.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("failed to encode journal index: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(indexPath), dirMode()); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return safeWriteFile(indexPath, content, fileMode)
+}
+
+// scanCompletions returns the completed todos of every journal in store. Journals
+// whose size and modification time match the index are not read; the index is
+// updated with the journals that were, and forgets journals that no longer exist.
+// Completed todos without a date tag count on the date of their journal. The
+// progress is reported on standard error.
+func scanCompletions(store storage.Storage, index *journalIndex, config *Config, logger *Logger) ([]core.Completion, error) {
+	arena := core.NewArena()
+	parser := journalParser(config, core.WithArena(arena))
+	root := indexKey(store, "")
+	progress := newProgress(logger)
+
+	seen := make(map[string]bool)
+	var completions []core.Completion
+	err := walkJournals(store, progress, func(info storage.FileInfo, date string) error {
+		key := indexKey(store, info.Name)
+		seen[key] = true
+		if entry, ok := index.Files[key]; ok && !info.ModTime.IsZero() &&
+			entry.Size == info.Size && entry.ModTime.Equal(info.ModTime) {
+			completions = append(completions, entry.Completions...)
+			return nil
+		}
+
+		progress.Stage(info.Name, core.StageRead)
+		content, err := readJournalFile(store, info.Name, config)
+		if err != nil {
+			logger.Info("Skipping %s: %v", key, err)
+			return nil
+		}
+		progress.Stage(info.Name, core.StageParse)
+		journal, err := parser.ParseJournal(string(content))
+		if err != nil {
+			logger.Debug("Skipping %s: %v", key, err)
+			return nil
+		}
+
+		found := core.CollectCompletions(journal, date)
+		arena.Release(journal)
+		for i := range found {
+			found[i].Text = hashCompletionText(found[i].Text)
+		}
+		if !info.ModTime.IsZero() {
+			index.Files[key] = indexEntry{Size: info.Size, ModTime: info.ModTime, Completions: found}
+		}
+		completions = append(completions, found...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan journals in %s: %w", root, err)
+	}
+
+	for key := range index.Files {
+		if !seen[key] && strings.HasPrefix(key, root) {
+			delete(index.Files, key)
+		}
+	}
+
+	return completions, nil
+}
+
+// indexKey returns the key of the named file in the index: its location, made
+// absolute for local storage so that runs from other directories share the entry.
+func indexKey(store storage.Storage, name string) string {
+	location := store.Location(name)
+	if storage.IsLocal(store) {
+		if abs, err := filepath.Abs(location); err == nil {
+			return abs
+		}
+	}
+	return location
+}
+
+// hashCompletionText shortens a todo text to a digest that still tells completions apart.
+func hashCompletionText(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:8])
+}
+
+// indexedCompletions returns the completed todos of the journals in store, using
+// and refreshing the index cache at indexPath. Failing to save the cache only
+// costs speed on the next run and is not reported as an error; read-only runs
+// do not save it.
+func indexedCompletions(store storage.Storage, indexPath string, config *Config, logger *Logger) ([]core.Completion, error) {
+	index := loadJournalIndex(indexPath)
+	completions, err := scanCompletions(store, index, config, logger)
+	if err != nil {
+		return nil, err
+	}
+	if readOnly {
+		return completions, nil
+	}
+	if err := index.save(indexPath); err != nil {
+		logger.Debug("Could not save journal index %s: %v", indexPath, err)
+	}
+	return completions, nil
+}
+
+// completionCounts returns the number of todos completed per date in the journals of
+// store, see indexedCompletions.
+func completionCounts(store storage.Storage, indexPath string, config *Config, logger *Logger) (map[string]int, error) {
+	completions, err := indexedCompletions(store, indexPath, config, logger)
+	if err != nil {
+		return nil, err
+	}
+	return core.CountCompletions(completions), nil
+}
+
+// completionStreaks computes the completion streaks of the journals in store as of
+// today, and the completions per hour of the day, see indexedCompletions.
+func completionStreaks(store storage.Storage, indexPath, today string, config *Config, logger *Logger) (core.StreakStats, error) {
+	completions, err := indexedCompletions(store, indexPath, config, logger)
+	if err != nil {
+		return core.StreakStats{}, err
+	}
+	stats := core.CalculateStreaks(core.CountCompletions(completions), today)
+	stats.CompletionHours = core.CompletionHours(completions)
+	stats.MedianDaysToComplete, stats.TrackedTodos = core.DaysToComplete(completions)
+	return stats, nil
+}
@@ -1,30 +1,36 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"os"
+	"io/fs"
+	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/inful/todoer/pkg/core"
 	"github.com/inful/todoer/pkg/generator"
+	"github.com/inful/todoer/pkg/storage"
 )
 
-// getGenerator builds a Generator from CLI/config, resolving template and previous date.
-func getGenerator(templateFile, templateDate, sourceFile string, config *Config) (*generator.Generator, string, error) {
+// getGenerator builds a Generator from CLI/config, resolving template and previous date
+// from the content of the source journal. Templates using streak variables get the
+// streaks of the journals in root, or in the configured journal root if root is nil,
+// and templates using noteField read the notes of that tree.
+func getGenerator(root storage.Storage, templateFile, templateDate, sourceContent string, config *Config, logger *Logger) (*generator.Generator, string, error) {
 	if templateDate == "" {
-		templateDate = time.Now().Format(core.DateFormat)
+		templateDate = effectiveToday(config)
 	}
 
 	previousDate := ""
-	if sourceFile != "" {
-		if content, readErr := os.ReadFile(sourceFile); readErr == nil {
-			if extractedDate, extractErr := generator.ExtractDateFromFrontmatter(string(content), config.FrontmatterDateKey); extractErr == nil {
-				previousDate = extractedDate
-			}
-		}
+	if extractedDate, err := generator.ExtractDateFromFrontmatter(sourceContent, config.FrontmatterDateKey); err == nil {
+		previousDate = extractedDate
 	}
 
 	tmplSource := resolveTemplate(templateFile)
@@ -32,43 +38,267 @@ func getGenerator(templateFile, templateDate, sourceFile string, config *Config)
 		return nil, "", fmt.Errorf("error resolving template: %w", tmplSource.err)
 	}
 
-	gen, err := generator.NewGeneratorWithOptions(tmplSource.content, templateDate,
+	opts := []generator.Option{
 		generator.WithPreviousDate(previousDate),
 		generator.WithCustomVariables(config.Custom),
 		generator.WithFrontmatterDateKey(config.FrontmatterDateKey),
 		generator.WithTodosHeader(config.TodosHeader),
-	)
+		generator.WithFormat(journalFormat(config)),
+	}
+	if config.CollapseCarried {
+		opts = append(opts, generator.WithCollapseCarried(config.AnnotateCarried))
+	}
+	if config.MaxCarry > 0 {
+		opts = append(opts, generator.WithMaxCarry(config.MaxCarry))
+	}
+	if config.Assignee != "" {
+		opts = append(opts, generator.WithAssignee(config.Assignee))
+	}
+	if config.CascadeComplete {
+		opts = append(opts, generator.WithCascadeComplete())
+	}
+	if config.AutoCompleteParent {
+		opts = append(opts, generator.WithAutoCompleteParent())
+	}
+	if config.CompletionTime {
+		opts = append(opts, generator.WithCompletionTime(configNow(config)))
+	}
+	if config.StrictTemplates {
+		opts = append(opts, generator.WithStrictTemplates())
+	}
+	if secrets := secretVars(config); len(secrets) > 0 {
+		opts = append(opts, generator.WithSecretVariables(secrets...))
+	}
+	if config.SprigFunctions {
+		opts = append(opts, generator.WithSprigFunctions())
+	}
+	if config.Deterministic {
+		opts = append(opts, generator.WithDeterministicTemplates())
+	}
+	if config.StampVersion {
+		opts = append(opts, generator.WithVersionStamp(buildVersion().Version))
+	}
+	calendar, err := configCalendar(config)
+	if err != nil {
+		return nil, "", err
+	}
+	opts = append(opts, generator.WithCalendar(calendar))
+	readsNotes := usesNoteFields(tmplSource.content)
+	if len(config.Outputs) > 0 {
+		outputs, err := generatorOutputs(config)
+		if err != nil {
+			return nil, "", err
+		}
+		opts = append(opts, generator.WithOutputs(outputs...))
+		for _, o := range outputs {
+			readsNotes = readsNotes || usesNoteFields(o.Template)
+		}
+	}
+	if readsNotes {
+		notes := root
+		if notes == nil {
+			if notes, err = openJournalTree(config.RootDir, config); err != nil {
+				return nil, "", err
+			}
+		}
+		opts = append(opts, generator.WithNotes(notes))
+	}
+	if usesStreaks(tmplSource.content) {
+		streaks, err := templateStreaks(root, templateDate, config, logger)
+		if err != nil {
+			return nil, "", fmt.Errorf("error computing completion streaks: %w", err)
+		}
+		opts = append(opts, generator.WithStreaks(streaks))
+	}
+
+	gen, err := generator.NewGeneratorWithOptions(tmplSource.content, templateDate, opts...)
 	if err != nil {
-		return nil, "", fmt.Errorf("error creating generator from template: %w", err)
+		return nil, "", fmt.Errorf("error creating generator from template %s: %w", tmplSource.name, withSecretsHint(err))
 	}
 
 	return gen, tmplSource.name, nil
 }
 
-// processJournal processes a journal file, writing the target and optionally updating source with backup.
-func processJournal(sourceFile, targetFile, templateFile, templateDate string, skipBackup, printPath bool, config *Config, logger *Logger) error {
-	logger.Debug("Processing journal: source=%s, target=%s, template=%s, date=%s", sourceFile, targetFile, templateFile, templateDate)
+// noteFieldRegex matches calls of the noteField template function
+var noteFieldRegex = regexp.MustCompile(`\bnoteField\b`)
+
+// usesNoteFields reports whether templateContent reads other notes with noteField,
+// so that the journal tree is only opened for templates that do.
+func usesNoteFields(templateContent string) bool {
+	return noteFieldRegex.MatchString(templateContent)
+}
 
-	quiet := printPath
+// secretVars returns the custom variables templates must not use: those named in
+// secret_vars, unless --allow-secrets is given.
+func secretVars(config *Config) []string {
+	if CLI.AllowSecrets {
+		return nil
+	}
+	return config.SecretVars
+}
+
+// templateVars returns the custom variables templates are rendered with, those of
+// secretVars masked, since not every use of them can be told from the template.
+func templateVars(config *Config) map[string]interface{} {
+	return core.MaskSecrets(config.Custom, secretVars(config))
+}
+
+// checkTemplateSecrets rejects template content using the custom variables of secretVars.
+func checkTemplateSecrets(content string, config *Config) error {
+	return withSecretsHint(core.CheckTemplateSecrets(content, secretVars(config), core.TemplateFunctions(config.SprigFunctions)))
+}
+
+// withSecretsHint adds how to allow them to errors about templates using secret variables.
+func withSecretsHint(err error) error {
+	if errors.Is(err, core.ErrSecretVariable) {
+		return fmt.Errorf("%w (pass --allow-secrets to render them)", err)
+	}
+	return err
+}
+
+// movedMessageData is the data of the moved_message template
+type movedMessageData struct {
+	Date string // Date of the journal the todos were carried to
+	Path string // File of that journal, relative to the journal root for new
+	Name string // File name of that journal without extensions, as wiki links refer to it
+}
+
+// movedMessage renders the configured moved_message template for the journal of date
+// written to target. An empty result stands for the default message.
+func movedMessage(config *Config, date, target string) (string, error) {
+	if config.MovedMessage == "" {
+		return "", nil
+	}
+	tmpl, err := template.New("moved_message").Option("missingkey=error").Funcs(core.TemplateFunctions(config.SprigFunctions)).Parse(config.MovedMessage)
+	if err != nil {
+		return "", err
+	}
+	name := path.Base(target)
+	if journalCodecs[path.Ext(name)] != nil {
+		name = strings.TrimSuffix(name, path.Ext(name))
+	}
+	name = strings.TrimSuffix(name, ".md")
+	var b strings.Builder
+	if err := tmpl.Execute(&b, movedMessageData{Date: date, Path: target, Name: name}); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// withMovedMessage configures gen to leave the configured moved message, or none, in
+// a processed journal whose todos were all carried to target in store.
+func withMovedMessage(gen *generator.Generator, store storage.Storage, target, date string, config *Config) (*generator.Generator, error) {
+	if config.OmitMovedMessage {
+		return gen.WithOptions(generator.WithoutMovedMessage())
+	}
+	if date == "" {
+		date = effectiveToday(config)
+	}
+	if s, ok := store.(*singleFileStorage); ok {
+		target = s.file
+	}
+	message, err := movedMessage(config, date, filepath.ToSlash(target))
+	if err != nil {
+		return nil, fmt.Errorf("invalid moved_message: %w", err)
+	}
+	return gen.WithOptions(generator.WithMovedMessage(message))
+}
+
+// processJournal processes a journal file, writing the target and optionally updating source with backup.
+// Only the day sections in days are processed. The returned summary is filled in as far as
+// processing got, even when an error is returned.
+func processJournal(ctx context.Context, sourceFile, targetFile, templateFile, templateDate string, skipBackup bool, days core.DayRange, config *Config, logger *Logger) (*resultSummary, error) {
+	summary := newResultSummary("process")
+	summary.Source = sourceFile
+	summary.Target = targetFile
 
 	if err := validateProcessArgs(sourceFile, targetFile, templateDate); err != nil {
-		return err
+		return summary, err
 	}
 
+	store := localStore("")
+	if entries, err := store.List(path.Dir(filepath.ToSlash(sourceFile))); err == nil {
+		for _, conflict := range groupConflicts(entries) {
+			if conflict.Original == path.Clean(filepath.ToSlash(sourceFile)) {
+				warnConflicts(summary, store, []journalConflict{conflict}, logger)
+			}
+		}
+	}
+
+	err := processJournalIn(ctx, store, nil, sourceFile, targetFile, templateFile, templateDate, skipBackup, days, summary, config, logger)
+	if err == nil {
+		postAfterProcess(store, targetFile, summary, config, logger)
+	}
+	return summary, err
+}
+
+// processJournalIn processes the day sections in days of the journal sourceFile in store
+// into targetFile, recording the outcome in summary. An empty sourceFile starts from an
+// empty todos section.
+// Todos beyond max_carry are merged into the backlog file in root, the journal root,
+// or in the configured journal root if root is nil.
+func processJournalIn(ctx context.Context, store, root storage.Storage, sourceFile, targetFile, templateFile, templateDate string, skipBackup bool, days core.DayRange, summary *resultSummary, config *Config, logger *Logger) error {
+	logger.Debug("Processing journal: source=%s, target=%s, template=%s, date=%s", sourceFile, targetFile, templateFile, templateDate)
+
 	if err := validateConfig(config); err != nil {
-		return fmt.Errorf("configuration validation failed: %w", err)
+		return withExitCode(ExitConfigError, fmt.Errorf("configuration validation failed: %w", err))
+	}
+
+	sourceLocation := store.Location(sourceFile)
+	targetLocation := store.Location(targetFile)
+
+	var original, content []byte
+	if sourceFile == "" {
+		content = []byte(todosHeader(config) + "\n\n")
+		sourceLocation = "template"
+	} else {
+		if err := ctx.Err(); err != nil {
+			return withExitCode(ExitFailure, fmt.Errorf("error processing file %s: %v", sourceLocation, err))
+		}
+		var err error
+		if original, err = store.Read(sourceFile); err != nil {
+			return withExitCode(ExitFailure, fmt.Errorf("error processing file %s: %v", sourceLocation, err))
+		}
+		if content, err = decodeJournal(sourceFile, original, config); err != nil {
+			return withExitCode(ExitParseError, fmt.Errorf("error processing file %s: %v", sourceLocation, err))
+		}
+		if err := checkDependencies(string(content), summary, config, logger); err != nil {
+			return fmt.Errorf("error processing file %s: %w", sourceLocation, err)
+		}
 	}
 
-	gen, templateSource, err := getGenerator(templateFile, templateDate, sourceFile, config)
+	gen, templateSource, err := getGenerator(root, templateFile, templateDate, string(content), config, logger)
+	if err == nil && days != (core.DayRange{}) {
+		gen, err = gen.WithOptions(generator.WithDayRange(days))
+	}
+	if err == nil {
+		gen, err = withMovedMessage(gen, store, targetFile, templateDate, config)
+	}
 	if err != nil {
-		return err
+		return withExitCode(ExitConfigError, err)
 	}
+	summary.Template = templateSource
 
 	logger.Debug("Using template source: %s", templateSource)
 
-	result, err := gen.ProcessFile(sourceFile)
+	result, err := gen.ProcessContext(ctx, string(content))
 	if err != nil {
-		return fmt.Errorf("error processing file %s: %v", sourceFile, err)
+		code := ExitParseError
+		if ctx.Err() != nil {
+			code = ExitFailure
+		}
+		var jerr *core.JournalError
+		if errors.As(err, &jerr) {
+			return withExitCode(code, core.WithFile(err, sourceLocation))
+		}
+		return withExitCode(code, fmt.Errorf("error processing file %s: %v", sourceLocation, err))
+	}
+	summary.CarriedTodos = result.Stats.UncompletedTodos
+	summary.CompletedTodos = result.Stats.CompletedTodos
+	for _, w := range result.Warnings {
+		msg := fmt.Sprintf("%s:%d: %s", sourceLocation, w.Line, w.Message)
+		summary.addWarning("%s", msg)
+		logger.Warn("%s", msg)
 	}
 
 	modifiedContentBytes, err := io.ReadAll(result.ModifiedOriginal)
@@ -76,48 +306,164 @@ func processJournal(sourceFile, targetFile, templateFile, templateDate string, s
 		return fmt.Errorf("error reading modified content: %v", err)
 	}
 
-	newContentBytes, err := io.ReadAll(result.NewFile)
+	// Backlog first: if the target cannot be written, processing again merges the
+	// same todos into the backlog without duplicating them
+	if overflow := core.CountTotalItems(journalItems(result.Overflow)); overflow > 0 {
+		if root == nil {
+			if root, err = openJournalTree(config.RootDir, config); err != nil {
+				return withExitCode(ExitConfigError, err)
+			}
+		}
+		backlog, err := mergeBacklog(root, result.Overflow, sourceFile, config)
+		if err != nil {
+			return withExitCode(ExitWriteError, fmt.Errorf("error updating backlog %s: %v", root.Location(backlog), err))
+		}
+		summary.Backlog = root.Location(backlog)
+		summary.BacklogTodos = overflow
+		summary.CarriedTodos -= overflow
+		logger.Debug("Moved %d todos beyond max_carry to %s", overflow, summary.Backlog)
+	}
+
+	newContent, err := io.ReadAll(result.NewFile)
 	if err != nil {
-		return fmt.Errorf("error reading new file content: %v", err)
+		return fmt.Errorf("error reading new content: %v", err)
 	}
 
-	logger.Debug("Writing %d bytes to target file: %s", len(newContentBytes), targetFile)
-	if err := safeWriteFile(targetFile, newContentBytes, FilePermissions); err != nil {
-		return fmt.Errorf("error writing to target file %s: %v", targetFile, err)
+	// Processing into an existing target merges the carried todos into it instead
+	// of replacing it, which makes processing the same source again a no-op
+	if sourceFile != "" {
+		existing, merged, err := mergeIntoTarget(store, targetFile, newContent, config)
+		if err != nil {
+			return withExitCode(ExitParseError, fmt.Errorf("error merging into %s: %v", targetLocation, err))
+		}
+		if existing != nil {
+			if bytes.Equal(merged, existing) && bytes.Equal(modifiedContentBytes, content) {
+				summary.AlreadyExists = true
+				logger.Debug("%s was already processed into %s", sourceLocation, targetLocation)
+				return nil
+			}
+			logger.Debug("Merging the carried todos into the existing %s", targetLocation)
+			newContent = merged
+		}
 	}
 
-	logger.Info("Successfully processed %s -> %s (template: %s)", sourceFile, targetFile, templateSource)
+	// The target, the backup and the processed source are written together: if
+	// one of them cannot be written, the others are rolled back
+	logger.Debug("Writing target file: %s", targetLocation)
+	writes := []pendingWrite{{name: targetFile, data: newContent}}
+	backupFile := ""
+	if len(modifiedContentBytes) > 0 && !skipBackup && sourceFile != "" {
+		backupFile = sourceFile + ".bak"
+		writes = append(writes,
+			pendingWrite{name: backupFile, data: original},
+			pendingWrite{name: sourceFile, data: modifiedContentBytes},
+		)
+	}
+	if err := writeJournalFiles(store, writes, config); err != nil {
+		return withExitCode(ExitWriteError, fmt.Errorf("error writing %s: %v", targetLocation, err))
+	}
 
-	if printPath {
-		fmt.Println(targetFile)
+	logger.Info("Successfully processed %s -> %s (template: %s)", sourceLocation, targetLocation, templateSource)
+	if backupFile != "" {
+		summary.Backup = store.Location(backupFile)
 	}
 
-	if len(modifiedContentBytes) > 0 && !skipBackup {
-		backupFile := sourceFile + ".bak"
-		originalContentBytes, err := os.ReadFile(sourceFile)
-		if err != nil {
-			return fmt.Errorf("error reading original file for backup: %v", err)
+	// Outputs last: appending outputs must not be appended to again when an earlier
+	// step fails and processing is repeated
+	if len(result.Outputs) > 0 {
+		if root == nil {
+			if root, err = openJournalTree(config.RootDir, config); err != nil {
+				return withExitCode(ExitConfigError, err)
+			}
 		}
-		if err := safeWriteFile(backupFile, originalContentBytes, FilePermissions); err != nil {
-			return fmt.Errorf("error creating backup file %s: %v", backupFile, err)
+		if err := writeOutputs(root, result.Outputs, sourceFile, summary, config, logger); err != nil {
+			return withExitCode(ExitWriteError, err)
 		}
+	}
 
-		if err := safeWriteFile(sourceFile, modifiedContentBytes, FilePermissions); err != nil {
-			return fmt.Errorf("error updating source file %s: %v", sourceFile, err)
-		}
+	return nil
+}
 
-		if !quiet {
-			fmt.Printf("Backup of original file created: %s\n", backupFile)
-		}
-	} else if !quiet {
-		fmt.Printf("No modifications found in the original file, backup not created.\n")
+// mergeIntoTarget merges the todos of newContent, the new journal rendered by
+// processing, into the existing journal targetFile in store. It returns the decoded
+// content of the target and the merged content, the same when the target already
+// holds every todo, or nil for both if the target does not exist or is empty. A
+// target whose todos section cannot be parsed is an error rather than replaced.
+func mergeIntoTarget(store storage.Storage, targetFile string, newContent []byte, config *Config) ([]byte, []byte, error) {
+	existing, err := readJournalFile(store, targetFile, config)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(bytes.TrimSpace(existing)) == 0 {
+		return nil, nil, nil
 	}
 
-	return nil
+	parser := journalParser(config)
+	target, err := parser.ParseJournal(string(existing))
+	if err != nil {
+		return nil, nil, err
+	}
+	carried, err := parser.ParseJournal(string(newContent))
+	if err != nil {
+		return nil, nil, err
+	}
+	renderer := journalRenderer(config)
+	before := renderer.Render(target)
+	merged := core.MergeJournals(target, carried)
+	if renderer.Render(merged) == before {
+		return existing, existing, nil
+	}
+	updated, err := renderer.ReplaceJournal(string(existing), merged)
+	if err != nil {
+		return nil, nil, err
+	}
+	return existing, []byte(updated), nil
+}
+
+// Values of symlinks, deciding whether walks of a local journal tree follow
+// symbolic links
+const (
+	SymlinksFollow = "follow"
+	SymlinksIgnore = "ignore"
+)
+
+// openJournalTree opens the journal tree below rootDir, a local directory or a
+// storage URL understood by storage.Open, leaving the files matching the ignore
+// patterns of config and of the .todoerignore file at its root out of its walks,
+// and symbolic links too if config says so.
+func openJournalTree(rootDir string, config *Config) (storage.Storage, error) {
+	store, err := storage.Open(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	if local, ok := store.(*storage.Local); ok {
+		local.SkipSymlinks = config.Symlinks == SymlinksIgnore
+		local.Retry = fileRetry
+		local.Mode = fileMode
+	}
+	patterns := append([]string{}, config.Ignore...)
+	content, err := store.Read(IgnoreFile)
+	if err != nil && !errors.Is(err, storage.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read %s: %w", store.Location(IgnoreFile), err)
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			patterns = append(patterns, line)
+		}
+	}
+	for _, pattern := range patterns {
+		if err := storage.ValidatePattern(pattern); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+		}
+	}
+	return storage.Ignore(store, patterns...), nil
 }
 
-// findClosestJournalFile returns the most recent journal before the given date.
-func findClosestJournalFile(rootDir, today string) (string, error) {
+// findClosestJournalFile returns the name of the most recent journal in store before the given date.
+func findClosestJournalFile(store storage.Storage, today string) (string, error) {
 	var closestFile string
 	var minDiff time.Duration = -1
 
@@ -126,21 +472,12 @@ func findClosestJournalFile(rootDir, today string) (string, error) {
 		return "", fmt.Errorf("invalid today date: %w", err)
 	}
 
-	err = filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if info.IsDir() {
+	err = storage.Walk(store, "", func(info storage.FileInfo) error {
+		dateStr, ok := journalDateFromPath(info.Name)
+		if !ok {
 			return nil
 		}
-
-		base := filepath.Base(path)
-		if len(base) != len("2006-01-02.md") || filepath.Ext(base) != ".md" {
-			return nil
-		}
-
-		dateStr := strings.TrimSuffix(base, ".md")
-		fileTime, err := time.Parse("2006-01-02", dateStr)
+		fileTime, err := time.Parse(core.DateFormat, dateStr)
 		if err != nil {
 			return nil
 		}
@@ -149,7 +486,7 @@ func findClosestJournalFile(rootDir, today string) (string, error) {
 			diff := todayTime.Sub(fileTime)
 			if minDiff == -1 || diff < minDiff {
 				minDiff = diff
-				closestFile = path
+				closestFile = info.Name
 			}
 		}
 
@@ -161,72 +498,131 @@ func findClosestJournalFile(rootDir, today string) (string, error) {
 	}
 
 	if closestFile == "" {
-		return "", fmt.Errorf("no previous journal found in %s", rootDir)
+		return "", fmt.Errorf("no previous journal found in %s", store.Location(""))
 	}
 
 	return closestFile, nil
 }
 
-// cmdNew creates today's journal using the closest previous journal or a blank template.
-func cmdNew(rootDir, templateFile string, printPath bool, config *Config, logger *Logger) error {
-	today := time.Now().Format(core.DateFormat)
-	journalPath := buildJournalPath(rootDir, today)
+// journalDateFromPath returns the date of a journal file named YYYY-MM-DD.md, or
+// YYYY_MM_DD.md like Logseq journals, optionally followed by the extension of a
+// journal codec such as .age. It reports false for any other file name.
+func journalDateFromPath(path string) (string, bool) {
+	base := filepath.Base(path)
+	if _, ok := journalCodecs[filepath.Ext(base)]; ok {
+		base = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	if len(base) != len("2006-01-02.md") || filepath.Ext(base) != ".md" {
+		return "", false
+	}
 
-	if _, err := os.Stat(journalPath); err == nil {
-		if printPath {
-			fmt.Println(journalPath)
-		} else {
-			fmt.Printf("Journal for today already exists: %s\n", journalPath)
-		}
-		return nil
+	dateStr := strings.ReplaceAll(strings.TrimSuffix(base, ".md"), "_", "-")
+	if _, err := time.Parse(core.DateFormat, dateStr); err != nil {
+		return "", false
+	}
+	return dateStr, true
+}
+
+// cmdNew creates today's journal, in the configured timezone, using the closest previous
+// journal or a blank template.
+// rootDir is a local directory or a storage URL understood by storage.Open.
+func cmdNew(ctx context.Context, rootDir, templateFile string, config *Config, logger *Logger) (*resultSummary, error) {
+	today, err := newJournalDate(effectiveToday(config), config)
+	if err != nil {
+		return newResultSummary("new"), err
 	}
+	return createJournal(ctx, rootDir, templateFile, today, config, logger)
+}
 
-	if err := os.MkdirAll(filepath.Dir(journalPath), 0o755); err != nil {
-		return err
+// createJournal creates the journal for today like new does for the current date,
+// carrying over from the closest previous journal. An existing journal is left alone.
+func createJournal(ctx context.Context, rootDir, templateFile, today string, config *Config, logger *Logger) (*resultSummary, error) {
+	summary := newResultSummary("new")
+	if err := validateDateFormat(today); err != nil {
+		return summary, withExitCode(ExitConfigError, err)
 	}
 
-	closest, err := findClosestJournalFile(rootDir, today)
-	skipBackup := false
+	store, err := openJournalTree(rootDir, config)
 	if err != nil {
-		if !printPath {
-			fmt.Println("No previous journal found, creating a new one from template.")
-		}
+		return summary, withExitCode(ExitConfigError, err)
+	}
+
+	if conflicts, err := findConflicts(store); err == nil {
+		warnConflicts(summary, store, conflicts, logger)
+	} else {
+		logger.Debug("Could not check for conflicting copies: %v", err)
+	}
 
-		tmpFile, err := os.CreateTemp("", "empty-journal-*.md")
+	if config.JournalFile != "" {
+		s, err := openSingleFile(store, config.JournalFile, config)
 		if err != nil {
-			return fmt.Errorf("failed to create temp file: %w", err)
+			return summary, err
 		}
-		defer os.Remove(tmpFile.Name())
+		return summary, appendDay(ctx, s, store, templateFile, today, core.DayRange{}, summary, config, logger)
+	}
 
-		if _, err := tmpFile.WriteString(core.TodosHeader + "\n\n"); err != nil {
-			return fmt.Errorf("failed to write to temp file: %w", err)
-		}
-		if err := tmpFile.Close(); err != nil {
-			return fmt.Errorf("failed to close temp file: %w", err)
-		}
+	journalFile := resolveJournalName(store, today, config)
+	summary.Target = store.Location(journalFile)
+
+	if _, err := store.Stat(journalFile); err == nil {
+		summary.AlreadyExists = true
+		return summary, nil
+	}
 
-		closest = tmpFile.Name()
-		skipBackup = true
+	if err := store.MkdirAll(path.Dir(journalFile)); err != nil {
+		return summary, withExitCode(ExitWriteError, err)
 	}
 
-	if !printPath {
-		fmt.Printf("Using '%s' as source to create new journal for today.\n", closest)
+	closest, journalFile, err := previousJournal(store, journalFile, today, config)
+	if err != nil {
+		return summary, err
+	}
+	skipBackup := closest == ""
+	summary.Target = store.Location(journalFile)
+	if closest != "" {
+		summary.Source = store.Location(closest)
 	}
 
-	if err := processJournal(closest, journalPath, templateFile, today, skipBackup, printPath, config, logger); err != nil {
-		return err
+	err = processJournalIn(ctx, store, store, closest, journalFile, templateFile, today, skipBackup, core.DayRange{}, summary, config, logger)
+	if skipBackup {
+		summary.fromTemplate = true
+		summary.addWarning("no previous journal found in %s, created from template", rootDir)
+	}
+	if err == nil {
+		postAfterProcess(store, journalFile, summary, config, logger)
 	}
 
-	return nil
+	return summary, err
 }
 
-// buildJournalPath constructs a YYYY/MM/YYYY-MM-DD.md path under rootDir.
-func buildJournalPath(rootDir, date string) string {
+// previousJournal returns the journal in store before today that new carries over
+// from, see selectPreviousJournal, empty if there is none, and journalFile with the
+// extension of its encoding: encoded journals stay encoded from day to day.
+func previousJournal(store storage.Storage, journalFile, today string, config *Config) (string, string, error) {
+	previous, err := selectPreviousJournal(store, today, config)
+	if err != nil || previous == "" {
+		return "", journalFile, err
+	}
+	if ext := filepath.Ext(previous); journalCodecs[ext] != nil && filepath.Ext(journalFile) == ".md" {
+		journalFile += ext
+	}
+	return previous, journalFile, nil
+}
+
+// journalName returns the YYYY/MM/YYYY-MM-DD.md name of the journal for date
+// relative to the journal root, or journals/YYYY_MM_DD.md in the logseq dialect.
+func journalName(date string, config *Config) string {
 	t, err := time.Parse(core.DateFormat, date)
 	if err != nil {
 		t = time.Now()
 	}
-	year := t.Format("2006")
-	month := t.Format("01")
-	return filepath.Join(rootDir, year, month, date+".md")
+	if config != nil && config.Dialect == DialectLogseq {
+		return path.Join(LogseqJournalsDir, t.Format("2006_01_02")+".md")
+	}
+	return path.Join(t.Format("2006"), t.Format("01"), date+".md")
+}
+
+// buildJournalPath constructs a YYYY/MM/YYYY-MM-DD.md path under rootDir.
+func buildJournalPath(rootDir, date string) string {
+	return filepath.Join(rootDir, filepath.FromSlash(journalName(date, nil)))
 }
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/inful/todoer/pkg/core"
+	"github.com/inful/todoer/pkg/storage"
+)
+
+// DefaultJournalCacheSize is the number of parsed journals serve keeps in memory
+const DefaultJournalCacheSize = 64
+
+// journalCache keeps the journals of a tree between the refreshes of a
+// long-running command. A refresh only reads the journals whose size or
+// modification time changed, and only parses those whose content changed too.
+// What the metrics need of every journal is kept for all of them; the parsed
+// journals themselves only for the most recently used ones.
+type journalCache struct {
+	mu       sync.Mutex
+	store    storage.Storage
+	config   *Config
+	parser   *core.Parser
+	capacity int                       // Parsed journals kept at most
+	files    map[string]*cachedJournal // Journals of the tree by name
+	recent   *list.List                // Journals holding their parse, most recently used first
+	parses   int                       // Journals parsed since the cache was created
+}
+
+// cachedJournal is a journal of the tree as of the last refresh
+type cachedJournal struct {
+	name        string
+	date        string
+	size        int64
+	modTime     time.Time
+	hash        [sha256.Size]byte
+	err         error             // Why the journal could not be read or parsed, if it could not
+	completions []core.Completion // Completed todos, with their texts hashed like the index does
+	journal     *core.TodoJournal // Parsed journal, nil if it was evicted
+	element     *list.Element     // Element of the journal in recent, nil if evicted
+}
+
+// newJournalCache creates an empty cache of the journals of store, keeping up to
+// capacity parsed journals, at least one.
+func newJournalCache(store storage.Storage, capacity int, config *Config) *journalCache {
+	return &journalCache{
+		store:    store,
+		config:   config,
+		parser:   journalParser(config),
+		capacity: max(capacity, 1),
+		files:    make(map[string]*cachedJournal),
+		recent:   list.New(),
+	}
+}
+
+// reconfigure makes the cache read journals with config from now on. A config
+// other than the current one, as reloaded by a long-running command, empties the
+// cache, since it may parse the journals differently.
+func (c *journalCache) reconfigure(config *Config) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if config == c.config {
+		return
+	}
+	c.config, c.parser = config, journalParser(config)
+	c.files = make(map[string]*cachedJournal)
+	c.recent.Init()
+}
+
+// refresh brings the cache up to date with the tree and returns the number of
+// journals parsed again.
+func (c *journalCache) refresh() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	parsed := 0
+	seen := make(map[string]bool)
+	err := storage.Walk(c.store, "", func(info storage.FileInfo) error {
+		date, ok := journalDateFromPath(info.Name)
+		if !ok {
+			return nil
+		}
+		seen[info.Name] = true
+		cached, ok := c.files[info.Name]
+		if ok && !info.ModTime.IsZero() && cached.size == info.Size && cached.modTime.Equal(info.ModTime) {
+			return nil
+		}
+		if !ok {
+			cached = &cachedJournal{name: info.Name, date: date}
+			c.files[info.Name] = cached
+		}
+		cached.size, cached.modTime = info.Size, info.ModTime
+
+		content, err := readJournalFile(c.store, info.Name, c.config)
+		if err != nil {
+			c.forget(cached)
+			cached.err, cached.completions = err, nil
+			return nil
+		}
+		// A journal saved without changes keeps its parse
+		if hash := sha256.Sum256(content); hash != cached.hash || cached.err != nil {
+			cached.hash = hash
+			c.parse(cached, content)
+			parsed++
+		}
+		return nil
+	})
+	if err != nil {
+		return parsed, fmt.Errorf("failed to scan journals in %s: %w", c.store.Location(""), err)
+	}
+
+	for name, cached := range c.files {
+		if !seen[name] {
+			c.forget(cached)
+			delete(c.files, name)
+		}
+	}
+	return parsed, nil
+}
+
+// parse parses the content of cached and keeps the result as most recently used.
+func (c *journalCache) parse(cached *cachedJournal, content []byte) {
+	c.parses++
+	c.forget(cached)
+	journal, err := c.parser.ParseJournal(string(content))
+	cached.err, cached.completions = err, nil
+	if err != nil {
+		return
+	}
+	for _, completion := range core.CollectCompletions(journal, cached.date) {
+		completion.Text = hashCompletionText(completion.Text)
+		cached.completions = append(cached.completions, completion)
+	}
+	c.remember(cached, journal)
+}
+
+// remember keeps the parsed journal of cached as most recently used, evicting the
+// least recently used journals beyond the capacity.
+func (c *journalCache) remember(cached *cachedJournal, journal *core.TodoJournal) {
+	cached.journal = journal
+	cached.element = c.recent.PushFront(cached)
+	for c.recent.Len() > c.capacity {
+		c.forget(c.recent.Back().Value.(*cachedJournal))
+	}
+}
+
+// forget drops the parsed journal of cached.
+func (c *journalCache) forget(cached *cachedJournal) {
+	if cached.element != nil {
+		c.recent.Remove(cached.element)
+	}
+	cached.journal, cached.element = nil, nil
+}
+
+// journal returns the parsed journal name as of the last refresh, parsing it
+// again if it was evicted.
+func (c *journalCache) journal(name string) (*core.TodoJournal, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached, ok := c.files[name]
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("no journal %s", c.store.Location(name))
+	case cached.err != nil:
+		return nil, cached.err
+	case cached.journal != nil:
+		c.recent.MoveToFront(cached.element)
+		return cached.journal, nil
+	}
+
+	content, err := readJournalFile(c.store, name, c.config)
+	if err != nil {
+		return nil, err
+	}
+	if sha256.Sum256(content) != cached.hash {
+		// Changed since the last refresh, which will parse it
+		c.parses++
+		return c.parser.ParseJournal(string(content))
+	}
+	c.parse(cached, content)
+	return cached.journal, cached.err
+}
+
+// journalCacheStats summarises the journals of a cache.
+type journalCacheStats struct {
+	Journals    int               // Journals in the tree
+	Unparseable int               // Journals that could not be read or parsed
+	Latest      string            // Name of the latest journal, empty if there is none
+	Completions []core.Completion // Completed todos of all journals, texts hashed
+	Parses      int               // Journals parsed since the cache was created
+}
+
+// stats returns the summary of the journals as of the last refresh.
+func (c *journalCache) stats() journalCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := journalCacheStats{Journals: len(c.files), Parses: c.parses}
+	names := make([]string, 0, len(c.files))
+	for name := range c.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	latest := ""
+	for _, name := range names {
+		cached := c.files[name]
+		if cached.err != nil {
+			stats.Unparseable++
+			continue
+		}
+		stats.Completions = append(stats.Completions, cached.completions...)
+		if cached.date > latest {
+			latest, stats.Latest = cached.date, name
+		}
+	}
+	return stats
+}
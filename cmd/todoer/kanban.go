@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/inful/todoer/pkg/core"
+)
+
+// kanbanCardRegex matches a card of a Kanban board: a top-level checkbox item.
+// Captures: (checkbox state) (text)
+var kanbanCardRegex = regexp.MustCompile(`^- \[([ xX])\] (.*?)\s*$`)
+
+// kanbanSettings ends a board, marking it as one for the Obsidian Kanban plugin
+const kanbanSettings = "%% kanban:settings\n```\n{\"kanban-plugin\":\"basic\"}\n```\n%%\n"
+
+// kanbanColumns splits columns into the names of the open, blocked and completed
+// columns of a board.
+func kanbanColumns(columns string) ([]string, error) {
+	names := strings.Split(columns, ",")
+	for i, name := range names {
+		names[i] = strings.TrimSpace(name)
+		if names[i] == "" {
+			return nil, fmt.Errorf("invalid --columns %q: column names cannot be empty", columns)
+		}
+	}
+	if len(names) != 3 {
+		return nil, fmt.Errorf("invalid --columns %q: name three columns, for open, blocked and completed tasks", columns)
+	}
+	return names, nil
+}
+
+// writeKanbanBoard writes the top-level tasks of journal as a board for the Obsidian
+// Kanban plugin with the named columns: open tasks go into the first, open tasks
+// blocked by another open task into the second and completed tasks into the last,
+// which the plugin keeps checked.
+func writeKanbanBoard(w io.Writer, journal *core.TodoJournal, columns []string) error {
+	lanes := make([][]*core.TodoItem, len(columns))
+	for _, loc := range core.FindItems(journal, func(item *core.TodoItem) bool { return item.Text != "" }) {
+		if loc.Parent != nil {
+			continue
+		}
+		lane := 0
+		switch {
+		case loc.Item.Completed:
+			lane = 2
+		case core.IsBlocked(journal, loc.Item):
+			lane = 1
+		}
+		lanes[lane] = append(lanes[lane], loc.Item)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("---\n\nkanban-plugin: basic\n\n---\n\n")
+	for i, name := range columns {
+		fmt.Fprintf(&buf, "## %s\n\n", name)
+		if i == len(columns)-1 {
+			buf.WriteString("**Complete**\n")
+		}
+		for _, item := range lanes[i] {
+			checkbox := " "
+			if item.Completed {
+				checkbox = "x"
+			}
+			fmt.Fprintf(&buf, "- [%s] %s\n", checkbox, item.Text)
+		}
+		buf.WriteString("\n\n")
+	}
+	buf.WriteString("\n\n" + kanbanSettings)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// parseKanbanBoard returns the completion state of the cards of a board by task
+// ID, see syncTaskID. Only cards at the top level of a column count; the card
+// text written by export is the task text.
+func parseKanbanBoard(content string) map[string]bool {
+	states := make(map[string]bool)
+	for i, line := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		if i == 0 && line == "---" {
+			continue
+		}
+		if strings.HasPrefix(line, "%% kanban:settings") {
+			break
+		}
+		if m := kanbanCardRegex.FindStringSubmatch(line); m != nil {
+			states[syncTaskID(m[2])] = m[1] != " "
+		}
+	}
+	return states
+}
+
+// exportKanban writes the top-level tasks of the current journal below rootDir as a
+// Kanban board with the named columns, on w or into file if set.
+func exportKanban(w io.Writer, rootDir, columnNames, file string, now time.Time, config *Config, logger *Logger) error {
+	columns, err := kanbanColumns(columnNames)
+	if err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+
+	store, err := openJournalTree(rootDir, config)
+	if err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+	name, err := currentJournalName(store, effectiveDay(now, config).Format(core.DateFormat), config)
+	if err != nil {
+		return err
+	}
+	logger.Debug("Exporting %s", store.Location(name))
+	_, journal, err := readJournalTodos(store, name, config)
+	if err != nil {
+		return fmt.Errorf("%s: %w", store.Location(name), err)
+	}
+
+	if file == "" {
+		return writeKanbanBoard(w, journal, columns)
+	}
+	var buf bytes.Buffer
+	if err := writeKanbanBoard(&buf, journal, columns); err != nil {
+		return err
+	}
+	if err := safeWriteFile(file, buf.Bytes(), fileMode); err != nil {
+		return withExitCode(ExitWriteError, fmt.Errorf("failed to write board: %w", err))
+	}
+	logger.Info("Board of %s written to %s", store.Location(name), file)
+	return nil
+}
+
+// cmdImport reads file in format and sets the completion state of the top-level
+// tasks of the current journal below rootDir to that of their cards, reporting the
+// changes on w. Tasks are matched by syncTaskID, so the completion date tag does
+// not get in the way. Cards without a task in the journal are left alone.
+func cmdImport(w io.Writer, rootDir, format, file string, now time.Time, config *Config, logger *Logger) error {
+	if format != ExportKanban {
+		return errors.New("--format must be kanban")
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read board: %w", err)
+	}
+	states := parseKanbanBoard(string(content))
+
+	store, err := openJournalTree(rootDir, config)
+	if err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+	name, err := currentJournalName(store, effectiveDay(now, config).Format(core.DateFormat), config)
+	if err != nil {
+		return err
+	}
+	journalContent, journal, err := readJournalTodos(store, name, config)
+	if err != nil {
+		return fmt.Errorf("%s: %w", store.Location(name), err)
+	}
+
+	changed := 0
+	matched := make(map[string]bool)
+	for _, loc := range core.FindItems(journal, func(item *core.TodoItem) bool { return item.Text != "" }) {
+		if loc.Parent != nil {
+			continue
+		}
+		id := syncTaskID(loc.Item.Text)
+		completed, ok := states[id]
+		if !ok {
+			continue
+		}
+		matched[id] = true
+		if loc.Item.Completed != completed {
+			loc.Item.Completed = completed
+			if config.CompletionTime {
+				core.StampCompletion(loc.Item, now.In(configLocation(config)))
+			}
+			changed++
+		}
+	}
+	if unmatched := len(states) - len(matched); unmatched > 0 {
+		logger.Info("%d %s not found in %s", unmatched, plural(unmatched, "card was", "cards were"), store.Location(name))
+	}
+
+	if changed > 0 {
+		updated, err := journalRenderer(config).ReplaceJournal(journalContent, journal)
+		if err != nil {
+			return fmt.Errorf("failed to update %s: %w", store.Location(name), err)
+		}
+		if err := writeJournalFile(store, name, []byte(updated), config); err != nil {
+			return withExitCode(ExitWriteError, fmt.Errorf("failed to write %s: %w", store.Location(name), err))
+		}
+	}
+	fmt.Fprintf(w, "Updated %d %s in %s\n", changed, plural(changed, "task", "tasks"), store.Location(name))
+	return nil
+}
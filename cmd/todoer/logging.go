@@ -38,6 +38,14 @@ func (l *Logger) Info(format string, args ...interface{}) {
 	fmt.Fprintf(os.Stderr, "INFO: "+format+"\n", args...)
 }
 
+// Warn logs warnings unless in quiet mode.
+func (l *Logger) Warn(format string, args ...interface{}) {
+	if l.mode == ModeQuiet {
+		return
+	}
+	fmt.Fprintf(os.Stderr, stderrColors.yellow("WARNING:")+" "+format+"\n", args...)
+}
+
 // Debug logs debug messages only in debug mode.
 func (l *Logger) Debug(format string, args ...interface{}) {
 	if l.mode != ModeDebug {
@@ -48,5 +56,5 @@ func (l *Logger) Debug(format string, args ...interface{}) {
 
 // Error always logs errors.
 func (l *Logger) Error(format string, args ...interface{}) {
-	log.Printf("ERROR: "+format, args...)
+	log.Printf(stderrColors.red("ERROR:")+" "+format, args...)
 }
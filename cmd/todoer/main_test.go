@@ -1,13 +1,33 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
+	"regexp"
 	"strings"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
+
+	"github.com/inful/todoer/pkg/caldav"
+	"github.com/inful/todoer/pkg/core"
+	"github.com/inful/todoer/pkg/generator"
+	"github.com/inful/todoer/pkg/storage"
 )
 
 // Helper function to create a temporary directory and clean it up
@@ -139,6 +159,218 @@ func TestResolveTemplate(t *testing.T) {
 	}
 }
 
+func TestTemplateStore(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tempDir, "config"))
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(tempDir, "cache"))
+	createTestFile(t, filepath.Join(tempDir, "config", "todoer", "templates", "daily.md"), "# Daily {{.Date}}")
+
+	remote := "# Remote {{.Date}}"
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/daily.md" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, remote)
+	}))
+	defer server.Close()
+	sum := fmt.Sprintf("%x", sha256.Sum256([]byte(remote)))
+
+	t.Run("named template", func(t *testing.T) {
+		source := newTemplateStore().load("daily")
+		if source.err != nil {
+			t.Fatalf("load() error = %v", source.err)
+		}
+		if source.content != "# Daily {{.Date}}" {
+			t.Errorf("content = %q", source.content)
+		}
+		if want := filepath.Join(tempDir, "config", "todoer", "templates", "daily.md"); source.name != want {
+			t.Errorf("name = %q, want %q", source.name, want)
+		}
+		if err := validateTemplateRef("weekly"); !errors.Is(err, ErrTemplateNotFound) {
+			t.Errorf("validateTemplateRef(weekly) error = %v, want ErrTemplateNotFound", err)
+		}
+	})
+
+	t.Run("url is cached", func(t *testing.T) {
+		requests = 0
+		for i := 0; i < 2; i++ {
+			source := newTemplateStore().load(server.URL + "/daily.md")
+			if source.err != nil {
+				t.Fatalf("load() error = %v", source.err)
+			}
+			if source.content != remote || source.name != server.URL+"/daily.md" {
+				t.Errorf("load() = %q from %q", source.content, source.name)
+			}
+		}
+		if requests != 1 {
+			t.Errorf("fetched %d times, want 1", requests)
+		}
+	})
+
+	t.Run("pinned url", func(t *testing.T) {
+		source := newTemplateStore().load(server.URL + "/daily.md#sha256=" + sum)
+		if source.err != nil || source.content != remote {
+			t.Fatalf("load() = %q, %v", source.content, source.err)
+		}
+
+		wrong := strings.Repeat("0", 64)
+		store := newTemplateStore()
+		store.cacheDir = ""
+		source = store.load(server.URL + "/daily.md#sha256=" + wrong)
+		if source.err == nil || !strings.Contains(source.err.Error(), "does not match its checksum") {
+			t.Errorf("load() with wrong checksum error = %v", source.err)
+		}
+		if err := validateTemplateRef(server.URL + "/daily.md#sha256=abc"); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("validateTemplateRef() with short checksum error = %v, want ErrInvalidConfig", err)
+		}
+	})
+
+	t.Run("stale cache is used offline", func(t *testing.T) {
+		store := newTemplateStore()
+		location := server.URL + "/daily.md"
+		old := time.Now().Add(-2 * templateCacheTTL)
+		if err := os.Chtimes(store.cachePath(location), old, old); err != nil {
+			t.Fatal(err)
+		}
+		server.Close()
+		source := store.load(location)
+		if source.err != nil || source.content != remote {
+			t.Errorf("load() = %q, %v, want the cached template", source.content, source.err)
+		}
+
+		source = store.load(server.URL + "/missing.md")
+		if source.err == nil {
+			t.Error("load() of an uncached template offline succeeded")
+		}
+	})
+
+	t.Run("config relative paths", func(t *testing.T) {
+		configPath := filepath.Join(tempDir, "config", "todoer", "config.toml")
+		for ref, want := range map[string]string{
+			"daily":                  "daily",
+			server.URL + "/daily.md": server.URL + "/daily.md",
+			"daily.md":               filepath.Join(tempDir, "config", "todoer", "daily.md"),
+		} {
+			if got := configRelativeTemplate(configPath, ref); got != want {
+				t.Errorf("configRelativeTemplate(%q) = %q, want %q", ref, got, want)
+			}
+		}
+	})
+}
+
+func TestInspectTemplate(t *testing.T) {
+	content := `{{.Date}} {{addDays .Date 1}}
+{{if gt .TotalTodos 0}}{{.Custom.Project}}{{end}}
+{{range .TopTodos}}{{.Text}} {{$.DayName}}{{else}}{{.LongestStreak}}{{end}}
+{{with .Goals}}{{.Goal}}{{end}}`
+	usage, err := inspectTemplate(content)
+	if err != nil {
+		t.Fatalf("inspectTemplate() error = %v", err)
+	}
+	wantVariables := []string{".Custom.Project", ".Date", ".DayName", ".Goals", ".LongestStreak", ".TopTodos", ".TotalTodos"}
+	if !reflect.DeepEqual(usage.Variables, wantVariables) {
+		t.Errorf("Variables = %v, want %v", usage.Variables, wantVariables)
+	}
+	if wantFunctions := []string{"addDays", "gt"}; !reflect.DeepEqual(usage.Functions, wantFunctions) {
+		t.Errorf("Functions = %v, want %v", usage.Functions, wantFunctions)
+	}
+
+	if _, err := inspectTemplate("{{.Date"); err == nil {
+		t.Error("inspectTemplate() of an invalid template succeeded")
+	}
+}
+
+func TestCmdTemplates(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tempDir, "config"))
+	store := newTemplateStore()
+	logger := NewLogger(ModeQuiet)
+
+	var out bytes.Buffer
+	if err := cmdTemplatesNew(&out, store, "daily", false, logger); err != nil {
+		t.Fatalf("cmdTemplatesNew() error = %v", err)
+	}
+	if err := cmdTemplatesNew(&out, store, "daily", false, logger); err == nil {
+		t.Error("cmdTemplatesNew() overwrote an existing template")
+	}
+	if err := cmdTemplatesNew(&out, store, "../daily", false, logger); err == nil {
+		t.Error("cmdTemplatesNew() accepted an invalid name")
+	}
+
+	// The scaffold renders as a journal template
+	gen, err := generator.NewGeneratorWithOptions(scaffoldTemplate, "2025-06-20")
+	if err != nil {
+		t.Fatalf("scaffold is not a valid template: %v", err)
+	}
+	result, err := gen.Process(core.TodosHeader + "\n\n- [ ] Task\n")
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	rendered, _ := io.ReadAll(result.NewFile)
+	if !strings.HasPrefix(string(rendered), "---\ntitle: 2025-06-20") || !strings.Contains(string(rendered), "- [ ] Task") {
+		t.Errorf("scaffold rendered as %q", rendered)
+	}
+
+	config := &Config{
+		TemplateFile: "daily",
+		Templates:    map[string]string{"friday": filepath.Join(tempDir, "review.md")},
+	}
+	out.Reset()
+	if err := cmdTemplatesList(&out, config); err != nil {
+		t.Fatalf("cmdTemplatesList() error = %v", err)
+	}
+	for _, want := range []string{"embedded default template", store.file("daily"), "template_file", "configured", "templates.friday"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("cmdTemplatesList() output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := cmdTemplatesShow(&out, "daily"); err != nil {
+		t.Fatalf("cmdTemplatesShow() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "Variables: ") || !strings.Contains(out.String(), ".TODOS") {
+		t.Errorf("cmdTemplatesShow() output = %q", out.String())
+	}
+
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "true")
+	if err := cmdTemplatesEdit(store, "daily"); err != nil {
+		t.Errorf("cmdTemplatesEdit() error = %v", err)
+	}
+	if err := cmdTemplatesEdit(store, "weekly"); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("cmdTemplatesEdit() of a missing template error = %v, want ErrTemplateNotFound", err)
+	}
+	if err := cmdTemplatesEdit(store, "https://example.com/daily.md"); err == nil {
+		t.Error("cmdTemplatesEdit() of a URL succeeded")
+	}
+}
+
+func TestCmdPreviewListVars(t *testing.T) {
+	tempDir := t.TempDir()
+	templateFile := filepath.Join(tempDir, "template.md")
+	createTestFile(t, templateFile, "# {{.Date}}\n\n## Todos\n\n{{.TODOS}}\n")
+
+	var out bytes.Buffer
+	opts := previewOptions{TemplateFile: templateFile, Date: "2025-06-20", TodosString: "- [ ] Task", CustomVars: `{"Project":"todoer"}`}
+	if err := cmdPreviewListVars(&out, opts, &Config{}); err != nil {
+		t.Fatalf("cmdPreviewListVars() error = %v", err)
+	}
+	for _, want := range []string{"Variables for 2025-06-20", "{{.DayName}}", "Friday", "{{.Custom.Project}}", "todoer", "addDays(string, int) string", "2025-06-27"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	opts.Date = "20-06-2025"
+	if err := cmdPreviewListVars(&out, opts, &Config{}); err == nil {
+		t.Error("cmdPreviewListVars() accepted an invalid date")
+	}
+}
+
 func TestLoadConfig(t *testing.T) {
 	// Save original environment to avoid interference from workspace files
 	originalXDG := os.Getenv("XDG_CONFIG_HOME")
@@ -181,7 +413,7 @@ func TestLoadConfig(t *testing.T) {
 	os.Unsetenv("TODOER_TEMPLATE_FILE")
 
 	// Test loading config with no config file (should succeed with defaults)
-	config, err := loadConfig()
+	config, err := loadConfig(localLookup{})
 	if err != nil {
 		t.Errorf("loadConfig() error = %v, want nil", err)
 	}
@@ -305,7 +537,7 @@ func TestLoadConfigWithXDG(t *testing.T) {
 			}
 
 			// Load config
-			config, err := loadConfig()
+			config, err := loadConfig(localLookup{})
 
 			if tt.expectError {
 				if err == nil {
@@ -498,7 +730,7 @@ func TestProcessJournal_ValidationErrors(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			logger := NewLogger(ModeQuiet)
-			err := processJournal(tt.sourceFile, tt.targetFile, "", tt.templateDate, false, false, config, logger)
+			_, err := processJournal(context.Background(), tt.sourceFile, tt.targetFile, "", tt.templateDate, false, core.DayRange{}, config, logger)
 
 			if tt.expectError {
 				if err == nil {
@@ -544,7 +776,7 @@ Some notes here.
 	config := &Config{RootDir: tempDir}
 
 	logger := NewLogger(ModeQuiet)
-	err := processJournal(sourceFile, targetFile, "", "", false, false, config, logger)
+	_, err := processJournal(context.Background(), sourceFile, targetFile, "", "", false, core.DayRange{}, config, logger)
 	if err != nil {
 		t.Fatalf("processJournal() unexpected error: %v", err)
 	}
@@ -561,611 +793,5825 @@ Some notes here.
 	}
 }
 
-func TestFindClosestJournalFile(t *testing.T) {
+func TestProcessJournal_Idempotent(t *testing.T) {
 	tempDir, cleanup := setupTempDir(t)
 	defer cleanup()
 
-	// Create some test journal files
-	testFiles := []string{
-		"2024/01/2024-01-01.md",
-		"2024/01/2024-01-05.md",
-		"2024/01/2024-01-10.md",
-		"2024/01/other-file.txt", // Should be ignored
+	config := &Config{RootDir: tempDir, FrontmatterDateKey: "title"}
+	logger := NewLogger(ModeQuiet)
+	templateFile := filepath.Join(tempDir, "template.md")
+	createTestFile(t, templateFile, "---\ntitle: {{.Date}}\n---\n\n## Todos\n\n{{.TODOS}}\n")
+	original := "---\ntitle: 2025-06-19\n---\n\n## Todos\n\n- [[2025-06-19]]\n  - [x] Done\n  - [ ] Open\n"
+	sourceFile := filepath.Join(tempDir, "source.md")
+	targetFile := filepath.Join(tempDir, "target.md")
+	createTestFile(t, sourceFile, original)
+
+	process := func() *resultSummary {
+		t.Helper()
+		summary, err := processJournal(context.Background(), sourceFile, targetFile, templateFile, "2025-06-20", false, core.DayRange{}, config, logger)
+		if err != nil {
+			t.Fatalf("processJournal() unexpected error: %v", err)
+		}
+		return summary
+	}
+	read := func(name string) string {
+		t.Helper()
+		content, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", name, err)
+		}
+		return string(content)
 	}
 
-	for _, file := range testFiles {
-		createTestFile(t, filepath.Join(tempDir, file), "test content")
+	process()
+	source, target := read(sourceFile), read(targetFile)
+
+	// Processing the same source again changes nothing and keeps the backup of the original
+	if summary := process(); !summary.AlreadyExists {
+		t.Errorf("second run summary = %+v, want already_exists", summary)
+	}
+	if read(sourceFile) != source || read(targetFile) != target {
+		t.Errorf("second run changed the journals:\n%s\n%s", read(sourceFile), read(targetFile))
+	}
+	if read(sourceFile+".bak") != original {
+		t.Errorf("second run replaced the backup of the original: %q", read(sourceFile+".bak"))
+	}
+	var out bytes.Buffer
+	if err := writeSummary(&out, process(), OutputText, false); err != nil || out.String() != "Already processed into "+targetFile+", nothing to do.\n" {
+		t.Errorf("summary = %q (err: %v)", out.String(), err)
+	}
+
+	// A task added to the source later is merged into the target, keeping its edits
+	createTestFile(t, targetFile, strings.Replace(target, "- [ ] Open", "- [x] Open", 1))
+	createTestFile(t, sourceFile, strings.TrimRight(source, "\n")+"\n  - [ ] Later\n")
+	if summary := process(); summary.AlreadyExists || summary.CarriedTodos != 1 {
+		t.Errorf("merging run summary = %+v", summary)
+	}
+	want := "---\ntitle: 2025-06-20\n---\n\n## Todos\n\n- [[2025-06-19]]\n  - [x] Open created::[[2025-06-19]]\n  - [ ] Later created::[[2025-06-19]]\n"
+	if got := read(targetFile); got != want {
+		t.Errorf("merged target = %q, want %q", got, want)
+	}
+	if strings.Contains(read(sourceFile), "Later") {
+		t.Errorf("merged task left in the source: %q", read(sourceFile))
+	}
+
+	// A target that cannot be parsed is not overwritten
+	broken := "## Todos\n\n- [[2025-06-19]]\n  - [ ] Task\n- [[2024-13-01]]\n"
+	createTestFile(t, targetFile, broken)
+	createTestFile(t, sourceFile, original)
+	_, err := processJournal(context.Background(), sourceFile, targetFile, templateFile, "2025-06-20", false, core.DayRange{}, config, logger)
+	if exitCodeFor(err) != ExitParseError || read(targetFile) != broken {
+		t.Errorf("processJournal() into an unparseable target error = %v, target %q", err, read(targetFile))
 	}
+}
 
+func TestProcessJournal_Format(t *testing.T) {
 	tests := []struct {
-		name        string
-		today       string
-		expectFile  string
-		expectError bool
+		name   string
+		config Config
+		header string // Day header line of 2024-01-01
+		indent string // Indentation of one level
+		nested bool   // Whether items are indented below the day header
 	}{
-		{
-			name:       "find closest before date",
-			today:      "2024-01-07",
-			expectFile: "2024/01/2024-01-05.md",
-		},
-		{
-			name:       "find closest when multiple exist",
-			today:      "2024-01-15",
-			expectFile: "2024/01/2024-01-10.md",
-		},
-		{
-			name:        "no previous journals",
-			today:       "2024-01-01",
-			expectError: true,
-		},
-		{
-			name:        "invalid date format",
-			today:       "invalid-date",
-			expectError: true,
-		},
+		{"four spaces", Config{IndentSpaces: 4}, "- [[2024-01-01]]", "    ", true},
+		{"tabs", Config{UseTabs: true}, "- [[2024-01-01]]", "\t", true},
+		{"heading day headers", Config{DayHeader: "### 2006-01-02"}, "### 2024-01-01", "  ", false},
+		{"aliased day headers", Config{DayHeader: "[[2006-01-02|Mon 2 Jan]]"}, "[[2024-01-01|Mon 1 Jan]]", "  ", false},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := findClosestJournalFile(tempDir, tt.today)
+			tempDir, cleanup := setupTempDir(t)
+			defer cleanup()
 
-			if tt.expectError {
-				if err == nil {
-					t.Errorf("findClosestJournalFile() expected error, got none")
-				}
-			} else {
-				if err != nil {
-					t.Errorf("findClosestJournalFile() unexpected error: %v", err)
-				}
-				expectedPath := filepath.Join(tempDir, tt.expectFile)
-				if result != expectedPath {
-					t.Errorf("findClosestJournalFile() = %v, want %v", result, expectedPath)
+			in := func(depth int) string {
+				if !tt.nested {
+					depth--
 				}
+				return strings.Repeat(tt.indent, depth)
+			}
+			sourceContent := "---\ntitle: 2024-01-01\n---\n\n## Todos\n\n" + tt.header + "\n" +
+				in(1) + "- [ ] Open task\n" +
+				in(2) + "- note\n" +
+				in(2) + "- [ ] Open subtask\n" +
+				in(1) + "- [x] Done task\n"
+			sourceFile := filepath.Join(tempDir, "source.md")
+			targetFile := filepath.Join(tempDir, "target.md")
+			createTestFile(t, sourceFile, sourceContent)
+
+			config := tt.config
+			config.RootDir = tempDir
+			if _, err := processJournal(context.Background(), sourceFile, targetFile, "", "2024-01-02", false, core.DayRange{}, &config, NewLogger(ModeQuiet)); err != nil {
+				t.Fatalf("processJournal() unexpected error: %v", err)
+			}
+
+			target, err := os.ReadFile(targetFile)
+			if err != nil {
+				t.Fatalf("Failed to read target file: %v", err)
+			}
+			wantTarget := tt.header + "\n" + in(1) + "- [ ] Open task created::[[2024-01-01]]\n" + in(2) + "- note\n" + in(2) + "- [ ] Open subtask\n"
+			if !strings.Contains(string(target), wantTarget) {
+				t.Errorf("target file does not contain %q, got:\n%s", wantTarget, target)
+			}
+
+			source, err := os.ReadFile(sourceFile)
+			if err != nil {
+				t.Fatalf("Failed to read source file: %v", err)
+			}
+			if want := tt.header + "\n" + in(1) + "- [x] Done task #2024-01-01"; !strings.Contains(string(source), want) {
+				t.Errorf("source file does not contain %q, got:\n%s", want, source)
 			}
 		})
 	}
 }
 
-func TestCmdNew(t *testing.T) {
+func TestProcessJournal_CheckboxesAndDateTags(t *testing.T) {
 	tempDir, cleanup := setupTempDir(t)
 	defer cleanup()
 
-	config := &Config{RootDir: tempDir}
+	sourceFile := filepath.Join(tempDir, "source.md")
+	targetFile := filepath.Join(tempDir, "target.md")
+	createTestFile(t, sourceFile, "---\ntitle: 2024-01-01\n---\n\n## Todos\n\n- [[2024-01-01]]\n  - [/] Started task\n  - [X] Done task\n  - [x] Old task ✅ 2023-12-31\n")
+	config := &Config{RootDir: tempDir, OpenMarkers: " /", DoneMarkers: "xX", DateTag: "✅ 2006-01-02"}
 
-	// Create a previous journal to use as source
-	prevJournal := filepath.Join(tempDir, "2024/01/2024-01-01.md")
-	createTestFile(t, prevJournal, `---
-date: 2024-01-01
----
+	summary, err := processJournal(context.Background(), sourceFile, targetFile, "", "2024-01-02", false, core.DayRange{}, config, NewLogger(ModeQuiet))
+	if err != nil {
+		t.Fatalf("processJournal() unexpected error: %v", err)
+	}
+	if summary.CarriedTodos != 1 || summary.CompletedTodos != 2 {
+		t.Errorf("carried %d, completed %d; want 1 and 2", summary.CarriedTodos, summary.CompletedTodos)
+	}
 
-# Daily Journal
+	target, _ := os.ReadFile(targetFile)
+	if !strings.Contains(string(target), "- [[2024-01-01]]\n  - [ ] Started task created::[[2024-01-01]]\n") {
+		t.Errorf("target file =\n%s", target)
+	}
+	source, _ := os.ReadFile(sourceFile)
+	if want := "  - [x] Done task ✅ 2024-01-01\n  - [x] Old task ✅ 2023-12-31"; !strings.Contains(string(source), want) {
+		t.Errorf("source file does not contain %q, got:\n%s", want, source)
+	}
 
-## Todos
+	config.DateTag = "2006-01-02"
+	if err := validateConfig(config); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("validateConfig() with unmarked date tag: error = %v, want ErrInvalidConfig", err)
+	}
+}
 
-- [ ] Previous task
-- [x] Completed task
+func TestProcessJournalStdio(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
 
-## Notes
+	source := "---\ntitle: 2024-01-01\n---\n\n## Todos\n\n- [[2024-01-01]]\n  - [ ] Task\n  - [x] Done\n"
+	templateFile := filepath.Join(tempDir, "template.md")
+	createTestFile(t, templateFile, "# {{.Date}}\n\n## Todos\n\n{{.TODOS}}\n")
+	config := &Config{RootDir: tempDir}
+	logger := NewLogger(ModeQuiet)
 
-Previous notes.
-`)
+	t.Run("stdin to stdout", func(t *testing.T) {
+		var out bytes.Buffer
+		summary, err := processJournalStdio(context.Background(), strings.NewReader(source), &out, "-", "-", "", templateFile, "2024-01-02", core.DayRange{}, config, logger)
+		if err != nil {
+			t.Fatalf("processJournalStdio() error = %v", err)
+		}
+		if want := "# 2024-01-02\n\n## Todos\n\n- [[2024-01-01]]\n  - [ ] Task created::[[2024-01-01]]\n"; out.String() != want {
+			t.Errorf("stdout = %q, want %q", out.String(), want)
+		}
+		if summary.Backup != "" || summary.CarriedTodos != 1 || summary.CompletedTodos != 1 {
+			t.Errorf("summary = %+v", summary)
+		}
+	})
 
-	tests := []struct {
-		name        string
-		rootDir     string
-		expectError bool
-	}{
-		{
-			name:        "successful creation",
-			rootDir:     tempDir,
-			expectError: false,
-		},
-		{
-			name:        "invalid root directory",
-			rootDir:     "/nonexistent/path",
-			expectError: true,
-		},
-	}
+	t.Run("modified journal to a file", func(t *testing.T) {
+		var out bytes.Buffer
+		modified := filepath.Join(tempDir, "modified.md")
+		target := filepath.Join(tempDir, "target.md")
+		if _, err := processJournalStdio(context.Background(), strings.NewReader(source), &out, "-", target, modified, templateFile, "2024-01-02", core.DayRange{}, config, logger); err != nil {
+			t.Fatalf("processJournalStdio() error = %v", err)
+		}
+		if out.Len() != 0 {
+			t.Errorf("stdout = %q, want nothing", out.String())
+		}
+		content, err := os.ReadFile(modified)
+		if err != nil || !strings.Contains(string(content), "- [x] Done #2024-01-01") || strings.Contains(string(content), "Task") {
+			t.Errorf("modified journal = %q, %v", content, err)
+		}
+		if _, err := os.Stat(target); err != nil {
+			t.Errorf("target file: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(tempDir, "<stdin>.bak")); !os.IsNotExist(err) {
+			t.Errorf("backup of stdin was written: %v", err)
+		}
+	})
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			logger := NewLogger(ModeQuiet)
-			err := cmdNew(tt.rootDir, "", false, config, logger)
+	t.Run("file to stdout", func(t *testing.T) {
+		var out bytes.Buffer
+		sourceFile := filepath.Join(tempDir, "source.md")
+		createTestFile(t, sourceFile, source)
+		summary, err := processJournalStdio(context.Background(), strings.NewReader(""), &out, sourceFile, "-", "", templateFile, "2024-01-02", core.DayRange{}, config, logger)
+		if err != nil {
+			t.Fatalf("processJournalStdio() error = %v", err)
+		}
+		if !strings.Contains(out.String(), "  - [ ] Task") {
+			t.Errorf("stdout = %q", out.String())
+		}
+		if summary.Backup != sourceFile+".bak" {
+			t.Errorf("Backup = %q, want the source file backed up", summary.Backup)
+		}
+	})
 
-			if tt.expectError {
-				if err == nil {
-					t.Errorf("cmdNew() expected error, got none")
-				}
-			} else {
-				if err != nil {
-					t.Errorf("cmdNew() unexpected error: %v", err)
-				}
+	t.Run("invalid flags", func(t *testing.T) {
+		for _, args := range [][3]string{
+			{filepath.Join(tempDir, "source.md"), "-", filepath.Join(tempDir, "m.md")}, // --modified-out without stdin
+			{"-", "-", "-"}, // Both journals on stdout
+		} {
+			_, err := processJournalStdio(context.Background(), strings.NewReader(source), io.Discard, args[0], args[1], args[2], templateFile, "2024-01-02", core.DayRange{}, config, logger)
+			if exitCodeFor(err) != ExitConfigError {
+				t.Errorf("processJournalStdio(%q) error = %v, want a configuration error", args, err)
 			}
-		})
+		}
+	})
+
+	t.Run("parse error", func(t *testing.T) {
+		var out bytes.Buffer
+		modified := filepath.Join(tempDir, "untouched.md")
+		_, err := processJournalStdio(context.Background(), strings.NewReader("## Todos\n\n- [[2024-01-01]]\n  - [ ] Task\n- [[2024-13-01]]\n"), &out, "-", "-", modified, templateFile, "2024-01-02", core.DayRange{}, config, logger)
+		if err == nil || !strings.HasPrefix(err.Error(), "stdin:5:1: invalid date in day header") {
+			t.Fatalf("processJournalStdio() error = %v, want the line in stdin", err)
+		}
+		if _, statErr := os.Stat(modified); !os.IsNotExist(statErr) {
+			t.Errorf("--modified-out was created despite the error: %v", statErr)
+		}
+	})
+}
+
+func TestProcessJournal_StrictTemplates(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	sourceFile := filepath.Join(tempDir, "source.md")
+	targetFile := filepath.Join(tempDir, "target.md")
+	templateFile := filepath.Join(tempDir, "template.md")
+	source := "---\ntitle: 2024-01-01\n---\n\n## Todos\n\n- [[2024-01-01]]\n  - [ ] Task\n  - [x] Done\n"
+	createTestFile(t, sourceFile, source)
+	createTestFile(t, templateFile, "# {{.Date}} {{.Custom.Projet}}\n\n## Todos\n\n{{.TODOS}}\n")
+	config := &Config{RootDir: tempDir, StrictTemplates: true, Custom: map[string]interface{}{"Project": "todoer"}}
+
+	_, err := processJournal(context.Background(), sourceFile, targetFile, templateFile, "2024-01-02", false, core.DayRange{}, config, NewLogger(ModeQuiet))
+	if err == nil || !strings.Contains(err.Error(), "line 1, column 22: .Custom.Projet (did you mean .Custom.Project?)") {
+		t.Fatalf("processJournal() error = %v, want the unknown field", err)
+	}
+	if exitCodeFor(err) != ExitConfigError {
+		t.Errorf("exit code = %d, want %d", exitCodeFor(err), ExitConfigError)
+	}
+	if _, err := os.Stat(targetFile); !os.IsNotExist(err) {
+		t.Errorf("target file was written: %v", err)
+	}
+	if content, _ := os.ReadFile(sourceFile); string(content) != source {
+		t.Errorf("source file was changed:\n%s", content)
+	}
+
+	var out bytes.Buffer
+	opts := previewOptions{TemplateFile: templateFile, Date: "2024-01-02"}
+	if _, err := renderPreview(opts, config); err == nil {
+		t.Error("renderPreview() accepted the unknown field")
+	}
+	config.StrictTemplates = false
+	if err := cmdPreviewListVars(&out, opts, config); err != nil {
+		t.Errorf("cmdPreviewListVars() without strict templates error = %v", err)
 	}
 }
 
-func TestCmdNew_AlreadyExists(t *testing.T) {
+func TestProcessJournal_SprigFunctions(t *testing.T) {
 	tempDir, cleanup := setupTempDir(t)
 	defer cleanup()
 
-	config := &Config{RootDir: tempDir}
+	sourceFile := filepath.Join(tempDir, "source.md")
+	targetFile := filepath.Join(tempDir, "target.md")
+	templateFile := filepath.Join(tempDir, "template.md")
+	createTestFile(t, sourceFile, "---\ntitle: 2024-01-01\n---\n\n## Todos\n\n- [[2024-01-01]]\n  - [ ] Task\n  - [x] Done\n")
+	createTestFile(t, templateFile, "# {{date \"Jan 2\" .Date}} {{ternary \"weekend\" \"weekday\" (isWeekend .Date)}}\n\n## Todos\n\n{{.TODOS}}\n")
 
-	// Create journal for today
-	today := time.Now().Format("2006-01-02")
-	year := time.Now().Format("2006")
-	month := time.Now().Format("01")
-	expectedPath := filepath.Join(tempDir, year, month, today+".md")
-	createTestFile(t, expectedPath, "existing content")
+	config := &Config{RootDir: tempDir}
+	if _, err := processJournal(context.Background(), sourceFile, targetFile, templateFile, "2024-01-02", false, core.DayRange{}, config, NewLogger(ModeQuiet)); err == nil {
+		t.Fatal("processJournal() accepted Sprig functions without sprig_functions")
+	}
 
-	// Should not error if file already exists
-	logger := NewLogger(ModeQuiet)
-	err := cmdNew(tempDir, "", false, config, logger)
+	config.SprigFunctions = true
+	config.StrictTemplates = true
+	if _, err := processJournal(context.Background(), sourceFile, targetFile, templateFile, "2024-01-02", false, core.DayRange{}, config, NewLogger(ModeQuiet)); err != nil {
+		t.Fatalf("processJournal() error = %v", err)
+	}
+	target, err := os.ReadFile(targetFile)
 	if err != nil {
-		t.Errorf("cmdNew() unexpected error when file exists: %v", err)
+		t.Fatalf("Failed to read target file: %v", err)
+	}
+	if !strings.Contains(string(target), "# Jan 2 weekday") {
+		t.Errorf("target file = %q", target)
+	}
+
+	opts := previewOptions{TemplateFile: templateFile, Date: "2024-01-06"}
+	preview, err := renderPreview(opts, config)
+	if err != nil || !strings.Contains(preview, "# Jan 6 weekend") {
+		t.Errorf("renderPreview() = %q, %v", preview, err)
+	}
+	var out bytes.Buffer
+	if err := cmdPreviewListVars(&out, opts, config); err != nil || !strings.Contains(out.String(), "toJson") {
+		t.Errorf("cmdPreviewListVars() error = %v, output lacks toJson:\n%s", err, out.String())
+	}
+
+	postTemplate := filepath.Join(tempDir, "post.md")
+	createTestFile(t, postTemplate, "{{.Total}} {{plural \"task\" \"tasks\" .Total}}: {{toJson .Tasks}}")
+	message, err := renderPost(postTemplate, postData{Total: 2, Tasks: []string{"a", "b"}}, config)
+	if err != nil || message != `2 tasks: ["a","b"]` {
+		t.Errorf("renderPost() = %q, %v", message, err)
 	}
 }
 
-func TestValidateFilePath(t *testing.T) {
+func TestProcessJournal_NoteField(t *testing.T) {
 	tempDir, cleanup := setupTempDir(t)
 	defer cleanup()
 
-	tests := []struct {
-		name        string
-		path        string
-		expectError bool
-	}{
-		{
-			name:        "empty path",
-			path:        "",
-			expectError: true,
-		},
-		{
-			name:        "directory traversal",
-			path:        "../../../etc/passwd",
-			expectError: true,
-		},
-		{
-			name:        "valid relative path",
-			path:        "test.md",
-			expectError: false,
-		},
-		{
-			name:        "valid absolute path in temp dir",
-			path:        filepath.Join(tempDir, "test.md"),
-			expectError: false,
-		},
-		{
-			name:        "path with non-existent parent",
-			path:        filepath.Join(tempDir, "subdir/test.md"),
-			expectError: false, // Should be valid since parent can potentially be created
-		},
+	sourceFile := filepath.Join(tempDir, "source.md")
+	targetFile := filepath.Join(tempDir, "target.md")
+	templateFile := filepath.Join(tempDir, "template.md")
+	createTestFile(t, filepath.Join(tempDir, "projects", "ProjectX.md"), "---\nstatus: active\n---\n\n# Project X\n")
+	createTestFile(t, sourceFile, "---\ntitle: 2024-01-01\n---\n\n## Todos\n\n- [[2024-01-01]]\n  - [ ] Task\n")
+	createTestFile(t, templateFile, "Project X: {{noteField \"projects/ProjectX.md\" \"status\"}}\n\n## Todos\n\n{{.TODOS}}\n")
+
+	config := &Config{RootDir: tempDir, StrictTemplates: true}
+	if _, err := processJournal(context.Background(), sourceFile, targetFile, templateFile, "2024-01-02", false, core.DayRange{}, config, NewLogger(ModeQuiet)); err != nil {
+		t.Fatalf("processJournal() error = %v", err)
+	}
+	target, err := os.ReadFile(targetFile)
+	if err != nil {
+		t.Fatalf("Failed to read target file: %v", err)
+	}
+	if !strings.HasPrefix(string(target), "Project X: active\n") {
+		t.Errorf("target file = %q, want the status of Project X", target)
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			err := validateFilePath(tt.path)
-			if (err != nil) != tt.expectError {
-				t.Errorf("validateFilePath() error = %v, expectError %v", err, tt.expectError)
-			}
-		})
+	preview, err := renderPreview(previewOptions{TemplateFile: templateFile, Date: "2024-01-02"}, config)
+	if err != nil || !strings.HasPrefix(preview, "Project X: active\n") {
+		t.Errorf("renderPreview() = %q, %v", preview, err)
+	}
+
+	createTestFile(t, templateFile, "{{noteField \"../outside.md\" \"status\"}}\n\n## Todos\n\n{{.TODOS}}\n")
+	if _, err := processJournal(context.Background(), sourceFile, targetFile, templateFile, "2024-01-02", false, core.DayRange{}, config, NewLogger(ModeQuiet)); err == nil {
+		t.Error("processJournal() read a note outside the journal root")
 	}
 }
 
-func TestValidateDateFormat(t *testing.T) {
-	tests := []struct {
-		name        string
-		date        string
-		expectError bool
-	}{
-		{
-			name:        "empty date",
-			date:        "",
-			expectError: false,
-		},
-		{
-			name:        "valid date",
-			date:        "2024-01-15",
-			expectError: false,
-		},
-		{
-			name:        "invalid format",
-			date:        "01/15/2024",
-			expectError: true,
-		},
-		{
-			name:        "invalid date",
-			date:        "2024-13-32",
-			expectError: true,
-		},
-		{
-			name:        "incomplete date",
-			date:        "2024-01",
-			expectError: true,
-		},
+func TestProcessJournal_SecretVariables(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	sourceFile := filepath.Join(tempDir, "source.md")
+	targetFile := filepath.Join(tempDir, "target.md")
+	templateFile := filepath.Join(tempDir, "template.md")
+	createTestFile(t, sourceFile, "---\ntitle: 2024-01-01\n---\n\n## Todos\n\n- [[2024-01-01]]\n  - [ ] Task\n")
+	createTestFile(t, templateFile, "Key: {{.Custom.Token}}\n\n## Todos\n\n{{.TODOS}}\n")
+
+	config := &Config{RootDir: tempDir, Custom: map[string]interface{}{"Token": "s3cr3t"}, SecretVars: []string{"Token"}}
+	_, err := processJournal(context.Background(), sourceFile, targetFile, templateFile, "2024-01-02", false, core.DayRange{}, config, NewLogger(ModeQuiet))
+	if !errors.Is(err, core.ErrSecretVariable) || !strings.Contains(err.Error(), "--allow-secrets") {
+		t.Fatalf("processJournal() error = %v, want ErrSecretVariable with a hint", err)
+	}
+	if _, err := os.Stat(targetFile); !os.IsNotExist(err) {
+		t.Errorf("target written although the template uses a secret (stat error: %v)", err)
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			err := validateDateFormat(tt.date)
-			if (err != nil) != tt.expectError {
-				t.Errorf("validateDateFormat() error = %v, expectError %v", err, tt.expectError)
-			}
-		})
+	preview, err := renderPreview(previewOptions{TemplateFile: templateFile, Date: "2024-01-02"}, config)
+	if err != nil || !strings.HasPrefix(preview, "Key: "+core.SecretMask+"\n") {
+		t.Errorf("renderPreview() = %q, %v, want the secret masked", preview, err)
+	}
+	if _, err := renderDigest("{{.Custom.Token}}", digestData{Custom: config.Custom}, config); !errors.Is(err, core.ErrSecretVariable) {
+		t.Errorf("renderDigest() error = %v, want ErrSecretVariable", err)
+	}
+
+	CLI.AllowSecrets = true
+	t.Cleanup(func() { CLI.AllowSecrets = false })
+	if _, err := processJournal(context.Background(), sourceFile, targetFile, templateFile, "2024-01-02", false, core.DayRange{}, config, NewLogger(ModeQuiet)); err != nil {
+		t.Fatalf("processJournal() with --allow-secrets error = %v", err)
+	}
+	if target, _ := os.ReadFile(targetFile); !strings.HasPrefix(string(target), "Key: s3cr3t\n") {
+		t.Errorf("target file = %q, want the secret rendered", target)
 	}
 }
 
-func TestValidateProcessArgs(t *testing.T) {
+func TestProcessJournal_CollapseCarried(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	sourceFile := filepath.Join(tempDir, "source.md")
+	targetFile := filepath.Join(tempDir, "target.md")
+	createTestFile(t, sourceFile, "---\ntitle: 2024-01-01\n---\n\n## Todos\n\n"+
+		"- [[2023-12-20]]\n  - [ ] Old task\n- [[2024-01-01]]\n  - [ ] New task\n")
+
+	config := &Config{RootDir: tempDir, CollapseCarried: true, AnnotateCarried: true}
+	if _, err := processJournal(context.Background(), sourceFile, targetFile, "", "2024-01-02", false, core.DayRange{}, config, NewLogger(ModeQuiet)); err != nil {
+		t.Fatalf("processJournal() unexpected error: %v", err)
+	}
+
+	target, err := os.ReadFile(targetFile)
+	if err != nil {
+		t.Fatalf("Failed to read target file: %v", err)
+	}
+	want := "- [[2024-01-02]]\n  - [ ] Old task created::[[2023-12-20]] (from [[2023-12-20]])\n  - [ ] New task created::[[2024-01-01]] (from [[2024-01-01]])\n"
+	if !strings.Contains(string(target), want) {
+		t.Errorf("target file does not contain %q, got:\n%s", want, target)
+	}
+}
+
+func TestProcessJournal_MaxCarry(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	backlogFile := filepath.Join(tempDir, "backlog.md")
+	createTestFile(t, backlogFile, "# Someday\n\nKeep this.\n\n## Todos\n\n- [[2023-11-01]]\n  - [ ] Learn Rust\n")
+
+	sourceFile := filepath.Join(tempDir, "source.md")
+	targetFile := filepath.Join(tempDir, "target.md")
+	createTestFile(t, sourceFile, "---\ntitle: 2024-01-01\n---\n\n## Todos\n\n"+
+		"- [[2023-11-01]]\n  - [ ] Learn Rust\n  - [ ] Paint fence\n- [[2024-01-01]]\n  - [ ] Call mom\n")
+
+	config := &Config{RootDir: tempDir, MaxCarry: 1, BacklogFile: "backlog.md"}
+	summary, err := processJournal(context.Background(), sourceFile, targetFile, "", "2024-01-02", false, core.DayRange{}, config, NewLogger(ModeQuiet))
+	if err != nil {
+		t.Fatalf("processJournal() unexpected error: %v", err)
+	}
+	if summary.BacklogTodos != 2 || summary.CarriedTodos != 1 || summary.Backlog != backlogFile {
+		t.Errorf("summary = %+v, want 2 todos in %s and 1 carried", summary, backlogFile)
+	}
+
+	target, err := os.ReadFile(targetFile)
+	if err != nil {
+		t.Fatalf("Failed to read target file: %v", err)
+	}
+	if !strings.Contains(string(target), "Call mom") || strings.Contains(string(target), "Paint fence") {
+		t.Errorf("target file should only carry the newest todo, got:\n%s", target)
+	}
+
+	backlog, err := os.ReadFile(backlogFile)
+	if err != nil {
+		t.Fatalf("Failed to read backlog: %v", err)
+	}
+	want := "# Someday\n\nKeep this.\n\n## Todos\n\n- [[2023-11-01]]\n  - [ ] Learn Rust\n  - [ ] Paint fence\n"
+	if string(backlog) != want {
+		t.Errorf("backlog = %q, want %q", backlog, want)
+	}
+
+	t.Run("new backlog", func(t *testing.T) {
+		config := &Config{RootDir: tempDir, MaxCarry: 1, BacklogFile: "later.md"}
+		if _, err := processJournal(context.Background(), sourceFile+".bak", targetFile, "", "2024-01-02", true, core.DayRange{}, config, NewLogger(ModeQuiet)); err != nil {
+			t.Fatalf("processJournal() unexpected error: %v", err)
+		}
+		backlog, err := os.ReadFile(filepath.Join(tempDir, "later.md"))
+		if err != nil {
+			t.Fatalf("Failed to read backlog: %v", err)
+		}
+		want := "# Backlog\n\n## Todos\n\n- [[2023-11-01]]\n  - [ ] Learn Rust\n  - [ ] Paint fence\n"
+		if string(backlog) != want {
+			t.Errorf("backlog = %q, want %q", backlog, want)
+		}
+	})
+}
+
+// faultStorage wraps a Storage and fails every write of the file failName with
+// err, as a full disk would.
+type faultStorage struct {
+	storage.Storage
+	failName string
+	err      error
+}
+
+func (f *faultStorage) Write(name string, r io.Reader) error {
+	if name == f.failName {
+		return f.err
+	}
+	return f.Storage.Write(name, r)
+}
+
+func TestProcessJournalIn_WriteFault(t *testing.T) {
+	const source = "---\ntitle: 2024-01-01\n---\n\n## Todos\n\n- [[2024-01-01]]\n  - [ ] Open task\n  - [x] Done task\n"
+
+	t.Run("separate files", func(t *testing.T) {
+		tempDir, cleanup := setupTempDir(t)
+		defer cleanup()
+		createTestFile(t, filepath.Join(tempDir, "source.md"), source)
+
+		store := &faultStorage{Storage: storage.NewLocal(tempDir), failName: "source.md", err: syscall.ENOSPC}
+		config := &Config{RootDir: tempDir}
+		err := processJournalIn(context.Background(), store, nil, "source.md", "target.md", "", "2024-01-02", false, core.DayRange{}, newResultSummary("process"), config, NewLogger(ModeQuiet))
+		if err == nil || !strings.Contains(err.Error(), syscall.ENOSPC.Error()) {
+			t.Fatalf("processJournalIn() error = %v, want ENOSPC", err)
+		}
+		if code := exitCodeFor(err); code != ExitWriteError {
+			t.Errorf("exit code = %d, want %d", code, ExitWriteError)
+		}
+
+		entries, _ := os.ReadDir(tempDir)
+		if len(entries) != 1 || entries[0].Name() != "source.md" {
+			t.Errorf("files after failed run = %v, want only source.md", entries)
+		}
+		if got, _ := os.ReadFile(filepath.Join(tempDir, "source.md")); string(got) != source {
+			t.Errorf("source changed by failed run:\n%s", got)
+		}
+	})
+
+	t.Run("single file", func(t *testing.T) {
+		tempDir, cleanup := setupTempDir(t)
+		defer cleanup()
+		original := "<!-- todoer:day 2024-01-01 -->\n## Todos\n\n- [[2024-01-01]]\n  - [ ] Open task\n"
+		createTestFile(t, filepath.Join(tempDir, "journal.md"), original)
+
+		// The backup is written between the new day and the processed previous day
+		store := &faultStorage{Storage: storage.NewLocal(tempDir), failName: "journal.md.bak", err: syscall.ENOSPC}
+		config := &Config{RootDir: tempDir}
+		s, err := openSingleFile(store, "journal.md", config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := appendDay(context.Background(), s, nil, "", "2024-01-02", core.DayRange{}, newResultSummary("new"), config, NewLogger(ModeQuiet)); err == nil {
+			t.Fatal("appendDay() expected error")
+		}
+		if got, _ := os.ReadFile(filepath.Join(tempDir, "journal.md")); string(got) != original {
+			t.Errorf("journal changed by failed run:\n%s", got)
+		}
+	})
+}
+
+func TestProcessJournal_DayRange(t *testing.T) {
 	tempDir, cleanup := setupTempDir(t)
 	defer cleanup()
 
 	sourceFile := filepath.Join(tempDir, "source.md")
 	targetFile := filepath.Join(tempDir, "target.md")
+	createTestFile(t, sourceFile, "---\ntitle: 2024-01-01\n---\n\n## Todos\n\n"+
+		"- [[2023-06-01]]\n  - [ ] Ancient task\n  - [x] Ancient done\n- [[2024-01-01]]\n  - [ ] New task\n  - [x] New done\n")
+
+	days, err := processDays(7, "", "", "2024-01-02")
+	if err != nil {
+		t.Fatalf("processDays() unexpected error: %v", err)
+	}
+	config := &Config{RootDir: tempDir}
+	summary, err := processJournal(context.Background(), sourceFile, targetFile, "", "2024-01-02", false, days, config, NewLogger(ModeQuiet))
+	if err != nil {
+		t.Fatalf("processJournal() unexpected error: %v", err)
+	}
+	if summary.CarriedTodos != 1 || summary.CompletedTodos != 1 {
+		t.Errorf("summary = %+v, want only the todos of the range counted", summary)
+	}
+
+	source, err := os.ReadFile(sourceFile)
+	if err != nil {
+		t.Fatalf("Failed to read source file: %v", err)
+	}
+	want := "- [[2023-06-01]]\n  - [ ] Ancient task\n  - [x] Ancient done\n- [[2024-01-01]]\n  - [x] New done #2024-01-01"
+	if !strings.Contains(string(source), want) {
+		t.Errorf("source file does not contain %q, got:\n%s", want, source)
+	}
+	target, err := os.ReadFile(targetFile)
+	if err != nil {
+		t.Fatalf("Failed to read target file: %v", err)
+	}
+	if strings.Contains(string(target), "Ancient") || !strings.Contains(string(target), "New task") {
+		t.Errorf("target file should only carry the todos of the range, got:\n%s", target)
+	}
+}
 
+func TestProcessDays(t *testing.T) {
 	tests := []struct {
-		name         string
-		sourceFile   string
-		targetFile   string
-		templateDate string
-		expectError  bool
+		name     string
+		days     int
+		from, to string
+		want     core.DayRange
+		wantErr  bool
 	}{
-		{
-			name:        "valid arguments",
-			sourceFile:  sourceFile,
-			targetFile:  targetFile,
-			expectError: false,
-		},
-		{
-			name:         "valid with template date",
-			sourceFile:   sourceFile,
-			targetFile:   targetFile,
-			templateDate: "2024-01-15",
-			expectError:  false,
-		},
-		{
-			name:        "same source and target",
-			sourceFile:  sourceFile,
-			targetFile:  sourceFile,
-			expectError: true,
-		},
-		{
-			name:         "invalid template date",
-			sourceFile:   sourceFile,
-			targetFile:   targetFile,
-			templateDate: "invalid",
-			expectError:  true,
-		},
-		{
-			name:        "empty source",
-			sourceFile:  "",
-			targetFile:  targetFile,
-			expectError: true,
-		},
+		{name: "everything"},
+		{name: "days", days: 7, want: core.DayRange{From: "2024-01-03"}},
+		{name: "from and to", from: "2023-12-01", to: "2023-12-31", want: core.DayRange{From: "2023-12-01", To: "2023-12-31"}},
+		{name: "days with from", days: 7, from: "2023-12-01", wantErr: true},
+		{name: "negative days", days: -1, wantErr: true},
+		{name: "reversed", from: "2023-12-31", to: "2023-12-01", wantErr: true},
+		{name: "invalid date", to: "2023-12-32", wantErr: true},
 	}
-
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := validateProcessArgs(tt.sourceFile, tt.targetFile, tt.templateDate)
-			if (err != nil) != tt.expectError {
-				t.Errorf("validateProcessArgs() error = %v, expectError %v", err, tt.expectError)
+			got, err := processDays(tt.days, tt.from, tt.to, "2024-01-10")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("processDays() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("processDays() = %+v, want %+v", got, tt.want)
 			}
 		})
 	}
 }
 
-func TestValidateConfig(t *testing.T) {
+func TestProcessSingleFile(t *testing.T) {
 	tempDir, cleanup := setupTempDir(t)
 	defer cleanup()
 
-	// Create a valid template file for testing
-	validTemplateFile := filepath.Join(tempDir, "valid_template.md")
-	createTestFile(t, validTemplateFile, "# Test Template\n## Todos\n{{.TODOS}}")
+	journalFile := filepath.Join(tempDir, "journal.md")
+	original := "# Journal\n\n<!-- todoer:day 2024-01-01 -->\n## Todos\n\n- [[2024-01-01]]\n  - [ ] Open task\n  - [x] Done task\n\n## Notes\n\nFirst day.\n"
+	createTestFile(t, journalFile, original)
 
-	tests := []struct {
-		name        string
-		config      *Config
-		expectError bool
-		errorType   error
-	}{
-		{
-			name:        "nil config",
-			config:      nil,
-			expectError: true,
-			errorType:   ErrInvalidConfig,
-		},
-		{
-			name: "valid config",
-			config: &Config{
-				RootDir: tempDir,
-			},
-			expectError: false,
-		},
-		{
-			name: "empty root dir",
-			config: &Config{
-				RootDir: "",
-			},
-			expectError: true,
-			errorType:   ErrInvalidConfig,
-		},
-		{
-			name: "valid config with template",
-			config: &Config{
-				RootDir:      tempDir,
-				TemplateFile: validTemplateFile,
-			},
-			expectError: false,
-		},
-		{
-			name: "config with invalid root dir path",
-			config: &Config{
-				RootDir: "../../../etc",
-			},
-			expectError: true,
-			errorType:   ErrInvalidPath,
-		},
-		{
-			name: "config with nonexistent template file",
-			config: &Config{
-				RootDir:      tempDir,
-				TemplateFile: filepath.Join(tempDir, "nonexistent.md"),
-			},
-			expectError: true,
-			errorType:   ErrTemplateNotFound,
-		},
-		{
-			name: "config with directory as template file",
-			config: &Config{
-				RootDir:      tempDir,
-				TemplateFile: tempDir, // Directory instead of file
-			},
-			expectError: true,
-			errorType:   ErrInvalidConfig,
-		},
+	templateFile := filepath.Join(tempDir, "template.md")
+	createTestFile(t, templateFile, "---\ntitle: {{.Date}}\n---\n\n## Todos\n\n{{.TODOS}}\n")
+
+	config := &Config{RootDir: tempDir}
+	summary, err := processSingleFile(context.Background(), journalFile, templateFile, "2024-01-02", core.DayRange{}, config, NewLogger(ModeQuiet))
+	if err != nil {
+		t.Fatalf("processSingleFile() unexpected error: %v", err)
+	}
+	if summary.CarriedTodos != 1 || summary.CompletedTodos != 1 {
+		t.Errorf("summary = %+v, want 1 carried and 1 completed todo", summary)
+	}
+
+	content, err := os.ReadFile(journalFile)
+	if err != nil {
+		t.Fatalf("Failed to read journal file: %v", err)
+	}
+	got := string(content)
+	if !strings.HasPrefix(got, "# Journal\n\n<!-- todoer:day 2024-01-01 -->\n## Todos\n\n- [[2024-01-01]]\n  - [x] Done task #2024-01-01\n") {
+		t.Errorf("previous day was not processed in place, got:\n%s", got)
+	}
+	if !strings.Contains(got, "First day.\n\n<!-- todoer:day 2024-01-02 -->\n## Todos\n\n- [[2024-01-01]]\n  - [ ] Open task created::[[2024-01-01]]\n") {
+		t.Errorf("new day section was not appended, got:\n%s", got)
+	}
+	if strings.Count(got, "---") != 0 {
+		t.Errorf("template frontmatter should not be appended, got:\n%s", got)
+	}
+	if files, _ := filepath.Glob(filepath.Join(tempDir, "*.md")); len(files) != 2 {
+		t.Errorf("only the journal and template should exist, got %v", files)
+	}
+	if backup, err := os.ReadFile(journalFile + ".bak"); err != nil || string(backup) != original {
+		t.Errorf("backup = %q (%v), want the original journal", backup, err)
+	}
+
+	summary, err = processSingleFile(context.Background(), journalFile, templateFile, "2024-01-02", core.DayRange{}, config, NewLogger(ModeQuiet))
+	if err != nil || !summary.AlreadyExists {
+		t.Errorf("processing the same day again: summary = %+v, err = %v, want AlreadyExists", summary, err)
+	}
+	if again, _ := os.ReadFile(journalFile); string(again) != got {
+		t.Errorf("processing the same day again changed the journal:\n%s", again)
+	}
+}
+
+func TestPlanJournal(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	previous := buildJournalPath(tempDir, "2024-01-01")
+	original := "---\ntitle: 2024-01-01\n---\n\n## Todos\n\n- [[2024-01-01]]\n  - [ ] Open task\n  - [ ] Another task\n  - [x] Done task\n"
+	createTestFile(t, previous, original)
+	config := &Config{RootDir: tempDir, MaxCarry: 1, BacklogFile: "backlog.md"}
+
+	plan, err := planJournal(context.Background(), tempDir, "", "2024-01-02", config, NewLogger(ModeQuiet))
+	if err != nil {
+		t.Fatalf("planJournal() unexpected error: %v", err)
+	}
+	want := &newPlan{
+		Date:           "2024-01-02",
+		Source:         previous,
+		Target:         buildJournalPath(tempDir, "2024-01-02"),
+		Template:       plan.Template,
+		CarriedTodos:   1,
+		CompletedTodos: 1,
+		Backlog:        filepath.Join(tempDir, "backlog.md"),
+		BacklogTodos:   1,
+		Warnings:       []string{},
+	}
+	if !reflect.DeepEqual(plan, want) {
+		t.Errorf("planJournal() = %+v, want %+v", plan, want)
+	}
+	if plan.Template == "" {
+		t.Error("planJournal() did not name the template")
+	}
+
+	// Nothing is written
+	if content, _ := os.ReadFile(previous); string(content) != original {
+		t.Errorf("planJournal() changed the previous journal to %q", content)
+	}
+	for _, name := range []string{plan.Target, plan.Backlog} {
+		if _, err := os.Stat(name); err == nil {
+			t.Errorf("planJournal() created %s", name)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := writePlan(&out, plan); err != nil {
+		t.Fatalf("writePlan() error: %v", err)
+	}
+	if !strings.Contains(out.String(), `"carried_todos": 1`) || !strings.Contains(out.String(), `"source": `) {
+		t.Errorf("writePlan() = %s", out.String())
+	}
+
+	createTestFile(t, plan.Target, "## Todos\n")
+	if plan, err = planJournal(context.Background(), tempDir, "", "2024-01-02", config, NewLogger(ModeQuiet)); err != nil || !plan.AlreadyExists {
+		t.Errorf("planJournal() of an existing journal = %+v, %v, want already_exists", plan, err)
+	}
+	if plan, err = planJournal(context.Background(), tempDir, "", "2023-12-31", config, NewLogger(ModeQuiet)); err != nil || plan.Source != "" || len(plan.Warnings) != 1 {
+		t.Errorf("planJournal() without a previous journal = %+v, %v, want no source and a warning", plan, err)
+	}
+}
+
+func TestCreateJournal_SingleFile(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir, JournalFile: "journal.md"}
+	if _, err := createJournal(context.Background(), tempDir, "", "2024-01-01", config, NewLogger(ModeQuiet)); err != nil {
+		t.Fatalf("createJournal() unexpected error: %v", err)
+	}
+	if _, err := createJournal(context.Background(), tempDir, "", "2024-01-02", config, NewLogger(ModeQuiet)); err != nil {
+		t.Fatalf("createJournal() unexpected error: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "journal.md"))
+	if err != nil {
+		t.Fatalf("Failed to read journal file: %v", err)
+	}
+	sections := daySections(content)
+	if len(sections) != 2 || sections[0].date != "2024-01-01" || sections[1].date != "2024-01-02" {
+		t.Errorf("journal has sections %+v, want 2024-01-01 and 2024-01-02", sections)
+	}
+	if _, err := os.Stat(buildJournalPath(tempDir, "2024-01-02")); err == nil {
+		t.Error("new should not create a per-day file with journal_file set")
+	}
+
+	config.JournalFile = "journal.md.age"
+	if _, err := createJournal(context.Background(), tempDir, "", "2024-01-03", config, NewLogger(ModeQuiet)); err == nil {
+		t.Error("createJournal() expected error for an encoded journal file")
+	}
+}
+
+func TestCreateJournal_MovedMessage(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   string // Todos section left in the previous journal
+	}{
+		{"default", Config{}, "## Todos\n\nMoved to [[2024-01-02]]"},
+		{"template", Config{MovedMessage: "→ continued in [[{{.Name}}|{{.Date}}]] ({{.Path}})"}, "## Todos\n\n→ continued in [[2024-01-02|2024-01-02]] (2024/01/2024-01-02.md)"},
+		{"omitted", Config{OmitMovedMessage: true}, "## Todos\n\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir, cleanup := setupTempDir(t)
+			defer cleanup()
+
+			previous := buildJournalPath(tempDir, "2024-01-01")
+			createTestFile(t, previous, "---\ntitle: 2024-01-01\n---\n\n## Todos\n\n- [[2024-01-01]]\n  - [ ] Open task\n")
+			config := tt.config
+			config.RootDir = tempDir
+			if _, err := createJournal(context.Background(), tempDir, "", "2024-01-02", &config, NewLogger(ModeQuiet)); err != nil {
+				t.Fatalf("createJournal() unexpected error: %v", err)
+			}
+			content, _ := os.ReadFile(previous)
+			if got := strings.TrimPrefix(string(content), "---\ntitle: 2024-01-01\n---\n\n"); got != tt.want {
+				t.Errorf("previous journal = %q, want %q", got, tt.want)
+			}
+			if _, warnings, err := journalParser(&config).Parse(context.Background(), strings.TrimPrefix(tt.want, "## Todos\n\n")); err != nil || len(warnings) != 0 {
+				t.Errorf("Parse() of the moved message = %v, %v; want no warnings", warnings, err)
+			}
+		})
+	}
+
+	for _, message := range []string{"Moved to {{.Date", "Moved to {{.Target}}"} {
+		if err := validateConfig(&Config{RootDir: t.TempDir(), MovedMessage: message}); err == nil || !strings.Contains(err.Error(), "moved_message") {
+			t.Errorf("validateConfig(%q) error = %v, want an invalid moved_message", message, err)
+		}
+	}
+}
+
+func TestCreateJournal_Logseq(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	previous := filepath.Join(tempDir, "journals", "2024_01_01.md")
+	createTestFile(t, previous, "---\ndate: 2024-01-01\n---\n\n## Todos\n\n- [[2024-01-01]]\n\t- LATER Open task\n\t\t- NOW Open subtask\n\t- DONE Done task\n")
+	templateFile := filepath.Join(tempDir, "template.md")
+	createTestFile(t, templateFile, "---\ndate: {{.Date}}\n---\n\n## Todos\n\n{{.TODOS}}\n")
+	config := &Config{RootDir: tempDir, FrontmatterDateKey: "date", Dialect: DialectLogseq}
+
+	summary, err := createJournal(context.Background(), tempDir, templateFile, "2024-01-02", config, NewLogger(ModeQuiet))
+	if err != nil {
+		t.Fatalf("createJournal() unexpected error: %v", err)
+	}
+	if summary.Source != previous || summary.CarriedTodos != 2 {
+		t.Errorf("summary = %+v, want 2 todos carried from %s", summary, previous)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "journals", "2024_01_02.md"))
+	if err != nil {
+		t.Fatalf("Failed to read new journal: %v", err)
+	}
+	if want := "- [[2024-01-01]]\n\t- TODO Open task created::[[2024-01-01]]\n\t\t- TODO Open subtask\n"; !strings.Contains(string(content), want) {
+		t.Errorf("new journal does not contain %q, got:\n%s", want, content)
+	}
+	source, _ := os.ReadFile(previous)
+	if want := "\t- DONE Done task #2024-01-01"; !strings.Contains(string(source), want) {
+		t.Errorf("previous journal does not contain %q, got:\n%s", want, source)
+	}
+
+	if date, ok := journalDateFromPath("journals/2024_01_02.md"); !ok || date != "2024-01-02" {
+		t.Errorf("journalDateFromPath() = %q, %v", date, ok)
+	}
+	if err := validateConfig(&Config{RootDir: tempDir, Dialect: "orgmode"}); err == nil || !strings.Contains(err.Error(), "dialect") {
+		t.Errorf("validateConfig() error = %v, want an invalid dialect", err)
+	}
+}
+
+func TestCreateJournal_GFM(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	previous := filepath.Join(tempDir, "2024", "01", "2024-01-01.md")
+	createTestFile(t, previous, "---\ntitle: 2024-01-01\n---\n\n## Todos\n\n- [[2024-01-01]]\n  * [ ] Open task\n    + [ X ] Done subtask\n  * [X] Done task\n")
+	templateFile := filepath.Join(tempDir, "template.md")
+	createTestFile(t, templateFile, "---\ntitle: {{.Date}}\n---\n\n## Todos\n\n{{.TODOS}}\n")
+	config := &Config{RootDir: tempDir, Dialect: DialectGFM}
+
+	summary, err := createJournal(context.Background(), tempDir, templateFile, "2024-01-02", config, NewLogger(ModeQuiet))
+	if err != nil {
+		t.Fatalf("createJournal() unexpected error: %v", err)
+	}
+	if summary.CarriedTodos != 2 || summary.CompletedTodos != 1 {
+		t.Errorf("summary = %+v, want 2 todos carried and 1 completed", summary)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "2024", "01", "2024-01-02.md"))
+	if err != nil {
+		t.Fatalf("Failed to read new journal: %v", err)
+	}
+	if want := "- [[2024-01-01]]\n  - [ ] Open task created::[[2024-01-01]]\n    - [x] Done subtask #2024-01-01\n"; !strings.Contains(string(content), want) {
+		t.Errorf("new journal does not contain %q, got:\n%s", want, content)
+	}
+	source, _ := os.ReadFile(previous)
+	if want := "  - [x] Done task #2024-01-01"; !strings.Contains(string(source), want) {
+		t.Errorf("previous journal does not contain %q, got:\n%s", want, source)
+	}
+}
+
+func TestCreateJournal_Limits(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	createTestFile(t, filepath.Join(tempDir, "2024", "01", "2024-01-01.md"), "---\ntitle: 2024-01-01\n---\n\n## Todos\n\n- [[2024-01-01]]\n  - [ ] Task\n    - [ ] Subtask\n")
+	templateFile := filepath.Join(tempDir, "template.md")
+	createTestFile(t, templateFile, "---\ntitle: {{.Date}}\n---\n\n## Todos\n\n{{.TODOS}}\n")
+	config := &Config{RootDir: tempDir, Limits: LimitsConfig{MaxDepth: 1}}
+
+	_, err := createJournal(context.Background(), tempDir, templateFile, "2024-01-02", config, NewLogger(ModeQuiet))
+	if !errors.Is(err, core.KindLimit) || !strings.Contains(err.Error(), "2024-01-01.md:9:") {
+		t.Fatalf("createJournal() error = %v, want a limit error on line 9", err)
+	}
+	if exitCodeFor(err) != ExitParseError {
+		t.Errorf("exit code = %d, want %d", exitCodeFor(err), ExitParseError)
+	}
+}
+
+func TestFindClosestJournalFile(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	// Create some test journal files
+	testFiles := []string{
+		"2024/01/2024-01-01.md",
+		"2024/01/2024-01-05.md",
+		"2024/01/2024-01-10.md",
+		"2024/01/other-file.txt", // Should be ignored
+	}
+
+	for _, file := range testFiles {
+		createTestFile(t, filepath.Join(tempDir, file), "test content")
+	}
+
+	tests := []struct {
+		name        string
+		today       string
+		expectFile  string
+		expectError bool
+	}{
 		{
-			name: "config with valid custom variables",
-			config: &Config{
-				RootDir: tempDir,
-				Custom: map[string]interface{}{
-					"author":  "John Doe",
-					"project": "Test Project",
-					"version": 1,
-					"active":  true,
-				},
-			},
-			expectError: false,
+			name:       "find closest before date",
+			today:      "2024-01-07",
+			expectFile: "2024/01/2024-01-05.md",
 		},
 		{
-			name: "config with reserved custom variable name",
-			config: &Config{
-				RootDir: tempDir,
-				Custom: map[string]interface{}{
-					"Date": "invalid", // Reserved name
-				},
-			},
-			expectError: true,
-			errorType:   ErrInvalidConfig,
+			name:       "find closest when multiple exist",
+			today:      "2024-01-15",
+			expectFile: "2024/01/2024-01-10.md",
 		},
 		{
-			name: "config with invalid custom variable name",
-			config: &Config{
-				RootDir: tempDir,
-				Custom: map[string]interface{}{
-					"123invalid": "value", // Invalid name starting with number
-				},
-			},
+			name:        "no previous journals",
+			today:       "2024-01-01",
 			expectError: true,
-			errorType:   ErrInvalidConfig,
 		},
 		{
-			name: "config with unsupported custom variable type",
-			config: &Config{
-				RootDir: tempDir,
-				Custom: map[string]interface{}{
-					"complex": complex(1, 2), // Unsupported type
-				},
-			},
+			name:        "invalid date format",
+			today:       "invalid-date",
 			expectError: true,
-			errorType:   ErrInvalidConfig,
 		},
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			err := validateConfig(tt.config)
-			if (err != nil) != tt.expectError {
-				t.Errorf("validateConfig() error = %v, expectError %v", err, tt.expectError)
-				return
-			}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := findClosestJournalFile(storage.NewLocal(tempDir), tt.today)
+
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("findClosestJournalFile() expected error, got none")
+				}
+			} else {
+				if err != nil {
+					t.Errorf("findClosestJournalFile() unexpected error: %v", err)
+				}
+				if result != tt.expectFile {
+					t.Errorf("findClosestJournalFile() = %v, want %v", result, tt.expectFile)
+				}
+			}
+		})
+	}
+}
+
+func TestSelectPreviousJournal(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	createTestFile(t, buildJournalPath(tempDir, "2024-01-02"), "## Todos\n\n- [[2024-01-02]]\n  - [ ] Open task\n")
+	createTestFile(t, buildJournalPath(tempDir, "2024-01-05"), "## Todos\n\n- [[2024-01-05]]\n  - [x] Done task\n")
+	createTestFile(t, buildJournalPath(tempDir, "2024-01-06"), "## Todos\n\n- [[2024-01-06]]\n  - [x] Weekend task\n")
+
+	tests := []struct {
+		strategy string
+		days     int
+		today    string
+		want     string // Date of the journal selected, empty for none
+		wantErr  error
+	}{
+		{strategy: "", today: "2024-01-08", want: "2024-01-06"},
+		{strategy: PreviousClosest, today: "2024-01-31", want: "2024-01-06"},
+		{strategy: PreviousWorkday, today: "2024-01-08", want: "2024-01-06"},
+		{strategy: PreviousWorkday, today: "2024-01-10"},
+		{strategy: PreviousYesterday, today: "2024-01-07", want: "2024-01-06"},
+		{strategy: PreviousYesterday, today: "2024-01-08", wantErr: ErrNoPreviousJournal},
+		{strategy: PreviousWithin, days: 2, today: "2024-01-08", want: "2024-01-06"},
+		{strategy: PreviousWithin, days: 1, today: "2024-01-08"},
+		{strategy: PreviousWithin, today: "2024-01-08", wantErr: ErrInvalidConfig},
+		{strategy: PreviousWithOpenTodos, today: "2024-01-08", want: "2024-01-02"},
+		{strategy: PreviousWithOpenTodos, today: "2024-01-02"},
+		{strategy: "nearest", today: "2024-01-08", wantErr: ErrInvalidConfig},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %d %s", tt.strategy, tt.days, tt.today), func(t *testing.T) {
+			config := &Config{RootDir: tempDir, PreviousJournal: tt.strategy, PreviousJournalDays: tt.days}
+			got, err := selectPreviousJournal(storage.NewLocal(tempDir), tt.today, config)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("selectPreviousJournal() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("selectPreviousJournal() unexpected error: %v", err)
+			}
+			want := ""
+			if tt.want != "" {
+				want = journalName(tt.want, nil)
+			}
+			if got != want {
+				t.Errorf("selectPreviousJournal() = %q, want %q", got, want)
+			}
+		})
+	}
+
+	// new creates the journal from the template when no journal qualifies
+	config := &Config{RootDir: tempDir, PreviousJournal: PreviousWithin, PreviousJournalDays: 1}
+	summary, err := createJournal(context.Background(), tempDir, "", "2024-01-10", config, NewLogger(ModeQuiet))
+	if err != nil {
+		t.Fatalf("createJournal() unexpected error: %v", err)
+	}
+	if summary.Source != "" || !summary.fromTemplate {
+		t.Errorf("createJournal() carried over from %q, want the template alone", summary.Source)
+	}
+}
+
+func TestOpenJournalTree(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	createTestFile(t, buildJournalPath(tempDir, "2024-01-05"), "## Todos\n\n- [[2024-01-05]]\n  - [ ] Task\n")
+	createTestFile(t, filepath.Join(tempDir, "archive", "2024-01-09.md"), "## Todos\n\n- [[2024-01-09]]\n  - [ ] Archived\n")
+	createTestFile(t, filepath.Join(tempDir, "templates", "2024-01-08.md"), "{{.Date}}\n")
+	createTestFile(t, filepath.Join(tempDir, IgnoreFile), "# Old years\narchive/**\n\n")
+	config := &Config{RootDir: tempDir, Ignore: []string{"templates/**"}}
+
+	store, err := openJournalTree(tempDir, config)
+	if err != nil {
+		t.Fatalf("openJournalTree() error: %v", err)
+	}
+	if got, err := findClosestJournalFile(store, "2024-01-10"); err != nil || got != "2024/01/2024-01-05.md" {
+		t.Errorf("findClosestJournalFile() = %q, %v, want 2024/01/2024-01-05.md", got, err)
+	}
+	journals, err := collectJournals(store, config, NewLogger(ModeQuiet))
+	if err != nil || len(journals) != 1 {
+		t.Errorf("collectJournals() = %d journals, %v, want 1", len(journals), err)
+	}
+
+	// Linked journals are read unless symlinks are ignored
+	outside := filepath.Join(t.TempDir(), "2024-01-07.md")
+	createTestFile(t, outside, "## Todos\n\n- [[2024-01-07]]\n  - [ ] Linked\n")
+	if err := os.Symlink(outside, filepath.Join(tempDir, "2024-01-07.md")); err != nil {
+		t.Skipf("symbolic links not supported: %v", err)
+	}
+	for symlinks, want := range map[string]string{SymlinksFollow: "2024-01-07.md", SymlinksIgnore: "2024/01/2024-01-05.md"} {
+		config.Symlinks = symlinks
+		if store, err = openJournalTree(tempDir, config); err != nil {
+			t.Fatalf("openJournalTree() error: %v", err)
+		}
+		if got, err := findClosestJournalFile(store, "2024-01-10"); err != nil || got != want {
+			t.Errorf("findClosestJournalFile() with symlinks = %s = %q, %v, want %s", symlinks, got, err, want)
+		}
+	}
+
+	config.Ignore = []string{"[a-"}
+	if _, err := openJournalTree(tempDir, config); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("openJournalTree() with an invalid pattern error = %v, want ErrInvalidConfig", err)
+	}
+}
+
+func TestCmdNew(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir}
+
+	// Create a previous journal to use as source
+	prevJournal := filepath.Join(tempDir, "2024/01/2024-01-01.md")
+	createTestFile(t, prevJournal, `---
+date: 2024-01-01
+---
+
+# Daily Journal
+
+## Todos
+
+- [ ] Previous task
+- [x] Completed task
+
+## Notes
+
+Previous notes.
+`)
+
+	tests := []struct {
+		name        string
+		rootDir     string
+		expectError bool
+	}{
+		{
+			name:        "successful creation",
+			rootDir:     tempDir,
+			expectError: false,
+		},
+		{
+			name:        "invalid root directory",
+			rootDir:     "/nonexistent/path",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := NewLogger(ModeQuiet)
+			_, err := cmdNew(context.Background(), tt.rootDir, "", config, logger)
+
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("cmdNew() expected error, got none")
+				}
+			} else {
+				if err != nil {
+					t.Errorf("cmdNew() unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestCmdNew_AlreadyExists(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir}
+
+	// Create journal for today
+	today := time.Now().Format("2006-01-02")
+	year := time.Now().Format("2006")
+	month := time.Now().Format("01")
+	expectedPath := filepath.Join(tempDir, year, month, today+".md")
+	createTestFile(t, expectedPath, "existing content")
+
+	// Should not error if file already exists
+	logger := NewLogger(ModeQuiet)
+	_, err := cmdNew(context.Background(), tempDir, "", config, logger)
+	if err != nil {
+		t.Errorf("cmdNew() unexpected error when file exists: %v", err)
+	}
+}
+
+func TestCmdNew_StorageURL(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir, TodosHeader: "## Todos"}
+	createTestFile(t, buildJournalPath(tempDir, "2024-01-01"), "---\ndate: 2024-01-01\n---\n\n## Todos\n\n- [ ] Previous task goal::[[Ship]]\n- [x] Completed task\n")
+
+	summary, err := cmdNew(context.Background(), "file://"+tempDir, "", config, NewLogger(ModeQuiet))
+	if err != nil {
+		t.Fatalf("cmdNew() unexpected error: %v", err)
+	}
+
+	today := time.Now().Format("2006-01-02")
+	if summary.Target != buildJournalPath(tempDir, today) {
+		t.Errorf("Target = %q, want %q", summary.Target, buildJournalPath(tempDir, today))
+	}
+	if summary.Source != buildJournalPath(tempDir, "2024-01-01") || summary.Backup == "" {
+		t.Errorf("Source = %q, Backup = %q", summary.Source, summary.Backup)
+	}
+	content, err := os.ReadFile(summary.Target)
+	if err != nil {
+		t.Fatalf("Failed to read new journal: %v", err)
+	}
+	if !strings.Contains(string(content), "Previous task") {
+		t.Errorf("new journal does not carry the open task:\n%s", content)
+	}
+
+	var out bytes.Buffer
+	if err := cmdGoals(&out, "file://"+tempDir, OutputText, config, NewLogger(ModeQuiet)); err != nil {
+		t.Fatalf("cmdGoals() unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "Ship: 0/1 done") {
+		t.Errorf("cmdGoals() output = %q", out.String())
+	}
+
+	for _, rootDir := range []string{"s3:///journal", "ftp://example.com/journal"} {
+		_, err := cmdNew(context.Background(), rootDir, "", config, NewLogger(ModeQuiet))
+		if code := exitCodeFor(err); code != ExitConfigError {
+			t.Errorf("cmdNew(%q) exit code = %d, want %d (err: %v)", rootDir, code, ExitConfigError, err)
+		}
+	}
+}
+
+func TestCmdMove(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir, TodosHeader: "## Todos"}
+	fromPath := buildJournalPath(tempDir, "2025-06-20")
+	toPath := buildJournalPath(tempDir, "2025-06-25")
+
+	createTestFile(t, fromPath, `# Friday
+
+## Todos
+
+- [[2025-06-19]]
+  - [ ] Write report
+    - [ ] Collect numbers
+    notes about the report
+  - [ ] Call Bob
+
+## Notes
+
+Friday notes.
+`)
+	createTestFile(t, toPath, `# Wednesday
+
+## Todos
+
+- [[2025-06-25]]
+  - [ ] Plan sprint
+
+## Notes
+`)
+
+	logger := NewLogger(ModeQuiet)
+	if err := cmdMove(tempDir, "REPORT", taskMatch{}, "2025-06-20", "2025-06-25", config, logger); err != nil {
+		t.Fatalf("cmdMove() unexpected error: %v", err)
+	}
+
+	fromContent, err := os.ReadFile(fromPath)
+	if err != nil {
+		t.Fatalf("Failed to read source journal: %v", err)
+	}
+	expectedFrom := `# Friday
+
+## Todos
+
+- [[2025-06-19]]
+  - [ ] Call Bob
+
+## Notes
+
+Friday notes.
+`
+	if string(fromContent) != expectedFrom {
+		t.Errorf("source journal mismatch.\nExpected:\n%s\nGot:\n%s", expectedFrom, fromContent)
+	}
+
+	toContent, err := os.ReadFile(toPath)
+	if err != nil {
+		t.Fatalf("Failed to read target journal: %v", err)
+	}
+	expectedTo := `# Wednesday
+
+## Todos
+
+- [[2025-06-19]]
+  - [ ] Write report created::[[2025-06-19]] (moved from [[2025-06-20]])
+    notes about the report
+    - [ ] Collect numbers
+- [[2025-06-25]]
+  - [ ] Plan sprint
+
+## Notes
+`
+	if string(toContent) != expectedTo {
+		t.Errorf("target journal mismatch.\nExpected:\n%s\nGot:\n%s", expectedTo, toContent)
+	}
+}
+
+func TestCmdMove_Footnotes(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir}
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-20"), "## Todos\n\n- [[2025-06-20]]\n  - [ ] Read the paper[^1]\n  - [ ] Call Bob[^2]\n\n[^1]: Smith et al., 2023\n[^2]: About the invoice\n")
+	toPath := buildJournalPath(tempDir, "2025-06-25")
+	createTestFile(t, toPath, "## Todos\n\n- [[2025-06-25]]\n  - [ ] Plan sprint\n")
+
+	if err := cmdMove(tempDir, "paper", taskMatch{}, "2025-06-20", "2025-06-25", config, NewLogger(ModeQuiet)); err != nil {
+		t.Fatalf("cmdMove() unexpected error: %v", err)
+	}
+	toContent, err := os.ReadFile(toPath)
+	if err != nil {
+		t.Fatalf("Failed to read target journal: %v", err)
+	}
+	want := "## Todos\n\n- [[2025-06-20]]\n  - [ ] Read the paper[^1] created::[[2025-06-20]] (moved from [[2025-06-20]])\n- [[2025-06-25]]\n  - [ ] Plan sprint\n\n[^1]: Smith et al., 2023\n"
+	if string(toContent) != want {
+		t.Errorf("target journal = %q, want %q", toContent, want)
+	}
+}
+
+func TestCmdMove_Twice(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir}
+	for _, date := range []string{"2025-06-23", "2025-06-25"} {
+		createTestFile(t, buildJournalPath(tempDir, date), "## Todos\n\n- [["+date+"]]\n  - [ ] Plan "+date+"\n")
+	}
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-20"), "## Todos\n\n- [[2025-06-20]]\n  - [ ] Write report\n")
+	logger := NewLogger(ModeQuiet)
+
+	// The second move replaces the annotation of the first
+	if err := cmdMove(tempDir, "report", taskMatch{}, "2025-06-20", "2025-06-23", config, logger); err != nil {
+		t.Fatalf("first cmdMove() unexpected error: %v", err)
+	}
+	if err := cmdMove(tempDir, "report", taskMatch{}, "2025-06-23", "2025-06-25", config, logger); err != nil {
+		t.Fatalf("second cmdMove() unexpected error: %v", err)
+	}
+	content, err := os.ReadFile(buildJournalPath(tempDir, "2025-06-25"))
+	if err != nil {
+		t.Fatalf("Failed to read target journal: %v", err)
+	}
+	want := "- [[2025-06-20]]\n  - [ ] Write report created::[[2025-06-20]] (moved from [[2025-06-23]])\n"
+	if !strings.Contains(string(content), want) {
+		t.Errorf("target journal =\n%s\nwant it to contain\n%s", content, want)
+	}
+}
+
+func TestCmdMove_Errors(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir}
+	fromPath := buildJournalPath(tempDir, "2025-06-20")
+	original := "## Todos\n\n- [[2025-06-20]]\n  - [ ] Task one\n  - [ ] Task two\n  - [x] Done task #2025-06-20\n"
+	createTestFile(t, fromPath, original)
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-25"), "## Todos\n\n")
+
+	tests := []struct {
+		name    string
+		pattern string
+		from    string
+		to      string
+	}{
+		{name: "ambiguous pattern", pattern: "task", from: "2025-06-20", to: "2025-06-25"},
+		{name: "no match", pattern: "missing", from: "2025-06-20", to: "2025-06-25"},
+		{name: "completed task", pattern: "done", from: "2025-06-20", to: "2025-06-25"},
+		{name: "same dates", pattern: "one", from: "2025-06-20", to: "2025-06-20"},
+		{name: "invalid date", pattern: "one", from: "2025-06-20", to: "25-06-2025"},
+		{name: "missing target journal", pattern: "one", from: "2025-06-20", to: "2025-06-26"},
+	}
+
+	logger := NewLogger(ModeQuiet)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := cmdMove(tempDir, tt.pattern, taskMatch{}, tt.from, tt.to, config, logger); err == nil {
+				t.Fatal("cmdMove() expected error, got none")
+			}
+			content, err := os.ReadFile(fromPath)
+			if err != nil {
+				t.Fatalf("Failed to read source journal: %v", err)
+			}
+			if string(content) != original {
+				t.Errorf("source journal modified on error:\n%s", content)
+			}
+		})
+	}
+}
+
+func TestCmdPostpone(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir, FrontmatterDateKey: "title", TodosHeader: "## Todos"}
+	journalFile := filepath.Join(tempDir, "journal.md")
+	original := `---
+title: 2025-06-20
+---
+
+## Todos
+
+- [ ] Undated errand #errand
+- [[2025-06-20]]
+  - [ ] Buy stamps #errand [#A]
+  - [ ] Pick up parcel #errand
+  - [ ] Write report [#A]
+
+## Notes
+`
+	createTestFile(t, journalFile, original)
+	logger := NewLogger(ModeQuiet)
+
+	// A dry run reports the tasks but leaves the file alone
+	var out bytes.Buffer
+	opts := postponeOptions{Days: 3, Tag: "#errand", DryRun: true}
+	if err := cmdPostpone(&out, journalFile, opts, config, logger); err != nil {
+		t.Fatalf("cmdPostpone() dry run unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "Would postpone 'Buy stamps #errand [#A]' from 2025-06-20 to 2025-06-23") {
+		t.Errorf("dry run output missing planned change:\n%s", out.String())
+	}
+	if content, _ := os.ReadFile(journalFile); string(content) != original {
+		t.Errorf("dry run modified the journal:\n%s", content)
+	}
+
+	out.Reset()
+	opts = postponeOptions{Days: 3, Tag: "errand", Priority: "A"}
+	if err := cmdPostpone(&out, journalFile, opts, config, logger); err != nil {
+		t.Fatalf("cmdPostpone() unexpected error: %v", err)
+	}
+	if strings.Count(out.String(), "Postponed") != 1 {
+		t.Errorf("expected exactly one postponed task, got:\n%s", out.String())
+	}
+
+	content, err := os.ReadFile(journalFile)
+	if err != nil {
+		t.Fatalf("Failed to read journal: %v", err)
+	}
+	expected := `---
+title: 2025-06-20
+---
+
+## Todos
+
+- [ ] Undated errand #errand
+- [[2025-06-20]]
+  - [ ] Pick up parcel #errand
+  - [ ] Write report [#A]
+- [[2025-06-23]]
+  - [ ] Buy stamps #errand [#A] created::[[2025-06-20]]
+
+## Notes
+`
+	if string(content) != expected {
+		t.Errorf("journal mismatch.\nExpected:\n%s\nGot:\n%s", expected, content)
+	}
+
+	if err := cmdPostpone(&out, journalFile, postponeOptions{Days: 0}, config, logger); err == nil {
+		t.Error("cmdPostpone() expected error for zero days")
+	}
+}
+
+func TestCmdGoals(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir, TodosHeader: "## Todos"}
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-20"), `## Todos
+
+- [[2025-06-20]]
+  - [x] Write spec goal::[[Q3 Objectives#Ship v2]] #2025-06-20
+  - [x] Interview goal::[[Hiring]] #2025-06-20
+`)
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-21"), `## Todos
+
+- [[2025-06-20]]
+  - [ ] Implement goal::[[Q3 Objectives#Ship v2]]
+`)
+	createTestFile(t, filepath.Join(tempDir, "notes.md"), "## Todos\n\n- [ ] Ignored goal::[[Hiring]]\n")
+
+	logger := NewLogger(ModeQuiet)
+
+	var out bytes.Buffer
+	if err := cmdGoals(&out, tempDir, OutputText, config, logger); err != nil {
+		t.Fatalf("cmdGoals() unexpected error: %v", err)
+	}
+	expected := "Hiring: 1/1 done (100%), 0 open\nQ3 Objectives#Ship v2: 1/2 done (50%), 1 open\n"
+	if out.String() != expected {
+		t.Errorf("cmdGoals() output = %q, want %q", out.String(), expected)
+	}
+
+	out.Reset()
+	if err := cmdGoals(&out, tempDir, OutputJSON, config, logger); err != nil {
+		t.Fatalf("cmdGoals() unexpected error: %v", err)
+	}
+	var entries []goalEntry
+	if err := json.Unmarshal(out.Bytes(), &entries); err != nil {
+		t.Fatalf("cmdGoals() produced invalid JSON: %v\n%s", err, out.String())
+	}
+	if len(entries) != 2 || entries[1].Total != 2 || entries[1].Percent != 50 {
+		t.Errorf("cmdGoals() JSON = %+v", entries)
+	}
+}
+
+func TestFindTask(t *testing.T) {
+	journal, err := core.ParseTodosSection(`- [[2025-06-20]]
+  - [ ] Write report
+  - [ ] Write report draft id::draft
+  - [ ] 📞 Call  Alice
+  - [x] Done task #2025-06-20`)
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error: %v", err)
+	}
+	defer func() { chooseTask = nil }()
+
+	tests := []struct {
+		name    string
+		pattern string
+		match   taskMatch
+		want    string
+	}{
+		{"normalized", "call alice", taskMatch{}, "📞 Call  Alice"},
+		{"typo", "cal alcie", taskMatch{}, "📞 Call  Alice"},
+		{"equal text wins", "write REPORT", taskMatch{}, "Write report"},
+		{"exact", "Write report draft id::draft", taskMatch{Exact: true}, "Write report draft id::draft"},
+		{"id", "draft", taskMatch{ID: true}, "Write report draft id::draft"},
+	}
+	for _, tt := range tests {
+		loc, err := findTask(journal, tt.pattern, tt.match, "2025-06-20")
+		if err != nil || loc.Item.Text != tt.want {
+			t.Errorf("%s: findTask(%q) = %v, %v; want %q", tt.name, tt.pattern, loc.Item, err, tt.want)
+		}
+	}
+
+	for _, tt := range []struct {
+		pattern string
+		match   taskMatch
+	}{
+		{"write", taskMatch{}},
+		{"missing", taskMatch{}},
+		{"done task", taskMatch{}},
+		{"write report draft", taskMatch{Exact: true}},
+		{"report", taskMatch{ID: true}},
+	} {
+		if loc, err := findTask(journal, tt.pattern, tt.match, "2025-06-20"); err == nil {
+			t.Errorf("findTask(%q, %+v) = %q, want an error", tt.pattern, tt.match, loc.Item.Text)
+		}
+	}
+
+	// Ambiguous patterns are resolved by the user when someone can answer
+	var offered []string
+	chooseTask = func(texts []string) (int, error) {
+		offered = texts
+		return 1, nil
+	}
+	loc, err := findTask(journal, "write", taskMatch{}, "2025-06-20")
+	if err != nil || loc.Item.Text != "Write report draft id::draft" {
+		t.Errorf("findTask() with a choice = %v, %v", loc.Item, err)
+	}
+	if want := []string{"Write report", "Write report draft id::draft"}; !reflect.DeepEqual(offered, want) {
+		t.Errorf("offered %q, want %q", offered, want)
+	}
+}
+
+func TestPromptTask(t *testing.T) {
+	texts := []string{"Write report", "Write report draft"}
+	tests := []struct {
+		input   string
+		want    int
+		wantErr bool
+	}{
+		{input: "2\n", want: 1},
+		{input: " 1 ", want: 0},
+		{input: "\n", wantErr: true},
+		{input: "3\n", wantErr: true},
+		{input: "draft\n", wantErr: true},
+		{input: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		got, err := promptTask(strings.NewReader(tt.input), &out, texts)
+		if (err != nil) != tt.wantErr || (!tt.wantErr && got != tt.want) {
+			t.Errorf("promptTask(%q) = %d, %v; want %d, error %v", tt.input, got, err, tt.want, tt.wantErr)
+		}
+		if want := "Several open tasks match:\n  1) Write report\n  2) Write report draft\nTask [1-2]: "; out.String() != want {
+			t.Errorf("prompt = %q, want %q", out.String(), want)
+		}
+	}
+}
+
+func TestCmdTimer(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir}
+	statePath := filepath.Join(tempDir, "state", TimerStateFile)
+	journalPath := buildJournalPath(tempDir, "2025-06-20")
+	createTestFile(t, journalPath, `## Todos
+
+- [[2025-06-20]]
+  - [ ] Write report
+    - [ ] Collect numbers
+  - [ ] Review PR
+
+## Notes
+`)
+
+	logger := NewLogger(ModeQuiet)
+	start := time.Date(2025, 6, 20, 9, 30, 0, 0, time.Local)
+
+	if err := cmdTimerStart(statePath, tempDir, "missing", taskMatch{}, start, config, logger); err == nil {
+		t.Error("cmdTimerStart() expected error for unknown task")
+	}
+	if err := cmdTimerStart(statePath, tempDir, "report", taskMatch{}, start, config, logger); err != nil {
+		t.Fatalf("cmdTimerStart() unexpected error: %v", err)
+	}
+	if err := cmdTimerStart(statePath, tempDir, "review", taskMatch{}, start, config, logger); err == nil {
+		t.Error("cmdTimerStart() expected error while a timer is running")
+	}
+
+	var out bytes.Buffer
+	if err := cmdTimerStop(&out, statePath, tempDir, start.Add(84*time.Minute), config, logger); err != nil {
+		t.Fatalf("cmdTimerStop() unexpected error: %v", err)
+	}
+	if out.String() != "Recorded 1h24m on 'Write report'\n" {
+		t.Errorf("cmdTimerStop() output = %q", out.String())
+	}
+
+	content, err := os.ReadFile(journalPath)
+	if err != nil {
+		t.Fatalf("Failed to read journal: %v", err)
+	}
+	expected := `## Todos
+
+- [[2025-06-20]]
+  - [ ] Write report
+    - ⏱ 1h24m (09:30-10:54)
+    - [ ] Collect numbers
+  - [ ] Review PR
+
+## Notes
+`
+	if string(content) != expected {
+		t.Errorf("journal mismatch.\nExpected:\n%s\nGot:\n%s", expected, content)
+	}
+
+	if _, err := os.Stat(statePath); !os.IsNotExist(err) {
+		t.Error("timer state file should be removed after stop")
+	}
+	if err := cmdTimerStop(&out, statePath, tempDir, start, config, logger); err == nil {
+		t.Error("cmdTimerStop() expected error when no timer is running")
+	}
+}
+
+func TestCmdTimerCompletedTask(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir}
+	statePath := filepath.Join(tempDir, "state", TimerStateFile)
+	journalPath := buildJournalPath(tempDir, "2025-06-20")
+	createTestFile(t, journalPath, "## Todos\n\n- [[2025-06-20]]\n  - [ ] Write report\n")
+
+	logger := NewLogger(ModeQuiet)
+	start := time.Date(2025, 6, 20, 9, 30, 0, 0, time.Local)
+	if err := cmdTimerStart(statePath, tempDir, "report", taskMatch{}, start, config, logger); err != nil {
+		t.Fatalf("cmdTimerStart() unexpected error: %v", err)
+	}
+
+	// The task is checked off before the timer is stopped
+	createTestFile(t, journalPath, "## Todos\n\n- [[2025-06-20]]\n  - [x] Write report #2025-06-20\n")
+	var out bytes.Buffer
+	if err := cmdTimerStop(&out, statePath, tempDir, start.Add(30*time.Minute), config, logger); err != nil {
+		t.Fatalf("cmdTimerStop() unexpected error: %v", err)
+	}
+	content, _ := os.ReadFile(journalPath)
+	if want := "## Todos\n\n- [[2025-06-20]]\n  - [x] Write report #2025-06-20\n    - ⏱ 30m (09:30-10:00)\n"; string(content) != want {
+		t.Errorf("journal = %q, want %q", content, want)
+	}
+
+	// A timer whose task is gone can be cancelled
+	if err := cmdTimerStart(statePath, tempDir, "report", taskMatch{}, start, config, logger); err == nil {
+		t.Error("cmdTimerStart() expected error for a completed task")
+	}
+	createTestFile(t, journalPath, "## Todos\n\n- [[2025-06-20]]\n  - [ ] Write report\n")
+	if err := cmdTimerStart(statePath, tempDir, "report", taskMatch{}, start, config, logger); err != nil {
+		t.Fatalf("cmdTimerStart() unexpected error: %v", err)
+	}
+	createTestFile(t, journalPath, "## Todos\n\n- [[2025-06-20]]\n  - [ ] Something else\n")
+	if err := cmdTimerStop(&out, statePath, tempDir, start, config, logger); err == nil {
+		t.Error("cmdTimerStop() expected error for a removed task")
+	}
+	out.Reset()
+	if err := cmdTimerCancel(&out, statePath); err != nil {
+		t.Fatalf("cmdTimerCancel() unexpected error: %v", err)
+	}
+	if out.String() != "Discarded timer for 'Write report' started at 09:30\n" {
+		t.Errorf("cmdTimerCancel() output = %q", out.String())
+	}
+	if err := cmdTimerStart(statePath, tempDir, "report", taskMatch{}, start, config, logger); err == nil {
+		t.Error("cmdTimerStart() expected error for a removed task")
+	}
+	if err := cmdTimerCancel(&out, statePath); err == nil {
+		t.Error("cmdTimerCancel() expected error when no timer is running")
+	}
+
+	// An unreadable state file can be cancelled too
+	createTestFile(t, statePath, "{broken")
+	if err := cmdTimerCancel(&out, statePath); err != nil {
+		t.Errorf("cmdTimerCancel() of a broken state unexpected error: %v", err)
+	}
+}
+
+func TestCmdBacklog(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir, BacklogFile: "backlog.md"}
+	backlogPath := filepath.Join(tempDir, "backlog.md")
+	createTestFile(t, backlogPath, `# Backlog
+
+## Todos
+
+- [[2023-11-01]]
+  - [ ] Learn Rust #dev
+    - [ ] Read the book
+  - [x] Done already
+  - [ ] Paint fence
+- [[2023-12-01]]
+  - [ ] Fix bike #errand
+`)
+	journalPath := buildJournalPath(tempDir, "2025-06-20")
+	createTestFile(t, journalPath, `## Todos
+
+- [[2025-06-20]]
+  - [ ] Write report
+
+## Notes
+`)
+
+	logger := NewLogger(ModeQuiet)
+	now := time.Date(2025, 6, 20, 9, 0, 0, 0, time.Local)
+
+	var out bytes.Buffer
+	if err := cmdBacklogList(&out, tempDir, config, logger); err != nil {
+		t.Fatalf("cmdBacklogList() unexpected error: %v", err)
+	}
+	expected := "  1. [2023-11-01] Learn Rust #dev\n  2. [2023-11-01] Paint fence\n  3. [2023-12-01] Fix bike #errand\n"
+	if out.String() != expected {
+		t.Errorf("cmdBacklogList() output = %q, want %q", out.String(), expected)
+	}
+
+	if err := cmdBacklogPull(&out, tempDir, []int{4}, "", now, config, logger); err == nil {
+		t.Error("cmdBacklogPull() expected error for unknown task number")
+	}
+	if err := cmdBacklogPull(&out, tempDir, nil, "", now, config, logger); err == nil {
+		t.Error("cmdBacklogPull() expected error without numbers or tag")
+	}
+
+	out.Reset()
+	if err := cmdBacklogPull(&out, tempDir, []int{1}, "#errand", now, config, logger); err != nil {
+		t.Fatalf("cmdBacklogPull() unexpected error: %v", err)
+	}
+	if strings.Count(out.String(), "Pulled") != 2 {
+		t.Errorf("expected two pulled tasks, got:\n%s", out.String())
+	}
+
+	content, err := os.ReadFile(journalPath)
+	if err != nil {
+		t.Fatalf("Failed to read journal: %v", err)
+	}
+	expected = `## Todos
+
+- [[2023-11-01]]
+  - [ ] Learn Rust #dev
+    - [ ] Read the book
+- [[2023-12-01]]
+  - [ ] Fix bike #errand
+- [[2025-06-20]]
+  - [ ] Write report
+
+## Notes
+`
+	if string(content) != expected {
+		t.Errorf("journal mismatch.\nExpected:\n%s\nGot:\n%s", expected, content)
+	}
+
+	content, err = os.ReadFile(backlogPath)
+	if err != nil {
+		t.Fatalf("Failed to read backlog: %v", err)
+	}
+	expected = `# Backlog
+
+## Todos
+
+- [[2023-11-01]]
+  - [x] Done already
+  - [ ] Paint fence
+`
+	if string(content) != expected {
+		t.Errorf("backlog mismatch.\nExpected:\n%s\nGot:\n%s", expected, content)
+	}
+}
+
+func TestCmdStats(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir}
+	indexPath := filepath.Join(tempDir, "cache", IndexCacheFile)
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-18"), `## Todos
+
+- [[2025-06-18]]
+  - [x] Write spec #2025-06-18
+  - [ ] Implement
+    - [x] Parser #2025-06-18
+`)
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-19"), `## Todos
+
+- [[2025-06-18]]
+  - [ ] Implement
+    - [x] Parser #2025-06-18
+    - [x] Docs
+`)
+
+	logger := NewLogger(ModeQuiet)
+
+	var out bytes.Buffer
+	if err := cmdStats(&out, tempDir, indexPath, "2025-06-20", OutputText, 0, "", config, logger); err != nil {
+		t.Fatalf("cmdStats() unexpected error: %v", err)
+	}
+	expected := "Completed: 3 todos on 2 days\nCurrent streak: 2 days\nLongest streak: 2 days\nWeekly velocity: 0.8 todos/week (last 4 weeks)\nDays to complete: 0.0 median (3 todos)\n"
+	if out.String() != expected {
+		t.Errorf("cmdStats() output = %q, want %q", out.String(), expected)
+	}
+
+	index := loadJournalIndex(indexPath)
+	if len(index.Files) != 2 {
+		t.Fatalf("index has %d journals, want 2", len(index.Files))
+	}
+	for key, entry := range index.Files {
+		for _, c := range entry.Completions {
+			if strings.Contains(c.Text, "Parser") {
+				t.Errorf("index entry %s holds todo text %q", key, c.Text)
+			}
+		}
+	}
+
+	// Unchanged journals are taken from the index
+	journal := buildJournalPath(tempDir, "2025-06-19")
+	info, err := os.Stat(journal)
+	if err != nil {
+		t.Fatalf("Failed to stat journal: %v", err)
+	}
+	key, err := filepath.Abs(journal)
+	if err != nil {
+		t.Fatalf("Failed to resolve journal path: %v", err)
+	}
+	entry := index.Files[key]
+	entry.Completions = append(entry.Completions, core.Completion{Date: "2025-06-20", Text: "cached"})
+	index.Files[key] = entry
+	if err := index.save(indexPath); err != nil {
+		t.Fatalf("Failed to save index: %v", err)
+	}
+	if entry.Size != info.Size() {
+		t.Errorf("index size = %d, want %d", entry.Size, info.Size())
+	}
+
+	out.Reset()
+	if err := cmdStats(&out, tempDir, indexPath, "2025-06-20", OutputJSON, 0, "", config, logger); err != nil {
+		t.Fatalf("cmdStats() unexpected error: %v", err)
+	}
+	var stats statsEntry
+	if err := json.Unmarshal(out.Bytes(), &stats); err != nil {
+		t.Fatalf("cmdStats() produced invalid JSON: %v\n%s", err, out.String())
+	}
+	if stats.CompletedTodos != 4 || stats.CurrentStreak != 3 || stats.ActiveDays != 3 {
+		t.Errorf("cmdStats() JSON = %+v, want the cached completion counted", stats)
+	}
+}
+
+func TestCmdStats_CompletionHours(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir, DateTag: "✅ 2006-01-02", CompletionTime: true}
+	indexPath := filepath.Join(tempDir, "cache", IndexCacheFile)
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-20"), `## Todos
+
+- [[2025-06-20]]
+  - [x] Call Bob ✅ 2025-06-20 09:10
+  - [x] Write report ✅ 2025-06-20 11:45
+  - [x] Review ✅ 2025-06-20 11:02
+  - [x] Undated
+`)
+
+	logger := NewLogger(ModeQuiet)
+	var out bytes.Buffer
+	if err := cmdStats(&out, tempDir, indexPath, "2025-06-20", OutputText, 0, "", config, logger); err != nil {
+		t.Fatalf("cmdStats() unexpected error: %v", err)
+	}
+	want := "Completions by hour:\n  09:00 " + strings.Repeat("█", 15) + " 1\n  10:00 0\n  11:00 " + strings.Repeat("█", 30) + " 2\n"
+	if !strings.HasSuffix(out.String(), want) {
+		t.Errorf("cmdStats() output = %q, want it to end with %q", out.String(), want)
+	}
+
+	out.Reset()
+	if err := cmdStats(&out, tempDir, indexPath, "2025-06-20", OutputJSON, 0, "", config, logger); err != nil {
+		t.Fatalf("cmdStats() unexpected error: %v", err)
+	}
+	var stats statsEntry
+	if err := json.Unmarshal(out.Bytes(), &stats); err != nil {
+		t.Fatalf("cmdStats() produced invalid JSON: %v\n%s", err, out.String())
+	}
+	if len(stats.CompletionHours) != 24 || stats.CompletionHours[9] != 1 || stats.CompletionHours[11] != 2 {
+		t.Errorf("cmdStats() JSON completion hours = %v", stats.CompletionHours)
+	}
+}
+
+func TestCmdStatsTopCarried(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir, CarryMarker: "↪×%d"}
+	indexPath := filepath.Join(tempDir, "cache", IndexCacheFile)
+	source := buildJournalPath(tempDir, "2025-06-19")
+	createTestFile(t, source, `---
+title: 2025-06-19
+---
+
+## Todos
+
+- [[2025-06-12]]
+  - [ ] Call the bank ↪×3
+  - [ ] File taxes
+- [[2025-06-19]]
+  - [ ] Fix the bike ↪×1
+  - [x] Done late ↪×7
+`)
+	target := buildJournalPath(tempDir, "2025-06-20")
+	templateFile := filepath.Join(tempDir, "template.md")
+	createTestFile(t, templateFile, "---\ntitle: {{.Date}}\n---\n\n## Todos\n\n{{.TODOS}}\n")
+
+	logger := NewLogger(ModeQuiet)
+	if _, err := processJournal(context.Background(), source, target, templateFile, "2025-06-20", false, core.DayRange{}, config, logger); err != nil {
+		t.Fatalf("processJournal() unexpected error: %v", err)
+	}
+	content, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatalf("Failed to read new journal: %v", err)
+	}
+	for _, want := range []string{"Call the bank ↪×4", "File taxes ↪×1", "Fix the bike ↪×2"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("new journal lacks %q:\n%s", want, content)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := cmdStats(&out, tempDir, indexPath, "2025-06-20", OutputText, 2, "", config, logger); err != nil {
+		t.Fatalf("cmdStats() unexpected error: %v", err)
+	}
+	want := "Most carried:\n  4× since 2025-06-12: Call the bank ↪×4 created::[[2025-06-12]]\n  2× since 2025-06-19: Fix the bike ↪×2 created::[[2025-06-19]]\n"
+	if !strings.HasSuffix(out.String(), want) {
+		t.Errorf("cmdStats() output = %q, want it to end with %q", out.String(), want)
+	}
+
+	out.Reset()
+	if err := cmdStats(&out, tempDir, indexPath, "2025-06-20", OutputJSON, 1, "", config, logger); err != nil {
+		t.Fatalf("cmdStats() unexpected error: %v", err)
+	}
+	var stats statsEntry
+	if err := json.Unmarshal(out.Bytes(), &stats); err != nil {
+		t.Fatalf("cmdStats() produced invalid JSON: %v\n%s", err, out.String())
+	}
+	if len(stats.MostCarried) != 1 || stats.MostCarried[0].CarryCount != 4 {
+		t.Errorf("cmdStats() JSON most_carried = %+v, want the bank call carried 4 times", stats.MostCarried)
+	}
+}
+
+func TestCmdStatsMarkdown(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir}
+	indexPath := filepath.Join(tempDir, "cache", IndexCacheFile)
+	logger := NewLogger(ModeQuiet)
+
+	var out bytes.Buffer
+	if err := cmdStats(&out, tempDir, indexPath, "2025-06-20", OutputMarkdown, 0, "", config, logger); err != nil {
+		t.Fatalf("cmdStats() unexpected error: %v", err)
+	}
+	if out.String() != "" {
+		t.Errorf("cmdStats() output without tags = %q, want none", out.String())
+	}
+
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-19"), `## Todos
+
+- [[2025-06-19]]
+  - [x] Buy milk #errand #2025-06-19
+  - [ ] Review PR #work
+    - [ ] Ask Kim #work #errand
+
+## Notes
+`)
+	if err := cmdStats(&out, tempDir, indexPath, "2025-06-20", OutputMarkdown, 0, "", config, logger); err != nil {
+		t.Fatalf("cmdStats() unexpected error: %v", err)
+	}
+	want := "| Tag     | Open | Closed | Total |\n" +
+		"| ------- | ---: | -----: | ----: |\n" +
+		"| #errand |    1 |      1 |     2 |\n" +
+		"| #work   |    2 |      0 |     2 |\n"
+	if out.String() != want {
+		t.Errorf("cmdStats() output =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestCmdHeatmap(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir}
+	indexPath := filepath.Join(tempDir, "cache", IndexCacheFile)
+	createTestFile(t, buildJournalPath(tempDir, "2025-01-06"), `## Todos
+
+- [[2025-01-06]]
+  - [x] First #2025-01-01
+  - [x] Second #2025-01-06
+  - [x] Third #2025-01-06
+  - [x] Fourth #2025-01-06
+  - [x] Fifth #2025-01-06
+  - [x] Last year #2024-12-31
+`)
+	logger := NewLogger(ModeQuiet)
+
+	var out bytes.Buffer
+	if err := cmdHeatmap(&out, tempDir, indexPath, heatmapOptions{Year: 2025, Format: HeatmapASCII}, config, logger); err != nil {
+		t.Fatalf("cmdHeatmap() unexpected error: %v", err)
+	}
+	lines := strings.Split(out.String(), "\n")
+	if lines[0] != "2025: 5 todos completed" {
+		t.Errorf("heatmap title = %q", lines[0])
+	}
+	// 2025-01-01 is a Wednesday in the first week, 2025-01-06 the Monday after
+	if !strings.HasPrefix(lines[3], "Mon  █·") {
+		t.Errorf("Monday row = %q, want the busiest day in the second week", lines[3])
+	}
+	if !strings.HasPrefix(lines[5], "Wed ░·") {
+		t.Errorf("Wednesday row = %q, want the lowest level in the first week", lines[5])
+	}
+
+	svgFile := filepath.Join(tempDir, "heatmap.svg")
+	opts := heatmapOptions{Year: 2025, Format: HeatmapSVG, OutputFile: svgFile}
+	if err := cmdHeatmap(&out, tempDir, indexPath, opts, config, logger); err != nil {
+		t.Fatalf("cmdHeatmap() unexpected error: %v", err)
+	}
+	svg, err := os.ReadFile(svgFile)
+	if err != nil {
+		t.Fatalf("Failed to read heatmap: %v", err)
+	}
+	if !strings.HasPrefix(string(svg), "<svg") || strings.Count(string(svg), "<rect") != 365 ||
+		!strings.Contains(string(svg), `fill="#216e39"><title>2025-01-06: 4 completed</title>`) {
+		t.Errorf("unexpected SVG heatmap:\n%s", svg)
+	}
+
+	if err := cmdHeatmap(&out, tempDir, indexPath, heatmapOptions{Year: 2025, Format: "png"}, config, logger); err == nil {
+		t.Error("cmdHeatmap() expected error for unknown format")
+	}
+}
+
+func TestProcessJournal_Streaks(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(tempDir, "cache"))
+
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-19"), "## Todos\n\n- [[2025-06-19]]\n  - [x] Earlier #2025-06-19\n")
+	sourceFile := buildJournalPath(tempDir, "2025-06-20")
+	createTestFile(t, sourceFile, "---\ntitle: 2025-06-20\n---\n\n## Todos\n\n- [[2025-06-20]]\n  - [x] Today\n  - [ ] Tomorrow\n")
+	templateFile := filepath.Join(tempDir, "template.md")
+	createTestFile(t, templateFile, "Streak: {{.CurrentStreak}} days\n\n## Todos\n\n{{.TODOS}}\n")
+	targetFile := filepath.Join(tempDir, "target.md")
+
+	config := &Config{RootDir: tempDir, FrontmatterDateKey: "title"}
+	if _, err := processJournal(context.Background(), sourceFile, targetFile, templateFile, "2025-06-21", false, core.DayRange{}, config, NewLogger(ModeQuiet)); err != nil {
+		t.Fatalf("processJournal() unexpected error: %v", err)
+	}
+	target, err := os.ReadFile(targetFile)
+	if err != nil {
+		t.Fatalf("Failed to read target file: %v", err)
+	}
+	if !strings.HasPrefix(string(target), "Streak: 2 days\n") {
+		t.Errorf("target file should start with the streak, got:\n%s", target)
+	}
+}
+
+func TestCmdGraph(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir}
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-20"), `## Todos
+
+- [[2025-06-20]]
+  - [ ] Build id::build
+  - [ ] Deploy "v2" depends::[[build]] depends::[[budget]]
+`)
+	logger := NewLogger(ModeQuiet)
+	now := time.Date(2025, 6, 21, 9, 0, 0, 0, time.Local)
+
+	var out bytes.Buffer
+	if err := cmdGraph(&out, tempDir, GraphMermaid, now, config, logger); err != nil {
+		t.Fatalf("cmdGraph() unexpected error: %v", err)
+	}
+	expected := `graph TD
+  t0["Build"]
+  t1["Deploy #quot;v2#quot;"]
+  t2(["budget (missing)"])
+  t0 --> t1
+  t2 --> t1
+`
+	if out.String() != expected {
+		t.Errorf("mermaid graph mismatch.\nExpected:\n%s\nGot:\n%s", expected, out.String())
+	}
+
+	out.Reset()
+	if err := cmdGraph(&out, tempDir, GraphDOT, now, config, logger); err != nil {
+		t.Fatalf("cmdGraph() unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), `t1 [label="Deploy \"v2\""];`) || !strings.Contains(out.String(), "t2 -> t1;") {
+		t.Errorf("unexpected DOT graph:\n%s", out.String())
+	}
+
+	if err := cmdGraph(&out, tempDir, "png", now, config, logger); err == nil {
+		t.Error("cmdGraph() expected error for unknown format")
+	}
+}
+
+func TestCmdExportBear(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir}
+	createTestFile(t, buildJournalPath(tempDir, "2025-05-31"), "## Todos\n\n- [[2025-05-31]]\n  - [x] Pay rent #2025-05-31\n")
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-20"), "## Todos\n\n- [[2025-06-19]]\n  - [ ] Write spec\n    - note\n- [[2025-06-20]]\n  - [x] Buy milk #2025-06-20\n    - [ ] Oat milk\n")
+	logger := NewLogger(ModeQuiet)
+	now := time.Date(2025, 6, 21, 9, 0, 0, 0, time.Local)
+
+	var out bytes.Buffer
+	if err := cmdExport(&out, tempDir, exportOptions{Format: ExportBear}, now, config, logger); err != nil {
+		t.Fatalf("cmdExport() unexpected error: %v", err)
+	}
+	want := "# 2025-06-20\n#journal/2025/06\n\n## 2025-06-19\n- [ ] Write spec\n\t- note\n## 2025-06-20\n- [x] Buy milk #2025-06-20\n\t- [ ] Oat milk\n"
+	if out.String() != want {
+		t.Errorf("note =\n%s\nwant\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	if err := cmdExport(&out, tempDir, exportOptions{Format: ExportBear, URL: true}, now, config, logger); err != nil {
+		t.Fatalf("cmdExport() unexpected error: %v", err)
+	}
+	wantURL := "bear://x-callback-url/create?title=2025-06-20&text=%23%23 2025-06-19%0A- %5B %5D Write spec%0A%09- note%0A%23%23 2025-06-20%0A- %5Bx%5D Buy milk %232025-06-20%0A%09- %5B %5D Oat milk&tags=journal%2F2025%2F06\n"
+	if got := out.String(); got != strings.ReplaceAll(wantURL, " ", "%20") {
+		t.Errorf("URL = %q", got)
+	}
+
+	notesDir := filepath.Join(tempDir, "bear")
+	if err := cmdExport(io.Discard, tempDir, exportOptions{Format: ExportBear, File: notesDir}, now, config, logger); err != nil {
+		t.Fatalf("cmdExport() unexpected error: %v", err)
+	}
+	note, err := os.ReadFile(filepath.Join(notesDir, "2025-05-31.md"))
+	if err != nil || string(note) != "# 2025-05-31\n#journal/2025/05\n\n## 2025-05-31\n- [x] Pay rent #2025-05-31\n" {
+		t.Errorf("note of 2025-05-31 = %q (%v)", note, err)
+	}
+	if _, err := os.Stat(filepath.Join(notesDir, "2025-06-20.md")); err != nil {
+		t.Errorf("note of 2025-06-20 not written: %v", err)
+	}
+
+	if err := cmdExport(io.Discard, tempDir, exportOptions{Format: ExportBear, File: notesDir, Push: true}, now, config, logger); err == nil {
+		t.Error("cmdExport() expected error for --push with a directory")
+	}
+}
+
+func TestCmdExportImportKanban(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir}
+	journalPath := buildJournalPath(tempDir, "2025-06-20")
+	createTestFile(t, journalPath, `## Todos
+
+- [[2025-06-20]]
+  - [ ] Write spec id::spec
+  - [ ] Implement depends::[[spec]]
+    - [ ] Parser
+  - [x] Buy milk #2025-06-20
+`)
+	logger := NewLogger(ModeQuiet)
+	now := time.Date(2025, 6, 21, 9, 0, 0, 0, time.Local)
+	boardPath := filepath.Join(tempDir, "board.md")
+
+	opts := exportOptions{Format: ExportKanban, Columns: "Todo, Waiting ,Done", File: boardPath}
+	if err := cmdExport(io.Discard, tempDir, opts, now, config, logger); err != nil {
+		t.Fatalf("cmdExport() unexpected error: %v", err)
+	}
+	board, err := os.ReadFile(boardPath)
+	if err != nil {
+		t.Fatalf("Failed to read board: %v", err)
+	}
+	want := "---\n\nkanban-plugin: basic\n\n---\n\n" +
+		"## Todo\n\n- [ ] Write spec id::spec\n\n\n" +
+		"## Waiting\n\n- [ ] Implement depends::[[spec]]\n\n\n" +
+		"## Done\n\n**Complete**\n- [x] Buy milk #2025-06-20\n\n\n\n\n" + kanbanSettings
+	if string(board) != want {
+		t.Errorf("board mismatch.\nExpected:\n%s\nGot:\n%s", want, board)
+	}
+
+	// Complete the spec, reopen the milk and add a card of an unknown task
+	edited := strings.Replace(string(board), "- [ ] Write spec", "- [x] Write spec", 1)
+	edited = strings.Replace(edited, "- [x] Buy milk #2025-06-20", "- [ ] Buy milk", 1)
+	edited = strings.Replace(edited, "## Waiting\n\n", "## Waiting\n\n- [ ] New card\n", 1)
+	createTestFile(t, boardPath, edited)
+
+	// Completions learnt from the board are stamped with the time of the import
+	config.CompletionTime = true
+	var out bytes.Buffer
+	if err := cmdImport(&out, tempDir, ExportKanban, boardPath, now, config, logger); err != nil {
+		t.Fatalf("cmdImport() unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "Updated 2 tasks in ") {
+		t.Errorf("cmdImport() output = %q, want 2 tasks updated", out.String())
+	}
+	content, err := os.ReadFile(journalPath)
+	if err != nil {
+		t.Fatalf("Failed to read journal: %v", err)
+	}
+	for _, line := range []string{"- [x] Write spec id::spec #2025-06-21T09:00", "- [ ] Implement depends::[[spec]]", "- [ ] Parser", "- [ ] Buy milk #2025-06-20"} {
+		if !strings.Contains(string(content), line) {
+			t.Errorf("journal lacks %q:\n%s", line, content)
+		}
+	}
+
+	opts.Columns = "Todo,Done"
+	if err := cmdExport(io.Discard, tempDir, opts, now, config, logger); exitCodeFor(err) != ExitConfigError {
+		t.Errorf("cmdExport() with two columns error = %v, want a config error", err)
+	}
+}
+
+func TestWriteSQLDump(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir}
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-20"), `## Todos
+
+- [[2025-06-19]]
+  - [ ] Call Kim's bank #errand
+    - [x] Find number
+`)
+	store, err := storage.Open(tempDir)
+	if err != nil {
+		t.Fatalf("storage.Open() error: %v", err)
+	}
+	journals, err := loadSyncJournals(store, core.NoProgress, config)
+	if err != nil {
+		t.Fatalf("loadSyncJournals() error: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := writeSQLDump(&out, store, journals); err != nil {
+		t.Fatalf("writeSQLDump() error: %v", err)
+	}
+	for _, want := range []string{
+		"INSERT INTO tasks VALUES (1, '2025-06-20', '2025-06-19', NULL, 1, 'Call Kim''s bank #errand', 0, 0, '" + syncTaskID("Call Kim's bank #errand") + "', '2025-06-19');\n",
+		"INSERT INTO tasks VALUES (2, '2025-06-20', '2025-06-19', 1, 2, 'Find number', 1, 0, '" + syncTaskID("Find number") + "', '2025-06-19');\n",
+		"INSERT INTO tags VALUES (1, '#errand');\n",
+		"INSERT OR IGNORE INTO completions VALUES ('2025-06-20', 'Find number', '2025-06-19');\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("SQL dump lacks %q:\n%s", want, out.String())
+		}
+	}
+	if !strings.HasPrefix(out.String(), "BEGIN;\n") || !strings.HasSuffix(out.String(), "COMMIT;\n") {
+		t.Errorf("SQL dump is not a single transaction:\n%s", out.String())
+	}
+}
+
+func TestCmdExportSQLiteAndQuery(t *testing.T) {
+	if _, err := exec.LookPath(DefaultSQLite); err != nil {
+		t.Skip("sqlite3 command not available")
+	}
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir}
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-19"), `## Todos
+
+- [[2025-06-19]]
+  - [x] Buy milk #errand #2025-06-19
+  - [ ] Post letter #errand
+`)
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-20"), `## Todos
+
+- [[2025-06-19]]
+  - [ ] Post letter #errand
+`)
+	logger := NewLogger(ModeQuiet)
+	now := time.Date(2025, 6, 20, 9, 0, 0, 0, time.Local)
+	dbPath := filepath.Join(tempDir, "journal.db")
+
+	// A second export replaces the tables of the first
+	for i := 0; i < 2; i++ {
+		if err := cmdExport(io.Discard, tempDir, exportOptions{Format: ExportSQLite, File: dbPath}, now, config, logger); err != nil {
+			t.Fatalf("cmdExport() unexpected error: %v", err)
+		}
+	}
+
+	query := "SELECT tag, count(DISTINCT task_key) AS tasks FROM tags JOIN tasks ON tasks.id = task_id GROUP BY tag"
+	var out bytes.Buffer
+	if err := cmdQuery(&out, tempDir, dbPath, query, OutputJSON, "", config); err != nil {
+		t.Fatalf("cmdQuery() unexpected error: %v", err)
+	}
+	if got := strings.TrimSpace(out.String()); got != `[{"tag":"#errand","tasks":2}]` {
+		t.Errorf("cmdQuery() on database = %s", got)
+	}
+
+	out.Reset()
+	if err := cmdQuery(&out, tempDir, "", "SELECT count(*) AS open FROM tasks WHERE day = '2025-06-20' AND completed = 0;", OutputText, "", config); err != nil {
+		t.Fatalf("cmdQuery() unexpected error: %v", err)
+	}
+	if fields := strings.Fields(out.String()); len(fields) != 3 || fields[0] != "open" || fields[2] != "1" {
+		t.Errorf("cmdQuery() on journals = %q", out.String())
+	}
+
+	if err := cmdQuery(&out, tempDir, "", "SELECT * FROM missing", OutputText, "", config); err == nil || !strings.Contains(err.Error(), "no such table") {
+		t.Errorf("cmdQuery() error = %v, want the sqlite3 error", err)
+	}
+
+	// The shell must not run dot-commands smuggled into the statement
+	marker := filepath.Join(tempDir, "written.txt")
+	for _, query := range []string{"SELECT 1;\n.output " + marker + "\nSELECT 2", "  .shell touch " + marker} {
+		if err := cmdQuery(&out, tempDir, dbPath, query, OutputText, "", config); exitCodeFor(err) != ExitConfigError {
+			t.Errorf("cmdQuery(%q) error = %v, want a config error", query, err)
+		}
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("cmdQuery() ran a dot-command")
+	}
+
+	// Journals in different folders may share a date
+	createTestFile(t, filepath.Join(tempDir, "work", "2025-06-20.md"), "## Todos\n\n- [[2025-06-20]]\n  - [ ] Review PR\n")
+	if err := cmdExport(io.Discard, tempDir, exportOptions{Format: ExportSQLite, File: dbPath}, now, config, logger); err != nil {
+		t.Fatalf("cmdExport() with journals sharing a date: %v", err)
+	}
+	out.Reset()
+	if err := cmdQuery(&out, tempDir, "", "SELECT count(*) AS journals FROM days WHERE date = '2025-06-20'", OutputJSON, "", config); err != nil {
+		t.Fatalf("cmdQuery() with journals sharing a date: %v", err)
+	}
+	if got := strings.TrimSpace(out.String()); got != `[{"journals":2}]` {
+		t.Errorf("cmdQuery() of journals sharing a date = %s", got)
+	}
+}
+
+func TestProcessJournal_DependencyPolicy(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	sourceFile := filepath.Join(tempDir, "source.md")
+	targetFile := filepath.Join(tempDir, "target.md")
+	content := "---\ntitle: 2024-01-01\n---\n\n## Todos\n\n- [[2024-01-01]]\n  - [ ] Build id::build\n  - [x] Deploy depends::[[build]]\n"
+
+	tests := []struct {
+		policy       string
+		wantErr      bool
+		wantWarnings int
+	}{
+		{policy: "", wantWarnings: 1},
+		{policy: DependencyWarn, wantWarnings: 1},
+		{policy: DependencyIgnore, wantWarnings: 0},
+		{policy: DependencyRefuse, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run("policy "+tt.policy, func(t *testing.T) {
+			createTestFile(t, sourceFile, content)
+			config := &Config{RootDir: tempDir, FrontmatterDateKey: "title", DependencyPolicy: tt.policy}
+			summary, err := processJournal(context.Background(), sourceFile, targetFile, "", "2024-01-02", true, core.DayRange{}, config, NewLogger(ModeQuiet))
+			if tt.wantErr {
+				if err == nil || exitCodeFor(err) != ExitParseError {
+					t.Fatalf("processJournal() error = %v, want a parse error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("processJournal() unexpected error: %v", err)
+			}
+			if len(summary.Warnings) != tt.wantWarnings {
+				t.Errorf("warnings = %v, want %d", summary.Warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func TestFormatElapsed(t *testing.T) {
+	tests := map[time.Duration]string{
+		10 * time.Second: "1m",
+		25 * time.Minute: "25m",
+		65 * time.Minute: "1h05m",
+		3 * time.Hour:    "3h00m",
+	}
+	for d, want := range tests {
+		if got := formatElapsed(d); got != want {
+			t.Errorf("formatElapsed(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestEncodedJournals(t *testing.T) {
+	if _, err := exec.LookPath("base64"); err != nil {
+		t.Skip("base64 command not available")
+	}
+
+	// Stand in for an encryption tool with a reversible external command
+	journalCodecs[".b64"] = func(*Config) (generator.Codec, error) {
+		return &execCodec{command: "base64", decodeArgs: []string{"-d"}, encodeArgs: []string{"-w0"}}, nil
+	}
+	defer delete(journalCodecs, ".b64")
+
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir, FrontmatterDateKey: "title"}
+	sourceFile := filepath.Join(tempDir, "2024-01-01.md.b64")
+	targetFile := filepath.Join(tempDir, "2024-01-02.md.b64")
+	plain := "---\ntitle: 2024-01-01\n---\n\n## Todos\n\n- [[2024-01-01]]\n  - [ ] Secret task\n  - [x] Done task\n"
+	createTestFile(t, sourceFile, base64.StdEncoding.EncodeToString([]byte(plain)))
+
+	if date, ok := journalDateFromPath(sourceFile); !ok || date != "2024-01-01" {
+		t.Errorf("journalDateFromPath(%q) = %q, %v", sourceFile, date, ok)
+	}
+
+	_, err := processJournal(context.Background(), sourceFile, targetFile, "", "2024-01-02", false, core.DayRange{}, config, NewLogger(ModeQuiet))
+	if err != nil {
+		t.Fatalf("processJournal() unexpected error: %v", err)
+	}
+
+	raw, err := os.ReadFile(targetFile)
+	if err != nil {
+		t.Fatalf("Failed to read target file: %v", err)
+	}
+	if strings.Contains(string(raw), "Secret task") {
+		t.Error("target file was written without encoding")
+	}
+	target, err := readJournalFile(storage.NewLocal(""), targetFile, config)
+	if err != nil {
+		t.Fatalf("readJournalFile() error: %v", err)
+	}
+	if !strings.Contains(string(target), "Secret task") {
+		t.Errorf("target file does not carry the open task:\n%s", target)
+	}
+
+	source, err := readJournalFile(storage.NewLocal(""), sourceFile, config)
+	if err != nil {
+		t.Fatalf("readJournalFile() error: %v", err)
+	}
+	if strings.Contains(string(source), "Secret task") || !strings.Contains(string(source), "Done task") {
+		t.Errorf("source file was not updated through the codec:\n%s", source)
+	}
+}
+
+func TestEncodedJournals_OutputsAndBacklog(t *testing.T) {
+	if _, err := exec.LookPath("base64"); err != nil {
+		t.Skip("base64 command not available")
+	}
+
+	journalCodecs[".b64"] = func(*Config) (generator.Codec, error) {
+		return &execCodec{command: "base64", decodeArgs: []string{"-d"}, encodeArgs: []string{"-w0"}}, nil
+	}
+	defer delete(journalCodecs, ".b64")
+
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	templateFile := filepath.Join(tempDir, "archive-template.md")
+	createTestFile(t, templateFile, "## {{.Date}}\n{{.TODOS}}\n")
+	config := &Config{
+		RootDir:     tempDir,
+		MaxCarry:    1,
+		BacklogFile: "backlog.md",
+		Outputs: map[string]OutputConfig{
+			"archive": {Path: "archive.md", Template: templateFile, Select: "completed", Append: true},
+			"open":    {Path: "open.md", Select: "open"},
+		},
+	}
+	sourceFile := filepath.Join(tempDir, "2024-01-01.md.b64")
+	plain := "---\ntitle: 2024-01-01\n---\n\n## Todos\n\n- [[2024-01-01]]\n  - [ ] Secret task\n  - [ ] Secret overflow\n  - [x] Secret done\n"
+
+	// The outputs and the backlog are encoded like the journal, twice for the appended archive
+	for i := 0; i < 2; i++ {
+		createTestFile(t, sourceFile, base64.StdEncoding.EncodeToString([]byte(plain)))
+		summary, err := processJournal(context.Background(), sourceFile, filepath.Join(tempDir, "2024-01-02.md.b64"), "", "2024-01-02", true, core.DayRange{}, config, NewLogger(ModeQuiet))
+		if err != nil {
+			t.Fatalf("processJournal() unexpected error: %v", err)
+		}
+		if summary.Backlog != filepath.Join(tempDir, "backlog.md.b64") {
+			t.Errorf("Backlog = %q, want the encoded backlog", summary.Backlog)
+		}
+	}
+	for _, name := range []string{"archive.md", "open.md", "backlog.md"} {
+		if _, err := os.Stat(filepath.Join(tempDir, name)); err == nil {
+			t.Errorf("%s written as plain text", name)
+		}
+		raw, err := os.ReadFile(filepath.Join(tempDir, name+".b64"))
+		if err != nil {
+			t.Fatalf("%s not written encoded: %v", name, err)
+		}
+		if strings.Contains(string(raw), "Secret") {
+			t.Errorf("%s.b64 holds plain text", name)
+		}
+	}
+	archive, err := readJournalFile(storage.NewLocal(""), filepath.Join(tempDir, "archive.md.b64"), config)
+	if err != nil {
+		t.Fatalf("readJournalFile() error: %v", err)
+	}
+	if strings.Count(string(archive), "Secret done") != 2 {
+		t.Errorf("archive.md.b64 =\n%s\nwant the completed todo appended twice", archive)
+	}
+	_, backlog, err := readBacklog(storage.NewLocal(tempDir), config)
+	if err != nil || len(backlogEntries(backlog)) != 1 {
+		t.Errorf("readBacklog() = %v, %v; want the overflow in the encoded backlog", backlog, err)
+	}
+
+	// Todos of an encoded journal do not go into a plain text backlog
+	os.Remove(filepath.Join(tempDir, "backlog.md.b64"))
+	createTestFile(t, filepath.Join(tempDir, "backlog.md"), "# Backlog\n\n## Todos\n\n")
+	createTestFile(t, sourceFile, base64.StdEncoding.EncodeToString([]byte(plain)))
+	if _, err := processJournal(context.Background(), sourceFile, filepath.Join(tempDir, "2024-01-02.md.b64"), "", "2024-01-02", true, core.DayRange{}, config, NewLogger(ModeQuiet)); err == nil {
+		t.Error("processJournal() into a plain text backlog: expected error")
+	}
+	if backlog, _ := os.ReadFile(filepath.Join(tempDir, "backlog.md")); strings.Contains(string(backlog), "Secret") {
+		t.Errorf("plain text backlog = %q", backlog)
+	}
+}
+
+func TestCodecForPath(t *testing.T) {
+	config := &Config{}
+
+	if codec, err := codecForPath("2024-01-01.md", config); codec != nil || err != nil {
+		t.Errorf("codecForPath() for plain markdown = %v, %v", codec, err)
+	}
+	if _, err := codecForPath("2024-01-01.md.age", config); err == nil {
+		t.Error("codecForPath() expected error for age journal without recipients")
+	}
+	if _, err := codecForPath("2024-01-01.md.gpg", config); err == nil {
+		t.Error("codecForPath() expected error for gpg journal without recipients")
+	}
+
+	config.Encryption = EncryptionConfig{AgeRecipients: []string{"age1example"}, AgeIdentity: "key.txt"}
+	codec, err := codecForPath("2024-01-01.md.age", config)
+	if err != nil {
+		t.Fatalf("codecForPath() unexpected error: %v", err)
+	}
+	age := codec.(*execCodec)
+	if age.command != "age" || strings.Join(age.encodeArgs, " ") != "--encrypt --recipient age1example" {
+		t.Errorf("age codec = %+v", age)
+	}
+}
+
+func TestValidateFilePath(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	tests := []struct {
+		name        string
+		path        string
+		expectError bool
+	}{
+		{
+			name:        "empty path",
+			path:        "",
+			expectError: true,
+		},
+		{
+			name:        "directory traversal",
+			path:        "../../../etc/passwd",
+			expectError: true,
+		},
+		{
+			name:        "valid relative path",
+			path:        "test.md",
+			expectError: false,
+		},
+		{
+			name:        "valid absolute path in temp dir",
+			path:        filepath.Join(tempDir, "test.md"),
+			expectError: false,
+		},
+		{
+			name:        "path with non-existent parent",
+			path:        filepath.Join(tempDir, "subdir/test.md"),
+			expectError: false, // Should be valid since parent can potentially be created
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateFilePath(tt.path)
+			if (err != nil) != tt.expectError {
+				t.Errorf("validateFilePath() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestValidateDateFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		date        string
+		expectError bool
+	}{
+		{
+			name:        "empty date",
+			date:        "",
+			expectError: false,
+		},
+		{
+			name:        "valid date",
+			date:        "2024-01-15",
+			expectError: false,
+		},
+		{
+			name:        "invalid format",
+			date:        "01/15/2024",
+			expectError: true,
+		},
+		{
+			name:        "invalid date",
+			date:        "2024-13-32",
+			expectError: true,
+		},
+		{
+			name:        "incomplete date",
+			date:        "2024-01",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateDateFormat(tt.date)
+			if (err != nil) != tt.expectError {
+				t.Errorf("validateDateFormat() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestValidateProcessArgs(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	sourceFile := filepath.Join(tempDir, "source.md")
+	targetFile := filepath.Join(tempDir, "target.md")
+
+	tests := []struct {
+		name         string
+		sourceFile   string
+		targetFile   string
+		templateDate string
+		expectError  bool
+	}{
+		{
+			name:        "valid arguments",
+			sourceFile:  sourceFile,
+			targetFile:  targetFile,
+			expectError: false,
+		},
+		{
+			name:         "valid with template date",
+			sourceFile:   sourceFile,
+			targetFile:   targetFile,
+			templateDate: "2024-01-15",
+			expectError:  false,
+		},
+		{
+			name:        "same source and target",
+			sourceFile:  sourceFile,
+			targetFile:  sourceFile,
+			expectError: true,
+		},
+		{
+			name:         "invalid template date",
+			sourceFile:   sourceFile,
+			targetFile:   targetFile,
+			templateDate: "invalid",
+			expectError:  true,
+		},
+		{
+			name:        "empty source",
+			sourceFile:  "",
+			targetFile:  targetFile,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateProcessArgs(tt.sourceFile, tt.targetFile, tt.templateDate)
+			if (err != nil) != tt.expectError {
+				t.Errorf("validateProcessArgs() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	// Create a valid template file for testing
+	validTemplateFile := filepath.Join(tempDir, "valid_template.md")
+	createTestFile(t, validTemplateFile, "# Test Template\n## Todos\n{{.TODOS}}")
+
+	tests := []struct {
+		name        string
+		config      *Config
+		expectError bool
+		errorType   error
+	}{
+		{
+			name:        "nil config",
+			config:      nil,
+			expectError: true,
+			errorType:   ErrInvalidConfig,
+		},
+		{
+			name: "valid config",
+			config: &Config{
+				RootDir: tempDir,
+			},
+			expectError: false,
+		},
+		{
+			name: "empty root dir",
+			config: &Config{
+				RootDir: "",
+			},
+			expectError: true,
+			errorType:   ErrInvalidConfig,
+		},
+		{
+			name: "webdav root dir",
+			config: &Config{
+				RootDir: "https://dav.example.com/journal",
+			},
+			expectError: false,
+		},
+		{
+			name: "unsupported root dir scheme",
+			config: &Config{
+				RootDir: "ftp://example.com/journal",
+			},
+			expectError: true,
+			errorType:   ErrInvalidConfig,
+		},
+		{
+			name: "s3 root dir without bucket",
+			config: &Config{
+				RootDir: "s3:///journal",
+			},
+			expectError: true,
+			errorType:   ErrInvalidConfig,
+		},
+		{
+			name: "valid config with template",
+			config: &Config{
+				RootDir:      tempDir,
+				TemplateFile: validTemplateFile,
+			},
+			expectError: false,
+		},
+		{
+			name: "config with invalid root dir path",
+			config: &Config{
+				RootDir: "../../../etc",
+			},
+			expectError: true,
+			errorType:   ErrInvalidPath,
+		},
+		{
+			name: "config with nonexistent template file",
+			config: &Config{
+				RootDir:      tempDir,
+				TemplateFile: filepath.Join(tempDir, "nonexistent.md"),
+			},
+			expectError: true,
+			errorType:   ErrTemplateNotFound,
+		},
+		{
+			name: "config with directory as template file",
+			config: &Config{
+				RootDir:      tempDir,
+				TemplateFile: tempDir, // Directory instead of file
+			},
+			expectError: true,
+			errorType:   ErrInvalidConfig,
+		},
+		{
+			name: "config with valid custom variables",
+			config: &Config{
+				RootDir: tempDir,
+				Custom: map[string]interface{}{
+					"author":  "John Doe",
+					"project": "Test Project",
+					"version": 1,
+					"active":  true,
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "config with reserved custom variable name",
+			config: &Config{
+				RootDir: tempDir,
+				Custom: map[string]interface{}{
+					"Date": "invalid", // Reserved name
+				},
+			},
+			expectError: true,
+			errorType:   ErrInvalidConfig,
+		},
+		{
+			name: "config with invalid custom variable name",
+			config: &Config{
+				RootDir: tempDir,
+				Custom: map[string]interface{}{
+					"123invalid": "value", // Invalid name starting with number
+				},
+			},
+			expectError: true,
+			errorType:   ErrInvalidConfig,
+		},
+		{
+			name: "day header without a date",
+			config: &Config{
+				RootDir:   tempDir,
+				DayHeader: "### Today",
+			},
+			expectError: true,
+			errorType:   ErrInvalidConfig,
+		},
+		{
+			name: "unknown dependency policy",
+			config: &Config{
+				RootDir:          tempDir,
+				DependencyPolicy: "block",
+			},
+			expectError: true,
+			errorType:   ErrInvalidConfig,
+		},
+		{
+			name: "rollover hour out of range",
+			config: &Config{
+				RootDir:         tempDir,
+				DayRolloverHour: 24,
+			},
+			expectError: true,
+			errorType:   ErrInvalidConfig,
+		},
+		{
+			name: "unknown date locale",
+			config: &Config{
+				RootDir:    tempDir,
+				DateLocale: "tlh",
+			},
+			expectError: true,
+			errorType:   ErrInvalidConfig,
+		},
+		{
+			name: "week start not a weekday",
+			config: &Config{
+				RootDir:   tempDir,
+				WeekStart: "mon",
+			},
+			expectError: true,
+			errorType:   ErrInvalidConfig,
+		},
+		{
+			name: "unknown timezone",
+			config: &Config{
+				RootDir:  tempDir,
+				Timezone: "Mars/Olympus_Mons",
+			},
+			expectError: true,
+			errorType:   ErrInvalidConfig,
+		},
+		{
+			name: "indentation too wide",
+			config: &Config{
+				RootDir:      tempDir,
+				IndentSpaces: 9,
+			},
+			expectError: true,
+			errorType:   ErrInvalidConfig,
+		},
+		{
+			name: "config with unsupported custom variable type",
+			config: &Config{
+				RootDir: tempDir,
+				Custom: map[string]interface{}{
+					"complex": complex(1, 2), // Unsupported type
+				},
+			},
+			expectError: true,
+			errorType:   ErrInvalidConfig,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateConfig(tt.config)
+			if (err != nil) != tt.expectError {
+				t.Errorf("validateConfig() error = %v, expectError %v", err, tt.expectError)
+				return
+			}
+
+			if tt.expectError && tt.errorType != nil {
+				if !errors.Is(err, tt.errorType) {
+					t.Errorf("validateConfig() error type = %T, expected error type %T", err, tt.errorType)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateCustomVariables(t *testing.T) {
+	tests := []struct {
+		name        string
+		custom      map[string]interface{}
+		expectError bool
+	}{
+		{
+			name:        "nil custom variables",
+			custom:      nil,
+			expectError: false,
+		},
+		{
+			name:        "empty custom variables",
+			custom:      map[string]interface{}{},
+			expectError: false,
+		},
+		{
+			name: "valid custom variables",
+			custom: map[string]interface{}{
+				"author":    "John Doe",
+				"project":   "Test",
+				"version":   1,
+				"active":    true,
+				"tags":      []string{"test", "demo"},
+				"numbers":   []int{1, 2, 3},
+				"mixed":     []interface{}{"string", 42, true},
+				"_private":  "value",
+				"CamelCase": "value",
+			},
+			expectError: false,
+		},
+		{
+			name: "reserved variable name",
+			custom: map[string]interface{}{
+				"TODOS": "invalid",
+			},
+			expectError: true,
+		},
+		{
+			name: "invalid variable name - starts with number",
+			custom: map[string]interface{}{
+				"123invalid": "value",
+			},
+			expectError: true,
+		},
+		{
+			name: "invalid variable name - contains special chars",
+			custom: map[string]interface{}{
+				"test-value": "value",
+			},
+			expectError: true,
+		},
+		{
+			name: "unsupported type - complex number",
+			custom: map[string]interface{}{
+				"complex": complex(1, 2),
+			},
+			expectError: true,
+		},
+		{
+			name: "unsupported type - map",
+			custom: map[string]interface{}{
+				"nested": map[string]string{"key": "value"},
+			},
+			expectError: true,
+		},
+		{
+			name: "unsupported type in array",
+			custom: map[string]interface{}{
+				"mixed": []interface{}{"valid", complex(1, 2)},
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCustomVariables(tt.custom)
+			if (err != nil) != tt.expectError {
+				t.Errorf("validateCustomVariables() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestIsValidVariableName(t *testing.T) {
+	tests := []struct {
+		name     string
+		varName  string
+		expected bool
+	}{
+		{"empty string", "", false},
+		{"valid simple name", "test", true},
+		{"valid with underscore", "test_var", true},
+		{"valid starting with underscore", "_private", true},
+		{"valid with numbers", "var123", true},
+		{"invalid starting with number", "123var", false},
+		{"invalid with dash", "test-var", false},
+		{"invalid with space", "test var", false},
+		{"invalid with special chars", "test@var", false},
+		{"valid camelCase", "camelCase", true},
+		{"valid PascalCase", "PascalCase", true},
+		{"valid UPPERCASE", "UPPERCASE", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := isValidVariableName(tt.varName)
+			if result != tt.expected {
+				t.Errorf("isValidVariableName(%q) = %v, expected %v", tt.varName, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIsValidVariableType(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected bool
+	}{
+		{"string", "test", true},
+		{"int", 42, true},
+		{"int32", int32(42), true},
+		{"int64", int64(42), true},
+		{"float32", float32(3.14), true},
+		{"float64", 3.14, true},
+		{"bool", true, true},
+		{"string slice", []string{"a", "b"}, true},
+		{"int slice", []int{1, 2, 3}, true},
+		{"interface slice with valid types", []interface{}{"string", 42, true}, true},
+		{"interface slice with invalid type", []interface{}{"string", complex(1, 2)}, false},
+		{"complex number", complex(1, 2), false},
+		{"map", map[string]string{"key": "value"}, false},
+		{"struct", struct{ Name string }{Name: "test"}, false},
+		{"nil", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := isValidVariableType(tt.value)
+			if result != tt.expected {
+				t.Errorf("isValidVariableType(%v) = %v, expected %v", tt.value, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestProcessJournal_Summary(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	sourceContent := `---
+title: 2024-01-01
+---
+
+## Todos
+
+- [[2024-01-01]]
+  - [ ] Task 1
+  - [x] Completed task
+  - [ ] Task 2
+`
+
+	sourceFile := filepath.Join(tempDir, "source.md")
+	targetFile := filepath.Join(tempDir, "target.md")
+	createTestFile(t, sourceFile, sourceContent)
+
+	config := &Config{RootDir: tempDir}
+
+	summary, err := processJournal(context.Background(), sourceFile, targetFile, "", "2024-01-02", false, core.DayRange{}, config, NewLogger(ModeQuiet))
+	if err != nil {
+		t.Fatalf("processJournal() unexpected error: %v", err)
+	}
+
+	if summary.Source != sourceFile || summary.Target != targetFile {
+		t.Errorf("summary paths = %q -> %q, want %q -> %q", summary.Source, summary.Target, sourceFile, targetFile)
+	}
+	if summary.Backup != sourceFile+".bak" {
+		t.Errorf("summary.Backup = %q, want %q", summary.Backup, sourceFile+".bak")
+	}
+	if summary.CarriedTodos != 2 {
+		t.Errorf("summary.CarriedTodos = %d, want 2", summary.CarriedTodos)
+	}
+	if summary.CompletedTodos != 1 {
+		t.Errorf("summary.CompletedTodos = %d, want 1", summary.CompletedTodos)
+	}
+	if summary.Template == "" {
+		t.Error("summary.Template should name the template source")
+	}
+}
+
+func TestProcessJournal_ExitCodes(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	sourceFile := filepath.Join(tempDir, "source.md")
+	createTestFile(t, sourceFile, "---\ntitle: 2024-01-01\n---\n\n## Todos\n\n- [[2024-01-01]]\n  - [ ] Task\n")
+	config := &Config{RootDir: tempDir}
+	logger := NewLogger(ModeQuiet)
+
+	tests := []struct {
+		name         string
+		sourceFile   string
+		targetFile   string
+		templateFile string
+		wantCode     int
+	}{
+		{
+			name:       "missing source file",
+			sourceFile: filepath.Join(tempDir, "missing.md"),
+			targetFile: filepath.Join(tempDir, "target.md"),
+			wantCode:   ExitFailure,
+		},
+		{
+			name:         "missing template file",
+			sourceFile:   sourceFile,
+			targetFile:   filepath.Join(tempDir, "target.md"),
+			templateFile: filepath.Join(tempDir, "missing-template.md"),
+			wantCode:     ExitConfigError,
+		},
+		{
+			name:       "unwritable target",
+			sourceFile: sourceFile,
+			targetFile: filepath.Join(tempDir, "no-such-dir", "target.md"),
+			wantCode:   ExitWriteError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := processJournal(context.Background(), tt.sourceFile, tt.targetFile, tt.templateFile, "2024-01-02", false, core.DayRange{}, config, logger)
+			if err == nil {
+				t.Fatal("processJournal() expected error, got none")
+			}
+			if code := exitCodeFor(err); code != tt.wantCode {
+				t.Errorf("exitCodeFor() = %d, want %d (err: %v)", code, tt.wantCode, err)
+			}
+		})
+	}
+}
+
+func TestProcessJournal_JournalError(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	sourceFile := filepath.Join(tempDir, "source.md")
+	createTestFile(t, sourceFile, "---\ntitle: 2024-01-01\n---\n\n## Todos\n\n- [[2024-01-01]]\n  - [ ] Task\n- [[2024-13-01]]\n")
+	config := &Config{RootDir: tempDir}
+
+	_, err := processJournal(context.Background(), sourceFile, filepath.Join(tempDir, "target.md"), "", "2024-01-02", true, core.DayRange{}, config, NewLogger(ModeQuiet))
+	if code := exitCodeFor(err); code != ExitParseError {
+		t.Fatalf("exitCodeFor() = %d, want %d (err: %v)", code, ExitParseError, err)
+	}
+	if want := sourceFile + ":9:1: invalid date in day header: invalid date '- [[2024-13-01]]', expected - [[2006-01-02]]"; err.Error() != want {
+		t.Errorf("processJournal() error = %q, want %q", err.Error(), want)
+	}
+	if got, want := journalErrorContext(err), "    9 | - [[2024-13-01]]\n      | ^"; got != want {
+		t.Errorf("journalErrorContext() = %q, want %q", got, want)
+	}
+}
+
+func TestProcessJournal_Warnings(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	sourceFile := filepath.Join(tempDir, "source.md")
+	createTestFile(t, sourceFile, "---\ntitle: 2024-01-01\n---\n\n## Todos\n\nStray note\n- [[2024-01-01]]\n  - [ ] Task\n[ ] Broken\n")
+	config := &Config{RootDir: tempDir}
+	targetFile := filepath.Join(tempDir, "target.md")
+
+	summary, err := processJournal(context.Background(), sourceFile, targetFile, "", "2024-01-02", true, core.DayRange{}, config, NewLogger(ModeQuiet))
+	if err != nil {
+		t.Fatalf("processJournal() unexpected error: %v", err)
+	}
+	want := []string{
+		sourceFile + `:7: "Stray note" comes before the first day header and is dropped`,
+		sourceFile + `:10: "[ ] Broken" is not a todo or note and is kept with the todo above`,
+	}
+	if !reflect.DeepEqual(summary.Warnings, want) {
+		t.Errorf("Warnings = %q, want %q", summary.Warnings, want)
+	}
+	if target, _ := os.ReadFile(targetFile); !strings.Contains(string(target), "  - [ ] Task created::[[2024-01-01]]\n    [ ] Broken\n") {
+		t.Errorf("target = %q, want the unknown line kept with its task", target)
+	}
+}
+
+func TestProcessJournal_Outputs(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	templateFile := filepath.Join(tempDir, "archive-template.md")
+	createTestFile(t, templateFile, "## {{.Date}}\n{{.TODOS}}\n")
+	config := &Config{
+		RootDir: tempDir,
+		Outputs: map[string]OutputConfig{
+			"archive": {Path: "archive/done.md", Template: templateFile, Select: "completed", Append: true},
+			"open":    {Path: "open.md", Select: "open"},
+		},
+	}
+
+	for i, date := range []string{"2024-01-01", "2024-01-02"} {
+		sourceFile := filepath.Join(tempDir, date+".md")
+		createTestFile(t, sourceFile, "---\ntitle: "+date+"\n---\n\n## Todos\n\n- [["+date+"]]\n  - [ ] Open "+date+"\n  - [x] Done "+date+"\n")
+		summary, err := processJournal(context.Background(), sourceFile, filepath.Join(tempDir, "target.md"), "", "2024-01-0"+fmt.Sprint(i+2), true, core.DayRange{}, config, NewLogger(ModeQuiet))
+		if err != nil {
+			t.Fatalf("processJournal() unexpected error: %v", err)
+		}
+		if len(summary.Outputs) != 2 {
+			t.Errorf("Outputs = %v, want both outputs", summary.Outputs)
+		}
+	}
+
+	archive, err := os.ReadFile(filepath.Join(tempDir, "archive", "done.md"))
+	if err != nil {
+		t.Fatalf("archive not written: %v", err)
+	}
+	want := "## 2024-01-02\n- [[2024-01-01]]\n  - [x] Done 2024-01-01 #2024-01-01\n## 2024-01-03\n- [[2024-01-02]]\n  - [x] Done 2024-01-02 #2024-01-02\n"
+	if string(archive) != want {
+		t.Errorf("archive =\n%s\nwant\n%s", archive, want)
+	}
+	open, err := os.ReadFile(filepath.Join(tempDir, "open.md"))
+	if err != nil || string(open) != "- [[2024-01-02]]\n  - [ ] Open 2024-01-02 created::[[2024-01-02]]\n" {
+		t.Errorf("open.md = %q, %v; want only the todos of the last run", open, err)
+	}
+
+	config.Outputs["open"] = OutputConfig{Path: "open.md", Select: "overdue"}
+	if err := validateConfig(config); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("validateConfig() with an unknown selection: error = %v, want ErrInvalidConfig", err)
+	}
+}
+
+func TestProcessJournal_Assignee(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{
+		RootDir:  tempDir,
+		Assignee: "alice",
+		Outputs: map[string]OutputConfig{
+			"people": {Path: "carried/{assignee}.md", Select: "open", SplitByAssignee: true},
+		},
+	}
+	sourceFile := buildJournalPath(tempDir, "2025-06-19")
+	targetFile := buildJournalPath(tempDir, "2025-06-20")
+	createTestFile(t, sourceFile, `---
+title: 2025-06-19
+---
+
+## Todos
+
+- [[2025-06-19]]
+  - [ ] Review PR @alice
+  - [ ] Deploy @bob
+  - [x] Standup notes @alice
+  - [ ] Unassigned
+`)
+	summary, err := processJournal(context.Background(), sourceFile, targetFile, "", "2025-06-20", false, core.DayRange{}, config, NewLogger(ModeQuiet))
+	if err != nil {
+		t.Fatalf("processJournal() unexpected error: %v", err)
+	}
+
+	target, _ := os.ReadFile(targetFile)
+	if !strings.Contains(string(target), "- [ ] Review PR @alice") || strings.Contains(string(target), "Deploy") {
+		t.Errorf("target carries the todos of others:\n%s", target)
+	}
+	source, _ := os.ReadFile(sourceFile)
+	if !strings.Contains(string(source), "  - [ ] Deploy @bob\n  - [x] Standup notes @alice #2025-06-19\n  - [ ] Unassigned") {
+		t.Errorf("source does not keep the todos of others:\n%s", source)
+	}
+	mine, err := os.ReadFile(filepath.Join(tempDir, "carried", "alice.md"))
+	if err != nil || string(mine) != "- [[2025-06-19]]\n  - [ ] Review PR @alice created::[[2025-06-19]]\n" {
+		t.Errorf("carried/alice.md = %q, %v", mine, err)
+	}
+	if len(summary.Outputs) != 1 {
+		t.Errorf("Outputs = %v, want only the output of alice", summary.Outputs)
+	}
+
+	var out bytes.Buffer
+	indexPath := filepath.Join(tempDir, "cache", IndexCacheFile)
+	if err := cmdStats(&out, tempDir, indexPath, "2025-06-20", OutputJSON, 0, "@Alice", config, NewLogger(ModeQuiet)); err != nil {
+		t.Fatalf("cmdStats() unexpected error: %v", err)
+	}
+	var stats statsEntry
+	if err := json.Unmarshal(out.Bytes(), &stats); err != nil || stats.CompletedTodos != 1 {
+		t.Errorf("cmdStats() of alice = %s, %v; want one completed todo", out.String(), err)
+	}
+	if err := cmdStats(&out, tempDir, indexPath, "2025-06-20", OutputJSON, 0, "bob smith", config, NewLogger(ModeQuiet)); exitCodeFor(err) != ExitConfigError {
+		t.Errorf("cmdStats() with an invalid assignee error = %v, want a config error", err)
+	}
+
+	config.Outputs["people"] = OutputConfig{Path: "carried.md", Select: "open", SplitByAssignee: true}
+	if err := validateConfig(config); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("validateConfig() with a split output without {assignee}: error = %v, want ErrInvalidConfig", err)
+	}
+
+	if _, err := exec.LookPath(DefaultSQLite); err != nil {
+		return
+	}
+	out.Reset()
+	query := "SELECT count(*) AS tasks FROM tasks"
+	if err := cmdQuery(&out, tempDir, "", query, OutputJSON, "bob", config); err != nil {
+		t.Fatalf("cmdQuery() unexpected error: %v", err)
+	}
+	if got := strings.TrimSpace(out.String()); got != `[{"tasks":1}]` {
+		t.Errorf("cmdQuery() of bob = %s", got)
+	}
+	out.Reset()
+	query = "SELECT assignee, count(*) AS tasks FROM assignees GROUP BY assignee"
+	if err := cmdQuery(&out, tempDir, "", query, OutputJSON, "", config); err != nil {
+		t.Fatalf("cmdQuery() unexpected error: %v", err)
+	}
+	if got := strings.ReplaceAll(strings.TrimSpace(out.String()), "\n", ""); got != `[{"assignee":"alice","tasks":2},{"assignee":"bob","tasks":1}]` {
+		t.Errorf("cmdQuery() of the assignees table = %s", got)
+	}
+}
+
+func TestReadConfig_LocalConfig(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+	t.Setenv("TODOER_ROOT_DIR", "")
+	t.Setenv("TODOER_TEMPLATE_FILE", "")
+
+	vault := filepath.Join(tempDir, "vault")
+	configPath := filepath.Join(tempDir, ConfigFileName)
+	createTestFile(t, configPath, `root_dir = "`+vault+`"
+todos_header = "## Global"
+max_carry = 3
+`)
+	createTestFile(t, filepath.Join(vault, LocalConfigFile), `todos_header = "## Tasks"
+template_file = "templates/daily.md"
+`)
+
+	loaded, err := readConfig(configPath, localLookup{})
+	if err != nil {
+		t.Fatalf("readConfig() unexpected error: %v", err)
+	}
+	config := loaded.Config
+	if config.TodosHeader != "## Tasks" || config.MaxCarry != 3 {
+		t.Errorf("TodosHeader = %q, MaxCarry = %d, want the local header and the global carry limit", config.TodosHeader, config.MaxCarry)
+	}
+	if want := filepath.Join(vault, "templates", "daily.md"); config.TemplateFile != want {
+		t.Errorf("TemplateFile = %q, want %q relative to the local config file", config.TemplateFile, want)
+	}
+	if loaded.LocalPath != filepath.Join(vault, LocalConfigFile) || loaded.Sources["todos_header"] != sourceLocalFile || loaded.Sources["max_carry"] != sourceFile {
+		t.Errorf("LocalPath = %q, Sources = %v", loaded.LocalPath, loaded.Sources)
+	}
+
+	// Environment variables override the local config file
+	t.Setenv("TODOER_TEMPLATE_FILE", "/env/template.md")
+	loaded, err = readConfig(configPath, localLookup{})
+	if err != nil {
+		t.Fatalf("readConfig() unexpected error: %v", err)
+	}
+	if loaded.Config.TemplateFile != "/env/template.md" {
+		t.Errorf("TemplateFile = %q, want the environment value", loaded.Config.TemplateFile)
+	}
+
+	// The nearest local config file above a journal wins over the root's
+	other := filepath.Join(tempDir, "other")
+	createTestFile(t, filepath.Join(other, LocalConfigFile), "todos_header = \"## Other\"\nbogus = 1\n")
+	journal := filepath.Join(other, "2025", "06", "2025-06-20.md")
+	createTestFile(t, journal, "")
+	loaded, err = readConfig(configPath, localLookup{File: journal})
+	if err != nil {
+		t.Fatalf("readConfig() unexpected error: %v", err)
+	}
+	if loaded.Config.TodosHeader != "## Other" {
+		t.Errorf("TodosHeader = %q, want %q", loaded.Config.TodosHeader, "## Other")
+	}
+	if want := []string{"bogus in " + filepath.Join(other, LocalConfigFile)}; !reflect.DeepEqual(loaded.Unknown, want) {
+		t.Errorf("Unknown = %v, want %v", loaded.Unknown, want)
+	}
+
+	// A root directory given on the command line is searched instead of the configured one
+	loaded, err = readConfig(configPath, localLookup{RootDir: other})
+	if err != nil {
+		t.Fatalf("readConfig() unexpected error: %v", err)
+	}
+	if loaded.Config.TodosHeader != "## Other" {
+		t.Errorf("TodosHeader = %q, want %q", loaded.Config.TodosHeader, "## Other")
+	}
+}
+
+func TestSelectTemplate(t *testing.T) {
+	config := &Config{
+		TemplateFile: "fallback.md",
+		Templates: map[string]string{
+			"monday":       "weekly-plan.md",
+			"fri":          "review.md",
+			"last-workday": "month-review.md",
+			"2025-12-24":   "holiday.md",
+			"weekend":      "weekend.md",
+		},
+	}
+	tests := []struct {
+		date string
+		want string
+	}{
+		{"2025-06-16", "weekly-plan.md"},  // Monday
+		{"2025-06-17", "fallback.md"},     // Tuesday, no rule
+		{"2025-06-20", "review.md"},       // Friday
+		{"2025-06-21", "weekend.md"},      // Saturday
+		{"2025-06-30", "month-review.md"}, // Monday, last workday of June
+		{"2025-05-30", "month-review.md"}, // Friday, last workday of May
+		{"2025-05-31", "weekend.md"},      // Saturday, last day of May
+		{"2025-12-24", "holiday.md"},      // A date beats everything
+	}
+	for _, tt := range tests {
+		got, err := selectTemplate(config, tt.date)
+		if err != nil || got != tt.want {
+			t.Errorf("selectTemplate(%s) = %q, %v, want %q", tt.date, got, err, tt.want)
+		}
+	}
+
+	config.Templates = map[string]string{"*": "daily.md", "first-of-month": "month.md", "first-workday": "kickoff.md"}
+	for date, want := range map[string]string{
+		"2025-06-02": "kickoff.md", // Monday, the 1st was a Sunday
+		"2025-06-01": "month.md",
+		"2025-06-03": "daily.md",
+		"2025-07-01": "kickoff.md", // Both rules match; the first workday ranks higher
+	} {
+		if got, _ := selectTemplate(config, date); got != want {
+			t.Errorf("selectTemplate(%s) = %q, want %q", date, got, want)
+		}
+	}
+
+	if _, err := selectTemplate(config, "not-a-date"); !errors.Is(err, ErrInvalidDate) {
+		t.Errorf("selectTemplate() with an invalid date: error = %v, want ErrInvalidDate", err)
+	}
+	if err := validateTemplateRules(map[string]string{"payday": "pay.md"}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("validateTemplateRules() with an unknown rule: error = %v, want ErrInvalidConfig", err)
+	}
+}
+
+func TestReadConfig_TemplateRules(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+	t.Setenv("TODOER_ROOT_DIR", "")
+	t.Setenv("TODOER_TEMPLATE_FILE", "")
+
+	configPath := filepath.Join(tempDir, ConfigFileName)
+	createTestFile(t, configPath, `[templates]
+monday = "weekly-plan.md"
+"*" = "/abs/daily.md"
+`)
+	loaded, err := readConfig(configPath, localLookup{})
+	if err != nil {
+		t.Fatalf("readConfig() unexpected error: %v", err)
+	}
+	want := map[string]string{"monday": filepath.Join(tempDir, "weekly-plan.md"), "*": "/abs/daily.md"}
+	if !reflect.DeepEqual(loaded.Config.Templates, want) {
+		t.Errorf("Templates = %v, want %v", loaded.Config.Templates, want)
+	}
+
+	// A template given in the environment overrides the rules
+	t.Setenv("TODOER_TEMPLATE_FILE", "/env/template.md")
+	loaded, err = readConfig(configPath, localLookup{})
+	if err != nil {
+		t.Fatalf("readConfig() unexpected error: %v", err)
+	}
+	if got, _ := selectTemplate(loaded.Config, "2025-06-16"); got != "/env/template.md" {
+		t.Errorf("selectTemplate() = %q, want the environment template", got)
+	}
+}
+
+func TestCmdConfig(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	configPath := filepath.Join(tempDir, ConfigFileName)
+	createTestFile(t, configPath, `root_dir = "`+tempDir+`"
+max_carry = 5
+colour = "blue"
+secret_vars = ["Token"]
+
+[notify]
+ntfy_token = "tk_secret"
+
+[custom_variables]
+Project = "todoer"
+Token = "s3cr3t"
+`)
+	t.Setenv("TODOER_ROOT_DIR", "")
+	t.Setenv("TODOER_TEMPLATE_FILE", "")
+
+	loaded, err := readConfig(configPath, localLookup{})
+	if err != nil {
+		t.Fatalf("readConfig() unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Unknown, []string{"colour"}) {
+		t.Errorf("Unknown = %v, want [colour]", loaded.Unknown)
+	}
+
+	var out bytes.Buffer
+	if err := cmdConfigShow(&out, loaded, "", filepath.Join(tempDir, "t.md")); err != nil {
+		t.Fatalf("cmdConfigShow() unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		`root_dir = "` + tempDir + `" # config file`,
+		`template_file = "` + filepath.Join(tempDir, "t.md") + `" # flag --template-file`,
+		"max_carry = 5 # config file",
+		`backlog_file = "backlog.md" # default`,
+		"[notify]\n",
+		`ntfy_token = "xxxxx" # config file`,
+		"notifiers = [] # default",
+		`Project = "todoer" # config file`,
+		`Token = "xxxxx" # config file`,
+		"# Unknown key colour is ignored",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("cmdConfigShow() output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	err = cmdConfigValidate(&out, loaded)
+	if exitCodeFor(err) != ExitConfigError || !strings.Contains(out.String(), "  - unknown key colour") {
+		t.Errorf("cmdConfigValidate() error = %v, output:\n%s", err, out.String())
+	}
+	loaded.Unknown = nil
+	out.Reset()
+	if err := cmdConfigValidate(&out, loaded); err != nil {
+		t.Errorf("cmdConfigValidate() unexpected error: %v", err)
+	}
+
+	initDir := filepath.Join(tempDir, "todoer")
+	logger := NewLogger(ModeQuiet)
+	out.Reset()
+	if err := cmdConfigInit(&out, initDir, false, logger); err != nil {
+		t.Fatalf("cmdConfigInit() unexpected error: %v", err)
+	}
+	initLoaded, err := readConfig(filepath.Join(initDir, ConfigFileName), localLookup{})
+	if err != nil || len(initLoaded.Unknown) != 0 || len(initLoaded.Sources) != 0 {
+		t.Errorf("scaffolded config.toml does not load as the defaults: %+v, %v", initLoaded, err)
+	}
+	if content, err := os.ReadFile(filepath.Join(initDir, TemplateFileName)); err != nil || string(content) != defaultTemplate {
+		t.Errorf("scaffolded template.md is not the default template: %v", err)
+	}
+
+	createTestFile(t, filepath.Join(initDir, ConfigFileName), "max_carry = 3\n")
+	out.Reset()
+	if err := cmdConfigInit(&out, initDir, false, logger); err != nil {
+		t.Fatalf("cmdConfigInit() unexpected error: %v", err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(initDir, ConfigFileName)); string(content) != "max_carry = 3\n" {
+		t.Error("cmdConfigInit() overwrote an existing config.toml without --force")
+	}
+	if !strings.Contains(out.String(), "Kept existing") {
+		t.Errorf("cmdConfigInit() output = %q", out.String())
+	}
+}
+
+func TestExitCodeFor(t *testing.T) {
+	base := errors.New("boom")
+
+	if code := exitCodeFor(nil); code != ExitOK {
+		t.Errorf("exitCodeFor(nil) = %d, want %d", code, ExitOK)
+	}
+	if code := exitCodeFor(base); code != ExitFailure {
+		t.Errorf("exitCodeFor(plain) = %d, want %d", code, ExitFailure)
+	}
+
+	wrapped := fmt.Errorf("context: %w", withExitCode(ExitParseError, base))
+	if code := exitCodeFor(wrapped); code != ExitParseError {
+		t.Errorf("exitCodeFor(wrapped) = %d, want %d", code, ExitParseError)
+	}
+	if !errors.Is(wrapped, base) {
+		t.Error("withExitCode should preserve the wrapped error")
+	}
+	if withExitCode(ExitParseError, nil) != nil {
+		t.Error("withExitCode(nil) should return nil")
+	}
+}
+
+func TestWriteSummary(t *testing.T) {
+	summary := newResultSummary("process")
+	summary.Source = "in.md"
+	summary.Target = "out.md"
+	summary.Backup = "in.md.bak"
+	summary.CarriedTodos = 3
+
+	var buf bytes.Buffer
+	if err := writeSummary(&buf, summary, OutputJSON, false); err != nil {
+		t.Fatalf("writeSummary() unexpected error: %v", err)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("summary is not valid JSON: %v\n%s", err, buf.String())
+	}
+	if decoded["target"] != "out.md" || decoded["carried_todos"] != float64(3) {
+		t.Errorf("unexpected JSON summary: %s", buf.String())
+	}
+	if _, ok := decoded["warnings"].([]interface{}); !ok {
+		t.Errorf("warnings should always be a JSON array: %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := writeSummary(&buf, summary, OutputText, true); err != nil {
+		t.Fatalf("writeSummary() unexpected error: %v", err)
+	}
+	if buf.String() != "out.md\n" {
+		t.Errorf("print-path output = %q, want %q", buf.String(), "out.md\n")
+	}
+
+	buf.Reset()
+	if err := writeSummary(&buf, summary, OutputText, false); err != nil {
+		t.Fatalf("writeSummary() unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "Backup of original file created: in.md.bak") {
+		t.Errorf("text output missing backup line: %q", buf.String())
+	}
+}
+
+// Benchmark tests
+func BenchmarkExpandPath(b *testing.B) {
+	paths := []string{
+		"/absolute/path",
+		"relative/path",
+		"~/home/path",
+		"",
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, path := range paths {
+			expandPath(path)
+		}
+	}
+}
+
+func BenchmarkResolveTemplate(b *testing.B) {
+	tempDir, err := os.MkdirTemp("", "todoer-bench-*")
+	if err != nil {
+		b.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	templateFile := filepath.Join(tempDir, "template.md")
+	if err := os.WriteFile(templateFile, []byte("Test template content"), 0644); err != nil {
+		b.Fatalf("Failed to create template file: %v", err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		resolveTemplate(templateFile)
+	}
+}
+
+func TestConflictOriginal(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		ok       bool
+	}{
+		{"2025/06/2025-06-21 (conflicted copy).md", "2025/06/2025-06-21.md", true},
+		{"2025/06/2025-06-21 (Alice's conflicted copy 2025-06-22).md", "2025/06/2025-06-21.md", true},
+		{"2025/06/2025-06-21.md (conflicted copy).age", "2025/06/2025-06-21.md.age", true},
+		{"2025/06/2025-06-21.sync-conflict-20250621-101010-ABCDEFG.md", "2025/06/2025-06-21.md", true},
+		{"2025/06/2025-06-21.md", "", false},
+		{"notes (conflicted copy).md", "", false},
+		{"2025/06/2025-06-21.sync-conflict-bad.md", "", false},
+	}
+
+	for _, tt := range tests {
+		original, ok := conflictOriginal(tt.name)
+		if original != tt.original || ok != tt.ok {
+			t.Errorf("conflictOriginal(%q) = %q, %v, want %q, %v", tt.name, original, ok, tt.original, tt.ok)
+		}
+	}
+}
+
+// createConflictedJournal creates the journal for 2025-06-21 below rootDir together
+// with a Dropbox copy that only changes todos and a Syncthing copy that also changes notes.
+func createConflictedJournal(t *testing.T, rootDir string) (journalPath, dropboxCopy, syncthingCopy string) {
+	t.Helper()
+
+	journalPath = buildJournalPath(rootDir, "2025-06-21")
+	createTestFile(t, journalPath, "---\ntitle: 2025-06-21\n---\n\n## Todos\n\n- [[2025-06-21]]\n  - [ ] Write report\n  - [ ] Call Alice\n\n## Notes\n\nMorning notes.\n")
+
+	dropboxCopy = filepath.Join(filepath.Dir(journalPath), "2025-06-21 (conflicted copy).md")
+	createTestFile(t, dropboxCopy, "---\ntitle: 2025-06-21\n---\n\n## Todos\n\n- [[2025-06-21]]\n  - [x] Write report\n  - [ ] Buy milk\n\n## Notes\n\nMorning notes.\n")
+	syncthingCopy = filepath.Join(filepath.Dir(journalPath), "2025-06-21.sync-conflict-20250621-101010-ABCDEFG.md")
+	createTestFile(t, syncthingCopy, "---\ntitle: 2025-06-21\n---\n\n## Todos\n\n- [[2025-06-21]]\n  - [ ] Call Alice\n  - [ ] Book train\n\n## Notes\n\nLaptop notes.\n")
+
+	return journalPath, dropboxCopy, syncthingCopy
+}
+
+func TestConflictWarnings(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir, TodosHeader: "## Todos"}
+	journalPath, _, _ := createConflictedJournal(t, tempDir)
+
+	summary, err := cmdNew(context.Background(), tempDir, "", config, NewLogger(ModeQuiet))
+	if err != nil {
+		t.Fatalf("cmdNew() unexpected error: %v", err)
+	}
+	if len(summary.Warnings) != 1 || !strings.Contains(summary.Warnings[0], "2 conflicting copies") {
+		t.Errorf("cmdNew() warnings = %v, want one warning about 2 conflicting copies", summary.Warnings)
+	}
+
+	targetFile := filepath.Join(tempDir, "target.md")
+	summary, err = processJournal(context.Background(), journalPath, targetFile, "", "2025-06-22", false, core.DayRange{}, config, NewLogger(ModeQuiet))
+	if err != nil {
+		t.Fatalf("processJournal() unexpected error: %v", err)
+	}
+	if len(summary.Warnings) != 1 || !strings.Contains(summary.Warnings[0], "todoer conflicts resolve") {
+		t.Errorf("processJournal() warnings = %v, want one conflict warning", summary.Warnings)
+	}
+}
+
+func TestCmdConflictsResolve(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir, TodosHeader: "## Todos"}
+	journalPath, dropboxCopy, syncthingCopy := createConflictedJournal(t, tempDir)
+
+	var out bytes.Buffer
+	if err := cmdConflictsResolve(&out, tempDir, config, NewLogger(ModeQuiet)); err != nil {
+		t.Fatalf("cmdConflictsResolve() unexpected error: %v", err)
+	}
+
+	content, err := os.ReadFile(journalPath)
+	if err != nil {
+		t.Fatalf("Failed to read journal: %v", err)
+	}
+	wantTodos := "- [[2025-06-21]]\n  - [x] Write report\n  - [ ] Call Alice\n  - [ ] Buy milk\n  - [ ] Book train\n\n## Notes\n\nMorning notes.\n"
+	if !strings.Contains(string(content), wantTodos) {
+		t.Errorf("merged journal:\n%s\nwant todos:\n%s", content, wantTodos)
+	}
+
+	if _, err := os.Stat(dropboxCopy); !os.IsNotExist(err) {
+		t.Errorf("merged copy %s was not removed", dropboxCopy)
+	}
+	if _, err := os.Stat(syncthingCopy); err != nil {
+		t.Errorf("copy with other changes should be kept: %v", err)
+	}
+	if !strings.Contains(out.String(), "Kept 2025/06/2025-06-21.sync-conflict-20250621-101010-ABCDEFG.md") ||
+		!strings.Contains(out.String(), "Merged 2 conflicting copies into 2025/06/2025-06-21.md") {
+		t.Errorf("cmdConflictsResolve() output = %q", out.String())
+	}
+
+	// Resolving again merges nothing new
+	out.Reset()
+	if err := cmdConflictsResolve(&out, tempDir, config, NewLogger(ModeQuiet)); err != nil {
+		t.Fatalf("cmdConflictsResolve() unexpected error: %v", err)
+	}
+	again, _ := os.ReadFile(journalPath)
+	if string(again) != string(content) {
+		t.Errorf("second resolve changed the journal:\n%s", again)
+	}
+
+	os.Remove(syncthingCopy)
+	out.Reset()
+	if err := cmdConflictsResolve(&out, tempDir, config, NewLogger(ModeQuiet)); err != nil {
+		t.Fatalf("cmdConflictsResolve() unexpected error: %v", err)
+	}
+	if out.String() != "No conflicting copies found\n" {
+		t.Errorf("cmdConflictsResolve() output = %q", out.String())
+	}
+}
+
+// mockCalDAV is a minimal CalDAV server with a single task list at /dav/tasks/
+type mockCalDAV struct {
+	mu      sync.Mutex
+	objects map[string]caldav.Todo // VTODOs by UID
+	puts    int
+}
+
+func (m *mockCalDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	uid := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/dav/tasks/"), ".ics")
+	switch {
+	case r.Method == "PROPFIND" && r.URL.Path == "/dav/":
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprint(w, `<?xml version="1.0"?><d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">`+
+			`<d:response><d:href>/dav/tasks/</d:href><d:propstat><d:prop><d:resourcetype><d:collection/><c:calendar/></d:resourcetype>`+
+			`<d:displayname>Tasks</d:displayname></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response></d:multistatus>`)
+	case r.Method == http.MethodGet:
+		todo, ok := m.objects[uid]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(todo.Marshal())
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		todo, err := caldav.ParseTodo(data)
+		if err != nil || todo.UID != uid {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		m.objects[uid] = todo
+		m.puts++
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// todoBySummary returns the UID of the VTODO with the given summary
+func (m *mockCalDAV) todoBySummary(summary string) (string, caldav.Todo) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for uid, todo := range m.objects {
+		if todo.Summary == summary {
+			return uid, todo
+		}
+	}
+	return "", caldav.Todo{}
+}
+
+func TestCmdSyncCalDAV(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	server := &mockCalDAV{objects: map[string]caldav.Todo{}}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	config := &Config{RootDir: tempDir, TodosHeader: "## Todos"}
+	opts := caldavOptions{URL: httpServer.URL + "/dav", Calendar: "Tasks"}
+	mappingPath := filepath.Join(tempDir, "data", "caldav.json")
+	now := time.Date(2025, 6, 21, 9, 0, 0, 0, time.UTC)
+	logger := NewLogger(ModeQuiet)
+
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-20"), "## Todos\n\n- [[2025-06-20]]\n  - [x] Old task #2025-06-20\n")
+	journalPath := buildJournalPath(tempDir, "2025-06-21")
+	createTestFile(t, journalPath, "## Todos\n\n- [[2025-06-20]]\n  - [ ] Write report\n    - [ ] Outline\n- [[2025-06-21]]\n  - [ ] Call Alice\n  - [ ] Buy milk\n  - [x] Done already\n")
+
+	var out bytes.Buffer
+	if err := cmdSyncCalDAV(&out, mappingPath, tempDir, opts, now, config, logger); err != nil {
+		t.Fatalf("cmdSyncCalDAV() unexpected error: %v", err)
+	}
+	if out.String() != "Created 3, updated 0 task(s) on Tasks; pulled 0 change(s) into the journal\n" {
+		t.Errorf("first sync output = %q", out.String())
+	}
+	reportUID, report := server.todoBySummary("Write report")
+	if reportUID == "" || report.Due != "2025-06-20" || report.Completed {
+		t.Errorf("Write report VTODO = %+v", report)
+	}
+	if uid, _ := server.todoBySummary("Outline"); uid != "" {
+		t.Error("subtasks should not be pushed")
+	}
+
+	// A second sync without changes does nothing
+	puts := server.puts
+	out.Reset()
+	if err := cmdSyncCalDAV(&out, mappingPath, tempDir, opts, now, config, logger); err != nil {
+		t.Fatalf("cmdSyncCalDAV() unexpected error: %v", err)
+	}
+	if server.puts != puts || !strings.HasPrefix(out.String(), "Created 0, updated 0") {
+		t.Errorf("unchanged sync pushed %d task(s), output %q", server.puts-puts, out.String())
+	}
+
+	// Completed on the phone, completed in the journal, deleted on the server
+	milkUID, _ := server.todoBySummary("Buy milk")
+	server.mu.Lock()
+	report.Completed = true
+	server.objects[reportUID] = report
+	delete(server.objects, milkUID)
+	server.mu.Unlock()
+
+	content, _ := os.ReadFile(journalPath)
+	createTestFile(t, journalPath, strings.Replace(string(content), "- [ ] Call Alice", "- [x] Call Alice", 1))
+
+	out.Reset()
+	if err := cmdSyncCalDAV(&out, mappingPath, tempDir, opts, now, config, logger); err != nil {
+		t.Fatalf("cmdSyncCalDAV() unexpected error: %v", err)
+	}
+	if out.String() != "Created 0, updated 1 task(s) on Tasks; pulled 1 change(s) into the journal\n" {
+		t.Errorf("third sync output = %q", out.String())
+	}
+
+	content, _ = os.ReadFile(journalPath)
+	want := "## Todos\n\n- [[2025-06-20]]\n  - [x] Write report\n    - [ ] Outline\n- [[2025-06-21]]\n  - [x] Call Alice\n  - [ ] Buy milk\n  - [x] Done already\n"
+	if string(content) != want {
+		t.Errorf("journal after sync:\n%s\nwant:\n%s", content, want)
+	}
+	if _, alice := server.todoBySummary("Call Alice"); !alice.Completed {
+		t.Error("completion in the journal was not pushed")
+	}
+	if uid, _ := server.todoBySummary("Buy milk"); uid != "" {
+		t.Error("task deleted on the server was pushed again")
+	}
+
+	mapping, err := loadCalDAVMapping(mappingPath)
+	if err != nil {
+		t.Fatalf("loadCalDAVMapping() error: %v", err)
+	}
+	entries := mapping[httpServer.URL+"/dav/tasks/"]
+	if len(entries) != 3 || !entries[syncTaskID("Buy milk")].Deleted || !entries[syncTaskID("Write report")].Completed {
+		t.Errorf("mapping = %+v", entries)
+	}
+}
+
+// fakeTaskwarrior is a taskwarrior keeping its tasks in memory, as JSON like the
+// task program does.
+type fakeTaskwarrior struct {
+	tasks map[string][]byte // Tasks by UUID
+}
+
+func (f *fakeTaskwarrior) Export() ([]*taskwarriorTask, error) {
+	var tasks []*taskwarriorTask
+	for _, data := range f.tasks {
+		task := &taskwarriorTask{}
+		if err := json.Unmarshal(data, task); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
+func (f *fakeTaskwarrior) Import(tasks []*taskwarriorTask) error {
+	for _, task := range tasks {
+		data, err := json.Marshal(task)
+		if err != nil {
+			return err
+		}
+		f.tasks[task.UUID] = data
+	}
+	return nil
+}
+
+// task returns the task with description.
+func (f *fakeTaskwarrior) task(t *testing.T, description string) *taskwarriorTask {
+	t.Helper()
+	tasks, _ := f.Export()
+	for _, task := range tasks {
+		if task.Description == description {
+			return task
+		}
+	}
+	return nil
+}
+
+func TestSyncTaskID(t *testing.T) {
+	open := syncTaskID("Call bank #errand")
+	for _, completed := range []string{"Call bank #errand #2025-06-21", "Call bank #errand #2025-06-21T14:32"} {
+		if got := syncTaskID(completed); got != open {
+			t.Errorf("syncTaskID(%q) = %s, want the ID of the open task %s", completed, got, open)
+		}
+	}
+
+	// Custom date tag layouts are parsed into the default one
+	config := &Config{DateTag: "✅ 2006-01-02"}
+	journal, err := journalParser(config).ParseJournal("## Todos\n\n- [[2025-06-20]]\n  - [x] Call bank #errand ✅ 2025-06-21\n")
+	if err != nil {
+		t.Fatalf("ParseJournal() error: %v", err)
+	}
+	if got := syncTaskID(journal.Days[0].Items[0].Text); got != open {
+		t.Errorf("syncTaskID() of a task with a custom date tag = %s, want %s", got, open)
+	}
+}
+
+func TestNewTaskwarriorTask(t *testing.T) {
+	now := time.Date(2025, 6, 21, 9, 0, 0, 0, time.UTC)
+	task := newTaskwarriorTask("u1", "Ship release #work/app #urgent due::[[2025-06-30]] [#A] #2025-06-21", true, now)
+	if task.Description != "Ship release" || task.Project != "work.app" || !reflect.DeepEqual(task.Tags, []string{"urgent"}) ||
+		task.Priority != "H" || task.Due != taskwarriorDate("2025-06-30") || task.Status != "completed" || task.End != "20250621T090000Z" {
+		t.Errorf("newTaskwarriorTask() = %+v", task)
+	}
+	if got := journalTaskText(task); got != "Ship release #work/app #urgent due::[[2025-06-30]] [#A]" {
+		t.Errorf("journalTaskText() = %q", got)
+	}
+
+	// Fields todoer does not know survive a round trip
+	var exported taskwarriorTask
+	if err := json.Unmarshal([]byte(`{"uuid":"u2","description":"Read","status":"pending","urgency":4.2,"annotations":[{"description":"note"}]}`), &exported); err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	exported.Status = "completed"
+	data, err := json.Marshal(&exported)
+	if err != nil {
+		t.Fatalf("Marshal() error: %v", err)
+	}
+	if want := `{"annotations":[{"description":"note"}],"description":"Read","status":"completed","urgency":4.2,"uuid":"u2"}`; string(data) != want {
+		t.Errorf("Marshal() = %s, want %s", data, want)
+	}
+}
+
+func TestCmdSyncTaskwarrior(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	tw := &fakeTaskwarrior{tasks: map[string][]byte{
+		"added-uuid": []byte(`{"uuid":"added-uuid","description":"Water plants","status":"pending","project":"home"}`),
+		"done-uuid":  []byte(`{"uuid":"done-uuid","description":"Old chore","status":"completed"}`),
+	}}
+	config := &Config{RootDir: tempDir, TodosHeader: "## Todos"}
+	mappingPath := filepath.Join(tempDir, "data", "taskwarrior.json")
+	now := time.Date(2025, 6, 21, 9, 0, 0, 0, time.UTC)
+	logger := NewLogger(ModeQuiet)
+
+	journalPath := buildJournalPath(tempDir, "2025-06-21")
+	createTestFile(t, journalPath, "## Todos\n\n- [[2025-06-20]]\n  - [ ] Write report #work\n    - [ ] Outline\n- [[2025-06-21]]\n  - [ ] Buy milk\n  - [x] Done already\n")
+
+	var out bytes.Buffer
+	if err := cmdSyncTaskwarrior(&out, tw, mappingPath, tempDir, now, config, logger); err != nil {
+		t.Fatalf("cmdSyncTaskwarrior() unexpected error: %v", err)
+	}
+	if out.String() != "Created 2, updated 0 task(s) in Taskwarrior; pulled 0 change(s) and 1 new task(s) into the journal\n" {
+		t.Errorf("first sync output = %q", out.String())
+	}
+	if report := tw.task(t, "Write report"); report == nil || report.Project != "work" || report.Status != "pending" {
+		t.Errorf("Write report task = %+v", report)
+	}
+	if tw.task(t, "Outline") != nil || tw.task(t, "Done already") != nil {
+		t.Error("subtasks and completed tasks should not be added")
+	}
+	content, _ := os.ReadFile(journalPath)
+	if !strings.HasSuffix(string(content), "  - [x] Done already\n  - [ ] Water plants #home\n") {
+		t.Errorf("journal after first sync:\n%s", content)
+	}
+
+	// A second sync without changes does nothing
+	out.Reset()
+	if err := cmdSyncTaskwarrior(&out, tw, mappingPath, tempDir, now, config, logger); err != nil {
+		t.Fatalf("cmdSyncTaskwarrior() unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "Created 0, updated 0 task(s) in Taskwarrior; pulled 0 change(s) and 0 new") {
+		t.Errorf("unchanged sync output = %q", out.String())
+	}
+
+	// Completed in Taskwarrior, completed in the journal, deleted in Taskwarrior
+	report := tw.task(t, "Write report")
+	report.Status = "completed"
+	milk := tw.task(t, "Buy milk")
+	milk.Status = "deleted"
+	if err := tw.Import([]*taskwarriorTask{report, milk}); err != nil {
+		t.Fatalf("Import() error: %v", err)
+	}
+	content, _ = os.ReadFile(journalPath)
+	createTestFile(t, journalPath, strings.Replace(string(content), "- [ ] Water plants", "- [x] Water plants", 1))
+
+	out.Reset()
+	if err := cmdSyncTaskwarrior(&out, tw, mappingPath, tempDir, now, config, logger); err != nil {
+		t.Fatalf("cmdSyncTaskwarrior() unexpected error: %v", err)
+	}
+	if out.String() != "Created 0, updated 1 task(s) in Taskwarrior; pulled 1 change(s) and 0 new task(s) into the journal\n" {
+		t.Errorf("third sync output = %q", out.String())
+	}
+	content, _ = os.ReadFile(journalPath)
+	want := "## Todos\n\n- [[2025-06-20]]\n  - [x] Write report #work\n    - [ ] Outline\n- [[2025-06-21]]\n  - [ ] Buy milk\n  - [x] Done already\n  - [x] Water plants #home\n"
+	if string(content) != want {
+		t.Errorf("journal after sync:\n%s\nwant:\n%s", content, want)
+	}
+	if plants := tw.task(t, "Water plants"); plants.Status != "completed" || plants.End == "" {
+		t.Errorf("completion in the journal was not pushed: %+v", plants)
+	}
+	if milk := tw.task(t, "Buy milk"); milk.Status != "deleted" {
+		t.Error("task deleted in Taskwarrior was added again")
+	}
+
+	mapping, err := loadTaskwarriorMapping(mappingPath)
+	if err != nil {
+		t.Fatalf("loadTaskwarriorMapping() error: %v", err)
+	}
+	if len(mapping) != 3 || !mapping[syncTaskID("Buy milk")].Deleted || mapping[syncTaskID("Water plants #home")].UUID != "added-uuid" {
+		t.Errorf("mapping = %+v", mapping)
+	}
+}
+
+func TestCmdSyncCalDAV_Errors(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir, TodosHeader: "## Todos"}
+	mappingPath := filepath.Join(tempDir, "caldav.json")
+	now := time.Date(2025, 6, 21, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		opts     caldavOptions
+		wantCode int
+	}{
+		{name: "missing url", opts: caldavOptions{Calendar: "Tasks"}, wantCode: ExitConfigError},
+		{name: "missing calendar", opts: caldavOptions{URL: "https://example.com/dav"}, wantCode: ExitConfigError},
+		{name: "invalid url", opts: caldavOptions{URL: "ftp://example.com/dav", Calendar: "Tasks"}, wantCode: ExitConfigError},
+		{name: "no journals", opts: caldavOptions{URL: "https://example.com/dav", Calendar: "Tasks"}, wantCode: ExitFailure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := cmdSyncCalDAV(io.Discard, mappingPath, tempDir, tt.opts, now, config, NewLogger(ModeQuiet))
+			if code := exitCodeFor(err); code != tt.wantCode {
+				t.Errorf("exit code = %d, want %d (err: %v)", code, tt.wantCode, err)
+			}
+		})
+	}
+}
+
+func TestCmdAgenda(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir, TodosHeader: "## Todos"}
+	indexPath := filepath.Join(tempDir, "cache", IndexCacheFile)
+	logger := NewLogger(ModeQuiet)
+
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-19"), "## Todos\n\n- [[2025-06-19]]\n  - [x] Water plants #2025-06-19\n")
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-20"), `## Todos
+
+- [[2025-05-01]]
+  - [ ] Renew passport
+- [[2025-06-16]]
+  - [ ] Pay rent due::[[2025-06-18]]
+  - [ ] Book dentist due::[[2025-06-20]]
+- [[2025-06-19]]
+  - [ ] Call Alice
+- [[2025-06-20]]
+  - [ ] Plan week
+`)
+
+	var out bytes.Buffer
+	if err := cmdAgenda(&out, tempDir, indexPath, "2025-06-20", OutputText, config, logger); err != nil {
+		t.Fatalf("cmdAgenda() unexpected error: %v", err)
+	}
+	want := "Agenda for 2025-06-20\n" +
+		"\nOverdue (1):\n  - Pay rent due::[[2025-06-18]] (2 days)\n" +
+		"\nDue today (1):\n  - Book dentist due::[[2025-06-20]]\n" +
+		"\nCarried (2):\n  over 4 weeks:\n    - Renew passport\n  1 day:\n    - Call Alice\n" +
+		"\nStreak: 1 day (longest 1)\n" +
+		"\nCompleted yesterday (1):\n  - Water plants\n"
+	if out.String() != want {
+		t.Errorf("text agenda = %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	if err := cmdAgenda(&out, tempDir, indexPath, "2025-06-20", AgendaMarkdown, config, logger); err != nil {
+		t.Fatalf("cmdAgenda() unexpected error: %v", err)
+	}
+	want = "## Agenda 2025-06-20\n" +
+		"\n### Overdue\n\n- Pay rent due::[[2025-06-18]] (2 days overdue)\n" +
+		"\n### Due today\n\n- Book dentist due::[[2025-06-20]]\n" +
+		"\n### Carried\n\n- Renew passport (over 4 weeks)\n- Call Alice (1 day)\n" +
+		"\n### Streak\n\n1 day, longest 1\n" +
+		"\n### Completed yesterday\n\n- Water plants\n"
+	if out.String() != want {
+		t.Errorf("markdown agenda = %q, want %q", out.String(), want)
+	}
+
+	if err := cmdAgenda(&out, filepath.Join(tempDir, "missing"), indexPath, "2025-06-20", OutputText, config, logger); err == nil {
+		t.Error("cmdAgenda() without journals succeeded, want an error")
+	}
+}
+
+func TestCmdNotify(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	var mu sync.Mutex
+	requests := map[string]*http.Request{}
+	bodies := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		requests[r.URL.Path] = r
+		bodies[r.URL.Path] = string(body)
+	}))
+	defer server.Close()
+
+	config := &Config{
+		RootDir:     tempDir,
+		TodosHeader: "## Todos",
+		Notify: NotifyConfig{
+			WebhookURL: server.URL + "/hook",
+			NtfyServer: server.URL,
+			NtfyTopic:  "todos",
+			NtfyToken:  "tk_secret",
+		},
+	}
+	now := time.Date(2025, 6, 20, 9, 0, 0, 0, time.UTC)
+	logger := NewLogger(ModeQuiet)
+
+	// A journal in the future is ignored
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-25"), "## Todos\n\n- [[2025-06-25]]\n  - [ ] Future due::[[2025-06-01]]\n")
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-19"), "## Todos\n\n- [[2025-06-01]]\n  - [ ] Renew passport\n- [[2025-06-19]]\n  - [ ] Pay rent due::[[2025-06-18]]\n  - [ ] Call Alice\n")
+
+	opts := notifyOptions{StaleDays: 14, Notifiers: []string{"webhook", "ntfy"}}
+	var out bytes.Buffer
+	if err := cmdNotify(&out, tempDir, opts, now, config, logger); err != nil {
+		t.Fatalf("cmdNotify() unexpected error: %v", err)
+	}
+	wantOut := "todoer: 1 overdue, 1 stale tasks\n" +
+		"- Pay rent due::[[2025-06-18]] (overdue by 2 days)\n" +
+		"- Renew passport (open for 19 days)\n"
+	if out.String() != wantOut {
+		t.Errorf("output = %q, want %q", out.String(), wantOut)
+	}
+
+	var payload struct {
+		Title string `json:"title"`
+		Tasks []struct {
+			Text string `json:"text"`
+			Kind string `json:"kind"`
+			Days int    `json:"days"`
+		} `json:"tasks"`
+	}
+	if err := json.Unmarshal([]byte(bodies["/hook"]), &payload); err != nil {
+		t.Fatalf("webhook payload %q: %v", bodies["/hook"], err)
+	}
+	if payload.Title != "todoer: 1 overdue, 1 stale tasks" || len(payload.Tasks) != 2 || payload.Tasks[0].Kind != "overdue" || payload.Tasks[1].Days != 19 {
+		t.Errorf("webhook payload = %+v", payload)
+	}
+
+	ntfy := requests["/todos"]
+	if ntfy == nil {
+		t.Fatal("ntfy topic was not published to")
+	}
+	if ntfy.Header.Get("Title") != payload.Title || ntfy.Header.Get("Authorization") != "Bearer tk_secret" {
+		t.Errorf("ntfy headers = %v", ntfy.Header)
+	}
+	if !strings.Contains(bodies["/todos"], "- Renew passport (open for 19 days)") {
+		t.Errorf("ntfy message = %q", bodies["/todos"])
+	}
+
+	// A dry run prints without sending
+	delete(requests, "/hook")
+	out.Reset()
+	opts.DryRun = true
+	if err := cmdNotify(&out, tempDir, opts, now, config, logger); err != nil {
+		t.Fatalf("cmdNotify() dry run unexpected error: %v", err)
+	}
+	if requests["/hook"] != nil || out.String() != wantOut {
+		t.Errorf("dry run sent a notification or printed %q", out.String())
+	}
+
+	// Nothing is sent when no task needs attention
+	out.Reset()
+	opts = notifyOptions{StaleDays: 30, OverdueDays: 5, Notifiers: []string{"webhook"}}
+	if err := cmdNotify(&out, tempDir, opts, now, config, logger); err != nil {
+		t.Fatalf("cmdNotify() unexpected error: %v", err)
+	}
+	if requests["/hook"] != nil || out.String() != "No overdue or stale tasks\n" {
+		t.Errorf("notify without alerts sent a notification or printed %q", out.String())
+	}
+}
+
+func TestCmdNotify_Errors(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	config := &Config{RootDir: tempDir, TodosHeader: "## Todos"}
+	now := time.Date(2025, 6, 20, 9, 0, 0, 0, time.UTC)
+	logger := NewLogger(ModeQuiet)
+
+	tests := []struct {
+		name     string
+		opts     notifyOptions
+		wantCode int
+	}{
+		{name: "unknown notifier", opts: notifyOptions{Notifiers: []string{"pager"}}, wantCode: ExitConfigError},
+		{name: "webhook without url", opts: notifyOptions{Notifiers: []string{"webhook"}}, wantCode: ExitConfigError},
+		{name: "ntfy without topic", opts: notifyOptions{Notifiers: []string{"ntfy"}}, wantCode: ExitConfigError},
+		{name: "no journals", opts: notifyOptions{StaleDays: 14}, wantCode: ExitFailure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := cmdNotify(io.Discard, tempDir, tt.opts, now, config, logger)
+			if code := exitCodeFor(err); code != tt.wantCode {
+				t.Errorf("exit code = %d, want %d (err: %v)", code, tt.wantCode, err)
+			}
+		})
+	}
+
+	t.Run("failing notifier", func(t *testing.T) {
+		createTestFile(t, buildJournalPath(tempDir, "2025-06-20"), "## Todos\n\n- [[2025-06-20]]\n  - [ ] Pay rent due::[[2025-06-18]]\n")
+		config.Notify.WebhookURL = server.URL
+		err := cmdNotify(io.Discard, tempDir, notifyOptions{Notifiers: []string{"webhook"}}, now, config, logger)
+		if err == nil || !strings.Contains(err.Error(), "500") {
+			t.Errorf("cmdNotify() error = %v, want status error", err)
+		}
+	})
+}
+
+func TestDesktopCommand(t *testing.T) {
+	command, args := desktopCommand("linux", "todoer: 1 stale task", "- Renew passport")
+	if command != "notify-send" || len(args) != 3 || args[1] != "todoer: 1 stale task" || args[2] != "- Renew passport" {
+		t.Errorf("desktopCommand(linux) = %s %q", command, args)
+	}
+
+	command, args = desktopCommand("darwin", "todoer", `Say "hi" \ bye`)
+	want := `display notification "Say \"hi\" \\ bye" with title "todoer"`
+	if command != "osascript" || len(args) != 2 || args[1] != want {
+		t.Errorf("desktopCommand(darwin) = %s %q", command, args)
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	today := time.Date(2025, 6, 20, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		since   string
+		want    string
+		wantErr bool
+	}{
+		{since: "7d", want: "2025-06-14"},
+		{since: "1d", want: "2025-06-20"},
+		{since: "2w", want: "2025-06-07"},
+		{since: "2025-06-01", want: "2025-06-01"},
+		{since: "2 weeks ago", want: "2025-06-06"},
+		{since: "last monday", want: "2025-06-16"},
+		{since: "2025-07-01", wantErr: true},
+		{since: "tomorrow", wantErr: true},
+		{since: "0d", wantErr: true},
+		{since: "week", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseSince(tt.since, today, core.DateParser{})
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseSince(%q) = %q, %v; want %q, error %v", tt.since, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestResolveDateFlags(t *testing.T) {
+	sunday := time.Date(2025, 6, 22, 9, 0, 0, 0, time.UTC)
+	defer func() { CLI.Move.From, CLI.Move.To = "", "" }()
+
+	CLI.Move.From, CLI.Move.To = "yesterday", "next monday"
+	if err := resolveDateFlags("move <pattern>", sunday, &Config{}); err != nil {
+		t.Fatalf("resolveDateFlags() error: %v", err)
+	}
+	if CLI.Move.From != "2025-06-21" || CLI.Move.To != "2025-06-23" {
+		t.Errorf("move dates = %s, %s; want 2025-06-21, 2025-06-23", CLI.Move.From, CLI.Move.To)
+	}
+
+	// Values that are no dates are left for the command to report
+	CLI.Move.From = "someday"
+	if err := resolveDateFlags("move <pattern>", sunday, &Config{}); err != nil || CLI.Move.From != "someday" {
+		t.Errorf("resolveDateFlags(someday) = %v, from %q; want it left alone", err, CLI.Move.From)
+	}
+	if err := resolveDateFlags("move <pattern>", sunday, &Config{DateLocale: "tlh"}); exitCodeFor(err) != ExitConfigError {
+		t.Errorf("resolveDateFlags() with an unknown locale error = %v, want a config error", err)
+	}
+
+	tests := []struct {
+		config *Config
+		value  string
+		want   string
+	}{
+		{&Config{}, "", ""},
+		{&Config{}, "2025-07-01", "2025-07-01"},
+		{&Config{}, "next monday", "2025-06-23"},
+		{&Config{WeekStart: "sunday"}, "next monday", "2025-06-30"},
+		{&Config{DateLocale: "en-US"}, "this week", "2025-06-22"},
+		{&Config{DateLocale: "de"}, "nächsten Montag", "2025-06-23"},
+	}
+	for _, tt := range tests {
+		got, err := resolveDate(tt.value, sunday, tt.config)
+		if err != nil || got != tt.want {
+			t.Errorf("resolveDate(%q) = %q, %v; want %q", tt.value, got, err, tt.want)
+		}
+	}
+
+	if _, err := resolveDate("someday", sunday, &Config{}); !errors.Is(err, ErrInvalidDate) || exitCodeFor(err) != ExitConfigError {
+		t.Errorf("resolveDate(someday) error = %v, want ErrInvalidDate with exit code %d", err, ExitConfigError)
+	}
+}
+
+func TestCmdDigest(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		body = string(raw)
+	}))
+	defer server.Close()
+
+	config := &Config{RootDir: tempDir, TodosHeader: "## Todos", Notify: NotifyConfig{WebhookURL: server.URL}}
+	now := time.Date(2025, 6, 20, 9, 0, 0, 0, time.UTC)
+	logger := NewLogger(ModeQuiet)
+
+	// Too old, in the period, and a completed subtask carried along with its open parent
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-10"), "## Todos\n\n- [[2025-06-10]]\n  - [x] Old task #2025-06-10\n")
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-16"), "## Todos\n\n- [[2025-06-16]]\n  - [x] Ship release #2025-06-16\n  - [ ] Write report\n    - [x] Collect numbers #2025-06-16\n")
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-17"), "## Todos\n\n- [[2025-06-16]]\n  - [ ] Write report\n    - [x] Collect numbers #2025-06-16\n- [[2025-06-17]]\n  - [x] Review PR\n")
+
+	var out bytes.Buffer
+	opts := digestOptions{Since: "7d", Notifiers: []string{"webhook"}}
+	if err := cmdDigest(&out, tempDir, opts, now, config, logger); err != nil {
+		t.Fatalf("cmdDigest() unexpected error: %v", err)
+	}
+	wantOut := "todoer: 3 tasks completed from 2025-06-14 to 2025-06-20\n\n" +
+		"Completed tasks from 2025-06-14 to 2025-06-20: 3\n\n" +
+		"2025-06-16 (Monday)\n- Ship release\n- Collect numbers\n\n" +
+		"2025-06-17 (Tuesday)\n- Review PR\n"
+	if out.String() != wantOut {
+		t.Errorf("output =\n%s\nwant\n%s", out.String(), wantOut)
+	}
+	if !strings.Contains(body, `"title":"todoer: 3 tasks completed from 2025-06-14 to 2025-06-20"`) {
+		t.Errorf("webhook payload = %s", body)
+	}
+
+	// A custom template
+	templateFile := filepath.Join(tempDir, "digest.md")
+	createTestFile(t, templateFile, "{{range .Days}}{{.Date}}: {{index .Tasks 0}}\n{{end}}")
+	out.Reset()
+	if err := cmdDigest(&out, tempDir, digestOptions{Since: "2025-06-17", TemplateFile: templateFile, DryRun: true}, now, config, logger); err != nil {
+		t.Fatalf("cmdDigest() unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(out.String(), "\n\n2025-06-17: Review PR\n") {
+		t.Errorf("output with custom template = %q", out.String())
+	}
+
+	// Nothing completed
+	out.Reset()
+	if err := cmdDigest(&out, tempDir, digestOptions{Since: "1d"}, now, config, logger); err != nil || out.String() != "No tasks completed from 2025-06-20 to 2025-06-20\n" {
+		t.Errorf("cmdDigest() = %q, %v", out.String(), err)
+	}
+
+	if err := cmdDigest(io.Discard, tempDir, digestOptions{Since: "soon"}, now, config, logger); exitCodeFor(err) != ExitConfigError {
+		t.Errorf("cmdDigest() with invalid period: error = %v, want config error", err)
+	}
+}
+
+func TestCmdPost(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	var payloads []map[string]string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("invalid payload: %v", err)
+		}
+		payloads = append(payloads, payload)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	config := &Config{RootDir: tempDir, TodosHeader: "## Todos"}
+	now := time.Date(2025, 6, 20, 9, 0, 0, 0, time.UTC)
+	logger := NewLogger(ModeQuiet)
+
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-19"), "## Todos\n\n- [[2025-06-19]]\n  - [x] Ship release #2025-06-19\n  - [x] Older task #2025-06-12\n")
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-20"), "## Todos\n\n- [[2025-06-18]]\n  - [ ] Write report\n    - [ ] Collect numbers\n- [[2025-06-20]]\n  - [x] Review PR #2025-06-19\n  - [ ] Plan sprint\n")
+
+	var out bytes.Buffer
+	opts := postOptions{Select: PostOpen, WebhookURL: server.URL}
+	if err := cmdPost(&out, tempDir, opts, now, config, logger); err != nil {
+		t.Fatalf("cmdPost() unexpected error: %v", err)
+	}
+	wantOpen := "Open tasks for 2025-06-20 (2)\n• Write report\n• Plan sprint"
+	if out.String() != wantOpen+"\n" {
+		t.Errorf("output = %q, want %q", out.String(), wantOpen+"\n")
+	}
+	if len(payloads) != 1 || payloads[0]["text"] != wantOpen {
+		t.Errorf("slack payloads = %v", payloads)
+	}
+
+	// Yesterday's completions as a Discord message
+	out.Reset()
+	opts = postOptions{Select: PostCompleted, WebhookURL: server.URL, Format: PostDiscord}
+	if err := cmdPost(&out, tempDir, opts, now, config, logger); err != nil {
+		t.Fatalf("cmdPost() unexpected error: %v", err)
+	}
+	wantCompleted := "Completed on 2025-06-19 (2)\n• Ship release\n• Review PR"
+	if len(payloads) != 2 || payloads[1]["content"] != wantCompleted {
+		t.Errorf("discord payloads = %v", payloads)
+	}
+
+	// A custom template, printed only
+	templateFile := filepath.Join(tempDir, "post.md")
+	createTestFile(t, templateFile, "{{.Total}} left: {{join \", \" .Tasks}}")
+	out.Reset()
+	opts = postOptions{Select: PostOpen, TemplateFile: templateFile, DryRun: true}
+	if err := cmdPost(&out, tempDir, opts, now, config, logger); err != nil || out.String() != "2 left: Write report, Plan sprint\n" {
+		t.Errorf("cmdPost() dry run = %q, %v", out.String(), err)
+	}
+	if len(payloads) != 2 {
+		t.Errorf("dry run posted a message")
+	}
+
+	if err := cmdPost(io.Discard, tempDir, postOptions{Select: PostOpen}, now, config, logger); exitCodeFor(err) != ExitConfigError {
+		t.Errorf("cmdPost() without webhook: error = %v, want config error", err)
+	}
+	status = http.StatusForbidden
+	if err := cmdPost(io.Discard, tempDir, postOptions{Select: PostOpen, WebhookURL: server.URL}, now, config, logger); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("cmdPost() with rejected webhook: error = %v", err)
+	}
+}
+
+func TestPostFormat(t *testing.T) {
+	tests := []struct {
+		format, url, want string
+	}{
+		{"", "https://hooks.slack.com/services/T0/B0/x", PostSlack},
+		{"", "https://discord.com/api/webhooks/1/x", PostDiscord},
+		{"", "https://ptb.discord.com/api/webhooks/1/x", PostDiscord},
+		{PostSlack, "https://discord.com/api/webhooks/1/x", PostSlack},
+	}
+	for _, tt := range tests {
+		if got := postFormat(tt.format, tt.url); got != tt.want {
+			t.Errorf("postFormat(%q, %q) = %q, want %q", tt.format, tt.url, got, tt.want)
+		}
+	}
+
+	long := strings.Repeat("x", discordMaxContent+10)
+	body, err := postPayload(PostDiscord, long)
+	var payload map[string]string
+	if err != nil || json.Unmarshal(body, &payload) != nil || len([]rune(payload["content"])) != discordMaxContent {
+		t.Errorf("postPayload() did not truncate to %d characters", discordMaxContent)
+	}
+}
+
+func TestProcessJournal_PostAfterProcess(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	var texts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		texts = append(texts, payload["text"])
+	}))
+	defer server.Close()
+
+	sourceFile := filepath.Join(tempDir, "2025-06-19.md")
+	targetFile := filepath.Join(tempDir, "2025-06-20.md")
+	createTestFile(t, sourceFile, "## Todos\n\n- [[2025-06-19]]\n  - [ ] Write report\n  - [x] Done\n")
+	config := &Config{RootDir: tempDir, TodosHeader: "## Todos", Post: PostConfig{WebhookURL: server.URL, AfterProcess: true}}
+
+	summary, err := processJournal(context.Background(), sourceFile, targetFile, "", "2025-06-20", false, core.DayRange{}, config, NewLogger(ModeQuiet))
+	if err != nil {
+		t.Fatalf("processJournal() unexpected error: %v", err)
+	}
+	if len(texts) != 1 || texts[0] != "Open tasks for 2025-06-20 (1)\n• Write report created::[[2025-06-19]]" {
+		t.Errorf("posted %q", texts)
+	}
+	if len(summary.Warnings) != 0 {
+		t.Errorf("unexpected warnings: %v", summary.Warnings)
+	}
+
+	// A failed post is a warning, not an error
+	server.Close()
+	if err := os.Remove(targetFile); err != nil {
+		t.Fatal(err)
+	}
+	createTestFile(t, sourceFile, "## Todos\n\n- [[2025-06-19]]\n  - [ ] Write report\n")
+	summary, err = processJournal(context.Background(), sourceFile, targetFile, "", "2025-06-20", true, core.DayRange{}, config, NewLogger(ModeQuiet))
+	if err != nil {
+		t.Fatalf("processJournal() unexpected error: %v", err)
+	}
+	if len(summary.Warnings) != 1 || !strings.HasPrefix(summary.Warnings[0], "could not post open tasks:") {
+		t.Errorf("warnings = %v", summary.Warnings)
+	}
+}
+
+func TestEmailNotifiers(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	n := notification{Title: "todoer: 2 tasks completed", Message: "- Ship release\n- Review PR"}
+	msg := string(emailMessage("me@example.com", []string{"boss@example.com", "team@example.com"}, n, time.Date(2025, 6, 20, 9, 0, 0, 0, time.UTC)))
+	for _, want := range []string{
+		"From: me@example.com\r\n",
+		"To: boss@example.com, team@example.com\r\n",
+		"Subject: todoer: 2 tasks completed\r\n",
+		"Date: Fri, 20 Jun 2025 09:00:00 +0000\r\n",
+		"\r\n\r\n- Ship release\r\n- Review PR\r\n",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("emailMessage() missing %q in:\n%s", want, msg)
+		}
+	}
+
+	config := &Config{Email: EmailConfig{From: "me@example.com", To: []string{"boss@example.com"}, SMTPServer: "smtp.example.com:587", SMTPUsername: "me", SMTPPassword: "secret"}}
+	target, err := newSMTPNotifier(config)
+	if err != nil {
+		t.Fatalf("newSMTPNotifier() unexpected error: %v", err)
+	}
+	smtpTarget := target.(*smtpNotifier)
+	var sentTo []string
+	smtpTarget.send = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		if addr != "smtp.example.com:587" || a == nil || from != "me@example.com" {
+			t.Errorf("smtp.SendMail(%s, %v, %s)", addr, a, from)
+		}
+		sentTo = to
+		return nil
+	}
+	if err := smtpTarget.Notify(n); err != nil || !reflect.DeepEqual(sentTo, []string{"boss@example.com"}) {
+		t.Errorf("smtp Notify() = %v, sent to %v", err, sentTo)
+	}
+
+	// sendmail gets the message on standard input
+	captured := filepath.Join(tempDir, "mail.txt")
+	script := filepath.Join(tempDir, "sendmail")
+	createTestFile(t, script, "#!/bin/sh\ncat > "+captured+"\n")
+	if err := os.Chmod(script, 0755); err != nil {
+		t.Fatal(err)
+	}
+	config.Email.Sendmail = script
+	target, err = newSendmailNotifier(config)
+	if err != nil {
+		t.Fatalf("newSendmailNotifier() unexpected error: %v", err)
+	}
+	if err := target.Notify(n); err != nil {
+		t.Fatalf("sendmail Notify() unexpected error: %v", err)
+	}
+	if mail, err := os.ReadFile(captured); err != nil || !strings.Contains(string(mail), "Subject: todoer: 2 tasks completed\r\n") {
+		t.Errorf("sendmail input = %q, %v", mail, err)
+	}
+
+	for _, cfg := range []EmailConfig{{To: []string{"a@example.com"}, SMTPServer: "smtp:25"}, {From: "a@example.com", SMTPServer: "smtp:25"}, {From: "a@example.com", To: []string{"b@example.com"}, SMTPServer: "smtp"}} {
+		if _, err := newSMTPNotifier(&Config{Email: cfg}); err == nil {
+			t.Errorf("newSMTPNotifier(%+v) expected error", cfg)
+		}
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// waitFor polls cond until it holds or a second has passed
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestServeJournals(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	createTestFile(t, buildJournalPath(tempDir, "2024-01-01"), "---\ntitle: 2024-01-01\n---\n\n## Todos\n\n- [ ] Open task\n- [x] Done task\n")
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	clock := time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)
+	now := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return clock
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var out syncBuffer
+	done := make(chan error, 1)
+	go func() {
+		done <- serveJournals(ctx, &out, listener, tempDir, "", 5*time.Millisecond, DefaultJournalCacheSize, now, newLiveConfig(&Config{RootDir: tempDir}, nil, NewLogger(ModeQuiet)), NewLogger(ModeQuiet))
+	}()
+
+	metrics := func() string {
+		resp, err := http.Get("http://" + listener.Addr().String() + "/metrics")
+		if err != nil {
+			return ""
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	waitFor(t, "first journal", func() bool { return strings.Contains(metrics(), "\ntodoer_journals_processed_total 1\n") })
+	got := metrics()
+	for _, want := range []string{
+		"# TYPE todoer_journals_processed_total counter\n",
+		"\ntodoer_tasks_carried_total 1\n",
+		"\ntodoer_process_failures_total 0\n",
+		"\ntodoer_processing_duration_seconds_count 1\n",
+		"\ntodoer_processing_duration_seconds_bucket{le=\"+Inf\"} 1\n",
+		"\ntodoer_last_success_timestamp_seconds " + formatFloat(float64(clock.Unix())) + "\n",
+		"\ntodoer_last_run_success 1\n",
+		"\ntodoer_journals 2\n",
+		"\ntodoer_open_todos 1\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("metrics missing %q:\n%s", want, got)
+		}
+	}
+	if _, err := os.Stat(buildJournalPath(tempDir, "2024-01-02")); err != nil {
+		t.Errorf("journal for 2024-01-02 not created: %v", err)
+	}
+
+	createTestFile(t, buildJournalPath(tempDir, "2024-01-02"), "---\ntitle: 2024-01-02\n---\n\n## Todos\n\n- [ ] Task\n- [[2024-13-01]]\n")
+	mu.Lock()
+	clock = clock.AddDate(0, 0, 1)
+	mu.Unlock()
+	parseErrors := regexp.MustCompile(`\ntodoer_parse_errors_total [1-9]\d*\n`)
+	waitFor(t, "parse error", func() bool { return parseErrors.MatchString(metrics()) })
+	if got := metrics(); !strings.Contains(got, "\ntodoer_last_run_success 0\n") {
+		t.Errorf("metrics should report the failed run:\n%s", got)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("serveJournals() unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "Serving metrics on http://"+listener.Addr().String()+"/metrics") {
+		t.Errorf("output = %q", out.String())
+	}
+}
+
+func TestJournalCache(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	journal := func(date, todos string) string {
+		return "---\ntitle: " + date + "\n---\n\n## Todos\n\n" + todos
+	}
+	first, second := buildJournalPath(tempDir, "2024-01-01"), buildJournalPath(tempDir, "2024-01-02")
+	createTestFile(t, first, journal("2024-01-01", "- [[2024-01-01]]\n  - [x] Done task\n  - [ ] Open task\n"))
+	createTestFile(t, second, journal("2024-01-02", "- [[2024-01-01]]\n  - [ ] Open task\n"))
+	createTestFile(t, filepath.Join(tempDir, "notes.md"), "Not a journal")
+
+	cache := newJournalCache(storage.NewLocal(tempDir), 1, &Config{RootDir: tempDir, FrontmatterDateKey: "title"})
+	refresh := func(want int) {
+		t.Helper()
+		if parsed, err := cache.refresh(); err != nil || parsed != want {
+			t.Errorf("refresh() = %d, %v, want %d journals parsed", parsed, err, want)
+		}
+	}
+	refresh(2)
+	refresh(0)
+
+	stats := cache.stats()
+	if stats.Journals != 2 || stats.Unparseable != 0 || len(stats.Completions) != 1 || stats.Latest != "2024/01/2024-01-02.md" {
+		t.Errorf("stats() = %+v", stats)
+	}
+
+	// A journal saved without changes is not parsed again, a changed one is
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(first, later, later); err != nil {
+		t.Fatal(err)
+	}
+	refresh(0)
+	createTestFile(t, second, journal("2024-01-02", "- [[2024-01-01]]\n  - [ ] Open task\n  - [ ] New task\n"))
+	if err := os.Chtimes(second, later, later); err != nil {
+		t.Fatal(err)
+	}
+	refresh(1)
+
+	// Only the most recently parsed journal is kept; evicted ones are parsed again
+	parses := cache.stats().Parses
+	if j, err := cache.journal("2024/01/2024-01-02.md"); err != nil || len(j.Days[0].Items) != 2 {
+		t.Errorf("journal() of the kept journal = %v, %v", j, err)
+	}
+	if got := cache.stats().Parses; got != parses {
+		t.Errorf("kept journal parsed again: %d parses, want %d", got, parses)
+	}
+	if j, err := cache.journal("2024/01/2024-01-01.md"); err != nil || len(j.Days[0].Items) != 2 {
+		t.Errorf("journal() of an evicted journal = %v, %v", j, err)
+	}
+	if got := cache.stats().Parses; got != parses+1 {
+		t.Errorf("evicted journal: %d parses, want %d", got, parses+1)
+	}
+
+	// Broken and deleted journals are noticed
+	createTestFile(t, first, journal("2024-01-01", "- [[2024-01-01]]\n- [[2024-13-01]]\n"))
+	if err := os.Chtimes(first, later.Add(time.Hour), later.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	refresh(1)
+	if stats := cache.stats(); stats.Unparseable != 1 || len(stats.Completions) != 0 {
+		t.Errorf("stats() with a broken journal = %+v", stats)
+	}
+	if _, err := cache.journal("2024/01/2024-01-01.md"); err == nil {
+		t.Error("journal() of a broken journal should fail")
+	}
+	if err := os.Remove(second); err != nil {
+		t.Fatal(err)
+	}
+	refresh(0)
+	if stats := cache.stats(); stats.Journals != 1 || stats.Latest != "" {
+		t.Errorf("stats() after deleting a journal = %+v", stats)
+	}
+}
+
+func TestWatchPreview(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	templateFile := filepath.Join(tempDir, "template.md")
+	createTestFile(t, templateFile, "# Version one {{.Date}}\n{{.TODOS}}")
+	todosFile := filepath.Join(tempDir, "todos.md")
+	createTestFile(t, todosFile, "- [ ] Sample task")
+
+	opts := previewOptions{TemplateFile: templateFile, Date: "2025-06-20", TodosFile: todosFile}
+	ctx, cancel := context.WithCancel(context.Background())
+	var out syncBuffer
+	done := make(chan error, 1)
+	go func() {
+		done <- watchPreview(ctx, &out, nil, opts, 5*time.Millisecond, newLiveConfig(&Config{}, nil, NewLogger(ModeQuiet)), NewLogger(ModeQuiet))
+	}()
+
+	waitFor(t, "first rendering", func() bool {
+		return strings.Contains(out.String(), "# Version one 2025-06-20") && strings.Contains(out.String(), "- [ ] Sample task")
+	})
+
+	createTestFile(t, templateFile, "# Version two {{.Date}}\n{{.TODOS}}")
+	waitFor(t, "template change", func() bool { return strings.Contains(out.String(), "# Version two") })
+
+	createTestFile(t, todosFile, "- [ ] Changed sample task")
+	waitFor(t, "todos change", func() bool { return strings.Contains(out.String(), "Changed sample task") })
+
+	createTestFile(t, templateFile, "# Broken {{.Date")
+	waitFor(t, "render error", func() bool { return strings.Contains(out.String(), "Render failed:") })
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("watchPreview() unexpected error: %v", err)
+	}
+}
+
+func TestLiveConfig(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", tempDir)
+	t.Setenv("TODOER_ROOT_DIR", "")
+	t.Setenv("TODOER_TEMPLATE_FILE", "")
+	configPath := filepath.Join(tempDir, ConfigDirName, ConfigFileName)
+	createTestFile(t, configPath, "root_dir = \""+tempDir+"\"\nmax_carry = 3\n")
+
+	load := commandConfigLoader("serve")
+	initial, files, err := load()
+	if err != nil || initial.MaxCarry != 3 || !reflect.DeepEqual(files, []string{configPath}) {
+		t.Fatalf("load() = %+v, %v, %v", initial, files, err)
+	}
+	configs := newLiveConfig(initial, load, NewLogger(ModeQuiet))
+
+	// An invalid configuration is not taken over
+	createTestFile(t, configPath, "root_dir = \""+tempDir+"\"\nsymlinks = \"sometimes\"\n")
+	if err := configs.reload(); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("reload() of an invalid configuration error = %v, want ErrInvalidConfig", err)
+	}
+	if configs.Snapshot() != initial {
+		t.Error("reload() replaced the configuration with an invalid one")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go configs.watch(ctx, 5*time.Millisecond)
+	createTestFile(t, configPath, "root_dir = \""+tempDir+"\"\nmax_carry = 5\n")
+	waitFor(t, "reload", func() bool { return configs.Snapshot().MaxCarry == 5 })
+	if initial.MaxCarry != 3 {
+		t.Errorf("reload changed the earlier snapshot: max_carry = %d", initial.MaxCarry)
+	}
+}
+
+func TestWatchPreview_ConfigReload(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	templateFile := filepath.Join(tempDir, "template.md")
+	createTestFile(t, templateFile, "# {{.Custom.Project}}\n{{.TODOS}}")
+
+	project := "first"
+	load := func() (*Config, []string, error) {
+		return &Config{Custom: map[string]interface{}{"Project": project}}, nil, nil
+	}
+	configs := newLiveConfig(&Config{Custom: map[string]interface{}{"Project": project}}, load, NewLogger(ModeQuiet))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var out syncBuffer
+	done := make(chan error, 1)
+	go func() {
+		done <- watchPreview(ctx, &out, nil, previewOptions{TemplateFile: templateFile, Date: "2025-06-20"}, 5*time.Millisecond, configs, NewLogger(ModeQuiet))
+	}()
+	waitFor(t, "first rendering", func() bool { return strings.Contains(out.String(), "# first") })
+
+	project = "second"
+	if err := configs.reload(); err != nil {
+		t.Fatalf("reload() error: %v", err)
+	}
+	waitFor(t, "rendering with the reloaded configuration", func() bool { return strings.Contains(out.String(), "# second") })
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("watchPreview() unexpected error: %v", err)
+	}
+}
+
+func TestWatchPreview_Serve(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	templateFile := filepath.Join(tempDir, "template.md")
+	createTestFile(t, templateFile, "# <Plan> {{.Date}}\n{{.TODOS}}")
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := previewOptions{TemplateFile: templateFile, Date: "2025-06-20", TodosString: "- [ ] Sample task"}
+	ctx, cancel := context.WithCancel(context.Background())
+	var out syncBuffer
+	done := make(chan error, 1)
+	go func() {
+		done <- watchPreview(ctx, &out, listener, opts, 5*time.Millisecond, newLiveConfig(&Config{}, nil, NewLogger(ModeQuiet)), NewLogger(ModeQuiet))
+	}()
+
+	base := "http://" + listener.Addr().String()
+	get := func(path string) string {
+		resp, err := http.Get(base + path)
+		if err != nil {
+			return ""
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	waitFor(t, "server", func() bool { return get("/version") == "1" })
+	page := get("/")
+	if !strings.Contains(page, "# &lt;Plan&gt; 2025-06-20") || !strings.Contains(page, `const version = "1"`) {
+		t.Errorf("preview page = %q", page)
+	}
+	if !strings.Contains(out.String(), "Serving preview on "+base+"/") {
+		t.Errorf("output = %q", out.String())
+	}
+
+	createTestFile(t, templateFile, "# Updated plan {{.Date}}")
+	waitFor(t, "new version", func() bool { return get("/version") == "2" })
+	if page := get("/"); !strings.Contains(page, "# Updated plan 2025-06-20") {
+		t.Errorf("updated preview page = %q", page)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("watchPreview() unexpected error: %v", err)
+	}
+	if _, err := http.Get(base + "/version"); err == nil {
+		t.Error("preview server still running after cancel")
+	}
+}
+
+func TestCmdSelftest(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	casesDir := filepath.Join(tempDir, "cases")
+	createTestFile(t, filepath.Join(casesDir, "shared_template.md"), "# {{.Date}}\n\n## Todos\n\n{{.TODOS}}\n")
+	input := "---\ntitle: 2025-06-16\n---\n\n## Todos\n\n- [[2025-06-16]]\n  - [ ] Open task\n  - [x] Done task\n"
+	for _, name := range []string{"carry", "mismatch"} {
+		createTestFile(t, filepath.Join(casesDir, name, "input.md"), input)
+		createTestFile(t, filepath.Join(casesDir, name, "expected_output.md"), "# 2025-06-17\n\n## Todos\n\n- [[2025-06-16]]\n  - [ ] Open task created::[[2025-06-16]]\n")
+		createTestFile(t, filepath.Join(casesDir, name, "expected_input_after.md"), "---\ntitle: 2025-06-16\n---\n\n## Todos\n\n- [[2025-06-16]]\n  - [x] Done task #2025-06-16")
+	}
+	createTestFile(t, filepath.Join(casesDir, "mismatch", "expected_output.md"), "# 2025-06-17\n\n## Todos\n\n- [[2025-06-16]]\n  - [ ] Other task\n")
+	createTestFile(t, filepath.Join(casesDir, "notes", "README.md"), "Not a case")
+
+	config := &Config{RootDir: tempDir, TodosHeader: "## Todos", FrontmatterDateKey: "title"}
+	logger := NewLogger(ModeQuiet)
+	opts := selftestOptions{CasesDir: casesDir, Date: "2025-06-17"}
+
+	var out bytes.Buffer
+	err := cmdSelftest(context.Background(), &out, opts, config, logger)
+	if code := exitCodeFor(err); code != ExitFailure {
+		t.Errorf("exit code = %d, want %d (err: %v)", code, ExitFailure, err)
+	}
+	wantDiff := "--- expected_output.md\n+++ actual\n@@ -3,4 +3,4 @@\n ## Todos\n \n - [[2025-06-16]]\n-  - [ ] Other task\n+  - [ ] Open task created::[[2025-06-16]]\n"
+	for _, want := range []string{"PASS carry\n", "FAIL mismatch\n" + wantDiff, "1 passed, 1 failed\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "notes") {
+		t.Errorf("directory without input.md run as a case:\n%s", out.String())
+	}
+
+	// Updating writes the actual results, after which all cases pass
+	opts.Update = true
+	out.Reset()
+	if err := cmdSelftest(context.Background(), &out, opts, config, logger); err != nil {
+		t.Fatalf("cmdSelftest() with update unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "UPDATED mismatch\n") {
+		t.Errorf("update output = %q", out.String())
+	}
+	opts.Update = false
+	out.Reset()
+	if err := cmdSelftest(context.Background(), &out, opts, config, logger); err != nil {
+		t.Fatalf("cmdSelftest() after update unexpected error: %v\n%s", err, out.String())
+	}
+
+	// The input of the case is left untouched
+	if content, _ := os.ReadFile(filepath.Join(casesDir, "carry", "input.md")); string(content) != input {
+		t.Errorf("case input modified: %q", content)
+	}
+}
+
+func TestCmdSelftest_Errors(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir, TodosHeader: "## Todos"}
+	tests := []struct {
+		name string
+		opts selftestOptions
+	}{
+		{name: "missing date", opts: selftestOptions{CasesDir: tempDir}},
+		{name: "invalid date", opts: selftestOptions{CasesDir: tempDir, Date: "17.06.2025"}},
+		{name: "missing directory", opts: selftestOptions{CasesDir: filepath.Join(tempDir, "missing"), Date: "2025-06-17"}},
+		{name: "no cases", opts: selftestOptions{CasesDir: tempDir, Date: "2025-06-17"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := cmdSelftest(context.Background(), io.Discard, tt.opts, config, NewLogger(ModeQuiet))
+			if code := exitCodeFor(err); code != ExitConfigError {
+				t.Errorf("exit code = %d, want %d (err: %v)", code, ExitConfigError, err)
+			}
+		})
+	}
+}
+
+func TestCmdBench(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	for _, date := range []string{"2025-06-16", "2025-06-17"} {
+		createTestFile(t, filepath.Join(tempDir, "2025/06", date+".md"),
+			"---\ntitle: "+date+"\n---\n\n## Todos\n\n- [["+date+"]]\n  - [ ] Open task\n  - [x] Done task\n")
+	}
+	templateFile := filepath.Join(tempDir, "template.md")
+	createTestFile(t, templateFile, "# {{.Date}}\n\n## Todos\n\n{{.TODOS}}\n")
+
+	config := &Config{RootDir: tempDir, TodosHeader: "## Todos", FrontmatterDateKey: "title"}
+	profile := filepath.Join(t.TempDir(), "cpu.pprof")
+	opts := benchOptions{TemplateFile: templateFile, Runs: 2, CPUProfile: profile}
+
+	var out bytes.Buffer
+	if err := cmdBench(context.Background(), &out, tempDir, opts, config, NewLogger(ModeQuiet)); err != nil {
+		t.Fatalf("cmdBench() unexpected error: %v\n%s", err, out.String())
+	}
+	for _, want := range append([]string{"2 journals", "fastest of 2 runs", "STAGE", "too small to judge"}, benchStages...) {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+	if info, err := os.Stat(profile); err != nil || info.Size() == 0 {
+		t.Errorf("CPU profile not written: %v", err)
+	}
+
+	// Invalid runs and an empty root directory are configuration errors
+	opts = benchOptions{TemplateFile: templateFile}
+	if err := cmdBench(context.Background(), io.Discard, tempDir, opts, config, NewLogger(ModeQuiet)); exitCodeFor(err) != ExitConfigError {
+		t.Errorf("cmdBench() with no runs error = %v, want a config error", err)
+	}
+	opts.Runs = 1
+	if err := cmdBench(context.Background(), io.Discard, t.TempDir(), opts, config, NewLogger(ModeQuiet)); exitCodeFor(err) != ExitConfigError {
+		t.Errorf("cmdBench() without journals error = %v, want a config error", err)
+	}
+}
+
+func TestPalette(t *testing.T) {
+	plain, colored := palette{}, palette{enabled: true}
+	if got := plain.red("FAIL"); got != "FAIL" {
+		t.Errorf("disabled red() = %q", got)
+	}
+	if got, want := colored.green("PASS"), "\x1b[32mPASS\x1b[0m"; got != want {
+		t.Errorf("green() = %q, want %q", got, want)
+	}
+
+	diff := "--- a\n+++ b\n@@ -1,2 +1,2 @@\n same\n-old\n+new\n"
+	if got := plain.diff(diff); got != diff {
+		t.Errorf("disabled diff() = %q", got)
+	}
+	want := "--- a\n+++ b\n\x1b[36m@@ -1,2 +1,2 @@\x1b[0m\n same\n\x1b[31m-old\x1b[0m\n\x1b[32m+new\x1b[0m\n"
+	if got := colored.diff(diff); got != want {
+		t.Errorf("diff() = %q, want %q", got, want)
+	}
+
+	// Files and NO_COLOR disable colors
+	file, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if colorEnabled(file, false) {
+		t.Error("colorEnabled() of a regular file = true")
+	}
+	t.Setenv("NO_COLOR", "1")
+	if colorEnabled(os.Stdout, false) {
+		t.Error("colorEnabled() with NO_COLOR = true")
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	if got := unifiedDiff("a", "b", "same\n", "same\n"); got != "" {
+		t.Errorf("unifiedDiff() of equal input = %q", got)
+	}
+
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b := "1\nTWO\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13"
+	want := "--- a\n+++ b\n" +
+		"@@ -1,5 +1,5 @@\n 1\n-2\n+TWO\n 3\n 4\n 5\n" +
+		"@@ -10,3 +10,4 @@\n 10\n 11\n 12\n+13\n\\ No newline at end of file\n"
+	if got := unifiedDiff("a", "b", a, b); got != want {
+		t.Errorf("unifiedDiff() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCmdMigrate(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir, TodosHeader: "## TODOS"}
+	logger := NewLogger(ModeQuiet)
+
+	first := "# 2025-06-19\n\n## TODOS\n\n- [[2025-06-19]]\n  - [ ] Task\n    - [x] Subtask\n\n## Notes\n\n  indented note\n"
+	second := "# 2025-06-20\n\n## TODOS\n\n- [[2025-06-20]]\n  - [ ] Other task\n"
+	noSection := "# 2025-06-21\n\nJust notes\n"
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-19"), first)
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-20"), second)
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-21"), noSection)
+
+	opts := migrateOptions{FromHeader: "## TODOS", ToHeader: "## Tasks", FromIndent: 2, Indent: 4, DryRun: true}
+
+	// A dry run reports without writing
+	var out bytes.Buffer
+	if err := cmdMigrate(&out, tempDir, opts, config, logger); err != nil {
+		t.Fatalf("cmdMigrate() dry run unexpected error: %v", err)
+	}
+	wantOut := "Would migrate 2025/06/2025-06-19.md (header, indentation)\n" +
+		"Would migrate 2025/06/2025-06-20.md (header, indentation)\n" +
+		"2 of 3 journals would be migrated (dry run)\n" +
+		"1 journal without a '## TODOS' section skipped\n"
+	if out.String() != wantOut {
+		t.Errorf("dry run output = %q, want %q", out.String(), wantOut)
+	}
+	if content, _ := os.ReadFile(buildJournalPath(tempDir, "2025-06-19")); string(content) != first {
+		t.Errorf("dry run modified journal: %q", content)
+	}
+
+	// With diff, the dry run shows the changes
+	opts.Diff = true
+	out.Reset()
+	if err := cmdMigrate(&out, tempDir, opts, config, logger); err != nil {
+		t.Fatalf("cmdMigrate() dry run with diff unexpected error: %v", err)
+	}
+	if want := "--- 2025/06/2025-06-20.md\n+++ 2025/06/2025-06-20.md\n@@ -1,6 +1,6 @@\n # 2025-06-20\n \n-## TODOS\n+## Tasks\n"; !strings.Contains(out.String(), want) {
+		t.Errorf("dry run diff missing %q:\n%s", want, out.String())
+	}
+
+	opts.DryRun, opts.Diff = false, false
+	out.Reset()
+	if err := cmdMigrate(&out, tempDir, opts, config, logger); err != nil {
+		t.Fatalf("cmdMigrate() unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(out.String(), "2 of 3 journals migrated\n1 journal without a '## TODOS' section skipped\n") {
+		t.Errorf("output = %q", out.String())
+	}
+
+	want := "# 2025-06-19\n\n## Tasks\n\n- [[2025-06-19]]\n    - [ ] Task\n        - [x] Subtask\n\n## Notes\n\n  indented note\n"
+	if content, _ := os.ReadFile(buildJournalPath(tempDir, "2025-06-19")); string(content) != want {
+		t.Errorf("migrated journal = %q, want %q", content, want)
+	}
+	if content, _ := os.ReadFile(buildJournalPath(tempDir, "2025-06-19") + ".bak"); string(content) != first {
+		t.Errorf("backup = %q, want original", content)
+	}
+	if _, err := os.Stat(buildJournalPath(tempDir, "2025-06-21") + ".bak"); !os.IsNotExist(err) {
+		t.Error("backup written for a journal that was not migrated")
+	}
+
+	// Running the migration again leaves migrated journals alone
+	out.Reset()
+	if err := cmdMigrate(&out, tempDir, opts, config, logger); err != nil {
+		t.Fatalf("cmdMigrate() rerun unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "0 of 3 journals migrated\n") {
+		t.Errorf("rerun output = %q", out.String())
+	}
+	if content, _ := os.ReadFile(buildJournalPath(tempDir, "2025-06-19")); string(content) != want {
+		t.Errorf("rerun changed journal: %q", content)
+	}
+
+	// The migrated journals are read by todoer with the new header
+	config.TodosHeader = "## Tasks"
+	journal, err := core.ParseTodosSectionFromContent(want, config.TodosHeader)
+	if err != nil || len(journal.Days) != 1 || len(journal.Days[0].Items) != 1 || len(journal.Days[0].Items[0].SubItems) != 1 {
+		t.Errorf("migrated journal parsed as %+v (err: %v)", journal, err)
+	}
+}
+
+func TestCmdMigrate_Errors(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir, TodosHeader: "## Todos"}
+	tests := []struct {
+		name string
+		opts migrateOptions
+	}{
+		{name: "nothing to migrate", opts: migrateOptions{FromIndent: 2}},
+		{name: "invalid indent", opts: migrateOptions{FromIndent: 2, Indent: 12}},
+		{name: "invalid header", opts: migrateOptions{ToHeader: "Tasks"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := cmdMigrate(io.Discard, tempDir, tt.opts, config, NewLogger(ModeQuiet))
+			if code := exitCodeFor(err); code != ExitConfigError {
+				t.Errorf("exit code = %d, want %d (err: %v)", code, ExitConfigError, err)
+			}
+		})
+	}
+}
+
+func TestCmdOpen(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir}
+	logger := NewLogger(ModeQuiet)
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-19"), "# 2025-06-19\n\n## Todos\n\n- [[2025-06-19]]\n  - [ ] Task\n")
+
+	// A missing journal is created like new does, carrying the open tasks over
+	var out bytes.Buffer
+	if err := cmdOpen(context.Background(), &out, tempDir, "", "2025-06-20", OpenPrint, config, logger); err != nil {
+		t.Fatalf("cmdOpen() unexpected error: %v", err)
+	}
+	path := buildJournalPath(tempDir, "2025-06-20")
+	if out.String() != path+"\n" {
+		t.Errorf("output = %q, want %q", out.String(), path+"\n")
+	}
+	content, err := os.ReadFile(path)
+	if err != nil || !strings.Contains(string(content), "- [ ] Task") {
+		t.Errorf("created journal = %q (err: %v)", content, err)
+	}
+
+	// An existing journal is opened as is
+	createTestFile(t, path, "edited\n")
+	out.Reset()
+	config.Open = OpenConfig{Vault: "My Vault", VaultPath: "Daily notes"}
+	if err := cmdOpen(context.Background(), &out, tempDir, "", "2025-06-20", OpenObsidian, config, logger); err != nil {
+		t.Fatalf("cmdOpen() obsidian unexpected error: %v", err)
+	}
+	want := "obsidian://open?vault=My%20Vault&file=Daily%20notes%2F2025%2F06%2F2025-06-20\n"
+	if out.String() != want {
+		t.Errorf("obsidian output = %q, want %q", out.String(), want)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "edited\n" {
+		t.Errorf("existing journal changed: %q", content)
+	}
+
+	// The editor gets the path of the journal
+	t.Setenv("VISUAL", "true")
+	if err := cmdOpen(context.Background(), io.Discard, tempDir, "", "2025-06-20", OpenEditor, config, logger); err != nil {
+		t.Errorf("cmdOpen() editor unexpected error: %v", err)
+	}
+	t.Setenv("VISUAL", "false")
+	if err := cmdOpen(context.Background(), io.Discard, tempDir, "", "2025-06-20", OpenEditor, config, logger); err == nil {
+		t.Error("cmdOpen() ignored a failing editor")
+	}
+
+	if got := obsidianURI("/notes/Journal", "2025/06/2025-06-20.md.age", OpenConfig{}); got != "obsidian://open?vault=Journal&file=2025%2F06%2F2025-06-20.md.age" {
+		t.Errorf("obsidianURI() = %q", got)
+	}
+
+	for _, tc := range []struct{ date, mode string }{{"20-06-2025", OpenPrint}, {"2025-06-20", "browser"}} {
+		err := cmdOpen(context.Background(), io.Discard, tempDir, "", tc.date, tc.mode, config, logger)
+		if code := exitCodeFor(err); code != ExitConfigError {
+			t.Errorf("cmdOpen(%s, %s) exit code = %d, want %d (err: %v)", tc.date, tc.mode, code, ExitConfigError, err)
+		}
+	}
+}
+
+func TestCmdDoctor(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir, FrontmatterDateKey: "title"}
+	logger := NewLogger(ModeQuiet)
+	journal := func(date string) string {
+		return "---\ntitle: " + date + "\n---\n\n## Todos\n\n- [[" + date + "]]\n  - [ ] Task\n"
+	}
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-18"), journal("2025-06-18"))
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-20"), journal("2025-06-19"))
+	createTestFile(t, filepath.Join(tempDir, "2025/05/2025-06-23.md"), journal("2025-06-23"))
+	createTestFile(t, filepath.Join(tempDir, "2025/05/2025-06-22.md"), "No todos here\n")
+	createTestFile(t, filepath.Join(tempDir, "2025/06/2025-06-22.md"), journal("2025-06-22"))
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-15")+".bak", journal("2025-06-15"))
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-18")+".tmp.12345", "partial")
+
+	var out bytes.Buffer
+	err := cmdDoctor(&out, tempDir, OutputJSON, false, config, logger)
+	if code := exitCodeFor(err); code != ExitFailure {
+		t.Errorf("exit code = %d, want %d (err: %v)", code, ExitFailure, err)
+	}
+	var report doctorReport
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("invalid JSON output %q: %v", out.String(), err)
+	}
+	kinds := make(map[string]int)
+	for _, issue := range report.Issues {
+		kinds[issue.Kind]++
+	}
+	want := map[string]int{
+		DoctorTempFile: 1, DoctorOrphanBackup: 1, DoctorDateMismatch: 1, DoctorMissingTodos: 1,
+		DoctorDuplicate: 1, DoctorMisplaced: 1, DoctorMissingDays: 2,
+	}
+	if !reflect.DeepEqual(kinds, want) {
+		t.Errorf("issue kinds = %v, want %v", kinds, want)
+	}
+	if report.Journals != 5 || report.Errors != 6 || report.Warnings != 2 {
+		t.Errorf("report = %d journals, %d errors, %d warnings, want 5, 6, 2", report.Journals, report.Errors, report.Warnings)
+	}
+
+	// Fixing repairs what it safely can and leaves the rest for a human
+	out.Reset()
+	if err := cmdDoctor(&out, tempDir, OutputText, true, config, logger); exitCodeFor(err) != ExitFailure {
+		t.Errorf("cmdDoctor() --fix error = %v, want remaining problems", err)
+	}
+	for _, line := range []string{
+		"error: 2025/06/2025-06-20.md: frontmatter title is 2025-06-19, file name says 2025-06-20 (fixed)\n",
+		"error: 2025/05/2025-06-23.md: journal belongs in 2025/06/2025-06-23.md (fixed)\n",
+		"error: 2025/05/2025-06-22.md: no '## Todos' section\n",
+		"warning: no journal for 2025-06-19\n",
+		"5 journals checked: 2 errors, 2 warnings\n",
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("output lacks %q:\n%s", line, out.String())
+		}
+	}
+	if content, _ := os.ReadFile(buildJournalPath(tempDir, "2025-06-20")); string(content) != strings.Replace(journal("2025-06-19"), "title: 2025-06-19", "title: 2025-06-20", 1) {
+		t.Errorf("fixed journal = %q", content)
+	}
+	if content, _ := os.ReadFile(buildJournalPath(tempDir, "2025-06-20") + ".bak"); string(content) != journal("2025-06-19") {
+		t.Errorf("backup = %q, want original", content)
+	}
+	for _, path := range []string{buildJournalPath(tempDir, "2025-06-15") + ".bak", buildJournalPath(tempDir, "2025-06-18") + ".tmp.12345", filepath.Join(tempDir, "2025/05/2025-06-23.md")} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s still exists", path)
+		}
+	}
+	if _, err := os.Stat(buildJournalPath(tempDir, "2025-06-23")); err != nil {
+		t.Errorf("misplaced journal not moved: %v", err)
+	}
+
+	// A healthy tree passes
+	cleanDir := t.TempDir()
+	createTestFile(t, buildJournalPath(cleanDir, "2025-06-18"), journal("2025-06-18"))
+	createTestFile(t, buildJournalPath(cleanDir, "2025-06-20"), journal("2025-06-20"))
+	out.Reset()
+	if err := cmdDoctor(&out, cleanDir, OutputText, false, config, logger); err != nil {
+		t.Errorf("cmdDoctor() healthy tree error = %v", err)
+	}
+	if out.String() != "warning: no journal for 2025-06-19\n2 journals checked: 0 errors, 1 warning\n" {
+		t.Errorf("healthy output = %q", out.String())
+	}
+}
+
+func TestJournalVersions(t *testing.T) {
+	savedVersion, savedUpgrades := version, formatUpgrades
+	defer func() { version, formatUpgrades = savedVersion, savedUpgrades }()
+	version = "v1.6.0"
+	formatUpgrades = []formatUpgrade{{
+		Since:  "v1.5.0",
+		Change: "carried tasks are marked with ⏩",
+		Upgrade: func(content string) (string, error) {
+			return strings.ReplaceAll(content, "- [>]", "- [ ] ⏩"), nil
+		},
+	}}
+
+	for _, tc := range []struct {
+		a, b string
+		want int
+		ok   bool
+	}{
+		{"v1.4.0", "v1.5.0", -1, true},
+		{"1.10", "v1.9.3", 1, true},
+		{"v2.0.0-rc.1", "v2.0.0", 0, true},
+		{"dev", "v1.0.0", 0, false},
+	} {
+		if got, ok := compareVersions(tc.a, tc.b); got != tc.want || ok != tc.ok {
+			t.Errorf("compareVersions(%s, %s) = %d, %v, want %d, %v", tc.a, tc.b, got, ok, tc.want, tc.ok)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := cmdVersion(&out, true); err != nil {
+		t.Fatalf("cmdVersion() error = %v", err)
+	}
+	var info versionInfo
+	if err := json.Unmarshal(out.Bytes(), &info); err != nil || info.Version != "v1.6.0" || info.Go == "" {
+		t.Errorf("cmdVersion() JSON = %q (err: %v)", out.String(), err)
+	}
+
+	// New journals are stamped with the running version
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+	config := &Config{RootDir: tempDir, FrontmatterDateKey: "title", StampVersion: true}
+	logger := NewLogger(ModeQuiet)
+	templateFile := filepath.Join(tempDir, "template.md")
+	createTestFile(t, templateFile, "---\ntitle: {{.Date}}\n---\n\n## Todos\n\n{{.TODOS}}\n")
+	if _, err := createJournal(context.Background(), tempDir, templateFile, "2025-06-20", config, logger); err != nil {
+		t.Fatalf("createJournal() error = %v", err)
+	}
+	if content, _ := os.ReadFile(buildJournalPath(tempDir, "2025-06-20")); !strings.HasPrefix(string(content), "---\ntitle: 2025-06-20\ntodoer_version: v1.6.0\n---\n") {
+		t.Errorf("stamped journal = %q", content)
+	}
+
+	// doctor reports journals in an older format and from newer releases
+	old := "---\ntitle: 2025-06-18\ntodoer_version: v1.4.2\n---\n\n## Todos\n\n- [[2025-06-18]]\n  - [>] Task\n"
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-18"), old)
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-19"), "---\ntitle: 2025-06-19\ntodoer_version: v2.0.0\n---\n\n## Todos\n\n- [[2025-06-19]]\n  - [ ] Task\n")
+	out.Reset()
+	if err := cmdDoctor(&out, tempDir, OutputText, false, config, logger); exitCodeFor(err) != ExitFailure {
+		t.Errorf("cmdDoctor() error = %v, want the old format reported", err)
+	}
+	for _, line := range []string{
+		"error: 2025/06/2025-06-18.md: written by todoer v1.4.2 in an older format: carried tasks are marked with ⏩ (fixable with --fix)\n",
+		"warning: 2025/06/2025-06-19.md: written by todoer v2.0.0, newer than this todoer v1.6.0, which may not process it correctly\n",
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("doctor output lacks %q:\n%s", line, out.String())
+		}
+	}
+
+	// migrate only counts them without --upgrade and upgrades them with it
+	if err := cmdMigrate(io.Discard, tempDir, migrateOptions{FromIndent: 2}, config, logger); exitCodeFor(err) != ExitConfigError {
+		t.Errorf("cmdMigrate() without changes error = %v", err)
+	}
+	out.Reset()
+	if err := cmdMigrate(&out, tempDir, migrateOptions{Upgrade: true}, config, logger); err != nil {
+		t.Fatalf("cmdMigrate() --upgrade error = %v", err)
+	}
+	if want := "Migrated 2025/06/2025-06-18.md (carried tasks are marked with ⏩)\n1 of 3 journals migrated\n"; out.String() != want {
+		t.Errorf("migrate output = %q, want %q", out.String(), want)
+	}
+	want := "---\ntitle: 2025-06-18\ntodoer_version: v1.6.0\n---\n\n## Todos\n\n- [[2025-06-18]]\n  - [ ] ⏩ Task\n"
+	if content, _ := os.ReadFile(buildJournalPath(tempDir, "2025-06-18")); string(content) != want {
+		t.Errorf("upgraded journal = %q, want %q", content, want)
+	}
+	if content, _ := os.ReadFile(buildJournalPath(tempDir, "2025-06-18") + ".bak"); string(content) != old {
+		t.Errorf("backup = %q, want original", content)
+	}
+
+	// doctor --fix upgrades them too
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-18"), old)
+	if err := cmdDoctor(io.Discard, tempDir, OutputText, true, config, logger); err != nil {
+		t.Errorf("cmdDoctor() --fix error = %v", err)
+	}
+	if content, _ := os.ReadFile(buildJournalPath(tempDir, "2025-06-18")); string(content) != want {
+		t.Errorf("fixed journal = %q, want %q", content, want)
+	}
+}
+
+func TestCatchUpJournals(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	config := &Config{RootDir: tempDir, FrontmatterDateKey: "title"}
+	logger := NewLogger(ModeQuiet)
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-16"), "---\ntitle: 2025-06-16\n---\n\n## Todos\n\n- [[2025-06-16]]\n  - [x] Done\n  - [ ] Task\n")
+
+	// A run failing on one day leaves its ledger behind
+	templateFile := filepath.Join(tempDir, "template.md")
+	createTestFile(t, templateFile, "---\ntitle: {{.Date}}\n---\n\n{{if eq .Date \"2025-06-19\"}}{{index .Custom \"missing\" \"key\"}}{{end}}## Todos\n\n{{.TODOS}}\n")
+	if _, err := catchUpJournals(context.Background(), tempDir, templateFile, "2025-06-20", false, config, logger); err == nil {
+		t.Fatal("catchUpJournals() with a failing template succeeded")
+	}
+	for date, exists := range map[string]bool{"2025-06-17": true, "2025-06-18": true, "2025-06-19": false} {
+		if _, err := os.Stat(buildJournalPath(tempDir, date)); (err == nil) != exists {
+			t.Errorf("journal of %s exists = %v, want %v", date, err == nil, exists)
+		}
+	}
+	ledgerPath, err := runLedgerPath("catch-up", tempDir)
+	if err != nil {
+		t.Fatalf("runLedgerPath() error = %v", err)
+	}
+	ledger, err := loadRunLedger(ledgerPath)
+	if err != nil || ledger == nil || len(ledger.Done) != 2 || ledger.Done[1].Target != buildJournalPath(tempDir, "2025-06-18") {
+		t.Fatalf("ledger = %+v (err: %v), want the two journals created", ledger, err)
+	}
+
+	// A new run refuses to start over, --resume continues the interrupted one
+	if _, err := catchUpJournals(context.Background(), tempDir, templateFile, "2025-06-20", false, config, logger); exitCodeFor(err) != ExitConfigError || !strings.Contains(err.Error(), "--resume") {
+		t.Errorf("catchUpJournals() without --resume error = %v", err)
+	}
+	journal17, _ := os.ReadFile(buildJournalPath(tempDir, "2025-06-17"))
+	createTestFile(t, templateFile, "---\ntitle: {{.Date}}\n---\n\n## Todos\n\n{{.TODOS}}\n")
+	summary, err := catchUpJournals(context.Background(), tempDir, templateFile, "2025-06-20", true, config, logger)
+	if err != nil {
+		t.Fatalf("catchUpJournals() --resume error = %v", err)
+	}
+	if summary.Target != buildJournalPath(tempDir, "2025-06-20") || summary.CarriedTodos != 1 {
+		t.Errorf("summary = %+v", summary)
+	}
+	if content, _ := os.ReadFile(buildJournalPath(tempDir, "2025-06-17")); string(content) != string(journal17) {
+		t.Errorf("resumed run processed 2025-06-17 again:\n%s", content)
+	}
+	if content, _ := os.ReadFile(buildJournalPath(tempDir, "2025-06-20")); !strings.Contains(string(content), "- [[2025-06-16]]\n  - [ ] Task") {
+		t.Errorf("caught up journal = %q", content)
+	}
+	if _, err := os.Stat(ledgerPath); !os.IsNotExist(err) {
+		t.Errorf("ledger left after a completed run: %v", err)
+	}
 
-			if tt.expectError && tt.errorType != nil {
-				if !errors.Is(err, tt.errorType) {
-					t.Errorf("validateConfig() error type = %T, expected error type %T", err, tt.errorType)
-				}
-			}
-		})
+	// Weekends are skipped with skip_weekends, today is always created
+	config.SkipWeekends = true
+	dates, err := catchUpDates("2025-06-20", "2025-06-24", config)
+	if err != nil || !reflect.DeepEqual(dates, []string{"2025-06-23", "2025-06-24"}) {
+		t.Errorf("catchUpDates() = %v, %v", dates, err)
 	}
 }
 
-func TestValidateCustomVariables(t *testing.T) {
-	tests := []struct {
-		name        string
-		custom      map[string]interface{}
-		expectError bool
-	}{
-		{
-			name:        "nil custom variables",
-			custom:      nil,
-			expectError: false,
-		},
-		{
-			name:        "empty custom variables",
-			custom:      map[string]interface{}{},
-			expectError: false,
-		},
-		{
-			name: "valid custom variables",
-			custom: map[string]interface{}{
-				"author":    "John Doe",
-				"project":   "Test",
-				"version":   1,
-				"active":    true,
-				"tags":      []string{"test", "demo"},
-				"numbers":   []int{1, 2, 3},
-				"mixed":     []interface{}{"string", 42, true},
-				"_private":  "value",
-				"CamelCase": "value",
-			},
-			expectError: false,
-		},
-		{
-			name: "reserved variable name",
-			custom: map[string]interface{}{
-				"TODOS": "invalid",
-			},
-			expectError: true,
-		},
-		{
-			name: "invalid variable name - starts with number",
-			custom: map[string]interface{}{
-				"123invalid": "value",
-			},
-			expectError: true,
-		},
-		{
-			name: "invalid variable name - contains special chars",
-			custom: map[string]interface{}{
-				"test-value": "value",
-			},
-			expectError: true,
-		},
-		{
-			name: "unsupported type - complex number",
-			custom: map[string]interface{}{
-				"complex": complex(1, 2),
-			},
-			expectError: true,
-		},
-		{
-			name: "unsupported type - map",
-			custom: map[string]interface{}{
-				"nested": map[string]string{"key": "value"},
-			},
-			expectError: true,
-		},
-		{
-			name: "unsupported type in array",
-			custom: map[string]interface{}{
-				"mixed": []interface{}{"valid", complex(1, 2)},
-			},
-			expectError: true,
-		},
+func TestEffectiveToday(t *testing.T) {
+	if got := configLocation(&Config{}); got != time.Local {
+		t.Errorf("configLocation() without timezone = %v, want Local", got)
+	}
+	for _, tz := range []string{"Etc/GMT+12", "Etc/GMT-14"} {
+		config := &Config{Timezone: tz}
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			t.Fatalf("LoadLocation(%s) error = %v", tz, err)
+		}
+		if got, want := effectiveToday(config), time.Now().In(loc).Format(core.DateFormat); got != want {
+			t.Errorf("effectiveToday() in %s = %s, want %s", tz, got, want)
+		}
+		if got := configNow(config).Location(); got.String() != tz {
+			t.Errorf("configNow() location = %v, want %s", got, tz)
+		}
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			err := validateCustomVariables(tt.custom)
-			if (err != nil) != tt.expectError {
-				t.Errorf("validateCustomVariables() error = %v, expectError %v", err, tt.expectError)
-			}
-		})
+	// Before the rollover hour it is still the previous day
+	config := &Config{DayRolloverHour: 4}
+	for _, tc := range []struct{ now, want string }{
+		{"2025-06-21T01:30:00", "2025-06-20"},
+		{"2025-06-21T04:00:00", "2025-06-21"},
+		{"2025-03-01T03:59:00", "2025-02-28"},
+	} {
+		now, _ := time.Parse("2006-01-02T15:04:05", tc.now)
+		if got := effectiveDay(now, config).Format(core.DateFormat); got != tc.want {
+			t.Errorf("effectiveDay(%s) = %s, want %s", tc.now, got, tc.want)
+		}
+	}
+
+	// new creates the journal of an explicit date and rejects invalid ones
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+	config = &Config{RootDir: tempDir}
+	summary, err := createJournal(context.Background(), tempDir, "", "2025-06-20", config, NewLogger(ModeQuiet))
+	if err != nil {
+		t.Fatalf("createJournal() error = %v", err)
+	}
+	if summary.Target != buildJournalPath(tempDir, "2025-06-20") {
+		t.Errorf("createJournal() target = %s", summary.Target)
+	}
+	if _, err := createJournal(context.Background(), tempDir, "", "2025-6-20", config, NewLogger(ModeQuiet)); exitCodeFor(err) != ExitConfigError {
+		t.Errorf("createJournal() invalid date error = %v", err)
 	}
 }
 
-func TestIsValidVariableName(t *testing.T) {
+func TestNewJournalDate(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	holidays := filepath.Join(tempDir, "holidays.txt")
+	createTestFile(t, holidays, "# Company holidays\n\n2025-06-23 Midsummer\n2025-12-25\n")
+	dates, err := parseHolidays([]byte("# Company holidays\n\n2025-06-23 Midsummer\n2025-12-25\n"))
+	if err != nil || !reflect.DeepEqual(dates, []string{"2025-06-23", "2025-12-25"}) {
+		t.Errorf("parseHolidays() = %v, %v", dates, err)
+	}
+	if _, err := parseHolidays([]byte("2025-06-23\nMidsummer\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("parseHolidays() error = %v, want line 2", err)
+	}
+
 	tests := []struct {
-		name     string
-		varName  string
-		expected bool
+		name   string
+		config *Config
+		date   string
+		want   string
 	}{
-		{"empty string", "", false},
-		{"valid simple name", "test", true},
-		{"valid with underscore", "test_var", true},
-		{"valid starting with underscore", "_private", true},
-		{"valid with numbers", "var123", true},
-		{"invalid starting with number", "123var", false},
-		{"invalid with dash", "test-var", false},
-		{"invalid with space", "test var", false},
-		{"invalid with special chars", "test@var", false},
-		{"valid camelCase", "camelCase", true},
-		{"valid PascalCase", "PascalCase", true},
-		{"valid UPPERCASE", "UPPERCASE", true},
+		{name: "weekends kept", config: &Config{}, date: "2025-06-21", want: "2025-06-21"},
+		{name: "workday", config: &Config{SkipWeekends: true}, date: "2025-06-20", want: "2025-06-20"},
+		{name: "saturday", config: &Config{SkipWeekends: true}, date: "2025-06-21", want: "2025-06-23"},
+		{name: "weekend before a holiday", config: &Config{SkipWeekends: true, HolidaysFile: holidays}, date: "2025-06-22", want: "2025-06-24"},
 	}
-
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := isValidVariableName(tt.varName)
-			if result != tt.expected {
-				t.Errorf("isValidVariableName(%q) = %v, expected %v", tt.varName, result, tt.expected)
+			got, err := newJournalDate(tt.date, tt.config)
+			if err != nil || got != tt.want {
+				t.Errorf("newJournalDate(%s) = %s, %v, want %s", tt.date, got, err, tt.want)
 			}
 		})
 	}
+
+	config := &Config{SkipWeekends: true, HolidaysFile: filepath.Join(tempDir, "missing.txt")}
+	if _, err := newJournalDate("2025-06-21", config); exitCodeFor(err) != ExitConfigError {
+		t.Errorf("newJournalDate() with missing holidays file error = %v", err)
+	}
 }
 
-func TestIsValidVariableType(t *testing.T) {
-	tests := []struct {
-		name     string
-		value    interface{}
-		expected bool
-	}{
-		{"string", "test", true},
-		{"int", 42, true},
-		{"int32", int32(42), true},
-		{"int64", int64(42), true},
-		{"float32", float32(3.14), true},
-		{"float64", 3.14, true},
-		{"bool", true, true},
-		{"string slice", []string{"a", "b"}, true},
-		{"int slice", []int{1, 2, 3}, true},
-		{"interface slice with valid types", []interface{}{"string", 42, true}, true},
-		{"interface slice with invalid type", []interface{}{"string", complex(1, 2)}, false},
-		{"complex number", complex(1, 2), false},
-		{"map", map[string]string{"key": "value"}, false},
-		{"struct", struct{ Name string }{Name: "test"}, false},
-		{"nil", nil, false},
+// recordingProgress records the calls it receives
+type recordingProgress struct {
+	calls []string
+}
+
+func (p *recordingProgress) Start(total int) {
+	p.calls = append(p.calls, fmt.Sprintf("start %d", total))
+}
+func (p *recordingProgress) Stage(journal, stage string) {
+	p.calls = append(p.calls, stage+" "+journal)
+}
+func (p *recordingProgress) Done(journal string) { p.calls = append(p.calls, "done "+journal) }
+func (p *recordingProgress) Finish()             { p.calls = append(p.calls, "finish") }
+
+func TestProgress(t *testing.T) {
+	tempDir, cleanup := setupTempDir(t)
+	defer cleanup()
+
+	config := &Config{RootDir: tempDir}
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-20"), "## Todos\n\n- [ ] Task\n")
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-21"), "## Todos\n\n- [x] Task\n")
+	createTestFile(t, filepath.Join(tempDir, "notes.md"), "Not a journal\n")
+	store, err := storage.Open(tempDir)
+	if err != nil {
+		t.Fatalf("storage.Open() error: %v", err)
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := isValidVariableType(tt.value)
-			if result != tt.expected {
-				t.Errorf("isValidVariableType(%v) = %v, expected %v", tt.value, result, tt.expected)
-			}
-		})
+	progress := &recordingProgress{}
+	if _, err := loadSyncJournals(store, progress, config); err != nil {
+		t.Fatalf("loadSyncJournals() error: %v", err)
+	}
+	first, second := "2025/06/2025-06-20.md", "2025/06/2025-06-21.md"
+	want := []string{
+		"start 2",
+		"read " + first, "parse " + first, "done " + first,
+		"read " + second, "parse " + second, "done " + second,
+		"finish",
+	}
+	if !reflect.DeepEqual(progress.calls, want) {
+		t.Errorf("progress calls = %q, want %q", progress.calls, want)
+	}
+
+	// Runs are reported after a second, in log lines every ten seconds
+	clock := time.Date(2025, 6, 20, 12, 0, 0, 0, time.UTC)
+	var out bytes.Buffer
+	reporter := &progressReporter{w: &out, now: func() time.Time { return clock }}
+	reporter.Start(4)
+	reporter.Stage(first, core.StageRead)
+	if out.Len() != 0 {
+		t.Errorf("progress reported before a second: %q", out.String())
+	}
+	clock = clock.Add(2 * time.Second)
+	reporter.Done(first)
+	reporter.Stage(second, core.StageParse)
+	clock = clock.Add(4 * time.Second)
+	reporter.Done(second)
+	clock = clock.Add(10 * time.Second)
+	reporter.Stage("2025/06/2025-06-22.md", core.StageRead)
+	reporter.Finish()
+	want = []string{
+		"INFO: 1/4 journals, read 2025/06/2025-06-20.md, ETA 6s",
+		"INFO: 2/4 journals, read 2025/06/2025-06-22.md, ETA 16s",
+		"INFO: 2 of 4 journals done in 16s",
+	}
+	if got := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("progress log = %q, want %q", got, want)
+	}
+
+	// Terminals get a bar redrawn in place and cleared at the end
+	out.Reset()
+	reporter = &progressReporter{w: &out, bar: true, now: func() time.Time { return clock }}
+	reporter.Start(2)
+	clock = clock.Add(3 * time.Second)
+	reporter.Done(first)
+	reporter.Finish()
+	if got, want := out.String(), "\r\x1b[K[############            ] 1/2 journals, ETA 3s\r\x1b[K"; got != want {
+		t.Errorf("progress bar = %q, want %q", got, want)
+	}
+
+	if got := newProgress(NewLogger(ModeQuiet)); got != core.NoProgress {
+		t.Errorf("newProgress() in quiet mode = %T, want core.NoProgress", got)
 	}
 }
 
-// Benchmark tests
-func BenchmarkExpandPath(b *testing.B) {
-	paths := []string{
-		"/absolute/path",
-		"relative/path",
-		"~/home/path",
-		"",
+func TestRetryPolicyConfig(t *testing.T) {
+	policy, err := retryPolicy(&Config{Retry: RetryConfig{Attempts: 4, Backoff: "50ms"}})
+	if err != nil || policy.Attempts != 4 || policy.Backoff != 50*time.Millisecond {
+		t.Errorf("retryPolicy() = %+v, %v, want 4 attempts 50ms apart", policy, err)
+	}
+	for _, retry := range []RetryConfig{{Attempts: -1}, {Attempts: 2, Backoff: "soon"}, {Attempts: 2, Backoff: "-1s"}} {
+		if _, err := retryPolicy(&Config{Retry: retry}); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("retryPolicy(%+v) error = %v, want ErrInvalidConfig", retry, err)
+		}
 	}
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		for _, path := range paths {
-			expandPath(path)
+	if timeout, err := commandTimeout("90s"); err != nil || timeout != 90*time.Second {
+		t.Errorf("commandTimeout(90s) = %v, %v", timeout, err)
+	}
+	if timeout, err := commandTimeout(""); err != nil || timeout != 0 {
+		t.Errorf("commandTimeout() = %v, %v, want no timeout", timeout, err)
+	}
+	if _, err := commandTimeout("5 minutes"); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("commandTimeout(5 minutes) error = %v, want ErrInvalidConfig", err)
+	}
+
+	defer func(watch bool) { CLI.Preview.Watch = watch }(CLI.Preview.Watch)
+	CLI.Preview.Watch = false
+	if runsUntilStopped("new") || runsUntilStopped("preview") || !runsUntilStopped("serve") {
+		t.Error("runsUntilStopped() exempts the wrong commands from the timeout")
+	}
+	CLI.Preview.Watch = true
+	if !runsUntilStopped("preview") {
+		t.Error("runsUntilStopped(preview --watch) = false, want true")
+	}
+}
+
+func TestParseFileMode(t *testing.T) {
+	for value, want := range map[string]os.FileMode{"": FilePermissions, "0600": 0600, "640": 0640} {
+		if mode, err := parseFileMode(value); err != nil || mode != want {
+			t.Errorf("parseFileMode(%q) = %v, %v, want %v", value, mode, err, want)
 		}
 	}
+	for _, value := range []string{"0644x", "0999", "01777", "0400", "u=rw"} {
+		if _, err := parseFileMode(value); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("parseFileMode(%q) error = %v, want ErrInvalidConfig", value, err)
+		}
+	}
+	if err := validateConfig(&Config{RootDir: t.TempDir(), FileMode: "0400"}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("validateConfig() error = %v, want ErrInvalidConfig for file_mode", err)
+	}
 }
 
-func BenchmarkResolveTemplate(b *testing.B) {
-	tempDir, err := os.MkdirTemp("", "todoer-bench-*")
-	if err != nil {
-		b.Fatalf("Failed to create temp dir: %v", err)
+func TestCheckReadOnly(t *testing.T) {
+	t.Cleanup(func() {
+		readOnly = false
+		CLI.New.Plan, CLI.Postpone.DryRun = false, false
+		CLI.Process.SourceFile, CLI.Process.TargetFile = "", ""
+	})
+
+	if err := checkReadOnly("new"); err != nil {
+		t.Errorf("checkReadOnly(new) without read-only error: %v", err)
+	}
+	readOnly = true
+	err := checkReadOnly("new")
+	if !errors.Is(err, ErrReadOnly) || exitCodeFor(err) != ExitWriteError {
+		t.Fatalf("checkReadOnly(new) error = %v, want ErrReadOnly with exit code %d", err, ExitWriteError)
+	}
+	if !strings.Contains(err.Error(), "new writes files") {
+		t.Errorf("error = %q, want the command named", err)
+	}
+	if err := checkReadOnly("process <source-file> <target-file>"); err == nil || !strings.Contains(err.Error(), "process writes files") {
+		t.Errorf("checkReadOnly(process) error = %v, want process refused without its arguments", err)
+	}
+	for _, command := range []string{"stats", "agenda", "preview", "open <date>", "config show"} {
+		if err := checkReadOnly(command); err != nil {
+			t.Errorf("checkReadOnly(%s) error: %v", command, err)
+		}
 	}
-	defer os.RemoveAll(tempDir)
 
-	templateFile := filepath.Join(tempDir, "template.md")
-	if err := os.WriteFile(templateFile, []byte("Test template content"), 0644); err != nil {
-		b.Fatalf("Failed to create template file: %v", err)
+	// Plans, dry runs and pipes only read
+	CLI.New.Plan, CLI.Postpone.DryRun = true, true
+	CLI.Process.SourceFile, CLI.Process.TargetFile = stdioName, stdioName
+	for _, command := range []string{"new", "postpone <file>", "process <source-file> <target-file>"} {
+		if err := checkReadOnly(command); err != nil {
+			t.Errorf("checkReadOnly(%s) without writing error: %v", command, err)
+		}
 	}
+}
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		resolveTemplate(templateFile)
+func TestReadOnlyOpen(t *testing.T) {
+	tempDir := t.TempDir()
+	config := &Config{RootDir: tempDir}
+	readOnly = true
+	t.Cleanup(func() { readOnly = false })
+
+	err := cmdOpen(context.Background(), io.Discard, tempDir, "", "2025-06-20", OpenPrint, config, NewLogger(ModeQuiet))
+	if !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("cmdOpen() of a missing journal error = %v, want ErrReadOnly", err)
+	}
+	if _, err := os.Stat(buildJournalPath(tempDir, "2025-06-20")); !os.IsNotExist(err) {
+		t.Errorf("read-only open created the journal (stat error: %v)", err)
+	}
+
+	createTestFile(t, buildJournalPath(tempDir, "2025-06-20"), "# 2025-06-20\n")
+	if err := cmdOpen(context.Background(), io.Discard, tempDir, "", "2025-06-20", OpenPrint, config, NewLogger(ModeQuiet)); err != nil {
+		t.Errorf("cmdOpen() of an existing journal error: %v", err)
+	}
+}
+
+func TestFileMode(t *testing.T) {
+	tempDir := t.TempDir()
+	fileMode = 0600
+	t.Cleanup(func() { fileMode = FilePermissions })
+
+	config := &Config{RootDir: tempDir}
+	if _, err := cmdNew(context.Background(), tempDir, "", config, NewLogger(ModeQuiet)); err != nil {
+		t.Fatalf("cmdNew() error: %v", err)
+	}
+	journals, _ := filepath.Glob(filepath.Join(tempDir, "*", "*", "*.md"))
+	if len(journals) != 1 {
+		t.Fatalf("journals = %v, want one", journals)
+	}
+	for path, want := range map[string]os.FileMode{journals[0]: 0600, filepath.Dir(journals[0]): 0700} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != want {
+			t.Errorf("permissions of %s = %v, want %v", path, info.Mode().Perm(), want)
+		}
 	}
 }
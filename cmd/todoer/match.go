@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/inful/todoer/pkg/core"
+)
+
+// taskMatch selects how commands find a task by the pattern given on the command
+// line. By default, tasks match by normalized text, then fuzzily, see
+// core.MatchTasks.
+type taskMatch struct {
+	Exact bool // The pattern is the whole text of the task
+	ID    bool // The pattern is the id:: of the task
+}
+
+// chooseTask asks which of the texts of tasks matching a pattern was meant and
+// returns its index. It is nil unless standard input and error are terminals, in
+// which case several matches are an error instead.
+var chooseTask func(texts []string) (int, error)
+
+// errNoTaskChosen is returned when the prompt of chooseTask was left without a choice
+var errNoTaskChosen = errors.New("no task chosen")
+
+// findTask returns the single open task of journal matching pattern, the journal
+// of date. A pattern matching several tasks equally well is ambiguous: the user
+// chooses one with chooseTask, if set, or must be more specific.
+func findTask(journal *core.TodoJournal, pattern string, match taskMatch, date string) (core.ItemLocation, error) {
+	var matches []core.ItemLocation
+	switch {
+	case match.ID:
+		matches = core.FindItems(journal, core.MatchTaskID(pattern, true))
+	case match.Exact:
+		matches = core.FindItems(journal, core.MatchExact(pattern, true))
+	default:
+		for _, m := range core.MatchTasks(journal, pattern, true) {
+			matches = append(matches, m.ItemLocation)
+		}
+	}
+
+	switch {
+	case len(matches) == 1:
+		return matches[0], nil
+	case len(matches) == 0 && match.ID:
+		return core.ItemLocation{}, fmt.Errorf("no open task with id::%s in journal for %s", pattern, date)
+	case len(matches) == 0:
+		return core.ItemLocation{}, fmt.Errorf("no open task matching %q in journal for %s", pattern, date)
+	}
+
+	texts := make([]string, len(matches))
+	for i, m := range matches {
+		texts[i] = m.Item.Text
+	}
+	if chooseTask != nil {
+		i, err := chooseTask(texts)
+		if err != nil {
+			return core.ItemLocation{}, err
+		}
+		return matches[i], nil
+	}
+	return core.ItemLocation{}, fmt.Errorf("pattern %q matches %d open tasks in journal for %s, be more specific or use --exact or --id: %s",
+		pattern, len(matches), date, strings.Join(texts, "; "))
+}
+
+// promptTask lists texts numbered on w and reads the number of the chosen one from r.
+func promptTask(r io.Reader, w io.Writer, texts []string) (int, error) {
+	fmt.Fprintln(w, "Several open tasks match:")
+	for i, text := range texts {
+		fmt.Fprintf(w, "  %d) %s\n", i+1, text)
+	}
+	fmt.Fprintf(w, "Task [1-%d]: ", len(texts))
+
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return 0, errNoTaskChosen
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil || n < 1 || n > len(texts) {
+		return 0, errNoTaskChosen
+	}
+	return n - 1, nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/inful/todoer/pkg/core"
+	"github.com/inful/todoer/pkg/storage"
+)
+
+// migrateOptions holds the settings of a migrate run.
+type migrateOptions struct {
+	FromHeader string // Current TODOS section header
+	ToHeader   string // New TODOS section header; empty keeps FromHeader
+	FromIndent int    // Current spaces per indentation level; 0 uses the configured indent_spaces
+	Indent     int    // New spaces per indentation level; 0 keeps FromIndent
+	Upgrade    bool   // Bring journals written in an older format up to date, see formatUpgrades
+	DryRun     bool   // Report what would change without writing
+	Diff       bool   // With DryRun, also print the changes of every journal as a unified diff
+}
+
+// reformats reports whether opts change the header or the indentation.
+func (opts migrateOptions) reformats() bool {
+	return opts.ToHeader != opts.FromHeader || opts.Indent != opts.FromIndent
+}
+
+// migrateJournal applies opts to the decoded content of a journal. It returns the new
+// content and the kinds of change made, or a nil slice if the journal does not contain
+// the section to reformat. A journal that already has the new header counts as migrated.
+func migrateJournal(content string, opts migrateOptions) (string, []string, error) {
+	header := opts.FromHeader
+	changes := []string{}
+
+	if opts.Upgrade {
+		upgraded, formats, err := upgradeJournal(content)
+		if err != nil {
+			return "", nil, err
+		}
+		content = upgraded
+		changes = append(changes, formats...)
+	}
+	// A journal without the section can still have been upgraded
+	withoutSection := func() (string, []string, error) {
+		if len(changes) > 0 || !opts.reformats() {
+			return content, changes, nil
+		}
+		return content, nil, nil
+	}
+
+	if opts.ToHeader != opts.FromHeader {
+		renamed, err := core.RenameTodosHeader(content, opts.FromHeader, opts.ToHeader)
+		switch {
+		case err == nil:
+			content = renamed
+			changes = append(changes, "header")
+		case containsTodosHeader(content, opts.ToHeader):
+			// Migrated by an earlier run, including its indentation
+			return content, changes, nil
+		default:
+			return withoutSection()
+		}
+		header = opts.ToHeader
+	} else if !containsTodosHeader(content, header) {
+		return withoutSection()
+	}
+
+	if opts.Indent != opts.FromIndent {
+		reindented, err := core.ReindentTodosSection(content, header, opts.FromIndent, opts.Indent)
+		if err != nil {
+			return "", nil, err
+		}
+		if reindented != content {
+			content = reindented
+			changes = append(changes, "indentation")
+		}
+	}
+	return content, changes, nil
+}
+
+// containsTodosHeader reports whether content has a line consisting of header.
+func containsTodosHeader(content, header string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		if strings.TrimRight(line, " \t\r") == header {
+			return true
+		}
+	}
+	return false
+}
+
+// cmdMigrate rewrites the TODOS section of every journal below rootDir from one
+// header and indentation to another, reporting each migrated journal on w. With
+// opts.Upgrade, journals written in an older format are brought up to date as well;
+// without it, they are counted and reported.
+//
+// The original of every changed journal is kept next to it with a .bak suffix, and
+// all files are written as one unit: if a write fails, the journals already written
+// are restored. When the header changes, journals already using the new header are
+// left alone, so the migration can safely be run again; a change of indentation alone
+// cannot be detected and is applied on every run. Journals without the section are
+// skipped.
+func cmdMigrate(w io.Writer, rootDir string, opts migrateOptions, config *Config, logger *Logger) error {
+	if opts.FromHeader == "" {
+		opts.FromHeader = todosHeader(config)
+	}
+	if opts.ToHeader == "" {
+		opts.ToHeader = opts.FromHeader
+	}
+	if opts.FromIndent == 0 {
+		opts.FromIndent = journalFormat(config).IndentSpaces
+	}
+	if opts.Indent == 0 {
+		opts.Indent = opts.FromIndent
+	}
+	if opts.FromIndent < 1 || opts.FromIndent > core.MaxIndentSpaces || opts.Indent < 1 || opts.Indent > core.MaxIndentSpaces {
+		return withExitCode(ExitConfigError, fmt.Errorf("indentation must be between 1 and %d spaces, got %d -> %d", core.MaxIndentSpaces, opts.FromIndent, opts.Indent))
+	}
+	if !strings.HasPrefix(opts.ToHeader, "#") || strings.Contains(opts.ToHeader, "\n") {
+		return withExitCode(ExitConfigError, fmt.Errorf("invalid header %q: must be a single markdown heading line", opts.ToHeader))
+	}
+	if !opts.reformats() && !opts.Upgrade {
+		return withExitCode(ExitConfigError, errors.New("nothing to migrate: give --to-header, --indent and/or --upgrade"))
+	}
+
+	store, err := openJournalTree(rootDir, config)
+	if err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+
+	var names []string
+	err = storage.Walk(store, "", func(info storage.FileInfo) error {
+		if _, ok := journalDateFromPath(info.Name); ok {
+			names = append(names, info.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	var backups, writes []pendingWrite
+	skipped, outdated := 0, 0
+	for _, name := range names {
+		original, err := store.Read(name)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", store.Location(name), err)
+		}
+		content, err := decodeJournal(name, original, config)
+		if err != nil {
+			return withExitCode(ExitParseError, fmt.Errorf("%s: %w", store.Location(name), err))
+		}
+
+		if !opts.Upgrade && len(pendingUpgrades(journalVersion(string(content)))) > 0 {
+			outdated++
+		}
+		migrated, changes, err := migrateJournal(string(content), opts)
+		if err != nil {
+			return withExitCode(ExitParseError, fmt.Errorf("%s: %w", store.Location(name), err))
+		}
+		if changes == nil {
+			logger.Debug("%s has no '%s' section, skipping", store.Location(name), opts.FromHeader)
+			skipped++
+			continue
+		}
+		if len(changes) == 0 {
+			continue
+		}
+
+		verb := "Migrated"
+		if opts.DryRun {
+			verb = "Would migrate"
+		}
+		fmt.Fprintf(w, "%s %s (%s)\n", verb, name, strings.Join(changes, ", "))
+		if opts.DryRun && opts.Diff {
+			fmt.Fprint(w, stdoutColors.diff(unifiedDiff(name, name, string(content), migrated)))
+		}
+		backups = append(backups, pendingWrite{name: name + ".bak", data: original})
+		writes = append(writes, pendingWrite{name: name, data: []byte(migrated)})
+	}
+
+	if !opts.DryRun && len(writes) > 0 {
+		// Backups first, so no journal is rewritten before its original is safe
+		if err := writeJournalFiles(store, append(backups, writes...), config); err != nil {
+			return withExitCode(ExitWriteError, err)
+		}
+	}
+
+	summary := fmt.Sprintf("%d of %d %s", len(writes), len(names), plural(len(names), "journal", "journals"))
+	if opts.DryRun {
+		fmt.Fprintf(w, "%s would be migrated (dry run)\n", summary)
+	} else {
+		fmt.Fprintf(w, "%s migrated\n", summary)
+	}
+	if skipped > 0 {
+		fmt.Fprintf(w, "%d %s without a '%s' section skipped\n", skipped, plural(skipped, "journal", "journals"), opts.FromHeader)
+	}
+	if outdated > 0 {
+		logger.Info("%d %s written in an older format; bring them up to date with --upgrade", outdated, plural(outdated, "journal is", "journals are"))
+	}
+	if !opts.DryRun && len(writes) > 0 && opts.ToHeader != todosHeader(config) {
+		logger.Info("Set todos_header = %q in config.toml so todoer finds the migrated sections", opts.ToHeader)
+	}
+	if !opts.DryRun && len(writes) > 0 && opts.Indent != journalFormat(config).IndentSpaces {
+		logger.Info("Set indent_spaces = %d in config.toml so todoer keeps the new indentation", opts.Indent)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/inful/todoer/pkg/core"
+)
+
+// cmdMove relocates the single open task matching pattern as selected by match,
+// together with its subtasks and bullet lines, from the journal of fromDate to the
+// journal of toDate. The task keeps its day section and is annotated with where it
+// came from, replacing an earlier such annotation, and when it was created. Both journals are rewritten together so a failure leaves neither
+// half-updated.
+func cmdMove(rootDir, pattern string, match taskMatch, fromDate, toDate string, config *Config, logger *Logger) error {
+	if strings.TrimSpace(pattern) == "" {
+		return errors.New("task pattern cannot be empty")
+	}
+	if fromDate == "" || toDate == "" {
+		return errors.New("both --from and --to dates are required")
+	}
+	if err := validateDateFormat(fromDate); err != nil {
+		return fmt.Errorf("invalid --from date: %w", err)
+	}
+	if err := validateDateFormat(toDate); err != nil {
+		return fmt.Errorf("invalid --to date: %w", err)
+	}
+	if fromDate == toDate {
+		return errors.New("--from and --to must be different dates")
+	}
+
+	store, err := openJournalTree(rootDir, config)
+	if err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+
+	parser, renderer := journalParser(config), journalRenderer(config)
+
+	fromPath := resolveJournalName(store, fromDate, config)
+	toPath := resolveJournalName(store, toDate, config)
+
+	fromContent, err := readJournalFile(store, fromPath, config)
+	if err != nil {
+		return fmt.Errorf("failed to read journal for %s: %w", fromDate, err)
+	}
+	toContent, err := readJournalFile(store, toPath, config)
+	if err != nil {
+		return fmt.Errorf("failed to read journal for %s: %w", toDate, err)
+	}
+
+	fromJournal, err := parser.ParseJournal(string(fromContent))
+	if err != nil {
+		return withExitCode(ExitParseError, core.WithFile(err, store.Location(fromPath)))
+	}
+	toJournal, err := parser.ParseJournal(string(toContent))
+	if err != nil {
+		return withExitCode(ExitParseError, core.WithFile(err, store.Location(toPath)))
+	}
+
+	loc, err := findTask(fromJournal, pattern, match, fromDate)
+	if err != nil {
+		return err
+	}
+	// The task records when it was written down, which its new journal does not tell
+	core.StampCreated(loc.Item, core.CreatedDates(fromJournal, fromDate)[loc.Item])
+	core.RemoveItem(loc)
+	core.RemoveEmptyDays(fromJournal)
+
+	// Undated tasks belong to the day of the journal they were written in
+	day := loc.Day.Date
+	if day == "" {
+		day = fromDate
+	}
+	// A task moved before names only the journal it was moved from last
+	loc.Item.Text = core.MovedFromRegex.ReplaceAllString(loc.Item.Text, "") + " (" + fmt.Sprintf(core.MovedFromTemplate, fromDate) + ")"
+	target := core.EnsureDay(toJournal, day)
+	target.Items = append(target.Items, loc.Item)
+
+	newFrom, err := renderer.ReplaceJournal(string(fromContent), fromJournal)
+	if err != nil {
+		return fmt.Errorf("failed to update journal for %s: %w", fromDate, err)
+	}
+	newTo, err := renderer.ReplaceJournal(string(toContent), toJournal)
+	if err != nil {
+		return fmt.Errorf("failed to update journal for %s: %w", toDate, err)
+	}
+	moved := renderer.Render(&core.TodoJournal{Days: []*core.DaySection{{Items: []*core.TodoItem{loc.Item}}}})
+	newTo = core.CarryFootnotes(newTo, moved, string(fromContent))
+
+	err = writeJournalFiles(store, []pendingWrite{
+		{name: fromPath, data: []byte(newFrom)},
+		{name: toPath, data: []byte(newTo)},
+	}, config)
+	if err != nil {
+		return withExitCode(ExitWriteError, err)
+	}
+
+	logger.Info("Moved task '%s' from %s to %s", loc.Item.Text, store.Location(fromPath), store.Location(toPath))
+	return nil
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/inful/todoer/pkg/core"
+	"github.com/inful/todoer/pkg/storage"
+)
+
+// notifyTimeout bounds each HTTP request made by a notifier
+const notifyTimeout = 10 * time.Second
+
+// notifyOptions holds the settings of a notify run.
+type notifyOptions struct {
+	StaleDays   int      // Days a top-level task may stay open before it is stale; 0 or less disables
+	OverdueDays int      // Grace days after the due date before a task is overdue
+	Notifiers   []string // Names of the notifiers to send to
+	DryRun      bool     // Print the notification without sending it
+}
+
+// notification is the message sent to every notifier.
+type notification struct {
+	Title   string
+	Message string
+	Alerts  []core.TaskAlert
+}
+
+// notifier delivers a notification somewhere.
+type notifier interface {
+	Notify(n notification) error
+}
+
+// notifiers maps the names accepted by --notifier and notify.notifiers to the
+// constructor of the notifier.
+var notifiers = map[string]func(*Config) (notifier, error){
+	"desktop":  newDesktopNotifier,
+	"webhook":  newWebhookNotifier,
+	"ntfy":     newNtfyNotifier,
+	"smtp":     newSMTPNotifier,
+	"sendmail": newSendmailNotifier,
+}
+
+// notifierNames lists the names of the notifiers for messages.
+const notifierNames = "desktop, webhook, ntfy, smtp or sendmail"
+
+// newNotifiers builds the notifiers named by names.
+func newNotifiers(names []string, config *Config) ([]notifier, error) {
+	var targets []notifier
+	for _, name := range names {
+		newNotifier, ok := notifiers[name]
+		if !ok {
+			return nil, withExitCode(ExitConfigError, fmt.Errorf("unknown notifier %q (use %s)", name, notifierNames))
+		}
+		target, err := newNotifier(config)
+		if err != nil {
+			return nil, withExitCode(ExitConfigError, fmt.Errorf("notifier %s: %w", name, err))
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// sendToNotifiers sends n to every target, named by the matching entry of names,
+// and returns the errors of the targets that failed.
+func sendToNotifiers(targets []notifier, names []string, n notification, logger *Logger) error {
+	var errs []error
+	for i, target := range targets {
+		if err := target.Notify(n); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", names[i], err))
+			continue
+		}
+		logger.Info("Sent notification via %s", names[i])
+	}
+	return errors.Join(errs...)
+}
+
+// desktopNotifier shows a desktop notification using notify-send or osascript.
+type desktopNotifier struct {
+	goos string
+}
+
+func newDesktopNotifier(*Config) (notifier, error) {
+	return &desktopNotifier{goos: runtime.GOOS}, nil
+}
+
+// desktopCommand returns the command showing a notification on goos.
+func desktopCommand(goos, title, message string) (string, []string) {
+	if goos == "darwin" {
+		quote := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+		script := fmt.Sprintf(`display notification "%s" with title "%s"`, quote.Replace(message), quote.Replace(title))
+		return "osascript", []string{"-e", script}
+	}
+	return "notify-send", []string{"--app-name=todoer", title, message}
+}
+
+// Notify runs the notification command of the platform.
+func (d *desktopNotifier) Notify(n notification) error {
+	command, args := desktopCommand(d.goos, n.Title, n.Message)
+	if _, err := exec.LookPath(command); err != nil {
+		return fmt.Errorf("desktop notifications need %s: %w", command, err)
+	}
+	if output, err := exec.Command(command, args...).CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(output)); msg != "" {
+			return fmt.Errorf("%s failed: %w: %s", command, err, msg)
+		}
+		return fmt.Errorf("%s failed: %w", command, err)
+	}
+	return nil
+}
+
+// webhookNotifier posts the notification as JSON to a URL.
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func newWebhookNotifier(config *Config) (notifier, error) {
+	if config.Notify.WebhookURL == "" {
+		return nil, errors.New("notify.webhook_url is not configured")
+	}
+	return &webhookNotifier{url: config.Notify.WebhookURL, client: &http.Client{Timeout: notifyTimeout}}, nil
+}
+
+// webhookTask is a task in the webhook payload
+type webhookTask struct {
+	Text string `json:"text"`
+	Kind string `json:"kind"`
+	Date string `json:"date"`
+	Days int    `json:"days"`
+}
+
+// Notify posts {"title", "message", "tasks"} to the webhook URL.
+func (h *webhookNotifier) Notify(n notification) error {
+	payload := struct {
+		Title   string        `json:"title"`
+		Message string        `json:"message"`
+		Tasks   []webhookTask `json:"tasks"`
+	}{Title: n.Title, Message: n.Message, Tasks: []webhookTask{}}
+	for _, alert := range n.Alerts {
+		payload.Tasks = append(payload.Tasks, webhookTask(alert))
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return sendNotification(h.client, "webhook", req)
+}
+
+// ntfyNotifier publishes the notification to an ntfy topic.
+type ntfyNotifier struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func newNtfyNotifier(config *Config) (notifier, error) {
+	cfg := config.Notify
+	if cfg.NtfyTopic == "" {
+		return nil, errors.New("notify.ntfy_topic is not configured")
+	}
+	server := cfg.NtfyServer
+	if server == "" {
+		server = DefaultNtfyServer
+	}
+	topicURL, err := url.JoinPath(server, cfg.NtfyTopic)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notify.ntfy_server %q: %w", server, err)
+	}
+	return &ntfyNotifier{url: topicURL, token: cfg.NtfyToken, client: &http.Client{Timeout: notifyTimeout}}, nil
+}
+
+// Notify publishes the message with the title in the Title header.
+func (t *ntfyNotifier) Notify(n notification) error {
+	req, err := http.NewRequest(http.MethodPost, t.url, strings.NewReader(n.Message))
+	if err != nil {
+		return fmt.Errorf("invalid ntfy URL: %w", err)
+	}
+	req.Header.Set("Title", n.Title)
+	req.Header.Set("Tags", "memo")
+	if t.token != "" {
+		req.Header.Set("Authorization", "Bearer "+t.token)
+	}
+	return sendNotification(t.client, "ntfy", req)
+}
+
+// sendNotification sends req and checks for a successful response.
+func sendNotification(client *http.Client, name string, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s request failed: %w", name, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s request failed: unexpected status %s", name, resp.Status)
+	}
+	return nil
+}
+
+// buildNotification summarizes alerts in a title and one line per task.
+func buildNotification(alerts []core.TaskAlert) notification {
+	var overdue, stale int
+	var lines []string
+	for _, alert := range alerts {
+		switch alert.Kind {
+		case core.AlertOverdue:
+			overdue++
+			lines = append(lines, fmt.Sprintf("- %s (overdue by %d %s)", alert.Text, alert.Days, plural(alert.Days, "day", "days")))
+		case core.AlertStale:
+			stale++
+			lines = append(lines, fmt.Sprintf("- %s (open for %d %s)", alert.Text, alert.Days, plural(alert.Days, "day", "days")))
+		}
+	}
+
+	var counts []string
+	if overdue > 0 {
+		counts = append(counts, fmt.Sprintf("%d overdue", overdue))
+	}
+	if stale > 0 {
+		counts = append(counts, fmt.Sprintf("%d stale", stale))
+	}
+	title := fmt.Sprintf("todoer: %s %s", strings.Join(counts, ", "), plural(len(alerts), "task", "tasks"))
+
+	return notification{Title: title, Message: strings.Join(lines, "\n"), Alerts: alerts}
+}
+
+// currentJournalName returns the name of the most recent journal in store that is
+// not in the future.
+func currentJournalName(store storage.Storage, today string, config *Config) (string, error) {
+	name := resolveJournalName(store, today, config)
+	if _, err := store.Stat(name); err == nil {
+		return name, nil
+	}
+	return findClosestJournalFile(store, today)
+}
+
+// cmdNotify evaluates the open tasks of the current journal below rootDir against the
+// overdue and stale thresholds and sends a notification listing the tasks that need
+// attention to every notifier in opts. The notification is also printed on w, so the
+// command can be run from cron without any notifier. Nothing is sent when no task
+// needs attention.
+func cmdNotify(w io.Writer, rootDir string, opts notifyOptions, now time.Time, config *Config, logger *Logger) error {
+	// Build the notifiers first so configuration errors surface even without alerts
+	targets, err := newNotifiers(opts.Notifiers, config)
+	if err != nil {
+		return err
+	}
+
+	store, err := openJournalTree(rootDir, config)
+	if err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+
+	today := effectiveDay(now, config).Format(core.DateFormat)
+	name, err := currentJournalName(store, today, config)
+	if err != nil {
+		return err
+	}
+	logger.Debug("Evaluating %s", store.Location(name))
+
+	journal, err := readTodoJournal(store, name, config)
+	if err != nil {
+		return err
+	}
+
+	journalDate, _ := journalDateFromPath(name)
+	alerts, err := core.FindAlerts(journal, today, opts.OverdueDays, opts.StaleDays, journalDate)
+	if err != nil {
+		return err
+	}
+	if len(alerts) == 0 {
+		fmt.Fprintln(w, "No overdue or stale tasks")
+		return nil
+	}
+
+	n := buildNotification(alerts)
+	fmt.Fprintf(w, "%s\n%s\n", n.Title, n.Message)
+	if opts.DryRun {
+		return nil
+	}
+
+	return sendToNotifiers(targets, opts.Notifiers, n, logger)
+}
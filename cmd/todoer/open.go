@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/inful/todoer/pkg/storage"
+)
+
+// Ways the open command can open a journal
+const (
+	OpenPrint    = "print"    // Print the path of the journal
+	OpenEditor   = "editor"   // Open the journal in $VISUAL or $EDITOR
+	OpenObsidian = "obsidian" // Print an obsidian://open URI for the journal
+)
+
+// validateOpenConfig checks the [open] section of the configuration.
+func validateOpenConfig(cfg OpenConfig) error {
+	switch cfg.Mode {
+	case "", OpenPrint, OpenEditor, OpenObsidian:
+	default:
+		return fmt.Errorf("%w: open.mode must be %s, %s or %s, got %q", ErrInvalidConfig, OpenPrint, OpenEditor, OpenObsidian, cfg.Mode)
+	}
+	return nil
+}
+
+// cmdOpen opens the journal for date below rootDir the way mode says, creating it
+// like new does with templateFile when it does not exist yet. The print and obsidian
+// modes write the path or URI to w.
+func cmdOpen(ctx context.Context, w io.Writer, rootDir, templateFile, date, mode string, config *Config, logger *Logger) error {
+	if err := validateDateFormat(date); err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+	if err := validateOpenConfig(OpenConfig{Mode: mode}); err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+
+	store, err := openJournalTree(rootDir, config)
+	if err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+
+	journalFile := resolveJournalName(store, date, config)
+	if _, err := store.Stat(journalFile); err != nil {
+		if readOnly {
+			return readOnlyError(fmt.Sprintf("the journal of %s is missing and creating it writes files", date))
+		}
+		summary, err := createJournal(ctx, rootDir, templateFile, date, config, logger)
+		if err != nil {
+			return err
+		}
+		for _, warning := range summary.Warnings {
+			logger.Warn("%s", warning)
+		}
+		logger.Info("Created %s", summary.Target)
+		journalFile = resolveJournalName(store, date, config)
+	}
+
+	switch mode {
+	case OpenEditor:
+		if !storage.IsLocal(store) {
+			return withExitCode(ExitConfigError, fmt.Errorf("cannot edit %s: journals in %s are not on local disk", journalFile, rootDir))
+		}
+		return runEditor(store.Location(journalFile))
+	case OpenObsidian:
+		_, err = fmt.Fprintln(w, obsidianURI(rootDir, journalFile, config.Open))
+	default:
+		_, err = fmt.Fprintln(w, store.Location(journalFile))
+	}
+	return err
+}
+
+// obsidianURI returns the obsidian://open URI of journalFile, a name relative to
+// the journal root rootDir. The vault defaults to the name of rootDir, and the file
+// is placed below cfg.VaultPath inside it. Obsidian adds the .md extension itself.
+func obsidianURI(rootDir, journalFile string, cfg OpenConfig) string {
+	vault := cfg.Vault
+	if vault == "" {
+		vault = filepath.Base(filepath.Clean(rootDir))
+	}
+	file := strings.TrimSuffix(path.Join(filepath.ToSlash(cfg.VaultPath), journalFile), ".md")
+	return "obsidian://open?vault=" + uriEscape(vault) + "&file=" + uriEscape(file)
+}
+
+// uriEscape escapes s for a query parameter the way Obsidian expects, with spaces
+// as %20 rather than +.
+func uriEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
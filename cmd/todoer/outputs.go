@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/inful/todoer/pkg/generator"
+	"github.com/inful/todoer/pkg/storage"
+)
+
+// assigneePlaceholder is replaced by the assignee in the paths of outputs split by assignee
+const assigneePlaceholder = "{assignee}"
+
+// validateOutputConfigs checks the [outputs] tables of the configuration.
+func validateOutputConfigs(outputs map[string]OutputConfig) error {
+	for name, output := range outputs {
+		if output.Path == "" {
+			return fmt.Errorf("%w: output %q has no path", ErrInvalidConfig, name)
+		}
+		switch generator.Selection(output.Select) {
+		case generator.SelectOpen, generator.SelectCompleted, generator.SelectStale:
+		default:
+			return fmt.Errorf("%w: output %q must select open, completed or stale, got %q", ErrInvalidConfig, name, output.Select)
+		}
+		if output.StaleDays < 0 {
+			return fmt.Errorf("%w: output %q stale_days cannot be negative, got %d", ErrInvalidConfig, name, output.StaleDays)
+		}
+		if output.SplitByAssignee != strings.Contains(output.Path, assigneePlaceholder) {
+			return fmt.Errorf("%w: output %q must use %s in its path exactly when split_by_assignee is set, got %q", ErrInvalidConfig, name, assigneePlaceholder, output.Path)
+		}
+	}
+	return nil
+}
+
+// outputNames returns the names of the configured outputs in alphabetical order.
+func outputNames(config *Config) []string {
+	names := make([]string, 0, len(config.Outputs))
+	for name := range config.Outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// generatorOutputs returns the configured outputs for the generator, with their
+// templates read.
+func generatorOutputs(config *Config) ([]generator.Output, error) {
+	var outputs []generator.Output
+	store := newTemplateStore()
+	for _, name := range outputNames(config) {
+		output := config.Outputs[name]
+		o := generator.Output{
+			Name:            name,
+			Select:          generator.Selection(output.Select),
+			StaleDays:       output.StaleDays,
+			SplitByAssignee: output.SplitByAssignee,
+			AnnotateCreated: output.AnnotateCreated,
+		}
+		if o.StaleDays == 0 {
+			o.StaleDays = config.Notify.StaleDays
+		}
+		if output.Template != "" {
+			source := store.load(output.Template)
+			if source.err != nil {
+				return nil, fmt.Errorf("failed to read template of output %q: %w", name, source.err)
+			}
+			o.Template = source.content
+		}
+		outputs = append(outputs, o)
+	}
+	return outputs, nil
+}
+
+// writeOutputs writes the rendered outputs of the journal source to their files in
+// root, recording them in summary. Appending outputs that selected no todos leave
+// their file alone. Outputs split by assignee write a file per assignee, with the
+// assignee in its path. The outputs of an encoded journal are encoded like it, with
+// the extension of its codec appended to their path.
+func writeOutputs(root storage.Storage, results []generator.OutputResult, source string, summary *resultSummary, config *Config, logger *Logger) error {
+	for _, result := range results {
+		output := config.Outputs[result.Name]
+		file := encodedLike(strings.ReplaceAll(output.Path, assigneePlaceholder, result.Assignee), source)
+		location := root.Location(file)
+
+		if output.Append && result.Todos == 0 {
+			logger.Debug("Output %s selected no todos, leaving %s alone", result.Name, location)
+			continue
+		}
+		content, err := io.ReadAll(result.Content)
+		if err != nil {
+			return fmt.Errorf("error rendering output %s: %v", location, err)
+		}
+		if output.Append {
+			var existing []byte
+			if _, err := root.Stat(file); err == nil {
+				if existing, err = readJournalFile(root, file, config); err != nil {
+					return fmt.Errorf("error reading output %s: %v", location, err)
+				}
+			}
+			if len(existing) > 0 && !bytes.HasSuffix(existing, []byte("\n")) {
+				existing = append(existing, '\n')
+			}
+			content = append(existing, content...)
+		}
+
+		if dir := path.Dir(file); dir != "." {
+			if err := root.MkdirAll(dir); err != nil {
+				return fmt.Errorf("error writing output %s: %v", location, err)
+			}
+		}
+		if err := writeJournalFile(root, file, content, config); err != nil {
+			return fmt.Errorf("error writing output %s: %v", location, err)
+		}
+		summary.Outputs = append(summary.Outputs, location)
+		logger.Debug("Wrote output %s to %s", result.Name, location)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/inful/todoer/pkg/core"
+)
+
+// newPlan is what new would do, as printed by new --plan.
+type newPlan struct {
+	Date           string   `json:"date"`
+	Source         string   `json:"source,omitempty"` // Previous journal carried over from, none if the journal is created from the template alone
+	Target         string   `json:"target"`
+	Template       string   `json:"template,omitempty"`
+	AlreadyExists  bool     `json:"already_exists,omitempty"` // The target exists, new would leave it alone
+	CarriedTodos   int      `json:"carried_todos"`
+	CompletedTodos int      `json:"completed_todos"`
+	Backlog        string   `json:"backlog,omitempty"`
+	BacklogTodos   int      `json:"backlog_todos,omitempty"` // Open todos beyond max_carry, moved to the backlog
+	Warnings       []string `json:"warnings"`
+}
+
+// planJournal works out what creating the journal for today below rootDir would do,
+// like createJournal, without writing anything.
+func planJournal(ctx context.Context, rootDir, templateFile, today string, config *Config, logger *Logger) (*newPlan, error) {
+	plan := &newPlan{Date: today, Warnings: []string{}}
+	if err := validateDateFormat(today); err != nil {
+		return nil, withExitCode(ExitConfigError, err)
+	}
+	if config.JournalFile != "" {
+		return nil, withExitCode(ExitConfigError, errors.New("--plan does not support a single journal_file"))
+	}
+
+	store, err := openJournalTree(rootDir, config)
+	if err != nil {
+		return nil, withExitCode(ExitConfigError, err)
+	}
+
+	journalFile := resolveJournalName(store, today, config)
+	plan.Target = store.Location(journalFile)
+	if _, err := store.Stat(journalFile); err == nil {
+		plan.AlreadyExists = true
+		return plan, nil
+	}
+
+	closest, journalFile, err := previousJournal(store, journalFile, today, config)
+	if err != nil {
+		return nil, err
+	}
+	plan.Target = store.Location(journalFile)
+	content, sourceLocation := []byte(todosHeader(config)+"\n\n"), "template"
+	if closest == "" {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("no previous journal found in %s, would create from template", rootDir))
+	} else {
+		plan.Source, sourceLocation = store.Location(closest), store.Location(closest)
+		if content, err = readJournalFile(store, closest, config); err != nil {
+			return nil, withExitCode(ExitFailure, fmt.Errorf("error reading %s: %v", plan.Source, err))
+		}
+	}
+
+	gen, templateSource, err := getGenerator(store, templateFile, today, string(content), config, logger)
+	if err == nil {
+		gen, err = withMovedMessage(gen, store, journalFile, today, config)
+	}
+	if err != nil {
+		return nil, withExitCode(ExitConfigError, err)
+	}
+	plan.Template = templateSource
+
+	result, err := gen.ProcessContext(ctx, string(content))
+	if err != nil {
+		return nil, withExitCode(ExitParseError, core.WithFile(err, sourceLocation))
+	}
+	plan.CarriedTodos = result.Stats.UncompletedTodos
+	plan.CompletedTodos = result.Stats.CompletedTodos
+	for _, w := range result.Warnings {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("%s:%d: %s", sourceLocation, w.Line, w.Message))
+	}
+	if overflow := core.CountTotalItems(journalItems(result.Overflow)); overflow > 0 {
+		plan.Backlog = store.Location(config.BacklogFile)
+		plan.BacklogTodos = overflow
+		plan.CarriedTodos -= overflow
+	}
+	return plan, nil
+}
+
+// writePlan prints plan on w as JSON.
+func writePlan(w io.Writer, plan *newPlan) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(plan)
+}
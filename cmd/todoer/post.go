@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/inful/todoer/pkg/core"
+	"github.com/inful/todoer/pkg/storage"
+)
+
+//go:embed default_post.md
+var defaultPostTemplate string
+
+// Tasks the post command can report
+const (
+	PostOpen      = "open"      // Open top-level tasks of the current journal
+	PostCompleted = "completed" // Tasks completed yesterday
+)
+
+// Chat services the post command can format messages for
+const (
+	PostSlack   = "slack"
+	PostDiscord = "discord"
+)
+
+// discordMaxContent is the length limit of a Discord message in characters
+const discordMaxContent = 2000
+
+// postOptions holds the settings of a post run.
+type postOptions struct {
+	Select       string // Tasks to post: open or completed
+	WebhookURL   string // Incoming webhook of the channel
+	Format       string // slack or discord; empty to guess from WebhookURL
+	TemplateFile string // Message template; empty for the built-in one
+	DryRun       bool   // Print the message without posting it
+}
+
+// postData is the data post templates are rendered with.
+type postData struct {
+	Select string                 // Tasks posted: open or completed
+	Date   string                 // Today for open tasks, yesterday for completed ones
+	Total  int                    // Number of tasks
+	Tasks  []string               // Texts of the tasks, without their date tag
+	Custom map[string]interface{} // Custom variables from the configuration
+}
+
+// postFormat returns the chat service of the webhook: format if set, else
+// discord for Discord webhook URLs and slack otherwise.
+func postFormat(format, webhookURL string) string {
+	if format != "" {
+		return format
+	}
+	if u, err := url.Parse(webhookURL); err == nil {
+		host := strings.ToLower(u.Hostname())
+		if host == "discord.com" || host == "discordapp.com" || strings.HasSuffix(host, ".discord.com") {
+			return PostDiscord
+		}
+	}
+	return PostSlack
+}
+
+// validatePostConfig checks the [post] table of the configuration.
+func validatePostConfig(cfg PostConfig) error {
+	switch cfg.Select {
+	case "", PostOpen, PostCompleted:
+	default:
+		return fmt.Errorf("%w: post.select must be %s or %s, got %q", ErrInvalidConfig, PostOpen, PostCompleted, cfg.Select)
+	}
+	switch cfg.Format {
+	case "", PostSlack, PostDiscord:
+	default:
+		return fmt.Errorf("%w: post.format must be %s or %s, got %q", ErrInvalidConfig, PostSlack, PostDiscord, cfg.Format)
+	}
+	if cfg.AfterProcess && cfg.WebhookURL == "" {
+		return fmt.Errorf("%w: post.after_process needs post.webhook_url", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// openPostData returns the open top-level tasks of journal for date.
+func openPostData(journal *core.TodoJournal, date string, config *Config) postData {
+	data := postData{Select: PostOpen, Date: date, Tasks: []string{}, Custom: templateVars(config)}
+	for _, item := range journalItems(journal) {
+		if item.Completed || item.Text == "" {
+			continue
+		}
+		data.Tasks = append(data.Tasks, item.Text)
+	}
+	data.Total = len(data.Tasks)
+	return data
+}
+
+// completedPostData returns the tasks completed on the day before today in the
+// journals of store. Completions are found in the journals of that day and today.
+func completedPostData(store storage.Storage, now time.Time, config *Config, logger *Logger) (postData, error) {
+	today := effectiveDay(now, config)
+	yesterday := today.AddDate(0, 0, -1).Format(core.DateFormat)
+	data := postData{Select: PostCompleted, Date: yesterday, Tasks: []string{}, Custom: templateVars(config)}
+
+	digest, err := collectDigest(store, yesterday, today.Format(core.DateFormat), config, logger)
+	if err != nil {
+		return data, err
+	}
+	for _, day := range digest.Days {
+		if day.Date == yesterday {
+			data.Tasks = day.Tasks
+		}
+	}
+	data.Total = len(data.Tasks)
+	return data, nil
+}
+
+// renderPost renders templateFile, or the built-in post template, with data and the
+// template functions enabled by config.
+func renderPost(templateFile string, data postData, config *Config) (string, error) {
+	content := defaultPostTemplate
+	if templateFile != "" {
+		source := newTemplateStore().load(templateFile)
+		if source.err != nil {
+			return "", fmt.Errorf("failed to read post template: %w", source.err)
+		}
+		content = source.content
+	}
+	if err := checkTemplateSecrets(content, config); err != nil {
+		return "", fmt.Errorf("invalid post template: %w", err)
+	}
+	tmpl, err := template.New("post").Funcs(core.TemplateFunctions(config.SprigFunctions)).Parse(content)
+	if err != nil {
+		return "", fmt.Errorf("invalid post template: %w", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render post template: %w", err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// postPayload returns the JSON body of an incoming webhook message for format.
+func postPayload(format, message string) ([]byte, error) {
+	if format == PostDiscord {
+		if runes := []rune(message); len(runes) > discordMaxContent {
+			message = string(runes[:discordMaxContent-1]) + "…"
+		}
+		return json.Marshal(map[string]string{"content": message})
+	}
+	return json.Marshal(map[string]string{"text": message})
+}
+
+// sendPost posts message to the incoming webhook at webhookURL.
+func sendPost(client *http.Client, webhookURL, format, message string) error {
+	body, err := postPayload(format, message)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s message: %w", format, err)
+	}
+	req, err := http.NewRequest(http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return sendNotification(client, format, req)
+}
+
+// cmdPost renders the open tasks of the current journal below rootDir, or the tasks
+// completed yesterday, as a chat message and posts it to the Slack or Discord webhook
+// of opts. The message is also printed on w. Nothing is posted when there are no tasks.
+func cmdPost(w io.Writer, rootDir string, opts postOptions, now time.Time, config *Config, logger *Logger) error {
+	if opts.WebhookURL == "" && !opts.DryRun {
+		return withExitCode(ExitConfigError, errors.New("post.webhook_url is not configured"))
+	}
+	format := postFormat(opts.Format, opts.WebhookURL)
+	if format != PostSlack && format != PostDiscord {
+		return withExitCode(ExitConfigError, fmt.Errorf("unknown format %q (use %s or %s)", format, PostSlack, PostDiscord))
+	}
+
+	store, err := openJournalTree(rootDir, config)
+	if err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+
+	var data postData
+	switch opts.Select {
+	case PostOpen, "":
+		today := effectiveDay(now, config).Format(core.DateFormat)
+		name, err := currentJournalName(store, today, config)
+		if err != nil {
+			return err
+		}
+		journal, err := readTodoJournal(store, name, config)
+		if err != nil {
+			return err
+		}
+		data = openPostData(journal, today, config)
+	case PostCompleted:
+		if data, err = completedPostData(store, now, config, logger); err != nil {
+			return err
+		}
+	default:
+		return withExitCode(ExitConfigError, fmt.Errorf("unknown selection %q (use %s or %s)", opts.Select, PostOpen, PostCompleted))
+	}
+	if data.Total == 0 {
+		fmt.Fprintf(w, "No %s tasks to post for %s\n", data.Select, data.Date)
+		return nil
+	}
+
+	message, err := renderPost(opts.TemplateFile, data, config)
+	if err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+	fmt.Fprintln(w, message)
+	if opts.DryRun {
+		return nil
+	}
+	if err := sendPost(&http.Client{Timeout: notifyTimeout}, opts.WebhookURL, format, message); err != nil {
+		return err
+	}
+	logger.Info("Posted %d %s %s to %s", data.Total, data.Select, plural(data.Total, "task", "tasks"), format)
+	return nil
+}
+
+// readTodoJournal reads the journal name in store and parses its todos section.
+func readTodoJournal(store storage.Storage, name string, config *Config) (*core.TodoJournal, error) {
+	content, err := readJournalFile(store, name, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", store.Location(name), err)
+	}
+	journal, err := journalParser(config).ParseJournal(string(content))
+	if err != nil {
+		return nil, withExitCode(ExitParseError, core.WithFile(err, store.Location(name)))
+	}
+	return journal, nil
+}
+
+// postAfterProcess posts the open tasks of the journal name in store, just written by
+// new or process, when post.after_process is set. A failed post does not fail the
+// run; it is recorded as a warning in summary.
+func postAfterProcess(store storage.Storage, name string, summary *resultSummary, config *Config, logger *Logger) {
+	cfg := config.Post
+	if !cfg.AfterProcess {
+		return
+	}
+	err := func() error {
+		journal, err := readTodoJournal(store, name, config)
+		if err != nil {
+			return err
+		}
+		date, ok := journalDateFromPath(name)
+		if !ok {
+			date = effectiveToday(config)
+		}
+		data := openPostData(journal, date, config)
+		if data.Total == 0 {
+			logger.Debug("No open tasks to post for %s", date)
+			return nil
+		}
+		message, err := renderPost(cfg.Template, data, config)
+		if err != nil {
+			return err
+		}
+		format := postFormat(cfg.Format, cfg.WebhookURL)
+		if err := sendPost(&http.Client{Timeout: notifyTimeout}, cfg.WebhookURL, format, message); err != nil {
+			return err
+		}
+		logger.Debug("Posted %d open %s to %s", data.Total, plural(data.Total, "task", "tasks"), format)
+		return nil
+	}()
+	if err != nil {
+		summary.addWarning("could not post open tasks: %v", err)
+		logger.Warn("could not post open tasks: %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/inful/todoer/pkg/core"
+)
+
+// postponeOptions holds the arguments of the postpone command.
+type postponeOptions struct {
+	Days     int    // Number of days to shift matching tasks by
+	Tag      string // Only postpone tasks with this tag (optional)
+	Priority string // Only postpone tasks with this priority (optional)
+	Annotate bool   // Annotate tasks in place instead of moving them
+	DryRun   bool   // Report what would change without writing the file
+}
+
+// cmdPostpone shifts the open tasks of a journal file matching the filters in opts
+// by opts.Days days and reports each postponed task on w.
+func cmdPostpone(w io.Writer, file string, opts postponeOptions, config *Config, logger *Logger) error {
+	if err := validateFilePath(file); err != nil {
+		return fmt.Errorf("invalid journal file: %w", err)
+	}
+	if opts.Days <= 0 {
+		return errors.New("--days must be a positive number")
+	}
+
+	store := localStore("")
+	content, err := readJournalFile(store, file, config)
+	if err != nil {
+		return fmt.Errorf("failed to read journal file: %w", err)
+	}
+
+	journal, err := journalParser(config).ParseJournal(string(content))
+	if err != nil {
+		return withExitCode(ExitParseError, core.WithFile(err, store.Location(file)))
+	}
+
+	// Undated tasks are postponed relative to the journal's own date
+	journalDate, err := core.ExtractDateFromFrontmatter(string(content), config.FrontmatterDateKey)
+	if err != nil {
+		return withExitCode(ExitParseError, fmt.Errorf("failed to extract date from frontmatter: %w", err))
+	}
+
+	matchers := []func(*core.TodoItem) bool{core.MatchOpen}
+	if opts.Tag != "" {
+		matchers = append(matchers, core.MatchTag(opts.Tag))
+	}
+	if opts.Priority != "" {
+		matchers = append(matchers, core.MatchPriority(opts.Priority))
+	}
+
+	postponed, err := core.PostponeItems(journal, core.MatchAll(matchers...), opts.Days, journalDate, opts.Annotate)
+	if err != nil {
+		return withExitCode(ExitParseError, err)
+	}
+
+	verb := "Postponed"
+	if opts.DryRun {
+		verb = "Would postpone"
+	}
+	for _, item := range postponed {
+		fmt.Fprintf(w, "%s '%s' from %s to %s\n", verb, item.Text, item.From, item.To)
+	}
+
+	if len(postponed) == 0 {
+		logger.Info("No open tasks matched, nothing to postpone")
+		return nil
+	}
+	if opts.DryRun {
+		return nil
+	}
+
+	updated, err := journalRenderer(config).ReplaceJournal(string(content), journal)
+	if err != nil {
+		return fmt.Errorf("failed to update journal: %w", err)
+	}
+	if err := writeJournalFile(store, file, []byte(updated), config); err != nil {
+		return withExitCode(ExitWriteError, fmt.Errorf("failed to write journal file: %w", err))
+	}
+
+	logger.Debug("Postponed %d task(s) in %s", len(postponed), file)
+	return nil
+}
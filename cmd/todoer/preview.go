@@ -1,26 +1,78 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html/template"
+	"io"
+	"net"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
 	"time"
 
 	"github.com/inful/todoer/pkg/core"
+	"github.com/inful/todoer/pkg/storage"
 )
 
-func cmdPreview(templateFile, date, todosFile, todosString, customVars string, config *Config) error {
+// previewOptions holds the inputs of a template preview.
+type previewOptions struct {
+	TemplateFile string // Template to render; empty resolves the config or embedded template
+	Date         string // Date used for template variables; empty means today
+	TodosFile    string // File containing a sample TODOS section
+	TodosString  string // Sample TODOS section, takes precedence over TodosFile
+	CustomVars   string // Custom variables as a JSON object, replacing the configured ones
+}
+
+func cmdPreview(opts previewOptions, config *Config) error {
+	output, err := renderPreview(opts, config)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(output)
+	return nil
+}
+
+// renderPreview renders the template of opts with its sample TODOS section.
+func renderPreview(opts previewOptions, config *Config) (string, error) {
+	templateOpts, err := previewTemplateOptions(opts, config)
+	if err != nil {
+		return "", err
+	}
+	output, err := core.CreateFromTemplate(templateOpts)
+	if err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+	output, err = core.MergeTodosSections(output, todosHeader(config))
+	if err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+
+	return output, nil
+}
+
+// previewTemplateOptions resolves the template, sample TODOS section and custom
+// variables of opts into the options the preview is rendered with.
+func previewTemplateOptions(opts previewOptions, config *Config) (core.TemplateOptions, error) {
+	date := opts.Date
 	if date == "" {
-		date = time.Now().Format(core.DateFormat)
+		date = effectiveToday(config)
 	}
 
 	var todosContent string
-	if todosString != "" {
-		todosContent = todosString
-	} else if todosFile != "" {
-		content, err := os.ReadFile(todosFile)
+	if opts.TodosString != "" {
+		todosContent = opts.TodosString
+	} else if opts.TodosFile != "" {
+		content, err := os.ReadFile(opts.TodosFile)
 		if err != nil {
-			return fmt.Errorf("failed to read todos file: %w", err)
+			return core.TemplateOptions{}, fmt.Errorf("failed to read todos file: %w", err)
 		}
 		todosContent = string(content)
 	} else {
@@ -40,38 +92,95 @@ func cmdPreview(templateFile, date, todosFile, todosString, customVars string, c
 	}
 
 	custom := config.Custom
-	if customVars != "" {
-		parsed, err := parseCustomVarsJSON(customVars)
+	if opts.CustomVars != "" {
+		parsed, err := parseCustomVarsJSON(opts.CustomVars)
 		if err != nil {
-			return fmt.Errorf("failed to parse custom vars: %w", err)
+			return core.TemplateOptions{}, fmt.Errorf("failed to parse custom vars: %w", err)
 		}
 		custom = parsed
 	}
+	// The preview is shown, and maybe shared, so secrets are masked instead of refused
+	custom = core.MaskSecrets(custom, config.SecretVars)
 
-	tmplSource := resolveTemplate(templateFile)
+	tmplSource := resolveTemplate(opts.TemplateFile)
 	if tmplSource.err != nil {
-		return fmt.Errorf("error resolving template: %w", tmplSource.err)
+		return core.TemplateOptions{}, fmt.Errorf("error resolving template: %w", tmplSource.err)
+	}
+	if config.StrictTemplates {
+		if err := core.CheckTemplateFunctions(tmplSource.content, custom, core.TemplateFunctions(config.SprigFunctions)); err != nil {
+			return core.TemplateOptions{}, fmt.Errorf("template %s: %w", tmplSource.name, err)
+		}
 	}
 
-	journal, err := core.ParseTodosSection(todosContent)
+	journal, err := core.ParseTodosSectionFormat(context.Background(), todosContent, journalFormat(config))
+	if err != nil {
+		return core.TemplateOptions{}, fmt.Errorf("failed to parse todos section: %w", err)
+	}
+	calendar, err := configCalendar(config)
 	if err != nil {
-		return fmt.Errorf("failed to parse todos section: %w", err)
+		return core.TemplateOptions{}, err
+	}
+	var notes storage.Storage
+	if usesNoteFields(tmplSource.content) {
+		if notes, err = openJournalTree(config.RootDir, config); err != nil {
+			return core.TemplateOptions{}, err
+		}
 	}
 
-	output, err := core.CreateFromTemplate(core.TemplateOptions{
-		Content:      tmplSource.content,
-		TodosContent: todosContent,
-		CurrentDate:  date,
-		PreviousDate: "",
-		Journal:      journal,
-		CustomVars:   custom,
-	})
+	return core.TemplateOptions{
+		Content:       tmplSource.content,
+		TodosContent:  todosContent,
+		CurrentDate:   date,
+		PreviousDate:  "",
+		Journal:       journal,
+		CustomVars:    custom,
+		Sprig:         config.SprigFunctions,
+		Calendar:      calendar,
+		Notes:         notes,
+		Deterministic: config.Deterministic,
+	}, nil
+}
+
+// cmdPreviewListVars prints the variables and functions available to the template of
+// opts, with their types, descriptions and example output for the preview date.
+func cmdPreviewListVars(w io.Writer, opts previewOptions, config *Config) error {
+	templateOpts, err := previewTemplateOptions(opts, config)
 	if err != nil {
-		return fmt.Errorf("failed to render template: %w", err)
+		return err
+	}
+	variables, functions, err := core.DescribeTemplateData(templateOpts)
+	if err != nil {
+		return withExitCode(ExitConfigError, err)
 	}
 
-	fmt.Println(output)
-	return nil
+	fmt.Fprintf(w, "Variables for %s:\n\n", templateOpts.CurrentDate)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VARIABLE\tTYPE\tEXAMPLE\tDESCRIPTION")
+	for _, v := range variables {
+		fmt.Fprintf(tw, "{{%s}}\t%s\t%s\t%s\n", v.Name, v.Type, exampleText(v.Example), v.Description)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\nFunctions:\n\n")
+	fmt.Fprintln(tw, "FUNCTION\tEXAMPLE\tOUTPUT\tDESCRIPTION")
+	for _, f := range functions {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", f.Signature, f.Example, exampleText(f.Output), f.Description)
+	}
+	return tw.Flush()
+}
+
+// exampleText shortens example output to one line of at most 40 characters.
+func exampleText(example string) string {
+	if example == "" {
+		return `""`
+	}
+	example = strings.ReplaceAll(example, "\n", `\n`)
+	if runes := []rune(example); len(runes) > 40 {
+		example = string(runes[:39]) + "…"
+	}
+	return example
 }
 
 func parseCustomVarsJSON(jsonStr string) (map[string]interface{}, error) {
@@ -86,3 +195,211 @@ func parseCustomVarsJSON(jsonStr string) (map[string]interface{}, error) {
 
 	return m, nil
 }
+
+// previewPollInterval is how often preview --watch checks the watched files for changes
+const previewPollInterval = 500 * time.Millisecond
+
+// clearScreen moves the cursor home and clears the terminal
+const clearScreen = "\033[H\033[2J"
+
+// previewPage is the page served by preview --watch --serve. It polls /version
+// and reloads itself whenever a new rendering is available.
+var previewPage = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>todoer preview</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+pre { white-space: pre-wrap; font-size: 14px; }
+.error { color: #b00020; }
+footer { color: #888; font-size: 12px; }
+</style>
+</head>
+<body>
+{{if .Err}}<pre class="error">{{.Err}}</pre>{{else}}<pre>{{.Output}}</pre>{{end}}
+<footer>Rendered at {{.Rendered}}</footer>
+<script>
+const version = "{{.Version}}";
+setInterval(async () => {
+  try {
+    const resp = await fetch("/version");
+    if ((await resp.text()) !== version) location.reload();
+  } catch (e) {}
+}, 1000);
+</script>
+</body>
+</html>
+`))
+
+// previewState is the latest rendering of a watched preview.
+type previewState struct {
+	mu       sync.Mutex
+	version  int
+	output   string
+	err      error
+	rendered time.Time
+}
+
+// update stores a new rendering.
+func (p *previewState) update(output string, err error, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.version++
+	p.output, p.err, p.rendered = output, err, now
+}
+
+// ServeHTTP serves the preview page on / and the current version on /version.
+func (p *previewState) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/":
+		data := struct {
+			Output, Err, Rendered string
+			Version               int
+		}{Output: p.output, Rendered: p.rendered.Format("15:04:05"), Version: p.version}
+		if p.err != nil {
+			data.Err = p.err.Error()
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = previewPage.Execute(w, data)
+	case "/version":
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, strconv.Itoa(p.version))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// fileStamp identifies a version of a watched file; a missing file has the zero stamp.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// statFiles returns the current stamps of files.
+func statFiles(files []string) map[string]fileStamp {
+	stamps := make(map[string]fileStamp, len(files))
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			stamps[file] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		} else {
+			stamps[file] = fileStamp{}
+		}
+	}
+	return stamps
+}
+
+// previewWatchFiles returns the files a preview of opts depends on.
+func previewWatchFiles(opts previewOptions) []string {
+	var files []string
+	if opts.TemplateFile != "" {
+		// Templates fetched from a URL are not watched
+		if file := newTemplateStore().file(opts.TemplateFile); file != "" {
+			files = append(files, file)
+		}
+	} else if configHome, err := getConfigDir(); err == nil {
+		// Watched even if it does not exist yet, so creating it is picked up
+		files = append(files, filepath.Join(configHome, ConfigDirName, TemplateFileName))
+	}
+	if opts.TodosString == "" && opts.TodosFile != "" {
+		files = append(files, opts.TodosFile)
+	}
+	return files
+}
+
+// cmdPreviewWatch renders the preview of opts and renders it again whenever the
+// template or todos file changes, until ctx is cancelled. Without serveAddr each
+// rendering replaces the previous one on w; with serveAddr the preview is served as
+// an HTML page on that address that reloads itself. Render errors are shown in place
+// of the output instead of ending the command. A changed configuration is reloaded,
+// see liveConfig, and renders the preview again.
+func cmdPreviewWatch(ctx context.Context, w io.Writer, opts previewOptions, serveAddr string, config *Config, logger *Logger) error {
+	var listener net.Listener
+	if serveAddr != "" {
+		var err error
+		listener, err = net.Listen("tcp", serveAddr)
+		if err != nil {
+			return withExitCode(ExitConfigError, fmt.Errorf("cannot serve preview: %w", err))
+		}
+	}
+	configs := newLiveConfig(config, commandConfigLoader("preview"), logger)
+	go configs.watch(ctx, configPollInterval)
+	return watchPreview(ctx, w, listener, opts, previewPollInterval, configs, logger)
+}
+
+// watchPreview implements cmdPreviewWatch, polling the watched files every interval.
+// A nil listener renders to w.
+func watchPreview(ctx context.Context, w io.Writer, listener net.Listener, opts previewOptions, interval time.Duration, configs *liveConfig, logger *Logger) error {
+	files := previewWatchFiles(opts)
+	state := &previewState{}
+
+	var config *Config // Configuration of the last rendering
+	render := func() {
+		config = configs.Snapshot()
+		output, err := renderPreview(opts, config)
+		now := time.Now()
+		state.update(output, err, now)
+
+		if listener != nil {
+			if err != nil {
+				logger.Info("Render failed: %v", err)
+			} else {
+				logger.Info("Rendered at %s", now.Format("15:04:05"))
+			}
+			return
+		}
+		fmt.Fprint(w, clearScreen)
+		if err != nil {
+			fmt.Fprintf(w, "Render failed: %v\n", err)
+		} else {
+			fmt.Fprintln(w, output)
+		}
+		fmt.Fprintf(w, "\n-- rendered at %s, watching for changes (Ctrl-C to stop)\n", now.Format("15:04:05"))
+	}
+
+	if listener != nil {
+		server := &http.Server{Handler: state, ReadHeaderTimeout: 10 * time.Second}
+		serveErr := make(chan error, 1)
+		go func() { serveErr <- server.Serve(listener) }()
+		defer func() {
+			_ = server.Close()
+			if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Error("Preview server failed: %v", err)
+			}
+		}()
+		fmt.Fprintf(w, "Serving preview on http://%s/ (Ctrl-C to stop)\n", listener.Addr())
+	}
+
+	stamps := statFiles(files)
+	render()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		current := statFiles(files)
+		changed := false
+		for file, stamp := range current {
+			if stamps[file] != stamp {
+				logger.Debug("%s changed", file)
+				changed = true
+			}
+		}
+		stamps = current
+		if configs.Snapshot() != config {
+			logger.Debug("Configuration changed")
+			changed = true
+		}
+		if changed {
+			render()
+		}
+	}
+}
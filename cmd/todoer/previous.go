@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/inful/todoer/pkg/core"
+	"github.com/inful/todoer/pkg/storage"
+)
+
+// Values of previous_journal, choosing the journal new carries over from
+const (
+	PreviousClosest       = "closest"                     // The latest journal before today, however old
+	PreviousWorkday       = "previous_workday"            // The latest journal since the previous workday
+	PreviousYesterday     = "yesterday_or_fail"           // The journal of yesterday, failing without one
+	PreviousWithin        = "within"                      // The latest journal of the last previous_journal_days days
+	PreviousWithOpenTodos = "most_recent_with_open_todos" // The latest journal with open todos
+)
+
+// ErrNoPreviousJournal is returned when yesterday_or_fail finds no journal of yesterday
+var ErrNoPreviousJournal = errors.New("no previous journal")
+
+// validatePreviousJournal checks the previous_journal strategy and its settings.
+func validatePreviousJournal(config *Config) error {
+	switch config.PreviousJournal {
+	case "", PreviousClosest, PreviousWorkday, PreviousYesterday, PreviousWithOpenTodos:
+	case PreviousWithin:
+		if config.PreviousJournalDays <= 0 {
+			return fmt.Errorf("%w: previous_journal = %q needs previous_journal_days of at least 1, got %d", ErrInvalidConfig, PreviousWithin, config.PreviousJournalDays)
+		}
+	default:
+		return fmt.Errorf("%w: previous_journal must be %s, %s, %s, %s or %s, got %q", ErrInvalidConfig,
+			PreviousClosest, PreviousWorkday, PreviousYesterday, PreviousWithin, PreviousWithOpenTodos, config.PreviousJournal)
+	}
+	return nil
+}
+
+// selectPreviousJournal returns the journal in store before today that new carries
+// over from with the configured previous_journal strategy, or an empty name if
+// there is none and the journal is created from the template alone.
+func selectPreviousJournal(store storage.Storage, today string, config *Config) (string, error) {
+	if err := validatePreviousJournal(config); err != nil {
+		return "", withExitCode(ExitConfigError, err)
+	}
+	t, err := time.Parse(core.DateFormat, today)
+	if err != nil {
+		return "", withExitCode(ExitConfigError, fmt.Errorf("%w: expected format YYYY-MM-DD, got %s", ErrInvalidDate, today))
+	}
+
+	// Journals before today, latest first
+	type candidate struct{ name, date string }
+	var candidates []candidate
+	err = storage.Walk(store, "", func(info storage.FileInfo) error {
+		if date, ok := journalDateFromPath(info.Name); ok && date < today {
+			candidates = append(candidates, candidate{info.Name, date})
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to scan journals in %s: %w", store.Location(""), err)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].date > candidates[j].date
+	})
+
+	earliest := ""
+	switch config.PreviousJournal {
+	case PreviousWorkday:
+		calendar, err := configCalendar(config)
+		if err != nil {
+			return "", err
+		}
+		earliest = calendar.PreviousWorkday(t).Format(core.DateFormat)
+	case PreviousYesterday:
+		yesterday := t.AddDate(0, 0, -1).Format(core.DateFormat)
+		if len(candidates) == 0 || candidates[0].date != yesterday {
+			return "", fmt.Errorf("%w: no journal of %s in %s", ErrNoPreviousJournal, yesterday, store.Location(""))
+		}
+	case PreviousWithin:
+		earliest = t.AddDate(0, 0, -config.PreviousJournalDays).Format(core.DateFormat)
+	case PreviousWithOpenTodos:
+		parser := journalParser(config)
+		for _, c := range candidates {
+			content, err := readJournalFile(store, c.name, config)
+			if err != nil {
+				return "", fmt.Errorf("error reading %s: %w", store.Location(c.name), err)
+			}
+			journal, err := parser.ParseJournal(string(content))
+			if err != nil {
+				return "", withExitCode(ExitParseError, core.WithFile(err, store.Location(c.name)))
+			}
+			if _, open := core.SplitJournal(journal); !open.IsEmpty() {
+				return c.name, nil
+			}
+		}
+		return "", nil
+	}
+
+	if len(candidates) == 0 || candidates[0].date < earliest {
+		return "", nil
+	}
+	return candidates[0].name, nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/inful/todoer/pkg/core"
+	"github.com/inful/todoer/pkg/storage"
+)
+
+const (
+	// progressDelay is how long a batch runs before its progress is reported, so
+	// that short runs stay quiet
+	progressDelay = time.Second
+	// progressRedrawInterval is how often the progress bar is redrawn at most
+	progressRedrawInterval = 100 * time.Millisecond
+	// progressLogInterval is how often progress is logged when standard error is no terminal
+	progressLogInterval = 10 * time.Second
+	// progressBarWidth is the number of cells of the progress bar
+	progressBarWidth = 24
+)
+
+// progressReporter reports the progress of batch runs on w: as a bar redrawn in
+// place on a terminal, as a log line every progressLogInterval otherwise.
+type progressReporter struct {
+	w        io.Writer
+	bar      bool             // Redraw a bar in place instead of logging lines
+	now      func() time.Time // Clock of the run
+	total    int              // Journals of the run
+	done     int              // Journals finished
+	journal  string           // Journal being worked on
+	stage    string           // Stage of the journal being worked on
+	start    time.Time        // Start of the run
+	last     time.Time        // Time of the last report
+	reported bool             // Whether anything was reported yet
+}
+
+// newProgress returns the progress reporter of a batch run on standard error,
+// or core.NoProgress in quiet mode.
+func newProgress(logger *Logger) core.Progress {
+	if logger.mode == ModeQuiet {
+		return core.NoProgress
+	}
+	return &progressReporter{w: os.Stderr, bar: isTerminal(os.Stderr) && os.Getenv("TERM") != "dumb", now: time.Now}
+}
+
+// Start begins a run over total journals.
+func (p *progressReporter) Start(total int) {
+	p.total, p.done, p.reported = total, 0, false
+	p.start = p.now()
+}
+
+// Stage notes the journal and stage being worked on.
+func (p *progressReporter) Stage(journal, stage string) {
+	p.journal, p.stage = journal, stage
+	p.report()
+}
+
+// Done counts a finished journal.
+func (p *progressReporter) Done(journal string) {
+	p.done++
+	p.report()
+}
+
+// Finish clears the bar, or logs the end of a run whose progress was logged.
+func (p *progressReporter) Finish() {
+	if !p.reported {
+		return
+	}
+	if p.bar {
+		fmt.Fprint(p.w, "\r\x1b[K")
+		return
+	}
+	fmt.Fprintf(p.w, "INFO: %d of %d %s done in %s\n", p.done, p.total, plural(p.total, "journal", "journals"),
+		p.now().Sub(p.start).Round(time.Second))
+}
+
+// report reports the progress if the run has taken long enough and the last
+// report is old enough.
+func (p *progressReporter) report() {
+	now := p.now()
+	interval := progressLogInterval
+	if p.bar {
+		interval = progressRedrawInterval
+	}
+	if now.Sub(p.start) < progressDelay || (p.reported && now.Sub(p.last) < interval) {
+		return
+	}
+	p.last, p.reported = now, true
+	if p.bar {
+		filled := 0
+		if p.total > 0 {
+			filled = progressBarWidth * p.done / p.total
+		}
+		fmt.Fprintf(p.w, "\r\x1b[K[%s%s] %s", strings.Repeat("#", filled), strings.Repeat(" ", progressBarWidth-filled), p.status(now))
+		return
+	}
+	fmt.Fprintf(p.w, "INFO: %s\n", p.status(now))
+}
+
+// status describes the progress as of now: the journals done, the journal being
+// worked on and the estimated time left.
+func (p *progressReporter) status(now time.Time) string {
+	status := fmt.Sprintf("%d/%d %s", p.done, p.total, plural(p.total, "journal", "journals"))
+	if p.journal != "" && p.done < p.total {
+		status += fmt.Sprintf(", %s %s", p.stage, p.journal)
+	}
+	if p.done > 0 && p.done < p.total {
+		elapsed := now.Sub(p.start)
+		left := time.Duration(float64(elapsed) * float64(p.total-p.done) / float64(p.done))
+		status += fmt.Sprintf(", ETA %s", left.Round(time.Second))
+	}
+	return status
+}
+
+// walkJournals calls fn for every journal below store in path order, with the
+// date of its file name, reporting the journals done to progress. It stops at
+// the first error of fn.
+func walkJournals(store storage.Storage, progress core.Progress, fn func(info storage.FileInfo, date string) error) error {
+	var journals []storage.FileInfo
+	err := storage.Walk(store, "", func(info storage.FileInfo) error {
+		if _, ok := journalDateFromPath(info.Name); ok {
+			journals = append(journals, info)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	progress.Start(len(journals))
+	defer progress.Finish()
+	for _, info := range journals {
+		date, _ := journalDateFromPath(info.Name)
+		err := fn(info, date)
+		progress.Done(info.Name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+
+	"github.com/inful/todoer/pkg/storage"
+)
+
+// ErrReadOnly is returned for commands that would write files while todoer is read-only
+var ErrReadOnly = errors.New("read-only")
+
+// readOnly forbids writing files, including caches; main sets it from the
+// --read-only flag and the configuration
+var readOnly bool
+
+// fileMode holds the permissions of the files todoer writes; main sets it from
+// the configuration. Directories get storage.DirMode(fileMode).
+var fileMode fs.FileMode = FilePermissions
+
+// parseFileMode parses the file_mode setting, an octal mode such as "0600". An
+// empty value keeps FilePermissions. The owner must be able to read and write
+// the files, or todoer could not update its own journals.
+func parseFileMode(value string) (fs.FileMode, error) {
+	if value == "" {
+		return FilePermissions, nil
+	}
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("%w: file_mode must be an octal mode such as \"0600\", got %q", ErrInvalidConfig, value)
+	}
+	if mode&0600 != 0600 {
+		return 0, fmt.Errorf("%w: file_mode must let the owner read and write, got %q", ErrInvalidConfig, value)
+	}
+	return fs.FileMode(mode), nil
+}
+
+// dirMode returns the permissions of the directories todoer creates.
+func dirMode() fs.FileMode {
+	return storage.DirMode(fileMode)
+}
+
+// writesFiles reports whether command, with its flags, writes files and so
+// cannot run while todoer is read-only. Dry runs, plans and output to stdout
+// only read. open only writes when the journal is missing, which cmdOpen checks.
+func writesFiles(command string) bool {
+	switch command {
+	case "new":
+		return !CLI.New.Plan
+	case "process <source-file>", "process <source-file> <target-file>":
+		toStdout := CLI.Process.ModifiedOut == "" || CLI.Process.ModifiedOut == stdioName
+		return CLI.Process.SourceFile != stdioName || CLI.Process.TargetFile != stdioName || !toStdout
+	case "postpone <file>":
+		return !CLI.Postpone.DryRun
+	case "migrate":
+		return !CLI.Migrate.DryRun
+	case "doctor":
+		return CLI.Doctor.Fix
+	case "selftest":
+		return CLI.Selftest.Update
+	case "heatmap":
+		return CLI.Heatmap.OutputFile != ""
+	case "export", "export <file>":
+		return CLI.Export.File != ""
+	case "move <pattern>", "backlog pull", "backlog pull <numbers>",
+		"timer start <task>", "timer stop", "timer cancel", "conflicts resolve",
+		"serve", "sync caldav", "sync taskwarrior",
+		"import <file>", "templates new <name>", "templates edit <template>",
+		"config init":
+		return true
+	}
+	return false
+}
+
+// checkReadOnly returns an ErrReadOnly error if command writes files while todoer is read-only.
+func checkReadOnly(command string) error {
+	if !readOnly || !writesFiles(command) {
+		return nil
+	}
+	var words []string
+	for _, word := range strings.Fields(command) {
+		if !strings.HasPrefix(word, "<") {
+			words = append(words, word)
+		}
+	}
+	return readOnlyError(strings.Join(words, " ") + " writes files")
+}
+
+// readOnlyError returns the error of an action refused because todoer is read-only.
+func readOnlyError(action string) error {
+	return withExitCode(ExitWriteError, fmt.Errorf("%w: %s; turn off --read-only and read_only in the config to allow it", ErrReadOnly, action))
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// configPollInterval is how often long-running commands check their config files for changes
+const configPollInterval = 2 * time.Second
+
+// restartSettings are the settings long-running commands read once when they
+// start; changing them only takes effect after a restart.
+var restartSettings = []string{"RootDir", "ReadOnly", "FileMode", "Timeout", "Retry"}
+
+// configLoader loads a configuration the way main does, returning it with the
+// config files it was read from. Files are returned even when loading fails, so
+// that fixing them is noticed.
+type configLoader func() (*Config, []string, error)
+
+// commandConfigLoader returns the configLoader of command: config.toml, and the
+// project-local config file command finds, validated.
+func commandConfigLoader(command string) configLoader {
+	return func() (*Config, []string, error) {
+		configPath, err := configFilePath()
+		if err != nil {
+			return nil, nil, err
+		}
+		files := []string{configPath}
+		loaded, err := readConfig(configPath, localConfigLookup(command))
+		if err != nil {
+			return nil, files, err
+		}
+		if loaded.LocalPath != "" {
+			files = append(files, loaded.LocalPath)
+		}
+		if err := validateConfig(loaded.Config); err != nil {
+			return nil, files, fmt.Errorf("configuration validation failed: %w", err)
+		}
+		return loaded.Config, files, nil
+	}
+}
+
+// liveConfig is the configuration of a long-running command, such as serve or
+// preview --watch, which reloads it when its config files change or on SIGHUP.
+// A reload replaces the whole configuration at once: a Snapshot is never
+// changed afterwards, so goroutines working with one never see a half-updated
+// configuration. An invalid configuration is reported and the active one kept.
+type liveConfig struct {
+	current atomic.Pointer[Config]
+	load    configLoader         // Loads the configuration again; nil never reloads
+	files   []string             // Config files watched for changes
+	stamps  map[string]fileStamp // Stamps of files when the configuration was loaded
+	logger  *Logger
+}
+
+// newLiveConfig returns the live configuration starting as config, reloaded with
+// load. A nil load keeps config for good.
+func newLiveConfig(config *Config, load configLoader, logger *Logger) *liveConfig {
+	c := &liveConfig{load: load, logger: logger}
+	c.current.Store(config)
+	if load != nil {
+		_, c.files, _ = load()
+		c.stamps = statFiles(c.files)
+	}
+	return c
+}
+
+// Snapshot returns the active configuration. It must not be modified: work that
+// needs the configuration to stay the same takes one snapshot and uses it throughout.
+func (c *liveConfig) Snapshot() *Config {
+	return c.current.Load()
+}
+
+// reload loads the configuration again and makes it active if it is valid.
+func (c *liveConfig) reload() error {
+	if c.load == nil {
+		return nil
+	}
+	config, files, err := c.load()
+	if files != nil {
+		c.files = files
+	}
+	if err != nil {
+		return err
+	}
+
+	active := reflect.ValueOf(c.Snapshot()).Elem()
+	reloaded := reflect.ValueOf(config).Elem()
+	for _, name := range restartSettings {
+		if !reflect.DeepEqual(active.FieldByName(name).Interface(), reloaded.FieldByName(name).Interface()) {
+			c.logger.Warn("The change of %s takes effect after a restart", configKey(name))
+		}
+	}
+	c.current.Store(config)
+	return nil
+}
+
+// watch reloads the configuration whenever a config file changes, checked every
+// interval, and when the process receives SIGHUP, until ctx is cancelled.
+func (c *liveConfig) watch(ctx context.Context, interval time.Duration) {
+	if c.load == nil {
+		return
+	}
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			c.logger.Debug("Received SIGHUP")
+		case <-ticker.C:
+			if reflect.DeepEqual(statFiles(c.files), c.stamps) {
+				continue
+			}
+		}
+		if err := c.reload(); err != nil {
+			c.logger.Error("Keeping the active configuration: %v", err)
+		} else {
+			c.logger.Info("Reloaded the configuration")
+		}
+		c.stamps = statFiles(c.files)
+	}
+}
+
+// configKey returns the TOML key of the Config field name.
+func configKey(name string) string {
+	if field, ok := reflect.TypeOf(Config{}).FieldByName(name); ok {
+		return field.Tag.Get("toml")
+	}
+	return name
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/inful/todoer/pkg/core"
+	"github.com/inful/todoer/pkg/storage"
+)
+
+// Files making up a selftest case directory
+const (
+	caseInputFile          = "input.md"
+	caseExpectedOutputFile = "expected_output.md"
+	caseExpectedInputFile  = "expected_input_after.md"
+	caseTemplateFile       = "template.md"
+	sharedTemplateFile     = "shared_template.md"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+// selftestOptions holds the settings of a selftest run.
+type selftestOptions struct {
+	CasesDir     string // Directory containing one subdirectory per case
+	Date         string // Date the cases are processed on
+	TemplateFile string // Template used by cases without their own
+	Update       bool   // Write the actual results as the new expected files
+}
+
+// cmdSelftest runs every case below opts.CasesDir through the same pipeline as
+// process and compares the results against the expected files, reporting a diff on
+// w for each mismatch.
+//
+// A case is a directory containing input.md, the journal to process,
+// expected_output.md, the expected new journal, and expected_input_after.md, the
+// expected input journal after processing. The template is the case's template.md,
+// else shared_template.md in the cases directory, else opts.TemplateFile or the
+// configured template. This is the layout of tests/testdata.
+func cmdSelftest(ctx context.Context, w io.Writer, opts selftestOptions, config *Config, logger *Logger) error {
+	if opts.Date == "" {
+		return withExitCode(ExitConfigError, errors.New("a date to process the cases on is required (--date)"))
+	}
+	if err := validateDateFormat(opts.Date); err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+
+	entries, err := os.ReadDir(opts.CasesDir)
+	if err != nil {
+		return withExitCode(ExitConfigError, fmt.Errorf("failed to read cases directory: %w", err))
+	}
+	var cases []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(opts.CasesDir, entry.Name(), caseInputFile)); err == nil {
+			cases = append(cases, entry.Name())
+		}
+	}
+	if len(cases) == 0 {
+		return withExitCode(ExitConfigError, fmt.Errorf("no cases found in %s (each case needs a directory with %s)", opts.CasesDir, caseInputFile))
+	}
+	sort.Strings(cases)
+
+	failed := 0
+	for _, name := range cases {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		diffs, err := runSelftestCase(ctx, filepath.Join(opts.CasesDir, name), opts, config, logger)
+		switch {
+		case err != nil:
+			failed++
+			fmt.Fprintf(w, "%s %s: %v\n", stdoutColors.red("FAIL"), name, err)
+		case len(diffs) == 0:
+			fmt.Fprintf(w, "%s %s\n", stdoutColors.green("PASS"), name)
+		case opts.Update:
+			fmt.Fprintf(w, "UPDATED %s\n", name)
+		default:
+			failed++
+			fmt.Fprintf(w, "%s %s\n", stdoutColors.red("FAIL"), name)
+			for _, diff := range diffs {
+				fmt.Fprint(w, stdoutColors.diff(diff))
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "%d passed, %d failed\n", len(cases)-failed, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d %s failed", failed, len(cases), plural(len(cases), "case", "cases"))
+	}
+	return nil
+}
+
+// runSelftestCase processes the input of the case in dir in a scratch directory and
+// returns a diff for every expected file that does not match. With opts.Update the
+// mismatching expected files are overwritten instead.
+func runSelftestCase(ctx context.Context, dir string, opts selftestOptions, config *Config, logger *Logger) ([]string, error) {
+	input, err := os.ReadFile(filepath.Join(dir, caseInputFile))
+	if err != nil {
+		return nil, err
+	}
+
+	templateFile := opts.TemplateFile
+	for _, candidate := range []string{filepath.Join(dir, caseTemplateFile), filepath.Join(filepath.Dir(dir), sharedTemplateFile)} {
+		if _, err := os.Stat(candidate); err == nil {
+			templateFile = candidate
+			break
+		}
+	}
+
+	scratch, err := os.MkdirTemp("", "todoer-selftest-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scratch directory: %w", err)
+	}
+	defer os.RemoveAll(scratch)
+
+	store := storage.NewLocal(scratch)
+	if err := store.Write(caseInputFile, strings.NewReader(string(input))); err != nil {
+		return nil, err
+	}
+	summary := newResultSummary("selftest")
+	if err := processJournalIn(ctx, store, store, caseInputFile, "output.md", templateFile, opts.Date, false, core.DayRange{}, summary, config, logger.WithMode(ModeQuiet)); err != nil {
+		return nil, err
+	}
+
+	actual := map[string][]byte{}
+	if actual[caseExpectedOutputFile], err = store.Read("output.md"); err != nil {
+		return nil, err
+	}
+	if actual[caseExpectedInputFile], err = store.Read(caseInputFile); err != nil {
+		return nil, err
+	}
+
+	var diffs []string
+	for _, file := range []string{caseExpectedOutputFile, caseExpectedInputFile} {
+		expectedPath := filepath.Join(dir, file)
+		expected, err := os.ReadFile(expectedPath)
+		if err != nil && !(opts.Update && errors.Is(err, os.ErrNotExist)) {
+			return nil, err
+		}
+		if string(expected) == string(actual[file]) {
+			continue
+		}
+		if opts.Update {
+			if err := safeWriteFile(expectedPath, actual[file], fileMode); err != nil {
+				return nil, withExitCode(ExitWriteError, fmt.Errorf("failed to update %s: %w", expectedPath, err))
+			}
+		}
+		diffs = append(diffs, unifiedDiff(file, "actual", string(expected), string(actual[file])))
+	}
+	return diffs, nil
+}
+
+// diffOp is a line of a diff script: kept (' '), removed ('-') or added ('+')
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// unifiedDiff returns a unified diff turning a into b, labelled with the file names
+// nameA and nameB, or an empty string if they are equal.
+func unifiedDiff(nameA, nameB, a, b string) string {
+	if a == b {
+		return ""
+	}
+	linesA := splitDiffLines(a)
+	linesB := splitDiffLines(b)
+
+	// lcs[i][j] is the length of the longest common subsequence of linesA[i:] and linesB[j:]
+	lcs := make([][]int, len(linesA)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(linesB)+1)
+	}
+	for i := len(linesA) - 1; i >= 0; i-- {
+		for j := len(linesB) - 1; j >= 0; j-- {
+			if linesA[i] == linesB[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	// Walk the table into a script of kept (' '), removed ('-') and added ('+') lines
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(linesA) || j < len(linesB) {
+		switch {
+		case i < len(linesA) && j < len(linesB) && linesA[i] == linesB[j]:
+			ops = append(ops, diffOp{' ', linesA[i]})
+			i++
+			j++
+		case i < len(linesA) && (j == len(linesB) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{'-', linesA[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', linesB[j]})
+			j++
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", nameA, nameB)
+	for start := 0; start < len(ops); {
+		// Find the next change and the end of its hunk
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+		// A hunk extends over runs of unchanged lines short enough to be shared context
+		from := max(start-diffContext, 0)
+		end, unchanged := start, 0
+		for ; end < len(ops) && unchanged <= 2*diffContext; end++ {
+			if ops[end].kind == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+		}
+		end -= max(unchanged-diffContext, 0)
+
+		lineA, lineB := 1, 1
+		for _, o := range ops[:from] {
+			if o.kind != '+' {
+				lineA++
+			}
+			if o.kind != '-' {
+				lineB++
+			}
+		}
+		countA, countB := 0, 0
+		for _, o := range ops[from:end] {
+			if o.kind != '+' {
+				countA++
+			}
+			if o.kind != '-' {
+				countB++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", lineA, countA, lineB, countB)
+		for _, o := range ops[from:end] {
+			sb.WriteByte(o.kind)
+			sb.WriteString(o.line)
+			sb.WriteByte('\n')
+		}
+		start = end
+	}
+	return sb.String()
+}
+
+// splitDiffLines splits s into lines, marking a missing final newline the way diff does
+func splitDiffLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.Split(s, "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+	lines[len(lines)-1] += "\n\\ No newline at end of file"
+	return lines
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/inful/todoer/pkg/core"
+)
+
+// serveCheckInterval is how often serve checks whether a new day has begun
+const serveCheckInterval = time.Minute
+
+// durationBuckets are the upper bounds in seconds of the processing latency histogram
+var durationBuckets = []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// serveMetrics counts the journals serve creates, exposed in the Prometheus text
+// format on /metrics.
+type serveMetrics struct {
+	mu             sync.Mutex
+	processed      int       // Journals created
+	failures       int       // Runs that failed, parse errors included
+	parseErrors    int       // Runs that failed because a journal could not be parsed
+	carried        int       // Open todos carried into new journals
+	completed      int       // Completed todos left behind
+	bucketCounts   []int     // Runs per duration bucket, not cumulative
+	durationSum    float64   // Total run time in seconds
+	runs           int       // Runs observed, failed ones included
+	lastSuccess    time.Time // End of the last run that left today's journal in place
+	lastRunSuccess bool
+	journals       *journalMetrics // Metrics of the journal tree, nil for none
+}
+
+// newServeMetrics returns metrics without any runs, with those of the journal
+// tree if journals is not nil.
+func newServeMetrics(journals *journalMetrics) *serveMetrics {
+	return &serveMetrics{bucketCounts: make([]int, len(durationBuckets)), journals: journals}
+}
+
+// observe records a run of new that took duration and ended with summary and err
+// at now. A journal that already existed counts as a success without processing.
+func (m *serveMetrics) observe(summary *resultSummary, err error, duration time.Duration, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seconds := duration.Seconds()
+	m.runs++
+	m.durationSum += seconds
+	for i, bound := range durationBuckets {
+		if seconds <= bound {
+			m.bucketCounts[i]++
+			break
+		}
+	}
+
+	m.lastRunSuccess = err == nil
+	if err != nil {
+		m.failures++
+		if exitCodeFor(err) == ExitParseError {
+			m.parseErrors++
+		}
+		return
+	}
+	m.lastSuccess = now
+	if !summary.AlreadyExists {
+		m.processed++
+		m.carried += summary.CarriedTodos
+		m.completed += summary.CompletedTodos
+	}
+}
+
+// ServeHTTP serves the metrics on /metrics.
+func (m *serveMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/metrics" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(w)
+	if m.journals != nil {
+		m.journals.write(w)
+	}
+}
+
+// write writes the metrics in the Prometheus text exposition format.
+func (m *serveMetrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	metric := func(name, kind, help string, value float64) {
+		writeMetric(w, name, kind, help, value)
+	}
+	metric("todoer_journals_processed_total", "counter", "Journals created with the open todos of the previous one.", float64(m.processed))
+	metric("todoer_process_failures_total", "counter", "Runs that failed to create the journal of the day.", float64(m.failures))
+	metric("todoer_parse_errors_total", "counter", "Runs that failed because a journal could not be parsed.", float64(m.parseErrors))
+	metric("todoer_tasks_carried_total", "counter", "Open todos carried into new journals.", float64(m.carried))
+	metric("todoer_tasks_completed_total", "counter", "Completed todos left behind in processed journals.", float64(m.completed))
+
+	name := "todoer_processing_duration_seconds"
+	fmt.Fprintf(w, "# HELP %s Time taken to create the journal of the day.\n# TYPE %s histogram\n", name, name)
+	cumulative := 0
+	for i, bound := range durationBuckets {
+		cumulative += m.bucketCounts[i]
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, formatFloat(bound), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n%s_sum %s\n%s_count %d\n", name, m.runs, name, formatFloat(m.durationSum), name, m.runs)
+
+	lastSuccess := 0.0
+	if !m.lastSuccess.IsZero() {
+		lastSuccess = float64(m.lastSuccess.Unix())
+	}
+	metric("todoer_last_success_timestamp_seconds", "gauge", "Unix time of the last run that left the journal of the day in place.", lastSuccess)
+	lastRun := 0.0
+	if m.lastRunSuccess {
+		lastRun = 1
+	}
+	metric("todoer_last_run_success", "gauge", "Whether the last run succeeded (1) or failed (0).", lastRun)
+}
+
+// writeMetric writes a metric without labels in the Prometheus text exposition format.
+func writeMetric(w io.Writer, name, kind, help string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", name, help, name, kind, name, formatFloat(value))
+}
+
+// journalMetrics exposes the state of the journal tree. Every scrape refreshes
+// the cache of journals, which only parses the journals changed since the last.
+type journalMetrics struct {
+	cache   *journalCache
+	configs *liveConfig   // Configuration the journals are read with
+	today   func() string // Date streaks are computed as of
+	logger  *Logger
+}
+
+// write brings the journal cache up to date and writes the metrics of the tree.
+// A tree that cannot be scanned keeps the metrics of the last scan.
+func (m *journalMetrics) write(w io.Writer) {
+	m.cache.reconfigure(m.configs.Snapshot())
+	if parsed, err := m.cache.refresh(); err != nil {
+		m.logger.Error("Failed to refresh journals: %v", err)
+	} else {
+		m.logger.Debug("Refreshed journals, %d parsed", parsed)
+	}
+
+	stats := m.cache.stats()
+	open := 0
+	if stats.Latest != "" {
+		journal, err := m.cache.journal(stats.Latest)
+		if err != nil {
+			m.logger.Debug("Cannot count open todos of %s: %v", stats.Latest, err)
+		} else {
+			for _, day := range journal.Days {
+				open += core.CountUncompletedTopLevelItems(day.Items)
+			}
+		}
+	}
+	streaks := core.CalculateStreaks(core.CountCompletions(stats.Completions), m.today())
+
+	writeMetric(w, "todoer_journals", "gauge", "Journals in the tree.", float64(stats.Journals))
+	writeMetric(w, "todoer_unparseable_journals", "gauge", "Journals that could not be read or parsed.", float64(stats.Unparseable))
+	writeMetric(w, "todoer_open_todos", "gauge", "Open top-level todos in the latest journal.", float64(open))
+	writeMetric(w, "todoer_completed_todos", "gauge", "Completed todos in all journals.", float64(streaks.CompletedTodos))
+	writeMetric(w, "todoer_current_streak_days", "gauge", "Consecutive days with a completed todo up to today.", float64(streaks.CurrentStreak))
+	writeMetric(w, "todoer_longest_streak_days", "gauge", "Longest run of consecutive days with a completed todo.", float64(streaks.LongestStreak))
+	writeMetric(w, "todoer_journal_parses_total", "counter", "Journals parsed for the metrics; unchanged journals are not parsed again.", float64(stats.Parses))
+}
+
+// formatFloat formats v the way Prometheus expects.
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// cmdServe creates the journal of each new day below rootDir as the day begins,
+// like new does, and serves Prometheus metrics about the runs and the journals on
+// listenAddr until ctx is cancelled. A failed run is tried again at the next
+// check. Up to cacheSize parsed journals are kept between scrapes. The
+// configuration is reloaded when it changes, see liveConfig.
+func cmdServe(ctx context.Context, w io.Writer, rootDir, templateFile, listenAddr string, cacheSize int, config *Config, logger *Logger) error {
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return withExitCode(ExitConfigError, fmt.Errorf("cannot serve metrics: %w", err))
+	}
+	configs := newLiveConfig(config, commandConfigLoader("serve"), logger)
+	go configs.watch(ctx, configPollInterval)
+	now := func() time.Time { return configNow(configs.Snapshot()) }
+	return serveJournals(ctx, w, listener, rootDir, templateFile, serveCheckInterval, cacheSize, now, configs, logger)
+}
+
+// serveJournals implements cmdServe, checking for a new day every interval with
+// the clock now and keeping up to cacheSize parsed journals for the metrics. Every
+// check works with one snapshot of configs.
+func serveJournals(ctx context.Context, w io.Writer, listener net.Listener, rootDir, templateFile string, interval time.Duration, cacheSize int, now func() time.Time, configs *liveConfig, logger *Logger) error {
+	store, err := openJournalTree(rootDir, configs.Snapshot())
+	if err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+	metrics := newServeMetrics(&journalMetrics{
+		cache:   newJournalCache(store, cacheSize, configs.Snapshot()),
+		configs: configs,
+		today:   func() string { return effectiveDay(now(), configs.Snapshot()).Format(core.DateFormat) },
+		logger:  logger,
+	})
+	server := &http.Server{Handler: metrics, ReadHeaderTimeout: 10 * time.Second}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- server.Serve(listener) }()
+	defer func() {
+		_ = server.Close()
+		if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Metrics server failed: %v", err)
+		}
+	}()
+	fmt.Fprintf(w, "Serving metrics on http://%s/metrics (Ctrl-C to stop)\n", listener.Addr())
+
+	lastDate, lastErr := "", ""
+	fail := func(err error) {
+		// A failure is logged once, not on every retry
+		if err.Error() != lastErr {
+			logger.Error("Failed to create new journal: %v", err)
+		}
+		lastErr = err.Error()
+	}
+	run := func() {
+		config := configs.Snapshot()
+		date, err := newJournalDate(effectiveDay(now(), config).Format(core.DateFormat), config)
+		if err != nil {
+			fail(err)
+			return
+		}
+		if date == lastDate {
+			return
+		}
+
+		start := time.Now()
+		var summary *resultSummary
+		template, err := selectTemplate(config, date)
+		if err == nil {
+			summary, err = createJournal(ctx, rootDir, getConfigValue(templateFile, template), date, config, logger)
+		}
+		metrics.observe(summary, err, time.Since(start), now())
+		if err != nil {
+			fail(err)
+			return
+		}
+		lastDate, lastErr = date, ""
+		if summary.AlreadyExists {
+			logger.Info("Journal for %s already exists: %s", date, summary.Target)
+		} else {
+			logger.Info("Created %s", summary.Target)
+		}
+	}
+
+	run()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			run()
+		}
+	}
+}
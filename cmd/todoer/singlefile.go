@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/inful/todoer/pkg/core"
+	"github.com/inful/todoer/pkg/storage"
+)
+
+// dayMarkerRegex matches the line starting a day section of a single-file journal.
+// Captures: (date)
+var dayMarkerRegex = regexp.MustCompile(`(?m)^<!-- todoer:day (\d{4}-\d{2}-\d{2}) -->[ \t]*\r?\n?`)
+
+// frontmatterRegex matches the frontmatter at the start of a rendered template
+var frontmatterRegex = regexp.MustCompile(`\A---\r?\n(?s:.*?)\r?\n---[ \t]*(?:\r?\n|\z)`)
+
+// dayMarker returns the line starting the day section of date.
+func dayMarker(date string) string {
+	return "<!-- todoer:day " + date + " -->"
+}
+
+// daySection is the location of a day section in a single-file journal.
+type daySection struct {
+	date  string
+	start int // Offset of the marker line
+	body  int // Offset just past the marker line
+	end   int // Offset of the next marker line, or the length of the file
+}
+
+// daySections returns the day sections of content in file order. Content before
+// the first marker belongs to no day.
+func daySections(content []byte) []daySection {
+	matches := dayMarkerRegex.FindAllSubmatchIndex(content, -1)
+	sections := make([]daySection, len(matches))
+	for i, m := range matches {
+		sections[i] = daySection{date: string(content[m[2]:m[3]]), start: m[0], body: m[1], end: len(content)}
+		if i > 0 {
+			sections[i-1].end = m[0]
+		}
+	}
+	return sections
+}
+
+// singleFileStorage presents the day sections of the journal file as journals
+// named <file>/YYYY-MM-DD.md, so journals can be processed within a single file.
+// Reading a day returns its section with a frontmatter holding its date. Writing
+// a day replaces its section, or appends a new one, without the frontmatter of
+// the written content. The backup of a day is a copy of the whole file as it was
+// when the storage was opened. Other names are passed to the wrapped storage.
+type singleFileStorage struct {
+	storage.Storage
+	file     string // Name of the journal file in Storage
+	original []byte // Content of the journal file when opened, nil if it did not exist
+	dateKey  string // Frontmatter key of the date of read days
+}
+
+// openSingleFile opens file in store for processing day sections.
+func openSingleFile(store storage.Storage, file string, config *Config) (*singleFileStorage, error) {
+	if codec, err := codecForPath(file, config); err != nil || codec != nil {
+		return nil, withExitCode(ExitConfigError, fmt.Errorf("single-file journal %s must be plain markdown", store.Location(file)))
+	}
+	original, err := store.Read(file)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, withExitCode(ExitFailure, fmt.Errorf("error reading %s: %v", store.Location(file), err))
+	}
+	return &singleFileStorage{Storage: store, file: file, original: original, dateKey: config.FrontmatterDateKey}, nil
+}
+
+// dayName returns the name the day section of date has in s.
+func (s *singleFileStorage) dayName(date string) string {
+	return path.Join(s.file, date+".md")
+}
+
+// day returns the date of the day section name stands for.
+func (s *singleFileStorage) day(name string) (string, bool) {
+	if path.Dir(name) != s.file {
+		return "", false
+	}
+	return journalDateFromPath(name)
+}
+
+// section returns the current content of the file and the day section of date.
+func (s *singleFileStorage) section(date string) ([]byte, *daySection, error) {
+	content, err := s.Storage.Read(s.file)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, err
+	}
+	for _, section := range daySections(content) {
+		if section.date == date {
+			return content, &section, nil
+		}
+	}
+	return content, nil, nil
+}
+
+// latestBefore returns the name of the last day section before date.
+func (s *singleFileStorage) latestBefore(date string) (string, bool) {
+	latest := ""
+	for _, section := range daySections(s.original) {
+		if section.date < date && section.date > latest {
+			latest = section.date
+		}
+	}
+	if latest == "" {
+		return "", false
+	}
+	return s.dayName(latest), true
+}
+
+func (s *singleFileStorage) Read(name string) ([]byte, error) {
+	if strings.HasSuffix(name, ".bak") {
+		if _, ok := s.day(strings.TrimSuffix(name, ".bak")); ok {
+			return s.Storage.Read(s.file + ".bak")
+		}
+	}
+	date, ok := s.day(name)
+	if !ok {
+		return s.Storage.Read(name)
+	}
+	content, section, err := s.section(date)
+	if err != nil {
+		return nil, err
+	}
+	if section == nil {
+		return nil, fmt.Errorf("%s: %w", s.Location(name), fs.ErrNotExist)
+	}
+	frontmatter := "---\n" + s.dateKey + ": " + date + "\n---\n\n"
+	return append([]byte(frontmatter), content[section.body:section.end]...), nil
+}
+
+func (s *singleFileStorage) Write(name string, r io.Reader) error {
+	if strings.HasSuffix(name, ".bak") {
+		if _, ok := s.day(strings.TrimSuffix(name, ".bak")); ok {
+			if s.original == nil {
+				return nil
+			}
+			return s.Storage.Write(s.file+".bak", bytes.NewReader(s.original))
+		}
+	}
+	date, ok := s.day(name)
+	if !ok {
+		return s.Storage.Write(name, r)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	body := strings.TrimLeft(frontmatterRegex.ReplaceAllString(string(data), ""), "\n")
+	body = strings.TrimRight(body, "\n") + "\n"
+
+	content, section, err := s.section(date)
+	if err != nil {
+		return err
+	}
+	var updated string
+	if section != nil {
+		rest := string(content[section.end:])
+		if rest != "" {
+			body += "\n"
+		}
+		updated = string(content[:section.body]) + body + rest
+	} else {
+		updated = strings.TrimRight(string(content), "\n")
+		if updated != "" {
+			updated += "\n\n"
+		}
+		updated += dayMarker(date) + "\n" + body
+	}
+	return s.Storage.Write(s.file, strings.NewReader(updated))
+}
+
+func (s *singleFileStorage) Remove(name string) error {
+	date, ok := s.day(name)
+	if !ok {
+		return s.Storage.Remove(name)
+	}
+	content, section, err := s.section(date)
+	if err != nil {
+		return err
+	}
+	if section == nil {
+		return fmt.Errorf("%s: %w", s.Location(name), fs.ErrNotExist)
+	}
+	updated := strings.TrimRight(string(content[:section.start]), "\n")
+	if rest := string(content[section.end:]); updated != "" && rest != "" {
+		updated += "\n\n" + rest
+	} else if updated != "" {
+		updated += "\n"
+	} else {
+		updated = rest
+	}
+	return s.Storage.Write(s.file, strings.NewReader(updated))
+}
+
+func (s *singleFileStorage) Stat(name string) (storage.FileInfo, error) {
+	date, ok := s.day(name)
+	if !ok {
+		return s.Storage.Stat(name)
+	}
+	_, section, err := s.section(date)
+	if err != nil {
+		return storage.FileInfo{}, err
+	}
+	if section == nil {
+		return storage.FileInfo{}, fmt.Errorf("%s: %w", s.Location(name), fs.ErrNotExist)
+	}
+	return storage.FileInfo{Name: name}, nil
+}
+
+func (s *singleFileStorage) Location(name string) string {
+	if strings.HasSuffix(name, ".bak") {
+		if _, ok := s.day(strings.TrimSuffix(name, ".bak")); ok {
+			return s.Storage.Location(s.file + ".bak")
+		}
+	}
+	if date, ok := s.day(name); ok {
+		return s.Storage.Location(s.file) + "#" + date
+	}
+	return s.Storage.Location(name)
+}
+
+// appendDay processes the last day section before date in the single-file journal
+// s into a new day section for date, appended to the file. An existing section for
+// date is left alone.
+func appendDay(ctx context.Context, s *singleFileStorage, root storage.Storage, templateFile, date string, days core.DayRange, summary *resultSummary, config *Config, logger *Logger) error {
+	target := s.dayName(date)
+	summary.Target = s.Location(target)
+	if _, err := s.Stat(target); err == nil {
+		summary.AlreadyExists = true
+		return nil
+	}
+
+	source, ok := s.latestBefore(date)
+	if ok {
+		summary.Source = s.Location(source)
+	}
+	err := processJournalIn(ctx, s, root, source, target, templateFile, date, !ok, days, summary, config, logger)
+	if !ok {
+		summary.fromTemplate = true
+		summary.addWarning("no previous day found in %s, created from template", s.Storage.Location(s.file))
+	}
+	if err == nil {
+		postAfterProcess(s, target, summary, config, logger)
+	}
+	return err
+}
+
+// processSingleFile processes the last day section of the journal file before
+// templateDate into a new day section for templateDate at the end of the file,
+// like process does across two files.
+func processSingleFile(ctx context.Context, file, templateFile, templateDate string, days core.DayRange, config *Config, logger *Logger) (*resultSummary, error) {
+	summary := newResultSummary("process")
+	if file == stdioName {
+		return summary, withExitCode(ExitConfigError, fmt.Errorf("a single-file journal cannot be read from stdin"))
+	}
+	if err := validateFilePath(file); err != nil {
+		return summary, fmt.Errorf("invalid source file: %w", err)
+	}
+	if err := validateDateFormat(templateDate); err != nil {
+		return summary, fmt.Errorf("invalid template date: %w", err)
+	}
+
+	store := localStore(filepath.Dir(file))
+	s, err := openSingleFile(store, filepath.Base(file), config)
+	if err != nil {
+		return summary, err
+	}
+	err = appendDay(ctx, s, nil, templateFile, templateDate, days, summary, config, logger)
+	return summary, err
+}
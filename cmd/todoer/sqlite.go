@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/inful/todoer/pkg/core"
+	"github.com/inful/todoer/pkg/storage"
+)
+
+// DefaultSQLite is the sqlite3 command-line shell used to create and query databases
+const DefaultSQLite = "sqlite3"
+
+// sqliteSchema creates the tables of an exported database, replacing those of an
+// earlier export.
+const sqliteSchema = `DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS assignees;
+DROP TABLE IF EXISTS completions;
+DROP TABLE IF EXISTS tasks;
+DROP TABLE IF EXISTS days;
+CREATE TABLE days (
+  date TEXT NOT NULL,   -- Date of the journal, YYYY-MM-DD, shared by journals in different folders
+  path TEXT PRIMARY KEY -- Location of the journal file
+);
+CREATE TABLE tasks (
+  id INTEGER PRIMARY KEY,
+  day TEXT NOT NULL,                       -- Date of the journal the task appears in
+  section TEXT,                            -- Date header the task is listed under, NULL if undated
+  parent_id INTEGER REFERENCES tasks(id),  -- Parent task of a subtask, NULL at the top level
+  position INTEGER NOT NULL,               -- Order of the task in its journal, from 1
+  text TEXT NOT NULL,
+  completed INTEGER NOT NULL,              -- 1 if checked, 0 if open
+  carry_count INTEGER NOT NULL,            -- Times the task was carried, from the carry marker
+  task_key TEXT NOT NULL,                  -- Same for a task in every journal it was carried to
+  created TEXT NOT NULL                    -- Date the task was first written down
+);
+CREATE TABLE tags (
+  task_id INTEGER NOT NULL REFERENCES tasks(id),
+  tag TEXT NOT NULL                        -- Lower case with its '#', e.g. #errand
+);
+CREATE TABLE assignees (
+  task_id INTEGER NOT NULL REFERENCES tasks(id),
+  assignee TEXT NOT NULL                   -- Lower case without its '@', e.g. alice
+);
+CREATE TABLE completions (
+  date TEXT NOT NULL,                      -- Completion date, from the date tag or the journal
+  text TEXT NOT NULL,                      -- Task text without its date tag
+  created TEXT,                            -- Date the task was first written down
+  PRIMARY KEY (date, text)
+);
+`
+
+// sqlQuote returns s as an SQL string literal.
+func sqlQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// writeSQLDump writes SQL statements creating the tables of sqliteSchema and
+// filling them with the journals of store, in one transaction.
+func writeSQLDump(w io.Writer, store storage.Storage, journals []*syncJournal) error {
+	var buf bytes.Buffer
+	buf.WriteString("BEGIN;\n" + sqliteSchema)
+
+	id := 0
+	for _, j := range journals {
+		fmt.Fprintf(&buf, "INSERT INTO days VALUES (%s, %s);\n", sqlQuote(j.date), sqlQuote(store.Location(j.name)))
+
+		ids := make(map[*core.TodoItem]int)
+		created := core.CreatedDates(j.journal, j.date)
+		for position, loc := range core.FindItems(j.journal, func(item *core.TodoItem) bool { return item.Text != "" }) {
+			id++
+			ids[loc.Item] = id
+			section, parent := "NULL", "NULL"
+			if loc.Day.Date != "" {
+				section = sqlQuote(loc.Day.Date)
+			}
+			if parentID, ok := ids[loc.Parent]; ok {
+				parent = strconv.Itoa(parentID)
+			}
+			completed := 0
+			if loc.Item.Completed {
+				completed = 1
+			}
+			fmt.Fprintf(&buf, "INSERT INTO tasks VALUES (%d, %s, %s, %s, %d, %s, %d, %d, %s, %s);\n",
+				id, sqlQuote(j.date), section, parent, position+1, sqlQuote(loc.Item.Text), completed,
+				loc.Item.CarryCount, sqlQuote(syncTaskID(loc.Item.Text)), sqlQuote(created[loc.Item]))
+			for _, tag := range core.ExtractTags(loc.Item.Text) {
+				fmt.Fprintf(&buf, "INSERT INTO tags VALUES (%d, %s);\n", id, sqlQuote(tag))
+			}
+			for _, name := range core.ExtractAssignees(loc.Item.Text) {
+				fmt.Fprintf(&buf, "INSERT INTO assignees VALUES (%d, %s);\n", id, sqlQuote(name))
+			}
+		}
+
+		for _, c := range core.CollectCompletions(j.journal, j.date) {
+			fmt.Fprintf(&buf, "INSERT OR IGNORE INTO completions VALUES (%s, %s, %s);\n", sqlQuote(c.Date), sqlQuote(core.StripDateTags(c.Text)), sqlQuote(c.Created))
+		}
+	}
+	buf.WriteString("COMMIT;\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// runSQLite runs the sqlite3 shell with args, feeding it script on stdin, and
+// copies its output to w.
+func runSQLite(w io.Writer, script []byte, args ...string) error {
+	if _, err := exec.LookPath(DefaultSQLite); err != nil {
+		return withExitCode(ExitConfigError, fmt.Errorf("%s is not installed: %w", DefaultSQLite, err))
+	}
+	cmd := exec.Command(DefaultSQLite, append([]string{"-bail", "-batch"}, args...)...)
+	cmd.Stdin = bytes.NewReader(script)
+	cmd.Stdout = w
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s failed: %w: %s", DefaultSQLite, err, msg)
+		}
+		return fmt.Errorf("%s failed: %w", DefaultSQLite, err)
+	}
+	return nil
+}
+
+// exportSQLite writes the journals below rootDir as SQL statements on w, or into
+// the SQLite database file, created with the sqlite3 shell, if file is set.
+func exportSQLite(w io.Writer, rootDir, file string, config *Config, logger *Logger) error {
+	store, err := openJournalTree(rootDir, config)
+	if err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+	journals, err := loadSyncJournals(store, newProgress(logger), config)
+	if err != nil {
+		return err
+	}
+
+	if file == "" {
+		return writeSQLDump(w, store, journals)
+	}
+	var dump bytes.Buffer
+	if err := writeSQLDump(&dump, store, journals); err != nil {
+		return err
+	}
+	if err := runSQLite(io.Discard, dump.Bytes(), file); err != nil {
+		return withExitCode(ExitWriteError, err)
+	}
+	logger.Info("Exported %d %s to %s", len(journals), plural(len(journals), "journal", "journals"), file)
+	return nil
+}
+
+// cmdQuery runs the SQL statement query with the sqlite3 shell and prints the
+// result on w as aligned columns, or as JSON with format OutputJSON. The query runs
+// against the database file db if set, or else against an in-memory database
+// holding the journals below rootDir, with the tables of an export. With an
+// assignee, the in-memory database holds only the top-level todos assigned to them.
+func cmdQuery(w io.Writer, rootDir, db, query, format, assignee string, config *Config) error {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return errors.New("--sql cannot be empty")
+	}
+	if assignee != "" && db != "" {
+		return withExitCode(ExitConfigError, errors.New("--assignee cannot be used with --db; filter on the assignees table instead"))
+	}
+	if assignee != "" && !core.ValidAssignee(assignee) {
+		return withExitCode(ExitConfigError, fmt.Errorf("invalid assignee %q", assignee))
+	}
+	// The sqlite3 shell reads the query as a script, in which a line starting
+	// with a dot is a command that may run programs or write files
+	for _, line := range strings.Split(query, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), ".") {
+			return withExitCode(ExitConfigError, fmt.Errorf("--sql cannot contain sqlite3 dot-commands: %q", strings.TrimSpace(line)))
+		}
+	}
+	if !strings.HasSuffix(query, ";") {
+		query += ";"
+	}
+
+	var script bytes.Buffer
+	var args []string
+	if db != "" {
+		if _, err := os.Stat(db); err != nil {
+			return withExitCode(ExitConfigError, fmt.Errorf("cannot open database: %w", err))
+		}
+		args = append(args, "-readonly", db)
+	} else {
+		store, err := openJournalTree(rootDir, config)
+		if err != nil {
+			return withExitCode(ExitConfigError, err)
+		}
+		journals, err := loadSyncJournals(store, core.NoProgress, config)
+		if err != nil {
+			return err
+		}
+		if assignee != "" {
+			for _, j := range journals {
+				j.journal = core.FilterAssigned(j.journal, assignee)
+			}
+		}
+		if err := writeSQLDump(&script, store, journals); err != nil {
+			return err
+		}
+	}
+
+	mode := "column"
+	if format == OutputJSON {
+		mode = "json"
+	}
+	fmt.Fprintf(&script, ".headers on\n.mode %s\n%s\n", mode, query)
+	return runSQLite(w, script.Bytes(), args...)
+}
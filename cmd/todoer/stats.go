@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/inful/todoer/pkg/core"
+	"github.com/inful/todoer/pkg/storage"
+)
+
+// streakVariablesRegex matches template references to the streak variables, which
+// are only computed for templates that use them since they scan the journal tree
+var streakVariablesRegex = regexp.MustCompile(`\.(CurrentStreak|LongestStreak|WeeklyVelocity|CompletionHours)\b`)
+
+// hourBarWidth is the width of the longest bar of the completions per hour
+const hourBarWidth = 30
+
+// statsEntry is the JSON form of the stats command output.
+type statsEntry struct {
+	CompletedTodos int            `json:"completed_todos"`
+	ActiveDays     int            `json:"active_days"`
+	CurrentStreak  int            `json:"current_streak"`
+	LongestStreak  int            `json:"longest_streak"`
+	WeeklyVelocity float64        `json:"weekly_velocity"`
+	MostCarried    []carriedEntry `json:"most_carried,omitempty"`
+
+	CompletionHours      []int    `json:"completion_hours,omitempty"`        // Per hour of the day, omitted without time stamps
+	MedianDaysToComplete *float64 `json:"median_days_to_complete,omitempty"` // Omitted without completed todos
+}
+
+// carriedEntry is the JSON form of a task listed by stats --top-carried.
+type carriedEntry struct {
+	Text       string `json:"text"`
+	Date       string `json:"date"`
+	CarryCount int    `json:"carry_count"`
+}
+
+// usesStreaks reports whether templateContent refers to any streak variable.
+func usesStreaks(templateContent string) bool {
+	return streakVariablesRegex.MatchString(templateContent)
+}
+
+// templateStreaks computes the completion streaks as of today for a template, from the
+// journals in root, or in the configured journal root if root is nil.
+func templateStreaks(root storage.Storage, today string, config *Config, logger *Logger) (core.StreakStats, error) {
+	if root == nil {
+		var err error
+		if root, err = openJournalTree(config.RootDir, config); err != nil {
+			return core.StreakStats{}, err
+		}
+	}
+	indexPath, err := indexCachePath()
+	if err != nil {
+		logger.Debug("Scanning journals without index cache: %v", err)
+	}
+	return completionStreaks(root, indexPath, today, config, logger)
+}
+
+// cmdStats prints completion statistics and streaks of the journal tree as of today.
+// With topCarried above zero, the open tasks carried most often are listed too.
+// The markdown format prints a table of the open and closed todos per tag instead.
+// With an assignee, only the top-level todos assigned to them count.
+func cmdStats(w io.Writer, rootDir, indexPath, today, format string, topCarried int, assignee string, config *Config, logger *Logger) error {
+	if assignee != "" && !core.ValidAssignee(assignee) {
+		return withExitCode(ExitConfigError, fmt.Errorf("invalid assignee %q", assignee))
+	}
+	store, err := openJournalTree(rootDir, config)
+	if err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+
+	if format == OutputMarkdown {
+		journals, err := assignedJournals(store, assignee, config, logger)
+		if err != nil {
+			return err
+		}
+		table := core.TagTable(core.CountTags(journals...))
+		if table == "" {
+			logger.Info("No tagged todos found in %s", rootDir)
+			return nil
+		}
+		_, err = io.WriteString(w, table)
+		return err
+	}
+
+	var stats core.StreakStats
+	if assignee == "" {
+		stats, err = completionStreaks(store, indexPath, today, config, logger)
+	} else {
+		stats, err = assigneeStreaks(store, assignee, today, config, logger)
+	}
+	if err != nil {
+		return err
+	}
+
+	var carried []core.CarriedTodo
+	if topCarried > 0 {
+		journals, err := assignedJournals(store, assignee, config, logger)
+		if err != nil {
+			return err
+		}
+		carried = core.MostCarried(journals...)
+		if len(carried) > topCarried {
+			carried = carried[:topCarried]
+		}
+	}
+
+	if format == OutputJSON {
+		entry := statsEntry{
+			CompletedTodos: stats.CompletedTodos,
+			ActiveDays:     stats.ActiveDays,
+			CurrentStreak:  stats.CurrentStreak,
+			LongestStreak:  stats.LongestStreak,
+			WeeklyVelocity: stats.WeeklyVelocity,
+		}
+		if hasCompletionHours(stats.CompletionHours) {
+			entry.CompletionHours = stats.CompletionHours[:]
+		}
+		if stats.TrackedTodos > 0 {
+			entry.MedianDaysToComplete = &stats.MedianDaysToComplete
+		}
+		for _, todo := range carried {
+			entry.MostCarried = append(entry.MostCarried, carriedEntry{Text: todo.Text, Date: todo.Date, CarryCount: todo.CarryCount})
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entry)
+	}
+
+	fmt.Fprintf(w, "Completed: %s %s on %d %s\n", stdoutColors.green(strconv.Itoa(stats.CompletedTodos)), plural(stats.CompletedTodos, "todo", "todos"),
+		stats.ActiveDays, plural(stats.ActiveDays, "day", "days"))
+	fmt.Fprintf(w, "Current streak: %d %s\n", stats.CurrentStreak, plural(stats.CurrentStreak, "day", "days"))
+	fmt.Fprintf(w, "Longest streak: %d %s\n", stats.LongestStreak, plural(stats.LongestStreak, "day", "days"))
+	fmt.Fprintf(w, "Weekly velocity: %.1f todos/week (last %d weeks)\n", stats.WeeklyVelocity, core.VelocityWeeks)
+	if stats.TrackedTodos > 0 {
+		fmt.Fprintf(w, "Days to complete: %.1f median (%d %s)\n", stats.MedianDaysToComplete, stats.TrackedTodos, plural(stats.TrackedTodos, "todo", "todos"))
+	}
+	if hasCompletionHours(stats.CompletionHours) {
+		fmt.Fprintln(w, "Completions by hour:")
+		writeHourHistogram(w, stats.CompletionHours)
+	}
+	if topCarried > 0 {
+		if len(carried) == 0 {
+			fmt.Fprintln(w, "Most carried: none")
+			return nil
+		}
+		fmt.Fprintln(w, "Most carried:")
+		for _, todo := range carried {
+			fmt.Fprintf(w, "  %d× since %s: %s\n", todo.CarryCount, todo.Date, todo.Text)
+		}
+	}
+	return nil
+}
+
+// assignedJournals returns the journals in store like collectJournals, with only
+// the top-level todos assigned to assignee if it is set.
+func assignedJournals(store storage.Storage, assignee string, config *Config, logger *Logger) ([]*core.TodoJournal, error) {
+	journals, err := collectJournals(store, config, logger)
+	if err != nil || assignee == "" {
+		return journals, err
+	}
+	for i, journal := range journals {
+		journals[i] = core.FilterAssigned(journal, assignee)
+	}
+	return journals, nil
+}
+
+// assigneeStreaks computes the completion streaks of the top-level todos assigned
+// to assignee in the journals of store as of today, like completionStreaks. The
+// index cache does not know whom todos are assigned to, so every journal is read.
+func assigneeStreaks(store storage.Storage, assignee, today string, config *Config, logger *Logger) (core.StreakStats, error) {
+	parser := journalParser(config)
+	progress := newProgress(logger)
+	var completions []core.Completion
+	err := walkJournals(store, progress, func(info storage.FileInfo, date string) error {
+		progress.Stage(info.Name, core.StageRead)
+		content, err := readJournalFile(store, info.Name, config)
+		if err != nil {
+			logger.Info("Skipping %s: %v", store.Location(info.Name), err)
+			return nil
+		}
+		progress.Stage(info.Name, core.StageParse)
+		journal, err := parser.ParseJournal(string(content))
+		if err != nil {
+			logger.Debug("Skipping %s: %v", store.Location(info.Name), err)
+			return nil
+		}
+		completions = append(completions, core.CollectCompletions(core.FilterAssigned(journal, assignee), date)...)
+		return nil
+	})
+	if err != nil {
+		return core.StreakStats{}, fmt.Errorf("failed to scan journals in %s: %w", store.Location(""), err)
+	}
+	stats := core.CalculateStreaks(core.CountCompletions(completions), today)
+	stats.CompletionHours = core.CompletionHours(completions)
+	stats.MedianDaysToComplete, stats.TrackedTodos = core.DaysToComplete(completions)
+	return stats, nil
+}
+
+// hasCompletionHours reports whether any completion was stamped with a time of day.
+func hasCompletionHours(hours [24]int) bool {
+	return hours != [24]int{}
+}
+
+// writeHourHistogram prints a bar per hour of the day from the first to the last
+// hour with completions, the longest bar hourBarWidth wide.
+func writeHourHistogram(w io.Writer, hours [24]int) {
+	first, last, most := -1, 0, 0
+	for hour, n := range hours {
+		if n == 0 {
+			continue
+		}
+		if first < 0 {
+			first = hour
+		}
+		last, most = hour, max(most, n)
+	}
+	for hour := first; hour >= 0 && hour <= last; hour++ {
+		n := hours[hour]
+		if n == 0 {
+			fmt.Fprintf(w, "  %02d:00 0\n", hour)
+			continue
+		}
+		bar := strings.Repeat("█", (n*hourBarWidth+most-1)/most)
+		fmt.Fprintf(w, "  %02d:00 %s %d\n", hour, stdoutColors.green(bar), n)
+	}
+}
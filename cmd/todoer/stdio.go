@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/inful/todoer/pkg/core"
+	"github.com/inful/todoer/pkg/storage"
+)
+
+// stdioName is the source or target file of process that stands for standard
+// input or standard output.
+const stdioName = "-"
+
+// Names the journals read from stdin and written to stdout have in stdioStorage
+const (
+	stdinJournal  = "<stdin>"
+	stdoutJournal = "<stdout>"
+	stdinBackup   = stdinJournal + ".bak"
+)
+
+// stdioStorage is local storage in which the journal stdinJournal is read from in
+// and stdoutJournal is written to out, so process can run in a pipe. The processed
+// journal written back to stdinJournal goes to modifiedOut. There is no file to back
+// up, so the backup of stdinJournal is dropped.
+type stdioStorage struct {
+	storage.Storage
+	in          io.Reader
+	out         io.Writer
+	modifiedOut string // Destination of the processed journal read from in, see openModifiedOut; "" drops it
+	written     []byte // Content written to out, read back for post.after_process
+}
+
+func (s *stdioStorage) Read(name string) ([]byte, error) {
+	switch name {
+	case stdinJournal:
+		return io.ReadAll(s.in)
+	case stdoutJournal:
+		return s.written, nil
+	}
+	return s.Storage.Read(name)
+}
+
+func (s *stdioStorage) Write(name string, r io.Reader) error {
+	switch name {
+	case stdinJournal:
+		if s.modifiedOut == "" {
+			return nil
+		}
+		w, closeOut, err := openModifiedOut(s.modifiedOut, s.out)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, r); err != nil {
+			closeOut()
+			return err
+		}
+		return closeOut()
+	case stdinBackup:
+		return nil
+	case stdoutJournal:
+		var buf bytes.Buffer
+		if _, err := io.Copy(s.out, io.TeeReader(r, &buf)); err != nil {
+			return err
+		}
+		s.written = buf.Bytes()
+		return nil
+	}
+	return s.Storage.Write(name, r)
+}
+
+func (s *stdioStorage) Location(name string) string {
+	switch name {
+	case stdinJournal:
+		return "stdin"
+	case stdoutJournal:
+		return "stdout"
+	case stdinBackup:
+		return ""
+	}
+	return s.Storage.Location(name)
+}
+
+// openModifiedOut opens the destination of --modified-out: "-" for out, fd:N
+// for the inherited file descriptor N, or a file, created or truncated. The returned
+// function closes what was opened.
+func openModifiedOut(dest string, out io.Writer) (io.Writer, func() error, error) {
+	noop := func() error { return nil }
+	if dest == stdioName {
+		return out, noop, nil
+	}
+	if fd, ok := strings.CutPrefix(dest, "fd:"); ok {
+		n, err := strconv.Atoi(fd)
+		if err != nil || n < 0 {
+			return nil, nil, fmt.Errorf("%w: invalid file descriptor %q", ErrInvalidPath, dest)
+		}
+		f := os.NewFile(uintptr(n), dest)
+		if f == nil {
+			return nil, nil, fmt.Errorf("%w: invalid file descriptor %q", ErrInvalidPath, dest)
+		}
+		return f, f.Close, nil
+	}
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fileMode)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f.Close, nil
+}
+
+// processJournalStdio processes a journal like processJournal, where a sourceFile
+// of "-" reads the journal from in and a targetFile of "-" writes the new journal
+// to out. The processed journal read from in is written to modifiedOut, see
+// openModifiedOut, or dropped if modifiedOut is empty. A source file is processed
+// in place and backed up as usual. Only the day sections in days are processed.
+func processJournalStdio(ctx context.Context, in io.Reader, out io.Writer, sourceFile, targetFile, modifiedOut, templateFile, templateDate string, days core.DayRange, config *Config, logger *Logger) (*resultSummary, error) {
+	summary := newResultSummary("process")
+	summary.Source = sourceFile
+	summary.Target = targetFile
+
+	if modifiedOut != "" && sourceFile != stdioName {
+		return summary, withExitCode(ExitConfigError, errors.New("--modified-out requires reading the journal from stdin (source file -)"))
+	}
+	if modifiedOut == stdioName && targetFile == stdioName {
+		return summary, withExitCode(ExitConfigError, errors.New("--modified-out - cannot be used when the new journal is written to stdout"))
+	}
+	source, target := sourceFile, targetFile
+	if source == stdioName {
+		source = stdinJournal
+	} else if err := validateFilePath(source); err != nil {
+		return summary, fmt.Errorf("invalid source file: %w", err)
+	}
+	if target == stdioName {
+		target = stdoutJournal
+	} else if err := validateFilePath(target); err != nil {
+		return summary, fmt.Errorf("invalid target file: %w", err)
+	}
+	if modifiedOut != "" && modifiedOut != stdioName && !strings.HasPrefix(modifiedOut, "fd:") {
+		if err := validateFilePath(modifiedOut); err != nil {
+			return summary, fmt.Errorf("invalid --modified-out: %w", err)
+		}
+	}
+	if err := validateDateFormat(templateDate); err != nil {
+		return summary, fmt.Errorf("invalid template date: %w", err)
+	}
+
+	store := &stdioStorage{Storage: localStore(""), in: in, out: out, modifiedOut: modifiedOut}
+
+	err := processJournalIn(ctx, store, nil, source, target, templateFile, templateDate, false, days, summary, config, logger)
+	if err == nil {
+		postAfterProcess(store, target, summary, config, logger)
+	}
+	return summary, err
+}
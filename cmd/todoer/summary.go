@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Supported values for the --output flag.
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+	// OutputMarkdown is only supported by stats
+	OutputMarkdown = "markdown"
+)
+
+// resultSummary describes the outcome of a process or new command.
+// It is printed as JSON with --output json and drives the text output otherwise.
+type resultSummary struct {
+	Command        string   `json:"command"`
+	Source         string   `json:"source,omitempty"`
+	Target         string   `json:"target"`
+	Backup         string   `json:"backup,omitempty"`
+	Template       string   `json:"template,omitempty"`
+	AlreadyExists  bool     `json:"already_exists,omitempty"`
+	CarriedTodos   int      `json:"carried_todos"`
+	CompletedTodos int      `json:"completed_todos"`
+	Backlog        string   `json:"backlog,omitempty"`
+	BacklogTodos   int      `json:"backlog_todos,omitempty"`
+	Outputs        []string `json:"outputs,omitempty"`
+	Warnings       []string `json:"warnings"`
+	ExitCode       int      `json:"exit_code"`
+	Error          string   `json:"error,omitempty"`
+
+	fromTemplate bool // No previous journal was found; the target was created from the template alone
+}
+
+// newResultSummary creates an empty summary for the given command.
+func newResultSummary(command string) *resultSummary {
+	return &resultSummary{Command: command, Warnings: []string{}}
+}
+
+// addWarning records a non-fatal problem encountered during the run.
+func (s *resultSummary) addWarning(format string, args ...interface{}) {
+	s.Warnings = append(s.Warnings, fmt.Sprintf(format, args...))
+}
+
+// writeSummary reports the summary on w in the requested output format.
+// In text mode printPath reduces the output to the target path only.
+func writeSummary(w io.Writer, s *resultSummary, format string, printPath bool) error {
+	if format == OutputJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(s)
+	}
+
+	if s.Error != "" {
+		return nil
+	}
+
+	if printPath {
+		_, err := fmt.Fprintln(w, s.Target)
+		return err
+	}
+
+	if s.AlreadyExists && s.Command == "process" {
+		_, err := fmt.Fprintf(w, "Already processed into %s, nothing to do.\n", s.Target)
+		return err
+	}
+	if s.AlreadyExists {
+		_, err := fmt.Fprintf(w, "Journal for today already exists: %s\n", s.Target)
+		return err
+	}
+
+	if s.fromTemplate {
+		fmt.Fprintln(w, "No previous journal found, creating a new one from template.")
+	} else if s.Command == "new" {
+		fmt.Fprintf(w, "Using '%s' as source to create new journal for today.\n", s.Source)
+	}
+
+	if s.BacklogTodos > 0 {
+		fmt.Fprintf(w, "Moved %d %s over max_carry to the backlog: %s\n", s.BacklogTodos, plural(s.BacklogTodos, "todo", "todos"), s.Backlog)
+	}
+
+	for _, output := range s.Outputs {
+		fmt.Fprintf(w, "Wrote output: %s\n", output)
+	}
+
+	if s.Backup != "" {
+		fmt.Fprintf(w, "Backup of original file created: %s\n", s.Backup)
+	} else if !s.fromTemplate && s.Source != stdioName {
+		fmt.Fprintf(w, "No modifications found in the original file, backup not created.\n")
+	}
+
+	return nil
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/inful/todoer/pkg/caldav"
+	"github.com/inful/todoer/pkg/core"
+	"github.com/inful/todoer/pkg/storage"
+)
+
+// caldavTimeout bounds each request to the CalDAV server
+const caldavTimeout = 30 * time.Second
+
+// caldavOptions holds the CalDAV server settings of a sync run.
+type caldavOptions struct {
+	URL      string // Calendar home URL, optionally with credentials
+	Calendar string // Display name or path segment of the task list
+}
+
+// caldavMapping is the sync state kept between runs: per calendar URL, the entry of
+// every pushed task keyed by task ID.
+type caldavMapping map[string]map[string]*caldavEntry
+
+// caldavEntry links a journal task to the VTODO it was pushed as.
+type caldavEntry struct {
+	UID       string `json:"uid"`
+	Completed bool   `json:"completed"`         // Completion state agreed on at the last sync
+	Deleted   bool   `json:"deleted,omitempty"` // The VTODO was deleted on the server
+}
+
+// syncJournal is a journal loaded for syncing.
+type syncJournal struct {
+	name    string
+	date    string
+	content string
+	journal *core.TodoJournal
+	changed bool
+}
+
+// syncTask is the most recent occurrence of a top-level task across all journals.
+type syncTask struct {
+	journal *syncJournal
+	item    *core.TodoItem
+	due     string
+}
+
+// syncTaskID identifies a task across journals and runs. It is derived from the
+// task text without the completion date tags process and sync append, with or
+// without a time of day; parsed journals hold them in the default layout.
+func syncTaskID(text string) string {
+	text = core.StripDateTags(text)
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:8])
+}
+
+// caldavMappingPath returns the location of the CalDAV mapping file.
+func caldavMappingPath() (string, error) {
+	dataHome, err := getDataDir()
+	if err != nil {
+		return "", fmt.Errorf("could not determine data directory: %w", err)
+	}
+	return filepath.Join(dataHome, ConfigDirName, CalDAVMappingFile), nil
+}
+
+// loadCalDAVMapping reads the mapping file, returning an empty mapping if there is none.
+func loadCalDAVMapping(path string) (caldavMapping, error) {
+	mapping := caldavMapping{}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return mapping, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync mapping: %w", err)
+	}
+	if err := json.Unmarshal(content, &mapping); err != nil {
+		return nil, fmt.Errorf("failed to decode sync mapping %s: %w", path, err)
+	}
+	return mapping, nil
+}
+
+// saveCalDAVMapping writes the mapping file.
+func saveCalDAVMapping(path string, mapping caldavMapping) error {
+	content, err := json.MarshalIndent(mapping, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode sync mapping: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), dirMode()); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	if err := safeWriteFile(path, content, fileMode); err != nil {
+		return withExitCode(ExitWriteError, fmt.Errorf("failed to write sync mapping: %w", err))
+	}
+	return nil
+}
+
+// loadSyncJournals reads and parses every journal in store in chronological order,
+// reporting its progress to progress.
+func loadSyncJournals(store storage.Storage, progress core.Progress, config *Config) ([]*syncJournal, error) {
+	parser := journalParser(config, core.WithArena(core.NewArena()))
+
+	var journals []*syncJournal
+	err := walkJournals(store, progress, func(info storage.FileInfo, date string) error {
+		progress.Stage(info.Name, core.StageRead)
+		content, err := readJournalFile(store, info.Name, config)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", store.Location(info.Name), err)
+		}
+		progress.Stage(info.Name, core.StageParse)
+		journal, err := parser.ParseJournal(string(content))
+		if err != nil {
+			return withExitCode(ExitParseError, core.WithFile(err, store.Location(info.Name)))
+		}
+		journals = append(journals, &syncJournal{name: info.Name, date: date, content: string(content), journal: journal})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(journals, func(i, j int) bool {
+		return journals[i].date < journals[j].date
+	})
+	return journals, nil
+}
+
+// topLevelTasks returns the top-level tasks of a journal with their due dates.
+// Undated tasks are due on the journal's own date.
+func topLevelTasks(j *syncJournal) []syncTask {
+	var tasks []syncTask
+	for _, loc := range core.FindItems(j.journal, func(item *core.TodoItem) bool { return item.Text != "" }) {
+		if loc.Parent != nil {
+			continue
+		}
+		due := loc.Day.Date
+		if due == "" {
+			due = j.date
+		}
+		tasks = append(tasks, syncTask{journal: j, item: loc.Item, due: due})
+	}
+	return tasks
+}
+
+// cmdSyncCalDAV pushes the open tasks of the current journal below rootDir to a
+// CalDAV task list as VTODOs, pulls completions back into the journals and reports
+// the changes on w.
+//
+// Tasks are identified by syncTaskID, so a task carried over from day to day stays
+// the same VTODO. The mapping file remembers, per calendar, the VTODO UID of every
+// pushed task and the completion state both sides agreed on at the last sync. Only
+// top-level tasks are synced; subtasks and notes stay in the journal.
+//
+// Conflict rules, comparing the journal state J and server state R of a task with the
+// last synced state S:
+//
+//   - J == R: nothing to do.
+//   - J != S: the task was completed or reopened in the journal; the journal state is pushed.
+//   - R != S: the task was completed or reopened on the server; the server state is
+//     written to the most recent journal containing the task.
+//   - The journal owns the task text: edits to the VTODO summary are not pulled and are
+//     overwritten when the journal state is pushed.
+//   - A VTODO deleted on the server is not pushed again; the task stays in the journal.
+//   - A task that no longer appears in any journal is left alone on the server.
+func cmdSyncCalDAV(w io.Writer, mappingPath, rootDir string, opts caldavOptions, now time.Time, config *Config, logger *Logger) error {
+	if opts.URL == "" {
+		return withExitCode(ExitConfigError, errors.New("CalDAV URL is not configured (use --url or caldav.url)"))
+	}
+	if opts.Calendar == "" {
+		return withExitCode(ExitConfigError, errors.New("CalDAV calendar is not configured (use --calendar or caldav.calendar)"))
+	}
+	home, err := url.Parse(opts.URL)
+	if err != nil || (home.Scheme != "http" && home.Scheme != "https") {
+		return withExitCode(ExitConfigError, fmt.Errorf("invalid CalDAV URL %q", opts.URL))
+	}
+
+	store, err := openJournalTree(rootDir, config)
+	if err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+	journals, err := loadSyncJournals(store, newProgress(logger), config)
+	if err != nil {
+		return err
+	}
+
+	// The current journal is the most recent one that is not in the future
+	today := effectiveDay(now, config).Format(core.DateFormat)
+	var current *syncJournal
+	for _, j := range journals {
+		if j.date <= today {
+			current = j
+		}
+	}
+	if current == nil {
+		return fmt.Errorf("no journal found in %s", store.Location(""))
+	}
+
+	// Later journals override earlier occurrences of the same task
+	tasks := make(map[string]syncTask)
+	for _, j := range journals {
+		for _, task := range topLevelTasks(j) {
+			tasks[syncTaskID(task.item.Text)] = task
+		}
+	}
+
+	mapping, err := loadCalDAVMapping(mappingPath)
+	if err != nil {
+		return err
+	}
+
+	client := caldav.NewClient(home, &http.Client{Timeout: caldavTimeout})
+	calendar, err := client.FindCalendar(opts.Calendar)
+	if err != nil {
+		return fmt.Errorf("failed to find calendar: %w", err)
+	}
+	entries := mapping[calendar.URL.String()]
+	if entries == nil {
+		entries = make(map[string]*caldavEntry)
+		mapping[calendar.URL.String()] = entries
+	}
+
+	todoFor := func(task syncTask, uid string, completed bool) caldav.Todo {
+		todo := caldav.Todo{
+			UID:       uid,
+			Summary:   core.StripDateTags(task.item.Text),
+			Due:       task.due,
+			Completed: completed,
+			Stamp:     now,
+		}
+		if completed {
+			todo.CompletedAt = now
+		}
+		return todo
+	}
+
+	var created, pushed, pulled int
+	for id, entry := range entries {
+		task, ok := tasks[id]
+		if !ok || entry.Deleted {
+			continue
+		}
+
+		remote, err := client.GetTodo(calendar, entry.UID)
+		if errors.Is(err, caldav.ErrNotExist) {
+			logger.Info("'%s' was deleted on the server and will not be pushed again", task.item.Text)
+			entry.Deleted = true
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to fetch task '%s': %w", task.item.Text, err)
+		}
+
+		local := task.item.Completed
+		switch {
+		case local == remote.Completed:
+		case local != entry.Completed:
+			if err := client.PutTodo(calendar, todoFor(task, entry.UID, local)); err != nil {
+				return fmt.Errorf("failed to push task '%s': %w", task.item.Text, err)
+			}
+			pushed++
+		default:
+			task.item.Completed = remote.Completed
+			if config.CompletionTime {
+				at := remote.CompletedAt
+				if at.IsZero() {
+					at = now
+				}
+				core.StampCompletion(task.item, at.In(configLocation(config)))
+			}
+			task.journal.changed = true
+			pulled++
+		}
+		entry.Completed = task.item.Completed
+	}
+
+	for _, task := range topLevelTasks(current) {
+		id := syncTaskID(task.item.Text)
+		if task.item.Completed || entries[id] != nil {
+			continue
+		}
+		uid := id + "@todoer"
+		if err := client.PutTodo(calendar, todoFor(task, uid, false)); err != nil {
+			return fmt.Errorf("failed to push task '%s': %w", task.item.Text, err)
+		}
+		entries[id] = &caldavEntry{UID: uid}
+		created++
+	}
+
+	renderer := journalRenderer(config)
+	for _, j := range journals {
+		if !j.changed {
+			continue
+		}
+		updated, err := renderer.ReplaceJournal(j.content, j.journal)
+		if err != nil {
+			return fmt.Errorf("failed to update %s: %w", store.Location(j.name), err)
+		}
+		if err := writeJournalFile(store, j.name, []byte(updated), config); err != nil {
+			return withExitCode(ExitWriteError, fmt.Errorf("failed to write %s: %w", store.Location(j.name), err))
+		}
+	}
+
+	if err := saveCalDAVMapping(mappingPath, mapping); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Created %d, updated %d task(s) on %s; pulled %d change(s) into the journal\n",
+		created, pushed, calendar.Name, pulled)
+	return nil
+}
@@ -0,0 +1,464 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/inful/todoer/pkg/core"
+)
+
+// DefaultTaskwarrior is the Taskwarrior program used when taskwarrior.command is not set
+const DefaultTaskwarrior = "task"
+
+// taskwarriorTimeLayout is the layout of the dates in Taskwarrior's JSON format
+const taskwarriorTimeLayout = "20060102T150405Z"
+
+// Taskwarrior task statuses
+const (
+	taskwarriorPending   = "pending"
+	taskwarriorCompleted = "completed"
+	taskwarriorDeleted   = "deleted"
+)
+
+// taskwarriorPriorities maps priority markers such as [#A] to Taskwarrior priorities
+var taskwarriorPriorities = map[string]string{"A": "H", "B": "M", "C": "L"}
+
+// taskwarriorTask is a task in the JSON format of task export and task import.
+// Fields todoer does not use are kept as exported, so importing a task that was
+// exported does not lose them.
+type taskwarriorTask struct {
+	UUID        string
+	Description string
+	Status      string
+	Entry       string
+	Modified    string
+	End         string
+	Due         string
+	Project     string
+	Priority    string
+	Tags        []string
+
+	raw map[string]json.RawMessage // All fields of the exported task
+}
+
+// taskwarriorFields points at the fields of t by their JSON names.
+func (t *taskwarriorTask) taskwarriorFields() map[string]interface{} {
+	return map[string]interface{}{
+		"uuid": &t.UUID, "description": &t.Description, "status": &t.Status,
+		"entry": &t.Entry, "modified": &t.Modified, "end": &t.End, "due": &t.Due,
+		"project": &t.Project, "priority": &t.Priority, "tags": &t.Tags,
+	}
+}
+
+// UnmarshalJSON decodes an exported task, keeping every field in raw.
+func (t *taskwarriorTask) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &t.raw); err != nil {
+		return err
+	}
+	for name, field := range t.taskwarriorFields() {
+		if value, ok := t.raw[name]; ok {
+			if err := json.Unmarshal(value, field); err != nil {
+				return fmt.Errorf("invalid %s of task: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes the task with the fields of raw, overridden by the task's
+// own fields. Empty fields are left out.
+func (t *taskwarriorTask) MarshalJSON() ([]byte, error) {
+	fields := make(map[string]interface{}, len(t.raw))
+	for name, value := range t.raw {
+		fields[name] = value
+	}
+	for name, field := range t.taskwarriorFields() {
+		delete(fields, name)
+		switch v := field.(type) {
+		case *string:
+			if *v != "" {
+				fields[name] = *v
+			}
+		case *[]string:
+			if len(*v) > 0 {
+				fields[name] = *v
+			}
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// taskwarrior reads and writes the tasks of a Taskwarrior database.
+type taskwarrior interface {
+	Export() ([]*taskwarriorTask, error)
+	Import(tasks []*taskwarriorTask) error
+}
+
+// taskCommand is a taskwarrior using the task command-line program.
+type taskCommand struct {
+	program string
+}
+
+// newTaskCommand returns the taskwarrior running the configured program.
+func newTaskCommand(config *Config) *taskCommand {
+	program := config.Taskwarrior.Command
+	if program == "" {
+		program = DefaultTaskwarrior
+	}
+	return &taskCommand{program: program}
+}
+
+// Export returns all tasks, as printed by task export.
+func (c *taskCommand) Export() ([]*taskwarriorTask, error) {
+	output, err := c.run(nil, "export")
+	if err != nil {
+		return nil, err
+	}
+	var tasks []*taskwarriorTask
+	if err := json.Unmarshal(output, &tasks); err != nil {
+		return nil, fmt.Errorf("failed to decode task export: %w", err)
+	}
+	return tasks, nil
+}
+
+// Import adds tasks, or updates the tasks with their UUIDs, with task import.
+func (c *taskCommand) Import(tasks []*taskwarriorTask) error {
+	data, err := json.Marshal(tasks)
+	if err != nil {
+		return fmt.Errorf("failed to encode tasks: %w", err)
+	}
+	_, err = c.run(data, "import")
+	return err
+}
+
+// run runs the task program with args and stdin, returning its output.
+func (c *taskCommand) run(stdin []byte, args ...string) ([]byte, error) {
+	if _, err := exec.LookPath(c.program); err != nil {
+		return nil, withExitCode(ExitConfigError, fmt.Errorf("%s is not installed: %w", c.program, err))
+	}
+	cmd := exec.Command(c.program, append([]string{"rc.confirmation=off", "rc.hooks=off"}, args...)...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s %s failed: %w: %s", c.program, args[0], err, msg)
+		}
+		return nil, fmt.Errorf("%s %s failed: %w", c.program, args[0], err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// taskwarriorMapping is the sync state kept between runs: the entry of every
+// synced task keyed by task ID, see syncTaskID.
+type taskwarriorMapping map[string]*taskwarriorEntry
+
+// taskwarriorEntry links a journal task to the Taskwarrior task it was synced with.
+type taskwarriorEntry struct {
+	UUID      string `json:"uuid"`
+	Completed bool   `json:"completed"`         // Completion state agreed on at the last sync
+	Deleted   bool   `json:"deleted,omitempty"` // The task was deleted in Taskwarrior
+}
+
+// taskwarriorMappingPath returns the location of the Taskwarrior mapping file.
+func taskwarriorMappingPath() (string, error) {
+	dataHome, err := getDataDir()
+	if err != nil {
+		return "", fmt.Errorf("could not determine data directory: %w", err)
+	}
+	return filepath.Join(dataHome, ConfigDirName, TaskwarriorMappingFile), nil
+}
+
+// loadTaskwarriorMapping reads the mapping file, returning an empty mapping if there is none.
+func loadTaskwarriorMapping(path string) (taskwarriorMapping, error) {
+	mapping := taskwarriorMapping{}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return mapping, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync mapping: %w", err)
+	}
+	if err := json.Unmarshal(content, &mapping); err != nil {
+		return nil, fmt.Errorf("failed to decode sync mapping %s: %w", path, err)
+	}
+	return mapping, nil
+}
+
+// saveTaskwarriorMapping writes the mapping file.
+func saveTaskwarriorMapping(path string, mapping taskwarriorMapping) error {
+	content, err := json.MarshalIndent(mapping, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode sync mapping: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), dirMode()); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	if err := safeWriteFile(path, content, fileMode); err != nil {
+		return withExitCode(ExitWriteError, fmt.Errorf("failed to write sync mapping: %w", err))
+	}
+	return nil
+}
+
+// newTaskwarriorUUID returns a random version 4 UUID for a new Taskwarrior task.
+func newTaskwarriorUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// taskwarriorDate returns the Taskwarrior timestamp of the local start of date.
+func taskwarriorDate(date string) string {
+	t, err := time.ParseInLocation(core.DateFormat, date, time.Local)
+	if err != nil {
+		return ""
+	}
+	return t.UTC().Format(taskwarriorTimeLayout)
+}
+
+// taskwarriorLocalDate returns the local date of a Taskwarrior timestamp.
+func taskwarriorLocalDate(timestamp string) string {
+	t, err := time.Parse(taskwarriorTimeLayout, timestamp)
+	if err != nil {
+		return ""
+	}
+	return t.Local().Format(core.DateFormat)
+}
+
+// newTaskwarriorTask returns the Taskwarrior task for a journal task. The first tag
+// of the text becomes the project, with '/' as '.', and the others tags. The due
+// date and priority marker are taken over too; the description is what remains of
+// the text.
+func newTaskwarriorTask(uuid, text string, completed bool, now time.Time) *taskwarriorTask {
+	task := &taskwarriorTask{
+		UUID:     uuid,
+		Status:   taskwarriorPending,
+		Entry:    now.UTC().Format(taskwarriorTimeLayout),
+		Modified: now.UTC().Format(taskwarriorTimeLayout),
+	}
+	if completed {
+		task.Status = taskwarriorCompleted
+		task.End = task.Modified
+	}
+
+	description := core.StripDateTags(text)
+	if due := core.ExtractDue(description); due != "" {
+		task.Due = taskwarriorDate(due)
+		annotations := core.ParseAnnotations(description)
+		for i := len(annotations) - 1; i >= 0; i-- {
+			if a := annotations[i]; a.Key == "due" {
+				description = description[:a.Start] + description[a.End:]
+			}
+		}
+	}
+	if m := core.PriorityRegex.FindStringSubmatch(description); m != nil {
+		task.Priority = taskwarriorPriorities[strings.ToUpper(m[1])]
+		description = strings.Replace(description, m[0], "", 1)
+	}
+	for i, tag := range core.ExtractTags(description) {
+		name := strings.TrimPrefix(tag, "#")
+		if i == 0 {
+			task.Project = strings.ReplaceAll(name, "/", ".")
+		} else {
+			task.Tags = append(task.Tags, name)
+		}
+	}
+	description = core.TagRegex.ReplaceAllStringFunc(description, func(match string) string {
+		if core.DateTagRegex.MatchString(match) {
+			return match
+		}
+		return ""
+	})
+	task.Description = strings.Join(strings.Fields(description), " ")
+	return task
+}
+
+// journalTaskText returns the text of the journal task for a task added in
+// Taskwarrior, the reverse of newTaskwarriorTask.
+func journalTaskText(task *taskwarriorTask) string {
+	parts := []string{strings.TrimSpace(task.Description)}
+	if task.Project != "" {
+		parts = append(parts, "#"+strings.ReplaceAll(task.Project, ".", "/"))
+	}
+	for _, tag := range task.Tags {
+		parts = append(parts, "#"+tag)
+	}
+	if due := taskwarriorLocalDate(task.Due); due != "" {
+		parts = append(parts, core.FormatAnnotation("due", due))
+	}
+	for marker, priority := range taskwarriorPriorities {
+		if task.Priority == priority {
+			parts = append(parts, "[#"+marker+"]")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// cmdSyncTaskwarrior syncs the journals below rootDir with Taskwarrior through tw
+// and reports the changes on w.
+//
+// Open top-level tasks of the current journal that were never synced are added to
+// Taskwarrior, and pending Taskwarrior tasks that were never synced are added to
+// the current journal under today's date. For synced tasks, completions are synced
+// both ways with the rules of cmdSyncCalDAV: a change on one side since the last
+// sync is applied to the other, the journal owns the task text, and a task deleted
+// in Taskwarrior is not added again. The mapping file remembers the UUID and agreed
+// completion state of every synced task.
+func cmdSyncTaskwarrior(w io.Writer, tw taskwarrior, mappingPath, rootDir string, now time.Time, config *Config, logger *Logger) error {
+	store, err := openJournalTree(rootDir, config)
+	if err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+	journals, err := loadSyncJournals(store, newProgress(logger), config)
+	if err != nil {
+		return err
+	}
+
+	// The current journal is the most recent one that is not in the future
+	today := effectiveDay(now, config).Format(core.DateFormat)
+	var current *syncJournal
+	for _, j := range journals {
+		if j.date <= today {
+			current = j
+		}
+	}
+	if current == nil {
+		return fmt.Errorf("no journal found in %s", store.Location(""))
+	}
+
+	// Later journals override earlier occurrences of the same task
+	tasks := make(map[string]syncTask)
+	for _, j := range journals {
+		for _, task := range topLevelTasks(j) {
+			tasks[syncTaskID(task.item.Text)] = task
+		}
+	}
+
+	mapping, err := loadTaskwarriorMapping(mappingPath)
+	if err != nil {
+		return err
+	}
+	remote, err := tw.Export()
+	if err != nil {
+		return fmt.Errorf("failed to read tasks: %w", err)
+	}
+	byUUID := make(map[string]*taskwarriorTask, len(remote))
+	for _, task := range remote {
+		byUUID[task.UUID] = task
+	}
+
+	var pushes []*taskwarriorTask
+	var created, pushed, pulled, added int
+	mapped := make(map[string]bool, len(mapping))
+	for id, entry := range mapping {
+		mapped[entry.UUID] = true
+		task, ok := tasks[id]
+		if !ok || entry.Deleted {
+			continue
+		}
+
+		other, ok := byUUID[entry.UUID]
+		if !ok || other.Status == taskwarriorDeleted {
+			logger.Info("'%s' was deleted in Taskwarrior and will not be added again", task.item.Text)
+			entry.Deleted = true
+			continue
+		}
+
+		local := task.item.Completed
+		remoteCompleted := other.Status == taskwarriorCompleted
+		switch {
+		case local == remoteCompleted:
+		case local != entry.Completed:
+			other.Status, other.End = taskwarriorPending, ""
+			if local {
+				other.Status, other.End = taskwarriorCompleted, now.UTC().Format(taskwarriorTimeLayout)
+			}
+			other.Modified = now.UTC().Format(taskwarriorTimeLayout)
+			pushes = append(pushes, other)
+			pushed++
+		default:
+			task.item.Completed = remoteCompleted
+			if config.CompletionTime {
+				at, err := time.Parse(taskwarriorTimeLayout, other.End)
+				if err != nil {
+					at = now
+				}
+				core.StampCompletion(task.item, at.In(configLocation(config)))
+			}
+			task.journal.changed = true
+			pulled++
+		}
+		entry.Completed = task.item.Completed
+	}
+
+	for _, task := range topLevelTasks(current) {
+		id := syncTaskID(task.item.Text)
+		if task.item.Completed || mapping[id] != nil {
+			continue
+		}
+		uuid, err := newTaskwarriorUUID()
+		if err != nil {
+			return fmt.Errorf("failed to create task UUID: %w", err)
+		}
+		pushes = append(pushes, newTaskwarriorTask(uuid, task.item.Text, false, now))
+		mapping[id] = &taskwarriorEntry{UUID: uuid}
+		created++
+	}
+
+	for _, task := range remote {
+		if task.Status != taskwarriorPending || mapped[task.UUID] {
+			continue
+		}
+		text := journalTaskText(task)
+		id := syncTaskID(text)
+		if mapping[id] != nil {
+			continue
+		}
+		day := core.EnsureDay(current.journal, today)
+		day.Items = append(day.Items, &core.TodoItem{Text: text, Meta: core.ParseMeta(text)})
+		current.changed = true
+		mapping[id] = &taskwarriorEntry{UUID: task.UUID}
+		added++
+	}
+
+	if len(pushes) > 0 {
+		if err := tw.Import(pushes); err != nil {
+			return fmt.Errorf("failed to write tasks: %w", err)
+		}
+	}
+
+	renderer := journalRenderer(config)
+	for _, j := range journals {
+		if !j.changed {
+			continue
+		}
+		updated, err := renderer.ReplaceJournal(j.content, j.journal)
+		if err != nil {
+			return fmt.Errorf("failed to update %s: %w", store.Location(j.name), err)
+		}
+		if err := writeJournalFile(store, j.name, []byte(updated), config); err != nil {
+			return withExitCode(ExitWriteError, fmt.Errorf("failed to write %s: %w", store.Location(j.name), err))
+		}
+	}
+
+	if err := saveTaskwarriorMapping(mappingPath, mapping); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Created %d, updated %d task(s) in Taskwarrior; pulled %d change(s) and %d new task(s) into the journal\n",
+		created, pushed, pulled, added)
+	return nil
+}
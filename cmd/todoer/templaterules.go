@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/inful/todoer/pkg/core"
+)
+
+// Keys of [templates] rules besides weekday names and YYYY-MM-DD dates
+const (
+	TemplateRuleDefault      = "*"              // Any date
+	TemplateRuleWorkday      = "workday"        // Monday to Friday
+	TemplateRuleWeekend      = "weekend"        // Saturday and Sunday
+	TemplateRuleFirstOfMonth = "first-of-month" // The 1st of the month
+	TemplateRuleLastOfMonth  = "last-of-month"  // The last day of the month
+	TemplateRuleFirstWorkday = "first-workday"  // The first workday of the month
+	TemplateRuleLastWorkday  = "last-workday"   // The last workday of the month
+)
+
+// templateRuleRanks orders the rules that are not dates or weekday names; when several
+// rules match a date, the one with the lowest rank wins. Dates rank 0 and weekday
+// names rank after the rules about the month.
+var templateRuleRanks = map[string]int{
+	TemplateRuleLastWorkday:  1,
+	TemplateRuleFirstWorkday: 2,
+	TemplateRuleLastOfMonth:  3,
+	TemplateRuleFirstOfMonth: 4,
+	TemplateRuleWorkday:      6,
+	TemplateRuleWeekend:      6,
+	TemplateRuleDefault:      7,
+}
+
+// weekdayRank is the rank of weekday name rules, see templateRuleRanks
+const weekdayRank = 5
+
+// templateRuleRank returns the rank of rule, and whether rule is known at all.
+func templateRuleRank(rule string) (int, bool) {
+	if rank, ok := templateRuleRanks[rule]; ok {
+		return rank, true
+	}
+	if _, ok := parseWeekday(rule); ok {
+		return weekdayRank, true
+	}
+	if core.ValidateDate(rule) == nil {
+		return 0, true
+	}
+	return 0, false
+}
+
+// parseWeekday returns the weekday named by name, such as "monday" or "Mon".
+func parseWeekday(name string) (time.Weekday, bool) {
+	name = strings.ToLower(name)
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		full := strings.ToLower(d.String())
+		if name == full || name == full[:3] {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// isWorkday reports whether t falls on Monday to Friday.
+func isWorkday(t time.Time) bool {
+	return t.Weekday() != time.Saturday && t.Weekday() != time.Sunday
+}
+
+// templateRuleMatches reports whether rule applies to the date t.
+func templateRuleMatches(rule string, t time.Time) bool {
+	switch rule {
+	case TemplateRuleDefault:
+		return true
+	case TemplateRuleWorkday:
+		return isWorkday(t)
+	case TemplateRuleWeekend:
+		return !isWorkday(t)
+	case TemplateRuleFirstOfMonth:
+		return t.Day() == 1
+	case TemplateRuleLastOfMonth:
+		return t.AddDate(0, 0, 1).Day() == 1
+	case TemplateRuleFirstWorkday:
+		if !isWorkday(t) {
+			return false
+		}
+		for d := t.AddDate(0, 0, -1); d.Month() == t.Month(); d = d.AddDate(0, 0, -1) {
+			if isWorkday(d) {
+				return false
+			}
+		}
+		return true
+	case TemplateRuleLastWorkday:
+		if !isWorkday(t) {
+			return false
+		}
+		for d := t.AddDate(0, 0, 1); d.Month() == t.Month(); d = d.AddDate(0, 0, 1) {
+			if isWorkday(d) {
+				return false
+			}
+		}
+		return true
+	}
+	if d, ok := parseWeekday(rule); ok {
+		return t.Weekday() == d
+	}
+	return rule == t.Format(core.DateFormat)
+}
+
+// selectTemplate returns the template file for date: the template of the matching
+// [templates] rule with the lowest rank, or config.TemplateFile if none matches.
+// Rules of the same rank are tried in alphabetical order.
+func selectTemplate(config *Config, date string) (string, error) {
+	if len(config.Templates) == 0 {
+		return config.TemplateFile, nil
+	}
+	t, err := time.Parse(core.DateFormat, date)
+	if err != nil {
+		return "", fmt.Errorf("%w: expected format YYYY-MM-DD, got %s", ErrInvalidDate, date)
+	}
+
+	best, bestRank := "", -1
+	for rule := range config.Templates {
+		rank, ok := templateRuleRank(rule)
+		if !ok || !templateRuleMatches(rule, t) {
+			continue
+		}
+		if bestRank < 0 || rank < bestRank || (rank == bestRank && rule < best) {
+			best, bestRank = rule, rank
+		}
+	}
+	if bestRank < 0 {
+		return config.TemplateFile, nil
+	}
+	return config.Templates[best], nil
+}
+
+// validateTemplateRules checks that every [templates] rule is known and names a template.
+func validateTemplateRules(rules map[string]string) error {
+	for rule, file := range rules {
+		if _, ok := templateRuleRank(rule); !ok {
+			return fmt.Errorf("%w: unknown template rule %q (use a weekday, a YYYY-MM-DD date, %s)", ErrInvalidConfig, rule,
+				strings.Join([]string{TemplateRuleWorkday, TemplateRuleWeekend, TemplateRuleFirstOfMonth, TemplateRuleLastOfMonth, TemplateRuleFirstWorkday, TemplateRuleLastWorkday, `or "*"`}, ", "))
+		}
+		if file == "" {
+			return fmt.Errorf("%w: template rule %q has no template file", ErrInvalidConfig, rule)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/inful/todoer/pkg/core"
+)
+
+//go:embed default_scaffold.md
+var scaffoldTemplate string
+
+// Sources of the templates listed by templates list
+const (
+	templateSourceEmbedded   = "embedded"   // Built into todoer
+	templateSourceConfig     = "config"     // template.md in the config directory
+	templateSourceNamed      = "templates"  // In the templates directory
+	templateSourceConfigured = "configured" // Referenced by the configuration only
+)
+
+// templateEntry is a template listed by templates list.
+type templateEntry struct {
+	Name     string   // Reference to pass to --template-file
+	Source   string   // One of the templateSource* constants
+	Location string   // File or URL of the template
+	UsedBy   []string // Configuration keys using the template; "default" for the fallback
+}
+
+// templateUsage holds the variables and functions a template refers to.
+type templateUsage struct {
+	Variables []string // Fields of the template data, e.g. ".Date" or ".Custom.Project"
+	Functions []string // Functions called, e.g. "addDays"
+}
+
+// configuredTemplates returns the templates referenced by config, keyed by the
+// dotted TOML key referencing them.
+func configuredTemplates(config *Config) map[string]string {
+	refs := make(map[string]string)
+	if config.TemplateFile != "" {
+		refs["template_file"] = config.TemplateFile
+	}
+	for rule, ref := range config.Templates {
+		refs["templates."+rule] = ref
+	}
+	for name, output := range config.Outputs {
+		if output.Template != "" {
+			refs["outputs."+name+".template"] = output.Template
+		}
+	}
+	if config.Digest.Template != "" {
+		refs["digest.template"] = config.Digest.Template
+	}
+	if config.Post.Template != "" {
+		refs["post.template"] = config.Post.Template
+	}
+	return refs
+}
+
+// listTemplates returns the embedded template, template.md in the config directory,
+// the templates in the templates directory and those referenced by config.
+func listTemplates(store *templateStore, config *Config) ([]templateEntry, error) {
+	entries := []templateEntry{{Name: "", Source: templateSourceEmbedded, Location: "embedded default template"}}
+	fallback := 0
+	if configHome, err := getConfigDir(); err == nil {
+		configTemplate := filepath.Join(configHome, ConfigDirName, TemplateFileName)
+		if _, err := os.Stat(configTemplate); err == nil {
+			entries = append(entries, templateEntry{Name: configTemplate, Source: templateSourceConfig, Location: configTemplate})
+			fallback = len(entries) - 1
+		}
+	}
+	if config.TemplateFile == "" {
+		entries[fallback].UsedBy = append(entries[fallback].UsedBy, "default")
+	}
+
+	if store.dir != "" {
+		files, err := os.ReadDir(store.dir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read templates directory %s: %w", store.dir, err)
+		}
+		for _, file := range files {
+			name := strings.TrimSuffix(file.Name(), ".md")
+			if file.IsDir() || name == file.Name() || !isTemplateName(name) {
+				continue
+			}
+			entries = append(entries, templateEntry{Name: name, Source: templateSourceNamed, Location: store.file(name)})
+		}
+	}
+
+	refs := configuredTemplates(config)
+	keys := make([]string, 0, len(refs))
+	for key := range refs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		ref := refs[key]
+		location := store.file(ref)
+		if location == "" {
+			location = ref
+		}
+		found := false
+		for i := range entries {
+			if entries[i].Location == location {
+				entries[i].UsedBy = append(entries[i].UsedBy, key)
+				found = true
+				break
+			}
+		}
+		if !found {
+			entries = append(entries, templateEntry{Name: ref, Source: templateSourceConfigured, Location: location, UsedBy: []string{key}})
+		}
+	}
+	return entries, nil
+}
+
+// cmdTemplatesList prints the templates of listTemplates on w.
+func cmdTemplatesList(w io.Writer, config *Config) error {
+	entries, err := listTemplates(newTemplateStore(), config)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSOURCE\tLOCATION\tUSED BY")
+	for _, entry := range entries {
+		name := entry.Name
+		if entry.Source == templateSourceEmbedded {
+			name = "-"
+		}
+		usedBy := strings.Join(entry.UsedBy, ", ")
+		if usedBy == "" {
+			usedBy = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", name, entry.Source, entry.Location, usedBy)
+	}
+	return tw.Flush()
+}
+
+// inspectTemplate parses the template content and returns the variables and
+// functions it refers to. Fields are only reported where dot is the template
+// data, and inside range and with blocks when accessed through $. The Sprig
+// functions are accepted whether or not they are enabled.
+func inspectTemplate(content string) (templateUsage, error) {
+	tmpl, err := template.New("inspect").Funcs(core.TemplateFunctions(true)).Parse(content)
+	if err != nil {
+		return templateUsage{}, fmt.Errorf("invalid template: %w", err)
+	}
+
+	variables := make(map[string]bool)
+	functions := make(map[string]bool)
+	var walk func(node parse.Node, root bool)
+	walk = func(node parse.Node, root bool) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child, root)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe, root)
+		case *parse.IfNode:
+			walk(n.Pipe, root)
+			walk(n.List, root)
+			walk(n.ElseList, root)
+		case *parse.RangeNode:
+			walk(n.Pipe, root)
+			walk(n.List, false)
+			walk(n.ElseList, root)
+		case *parse.WithNode:
+			walk(n.Pipe, root)
+			walk(n.List, false)
+			walk(n.ElseList, root)
+		case *parse.TemplateNode:
+			walk(n.Pipe, root)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd, root)
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				walk(arg, root)
+			}
+		case *parse.ChainNode:
+			walk(n.Node, root)
+		case *parse.FieldNode:
+			if root {
+				variables["."+strings.Join(n.Ident, ".")] = true
+			}
+		case *parse.VariableNode:
+			if n.Ident[0] == "$" && len(n.Ident) > 1 {
+				variables["."+strings.Join(n.Ident[1:], ".")] = true
+			}
+		case *parse.IdentifierNode:
+			functions[n.Ident] = true
+		}
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			walk(t.Tree.Root, true)
+		}
+	}
+
+	usage := templateUsage{Variables: make([]string, 0, len(variables)), Functions: make([]string, 0, len(functions))}
+	for name := range variables {
+		usage.Variables = append(usage.Variables, name)
+	}
+	for name := range functions {
+		usage.Functions = append(usage.Functions, name)
+	}
+	sort.Strings(usage.Variables)
+	sort.Strings(usage.Functions)
+	return usage, nil
+}
+
+// cmdTemplatesShow prints the template ref, or the template used for today if ref is
+// empty, with the variables and functions it uses.
+func cmdTemplatesShow(w io.Writer, ref string) error {
+	source := resolveTemplate(ref)
+	if source.err != nil {
+		return withExitCode(ExitConfigError, source.err)
+	}
+	usage, err := inspectTemplate(source.content)
+	if err != nil {
+		return withExitCode(ExitConfigError, fmt.Errorf("%s: %w", source.name, err))
+	}
+
+	list := func(names []string) string {
+		if len(names) == 0 {
+			return "none"
+		}
+		return strings.Join(names, ", ")
+	}
+	fmt.Fprintf(w, "Template:  %s\n", source.name)
+	fmt.Fprintf(w, "Variables: %s\n", list(usage.Variables))
+	fmt.Fprintf(w, "Functions: %s\n\n", list(usage.Functions))
+	fmt.Fprint(w, source.content)
+	if !strings.HasSuffix(source.content, "\n") {
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+// cmdTemplatesNew writes a template with documented placeholders as name in the
+// templates directory of store. An existing template is kept unless force is set.
+func cmdTemplatesNew(w io.Writer, store *templateStore, name string, force bool, logger *Logger) error {
+	if !isTemplateName(name) {
+		return withExitCode(ExitConfigError, fmt.Errorf("invalid template name %q (use letters, digits, - and _)", name))
+	}
+	if store.dir == "" {
+		return withExitCode(ExitConfigError, errors.New("could not determine the templates directory"))
+	}
+	path := store.file(name)
+	if _, err := os.Stat(path); err == nil && !force {
+		return withExitCode(ExitConfigError, fmt.Errorf("template %s already exists (use --force to overwrite)", path))
+	}
+	if err := os.MkdirAll(store.dir, dirMode()); err != nil {
+		return withExitCode(ExitWriteError, fmt.Errorf("failed to create templates directory: %w", err))
+	}
+	if err := safeWriteFile(path, []byte(scaffoldTemplate), fileMode); err != nil {
+		return withExitCode(ExitWriteError, fmt.Errorf("failed to write %s: %w", path, err))
+	}
+	logger.Debug("Wrote %s", path)
+	fmt.Fprintf(w, "Wrote %s\nUse it with template_file = %q or --template-file %s\n", path, name, name)
+	return nil
+}
+
+// cmdTemplatesEdit opens the template file or named template ref in $VISUAL or
+// $EDITOR, falling back to vi.
+func cmdTemplatesEdit(store *templateStore, ref string) error {
+	path := store.file(ref)
+	if path == "" {
+		return withExitCode(ExitConfigError, fmt.Errorf("cannot edit template %s fetched from a URL", ref))
+	}
+	if _, err := os.Stat(path); err != nil {
+		if isTemplateName(ref) && os.IsNotExist(err) {
+			return withExitCode(ExitConfigError, fmt.Errorf("%w: template %q does not exist, create it with todoer templates new %s", ErrTemplateNotFound, ref, ref))
+		}
+		return withExitCode(ExitConfigError, fmt.Errorf("%w: %v", ErrTemplateNotFound, err))
+	}
+
+	return runEditor(path)
+}
+
+// runEditor opens the file path in $VISUAL, $EDITOR or vi and waits for it to exit.
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	args := strings.Fields(editor)
+	if len(args) == 0 {
+		args = []string{"vi"}
+	}
+	cmd := exec.Command(args[0], append(args[1:], path)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor %s failed: %w", args[0], err)
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Fetching of templates given by URL
+const (
+	templateFetchTimeout = 10 * time.Second // Bounds each template download
+	templateCacheTTL     = 24 * time.Hour   // Age after which an unpinned cached template is fetched again
+	maxTemplateSize      = 1 << 20          // Largest template accepted from a URL, in bytes
+)
+
+// templateNameRegex matches template references that name a template in the
+// templates directory rather than a file: no path separators and no extension.
+var templateNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// templateSource represents different sources of templates
+type templateSource struct {
+	content string
+	name    string
+	err     error
+}
+
+// isTemplateURL reports whether ref is a template fetched over HTTP(S).
+func isTemplateURL(ref string) bool {
+	return strings.HasPrefix(ref, "https://") || strings.HasPrefix(ref, "http://")
+}
+
+// isTemplateName reports whether ref names a template in the templates directory,
+// like "daily" for $XDG_CONFIG_HOME/todoer/templates/daily.md.
+func isTemplateName(ref string) bool {
+	return templateNameRegex.MatchString(ref)
+}
+
+// isTemplatePath reports whether ref is a template file path, which is made
+// relative to the config file defining it.
+func isTemplatePath(ref string) bool {
+	return ref != "" && !isTemplateURL(ref) && !isTemplateName(ref)
+}
+
+// templateStore loads templates by reference: a file path, the name of a template
+// in the templates directory, or an HTTP(S) URL. A URL may pin the expected content
+// with a "#sha256=<hex>" fragment. Fetched templates are cached, so a template that
+// was fetched once keeps working offline.
+type templateStore struct {
+	dir      string // Directory of named templates; empty if unknown
+	cacheDir string // Directory fetched templates are cached in; empty disables caching
+	client   *http.Client
+}
+
+// newTemplateStore returns the store of the templates directory in the config
+// directory, caching fetched templates in the cache directory.
+func newTemplateStore() *templateStore {
+	store := &templateStore{client: &http.Client{Timeout: templateFetchTimeout}}
+	if configHome, err := getConfigDir(); err == nil {
+		store.dir = filepath.Join(configHome, ConfigDirName, TemplatesDirName)
+	}
+	if cacheHome, err := getCacheDir(); err == nil {
+		store.cacheDir = filepath.Join(cacheHome, ConfigDirName, TemplatesDirName)
+	}
+	return store
+}
+
+// file returns the local file of the template ref, or an empty string for URLs.
+func (s *templateStore) file(ref string) string {
+	switch {
+	case isTemplateURL(ref):
+		return ""
+	case isTemplateName(ref) && s.dir != "":
+		return filepath.Join(s.dir, ref+".md")
+	default:
+		return ref
+	}
+}
+
+// load returns the template ref.
+func (s *templateStore) load(ref string) templateSource {
+	if isTemplateURL(ref) {
+		return s.fetch(ref)
+	}
+	if isTemplateName(ref) {
+		if s.dir == "" {
+			return templateSource{err: fmt.Errorf("%w: cannot find template %q without a config directory", ErrTemplateNotFound, ref)}
+		}
+		path := s.file(ref)
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return templateSource{err: fmt.Errorf("failed to read template %q: %w", ref, err)}
+		}
+		return templateSource{content: string(content), name: path}
+	}
+
+	content, err := os.ReadFile(ref)
+	if err != nil {
+		return templateSource{err: fmt.Errorf("failed to read template file '%s': %w", ref, err)}
+	}
+	return templateSource{content: string(content), name: ref}
+}
+
+// fetch returns the template at the URL ref. A cached copy is used while it is
+// fresh, or for as long as it matches the pinned checksum; otherwise the template
+// is downloaded again, falling back to the cached copy if that fails.
+func (s *templateStore) fetch(ref string) templateSource {
+	location, sum, err := parseTemplateURL(ref)
+	if err != nil {
+		return templateSource{err: err}
+	}
+
+	cachePath := s.cachePath(location)
+	cached, cacheErr := s.readCache(cachePath, sum)
+	if cacheErr == nil && (sum != "" || s.cacheFresh(cachePath)) {
+		return templateSource{content: cached, name: location}
+	}
+
+	content, err := s.download(location)
+	if err == nil {
+		err = verifyTemplate(location, content, sum)
+	}
+	if err != nil {
+		if cacheErr == nil {
+			return templateSource{content: cached, name: location}
+		}
+		return templateSource{err: err}
+	}
+
+	if cachePath != "" && !readOnly {
+		// The cache only saves downloads, so failing to write it is not an error
+		if err := os.MkdirAll(filepath.Dir(cachePath), dirMode()); err == nil {
+			_ = os.WriteFile(cachePath, []byte(content), fileMode)
+		}
+	}
+	return templateSource{content: content, name: location}
+}
+
+// download fetches the template at location.
+func (s *templateStore) download(location string) (string, error) {
+	resp, err := s.client.Get(location)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch template %s: %w", location, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("failed to fetch template %s: %s", location, resp.Status)
+	}
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxTemplateSize+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch template %s: %w", location, err)
+	}
+	if len(content) > maxTemplateSize {
+		return "", fmt.Errorf("template %s is larger than %d bytes", location, maxTemplateSize)
+	}
+	return string(content), nil
+}
+
+// cachePath returns the cache file of the template at location, or an empty
+// string if caching is disabled.
+func (s *templateStore) cachePath(location string) string {
+	if s.cacheDir == "" {
+		return ""
+	}
+	key := sha256.Sum256([]byte(location))
+	return filepath.Join(s.cacheDir, hex.EncodeToString(key[:])+".md")
+}
+
+// readCache returns the cached template at cachePath if it matches sum.
+func (s *templateStore) readCache(cachePath, sum string) (string, error) {
+	if cachePath == "" {
+		return "", errors.New("template cache is disabled")
+	}
+	content, err := os.ReadFile(cachePath)
+	if err != nil {
+		return "", err
+	}
+	if err := verifyTemplate(cachePath, string(content), sum); err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// cacheFresh reports whether the cached template at cachePath is younger than
+// templateCacheTTL.
+func (s *templateStore) cacheFresh(cachePath string) bool {
+	info, err := os.Stat(cachePath)
+	return err == nil && time.Since(info.ModTime()) < templateCacheTTL
+}
+
+// parseTemplateURL splits a template URL into the location fetched and the
+// checksum pinned by its "#sha256=<hex>" fragment, if any.
+func parseTemplateURL(ref string) (string, string, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: invalid template URL %q: %v", ErrInvalidConfig, ref, err)
+	}
+	if u.Host == "" {
+		return "", "", fmt.Errorf("%w: template URL %q has no host", ErrInvalidConfig, ref)
+	}
+
+	sum := ""
+	if u.Fragment != "" {
+		hexSum, ok := strings.CutPrefix(u.Fragment, "sha256=")
+		if _, err := hex.DecodeString(hexSum); !ok || err != nil || len(hexSum) != 2*sha256.Size {
+			return "", "", fmt.Errorf("%w: template URL %q must end in #sha256=<64 hex digits>, got #%s", ErrInvalidConfig, ref, u.Fragment)
+		}
+		sum = strings.ToLower(hexSum)
+	}
+	u.Fragment = ""
+	return u.String(), sum, nil
+}
+
+// verifyTemplate checks that the template content from source has the sha256
+// checksum sum. An empty sum accepts any content.
+func verifyTemplate(source, content, sum string) error {
+	if sum == "" {
+		return nil
+	}
+	actual := sha256.Sum256([]byte(content))
+	if got := hex.EncodeToString(actual[:]); got != sum {
+		return fmt.Errorf("template %s does not match its checksum: want sha256 %s, got %s", source, sum, got)
+	}
+	return nil
+}
+
+// resolveTemplate determines the template content and source based on configuration
+func resolveTemplate(templateFile string) templateSource {
+	if templateFile != "" {
+		return newTemplateStore().load(templateFile)
+	}
+
+	// Try config directory template
+	configHome, err := getConfigDir()
+	if err != nil {
+		// Fall back to embedded template if can't determine config dir
+		return templateSource{content: defaultTemplate, name: "embedded default template"}
+	}
+
+	configTemplate := filepath.Join(configHome, ConfigDirName, TemplateFileName)
+	if _, err := os.Stat(configTemplate); err == nil {
+		content, err := os.ReadFile(configTemplate)
+		if err != nil {
+			return templateSource{err: fmt.Errorf("failed to read config template '%s': %w", configTemplate, err)}
+		}
+		return templateSource{content: string(content), name: configTemplate}
+	}
+
+	// Fall back to embedded template
+	return templateSource{content: defaultTemplate, name: "embedded default template"}
+}
+
+// validateTemplateRef checks that the template ref can be loaded: that a template
+// file or named template exists, or that a template URL is well-formed.
+func validateTemplateRef(ref string) error {
+	if isTemplateURL(ref) {
+		_, _, err := parseTemplateURL(ref)
+		return err
+	}
+	if isTemplateName(ref) {
+		path := newTemplateStore().file(ref)
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("%w: template %q does not exist: no file %s", ErrTemplateNotFound, ref, path)
+		}
+		return nil
+	}
+
+	if err := validateFilePath(ref); err != nil {
+		return fmt.Errorf("invalid template file '%s': %w", ref, err)
+	}
+
+	// Check if template file exists and is readable
+	if info, err := os.Stat(ref); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: template file '%s' does not exist", ErrTemplateNotFound, ref)
+		} else if os.IsPermission(err) {
+			return fmt.Errorf("%w: cannot read template file '%s': %v", ErrPermissionDenied, ref, err)
+		} else {
+			return fmt.Errorf("%w: error accessing template file '%s': %v", ErrInvalidConfig, ref, err)
+		}
+	} else if info.IsDir() {
+		return fmt.Errorf("%w: template path '%s' is a directory, not a file", ErrInvalidConfig, ref)
+	}
+	return nil
+}
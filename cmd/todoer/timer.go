@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/inful/todoer/pkg/core"
+	"github.com/inful/todoer/pkg/storage"
+)
+
+// timerState is the running timer persisted between timer start and timer stop.
+type timerState struct {
+	Task    string    `json:"task"`            // Pattern identifying the timed task
+	Exact   bool      `json:"exact,omitempty"` // Task is the whole text of the task
+	ID      bool      `json:"id,omitempty"`    // Task is the id:: of the task
+	Date    string    `json:"date"`            // Date of the journal holding the task
+	Started time.Time `json:"started"`         // When the timer was started
+}
+
+// timerStatePath returns the location of the timer state file.
+func timerStatePath() (string, error) {
+	stateHome, err := getStateDir()
+	if err != nil {
+		return "", fmt.Errorf("could not determine state directory: %w", err)
+	}
+	return filepath.Join(stateHome, ConfigDirName, TimerStateFile), nil
+}
+
+// loadTimerState reads the running timer, returning nil if no timer is running.
+func loadTimerState(statePath string) (*timerState, error) {
+	content, err := os.ReadFile(statePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read timer state: %w", err)
+	}
+
+	var state timerState
+	if err := json.Unmarshal(content, &state); err != nil {
+		return nil, fmt.Errorf("failed to decode timer state %s: %w", statePath, err)
+	}
+	return &state, nil
+}
+
+// readJournalTodos reads a journal file from store and parses its TODOS section.
+func readJournalTodos(store storage.Storage, name string, config *Config) (string, *core.TodoJournal, error) {
+	content, err := readJournalFile(store, name, config)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+	journal, err := journalParser(config).ParseJournal(string(content))
+	if err != nil {
+		return "", nil, withExitCode(ExitParseError, core.WithFile(err, store.Location(name)))
+	}
+	return string(content), journal, nil
+}
+
+// cmdTimerStart starts timing the open task matching task as selected by match in
+// the journal for now. The timer remembers the task it found by its id or whole
+// text, so that stopping it finds the same task.
+func cmdTimerStart(statePath, rootDir, task string, match taskMatch, now time.Time, config *Config, logger *Logger) error {
+	if strings.TrimSpace(task) == "" {
+		return errors.New("task text cannot be empty")
+	}
+
+	running, err := loadTimerState(statePath)
+	if err != nil {
+		return err
+	}
+	if running != nil {
+		return fmt.Errorf("timer already running for %q since %s, stop or cancel it first",
+			running.Task, running.Started.Format("15:04"))
+	}
+
+	store, err := openJournalTree(rootDir, config)
+	if err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+
+	date := effectiveDay(now, config).Format(core.DateFormat)
+	_, journal, err := readJournalTodos(store, resolveJournalName(store, date, config), config)
+	if err != nil {
+		return err
+	}
+	loc, err := findTask(journal, task, match, date)
+	if err != nil {
+		return err
+	}
+
+	state := timerState{Task: loc.Item.Text, Exact: true, Date: date, Started: now}
+	if match.ID {
+		state = timerState{Task: task, ID: true, Date: date, Started: now}
+	}
+	content, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode timer state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(statePath), dirMode()); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := safeWriteFile(statePath, content, fileMode); err != nil {
+		return withExitCode(ExitWriteError, fmt.Errorf("failed to write timer state: %w", err))
+	}
+
+	logger.Info("Timer started for '%s'", loc.Item.Text)
+	return nil
+}
+
+// cmdTimerStop stops the running timer and records the elapsed time as a bullet
+// line under the timed task in the journal the timer was started for.
+func cmdTimerStop(w io.Writer, statePath, rootDir string, now time.Time, config *Config, logger *Logger) error {
+	state, err := loadTimerState(statePath)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		return errors.New("no timer is running")
+	}
+
+	store, err := openJournalTree(rootDir, config)
+	if err != nil {
+		return withExitCode(ExitConfigError, err)
+	}
+
+	journalPath := resolveJournalName(store, state.Date, config)
+	content, journal, err := readJournalTodos(store, journalPath, config)
+	if err != nil {
+		return err
+	}
+	loc, err := timedTask(journal, state)
+	if err != nil {
+		return err
+	}
+
+	elapsed := now.Sub(state.Started)
+	core.AddNote(loc, fmt.Sprintf("⏱ %s (%s-%s)",
+		formatElapsed(elapsed), state.Started.Format("15:04"), now.Format("15:04")))
+
+	updated, err := journalRenderer(config).ReplaceJournal(content, journal)
+	if err != nil {
+		return fmt.Errorf("failed to update journal: %w", err)
+	}
+	if err := writeJournalFile(store, journalPath, []byte(updated), config); err != nil {
+		return withExitCode(ExitWriteError, fmt.Errorf("failed to write journal: %w", err))
+	}
+
+	// The time is recorded; a stale state file would only block the next start
+	if err := os.Remove(statePath); err != nil {
+		logger.Error("Failed to remove timer state %s: %v", statePath, err)
+	}
+
+	fmt.Fprintf(w, "Recorded %s on '%s'\n", formatElapsed(elapsed), loc.Item.Text)
+	return nil
+}
+
+// timedTask returns the task state was started for in journal, open or completed
+// since. A task completed since may carry a date tag. If several tasks match,
+// the first open one is chosen.
+func timedTask(journal *core.TodoJournal, state *timerState) (core.ItemLocation, error) {
+	match := core.MatchTaskID(state.Task, false)
+	if !state.ID {
+		text := core.StripDateTags(state.Task)
+		match = func(item *core.TodoItem) bool { return core.StripDateTags(item.Text) == text }
+	}
+	matches := core.FindItems(journal, match)
+	if len(matches) == 0 {
+		return core.ItemLocation{}, fmt.Errorf("timed task %q is no longer in journal for %s; discard the timer with timer cancel", state.Task, state.Date)
+	}
+	for _, m := range matches {
+		if !m.Item.Completed {
+			return m, nil
+		}
+	}
+	return matches[0], nil
+}
+
+// cmdTimerCancel discards the running timer without recording anything, for
+// timers whose task is gone. A state file that cannot be read is removed too.
+func cmdTimerCancel(w io.Writer, statePath string) error {
+	state, loadErr := loadTimerState(statePath)
+	if state == nil && loadErr == nil {
+		return errors.New("no timer is running")
+	}
+	if err := os.Remove(statePath); err != nil {
+		return withExitCode(ExitWriteError, fmt.Errorf("failed to remove timer state: %w", err))
+	}
+	if loadErr != nil {
+		fmt.Fprintln(w, "Discarded unreadable timer state")
+		return nil
+	}
+	fmt.Fprintf(w, "Discarded timer for '%s' started at %s\n", state.Task, state.Started.Format("15:04"))
+	return nil
+}
+
+// formatElapsed formats a duration rounded to whole minutes, e.g. 25m or 1h05m.
+func formatElapsed(d time.Duration) string {
+	minutes := int(d.Round(time.Minute) / time.Minute)
+	if minutes < 1 {
+		minutes = 1
+	}
+	if minutes < 60 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%dh%02dm", minutes/60, minutes%60)
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/inful/todoer/pkg/core"
+	"github.com/inful/todoer/pkg/storage"
 )
 
 // Validation errors
@@ -104,6 +105,30 @@ func validateProcessArgs(sourceFile, targetFile, templateDate string) error {
 	return nil
 }
 
+// processDays returns the range of day sections process --days, --from and --to
+// select. --days N selects the sections of the N days before templateDate and
+// later ones; it cannot be combined with --from or --to.
+func processDays(days int, from, to, templateDate string) (core.DayRange, error) {
+	if days < 0 {
+		return core.DayRange{}, fmt.Errorf("--days cannot be negative, got %d", days)
+	}
+	if days > 0 {
+		if from != "" || to != "" {
+			return core.DayRange{}, errors.New("--days cannot be combined with --from or --to")
+		}
+		date, err := time.Parse(core.DateFormat, templateDate)
+		if err != nil {
+			return core.DayRange{}, fmt.Errorf("%w: expected format YYYY-MM-DD, got %s", ErrInvalidDate, templateDate)
+		}
+		return core.DayRange{From: date.AddDate(0, 0, -days).Format(core.DateFormat)}, nil
+	}
+	r := core.DayRange{From: from, To: to}
+	if err := r.Validate(); err != nil {
+		return core.DayRange{}, fmt.Errorf("invalid --from/--to: %w", err)
+	}
+	return r, nil
+}
+
 // validateConfig validates the configuration structure
 func validateConfig(config *Config) error {
 	if config == nil {
@@ -114,51 +139,105 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("%w: root directory cannot be empty", ErrInvalidConfig)
 	}
 
-	// Validate root directory path
-	if err := validateFilePath(config.RootDir); err != nil {
-		return fmt.Errorf("invalid root directory '%s': %w", config.RootDir, err)
+	mode, err := parseFileMode(config.FileMode)
+	if err != nil {
+		return err
 	}
-
-	// Check if root directory exists and is accessible
-	if info, err := os.Stat(config.RootDir); err != nil {
-		if os.IsNotExist(err) {
-			// Try to create the directory
-			if err := os.MkdirAll(config.RootDir, 0755); err != nil {
-				return fmt.Errorf("%w: cannot create root directory '%s': %v", ErrInvalidConfig, config.RootDir, err)
-			}
-		} else if os.IsPermission(err) {
-			return fmt.Errorf("%w: cannot access root directory '%s': %v", ErrPermissionDenied, config.RootDir, err)
-		} else {
-			return fmt.Errorf("%w: error accessing root directory '%s': %v", ErrInvalidConfig, config.RootDir, err)
-		}
-	} else if !info.IsDir() {
-		return fmt.Errorf("%w: root path '%s' exists but is not a directory", ErrInvalidConfig, config.RootDir)
+	if err := validateRootDir(config.RootDir, storage.DirMode(mode)); err != nil {
+		return err
 	}
 
 	// Validate template file if specified
 	if config.TemplateFile != "" {
-		if err := validateFilePath(config.TemplateFile); err != nil {
-			return fmt.Errorf("invalid template file '%s': %w", config.TemplateFile, err)
+		if err := validateTemplateRef(config.TemplateFile); err != nil {
+			return err
 		}
+	}
 
-		// Check if template file exists and is readable
-		if info, err := os.Stat(config.TemplateFile); err != nil {
-			if os.IsNotExist(err) {
-				return fmt.Errorf("%w: template file '%s' does not exist", ErrTemplateNotFound, config.TemplateFile)
-			} else if os.IsPermission(err) {
-				return fmt.Errorf("%w: cannot read template file '%s': %v", ErrPermissionDenied, config.TemplateFile, err)
-			} else {
-				return fmt.Errorf("%w: error accessing template file '%s': %v", ErrInvalidConfig, config.TemplateFile, err)
-			}
-		} else if info.IsDir() {
-			return fmt.Errorf("%w: template path '%s' is a directory, not a file", ErrInvalidConfig, config.TemplateFile)
+	if err := validateTemplateRules(config.Templates); err != nil {
+		return err
+	}
+
+	if err := validateOutputConfigs(config.Outputs); err != nil {
+		return err
+	}
+
+	if err := validatePostConfig(config.Post); err != nil {
+		return err
+	}
+
+	if err := validateOpenConfig(config.Open); err != nil {
+		return err
+	}
+
+	if err := journalFormat(config).Validate(); err != nil {
+		return fmt.Errorf("%w: invalid journal format: %v", ErrInvalidConfig, err)
+	}
+
+	if _, err := movedMessage(config, "2006-01-02", journalName("2006-01-02", config)); err != nil {
+		return fmt.Errorf("%w: invalid moved_message: %v", ErrInvalidConfig, err)
+	}
+
+	if config.MaxCarry < 0 {
+		return fmt.Errorf("%w: max_carry cannot be negative, got %d", ErrInvalidConfig, config.MaxCarry)
+	}
+
+	if config.Assignee != "" && !core.ValidAssignee(config.Assignee) {
+		return fmt.Errorf("%w: assignee must be a name such as alice or jane.doe, got %q", ErrInvalidConfig, config.Assignee)
+	}
+
+	if config.Timezone != "" {
+		if _, err := time.LoadLocation(config.Timezone); err != nil {
+			return fmt.Errorf("%w: timezone must be an IANA time zone such as Europe/Oslo, got %q", ErrInvalidConfig, config.Timezone)
 		}
 	}
 
+	if config.DayRolloverHour < 0 || config.DayRolloverHour > 23 {
+		return fmt.Errorf("%w: day_rollover_hour must be between 0 and 23, got %d", ErrInvalidConfig, config.DayRolloverHour)
+	}
+
+	if _, err := dateParser(config); err != nil {
+		return err
+	}
+
+	switch config.Dialect {
+	case "", DialectMarkdown, DialectLogseq, DialectGFM:
+	default:
+		return fmt.Errorf("%w: dialect must be markdown, logseq or gfm, got %q", ErrInvalidConfig, config.Dialect)
+	}
+
+	switch config.DependencyPolicy {
+	case "", DependencyIgnore, DependencyWarn, DependencyRefuse:
+	default:
+		return fmt.Errorf("%w: dependency_policy must be ignore, warn or refuse, got %q", ErrInvalidConfig, config.DependencyPolicy)
+	}
+
+	switch config.Symlinks {
+	case "", SymlinksFollow, SymlinksIgnore:
+	default:
+		return fmt.Errorf("%w: symlinks must be follow or ignore, got %q", ErrInvalidConfig, config.Symlinks)
+	}
+
+	if _, err := retryPolicy(config); err != nil {
+		return err
+	}
+	if _, err := commandTimeout(config.Timeout); err != nil {
+		return err
+	}
+
+	if err := validatePreviousJournal(config); err != nil {
+		return err
+	}
+
 	// Validate custom variables if present
 	if err := validateCustomVariables(config.Custom); err != nil {
 		return fmt.Errorf("invalid custom variables: %w", err)
 	}
+	for _, name := range config.SecretVars {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("%w: secret_vars must not contain empty names", ErrInvalidConfig)
+		}
+	}
 
 	return nil
 }
@@ -239,3 +318,43 @@ func isValidVariableType(value interface{}) bool {
 		return false
 	}
 }
+
+// validateRootDir checks that a local root directory is usable, creating it with
+// the permissions mode if needed. Storage URLs are only checked for a supported
+// scheme; file:// URLs are checked locally.
+func validateRootDir(rootDir string, mode os.FileMode) error {
+	if strings.Contains(rootDir, "://") {
+		store, err := storage.Open(rootDir)
+		if err != nil {
+			return fmt.Errorf("%w: invalid root directory '%s': %v", ErrInvalidConfig, rootDir, err)
+		}
+		local, ok := store.(*storage.Local)
+		if !ok {
+			return nil
+		}
+		rootDir = local.Root
+	}
+
+	// Validate root directory path
+	if err := validateFilePath(rootDir); err != nil {
+		return fmt.Errorf("invalid root directory '%s': %w", rootDir, err)
+	}
+
+	// Check if root directory exists and is accessible
+	if info, err := os.Stat(rootDir); err != nil {
+		if os.IsNotExist(err) {
+			// Try to create the directory
+			if err := os.MkdirAll(rootDir, mode); err != nil {
+				return fmt.Errorf("%w: cannot create root directory '%s': %v", ErrInvalidConfig, rootDir, err)
+			}
+		} else if os.IsPermission(err) {
+			return fmt.Errorf("%w: cannot access root directory '%s': %v", ErrPermissionDenied, rootDir, err)
+		} else {
+			return fmt.Errorf("%w: error accessing root directory '%s': %v", ErrInvalidConfig, rootDir, err)
+		}
+	} else if !info.IsDir() {
+		return fmt.Errorf("%w: root path '%s' exists but is not a directory", ErrInvalidConfig, rootDir)
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"github.com/inful/todoer/pkg/core"
+)
+
+// Build information, set when building releases with
+//
+//	go build -ldflags "-X main.version=v1.4.0 -X main.commit=abc1234 -X main.buildDate=2025-06-21T10:00:00Z"
+//
+// Builds without them fall back to what the Go toolchain records.
+var (
+	version   string
+	commit    string
+	buildDate string
+)
+
+// devVersion is the version of builds that do not know theirs
+const devVersion = "dev"
+
+// versionInfo describes the running todoer build.
+type versionInfo struct {
+	Version  string `json:"version"`
+	Commit   string `json:"commit,omitempty"`
+	Date     string `json:"date,omitempty"`
+	Modified bool   `json:"modified,omitempty"` // Built from a checkout with uncommitted changes
+	Go       string `json:"go"`
+}
+
+// buildVersion returns the information of the running build: the values set
+// with -ldflags, or the module version and VCS stamps recorded by the Go toolchain.
+func buildVersion() versionInfo {
+	info := versionInfo{Version: version, Commit: commit, Date: buildDate, Go: runtime.Version()}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && build.Main.Version != "(devel)" {
+			info.Version = build.Main.Version
+		}
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.Date == "" {
+					info.Date = setting.Value
+				}
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version = devVersion
+	}
+	return info
+}
+
+// cmdVersion writes the build information to w, as JSON if asJSON is set.
+func cmdVersion(w io.Writer, asJSON bool) error {
+	info := buildVersion()
+	if asJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(info)
+	}
+
+	line := "todoer " + info.Version
+	if info.Commit != "" {
+		line += " (" + shortCommit(info.Commit)
+		if info.Modified {
+			line += ", modified"
+		}
+		if info.Date != "" {
+			line += ", " + info.Date
+		}
+		line += ")"
+	}
+	_, err := fmt.Fprintf(w, "%s %s\n", line, info.Go)
+	return err
+}
+
+// shortCommit returns the abbreviated form of a commit hash.
+func shortCommit(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
+
+// formatUpgrade is a change of the journal format made by a release, with the
+// rewrite bringing journals written by earlier releases up to date.
+type formatUpgrade struct {
+	Since   string                               // Release that introduced the format, e.g. v1.5.0
+	Change  string                               // What changed, for doctor and migrate to report
+	Upgrade func(content string) (string, error) // Rewrites the decoded content of an older journal
+}
+
+// formatUpgrades lists the journal format changes, oldest first. Journals stamped
+// by a release before Since are upgraded by doctor --fix and migrate --upgrade;
+// unstamped journals are not touched, as their version is unknown. Add an entry
+// whenever a release writes journals older releases cannot process correctly.
+var formatUpgrades []formatUpgrade
+
+// journalVersion returns the version stamped in the frontmatter of the decoded
+// journal content, or "" if it has none.
+func journalVersion(content string) string {
+	stamped, _ := core.ParseDocument(content).FrontmatterValue(core.VersionKey)
+	return stamped
+}
+
+// pendingUpgrades returns the format upgrades a journal stamped with stamped
+// needs, or nil if it is up to date or its version cannot be compared.
+func pendingUpgrades(stamped string) []formatUpgrade {
+	var pending []formatUpgrade
+	for _, u := range formatUpgrades {
+		if cmp, ok := compareVersions(stamped, u.Since); ok && cmp < 0 {
+			pending = append(pending, u)
+		}
+	}
+	return pending
+}
+
+// upgradeJournal applies the pending format upgrades to the decoded journal
+// content and stamps it with the running version, or the version of the last
+// upgrade for development builds and others not known to be newer. It returns the
+// changes made, none if the journal is up to date.
+func upgradeJournal(content string) (string, []string, error) {
+	pending := pendingUpgrades(journalVersion(content))
+	if len(pending) == 0 {
+		return content, nil, nil
+	}
+
+	var changes []string
+	for _, u := range pending {
+		upgraded, err := u.Upgrade(content)
+		if err != nil {
+			return "", nil, fmt.Errorf("upgrading to the format of %s: %w", u.Since, err)
+		}
+		content = upgraded
+		changes = append(changes, u.Change)
+	}
+
+	stamp := buildVersion().Version
+	last := pending[len(pending)-1].Since
+	if cmp, ok := compareVersions(stamp, last); !ok || cmp < 0 {
+		stamp = last
+	}
+	doc := core.ParseDocument(content)
+	doc.SetFrontmatterValue(core.VersionKey, stamp)
+	return doc.String(), changes, nil
+}
+
+// compareVersions compares the release versions a and b, such as v1.4.0 or
+// 1.4, returning -1, 0 or 1, and whether both could be parsed. Pre-release and
+// build suffixes are ignored.
+func compareVersions(a, b string) (int, bool) {
+	va, ok := parseVersion(a)
+	if !ok {
+		return 0, false
+	}
+	vb, ok := parseVersion(b)
+	if !ok {
+		return 0, false
+	}
+	for i := range va {
+		switch {
+		case va[i] < vb[i]:
+			return -1, true
+		case va[i] > vb[i]:
+			return 1, true
+		}
+	}
+	return 0, true
+}
+
+// parseVersion returns the major, minor and patch number of a release version.
+func parseVersion(v string) ([3]int, bool) {
+	var parts [3]int
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	fields := strings.Split(v, ".")
+	if len(fields) > 3 {
+		return parts, false
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
@@ -6,6 +6,5 @@ require (
 	github.com/BurntSushi/toml v1.5.0
 	github.com/alecthomas/kong v1.13.0
 	github.com/spf13/afero v1.15.0
+	golang.org/x/text v0.28.0
 )
-
-require golang.org/x/text v0.28.0 // indirect
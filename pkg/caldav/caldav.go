@@ -0,0 +1,198 @@
+// Package caldav implements the parts of CalDAV (RFC 4791) todoer needs to keep
+// journal tasks in a task list: finding a calendar and reading and writing VTODOs.
+package caldav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// ErrNotExist is returned (wrapped) when a calendar or todo does not exist.
+var ErrNotExist = fs.ErrNotExist
+
+// propfindBody requests the properties needed to identify calendars
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<propfind xmlns="DAV:"><prop><resourcetype/><displayname/></prop></propfind>`
+
+// Client talks to a CalDAV server.
+type Client struct {
+	base   *url.URL
+	client *http.Client
+}
+
+// NewClient returns a client for the calendar home at base, the collection that
+// contains the user's calendars. Credentials in the URL are sent using basic
+// authentication. A nil client uses http.DefaultClient.
+func NewClient(base *url.URL, client *http.Client) *Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	home := *base
+	if !strings.HasSuffix(home.Path, "/") {
+		home.Path += "/"
+	}
+	return &Client{base: &home, client: client}
+}
+
+// Calendar is a calendar collection on the server.
+type Calendar struct {
+	Name string   // Display name, or the last path segment if the server has none
+	URL  *url.URL // URL of the collection, without credentials
+}
+
+// do sends a request to u
+func (c *Client) do(method string, u *url.URL, body io.Reader, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if user := c.base.User; user != nil {
+		password, _ := user.Password()
+		req.SetBasicAuth(user.Username(), password)
+	}
+	return c.client.Do(req)
+}
+
+// statusError converts an unexpected response into an error
+func statusError(method string, u *url.URL, resp *http.Response) error {
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		return fmt.Errorf("%s %s: %w", method, u.Path, ErrNotExist)
+	}
+	return fmt.Errorf("%s %s: unexpected status %s", method, u.Path, resp.Status)
+}
+
+// multistatus is the body of a PROPFIND response
+type multistatus struct {
+	Responses []struct {
+		Href      string `xml:"href"`
+		Propstats []struct {
+			Status string `xml:"status"`
+			Prop   struct {
+				ResourceType struct {
+					Calendar *struct{} `xml:"urn:ietf:params:xml:ns:caldav calendar"`
+				} `xml:"resourcetype"`
+				DisplayName string `xml:"displayname"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// Calendars lists the calendar collections directly inside the calendar home.
+func (c *Client) Calendars() ([]Calendar, error) {
+	home := *c.base
+	home.User = nil
+
+	header := http.Header{}
+	header.Set("Depth", "1")
+	header.Set("Content-Type", "application/xml; charset=utf-8")
+	resp, err := c.do("PROPFIND", &home, strings.NewReader(propfindBody), header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, statusError("PROPFIND", &home, resp)
+	}
+
+	var status multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, fmt.Errorf("PROPFIND %s: invalid response: %w", home.Path, err)
+	}
+
+	var calendars []Calendar
+	for _, response := range status.Responses {
+		href, err := url.Parse(response.Href)
+		if err != nil {
+			continue
+		}
+		for _, propstat := range response.Propstats {
+			if !strings.Contains(propstat.Status, " 200") || propstat.Prop.ResourceType.Calendar == nil {
+				continue
+			}
+			calendarURL := home.ResolveReference(href)
+			if !strings.HasSuffix(calendarURL.Path, "/") {
+				calendarURL.Path += "/"
+			}
+			name := propstat.Prop.DisplayName
+			if name == "" {
+				name = path.Base(calendarURL.Path)
+			}
+			calendars = append(calendars, Calendar{Name: name, URL: calendarURL})
+		}
+	}
+	return calendars, nil
+}
+
+// FindCalendar returns the calendar whose display name or last path segment is name.
+func (c *Client) FindCalendar(name string) (Calendar, error) {
+	calendars, err := c.Calendars()
+	if err != nil {
+		return Calendar{}, err
+	}
+	for _, calendar := range calendars {
+		if calendar.Name == name || path.Base(calendar.URL.Path) == name {
+			return calendar, nil
+		}
+	}
+	return Calendar{}, fmt.Errorf("calendar %q: %w", name, ErrNotExist)
+}
+
+// todoURL returns the URL of the resource holding the todo with uid
+func todoURL(calendar Calendar, uid string) *url.URL {
+	return calendar.URL.JoinPath(uid + ".ics")
+}
+
+// GetTodo fetches the todo with uid from calendar.
+func (c *Client) GetTodo(calendar Calendar, uid string) (Todo, error) {
+	u := todoURL(calendar, uid)
+	resp, err := c.do(http.MethodGet, u, nil, nil)
+	if err != nil {
+		return Todo{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Todo{}, statusError("GET", u, resp)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Todo{}, err
+	}
+
+	todo, err := ParseTodo(data)
+	if err != nil {
+		return Todo{}, fmt.Errorf("GET %s: %w", u.Path, err)
+	}
+	return todo, nil
+}
+
+// PutTodo creates or replaces todo in calendar.
+func (c *Client) PutTodo(calendar Calendar, todo Todo) error {
+	u := todoURL(calendar, todo.UID)
+	header := http.Header{}
+	header.Set("Content-Type", "text/calendar; charset=utf-8")
+
+	resp, err := c.do(http.MethodPut, u, bytes.NewReader(todo.Marshal()), header)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+		return nil
+	default:
+		return statusError("PUT", u, resp)
+	}
+}
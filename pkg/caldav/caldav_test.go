@@ -0,0 +1,197 @@
+package caldav
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTodoMarshalRoundTrip(t *testing.T) {
+	stamp := time.Date(2025, 6, 21, 9, 30, 0, 0, time.UTC)
+	todo := Todo{
+		UID:         "abc123@todoer",
+		Summary:     "Call Alice; ask about the report, budget\\numbers and " + strings.Repeat("ü", 40),
+		Due:         "2025-06-21",
+		Completed:   true,
+		CompletedAt: stamp,
+		Stamp:       stamp,
+	}
+
+	data := todo.Marshal()
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\r\n"), "\r\n") {
+		if len(line) > maxLineLength {
+			t.Errorf("line longer than %d octets: %q", maxLineLength, line)
+		}
+	}
+	if !strings.Contains(string(data), "SUMMARY:Call Alice\\; ask about the report\\, budget\\\\numbers") {
+		t.Errorf("SUMMARY not escaped:\n%s", data)
+	}
+
+	got, err := ParseTodo(data)
+	if err != nil {
+		t.Fatalf("ParseTodo() error: %v", err)
+	}
+	if got != todo {
+		t.Errorf("ParseTodo() = %+v, want %+v", got, todo)
+	}
+}
+
+func TestParseTodo(t *testing.T) {
+	data := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VTIMEZONE\r\nTZID:Europe/Oslo\r\nEND:VTIMEZONE\r\n" +
+		"BEGIN:VTODO\r\nUID:remote-1\r\nSUMMARY:Written on the\r\n  phone\r\nDUE;TZID=Europe/Oslo:20250622T170000\r\n" +
+		"STATUS:NEEDS-ACTION\r\nCOMPLETED:20250622T180000Z\r\nEND:VTODO\r\nEND:VCALENDAR\r\n"
+
+	got, err := ParseTodo([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseTodo() error: %v", err)
+	}
+	want := Todo{
+		UID:         "remote-1",
+		Summary:     "Written on the phone",
+		Due:         "2025-06-22",
+		Completed:   true,
+		CompletedAt: time.Date(2025, 6, 22, 18, 0, 0, 0, time.UTC),
+	}
+	if got != want {
+		t.Errorf("ParseTodo() = %+v, want %+v", got, want)
+	}
+
+	if _, err := ParseTodo([]byte("BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n")); err == nil {
+		t.Error("ParseTodo() without VTODO should fail")
+	}
+	if _, err := ParseTodo([]byte("BEGIN:VTODO\r\nSUMMARY:x\r\nEND:VTODO\r\n")); err == nil {
+		t.Error("ParseTodo() without UID should fail")
+	}
+}
+
+func TestClient(t *testing.T) {
+	server := httptest.NewServer(newFakeCalDAV("user", "secret", "tasks", "Tasks"))
+	defer server.Close()
+
+	home, err := url.Parse(server.URL + "/calendars/user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewClient(home, server.Client()).Calendars(); err == nil {
+		t.Error("Calendars() without credentials should fail")
+	}
+
+	home.User = url.UserPassword("user", "secret")
+	client := NewClient(home, server.Client())
+
+	calendars, err := client.Calendars()
+	if err != nil {
+		t.Fatalf("Calendars() error: %v", err)
+	}
+	if len(calendars) != 1 || calendars[0].Name != "Tasks" || calendars[0].URL.String() != server.URL+"/calendars/user/tasks/" {
+		t.Errorf("Calendars() = %+v", calendars)
+	}
+
+	for _, name := range []string{"Tasks", "tasks"} {
+		if _, err := client.FindCalendar(name); err != nil {
+			t.Errorf("FindCalendar(%q) error: %v", name, err)
+		}
+	}
+	if _, err := client.FindCalendar("Work"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("FindCalendar() of missing calendar error = %v, want ErrNotExist", err)
+	}
+
+	calendar := calendars[0]
+	if _, err := client.GetTodo(calendar, "missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("GetTodo() of missing todo error = %v, want ErrNotExist", err)
+	}
+
+	todo := Todo{UID: "abc@todoer", Summary: "Write report", Due: "2025-06-21", Stamp: time.Now().UTC().Truncate(time.Second)}
+	if err := client.PutTodo(calendar, todo); err != nil {
+		t.Fatalf("PutTodo() error: %v", err)
+	}
+	todo.Completed = true
+	if err := client.PutTodo(calendar, todo); err != nil {
+		t.Fatalf("PutTodo() update error: %v", err)
+	}
+
+	got, err := client.GetTodo(calendar, "abc@todoer")
+	if err != nil {
+		t.Fatalf("GetTodo() error: %v", err)
+	}
+	if got != todo {
+		t.Errorf("GetTodo() = %+v, want %+v", got, todo)
+	}
+}
+
+// fakeCalDAV is a minimal in-memory CalDAV server with one calendar below
+// /calendars/<user>/ for tests
+type fakeCalDAV struct {
+	mu          sync.Mutex
+	user        string
+	password    string
+	calendar    string
+	displayName string
+	objects     map[string][]byte
+}
+
+func newFakeCalDAV(user, password, calendar, displayName string) *fakeCalDAV {
+	return &fakeCalDAV{
+		user:        user,
+		password:    password,
+		calendar:    calendar,
+		displayName: displayName,
+		objects:     map[string][]byte{},
+	}
+}
+
+func (d *fakeCalDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, password, ok := r.BasicAuth(); !ok || user != d.user || password != d.password {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	home := "/calendars/" + d.user + "/"
+	calendarPath := home + d.calendar + "/"
+
+	switch {
+	case r.Method == "PROPFIND" && r.URL.Path == home:
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>
+<d:multistatus xmlns:d="DAV:" xmlns:cal="urn:ietf:params:xml:ns:caldav">
+<d:response><d:href>%s</d:href><d:propstat><d:prop><d:resourcetype><d:collection/></d:resourcetype></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>
+<d:response><d:href>%s</d:href><d:propstat><d:prop><d:resourcetype><d:collection/><cal:calendar/></d:resourcetype><d:displayname>%s</d:displayname></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>
+</d:multistatus>`, home, calendarPath, d.displayName)
+	case strings.HasPrefix(r.URL.Path, calendarPath) && r.Method == http.MethodGet:
+		data, ok := d.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		_, _ = w.Write(data)
+	case strings.HasPrefix(r.URL.Path, calendarPath) && r.Method == http.MethodPut:
+		if !strings.HasPrefix(r.Header.Get("Content-Type"), "text/calendar") {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		_, existed := d.objects[r.URL.Path]
+		d.objects[r.URL.Path] = data
+		if existed {
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			w.WriteHeader(http.StatusCreated)
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
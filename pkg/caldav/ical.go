@@ -0,0 +1,182 @@
+package caldav
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Date and time formats used in iCalendar properties
+const (
+	icalDate     = "20060102"
+	icalDateTime = "20060102T150405Z"
+)
+
+// maxLineLength is the length in octets after which iCalendar lines are folded
+const maxLineLength = 75
+
+// Todo is a task stored as a VTODO component.
+type Todo struct {
+	UID         string    // Unique identifier of the task
+	Summary     string    // Task text
+	Due         string    // Due date in YYYY-MM-DD format, empty if none
+	Completed   bool      // Whether the task is completed
+	CompletedAt time.Time // When the task was completed, zero if unknown
+	Stamp       time.Time // When the component was written
+}
+
+// Marshal encodes the todo as an iCalendar object containing a single VTODO.
+func (t Todo) Marshal() []byte {
+	var buf bytes.Buffer
+	write := func(line string) {
+		writeFolded(&buf, line)
+	}
+
+	write("BEGIN:VCALENDAR")
+	write("VERSION:2.0")
+	write("PRODID:-//todoer//todoer//EN")
+	write("BEGIN:VTODO")
+	write("UID:" + escapeText(t.UID))
+	write("DTSTAMP:" + t.Stamp.UTC().Format(icalDateTime))
+	write("SUMMARY:" + escapeText(t.Summary))
+	if due, err := time.Parse("2006-01-02", t.Due); err == nil {
+		write("DUE;VALUE=DATE:" + due.Format(icalDate))
+	}
+	if t.Completed {
+		write("STATUS:COMPLETED")
+		if !t.CompletedAt.IsZero() {
+			write("COMPLETED:" + t.CompletedAt.UTC().Format(icalDateTime))
+		}
+	} else {
+		write("STATUS:NEEDS-ACTION")
+	}
+	write("END:VTODO")
+	write("END:VCALENDAR")
+
+	return buf.Bytes()
+}
+
+// ParseTodo decodes the first VTODO of an iCalendar object.
+// A todo counts as completed if its STATUS is COMPLETED or it has a COMPLETED time.
+func ParseTodo(data []byte) (Todo, error) {
+	var todo Todo
+	found, inTodo := false, false
+
+	for _, line := range unfold(data) {
+		name, value := splitProperty(line)
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VTODO") && !found:
+			inTodo, found = true, true
+		case name == "END" && strings.EqualFold(value, "VTODO"):
+			inTodo = false
+		case !inTodo:
+		case name == "UID":
+			todo.UID = unescapeText(value)
+		case name == "SUMMARY":
+			todo.Summary = unescapeText(value)
+		case name == "STATUS":
+			if strings.EqualFold(value, "COMPLETED") {
+				todo.Completed = true
+			}
+		case name == "COMPLETED":
+			todo.Completed = true
+			if completedAt, err := time.Parse(icalDateTime, value); err == nil {
+				todo.CompletedAt = completedAt
+			}
+		case name == "DTSTAMP":
+			if stamp, err := time.Parse(icalDateTime, value); err == nil {
+				todo.Stamp = stamp
+			}
+		case name == "DUE":
+			// DUE may be a date or a date-time; only the date is kept
+			if len(value) >= len(icalDate) {
+				if due, err := time.Parse(icalDate, value[:len(icalDate)]); err == nil {
+					todo.Due = due.Format("2006-01-02")
+				}
+			}
+		}
+	}
+
+	if !found {
+		return Todo{}, errors.New("no VTODO component found")
+	}
+	if todo.UID == "" {
+		return Todo{}, errors.New("VTODO has no UID")
+	}
+	return todo, nil
+}
+
+// unfold splits iCalendar content into logical lines, joining folded continuation lines.
+func unfold(data []byte) []string {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// splitProperty splits a content line into its upper-cased name and its value,
+// dropping any parameters.
+func splitProperty(line string) (name, value string) {
+	colon := strings.Index(line, ":")
+	if colon == -1 {
+		return strings.ToUpper(line), ""
+	}
+	name, value = line[:colon], line[colon+1:]
+	if semicolon := strings.Index(name, ";"); semicolon != -1 {
+		name = name[:semicolon]
+	}
+	return strings.ToUpper(name), value
+}
+
+// writeFolded writes a content line, folding it at maxLineLength octets
+// without splitting UTF-8 sequences.
+func writeFolded(buf *bytes.Buffer, line string) {
+	limit := maxLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		buf.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space that counts towards the limit
+		limit = maxLineLength - 1
+	}
+	fmt.Fprintf(buf, "%s\r\n", line)
+}
+
+// escapeText escapes a TEXT property value.
+func escapeText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
+
+// unescapeText reverses escapeText.
+func unescapeText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			if s[i] == 'n' || s[i] == 'N' {
+				b.WriteByte('\n')
+			} else {
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
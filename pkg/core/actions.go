@@ -0,0 +1,148 @@
+// Package core provides next action selection for the todoer application.
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// NextAction is an open top-level task that can be worked on.
+type NextAction struct {
+	Text     string // Task text
+	Date     string // Date the task was written down, from its day section or carried-from annotation
+	Priority string // Priority marker such as "A" for [#A], empty if the task has none
+}
+
+// String returns the task text, so templates can print a NextAction directly.
+func (a NextAction) String() string {
+	return a.Text
+}
+
+// RankNextActions returns the open top-level tasks of journal that are not blocked by
+// another open task, see IsBlocked. Tasks with a priority marker come first, [#A]
+// before [#B], then the oldest by their date, then in document order.
+func RankNextActions(journal *TodoJournal) []NextAction {
+	var actions []NextAction
+	if journal == nil {
+		return actions
+	}
+
+	_, open := SplitJournal(journal)
+	ready, _ := SplitBlocked(open)
+	for _, day := range ready.Days {
+		for _, item := range day.Items {
+			action := NextAction{Text: item.Text, Date: day.Date}
+			if m := PriorityRegex.FindStringSubmatch(item.Text); m != nil {
+				action.Priority = strings.ToUpper(m[1])
+			}
+			if from := ExtractCarriedFrom(item.Text); from != "" {
+				action.Date = from
+			}
+			actions = append(actions, action)
+		}
+	}
+
+	sort.SliceStable(actions, func(i, j int) bool {
+		a, b := actions[i], actions[j]
+		if (a.Priority == "") != (b.Priority == "") {
+			return a.Priority != ""
+		}
+		if a.Priority != b.Priority {
+			return a.Priority < b.Priority
+		}
+		return a.Date < b.Date
+	})
+	return actions
+}
+
+// NextActions returns the first n of the ranked open tasks in TopTodos, for
+// templates that want a short focused list, e.g. {{range .NextActions 3}}.
+func (d TemplateData) NextActions(n int) []NextAction {
+	if n < 0 {
+		n = 0
+	}
+	if n > len(d.TopTodos) {
+		n = len(d.TopTodos)
+	}
+	return d.TopTodos[:n]
+}
+
+// NextActionList is a list of tasks that templates can range over, or print as
+// open checkboxes, one per line.
+type NextActionList []NextAction
+
+// String returns the tasks as "- [ ] " lines.
+func (l NextActionList) String() string {
+	lines := make([]string, len(l))
+	for i, action := range l {
+		lines[i] = "- [ ] " + action.Text
+	}
+	return strings.Join(lines, "\n")
+}
+
+// nextActionSortKeys give the value of each sort key of SortNextActions for a
+// task, compared in ascending order, and whether the task has one.
+var nextActionSortKeys = map[string]func(NextAction) (string, bool){
+	"priority": func(a NextAction) (string, bool) { return a.Priority, a.Priority != "" },
+	"date":     func(a NextAction) (string, bool) { return a.Date, a.Date != "" },
+	"text":     func(a NextAction) (string, bool) { return strings.ToLower(a.Text), true },
+	"tag": func(a NextAction) (string, bool) {
+		tags := ExtractTags(a.Text)
+		return strings.Join(tags, " "), len(tags) > 0
+	},
+	"assignee": func(a NextAction) (string, bool) {
+		names := ExtractAssignees(a.Text)
+		return strings.Join(names, " "), len(names) > 0
+	},
+}
+
+// SortNextActions returns a sorted copy of actions. keys is a comma-separated
+// list of sort keys, each compared when the ones before are equal:
+//
+//   - priority: [#A] before [#B]
+//   - age: the newest first, by their date; -age puts the oldest first
+//   - date: the oldest first
+//   - tag and assignee: by their tags or assignees, alphabetically
+//   - text: alphabetically, ignoring case
+//
+// A leading '-' reverses a key. Tasks without a priority, date, tag or assignee
+// come last either way, and tasks equal in every key keep their order. For
+// example "priority,-age" is the order of RankNextActions.
+func SortNextActions(actions []NextAction, keys string) (NextActionList, error) {
+	type sortKey struct {
+		value   func(NextAction) (string, bool)
+		reverse bool
+	}
+	var order []sortKey
+	for _, field := range strings.Split(keys, ",") {
+		field = strings.TrimSpace(field)
+		reverse := strings.HasPrefix(field, "-")
+		name := strings.TrimPrefix(field, "-")
+		if name == "age" {
+			name, reverse = "date", !reverse
+		}
+		value, ok := nextActionSortKeys[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown sort key %q, want priority, age, date, tag, assignee or text", field)
+		}
+		order = append(order, sortKey{value: value, reverse: reverse})
+	}
+
+	sorted := append(NextActionList(nil), actions...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		for _, key := range order {
+			a, hasA := key.value(sorted[i])
+			b, hasB := key.value(sorted[j])
+			if hasA != hasB {
+				return hasA
+			}
+			if a == b {
+				continue
+			}
+			return (a < b) != key.reverse
+		}
+		return false
+	})
+	return sorted, nil
+}
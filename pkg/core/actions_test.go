@@ -0,0 +1,124 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRankNextActions(t *testing.T) {
+	journal, err := ParseTodosSection(`- [[2025-06-18]]
+  - [ ] Old task
+  - [ ] Deploy blocked-by::[[build]]
+  - [x] Done task
+- [[2025-06-20]]
+  - [ ] Build id::build [#B]
+  - [ ] Urgent [#A]
+    - [ ] Subtask
+  - [ ] Carried (from [[2025-06-10]])
+  - [ ] New task`)
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error: %v", err)
+	}
+
+	got := RankNextActions(journal)
+	want := []NextAction{
+		{Text: "Urgent [#A]", Date: "2025-06-20", Priority: "A"},
+		{Text: "Build id::build [#B]", Date: "2025-06-20", Priority: "B"},
+		{Text: "Carried (from [[2025-06-10]])", Date: "2025-06-10"},
+		{Text: "Old task", Date: "2025-06-18"},
+		{Text: "New task", Date: "2025-06-20"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RankNextActions() = %+v, want %+v", got, want)
+	}
+}
+
+func TestNextActionsTemplate(t *testing.T) {
+	journal, err := ParseTodosSection(`- [[2025-06-20]]
+  - [ ] Write report
+  - [ ] Fix bug [#A]
+  - [ ] Call back`)
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error: %v", err)
+	}
+
+	got, err := CreateFromTemplate(TemplateOptions{
+		Content:     "{{range .NextActions 2}}- {{.}}\n{{end}}{{(index .TopTodos 0).Priority}} {{range .NextActions 10}}.{{end}}",
+		CurrentDate: "2025-06-21",
+		Journal:     journal,
+	})
+	if err != nil {
+		t.Fatalf("CreateFromTemplate() error: %v", err)
+	}
+	if want := "- Fix bug [#A]\n- Write report\nA ..."; got != want {
+		t.Errorf("CreateFromTemplate() = %q, want %q", got, want)
+	}
+}
+
+func TestSortNextActions(t *testing.T) {
+	actions := []NextAction{
+		{Text: "Write docs #docs", Date: "2025-06-18"},
+		{Text: "Fix bug #code @bob", Date: "2025-06-20", Priority: "B"},
+		{Text: "Review PR #code @alice", Date: "2025-06-19"},
+		{Text: "Ship release", Date: "2025-06-17", Priority: "A"},
+	}
+	texts := func(l NextActionList) []string {
+		var got []string
+		for _, a := range l {
+			got = append(got, a.Text)
+		}
+		return got
+	}
+
+	tests := []struct {
+		keys string
+		want []string
+	}{
+		{"priority,-age", []string{"Ship release", "Fix bug #code @bob", "Write docs #docs", "Review PR #code @alice"}},
+		{"age", []string{"Fix bug #code @bob", "Review PR #code @alice", "Write docs #docs", "Ship release"}},
+		{"tag, -date", []string{"Fix bug #code @bob", "Review PR #code @alice", "Write docs #docs", "Ship release"}},
+		{"-tag", []string{"Write docs #docs", "Fix bug #code @bob", "Review PR #code @alice", "Ship release"}},
+		{"assignee,text", []string{"Review PR #code @alice", "Fix bug #code @bob", "Ship release", "Write docs #docs"}},
+	}
+	for _, tt := range tests {
+		got, err := SortNextActions(actions, tt.keys)
+		if err != nil {
+			t.Fatalf("SortNextActions(%q) error: %v", tt.keys, err)
+		}
+		if !reflect.DeepEqual(texts(got), tt.want) {
+			t.Errorf("SortNextActions(%q) = %q, want %q", tt.keys, texts(got), tt.want)
+		}
+	}
+	if actions[0].Text != "Write docs #docs" {
+		t.Error("SortNextActions() reordered its input")
+	}
+	if _, err := SortNextActions(actions, "priority,size"); err == nil {
+		t.Error("SortNextActions() with an unknown key expected error")
+	}
+}
+
+func TestSortTodosTemplate(t *testing.T) {
+	journal, err := ParseTodosSection(`- [[2025-06-20]]
+  - [ ] Write report #work
+  - [ ] Fix bug [#A]
+  - [ ] Call back #home`)
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error: %v", err)
+	}
+
+	got, err := CreateFromTemplate(TemplateOptions{
+		Content:     `{{sortTodos .TopTodos "tag,priority"}}|{{range sortTodos (.NextActions 2) "-text"}}{{.Text}};{{end}}`,
+		CurrentDate: "2025-06-21",
+		Journal:     journal,
+	})
+	if err != nil {
+		t.Fatalf("CreateFromTemplate() error: %v", err)
+	}
+	if want := "- [ ] Call back #home\n- [ ] Write report #work\n- [ ] Fix bug [#A]|Write report #work;Fix bug [#A];"; got != want {
+		t.Errorf("CreateFromTemplate() = %q, want %q", got, want)
+	}
+
+	if _, err := CreateFromTemplate(TemplateOptions{Content: `{{sortTodos .TopTodos "size"}}`, CurrentDate: "2025-06-21", Journal: journal}); err == nil {
+		t.Error("CreateFromTemplate() with an unknown sort key expected error")
+	}
+}
@@ -0,0 +1,131 @@
+// Package core provides overdue and stale task detection for the todoer application.
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// DueRegex matches a due date annotation such as due::[[2025-06-30]]
+//
+// Deprecated: due dates are parsed with the other annotations, see ParseAnnotations.
+var DueRegex = regexp.MustCompile(`due::\[\[(\d{4}-\d{2}-\d{2})\]\]`)
+
+// Kinds of task alerts
+const (
+	AlertOverdue = "overdue" // The task's due date has passed
+	AlertStale   = "stale"   // The task has been open for too long
+)
+
+// TaskAlert is an open task that needs attention.
+type TaskAlert struct {
+	Text string // Task text
+	Kind string // AlertOverdue or AlertStale
+	Date string // Due date of overdue tasks, date of the day section of stale tasks
+	Days int    // Days since Date
+}
+
+// String returns the task text, so templates can print a TaskAlert directly.
+func (a TaskAlert) String() string {
+	return a.Text
+}
+
+// ExtractDue returns the due date a todo text is annotated with, as due::[[2025-06-30]]
+// or 📅 2025-06-30, or an empty string.
+func ExtractDue(text string) string {
+	due := annotationValue(text, "due")
+	if _, err := time.Parse(DateFormat, due); err != nil {
+		return ""
+	}
+	return due
+}
+
+// FindAlerts returns the open tasks of journal that are overdue or stale on today.
+//
+// A task at any depth is overdue when its due:: date lies more than overdueDays days
+// before today. A top-level task is stale when its day section is at least staleDays
+// days old, or its carried-from annotation that many days; undated tasks count from
+// undatedDate. A staleDays of zero or less disables
+// stale alerts. Tasks that are overdue are not reported as stale as well. Alerts are
+// sorted with the most overdue first, followed by the stalest.
+func FindAlerts(journal *TodoJournal, today string, overdueDays, staleDays int, undatedDate string) ([]TaskAlert, error) {
+	todayTime, err := time.Parse(DateFormat, today)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q: %w", today, err)
+	}
+	daysSince := func(date string) (int, bool) {
+		t, err := time.Parse(DateFormat, date)
+		if err != nil {
+			return 0, false
+		}
+		return int(todayTime.Sub(t).Hours() / 24), true
+	}
+
+	var overdue, stale []TaskAlert
+	for _, loc := range FindItems(journal, MatchOpen) {
+		if due := ExtractDue(loc.Item.Text); due != "" {
+			if days, ok := daysSince(due); ok && days > overdueDays {
+				overdue = append(overdue, TaskAlert{Text: loc.Item.Text, Kind: AlertOverdue, Date: due, Days: days})
+				continue
+			}
+		}
+
+		if staleDays <= 0 || loc.Parent != nil {
+			continue
+		}
+		date := openedDate(loc, undatedDate)
+		if days, ok := daysSince(date); ok && days >= staleDays {
+			stale = append(stale, TaskAlert{Text: loc.Item.Text, Kind: AlertStale, Date: date, Days: days})
+		}
+	}
+
+	for _, alerts := range [][]TaskAlert{overdue, stale} {
+		sort.SliceStable(alerts, func(i, j int) bool {
+			return alerts[i].Days > alerts[j].Days
+		})
+	}
+	return append(overdue, stale...), nil
+}
+
+// openedDate returns the date the task at loc was opened on: the date it was carried
+// from, else the date of its day section, else undatedDate.
+func openedDate(loc ItemLocation, undatedDate string) string {
+	if from := ExtractCarriedFrom(loc.Item.Text); from != "" {
+		return from
+	}
+	if loc.Day.Date != "" {
+		return loc.Day.Date
+	}
+	return undatedDate
+}
+
+// FilterStale returns the open top-level tasks of journal opened at least staleDays
+// days before today, with their days. A task counts from the date of its day section
+// or its carried-from annotation, undated tasks from undatedDate. Unlike FindAlerts,
+// overdue tasks are included. The returned journal shares its items with journal.
+func FilterStale(journal *TodoJournal, today string, staleDays int, undatedDate string) (*TodoJournal, error) {
+	todayTime, err := time.Parse(DateFormat, today)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q: %w", today, err)
+	}
+
+	stale := &TodoJournal{Days: []*DaySection{}}
+	var day *DaySection
+	for _, loc := range FindItems(journal, MatchOpen) {
+		if loc.Parent != nil {
+			continue
+		}
+		t, err := time.Parse(DateFormat, openedDate(loc, undatedDate))
+		if err != nil || int(todayTime.Sub(t).Hours()/24) < staleDays {
+			continue
+		}
+		if day == nil || day.Date != loc.Day.Date {
+			day = &DaySection{Date: loc.Day.Date, Items: []*TodoItem{}}
+			stale.Days = append(stale.Days, day)
+		}
+		day.Items = append(day.Items, loc.Item)
+	}
+	return stale, nil
+}
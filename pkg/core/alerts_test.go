@@ -0,0 +1,102 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractDue(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Send invoice due::[[2025-06-30]]", "2025-06-30"},
+		{"due::[[2025-06-30]] and due::[[2025-07-01]]", "2025-06-30"},
+		{"Link to [[2025-06-30]] without due", ""},
+		{"due::[[2025-13-40]] invalid", ""},
+		{"Plain task", ""},
+	}
+
+	for _, tt := range tests {
+		if got := ExtractDue(tt.text); got != tt.want {
+			t.Errorf("ExtractDue(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestFindAlerts(t *testing.T) {
+	journal, err := ParseTodosSection(`- [ ] Undated idea
+- [[2025-06-01]]
+  - [ ] Renew passport
+  - [ ] Pay rent due::[[2025-06-18]]
+  - [x] Done long ago due::[[2025-06-01]]
+- [[2025-06-15]]
+  - [ ] Write report
+    - [ ] Send draft due::[[2025-06-19]]
+    - [ ] Old subtask
+  - [ ] Due today due::[[2025-06-20]]
+  - [ ] Carried chore (from [[2025-06-02]])`)
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error: %v", err)
+	}
+
+	got, err := FindAlerts(journal, "2025-06-20", 0, 14, "2025-06-05")
+	if err != nil {
+		t.Fatalf("FindAlerts() error: %v", err)
+	}
+	want := []TaskAlert{
+		{Text: "Pay rent due::[[2025-06-18]]", Kind: AlertOverdue, Date: "2025-06-18", Days: 2},
+		{Text: "Send draft due::[[2025-06-19]]", Kind: AlertOverdue, Date: "2025-06-19", Days: 1},
+		{Text: "Renew passport", Kind: AlertStale, Date: "2025-06-01", Days: 19},
+		{Text: "Carried chore (from [[2025-06-02]])", Kind: AlertStale, Date: "2025-06-02", Days: 18},
+		{Text: "Undated idea", Kind: AlertStale, Date: "2025-06-05", Days: 15},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindAlerts() = %+v, want %+v", got, want)
+	}
+
+	// A grace period of one day and no stale alerts
+	got, err = FindAlerts(journal, "2025-06-20", 1, 0, "2025-06-05")
+	if err != nil {
+		t.Fatalf("FindAlerts() error: %v", err)
+	}
+	if len(got) != 1 || got[0].Text != "Pay rent due::[[2025-06-18]]" {
+		t.Errorf("FindAlerts() with grace period = %+v", got)
+	}
+
+	if _, err := FindAlerts(journal, "not-a-date", 0, 14, ""); err == nil {
+		t.Error("FindAlerts() with invalid date should fail")
+	}
+}
+
+func TestFilterStale(t *testing.T) {
+	journal, err := ParseTodosSection(`- [ ] Undated idea
+- [[2025-06-01]]
+  - [ ] Renew passport
+    - [ ] Book appointment
+  - [x] Done long ago
+- [[2025-06-15]]
+  - [ ] Write report
+  - [ ] Carried chore (from [[2025-06-02]])`)
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error: %v", err)
+	}
+
+	got, err := FilterStale(journal, "2025-06-20", 14, "2025-06-05")
+	if err != nil {
+		t.Fatalf("FilterStale() error: %v", err)
+	}
+	want := `- [ ] Undated idea
+- [[2025-06-01]]
+  - [ ] Renew passport
+    - [ ] Book appointment
+- [[2025-06-15]]
+  - [ ] Carried chore (from [[2025-06-02]])`
+	if s := JournalToString(got); s != want {
+		t.Errorf("FilterStale() =\n%s\nwant\n%s", s, want)
+	}
+
+	if _, err := FilterStale(journal, "not-a-date", 14, ""); err == nil {
+		t.Error("FilterStale() with invalid date should fail")
+	}
+}
@@ -0,0 +1,202 @@
+// Package core provides the Parser and Renderer of TODOS sections for the todoer application.
+package core
+
+import (
+	"context"
+)
+
+// Option configures a Parser or a Renderer, see NewParser and NewRenderer.
+type Option func(*options)
+
+// options holds the settings shared by parsers and renderers
+type options struct {
+	header string // Header line of the TODOS section
+	format Format // Layout of the TODOS section
+	arena  *Arena // Shares the memory of parsed journals, nil for none
+}
+
+// newOptions returns the settings of opts, starting from TodosHeader and DefaultFormat.
+func newOptions(opts []Option) options {
+	o := options{header: TodosHeader, format: DefaultFormat}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithHeader sets the header line of the TODOS section, e.g. "## Tasks".
+// An empty header keeps TodosHeader.
+func WithHeader(header string) Option {
+	return func(o *options) {
+		if header != "" {
+			o.header = header
+		}
+	}
+}
+
+// WithFormat sets the whole layout of the TODOS section. Options given after it
+// change single settings of format.
+func WithFormat(format Format) Option {
+	return func(o *options) {
+		o.format = format
+	}
+}
+
+// WithIndent sets the spaces per indentation level, see Format.IndentSpaces.
+func WithIndent(spaces int) Option {
+	return func(o *options) {
+		o.format.IndentSpaces = spaces
+	}
+}
+
+// WithTabs indents with one tab per level instead of spaces, see Format.UseTabs.
+func WithTabs(useTabs bool) Option {
+	return func(o *options) {
+		o.format.UseTabs = useTabs
+	}
+}
+
+// WithDayHeader sets the time layout of day header lines, see Format.DayHeader.
+func WithDayHeader(layout string) Option {
+	return func(o *options) {
+		o.format.DayHeader = layout
+	}
+}
+
+// WithCheckboxStates sets the checkbox characters of open and of completed todos,
+// e.g. " /" and "xX". The first character of each is written; the others are read.
+func WithCheckboxStates(open, done string) Option {
+	return func(o *options) {
+		o.format.OpenMarkers = open
+		o.format.DoneMarkers = done
+	}
+}
+
+// WithKeywords marks todos with TODO and DONE keywords instead of checkboxes, see
+// Format.Keywords.
+func WithKeywords(keywords bool) Option {
+	return func(o *options) {
+		o.format.Keywords = keywords
+	}
+}
+
+// WithGFM also reads notes with * and + bullets, see Format.GFM. Todos are read
+// in the task list variations of GitHub either way, and written with the first
+// checkbox characters as before.
+func WithGFM(gfm bool) Option {
+	return func(o *options) {
+		o.format.GFM = gfm
+	}
+}
+
+// WithDateTag sets the time layout of completion date tags, e.g. "✅ 2006-01-02",
+// see Format.DateTag.
+func WithDateTag(layout string) Option {
+	return func(o *options) {
+		o.format.DateTag = layout
+	}
+}
+
+// WithCarryMarker sets the layout of the carry count of todos, e.g. "↪×%d",
+// see Format.CarryMarker.
+func WithCarryMarker(layout string) Option {
+	return func(o *options) {
+		o.format.CarryMarker = layout
+	}
+}
+
+// WithLimits sets the bounds of the input accepted when parsing, see Format.Limits.
+func WithLimits(limits Limits) Option {
+	return func(o *options) {
+		o.format.Limits = limits
+	}
+}
+
+// WithArena makes a Parser share the memory of the journals it parses through
+// arena, see Arena. Renderers ignore it. A nil arena allocates every journal
+// on its own, as parsers do by default.
+func WithArena(arena *Arena) Option {
+	return func(o *options) {
+		o.arena = arena
+	}
+}
+
+// Parser reads TODOS sections in the layout it was created with, so that the
+// header and format are given once instead of to every parsing function.
+type Parser struct {
+	options
+}
+
+// NewParser creates a Parser for TODOS sections configured by opts. Invalid
+// settings are reported by the parsing methods, or up front by Validate.
+func NewParser(opts ...Option) *Parser {
+	return &Parser{options: newOptions(opts)}
+}
+
+// Header returns the header line of the TODOS sections the parser reads.
+func (p *Parser) Header() string {
+	return p.header
+}
+
+// Format returns the layout of the TODOS sections the parser reads.
+func (p *Parser) Format() Format {
+	return p.format
+}
+
+// Validate checks the layout of the parser.
+func (p *Parser) Validate() error {
+	return p.format.Validate()
+}
+
+// Parse parses the body of a TODOS section and also returns the problems that
+// did not stop parsing, like ParseTodosSectionWarnings.
+func (p *Parser) Parse(ctx context.Context, section string) (*TodoJournal, []Warning, error) {
+	return parseTodosSection(ctx, section, p.format, p.arena)
+}
+
+// ParseJournal extracts the TODOS section of a journal file and parses it, like
+// ParseTodosSectionFromContentFormat.
+func (p *Parser) ParseJournal(content string) (*TodoJournal, error) {
+	if err := p.format.Limits.CheckFileSize(len(content)); err != nil {
+		return nil, err
+	}
+	return parseJournal(content, p.header, p.format, p.arena)
+}
+
+// Renderer writes TODOS sections in the layout it was created with. Journals
+// parsed by a Parser with the same options are written back unchanged.
+type Renderer struct {
+	options
+}
+
+// NewRenderer creates a Renderer for TODOS sections configured by opts. An invalid
+// day header layout is written as DefaultDayHeader, like JournalToStringFormat does.
+func NewRenderer(opts ...Option) *Renderer {
+	return &Renderer{options: newOptions(opts)}
+}
+
+// Header returns the header line of the TODOS sections the renderer writes.
+func (r *Renderer) Header() string {
+	return r.header
+}
+
+// Format returns the layout of the TODOS sections the renderer writes.
+func (r *Renderer) Format() Format {
+	return r.format
+}
+
+// Validate checks the layout of the renderer.
+func (r *Renderer) Validate() error {
+	return r.format.Validate()
+}
+
+// Render returns journal as the body of a TODOS section.
+func (r *Renderer) Render(journal *TodoJournal) string {
+	return JournalToStringFormat(journal, r.format)
+}
+
+// ReplaceJournal returns the journal file content with the body of its TODOS
+// section replaced by journal, like ReplaceTodosSection.
+func (r *Renderer) ReplaceJournal(content string, journal *TodoJournal) (string, error) {
+	return ReplaceTodosSection(content, r.header, r.Render(journal))
+}
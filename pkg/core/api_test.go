@@ -0,0 +1,73 @@
+package core
+
+import (
+	"context"
+	"testing"
+)
+
+// TestParserRenderer tests that parsers and renderers created with the same options
+// read and write TODOS sections in one layout
+func TestParserRenderer(t *testing.T) {
+	opts := []Option{
+		WithHeader("## Tasks"),
+		WithIndent(4),
+		WithDayHeader("### 2006-01-02"),
+		WithCheckboxStates(" /", "xX"),
+		WithDateTag("✅ 2006-01-02"),
+	}
+	parser, renderer := NewParser(opts...), NewRenderer(opts...)
+	if err := parser.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if parser.Header() != "## Tasks" || renderer.Format().IndentSpaces != 4 {
+		t.Errorf("Header() = %q, Format() = %+v", parser.Header(), renderer.Format())
+	}
+
+	content := "# Journal\n\n## Tasks\n\n### 2025-06-20\n- [ ] Open\n    - [/] Started\n- [X] Done ✅ 2025-06-20\n- [x] Also done\n\n## Notes\n\nText\n"
+	journal, err := parser.ParseJournal(content)
+	if err != nil {
+		t.Fatalf("ParseJournal() error = %v", err)
+	}
+
+	items := journal.Days[0].Items
+	if len(items) != 3 || items[0].Completed || items[0].SubItems[0].Completed || !items[1].Completed || !items[2].Completed {
+		t.Fatalf("unexpected items: %+v", items)
+	}
+	// Parsed journals hold date tags in DefaultDateTag
+	if items[1].Text != "Done #2025-06-20" {
+		t.Errorf("Text = %q, want canonical date tag", items[1].Text)
+	}
+
+	TagCompletedItems(journal, "2025-06-21")
+	want := "### 2025-06-20\n- [ ] Open\n    - [ ] Started\n- [x] Done ✅ 2025-06-20\n- [x] Also done ✅ 2025-06-21"
+	if got := renderer.Render(journal); got != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+
+	updated, err := renderer.ReplaceJournal(content, journal)
+	if err != nil {
+		t.Fatalf("ReplaceJournal() error = %v", err)
+	}
+	if wantFile := "# Journal\n\n## Tasks\n\n" + want + "\n\n## Notes\n\nText\n"; updated != wantFile {
+		t.Errorf("ReplaceJournal() = %q, want %q", updated, wantFile)
+	}
+}
+
+// TestParserDefaults tests that a parser without options reads like the free functions
+func TestParserDefaults(t *testing.T) {
+	section := "- [[2025-06-20]]\n  - [ ] Task\n  - [x] Done #2025-06-20\n  * [X] Upper case done"
+	journal, warnings, err := NewParser().Parse(context.Background(), section)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(warnings) != 0 || len(journal.Days[0].Items) != 3 || !journal.Days[0].Items[2].Completed {
+		t.Fatalf("Parse() = %+v, %v", journal.Days[0].Items, warnings)
+	}
+	if got := NewRenderer().Render(journal); got != JournalToString(journal) {
+		t.Errorf("Render() = %q, want %q", got, JournalToString(journal))
+	}
+
+	if _, err := NewParser(WithCheckboxStates(" ", " ")).ParseJournal("## Todos\n\n"); err == nil {
+		t.Error("ParseJournal() accepted a marker for both states")
+	}
+}
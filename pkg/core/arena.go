@@ -0,0 +1,75 @@
+// Package core provides shared memory for journals parsed in batch for the todoer application.
+package core
+
+import "strings"
+
+// Arena shares memory between the journals parsed by the parsers it is given to
+// with WithArena, for operations reading a whole journal tree.
+//
+// Equal texts, bullet lines and dates are stored once: carried todos repeat in
+// every journal they were carried to, and parsed strings no longer keep the
+// content of their journal file alive. The todo items of journals given back
+// with Release are reused by later parses.
+//
+// An Arena is not safe for concurrent use, and neither are the parsers using it.
+type Arena struct {
+	strings map[string]string // Interned strings by their value
+	items   []*TodoItem       // Released items ready for reuse
+}
+
+// NewArena creates an empty Arena.
+func NewArena() *Arena {
+	return &Arena{strings: make(map[string]string)}
+}
+
+// Len returns the number of distinct strings held by the arena.
+func (a *Arena) Len() int {
+	return len(a.strings)
+}
+
+// intern returns the string of the arena equal to s, adding a copy of s if there
+// is none. A nil arena returns s.
+func (a *Arena) intern(s string) string {
+	if a == nil {
+		return s
+	}
+	if interned, ok := a.strings[s]; ok {
+		return interned
+	}
+	s = strings.Clone(s)
+	a.strings[s] = s
+	return s
+}
+
+// newItem returns an empty todo item, reusing a released one if there is any.
+func (a *Arena) newItem() *TodoItem {
+	if a == nil || len(a.items) == 0 {
+		return &TodoItem{SubItems: []*TodoItem{}, BulletLines: []string{}}
+	}
+	item := a.items[len(a.items)-1]
+	a.items = a.items[:len(a.items)-1]
+	return item
+}
+
+// Release gives the todo items of journal back to the arena for reuse by later
+// parses. Neither journal nor its items may be used afterwards; strings taken
+// from them stay valid.
+func (a *Arena) Release(journal *TodoJournal) {
+	if journal == nil {
+		return
+	}
+	for _, day := range journal.Days {
+		a.release(day.Items)
+	}
+}
+
+// release gives items and their subitems back to the arena.
+func (a *Arena) release(items []*TodoItem) {
+	for _, item := range items {
+		a.release(item.SubItems)
+		clear(item.SubItems)
+		clear(item.BulletLines)
+		*item = TodoItem{SubItems: item.SubItems[:0], BulletLines: item.BulletLines[:0]}
+		a.items = append(a.items, item)
+	}
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"unsafe"
+)
+
+func TestArena(t *testing.T) {
+	first := "- [[2025-06-20]]\n  - [ ] Carried task\n    - A note\n  - [x] Done #2025-06-20"
+	second := "- [[2025-06-21]]\n  - [ ] Carried task\n    - A note\n  - [ ] New task due::[[2025-06-30]]"
+
+	arena := NewArena()
+	parser := NewParser(WithArena(arena))
+	a, _, err := parser.Parse(context.Background(), first)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	b, _, err := parser.Parse(context.Background(), second)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	// Equal strings of both journals are stored once
+	if unsafe.StringData(a.Days[0].Items[0].Text) != unsafe.StringData(b.Days[0].Items[0].Text) {
+		t.Error("texts of the carried task are not shared")
+	}
+	if unsafe.StringData(a.Days[0].Items[0].BulletLines[0]) != unsafe.StringData(b.Days[0].Items[0].BulletLines[0]) {
+		t.Error("bullet lines of the carried task are not shared")
+	}
+	if got, want := arena.Len(), 6; got != want {
+		t.Errorf("Len() = %d, want %d", got, want)
+	}
+
+	// Journals parsed with an arena are those parsed without one
+	for content, journal := range map[string]*TodoJournal{first: a, second: b} {
+		want, err := ParseTodosSection(content)
+		if err != nil {
+			t.Fatalf("ParseTodosSection() error: %v", err)
+		}
+		if !reflect.DeepEqual(journal, want) {
+			t.Errorf("journal parsed with arena = %#v, want %#v", journal, want)
+		}
+	}
+
+	// Released items are reused, emptied, by the next parse
+	released := b.Days[0].Items[1]
+	text := b.Days[0].Items[1].Text
+	arena.Release(b)
+	c, _, err := parser.Parse(context.Background(), first)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if c.Days[0].Items[0] != released {
+		t.Error("released item not reused")
+	}
+	if want, _ := ParseTodosSection(first); !reflect.DeepEqual(c, want) {
+		t.Errorf("journal parsed from released items = %#v, want %#v", c, want)
+	}
+	if text != "New task due::[[2025-06-30]]" {
+		t.Errorf("string taken from a released journal changed to %q", text)
+	}
+}
@@ -0,0 +1,109 @@
+// Package core provides assignee mentions for the todoer application.
+package core
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// AssigneeRegex matches an assignee mention such as @alice or @jane.doe in a todo
+// text. Names start with a letter; e-mail addresses do not match since the '@'
+// must follow whitespace or start the text.
+// Captures: (name without '@')
+var AssigneeRegex = regexp.MustCompile(`(?:^|\s)@(\p{L}[\p{L}\p{N}_-]*(?:\.[\p{L}\p{N}_-]+)*)`)
+
+// ExtractAssignees returns the distinct names mentioned in a todo text in lower
+// case without their '@', in the order they first appear. Mentions in code spans,
+// URLs and link targets are not included.
+func ExtractAssignees(text string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, m := range findInProse(AssigneeRegex, text) {
+		name := strings.ToLower(text[m[2]:m[3]])
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// MatchAssignee returns a matcher for FindItems that selects items mentioning
+// name, ignoring case. The leading '@' of name is optional.
+func MatchAssignee(name string) func(*TodoItem) bool {
+	name = strings.ToLower(strings.TrimPrefix(name, "@"))
+	return func(item *TodoItem) bool {
+		for _, assignee := range ExtractAssignees(item.Text) {
+			if assignee == name {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// FilterAssigned returns the top-level todos of journal assigned to name, with
+// their subitems, see MatchAssignee. Days without such todos are left out. The
+// result shares the items of journal.
+func FilterAssigned(journal *TodoJournal, name string) *TodoJournal {
+	result := &TodoJournal{Days: []*DaySection{}}
+	if journal == nil {
+		return result
+	}
+	match := MatchAssignee(name)
+	for _, day := range journal.Days {
+		if day == nil {
+			continue
+		}
+		var assigned *DaySection
+		for _, item := range day.Items {
+			if item == nil || !match(item) {
+				continue
+			}
+			if assigned == nil {
+				assigned = &DaySection{Date: day.Date, Items: []*TodoItem{}}
+				result.Days = append(result.Days, assigned)
+			}
+			assigned.Items = append(assigned.Items, item)
+		}
+	}
+	return result
+}
+
+// Assignees returns the names mentioned by the top-level todos of journal, in
+// lower case without their '@', sorted.
+func Assignees(journal *TodoJournal) []string {
+	seen := make(map[string]bool)
+	var names []string
+	if journal == nil {
+		return names
+	}
+	for _, day := range journal.Days {
+		if day == nil {
+			continue
+		}
+		for _, item := range day.Items {
+			if item == nil {
+				continue
+			}
+			for _, name := range ExtractAssignees(item.Text) {
+				if !seen[name] {
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidAssignee reports whether name, with or without its leading '@', is a name
+// AssigneeRegex matches in full.
+func ValidAssignee(name string) bool {
+	name = "@" + strings.TrimPrefix(name, "@")
+	m := AssigneeRegex.FindStringSubmatchIndex(name)
+	return m != nil && m[0] == 0 && m[1] == len(name)
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestExtractAssignees(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"@alice Review PR", []string{"alice"}},
+		{"Pair on deploy @Bob @alice @bob", []string{"bob", "alice"}},
+		{"Ask @jane.doe.", []string{"jane.doe"}},
+		{"Mail bob@example.com", nil},
+		{"Run `git log @{u}` and @2x", nil},
+		{"See [docs](https://example.com/@alice)", nil},
+		{"Café with @zoë", []string{"zoë"}},
+	}
+	for _, tt := range tests {
+		if got := ExtractAssignees(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ExtractAssignees(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestValidAssignee(t *testing.T) {
+	for name, want := range map[string]bool{"alice": true, "@Jane.Doe": true, "bob smith": false, "2x": false, "": false, "a/b": false} {
+		if got := ValidAssignee(name); got != want {
+			t.Errorf("ValidAssignee(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestFilterAssigned(t *testing.T) {
+	journal, err := ParseTodosSection(`- [[2025-06-20]]
+  - [ ] Review PR @alice
+    - [ ] Ask @bob
+  - [ ] Deploy @bob
+- [[2025-06-21]]
+  - [x] Standup notes @Alice #2025-06-21
+  - [ ] Unassigned`)
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error: %v", err)
+	}
+
+	want := "- [[2025-06-20]]\n  - [ ] Review PR @alice\n    - [ ] Ask @bob\n- [[2025-06-21]]\n  - [x] Standup notes @Alice #2025-06-21"
+	if got := JournalToString(FilterAssigned(journal, "@alice")); got != want {
+		t.Errorf("FilterAssigned(alice) =\n%s\nwant:\n%s", got, want)
+	}
+	if got := FilterAssigned(journal, "carol"); !got.IsEmpty() {
+		t.Errorf("FilterAssigned(carol) = %s, want empty", JournalToString(got))
+	}
+	if got, want := Assignees(journal), []string{"alice", "bob"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Assignees() = %q, want %q", got, want)
+	}
+}
+
+func TestProcessAssignee(t *testing.T) {
+	input := `- [[2025-06-19]]
+  - [ ] Review PR @alice
+  - [ ] Deploy @bob
+  - [x] Standup notes @bob
+  - [ ] Unassigned`
+
+	result, err := ProcessTodosSectionWithOptions(context.Background(), input, "2025-06-19", "2025-06-20", ProcessOptions{Assignee: "Alice"})
+	if err != nil {
+		t.Fatalf("ProcessTodosSectionWithOptions() error: %v", err)
+	}
+	if want := "- [[2025-06-19]]\n  - [ ] Review PR @alice created::[[2025-06-19]]"; result.Uncompleted != want {
+		t.Errorf("carried section =\n%s\nwant:\n%s", result.Uncompleted, want)
+	}
+	wantCompleted := `- [[2025-06-19]]
+  - [ ] Deploy @bob
+  - [x] Standup notes @bob #2025-06-19
+  - [ ] Unassigned`
+	if result.Completed != wantCompleted {
+		t.Errorf("completed section =\n%s\nwant:\n%s", result.Completed, wantCompleted)
+	}
+}
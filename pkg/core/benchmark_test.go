@@ -0,0 +1,160 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// benchCorpora are the sizes of the generated todos sections benchmarked, in days
+// of 20 top-level tasks each
+var benchCorpora = []struct {
+	name string
+	days int
+}{
+	{"small", 1},
+	{"medium", 30},
+	{"huge", 1000},
+}
+
+// benchSection builds a todos section of days day sections, with the subtasks,
+// notes, tags, annotations and markup of real journals.
+func benchSection(days int) string {
+	var b strings.Builder
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for d := 0; d < days; d++ {
+		date := start.AddDate(0, 0, d).Format(DateFormat)
+		fmt.Fprintf(&b, "- [[%s]]\n", date)
+		for i := 0; i < 20; i++ {
+			switch i % 4 {
+			case 0:
+				fmt.Fprintf(&b, "  - [ ] Open task %d #work due::[[%s]]\n", i, date)
+				fmt.Fprintf(&b, "    - [x] Done subtask %d #%s\n", i, date)
+				fmt.Fprintf(&b, "    - [ ] Open subtask %d with `code #%s`\n", i, date)
+			case 1:
+				fmt.Fprintf(&b, "  - [x] Done task %d, see [notes](https://example.com/#%s)\n", i, date)
+				b.WriteString("    Continuation line with notes\n")
+			case 2:
+				fmt.Fprintf(&b, "  - [ ] Task %d blocked-by::[[task-%d]] 📅 %s\n", i, i-1, date)
+				b.WriteString("    - A bullet note\n")
+			default:
+				fmt.Fprintf(&b, "  - [x] Errand %d #errand #home\n", i)
+			}
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// benchJournal parses the todos section of a corpus for the benchmarks of later stages.
+func benchJournal(b *testing.B, section string) *TodoJournal {
+	b.Helper()
+	journal, err := ParseTodosSection(section)
+	if err != nil {
+		b.Fatalf("ParseTodosSection() error: %v", err)
+	}
+	return journal
+}
+
+// BenchmarkParse benchmarks parsing todos sections of growing size
+func BenchmarkParse(b *testing.B) {
+	for _, corpus := range benchCorpora {
+		section := benchSection(corpus.days)
+		b.Run(corpus.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(section)))
+			for i := 0; i < b.N; i++ {
+				if _, err := ParseTodosSectionContext(context.Background(), section); err != nil {
+					b.Fatalf("ParseTodosSectionContext() error: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkSplit benchmarks splitting parsed journals into completed and open todos
+func BenchmarkSplit(b *testing.B) {
+	for _, corpus := range benchCorpora {
+		section := benchSection(corpus.days)
+		journal := benchJournal(b, section)
+		b.Run(corpus.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(section)))
+			for i := 0; i < b.N; i++ {
+				SplitCarried(journal)
+			}
+		})
+	}
+}
+
+// BenchmarkTag benchmarks tagging completed todos with their date
+func BenchmarkTag(b *testing.B) {
+	for _, corpus := range benchCorpora {
+		section := benchSection(corpus.days)
+		b.Run(corpus.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(section)))
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				completed, open := SplitJournal(benchJournal(b, section))
+				b.StartTimer()
+				TagCompletedItems(completed, "2025-06-20")
+				TagCompletedSubitems(open, "2025-06-20")
+			}
+		})
+	}
+}
+
+// BenchmarkRender benchmarks writing parsed journals back as todos sections
+func BenchmarkRender(b *testing.B) {
+	format := Format{IndentSpaces: 4, DateTag: "✅ 2006-01-02"}
+	for _, corpus := range benchCorpora {
+		section := benchSection(corpus.days)
+		journal := benchJournal(b, section)
+		b.Run(corpus.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(section)))
+			for i := 0; i < b.N; i++ {
+				JournalToStringFormat(journal, format)
+			}
+		})
+	}
+}
+
+// BenchmarkProcessTodosSection benchmarks all stages of processing a todos section
+func BenchmarkProcessTodosSection(b *testing.B) {
+	for _, corpus := range benchCorpora {
+		section := benchSection(corpus.days)
+		b.Run(corpus.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(section)))
+			for i := 0; i < b.N; i++ {
+				if _, err := ProcessTodosSectionWithOptions(context.Background(), section, "2025-06-20", "2025-06-21", ProcessOptions{Format: DefaultFormat}); err != nil {
+					b.Fatalf("ProcessTodosSectionWithOptions() error: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkParseArena benchmarks parsing todos sections one after another with
+// an arena, releasing each journal before the next
+func BenchmarkParseArena(b *testing.B) {
+	for _, corpus := range benchCorpora {
+		section := benchSection(corpus.days)
+		b.Run(corpus.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(section)))
+			arena := NewArena()
+			parser := NewParser(WithArena(arena))
+			for i := 0; i < b.N; i++ {
+				journal, _, err := parser.Parse(context.Background(), section)
+				if err != nil {
+					b.Fatalf("Parse() error: %v", err)
+				}
+				arena.Release(journal)
+			}
+		})
+	}
+}
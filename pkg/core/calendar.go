@@ -0,0 +1,79 @@
+// Package core provides the workday calendar for the todoer application.
+package core
+
+import (
+	"text/template"
+	"time"
+)
+
+// Calendar tells workdays from days off. Saturdays, Sundays and the holidays are
+// days off. The zero Calendar has no holidays.
+type Calendar struct {
+	Holidays map[string]bool // Dates in YYYY-MM-DD format that are not workdays
+}
+
+// NewCalendar returns a Calendar with the given holidays in YYYY-MM-DD format.
+// Invalid dates are rejected.
+func NewCalendar(holidays ...string) (Calendar, error) {
+	c := Calendar{Holidays: make(map[string]bool, len(holidays))}
+	for _, date := range holidays {
+		if err := ValidateDate(date); err != nil {
+			return Calendar{}, err
+		}
+		c.Holidays[date] = true
+	}
+	return c, nil
+}
+
+// IsWorkday reports whether t falls on Monday to Friday and is not a holiday.
+func (c Calendar) IsWorkday(t time.Time) bool {
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+	return !c.Holidays[t.Format(DateFormat)]
+}
+
+// NextWorkday returns the first workday after t.
+func (c Calendar) NextWorkday(t time.Time) time.Time {
+	return c.step(t, 1)
+}
+
+// PreviousWorkday returns the last workday before t.
+func (c Calendar) PreviousWorkday(t time.Time) time.Time {
+	return c.step(t, -1)
+}
+
+// step moves from t by days until it reaches a workday. A year of holidays in a
+// row stops the search.
+func (c Calendar) step(t time.Time, days int) time.Time {
+	d := t.AddDate(0, 0, days)
+	for i := 0; i < 366 && !c.IsWorkday(d); i++ {
+		d = d.AddDate(0, 0, days)
+	}
+	return d
+}
+
+// Functions returns the template functions that depend on the calendar. They
+// take and return dates in YYYY-MM-DD format and return invalid dates unchanged.
+func (c Calendar) Functions() template.FuncMap {
+	shift := func(move func(time.Time) time.Time) func(string) string {
+		return func(dateStr string) string {
+			date, err := time.Parse(DateFormat, dateStr)
+			if err != nil {
+				return dateStr // Return original on error
+			}
+			return move(date).Format(DateFormat)
+		}
+	}
+	return template.FuncMap{
+		"nextWorkday": shift(c.NextWorkday),
+		"prevWorkday": shift(c.PreviousWorkday),
+		"isWorkday": func(dateStr string) bool {
+			date, err := time.Parse(DateFormat, dateStr)
+			if err != nil {
+				return false // Return false on error
+			}
+			return c.IsWorkday(date)
+		},
+	}
+}
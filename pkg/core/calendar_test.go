@@ -0,0 +1,65 @@
+package core
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalendar(t *testing.T) {
+	calendar, err := NewCalendar("2025-12-25", "2025-12-26")
+	if err != nil {
+		t.Fatalf("NewCalendar() error: %v", err)
+	}
+	day := func(s string) time.Time {
+		d, _ := time.Parse(DateFormat, s)
+		return d
+	}
+
+	tests := []struct {
+		date, next, prev string
+		workday          bool
+	}{
+		{date: "2025-06-20", next: "2025-06-23", prev: "2025-06-19", workday: true},  // Friday
+		{date: "2025-06-21", next: "2025-06-23", prev: "2025-06-20", workday: false}, // Saturday
+		{date: "2025-12-24", next: "2025-12-29", prev: "2025-12-23", workday: true},  // Before Christmas
+		{date: "2025-12-25", next: "2025-12-29", prev: "2025-12-24", workday: false}, // Holiday
+	}
+	for _, tt := range tests {
+		if got := calendar.IsWorkday(day(tt.date)); got != tt.workday {
+			t.Errorf("IsWorkday(%s) = %v, want %v", tt.date, got, tt.workday)
+		}
+		if got := calendar.NextWorkday(day(tt.date)).Format(DateFormat); got != tt.next {
+			t.Errorf("NextWorkday(%s) = %s, want %s", tt.date, got, tt.next)
+		}
+		if got := calendar.PreviousWorkday(day(tt.date)).Format(DateFormat); got != tt.prev {
+			t.Errorf("PreviousWorkday(%s) = %s, want %s", tt.date, got, tt.prev)
+		}
+	}
+
+	if _, err := NewCalendar("2025-13-01"); err == nil {
+		t.Error("NewCalendar() accepted an invalid date")
+	}
+
+	// Templates use the holidays of the calendar they are rendered with
+	for _, tc := range []struct {
+		calendar Calendar
+		want     string
+	}{
+		{Calendar{}, "2025-12-25 2025-12-26 true"},
+		{calendar, "2025-12-29 2025-12-29 false"},
+	} {
+		var out strings.Builder
+		err := WriteFromTemplate(&out, TemplateOptions{
+			Content:     `{{nextWorkday .Date}} {{nextWorkday "2025-12-25"}} {{isWorkday "2025-12-26"}}`,
+			CurrentDate: "2025-12-24",
+			Calendar:    tc.calendar,
+		})
+		if err != nil {
+			t.Fatalf("WriteFromTemplate() error: %v", err)
+		}
+		if out.String() != tc.want {
+			t.Errorf("rendered %q, want %q", out.String(), tc.want)
+		}
+	}
+}
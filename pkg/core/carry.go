@@ -0,0 +1,368 @@
+// Package core provides carry-forward transformations for the todoer application.
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	// CarriedFromRegex matches a carried-from annotation added by CollapseDays
+	CarriedFromRegex = regexp.MustCompile(` \(from \[\[(\d{4}-\d{2}-\d{2})\]\]\)$`)
+
+	// PriorityRegex matches a priority marker such as [#A] in todo text
+	// Captures: (priority)
+	PriorityRegex = regexp.MustCompile(`\[#(\w+)\]`)
+)
+
+// Carry directives of a todo, see CarryDirective
+const (
+	CarryKeep = "keep" // Never carried, the todo stays in the journal of its day
+	CarryDrop = "drop" // Dropped silently instead of being carried
+)
+
+// Markers of the carry directives in todo texts
+var (
+	carryKeepMarkers = []string{"📌", "<!-- todoer:keep -->"}
+	carryDropMarkers = []string{"🗑", "<!-- todoer:drop -->"}
+)
+
+// CarryDirective returns CarryKeep if text contains 📌 or <!-- todoer:keep -->,
+// CarryDrop if it contains 🗑 or <!-- todoer:drop -->, and an empty string otherwise.
+// Keeping wins over dropping, so a todo marked both ways is not lost.
+func CarryDirective(text string) string {
+	for _, marker := range carryKeepMarkers {
+		if strings.Contains(text, marker) {
+			return CarryKeep
+		}
+	}
+	for _, marker := range carryDropMarkers {
+		if strings.Contains(text, marker) {
+			return CarryDrop
+		}
+	}
+	return ""
+}
+
+// ExtractCarriedFrom returns the date of the carried-from annotation of text,
+// or an empty string if the text has none.
+func ExtractCarriedFrom(text string) string {
+	if m := CarriedFromRegex.FindStringSubmatch(text); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// CollapseDays returns a journal holding the items of all day sections of journal
+// in one section under date, in their original order. With annotate, top-level
+// items from other days are annotated with the date of their section, unless an
+// earlier collapse already annotated them. The items are shared with journal, so
+// annotations show in both.
+func CollapseDays(journal *TodoJournal, date string, annotate bool) *TodoJournal {
+	result := &TodoJournal{Days: []*DaySection{}}
+	if journal == nil {
+		return result
+	}
+
+	collapsed := &DaySection{Date: date, Items: []*TodoItem{}}
+	for _, day := range journal.Days {
+		if day == nil {
+			continue
+		}
+		for _, item := range day.Items {
+			if item == nil {
+				continue
+			}
+			if annotate && day.Date != "" && day.Date != date && ExtractCarriedFrom(item.Text) == "" {
+				item.Text += " (" + fmt.Sprintf(CarriedFromTemplate, day.Date) + ")"
+			}
+			collapsed.Items = append(collapsed.Items, item)
+		}
+	}
+
+	if len(collapsed.Items) > 0 {
+		result.Days = append(result.Days, collapsed)
+	}
+	return result
+}
+
+// LimitCarried splits the top-level items of journal into the at most limit items to
+// carry and the overflow. Items with a priority marker are kept first, [#A] before
+// [#B], then the newest by the date of their day section, or of their carried-from
+// annotation if they have one. Both journals keep the days and item order of journal
+// and share its items. A limit of zero or less carries everything.
+func LimitCarried(journal *TodoJournal, limit int) (*TodoJournal, *TodoJournal) {
+	carried := &TodoJournal{Days: []*DaySection{}}
+	overflow := &TodoJournal{Days: []*DaySection{}}
+	if journal == nil {
+		return carried, overflow
+	}
+
+	type candidate struct {
+		item     *TodoItem
+		priority string
+		date     string
+	}
+	var candidates []candidate
+	for _, day := range journal.Days {
+		if day == nil {
+			continue
+		}
+		for _, item := range day.Items {
+			if item == nil {
+				continue
+			}
+			c := candidate{item: item, date: day.Date}
+			if m := PriorityRegex.FindStringSubmatch(item.Text); m != nil {
+				c.priority = strings.ToUpper(m[1])
+			}
+			if from := ExtractCarriedFrom(item.Text); from != "" {
+				c.date = from
+			}
+			candidates = append(candidates, c)
+		}
+	}
+
+	keep := make(map[*TodoItem]bool, len(candidates))
+	if limit <= 0 || len(candidates) <= limit {
+		for _, c := range candidates {
+			keep[c.item] = true
+		}
+	} else {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			a, b := candidates[i], candidates[j]
+			if (a.priority == "") != (b.priority == "") {
+				return a.priority != ""
+			}
+			if a.priority != b.priority {
+				return a.priority < b.priority
+			}
+			return a.date > b.date
+		})
+		for _, c := range candidates[:limit] {
+			keep[c.item] = true
+		}
+	}
+
+	for _, day := range journal.Days {
+		if day == nil {
+			continue
+		}
+		var carriedDay, overflowDay *DaySection
+		for _, item := range day.Items {
+			if item == nil {
+				continue
+			}
+			if keep[item] {
+				if carriedDay == nil {
+					carriedDay = &DaySection{Date: day.Date, Items: []*TodoItem{}}
+					carried.Days = append(carried.Days, carriedDay)
+				}
+				carriedDay.Items = append(carriedDay.Items, item)
+			} else {
+				if overflowDay == nil {
+					overflowDay = &DaySection{Date: day.Date, Items: []*TodoItem{}}
+					overflow.Days = append(overflow.Days, overflowDay)
+				}
+				overflowDay.Items = append(overflowDay.Items, item)
+			}
+		}
+	}
+
+	return carried, overflow
+}
+
+// DayRange is an inclusive range of dates in YYYY-MM-DD format. An empty From or
+// To leaves the range open on that side, so the zero DayRange holds every date.
+type DayRange struct {
+	From string // First date of the range
+	To   string // Last date of the range
+}
+
+// Validate checks the dates of the range and that From is not after To.
+func (r DayRange) Validate() error {
+	for _, date := range []string{r.From, r.To} {
+		if date == "" {
+			continue
+		}
+		if err := ValidateDate(date); err != nil {
+			return err
+		}
+	}
+	if r.From != "" && r.To != "" && r.From > r.To {
+		return fmt.Errorf("range start %s is after its end %s", r.From, r.To)
+	}
+	return nil
+}
+
+// Contains reports whether date lies in the range. Undated sections, with an
+// empty date, are in every range.
+func (r DayRange) Contains(date string) bool {
+	if date == "" {
+		return true
+	}
+	return (r.From == "" || date >= r.From) && (r.To == "" || date <= r.To)
+}
+
+// SplitDays splits the day sections of journal into those with a date in r and
+// the others. Both journals keep the order of journal and share its day sections.
+func SplitDays(journal *TodoJournal, r DayRange) (*TodoJournal, *TodoJournal) {
+	inside := &TodoJournal{Days: []*DaySection{}}
+	outside := &TodoJournal{Days: []*DaySection{}}
+	if journal == nil {
+		return inside, outside
+	}
+	for _, day := range journal.Days {
+		if day == nil {
+			continue
+		}
+		if r.Contains(day.Date) {
+			inside.Days = append(inside.Days, day)
+		} else {
+			outside.Days = append(outside.Days, day)
+		}
+	}
+	return inside, outside
+}
+
+// restoreDays returns the day sections of processed, derived from the sections of
+// journal in r in the same order, with the sections of journal outside r put back
+// where they were.
+func restoreDays(journal, processed *TodoJournal, r DayRange) *TodoJournal {
+	result := &TodoJournal{Days: make([]*DaySection, 0, len(journal.Days))}
+	next := 0
+	for _, day := range journal.Days {
+		if day == nil {
+			continue
+		}
+		if !r.Contains(day.Date) {
+			result.Days = append(result.Days, day)
+		} else if next < len(processed.Days) && processed.Days[next].Date == day.Date {
+			result.Days = append(result.Days, processed.Days[next])
+			next++
+		}
+	}
+	return result
+}
+
+// carryMarker reads and writes the carry count of todo texts, see Format.CarryMarker
+type carryMarker struct {
+	layout  string         // Layout of the marker with one %d for the count
+	pattern *regexp.Regexp // Matches the marker anywhere in a todo text, capturing the count
+}
+
+// newCarryMarker creates a carryMarker for layout, which must contain %d once and
+// start with a marker such as ↪.
+func newCarryMarker(layout string) (*carryMarker, error) {
+	if strings.TrimSpace(layout) != layout || strings.Contains(layout, "\n") {
+		return nil, fmt.Errorf("carry marker %q must be a single line without surrounding space", layout)
+	}
+	if strings.Count(layout, "%d") != 1 || strings.Count(layout, "%") != 1 {
+		return nil, fmt.Errorf("carry marker %q must contain %%d once and no other %% verb", layout)
+	}
+	if r, _ := utf8.DecodeRuneInString(layout); unicode.IsLetter(r) || unicode.IsDigit(r) || r == '%' {
+		// Without a marker, every number in a todo text would be read as a count
+		return nil, fmt.Errorf("carry marker %q must start with a marker such as ↪", layout)
+	}
+	before, after, _ := strings.Cut(layout, "%d")
+	pattern := regexp.MustCompile(regexp.QuoteMeta(before) + `(\d+)` + regexp.QuoteMeta(after))
+	return &carryMarker{layout: layout, pattern: pattern}, nil
+}
+
+// count returns the carry count of text, 0 if it has no marker or m is nil.
+func (m *carryMarker) count(text string) int {
+	if m == nil {
+		return 0
+	}
+	match := m.pattern.FindStringSubmatch(text)
+	if match == nil {
+		return 0
+	}
+	n, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// increment returns text with its carry count raised by one. A text without a
+// marker gets one with a count of 1, placed before a carried-from annotation so
+// that it stays at the end.
+func (m *carryMarker) increment(text string) (string, int) {
+	n := m.count(text) + 1
+	marker := fmt.Sprintf(m.layout, n)
+	if loc := m.pattern.FindStringIndex(text); loc != nil {
+		return text[:loc[0]] + marker + text[loc[1]:], n
+	}
+	if loc := CarriedFromRegex.FindStringIndex(text); loc != nil {
+		return text[:loc[0]] + " " + marker + text[loc[0]:], n
+	}
+	return text + " " + marker, n
+}
+
+// CountCarries increments the carry count of the top-level items of journal, the
+// todos about to be carried to a new journal, in their text and CarryCount. It does
+// nothing if format does not count carries or its carry marker is invalid.
+func CountCarries(journal *TodoJournal, format Format) {
+	marker, err := format.carryMarker()
+	if marker == nil || err != nil || journal == nil {
+		return
+	}
+	for _, day := range journal.Days {
+		if day == nil {
+			continue
+		}
+		for _, item := range day.Items {
+			if item != nil {
+				item.Text, item.CarryCount = marker.increment(item.Text)
+			}
+		}
+	}
+}
+
+// CarriedTodo is an open todo that was carried to a new journal, see MostCarried.
+type CarriedTodo struct {
+	Text       string // Task text, including its carry marker
+	Date       string // Date the task was written down, from its day section or carried-from annotation
+	CarryCount int    // Times the task was carried
+}
+
+// MostCarried returns the open top-level todos of journals that were carried at
+// least once, the most carried first, then the oldest by their date, then in
+// document order.
+func MostCarried(journals ...*TodoJournal) []CarriedTodo {
+	var todos []CarriedTodo
+	for _, journal := range journals {
+		if journal == nil {
+			continue
+		}
+		for _, day := range journal.Days {
+			if day == nil {
+				continue
+			}
+			for _, item := range day.Items {
+				if item == nil || item.Completed || item.CarryCount == 0 {
+					continue
+				}
+				todo := CarriedTodo{Text: item.Text, Date: day.Date, CarryCount: item.CarryCount}
+				if from := ExtractCarriedFrom(item.Text); from != "" {
+					todo.Date = from
+				}
+				todos = append(todos, todo)
+			}
+		}
+	}
+
+	sort.SliceStable(todos, func(i, j int) bool {
+		if todos[i].CarryCount != todos[j].CarryCount {
+			return todos[i].CarryCount > todos[j].CarryCount
+		}
+		return todos[i].Date < todos[j].Date
+	})
+	return todos
+}
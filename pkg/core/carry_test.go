@@ -0,0 +1,367 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestCollapseDays(t *testing.T) {
+	input := `- [[2025-06-18]]
+  - [ ] Oldest task
+    - [ ] Subtask
+  - [ ] Carried before (from [[2025-06-01]])
+- [[2025-06-19]]
+  - [ ] Yesterday's task
+    - note
+- [[2025-06-20]]
+  - [ ] Today's task`
+
+	tests := []struct {
+		name     string
+		annotate bool
+		want     string
+	}{
+		{
+			name:     "without annotations",
+			annotate: false,
+			want: `- [[2025-06-20]]
+  - [ ] Oldest task
+    - [ ] Subtask
+  - [ ] Carried before (from [[2025-06-01]])
+  - [ ] Yesterday's task
+    - note
+  - [ ] Today's task`,
+		},
+		{
+			name:     "with annotations",
+			annotate: true,
+			want: `- [[2025-06-20]]
+  - [ ] Oldest task (from [[2025-06-18]])
+    - [ ] Subtask
+  - [ ] Carried before (from [[2025-06-01]])
+  - [ ] Yesterday's task (from [[2025-06-19]])
+    - note
+  - [ ] Today's task`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			journal, err := ParseTodosSection(input)
+			if err != nil {
+				t.Fatalf("ParseTodosSection() error: %v", err)
+			}
+			if got := JournalToString(CollapseDays(journal, "2025-06-20", tt.annotate)); got != tt.want {
+				t.Errorf("CollapseDays() =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+
+	if got := CollapseDays(&TodoJournal{Days: []*DaySection{{Date: "2025-06-19"}}}, "2025-06-20", true); !got.IsEmpty() {
+		t.Errorf("CollapseDays() of a journal without items = %+v, want empty", got.Days)
+	}
+	if got := CollapseDays(nil, "2025-06-20", true); !got.IsEmpty() {
+		t.Errorf("CollapseDays(nil) = %+v, want empty", got.Days)
+	}
+}
+
+func TestExtractCarriedFrom(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Task (from [[2025-06-18]])", "2025-06-18"},
+		{"Task (from [[2025-06-18]]) with more text", ""},
+		{"Task (moved from [[2025-06-18]])", ""},
+		{"Task", ""},
+	}
+
+	for _, tt := range tests {
+		if got := ExtractCarriedFrom(tt.text); got != tt.want {
+			t.Errorf("ExtractCarriedFrom(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestLimitCarried(t *testing.T) {
+	input := `- [[2025-06-10]]
+  - [ ] Old low
+  - [ ] Old urgent [#A]
+    - [ ] Subtask
+- [[2025-06-18]]
+  - [ ] Recent
+  - [ ] Carried long ago (from [[2025-06-01]])
+  - [ ] Medium [#B]
+- [[2025-06-19]]
+  - [ ] Newest`
+
+	tests := []struct {
+		name         string
+		limit        int
+		wantCarried  string
+		wantOverflow string
+	}{
+		{
+			name:  "priorities then newest",
+			limit: 4,
+			wantCarried: `- [[2025-06-10]]
+  - [ ] Old urgent [#A]
+    - [ ] Subtask
+- [[2025-06-18]]
+  - [ ] Recent
+  - [ ] Medium [#B]
+- [[2025-06-19]]
+  - [ ] Newest`,
+			wantOverflow: `- [[2025-06-10]]
+  - [ ] Old low
+- [[2025-06-18]]
+  - [ ] Carried long ago (from [[2025-06-01]])`,
+		},
+		{
+			name:  "only priorities",
+			limit: 1,
+			wantCarried: `- [[2025-06-10]]
+  - [ ] Old urgent [#A]
+    - [ ] Subtask`,
+			wantOverflow: `- [[2025-06-10]]
+  - [ ] Old low
+- [[2025-06-18]]
+  - [ ] Recent
+  - [ ] Carried long ago (from [[2025-06-01]])
+  - [ ] Medium [#B]
+- [[2025-06-19]]
+  - [ ] Newest`,
+		},
+		{
+			name:         "no limit",
+			limit:        0,
+			wantCarried:  input,
+			wantOverflow: "",
+		},
+		{
+			name:         "limit above the number of todos",
+			limit:        10,
+			wantCarried:  input,
+			wantOverflow: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			journal, err := ParseTodosSection(input)
+			if err != nil {
+				t.Fatalf("ParseTodosSection() error: %v", err)
+			}
+			carried, overflow := LimitCarried(journal, tt.limit)
+			if got := JournalToString(carried); got != tt.wantCarried {
+				t.Errorf("carried =\n%s\nwant:\n%s", got, tt.wantCarried)
+			}
+			if got := JournalToString(overflow); got != tt.wantOverflow {
+				t.Errorf("overflow =\n%s\nwant:\n%s", got, tt.wantOverflow)
+			}
+		})
+	}
+}
+
+func TestCountCarries(t *testing.T) {
+	format := Format{CarryMarker: "↪×%d"}
+	input := `- [[2025-06-18]]
+  - [ ] New task
+    - [ ] Subtask
+  - [ ] Carried before ↪×2
+  - [ ] Annotated (from [[2025-06-01]])
+  - [ ] Counted and annotated ↪×4 (from [[2025-06-01]])`
+
+	journal, err := ParseTodosSectionFormat(context.Background(), input, format)
+	if err != nil {
+		t.Fatalf("ParseTodosSectionFormat() error: %v", err)
+	}
+	if got := journal.Days[0].Items[1].CarryCount; got != 2 {
+		t.Errorf("CarryCount of %q = %d, want 2", journal.Days[0].Items[1].Text, got)
+	}
+
+	CountCarries(journal, format)
+	want := `- [[2025-06-18]]
+  - [ ] New task ↪×1
+    - [ ] Subtask
+  - [ ] Carried before ↪×3
+  - [ ] Annotated ↪×1 (from [[2025-06-01]])
+  - [ ] Counted and annotated ↪×5 (from [[2025-06-01]])`
+	if got := JournalToString(journal); got != want {
+		t.Errorf("CountCarries() =\n%s\nwant:\n%s", got, want)
+	}
+	for i, wantCount := range []int{1, 3, 1, 5} {
+		if got := journal.Days[0].Items[i].CarryCount; got != wantCount {
+			t.Errorf("CarryCount of item %d = %d, want %d", i, got, wantCount)
+		}
+	}
+	if from := ExtractCarriedFrom(journal.Days[0].Items[2].Text); from != "2025-06-01" {
+		t.Errorf("ExtractCarriedFrom() after counting = %q, want 2025-06-01", from)
+	}
+
+	unchanged := JournalToString(journal)
+	CountCarries(journal, DefaultFormat)
+	if got := JournalToString(journal); got != unchanged {
+		t.Errorf("CountCarries() without a carry marker changed the journal to\n%s", got)
+	}
+
+	result, err := ProcessTodosSectionWithOptions(context.Background(), "- [[2025-06-18]]\n  - [ ] Task ↪×1\n  - [x] Done",
+		"2025-06-18", "2025-06-19", ProcessOptions{Format: format, CollapseCarried: true, AnnotateCarried: true})
+	if err != nil {
+		t.Fatalf("ProcessTodosSectionWithOptions() error: %v", err)
+	}
+	if want := "- [[2025-06-19]]\n  - [ ] Task ↪×2 created::[[2025-06-18]] (from [[2025-06-18]])"; result.Uncompleted != want {
+		t.Errorf("ProcessTodosSectionWithOptions() carried\n%s\nwant:\n%s", result.Uncompleted, want)
+	}
+	if want := "- [[2025-06-18]]\n  - [x] Done #2025-06-18"; result.Completed != want {
+		t.Errorf("ProcessTodosSectionWithOptions() left\n%s\nwant:\n%s", result.Completed, want)
+	}
+}
+
+func TestMostCarried(t *testing.T) {
+	format := Format{CarryMarker: "↪×%d"}
+	input := `- [[2025-06-10]]
+  - [ ] Never carried
+  - [ ] Twice ↪×2
+  - [x] Done late ↪×9
+- [[2025-06-12]]
+  - [ ] Five times ↪×5
+  - [ ] Twice, older ↪×2 (from [[2025-06-01]])`
+
+	journal, err := ParseTodosSectionFormat(context.Background(), input, format)
+	if err != nil {
+		t.Fatalf("ParseTodosSectionFormat() error: %v", err)
+	}
+	got := MostCarried(journal, nil)
+	want := []CarriedTodo{
+		{Text: "Five times ↪×5", Date: "2025-06-12", CarryCount: 5},
+		{Text: "Twice, older ↪×2 (from [[2025-06-01]])", Date: "2025-06-01", CarryCount: 2},
+		{Text: "Twice ↪×2", Date: "2025-06-10", CarryCount: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MostCarried() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCarryDirective(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Plain task", ""},
+		{"Renew passport 📌", CarryKeep},
+		{"Renew passport <!-- todoer:keep -->", CarryKeep},
+		{"Maybe learn Rust 🗑️", CarryDrop},
+		{"Maybe learn Rust <!-- todoer:drop -->", CarryDrop},
+		{"Both 🗑 📌", CarryKeep},
+		{"todoer:keep without comment", ""},
+	}
+	for _, tt := range tests {
+		if got := CarryDirective(tt.text); got != tt.want {
+			t.Errorf("CarryDirective(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSplitCarried(t *testing.T) {
+	input := `- [[2025-06-18]]
+  - [ ] Pinned 📌
+    - [x] Pinned subtask
+  - [ ] Carried
+  - [ ] Dropped <!-- todoer:drop -->
+  - [x] Done
+  - [x] Done with drop marker 🗑
+- [[2025-06-19]]
+  - [ ] Only dropped 🗑`
+
+	result, err := ProcessTodosSectionWithOptions(context.Background(), input, "2025-06-19", "2025-06-20", ProcessOptions{})
+	if err != nil {
+		t.Fatalf("ProcessTodosSectionWithOptions() error: %v", err)
+	}
+	wantCompleted := `- [[2025-06-18]]
+  - [ ] Pinned 📌
+    - [x] Pinned subtask #2025-06-19
+  - [x] Done #2025-06-19
+  - [x] Done with drop marker 🗑 #2025-06-19`
+	if result.Completed != wantCompleted {
+		t.Errorf("completed section =\n%s\nwant:\n%s", result.Completed, wantCompleted)
+	}
+	if want := "- [[2025-06-18]]\n  - [ ] Carried created::[[2025-06-18]]"; result.Uncompleted != want {
+		t.Errorf("carried section =\n%s\nwant:\n%s", result.Uncompleted, want)
+	}
+	wantDone := "- [[2025-06-18]]\n  - [x] Done #2025-06-19\n  - [x] Done with drop marker 🗑 #2025-06-19"
+	if got := JournalToString(result.Done); got != wantDone {
+		t.Errorf("Done =\n%s\nwant:\n%s", got, wantDone)
+	}
+}
+
+func TestDayRange(t *testing.T) {
+	r := DayRange{From: "2025-06-10", To: "2025-06-20"}
+	for date, want := range map[string]bool{"2025-06-09": false, "2025-06-10": true, "2025-06-20": true, "2025-06-21": false, "": true} {
+		if got := r.Contains(date); got != want {
+			t.Errorf("%+v.Contains(%q) = %v, want %v", r, date, got, want)
+		}
+	}
+	if !(DayRange{}).Contains("1999-01-01") || !(DayRange{From: "2025-06-10"}).Contains("2030-01-01") {
+		t.Error("open ended range does not contain dates on its open side")
+	}
+
+	for _, tt := range []struct {
+		r       DayRange
+		wantErr bool
+	}{
+		{DayRange{}, false},
+		{DayRange{From: "2025-06-10"}, false},
+		{DayRange{From: "2025-06-10", To: "2025-06-10"}, false},
+		{DayRange{From: "2025-06-11", To: "2025-06-10"}, true},
+		{DayRange{To: "2025-13-01"}, true},
+	} {
+		if err := tt.r.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%+v.Validate() error = %v, wantErr %v", tt.r, err, tt.wantErr)
+		}
+	}
+}
+
+func TestProcessDayRange(t *testing.T) {
+	input := `- [[2024-01-03]]
+  - [ ] Ancient task
+  - [x] Ancient done
+- [[2025-06-18]]
+  - [ ] Recent task
+  - [x] Recent done
+- [[2025-06-19]]
+  - [ ] Newest task`
+
+	result, err := ProcessTodosSectionWithOptions(context.Background(), input, "2025-06-19", "2025-06-20", ProcessOptions{
+		Days: DayRange{From: "2025-06-01"},
+	})
+	if err != nil {
+		t.Fatalf("ProcessTodosSectionWithOptions() error: %v", err)
+	}
+	wantCompleted := `- [[2024-01-03]]
+  - [ ] Ancient task
+  - [x] Ancient done
+- [[2025-06-18]]
+  - [x] Recent done #2025-06-19`
+	if result.Completed != wantCompleted {
+		t.Errorf("completed section =\n%s\nwant:\n%s", result.Completed, wantCompleted)
+	}
+	wantCarried := "- [[2025-06-18]]\n  - [ ] Recent task created::[[2025-06-18]]\n- [[2025-06-19]]\n  - [ ] Newest task created::[[2025-06-19]]"
+	if result.Uncompleted != wantCarried {
+		t.Errorf("carried section =\n%s\nwant:\n%s", result.Uncompleted, wantCarried)
+	}
+	if got := CalculateTodoStatistics(result.Journal, "2025-06-20").UncompletedTodos; got != 2 {
+		t.Errorf("statistics count %d open todos, want only the 2 in the range", got)
+	}
+
+	result, err = ProcessTodosSectionWithOptions(context.Background(), input, "2025-06-19", "2025-06-20", ProcessOptions{
+		Days: DayRange{From: "2025-01-01", To: "2025-01-31"},
+	})
+	if err != nil {
+		t.Fatalf("ProcessTodosSectionWithOptions() error: %v", err)
+	}
+	if result.Completed != input || result.Uncompleted != "" {
+		t.Errorf("processing an empty range changed the section to\n%s\ncarrying\n%s", result.Completed, result.Uncompleted)
+	}
+}
@@ -0,0 +1,154 @@
+// Package core provides the line classifier of the parser for the todoer application.
+package core
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// lineClassifier tells the todo item, bullet entry and continuation lines of a
+// todos section apart by scanning each line once. It matches exactly the lines
+// TodoItemRegex, BulletEntryRegex and ContinuationRegex, or their variations for
+// the format, match, and returns the same submatches as FindStringSubmatch, so
+// the patterns stay the reference of what is a todo: parsing big journals is
+// dominated by the lines, which regular expressions match slowly.
+type lineClassifier struct {
+	todoBullets  string   // Bullet characters of todo item lines
+	entryBullets string   // Bullet characters of bullet entry lines
+	keywords     []string // Keywords marking todos, nil for checkboxes
+	open, done   string   // Checkbox characters of open and completed todos
+	padded       bool     // Whether the checkbox character may be surrounded by spaces, as GitHub allows
+}
+
+// newLineClassifier creates a lineClassifier for the lines of format.
+func newLineClassifier(format Format) *lineClassifier {
+	c := &lineClassifier{todoBullets: "-", entryBullets: "-"}
+	if format.GFM {
+		c.entryBullets = "-*+"
+	}
+	if format.Keywords {
+		c.keywords = append(append([]string{}, openKeywords...), doneKeywords...)
+		return c
+	}
+	c.todoBullets = "-*+"
+	c.padded = true
+	c.open, c.done = format.readMarkers()
+	return c
+}
+
+// isPatternSpace reports whether c is white space as \s matches it: ASCII
+// white space other than the vertical tab.
+func isPatternSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\f' || c == '\r'
+}
+
+// leadingSpace returns the number of bytes of white space line starts with.
+func leadingSpace(line string) int {
+	n := 0
+	for n < len(line) && isPatternSpace(line[n]) {
+		n++
+	}
+	return n
+}
+
+// isLineText reports whether text is matched by (.+)$: it is not empty and holds
+// no newline.
+func isLineText(text string) bool {
+	return text != "" && strings.IndexByte(text, '\n') < 0
+}
+
+// bullet splits a line starting with one of bullets and a space into its
+// indentation and the text after the bullet.
+func bullet(line, bullets string) (string, string, bool) {
+	n := leadingSpace(line)
+	if n+1 >= len(line) || strings.IndexByte(bullets, line[n]) < 0 || line[n+1] != ' ' {
+		return "", "", false
+	}
+	return line[:n], line[n+2:], true
+}
+
+// todoItem returns the submatches of a todo item line: the line, its
+// indentation, its checkbox character or keyword and its text. It returns nil if
+// line is no todo item.
+func (c *lineClassifier) todoItem(line string) []string {
+	indent, rest, ok := bullet(line, c.todoBullets)
+	if !ok {
+		return nil
+	}
+	var marker string
+	if c.keywords != nil {
+		marker, rest, ok = c.keyword(rest)
+	} else {
+		marker, rest, ok = c.checkbox(rest)
+	}
+	if !ok || !strings.HasPrefix(rest, " ") || !isLineText(rest[1:]) {
+		return nil
+	}
+	return []string{line, indent, marker, rest[1:]}
+}
+
+// keyword splits text starting with a todo keyword into the keyword and the rest.
+func (c *lineClassifier) keyword(text string) (string, string, bool) {
+	for _, keyword := range c.keywords {
+		if strings.HasPrefix(text, keyword+" ") {
+			return keyword, text[len(keyword):], true
+		}
+	}
+	return "", "", false
+}
+
+// checkbox splits text starting with a checkbox into its character and the rest.
+func (c *lineClassifier) checkbox(text string) (string, string, bool) {
+	if !strings.HasPrefix(text, "[") {
+		return "", "", false
+	}
+	// No checkbox character is ], so the first one closes the checkbox
+	end := strings.IndexByte(text, ']')
+	if end < 0 {
+		return "", "", false
+	}
+	marker := text[1:end]
+	if c.padded {
+		// The spaces around the character are optional, so a checkbox of spaces only
+		// holds the space character
+		if trimmed := strings.Trim(marker, " "); trimmed != "" || marker == "" {
+			marker = trimmed
+		} else {
+			marker = " "
+		}
+	}
+	r, size := utf8.DecodeRuneInString(marker)
+	if size == 0 || size != len(marker) || !strings.ContainsRune(c.open+c.done, r) {
+		return "", "", false
+	}
+	return marker, text[end+1:], true
+}
+
+// bulletEntry returns the submatches of a bullet line: the line, its indentation
+// and its text. It returns nil if line is no bullet line. Todo item lines are
+// bullet lines too, so they are classified first.
+func (c *lineClassifier) bulletEntry(line string) []string {
+	indent, text, ok := bullet(line, c.entryBullets)
+	if !ok || !isLineText(text) {
+		return nil
+	}
+	return []string{line, indent, text}
+}
+
+// continuation returns the submatches of an indented line like
+// ContinuationRegex: the line, its indentation and its text. It returns nil if
+// line is not indented.
+func continuation(line string) []string {
+	n := leadingSpace(line)
+	if n == 0 {
+		return nil
+	}
+	if n == len(line) {
+		// A line of white space only leaves its last character as the text
+		n--
+	}
+	if n == 0 || !isLineText(line[n:]) {
+		return nil
+	}
+	return []string{line, line[:n], line[n:]}
+}
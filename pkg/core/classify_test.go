@@ -0,0 +1,183 @@
+package core
+
+import (
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// classifierFormats are the formats whose lines the classifier is compared on
+var classifierFormats = map[string]Format{
+	"default":  DefaultFormat,
+	"markers":  {OpenMarkers: " /", DoneMarkers: "x✓"},
+	"keywords": {Keywords: true},
+	"gfm":      {GFM: true},
+	"gfm open": {GFM: true, OpenMarkers: " ?", DoneMarkers: "x-"},
+	"both":     {GFM: true, Keywords: true},
+}
+
+// todoItemRegex returns the pattern of todo item lines the parser matched before
+// the classifier, which the classifier must match exactly.
+func todoItemRegex(f Format) *regexp.Regexp {
+	if f.Keywords {
+		keywords := append(slices.Clone(openKeywords), doneKeywords...)
+		return regexp.MustCompile(`^(\s*)- (` + strings.Join(keywords, "|") + `) (.+)$`)
+	}
+	open, done := f.readMarkers()
+	if open == UncompletedMarker && done == CompletedMarker && !f.GFM {
+		return TodoItemRegex
+	}
+	var alternatives []string
+	for _, r := range open + done {
+		alternatives = append(alternatives, regexp.QuoteMeta(string(r)))
+	}
+	if f.GFM {
+		return regexp.MustCompile(`^(\s*)[-*+] \[ *(` + strings.Join(alternatives, "|") + `) *\] (.+)$`)
+	}
+	return regexp.MustCompile(`^(\s*)- \[(` + strings.Join(alternatives, "|") + `)\] (.+)$`)
+}
+
+// todoItemFormat returns the format whose todoItemRegex the classifier matches
+// todo item lines of f with. Checkbox formats read the GitHub task list
+// variations the gfm dialect reads, see TestLineClassifierChanges.
+func todoItemFormat(f Format) Format {
+	if !f.Keywords {
+		f.GFM = true
+	}
+	return f
+}
+
+// bulletEntryRegex returns the pattern of bullet lines the parser matched before
+// the classifier.
+func bulletEntryRegex(f Format) *regexp.Regexp {
+	if f.GFM {
+		return regexp.MustCompile(`^(\s*)[-*+] (.+)$`)
+	}
+	return BulletEntryRegex
+}
+
+// checkClassifier compares the classification of line in every format with
+// the patterns.
+func checkClassifier(t *testing.T, line string) {
+	t.Helper()
+	if got, want := continuation(line), ContinuationRegex.FindStringSubmatch(line); !reflect.DeepEqual(got, want) {
+		t.Errorf("continuation(%q) = %q, want %q", line, got, want)
+	}
+	for name, format := range classifierFormats {
+		c := newLineClassifier(format)
+		if got, want := c.todoItem(line), todoItemRegex(todoItemFormat(format)).FindStringSubmatch(line); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: todoItem(%q) = %q, want %q", name, line, got, want)
+		}
+		if got, want := c.bulletEntry(line), bulletEntryRegex(format).FindStringSubmatch(line); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: bulletEntry(%q) = %q, want %q", name, line, got, want)
+		}
+	}
+}
+
+// classifierLines are lines on the edges of the patterns
+var classifierLines = []string{
+	"",
+	"-",
+	"- ",
+	"-  ",
+	"- a",
+	"  - Bullet",
+	"\t- Tab bullet",
+	"\f\r- Odd space",
+	"\v- Vertical tab",
+	"  \n- Newline in indentation",
+	"- Newline\nin text",
+	"- [ ] Task",
+	"- [x] Done",
+	"- [X] Upper done",
+	"- [/] Half done",
+	"- [✓] Check mark",
+	"- [?] Question",
+	"- [-] Cancelled",
+	"- [ ]",
+	"- [ ] ",
+	"- [ ]  Two spaces",
+	"- [ ]Task",
+	"- []  Empty",
+	"- [  ] Spaces",
+	"- [   ] More spaces",
+	"- [ x ] Padded",
+	"- [ x] Left padded",
+	"- [x  ] Right padded",
+	"- [ x y ] Two markers",
+	"- [\t] Tab marker",
+	"- [xx] Double",
+	"- [x] Text with ] bracket",
+	"- [x Unclosed",
+	"- [\xff] Invalid byte",
+	"* [ ] Star task",
+	"+ [x] Plus done",
+	"* Star bullet",
+	"+",
+	"-- Dashes",
+	"- TODO Keyword",
+	"- DONE Keyword done",
+	"- DOING Doing",
+	"- TODO",
+	"- TODO ",
+	"- TODOS Not a keyword",
+	"* TODO Star keyword",
+	"  Continuation",
+	" ",
+	"  ",
+	"\t\t",
+	" \n",
+	"\n ",
+	"   \n x",
+	"No indent",
+	"  Ünïcödé 🎉",
+	"　- Full-width space",
+}
+
+func TestLineClassifier(t *testing.T) {
+	for _, line := range classifierLines {
+		checkClassifier(t, line)
+	}
+}
+
+// TestLineClassifierChanges lists the todo item lines the classifier reads
+// unlike the patterns of their format: checkbox formats read the task list
+// variations GitHub accepts, since a todo written as "* [X] Done" or
+// "- [  ] Open" is meant as one, and reading it as a note would drop it or
+// attach it to the todo above.
+func TestLineClassifierChanges(t *testing.T) {
+	tests := []struct {
+		format string
+		line   string
+		want   []string
+	}{
+		{"default", "* [ ] Star task", []string{"* [ ] Star task", "", " ", "Star task"}},
+		{"default", "+ [x] Plus done", []string{"+ [x] Plus done", "", "x", "Plus done"}},
+		{"default", "- [ x ] Padded", []string{"- [ x ] Padded", "", "x", "Padded"}},
+		{"default", "- [x  ] Right padded", []string{"- [x  ] Right padded", "", "x", "Right padded"}},
+		{"default", "  - [  ] Spaces", []string{"  - [  ] Spaces", "  ", " ", "Spaces"}},
+		{"markers", "* [/] Star half done", []string{"* [/] Star half done", "", "/", "Star half done"}},
+		{"markers", "- [ ✓ ] Padded check mark", []string{"- [ ✓ ] Padded check mark", "", "✓", "Padded check mark"}},
+	}
+	for _, tt := range tests {
+		format := classifierFormats[tt.format]
+		if todoItemRegex(format).MatchString(tt.line) {
+			t.Errorf("%s: %q is a todo item line for the patterns already", tt.format, tt.line)
+		}
+		if got := newLineClassifier(format).todoItem(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: todoItem(%q) = %q, want %q", tt.format, tt.line, got, tt.want)
+		}
+	}
+}
+
+// FuzzLineClassifier checks that the classifier matches the lines the patterns match.
+func FuzzLineClassifier(f *testing.F) {
+	for _, line := range classifierLines {
+		f.Add(line)
+	}
+	f.Fuzz(func(t *testing.T, line string) {
+		checkClassifier(t, line)
+	})
+}
@@ -0,0 +1,79 @@
+// Package core provides time-of-day completion stamps for the todoer application.
+package core
+
+import (
+	"regexp"
+	"time"
+)
+
+const (
+	// CompletionTimeFormat is the time layout of the time of day in completion stamps
+	CompletionTimeFormat = "15:04"
+	// completionTagLayout is the time layout of DefaultDateTag tags with a time of day
+	completionTagLayout = DefaultDateTag + "T" + CompletionTimeFormat
+)
+
+var (
+	// completionTagRegex matches date tags with an optional time of day, such as
+	// "#2025-06-21" and "#2025-06-21T14:32". Captures: (date, time of day)
+	completionTagRegex = regexp.MustCompile(`#(\d{4}-\d{2}-\d{2})(?:T(\d{2}:\d{2}))?`)
+
+	// taggedDateRegex matches what ExtractTags reads as the tag of a date tag, in
+	// lower case: the date, and the hour of a time of day
+	taggedDateRegex = regexp.MustCompile(`^#\d{4}-\d{2}-\d{2}(?:t\d{2})?$`)
+)
+
+// CompletionStamp returns the date tag, without its '#', of todos completed on
+// date: the date followed by the time of day of at, e.g. "2025-06-21T14:32", if
+// at falls on date, and else the date alone, since the time of a completion on
+// another day is not known. A zero at stamps the date alone.
+func CompletionStamp(date string, at time.Time) string {
+	if at.IsZero() || at.Format(DateFormat) != date {
+		return date
+	}
+	return date + "T" + at.Format(CompletionTimeFormat)
+}
+
+// StampCompletion tags item, if it is completed and has no date tag yet, with the
+// date and time of day of at, e.g. "#2025-06-21T14:32". It is meant for the moment
+// todoer learns of a completion, such as a sync pulling it. It reports whether the
+// item was tagged.
+func StampCompletion(item *TodoItem, at time.Time) bool {
+	if item == nil || !item.Completed || HasDateTag(item.Text) {
+		return false
+	}
+	item.Text += " #" + at.Format(DateFormat+"T"+CompletionTimeFormat)
+	return true
+}
+
+// CompletionHours returns the number of completed todos per hour of the day, from
+// the completions with a time of day. Like CountCompletions, completions with the
+// same date and text are counted once.
+func CompletionHours(completions []Completion) [24]int {
+	var hours [24]int
+	seen := make(map[Completion]bool, len(completions))
+	for _, c := range completions {
+		if c.Time == "" || seen[c] {
+			continue
+		}
+		seen[c] = true
+		if t, err := time.Parse(CompletionTimeFormat, c.Time); err == nil {
+			hours[t.Hour()]++
+		}
+	}
+	return hours
+}
+
+// parseCompletionTag returns the date and the time of day, empty if there is none
+// or it is invalid, of the date tag at m, a match of completionTagRegex in text.
+func parseCompletionTag(text string, m []int) (string, string) {
+	date := text[m[2]:m[3]]
+	if m[4] < 0 {
+		return date, ""
+	}
+	clock := text[m[4]:m[5]]
+	if _, err := time.Parse(CompletionTimeFormat, clock); err != nil {
+		return date, ""
+	}
+	return date, clock
+}
@@ -0,0 +1,130 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompletionStamp(t *testing.T) {
+	at := time.Date(2025, 6, 21, 14, 32, 10, 0, time.UTC)
+	tests := []struct {
+		date string
+		at   time.Time
+		want string
+	}{
+		{"2025-06-21", at, "2025-06-21T14:32"},
+		{"2025-06-20", at, "2025-06-20"},
+		{"2025-06-21", time.Time{}, "2025-06-21"},
+	}
+	for _, tt := range tests {
+		if got := CompletionStamp(tt.date, tt.at); got != tt.want {
+			t.Errorf("CompletionStamp(%q, %v) = %q, want %q", tt.date, tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestStampCompletion(t *testing.T) {
+	at := time.Date(2025, 6, 21, 9, 5, 0, 0, time.UTC)
+	item := &TodoItem{Completed: true, Text: "Call Bob"}
+	if !StampCompletion(item, at) || item.Text != "Call Bob #2025-06-21T09:05" {
+		t.Errorf("StampCompletion() gave %q", item.Text)
+	}
+	if StampCompletion(item, at.Add(time.Hour)) {
+		t.Error("StampCompletion() stamped a todo with a date tag again")
+	}
+	if StampCompletion(&TodoItem{Text: "Open"}, at) {
+		t.Error("StampCompletion() stamped an open todo")
+	}
+}
+
+func TestCompletionTimeInDateTags(t *testing.T) {
+	tests := []struct {
+		layout string
+		text   string
+	}{
+		{"✅ 2006-01-02", "Task ✅ 2025-06-21 14:32"},
+		{"[completion:: 2006-01-02]", "Task [completion:: 2025-06-21 14:32]"},
+		{"✅ 02.01.2006", "Task ✅ 21.06.2025 14:32"},
+	}
+	for _, tt := range tests {
+		tag, err := newDateTag(tt.layout)
+		if err != nil {
+			t.Fatalf("newDateTag(%q) error: %v", tt.layout, err)
+		}
+		canonical := tag.canonical(tt.text)
+		if canonical != "Task #2025-06-21T14:32" {
+			t.Errorf("%q: canonical(%q) = %q", tt.layout, tt.text, canonical)
+		}
+		if got := tag.format(canonical); got != tt.text {
+			t.Errorf("%q: format(%q) = %q, want %q", tt.layout, canonical, got, tt.text)
+		}
+	}
+
+	tag, _ := newDateTag("✅ 2006-01-02")
+	if got := tag.canonical("Task ✅ 2025-06-21 25:00"); got != "Task #2025-06-21 25:00" {
+		t.Errorf("canonical() of an invalid time = %q, want the date alone converted", got)
+	}
+	if got := tag.format("Task #2025-06-21T25:00"); got != "Task ✅ 2025-06-21T25:00" {
+		t.Errorf("format() of an invalid time = %q, want the date alone converted", got)
+	}
+}
+
+func TestProcessStampsCompletionTime(t *testing.T) {
+	section := "- [[2025-06-21]]\n  - [x] Done\n  - [ ] Open\n    - [x] Sub"
+	at := time.Date(2025, 6, 21, 14, 32, 0, 0, time.UTC)
+	format := Format{DateTag: "✅ 2006-01-02"}
+
+	processed, err := ProcessTodosSectionWithOptions(context.Background(), section, "2025-06-21", "2025-06-22", ProcessOptions{Format: format, CompletionTime: at})
+	if err != nil {
+		t.Fatalf("ProcessTodosSectionWithOptions() error: %v", err)
+	}
+	if !strings.Contains(processed.Completed, "- [x] Done ✅ 2025-06-21 14:32") {
+		t.Errorf("Completed = %q, want the time of day stamped", processed.Completed)
+	}
+	if !strings.Contains(processed.Uncompleted, "- [x] Sub ✅ 2025-06-21 14:32") {
+		t.Errorf("Uncompleted = %q, want the subtask stamped", processed.Uncompleted)
+	}
+
+	processed, err = ProcessTodosSectionWithOptions(context.Background(), section, "2025-06-21", "2025-06-22", ProcessOptions{CompletionTime: at.AddDate(0, 0, 1)})
+	if err != nil {
+		t.Fatalf("ProcessTodosSectionWithOptions() error: %v", err)
+	}
+	if !strings.HasSuffix(processed.Completed, "- [x] Done #2025-06-21") {
+		t.Errorf("Completed = %q, want the date alone on a later day", processed.Completed)
+	}
+}
+
+func TestCollectCompletionTimes(t *testing.T) {
+	journal, err := ParseTodosSection("- [[2025-06-21]]\n  - [x] Early #2025-06-21T08:15\n  - [x] Late #2025-06-21T17:40\n  - [x] Undated\n  - [x] Bad #2025-06-21T26:00")
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error: %v", err)
+	}
+	completions := CollectCompletions(journal, "2025-06-21")
+	var times []string
+	for _, c := range completions {
+		times = append(times, c.Date+" "+c.Time)
+	}
+	if want := []string{"2025-06-21 08:15", "2025-06-21 17:40", "2025-06-21 ", "2025-06-21 "}; !reflect.DeepEqual(times, want) {
+		t.Errorf("completion times = %q, want %q", times, want)
+	}
+
+	hours := CompletionHours(append(completions, completions[0]))
+	var want [24]int
+	want[8], want[17] = 1, 1
+	if hours != want {
+		t.Errorf("CompletionHours() = %v, want one at 8 and one at 17", hours)
+	}
+}
+
+func TestCompletionTimeIsNoTag(t *testing.T) {
+	text := "Ship it #work #2025-06-21T14:32"
+	if got := ExtractTags(text); !reflect.DeepEqual(got, []string{"#work"}) {
+		t.Errorf("ExtractTags(%q) = %q, want only #work", text, got)
+	}
+	if got := StripDateTags(text); got != "Ship it #work" {
+		t.Errorf("StripDateTags(%q) = %q", text, got)
+	}
+}
@@ -0,0 +1,151 @@
+// Package core provides task creation dates for the todoer application.
+package core
+
+import (
+	"regexp"
+	"sort"
+	"time"
+)
+
+// CreatedKey is the metadata key of the date a task was written down, as in
+// created::[[2025-06-18]] or ➕ 2025-06-18
+const CreatedKey = "created"
+
+// ExtractCreated returns the created date a todo text is annotated with, as
+// created::[[2025-06-18]] or ➕ 2025-06-18, or an empty string.
+func ExtractCreated(text string) string {
+	created := annotationValue(text, CreatedKey)
+	if _, err := time.Parse(DateFormat, created); err != nil {
+		return ""
+	}
+	return created
+}
+
+// CreatedDate returns the date item was first written down, date being the
+// date it counts as written down on without annotations, see CreatedDates: the
+// date of its created annotation, else of its carried-from annotation, else date.
+func CreatedDate(item *TodoItem, date string) string {
+	if item == nil {
+		return date
+	}
+	if created := ExtractCreated(item.Text); created != "" {
+		return created
+	}
+	if from := ExtractCarriedFrom(item.Text); from != "" {
+		return from
+	}
+	return date
+}
+
+// CreatedDates returns the CreatedDate of every item of journal, date being the
+// date of the journal. Todos are stamped with a created annotation when they are
+// carried, moved or postponed, see StampCreated, so an unstamped todo counts as
+// written down on the date of its journal, or on that of its day header if it is
+// earlier, which is where todos carried before they were stamped stay. A later
+// day header is one the todo was postponed to. Subitems have the created date of
+// their parent unless annotated with their own.
+func CreatedDates(journal *TodoJournal, date string) map[*TodoItem]string {
+	created := make(map[*TodoItem]string)
+	var walk func(items []*TodoItem, parent string)
+	walk = func(items []*TodoItem, parent string) {
+		for _, item := range items {
+			if item == nil {
+				continue
+			}
+			created[item] = CreatedDate(item, parent)
+			walk(item.SubItems, created[item])
+		}
+	}
+	if journal != nil {
+		for _, day := range journal.Days {
+			if day == nil {
+				continue
+			}
+			written := date
+			if day.Date != "" && (written == "" || day.Date < written) {
+				written = day.Date
+			}
+			walk(day.Items, written)
+		}
+	}
+	return created
+}
+
+// trailingAnnotations are the annotations that have to end a todo text
+var trailingAnnotations = []*regexp.Regexp{CarriedFromRegex, PostponedToRegex, MovedFromRegex}
+
+// StampCreated annotates item with created as created::[[2025-06-18]], unless it
+// already carries a created annotation. The annotation goes before a carried-from,
+// postponed-to or moved-from annotation, which has to end the text.
+func StampCreated(item *TodoItem, created string) {
+	if item == nil || created == "" || annotationValue(item.Text, CreatedKey) != "" {
+		return
+	}
+	annotation := FormatAnnotation(CreatedKey, created)
+	for _, trailing := range trailingAnnotations {
+		if loc := trailing.FindStringIndex(item.Text); loc != nil {
+			item.Text = item.Text[:loc[0]] + " " + annotation + item.Text[loc[0]:]
+			item.Meta = ParseMeta(item.Text)
+			return
+		}
+	}
+	item.Text += " " + annotation
+	item.Meta = ParseMeta(item.Text)
+}
+
+// AnnotateCreated returns a copy of journal with its top-level todos stamped with
+// their created date, see CreatedDates and StampCreated, date being the date of
+// the journal.
+func AnnotateCreated(journal *TodoJournal, date string) *TodoJournal {
+	result := &TodoJournal{Days: []*DaySection{}}
+	if journal == nil {
+		return result
+	}
+	created := CreatedDates(journal, date)
+	for _, day := range journal.Days {
+		if day == nil {
+			continue
+		}
+		annotated := &DaySection{Date: day.Date, Items: make([]*TodoItem, 0, len(day.Items))}
+		for _, item := range day.Items {
+			copied := DeepCopyItem(item)
+			StampCreated(copied, created[item])
+			annotated.Items = append(annotated.Items, copied)
+		}
+		result.Days = append(result.Days, annotated)
+	}
+	return result
+}
+
+// DaysToComplete returns the median number of days from the creation to the
+// completion of completions, and the number of completions it is taken over:
+// those with a created date no later than their completion date. Completions with
+// the same date and text are counted once, see CountCompletions.
+func DaysToComplete(completions []Completion) (float64, int) {
+	seen := make(map[Completion]bool, len(completions))
+	var days []int
+	for _, c := range completions {
+		if seen[c] {
+			continue
+		}
+		seen[c] = true
+		created, err := time.Parse(DateFormat, c.Created)
+		if err != nil {
+			continue
+		}
+		completed, err := time.Parse(DateFormat, c.Date)
+		if err != nil || completed.Before(created) {
+			continue
+		}
+		days = append(days, int(completed.Sub(created).Hours()/24))
+	}
+	if len(days) == 0 {
+		return 0, 0
+	}
+	sort.Ints(days)
+	mid := len(days) / 2
+	if len(days)%2 == 0 {
+		return float64(days[mid-1]+days[mid]) / 2, len(days)
+	}
+	return float64(days[mid]), len(days)
+}
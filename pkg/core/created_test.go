@@ -0,0 +1,115 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestCreatedDate(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Pay rent", "2025-06-18"},
+		{"Pay rent (from [[2025-06-16]])", "2025-06-16"},
+		{"Pay rent created::[[2025-06-10]] (from [[2025-06-16]])", "2025-06-10"},
+		{"Pay rent ➕ 2025-06-12", "2025-06-12"},
+		{"Pay rent created::[[soon]]", "2025-06-18"},
+	}
+	for _, tt := range tests {
+		if got := CreatedDate(&TodoItem{Text: tt.text}, "2025-06-18"); got != tt.want {
+			t.Errorf("CreatedDate(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestAnnotateCreated(t *testing.T) {
+	journal, err := ParseTodosSection(`- [[2025-06-18]]
+  - [x] Pay rent #2025-06-20
+    - [x] Find account #2025-06-19
+  - [ ] Call bank (from [[2025-06-16]])
+  - [ ] Book trip ➕ 2025-06-12`)
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error: %v", err)
+	}
+
+	want := `- [[2025-06-18]]
+  - [x] Pay rent #2025-06-20 created::[[2025-06-18]]
+    - [x] Find account #2025-06-19
+  - [ ] Call bank created::[[2025-06-16]] (from [[2025-06-16]])
+  - [ ] Book trip ➕ 2025-06-12`
+	if got := JournalToString(AnnotateCreated(journal, "2025-06-18")); got != want {
+		t.Errorf("AnnotateCreated() =\n%s\nwant:\n%s", got, want)
+	}
+	if got := journal.Days[0].Items[0].Text; got != "Pay rent #2025-06-20" {
+		t.Errorf("AnnotateCreated() changed the journal: %q", got)
+	}
+}
+
+func TestCreatedDates(t *testing.T) {
+	// The day header of a postponed todo is later than the journal it is in
+	journal, err := ParseTodosSection(`- [[2025-06-22]]
+  - [ ] Pay rent
+    - [ ] Find account
+    - [ ] Ask landlord created::[[2025-06-15]]
+  - [ ] Call bank created::[[2025-06-16]]
+    - [ ] Find number`)
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error: %v", err)
+	}
+
+	created := CreatedDates(journal, "2025-06-21")
+	items := journal.Days[0].Items
+	for item, want := range map[*TodoItem]string{
+		items[0]:             "2025-06-21",
+		items[0].SubItems[0]: "2025-06-21",
+		items[0].SubItems[1]: "2025-06-15",
+		items[1]:             "2025-06-16",
+		items[1].SubItems[0]: "2025-06-16",
+	} {
+		if created[item] != want {
+			t.Errorf("CreatedDates()[%q] = %q, want %q", item.Text, created[item], want)
+		}
+	}
+}
+
+func TestStampCreated(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Pay rent", "Pay rent created::[[2025-06-18]]"},
+		{"Pay rent (from [[2025-06-16]])", "Pay rent created::[[2025-06-18]] (from [[2025-06-16]])"},
+		{"Pay rent (postponed to [[2025-06-20]])", "Pay rent created::[[2025-06-18]] (postponed to [[2025-06-20]])"},
+		{"Pay rent created::[[2025-06-10]]", "Pay rent created::[[2025-06-10]]"},
+		{"Pay rent ➕ 2025-06-12", "Pay rent ➕ 2025-06-12"},
+	}
+	for _, tt := range tests {
+		item := &TodoItem{Text: tt.text, Meta: ParseMeta(tt.text)}
+		StampCreated(item, "2025-06-18")
+		if item.Text != tt.want {
+			t.Errorf("StampCreated(%q) = %q, want %q", tt.text, item.Text, tt.want)
+		}
+		if item.MetaValue(CreatedKey) == "" {
+			t.Errorf("StampCreated(%q) left no created metadata", tt.text)
+		}
+	}
+}
+
+func TestDaysToComplete(t *testing.T) {
+	completions := []Completion{
+		{Date: "2025-06-18", Text: "A", Created: "2025-06-18"},
+		{Date: "2025-06-20", Text: "B", Created: "2025-06-17"},
+		{Date: "2025-06-20", Text: "B", Created: "2025-06-17"},
+		{Date: "2025-06-21", Text: "C", Created: "2025-06-20"},
+		{Date: "2025-06-22", Text: "D", Created: "2025-06-12"},
+		{Date: "2025-06-22", Text: "E"},
+		{Date: "2025-06-10", Text: "F", Created: "2025-06-11"},
+	}
+	median, n := DaysToComplete(completions)
+	if median != 2 || n != 4 {
+		t.Errorf("DaysToComplete() = %v, %d, want 2, 4", median, n)
+	}
+	if median, n := DaysToComplete(nil); median != 0 || n != 0 {
+		t.Errorf("DaysToComplete(nil) = %v, %d, want 0, 0", median, n)
+	}
+}
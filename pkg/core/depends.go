@@ -0,0 +1,199 @@
+// Package core provides task dependency tracking for the todoer application.
+package core
+
+import (
+	"regexp"
+)
+
+var (
+	// TaskIDRegex matches a task id annotation such as id::write-spec
+	// Captures: (id)
+	//
+	// Deprecated: task ids are parsed with the other annotations, see ParseAnnotations.
+	TaskIDRegex = regexp.MustCompile(`(?:^|\s)id::([\w-]+)`)
+
+	// DependsRegex matches a dependency annotation such as depends::[[write-spec]]
+	// or blocked-by::[[write-spec]]. The single colon forms are accepted as well.
+	// Captures: (id)
+	//
+	// Deprecated: dependencies are parsed with the other annotations, see ParseAnnotations.
+	DependsRegex = regexp.MustCompile(`(?:depends|blocked-by)::?\[\[([^\[\]]+)\]\]`)
+)
+
+// BlockedHeader is the heading under which carried tasks waiting for an open task are
+// written, below the other todos of the section
+const BlockedHeader = "#### Blocked"
+
+// ExtractTaskID returns the id a todo text is annotated with, or an empty string.
+func ExtractTaskID(text string) string {
+	return annotationValue(text, "id")
+}
+
+// ExtractDependencies returns the ids of the tasks a todo text depends on, in order.
+func ExtractDependencies(text string) []string {
+	return annotationValues(text, "depends", "blocked-by")
+}
+
+// StripDependencyAnnotations removes id and dependency annotations from a todo text.
+func StripDependencyAnnotations(text string) string {
+	return removeAnnotations(text, "id", "depends", "blocked-by")
+}
+
+// DependencyViolation is a completed task that depends on a task that is still open.
+type DependencyViolation struct {
+	Task    string // Text of the completed task
+	Blocker string // Id of the open task it depends on
+}
+
+// DependencyTask is a node of a DependencyGraph.
+type DependencyTask struct {
+	ID      string // Task id, empty if the task has none
+	Text    string // Task text, or the id for a missing task
+	Missing bool   // The id is depended on but no task in the journal has it
+}
+
+// DependencyEdge links a blocking task to a task that depends on it, as indexes
+// into DependencyGraph.Tasks.
+type DependencyEdge struct {
+	From int // The blocking task
+	To   int // The dependent task
+}
+
+// DependencyGraph holds the dependencies between the open tasks of a journal.
+type DependencyGraph struct {
+	Tasks []DependencyTask
+	Edges []DependencyEdge
+}
+
+// indexTasks returns the items of journal at any depth that carry a task id, by id.
+// If several items have the same id, the first one wins.
+func indexTasks(journal *TodoJournal) map[string]*TodoItem {
+	byID := make(map[string]*TodoItem)
+	for _, loc := range FindItems(journal, func(item *TodoItem) bool { return ExtractTaskID(item.Text) != "" }) {
+		id := ExtractTaskID(loc.Item.Text)
+		if _, ok := byID[id]; !ok {
+			byID[id] = loc.Item
+		}
+	}
+	return byID
+}
+
+// FindDependencyViolations returns the completed tasks of journal, at any depth, that
+// depend on a task of the journal that is still open. Dependencies on ids that no
+// task in the journal carries are ignored, since the task may live elsewhere.
+func FindDependencyViolations(journal *TodoJournal) []DependencyViolation {
+	byID := indexTasks(journal)
+
+	var violations []DependencyViolation
+	for _, loc := range FindItems(journal, func(item *TodoItem) bool { return item.Completed }) {
+		for _, id := range ExtractDependencies(loc.Item.Text) {
+			if blocker, ok := byID[id]; ok && !blocker.Completed {
+				violations = append(violations, DependencyViolation{Task: loc.Item.Text, Blocker: id})
+			}
+		}
+	}
+	return violations
+}
+
+// IsBlocked reports whether item depends on a task of journal that is still open.
+// Dependencies on ids that no task in the journal carries do not block.
+func IsBlocked(journal *TodoJournal, item *TodoItem) bool {
+	return isBlocked(indexTasks(journal), item)
+}
+
+// isBlocked is IsBlocked with the tasks of the journal indexed by id.
+func isBlocked(byID map[string]*TodoItem, item *TodoItem) bool {
+	if item == nil {
+		return false
+	}
+	for _, id := range ExtractDependencies(item.Text) {
+		if blocker, ok := byID[id]; ok && blocker != item && !blocker.Completed {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitBlocked splits the top-level items of journal into those that can be worked
+// on and those blocked by an open task of journal, see IsBlocked. Both journals keep
+// the days and item order of journal and share its items.
+func SplitBlocked(journal *TodoJournal) (*TodoJournal, *TodoJournal) {
+	ready := &TodoJournal{Days: []*DaySection{}}
+	blocked := &TodoJournal{Days: []*DaySection{}}
+	if journal == nil {
+		return ready, blocked
+	}
+
+	byID := indexTasks(journal)
+	for _, day := range journal.Days {
+		if day == nil {
+			continue
+		}
+		var readyDay, blockedDay *DaySection
+		for _, item := range day.Items {
+			if item == nil {
+				continue
+			}
+			if isBlocked(byID, item) {
+				if blockedDay == nil {
+					blockedDay = &DaySection{Date: day.Date, Items: []*TodoItem{}}
+					blocked.Days = append(blocked.Days, blockedDay)
+				}
+				blockedDay.Items = append(blockedDay.Items, item)
+			} else {
+				if readyDay == nil {
+					readyDay = &DaySection{Date: day.Date, Items: []*TodoItem{}}
+					ready.Days = append(ready.Days, readyDay)
+				}
+				readyDay.Items = append(readyDay.Items, item)
+			}
+		}
+	}
+	return ready, blocked
+}
+
+// BuildDependencyGraph returns the dependencies between the open tasks of journal.
+// Open tasks with an id or a dependency become nodes, in document order; ids that
+// are depended on but carried by no task are added as missing nodes. Dependencies
+// on completed tasks are satisfied and left out.
+func BuildDependencyGraph(journal *TodoJournal) DependencyGraph {
+	byID := indexTasks(journal)
+
+	var graph DependencyGraph
+	nodes := make(map[string]int)
+	node := func(id string, task DependencyTask) int {
+		if i, ok := nodes[id]; ok && id != "" {
+			return i
+		}
+		graph.Tasks = append(graph.Tasks, task)
+		if id != "" {
+			nodes[id] = len(graph.Tasks) - 1
+		}
+		return len(graph.Tasks) - 1
+	}
+
+	open := FindItems(journal, func(item *TodoItem) bool {
+		return !item.Completed && (ExtractTaskID(item.Text) != "" || len(ExtractDependencies(item.Text)) > 0)
+	})
+	for _, loc := range open {
+		id := ExtractTaskID(loc.Item.Text)
+		if id != "" && byID[id] != loc.Item {
+			id = "" // A duplicate id; only the first task is linked
+		}
+		node(id, DependencyTask{ID: id, Text: loc.Item.Text})
+	}
+
+	// The open tasks are the first nodes, so a task's node is its index in open
+	for to, loc := range open {
+		for _, dep := range ExtractDependencies(loc.Item.Text) {
+			blocker, ok := byID[dep]
+			if ok && blocker.Completed {
+				continue
+			}
+			from := node(dep, DependencyTask{ID: dep, Text: dep, Missing: !ok})
+			graph.Edges = append(graph.Edges, DependencyEdge{From: from, To: to})
+		}
+	}
+
+	return graph
+}
@@ -0,0 +1,129 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractDependencies(t *testing.T) {
+	text := "Deploy id::deploy depends::[[build]] depends:[[review]] depends::[[ ]]"
+	if got := ExtractTaskID(text); got != "deploy" {
+		t.Errorf("ExtractTaskID() = %q, want deploy", got)
+	}
+	if got, want := ExtractDependencies(text), []string{"build", "review"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractDependencies() = %v, want %v", got, want)
+	}
+	if got := StripDependencyAnnotations(text); got != "Deploy" {
+		t.Errorf("StripDependencyAnnotations() = %q", got)
+	}
+	if got := ExtractTaskID("Mid-word grid::x is no id"); got != "" {
+		t.Errorf("ExtractTaskID() = %q, want none", got)
+	}
+}
+
+func TestFindDependencyViolations(t *testing.T) {
+	journal, err := ParseTodosSection(`- [[2025-06-20]]
+  - [ ] Build id::build
+  - [x] Deploy depends::[[build]] #2025-06-20
+  - [x] Announce depends::[[deploy]] depends::[[elsewhere]]
+  - [x] Review id::review
+    - [x] Merge depends::[[review]]`)
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error: %v", err)
+	}
+
+	got := FindDependencyViolations(journal)
+	want := []DependencyViolation{{Task: "Deploy depends::[[build]] #2025-06-20", Blocker: "build"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindDependencyViolations() = %+v, want %+v", got, want)
+	}
+}
+
+func TestBuildDependencyGraph(t *testing.T) {
+	journal, err := ParseTodosSection(`- [[2025-06-20]]
+  - [ ] Build id::build
+  - [ ] Deploy id::deploy depends::[[build]] depends::[[review]]
+  - [ ] Announce depends::[[deploy]] depends::[[budget]]
+  - [x] Review id::review
+  - [ ] Unrelated`)
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error: %v", err)
+	}
+
+	got := BuildDependencyGraph(journal)
+	want := DependencyGraph{
+		Tasks: []DependencyTask{
+			{ID: "build", Text: "Build id::build"},
+			{ID: "deploy", Text: "Deploy id::deploy depends::[[build]] depends::[[review]]"},
+			{Text: "Announce depends::[[deploy]] depends::[[budget]]"},
+			{ID: "budget", Text: "budget", Missing: true},
+		},
+		Edges: []DependencyEdge{{From: 0, To: 1}, {From: 1, To: 2}, {From: 3, To: 2}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BuildDependencyGraph() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSplitBlocked(t *testing.T) {
+	journal, err := ParseTodosSection(`- [[2025-06-19]]
+  - [ ] Deploy blocked-by::[[build]]
+  - [ ] Write docs depends::[[review]]
+- [[2025-06-20]]
+  - [ ] Build id::build
+    - [x] Compile id::compile
+  - [ ] Package depends::[[compile]] depends::[[budget]]`)
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error: %v", err)
+	}
+
+	ready, blocked := SplitBlocked(journal)
+	wantReady := "- [[2025-06-19]]\n  - [ ] Write docs depends::[[review]]\n- [[2025-06-20]]\n  - [ ] Build id::build\n    - [x] Compile id::compile\n  - [ ] Package depends::[[compile]] depends::[[budget]]"
+	if got := JournalToString(ready); got != wantReady {
+		t.Errorf("ready =\n%s\nwant\n%s", got, wantReady)
+	}
+	if got, want := JournalToString(blocked), "- [[2025-06-19]]\n  - [ ] Deploy blocked-by::[[build]]"; got != want {
+		t.Errorf("blocked =\n%s\nwant\n%s", got, want)
+	}
+	if !IsBlocked(journal, journal.Days[0].Items[0]) || IsBlocked(journal, journal.Days[1].Items[0]) {
+		t.Error("IsBlocked() reports the wrong tasks")
+	}
+}
+
+func TestProcessTodosSection_Blocked(t *testing.T) {
+	section := `- [[2025-06-20]]
+  - [ ] Deploy blocked-by::[[build]]
+  - [ ] Build id::build
+  - [ ] Announce depends::[[deploy]]
+  - [x] Review id::review`
+
+	result, err := ProcessTodosSectionWithOptions(context.Background(), section, "2025-06-20", "2025-06-21", ProcessOptions{Format: DefaultFormat})
+	if err != nil {
+		t.Fatalf("ProcessTodosSectionWithOptions() error: %v", err)
+	}
+	want := `- [[2025-06-20]]
+  - [ ] Build id::build created::[[2025-06-20]]
+  - [ ] Announce depends::[[deploy]] created::[[2025-06-20]]
+
+#### Blocked
+- [[2025-06-20]]
+  - [ ] Deploy blocked-by::[[build]] created::[[2025-06-20]]`
+	if result.Uncompleted != want {
+		t.Errorf("Uncompleted =\n%s\nwant\n%s", result.Uncompleted, want)
+	}
+
+	// The blocked tasks are read back into their day and moved out once unblocked
+	reparsed, err := ParseTodosSection(strings.Replace(result.Uncompleted, "[ ] Build", "[x] Build", 1))
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error: %v", err)
+	}
+	if len(reparsed.Days) != 2 || reparsed.Days[1].Date != "2025-06-20" || reparsed.Days[1].Items[0].Text != "Deploy blocked-by::[[build]] created::[[2025-06-20]]" {
+		t.Fatalf("blocked tasks parsed as %+v", reparsed.Days)
+	}
+	ready, blocked := SplitBlocked(reparsed)
+	if !blocked.IsEmpty() || len(ready.Days) != 2 {
+		t.Errorf("SplitBlocked() after completing the blocker = %d ready, %d blocked days", len(ready.Days), len(blocked.Days))
+	}
+}
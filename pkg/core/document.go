@@ -0,0 +1,208 @@
+// Package core provides section-aware editing of journal files for the todoer application.
+package core
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// frontmatterBlockRegex matches the frontmatter at the start of a journal file
+var frontmatterBlockRegex = regexp.MustCompile(`\A---[ \t]*\r?\n(?s:.*?\r?\n)?---[ \t]*(?:\r?\n|\z)`)
+
+// Document is the content of a journal file split into its frontmatter and its
+// "## " sections, so content can be read and inserted by section instead of by
+// string surgery in every caller. String joins the parts again and returns the
+// parsed content unchanged as long as no part was changed.
+type Document struct {
+	Frontmatter string     // Frontmatter block including its --- lines, empty if there is none
+	Preamble    string     // Content between the frontmatter and the first section
+	Sections    []*Section // Sections in file order
+}
+
+// Section is a "## " section of a Document. Deeper headers belong to the section
+// they appear in, and lines inside fenced code blocks never start a section.
+type Section struct {
+	Header string // Header line without its line break, e.g. "## Notes"
+	Body   string // Lines after the header up to the next section, with their line breaks
+	eol    string // Line break of the header line, empty at the end of the file
+}
+
+// ParseDocument splits the content of a journal file into a Document.
+func ParseDocument(content string) *Document {
+	doc := &Document{}
+	if m := frontmatterBlockRegex.FindStringIndex(content); m != nil {
+		doc.Frontmatter = content[:m[1]]
+		content = content[m[1]:]
+	}
+
+	var current *Section
+	start, fence := 0, ""
+	setBody := func(end int) {
+		if current == nil {
+			doc.Preamble = content[start:end]
+		} else {
+			current.Body = content[start:end]
+		}
+	}
+	for offset := 0; offset < len(content); {
+		next := len(content)
+		if i := strings.IndexByte(content[offset:], '\n'); i >= 0 {
+			next = offset + i + 1
+		}
+		line := strings.TrimRight(content[offset:next], "\r\n")
+
+		switch marker := strings.TrimLeft(line, " "); {
+		case fence != "":
+			if strings.HasPrefix(marker, fence) {
+				fence = ""
+			}
+		case strings.HasPrefix(marker, "```"), strings.HasPrefix(marker, "~~~"):
+			fence = marker[:3]
+		case strings.HasPrefix(line, "## "):
+			setBody(offset)
+			current = &Section{Header: line, eol: content[offset+len(line) : next]}
+			doc.Sections = append(doc.Sections, current)
+			start = next
+		}
+		offset = next
+	}
+	setBody(len(content))
+	return doc
+}
+
+// String returns the content of the document.
+func (d *Document) String() string {
+	var b strings.Builder
+	b.WriteString(d.Frontmatter)
+	b.WriteString(d.Preamble)
+	for _, s := range d.Sections {
+		b.WriteString(s.Header)
+		b.WriteString(s.eol)
+		b.WriteString(s.Body)
+	}
+	return b.String()
+}
+
+// Section returns the first section whose header is header, ignoring trailing
+// white space, or nil if there is none.
+func (d *Document) Section(header string) *Section {
+	if i := d.sectionIndex(header); i >= 0 {
+		return d.Sections[i]
+	}
+	return nil
+}
+
+// sectionIndex returns the index of the first section with header, or -1.
+func (d *Document) sectionIndex(header string) int {
+	header = strings.TrimRight(header, " \t")
+	for i, s := range d.Sections {
+		if strings.TrimRight(s.Header, " \t") == header {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddSection appends a new, empty section with header to the end of the document,
+// separated from the content before it by a blank line, and returns it. Like the
+// TODOS section of a journal, its header is followed by a blank line.
+func (d *Document) AddSection(header string) *Section {
+	switch {
+	case len(d.Sections) > 0:
+		last := d.Sections[len(d.Sections)-1]
+		last.eol = "\n"
+		last.Body = strings.TrimRight(last.Body, "\n") + "\n"
+		if last.Body != "\n" {
+			last.Body += "\n"
+		}
+	case strings.TrimRight(d.Preamble, "\n") != "":
+		d.Preamble = strings.TrimRight(d.Preamble, "\n") + "\n\n"
+	case d.Frontmatter != "":
+		if !strings.HasSuffix(d.Frontmatter, "\n") {
+			d.Frontmatter += "\n"
+		}
+		d.Preamble = "\n"
+	default:
+		d.Preamble = ""
+	}
+
+	s := &Section{Header: header, Body: "\n", eol: "\n"}
+	d.Sections = append(d.Sections, s)
+	return s
+}
+
+// AppendToSection adds text as lines at the end of the body of the section with
+// header, before the blank lines separating it from the next section. The section
+// is added with AddSection if the document has none with header.
+func (d *Document) AppendToSection(header, text string) *Section {
+	i := d.sectionIndex(header)
+	if i < 0 {
+		d.AddSection(header)
+		i = len(d.Sections) - 1
+	}
+	s := d.Sections[i]
+	s.eol = "\n"
+	text = strings.TrimRight(text, "\n") + "\n"
+
+	content := strings.TrimRight(s.Body, "\n")
+	if strings.TrimSpace(content) == "" {
+		s.Body = "\n" + text
+		if i < len(d.Sections)-1 {
+			s.Body += "\n"
+		}
+		return s
+	}
+	s.Body = content + "\n" + text + strings.TrimPrefix(s.Body[len(content):], "\n")
+	return s
+}
+
+// RemoveSection removes the first section with header from the document and
+// returns it, or nil if there is none.
+func (d *Document) RemoveSection(header string) *Section {
+	i := d.sectionIndex(header)
+	if i < 0 {
+		return nil
+	}
+	s := d.Sections[i]
+	d.Sections = append(d.Sections[:i], d.Sections[i+1:]...)
+	return s
+}
+
+// frontmatterKeyRegex returns a regex matching the line of key in a frontmatter.
+// Captures: (value)
+func frontmatterKeyRegex(key string) *regexp.Regexp {
+	key = regexp.QuoteMeta(strings.ToValidUTF8(key, string(utf8.RuneError)))
+	return regexp.MustCompile(`(?m)^` + key + `:[ \t]*([^\r\n]*)`)
+}
+
+// FrontmatterValue returns the value of key in the frontmatter, without
+// surrounding quotes, and whether the frontmatter holds key.
+func (d *Document) FrontmatterValue(key string) (string, bool) {
+	m := frontmatterKeyRegex(key).FindStringSubmatch(d.Frontmatter)
+	if m == nil {
+		return "", false
+	}
+	value := strings.TrimSpace(m[1])
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		value = value[1 : len(value)-1]
+	}
+	return value, true
+}
+
+// SetFrontmatterValue sets key in the frontmatter to value, replacing the line of
+// key or adding one at the end of the frontmatter. A document without frontmatter
+// gets one.
+func (d *Document) SetFrontmatterValue(key, value string) {
+	line := key + ": " + value
+	if d.Frontmatter == "" {
+		d.Frontmatter = "---\n" + line + "\n---\n"
+		return
+	}
+	if m := frontmatterKeyRegex(key).FindStringIndex(d.Frontmatter); m != nil {
+		d.Frontmatter = d.Frontmatter[:m[0]] + line + d.Frontmatter[m[1]:]
+		return
+	}
+	closing := strings.LastIndex(d.Frontmatter, "---")
+	d.Frontmatter = d.Frontmatter[:closing] + line + "\n" + d.Frontmatter[closing:]
+}
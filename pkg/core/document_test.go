@@ -0,0 +1,136 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestParseDocument(t *testing.T) {
+	content := "---\r\ntitle: 2025-06-21\r\n---\r\n# Saturday\n\n## Todos\n\n- [[2025-06-21]]\n  - [ ] Call Bob\n\n## Notes   \n### Meeting\n```\n## not a section\n```\n~~~md\n## nor this\n~~~\nEnd"
+
+	doc := ParseDocument(content)
+	if doc.Frontmatter != "---\r\ntitle: 2025-06-21\r\n---\r\n" {
+		t.Errorf("Frontmatter = %q", doc.Frontmatter)
+	}
+	if doc.Preamble != "# Saturday\n\n" {
+		t.Errorf("Preamble = %q", doc.Preamble)
+	}
+	if len(doc.Sections) != 2 {
+		t.Fatalf("got %d sections, want 2", len(doc.Sections))
+	}
+	if s := doc.Section("## Todos"); s == nil || s.Body != "\n- [[2025-06-21]]\n  - [ ] Call Bob\n\n" {
+		t.Errorf("Section(\"## Todos\") = %+v", s)
+	}
+	if s := doc.Section("## Notes"); s == nil || s.Header != "## Notes   " || s.Body != "### Meeting\n```\n## not a section\n```\n~~~md\n## nor this\n~~~\nEnd" {
+		t.Errorf("Section(\"## Notes\") = %+v", s)
+	}
+	if s := doc.Section("## Missing"); s != nil {
+		t.Errorf("Section(\"## Missing\") = %+v, want nil", s)
+	}
+	if got := doc.String(); got != content {
+		t.Errorf("String() = %q, want the parsed content", got)
+	}
+
+	for _, content := range []string{"", "no sections\n", "## Only\n", "## Header at the end", "---\ntitle: x\n---", "---\nnot closed\n## Todos\n"} {
+		if got := ParseDocument(content).String(); got != content {
+			t.Errorf("ParseDocument(%q).String() = %q", content, got)
+		}
+	}
+	if doc := ParseDocument("---\nnot closed\n## Todos\n"); doc.Frontmatter != "" || len(doc.Sections) != 1 {
+		t.Errorf("unclosed frontmatter parsed as %+v", doc)
+	}
+}
+
+func TestDocumentAddSection(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"empty", "", "## Todos\n\n"},
+		{"preamble", "# Backlog\n\n\n", "# Backlog\n\n## Todos\n\n"},
+		{"preamble without line break", "# Backlog", "# Backlog\n\n## Todos\n\n"},
+		{"frontmatter", "---\ntitle: x\n---\n", "---\ntitle: x\n---\n\n## Todos\n\n"},
+		{"frontmatter at the end", "---\ntitle: x\n---", "---\ntitle: x\n---\n\n## Todos\n\n"},
+		{"section", "## Notes\n\nText", "## Notes\n\nText\n\n## Todos\n\n"},
+		{"empty section", "## Notes", "## Notes\n\n## Todos\n\n"},
+	}
+	for _, tt := range tests {
+		doc := ParseDocument(tt.content)
+		doc.AddSection("## Todos")
+		if got := doc.String(); got != tt.want {
+			t.Errorf("%s: AddSection() gave %q, want %q", tt.name, got, tt.want)
+		}
+		if doc := ParseDocument(tt.want); doc.Section("## Todos") == nil {
+			t.Errorf("%s: added section not found again", tt.name)
+		}
+	}
+}
+
+func TestDocumentAppendToSection(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		header  string
+		want    string
+	}{
+		{"last section", "## Notes\n\nFirst\n", "## Notes", "## Notes\n\nFirst\n- [[2025-06-21]]\n"},
+		{"before next section", "## Notes\n\nFirst\n\n## Todos\n\n", "## Notes", "## Notes\n\nFirst\n- [[2025-06-21]]\n\n## Todos\n\n"},
+		{"empty section", "## Notes\n\n## Todos\n\n", "## Notes", "## Notes\n\n- [[2025-06-21]]\n\n## Todos\n\n"},
+		{"header at the end", "## Notes", "## Notes", "## Notes\n\n- [[2025-06-21]]\n"},
+		{"no line break at the end", "## Notes\n\nFirst", "## Notes", "## Notes\n\nFirst\n- [[2025-06-21]]\n"},
+		{"missing section", "# Day\n", "## Links", "# Day\n\n## Links\n\n- [[2025-06-21]]\n"},
+	}
+	for _, tt := range tests {
+		doc := ParseDocument(tt.content)
+		doc.AppendToSection(tt.header, "- [[2025-06-21]]\n")
+		if got := doc.String(); got != tt.want {
+			t.Errorf("%s: AppendToSection() gave %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDocumentRemoveSection(t *testing.T) {
+	doc := ParseDocument("# Day\n\n## Todos\n\n- [ ] Task\n\n## Archive\n\nOld\n")
+	removed := doc.RemoveSection("## Todos")
+	if removed == nil || removed.Body != "\n- [ ] Task\n\n" {
+		t.Fatalf("RemoveSection() = %+v", removed)
+	}
+	if got, want := doc.String(), "# Day\n\n## Archive\n\nOld\n"; got != want {
+		t.Errorf("String() after RemoveSection() = %q, want %q", got, want)
+	}
+	if doc.RemoveSection("## Todos") != nil {
+		t.Error("RemoveSection() of a removed section returned it again")
+	}
+}
+
+func TestDocumentFrontmatter(t *testing.T) {
+	doc := ParseDocument("---\ntitle: 2025-06-21\nauthor: \"Ann Lee\"\ntags:\n---\n\n## Todos\n\n")
+	tests := []struct {
+		key   string
+		value string
+		found bool
+	}{
+		{"title", "2025-06-21", true},
+		{"author", "Ann Lee", true},
+		{"tags", "", true},
+		{"date", "", false},
+	}
+	for _, tt := range tests {
+		if value, found := doc.FrontmatterValue(tt.key); value != tt.value || found != tt.found {
+			t.Errorf("FrontmatterValue(%q) = %q, %v; want %q, %v", tt.key, value, found, tt.value, tt.found)
+		}
+	}
+
+	doc.SetFrontmatterValue("title", "2025-06-22")
+	doc.SetFrontmatterValue("tags", "[daily]")
+	doc.SetFrontmatterValue("mood", "good")
+	if got, want := doc.String(), "---\ntitle: 2025-06-22\nauthor: \"Ann Lee\"\ntags: [daily]\nmood: good\n---\n\n## Todos\n\n"; got != want {
+		t.Errorf("String() after SetFrontmatterValue() = %q, want %q", got, want)
+	}
+
+	doc = ParseDocument("## Todos\n\n")
+	doc.SetFrontmatterValue("title", "2025-06-21")
+	if got, want := doc.String(), "---\ntitle: 2025-06-21\n---\n## Todos\n\n"; got != want {
+		t.Errorf("SetFrontmatterValue() without frontmatter gave %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,466 @@
+// Package core provides journal editing functionality for the todoer application.
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/inful/todoer/pkg/storage"
+)
+
+// PostponedToRegex matches a postpone annotation added by PostponeItems
+var PostponedToRegex = regexp.MustCompile(` \(postponed to \[\[(\d{4}-\d{2}-\d{2})\]\]\)$`)
+
+// MovedFromRegex matches the annotation of a task moved between journals, see MovedFromTemplate
+var MovedFromRegex = regexp.MustCompile(` \(moved from \[\[(\d{4}-\d{2}-\d{2})\]\]\)$`)
+
+// ItemLocation identifies a todo item inside a journal together with its container,
+// so the item can be removed or moved without searching again.
+type ItemLocation struct {
+	Day    *DaySection // Day section containing the item
+	Parent *TodoItem   // Parent item, nil for top-level items
+	Item   *TodoItem   // The located item
+	Depth  int         // Nesting depth of the item as serialized by JournalToString
+}
+
+// FindItems returns the locations of all items (including nested subitems) for which
+// match returns true, in document order.
+func FindItems(journal *TodoJournal, match func(*TodoItem) bool) []ItemLocation {
+	var locations []ItemLocation
+	if journal == nil || match == nil {
+		return locations
+	}
+
+	var walk func(day *DaySection, parent *TodoItem, items []*TodoItem, depth int)
+	walk = func(day *DaySection, parent *TodoItem, items []*TodoItem, depth int) {
+		for _, item := range items {
+			if item == nil {
+				continue
+			}
+			if match(item) {
+				locations = append(locations, ItemLocation{Day: day, Parent: parent, Item: item, Depth: depth})
+			}
+			walk(day, item, item.SubItems, depth+1)
+		}
+	}
+
+	for _, day := range journal.Days {
+		if day != nil {
+			walk(day, nil, day.Items, dayItemDepth(day))
+		}
+	}
+
+	return locations
+}
+
+// dayItemDepth returns the depth of top-level items in day: items under a date
+// header are nested one level, undated items are not.
+func dayItemDepth(day *DaySection) int {
+	if day.Date == "" {
+		return 0
+	}
+	return 1
+}
+
+// MatchText returns a matcher for FindItems that selects items whose text contains
+// pattern, ignoring case. If openOnly is true, completed items never match.
+func MatchText(pattern string, openOnly bool) func(*TodoItem) bool {
+	needle := strings.ToLower(pattern)
+	return func(item *TodoItem) bool {
+		if openOnly && item.Completed {
+			return false
+		}
+		return strings.Contains(strings.ToLower(item.Text), needle)
+	}
+}
+
+// MatchOpen is a matcher for FindItems that selects items that are not completed.
+func MatchOpen(item *TodoItem) bool {
+	return !item.Completed
+}
+
+// MatchTag returns a matcher for FindItems that selects items tagged with tag,
+// ignoring case. The leading '#' of tag is optional.
+func MatchTag(tag string) func(*TodoItem) bool {
+	tag = strings.TrimPrefix(tag, "#")
+	re := regexp.MustCompile(`(?i)(^|\s)#` + regexp.QuoteMeta(tag) + `($|\s)`)
+	return func(item *TodoItem) bool {
+		return re.MatchString(item.Text)
+	}
+}
+
+// MatchPriority returns a matcher for FindItems that selects items carrying the
+// priority marker [#priority], e.g. [#A]. The comparison ignores case.
+func MatchPriority(priority string) func(*TodoItem) bool {
+	marker := "[#" + strings.ToLower(strings.Trim(priority, "[#]")) + "]"
+	return func(item *TodoItem) bool {
+		return strings.Contains(strings.ToLower(item.Text), marker)
+	}
+}
+
+// MatchAll combines matchers into one that selects items accepted by all of them.
+func MatchAll(matchers ...func(*TodoItem) bool) func(*TodoItem) bool {
+	return func(item *TodoItem) bool {
+		for _, match := range matchers {
+			if !match(item) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// AddNote appends a bullet line with text to the located item, indented one
+// level deeper than the item itself.
+func AddNote(loc ItemLocation, text string) {
+	indent := strings.Repeat(" ", (loc.Depth+1)*IndentSpaces)
+	loc.Item.BulletLines = append(loc.Item.BulletLines, indent+"- "+text)
+}
+
+// RemoveItem detaches the located item, including its subitems and bullet lines,
+// from its parent or day section. It returns false if the item was not found there.
+func RemoveItem(loc ItemLocation) bool {
+	if loc.Item == nil {
+		return false
+	}
+
+	if loc.Parent != nil {
+		var removed bool
+		loc.Parent.SubItems, removed = removeFromItems(loc.Parent.SubItems, loc.Item)
+		return removed
+	}
+
+	if loc.Day == nil {
+		return false
+	}
+	var removed bool
+	loc.Day.Items, removed = removeFromItems(loc.Day.Items, loc.Item)
+	return removed
+}
+
+// removeFromItems removes target from items, preserving the order of the others
+func removeFromItems(items []*TodoItem, target *TodoItem) ([]*TodoItem, bool) {
+	for i, item := range items {
+		if item == target {
+			return append(items[:i], items[i+1:]...), true
+		}
+	}
+	return items, false
+}
+
+// EnsureDay returns the day section for date, creating it if necessary.
+// New dated sections are inserted in chronological order after any undated section.
+func EnsureDay(journal *TodoJournal, date string) *DaySection {
+	for _, day := range journal.Days {
+		if day != nil && day.Date == date {
+			return day
+		}
+	}
+
+	day := &DaySection{Date: date, Items: []*TodoItem{}}
+	index := len(journal.Days)
+	for i, existing := range journal.Days {
+		if existing != nil && existing.Date != "" && existing.Date > date {
+			index = i
+			break
+		}
+	}
+	journal.Days = append(journal.Days, nil)
+	copy(journal.Days[index+1:], journal.Days[index:])
+	journal.Days[index] = day
+
+	return day
+}
+
+// RemoveEmptyDays drops day sections that no longer contain any items.
+func RemoveEmptyDays(journal *TodoJournal) {
+	if journal == nil {
+		return
+	}
+	days := journal.Days[:0]
+	for _, day := range journal.Days {
+		if !day.IsEmpty() {
+			days = append(days, day)
+		}
+	}
+	journal.Days = days
+}
+
+// ReplaceTodosSection returns content with the body of the TODOS section identified by
+// todosHeader replaced by todos. Everything outside the section is preserved.
+func ReplaceTodosSection(content, todosHeader, todos string) (string, error) {
+	beforeTodos, _, afterTodos, err := ExtractTodosSectionWithHeader(content, todosHeader)
+	if err != nil {
+		return "", err
+	}
+
+	if todos == "" {
+		// Avoid leaving two blank lines where the section body used to be
+		return beforeTodos + strings.TrimPrefix(afterTodos, BlankLineSeparator), nil
+	}
+
+	if afterTodos == "" {
+		// The section was the last one; keep the file newline-terminated
+		return beforeTodos + todos + "\n", nil
+	}
+
+	if !strings.HasPrefix(afterTodos, "\n") {
+		// An empty section directly followed by the next one left no blank line
+		// for after the todos
+		afterTodos = BlankLineSeparator + afterTodos
+	}
+	return beforeTodos + todos + afterTodos, nil
+}
+
+// MergeTodosSections returns content with a single TODOS section identified by
+// todosHeader. A rendered template repeats the section when it writes the header
+// itself and also pulls in a partial or custom variable writing it again. The
+// repeated sections are dropped when they are empty or hold the same todos as the
+// kept one, which is the first with todos, at the place of the first header.
+// Sections with different todos are not merged, since that could carry a task
+// twice; an error naming the lines of the headers is returned instead.
+func MergeTodosSections(content, todosHeader string) (string, error) {
+	type section struct {
+		start, end int    // Lines of the header and of the first line after the section
+		body       string // Trimmed content of the section
+	}
+	lines := strings.SplitAfter(content, "\n")
+	isHeader := func(line string) bool { return strings.TrimRight(line, " \t\r\n") == todosHeader }
+
+	var sections []section
+	var headerLines []string
+	for i := 0; i < len(lines); i++ {
+		if !isHeader(lines[i]) {
+			continue
+		}
+		end := i + 1
+		for end < len(lines) && !strings.HasPrefix(lines[end], "## ") && !isHeader(lines[end]) {
+			end++
+		}
+		sections = append(sections, section{start: i, end: end, body: strings.TrimSpace(strings.Join(lines[i+1:end], ""))})
+		headerLines = append(headerLines, strconv.Itoa(i+1))
+		i = end - 1
+	}
+	if len(sections) < 2 {
+		return content, nil
+	}
+
+	kept := 0
+	for i, s := range sections {
+		if s.body != "" {
+			kept = i
+			break
+		}
+	}
+	for _, s := range sections {
+		if s.body != "" && s.body != sections[kept].body {
+			return "", fmt.Errorf("'%s' appears %d times with different todos (lines %s); keep one such header in the template",
+				todosHeader, len(sections), strings.Join(headerLines, ", "))
+		}
+	}
+
+	var b strings.Builder
+	next := 0
+	for i, s := range sections {
+		b.WriteString(strings.Join(lines[next:s.start], ""))
+		if i == 0 {
+			b.WriteString(lines[s.start])
+			if kept == 0 {
+				b.WriteString(strings.Join(lines[s.start+1:s.end], ""))
+			} else {
+				b.WriteString("\n" + sections[kept].body + "\n\n")
+			}
+		}
+		next = s.end
+	}
+	b.WriteString(strings.Join(lines[next:], ""))
+
+	merged := strings.TrimRight(b.String(), "\n")
+	if strings.HasSuffix(content, "\n") {
+		merged += "\n"
+	}
+	return merged, nil
+}
+
+// RenameTodosHeader returns content with the line holding the TODOS section header
+// fromHeader replaced by toHeader.
+func RenameTodosHeader(content, fromHeader, toHeader string) (string, error) {
+	lines := strings.SplitAfter(content, "\n")
+	for i, line := range lines {
+		if strings.TrimRight(line, " \t\r\n") == fromHeader {
+			lines[i] = toHeader + line[len(strings.TrimRight(line, "\r\n")):]
+			return strings.Join(lines, ""), nil
+		}
+	}
+	return "", fmt.Errorf("could not find '%s' section in file", fromHeader)
+}
+
+// ReindentTodosSection returns content with the leading indentation of every line in
+// the TODOS section identified by todosHeader converted from fromIndent to toIndent
+// spaces per level. Indentation that is not a whole number of levels keeps its
+// remainder. Everything outside the section is preserved.
+func ReindentTodosSection(content, todosHeader string, fromIndent, toIndent int) (string, error) {
+	if fromIndent <= 0 || toIndent <= 0 {
+		return "", fmt.Errorf("invalid indentation %d -> %d", fromIndent, toIndent)
+	}
+	_, section, _, err := ExtractTodosSectionWithHeader(content, todosHeader)
+	if err != nil {
+		return "", err
+	}
+
+	lines := strings.Split(section, "\n")
+	for i, line := range lines {
+		text := strings.TrimLeft(line, " \t")
+		if text == "" {
+			continue
+		}
+		indent := GetIndentLevel(line)
+		spaces := indent/fromIndent*toIndent + indent%fromIndent
+		lines[i] = strings.Repeat(" ", spaces) + text
+	}
+	return ReplaceTodosSection(content, todosHeader, strings.Join(lines, "\n"))
+}
+
+// ParseTodosSectionFromContent extracts and parses the TODOS section of a journal file.
+func ParseTodosSectionFromContent(content, todosHeader string) (*TodoJournal, error) {
+	return ParseTodosSectionFromContentFormat(content, todosHeader, DefaultFormat)
+}
+
+// ParseTodosSectionFromContentFormat extracts and parses the TODOS section of a journal
+// file laid out in format.
+func ParseTodosSectionFromContentFormat(content, todosHeader string, format Format) (*TodoJournal, error) {
+	return parseJournal(content, todosHeader, format, nil)
+}
+
+// parseJournal parses the TODOS section of a journal file like
+// ParseTodosSectionFromContentFormat, allocating from arena unless it is nil.
+func parseJournal(content, todosHeader string, format Format, arena *Arena) (*TodoJournal, error) {
+	before, todosSection, _, err := ExtractTodosSectionWithHeader(content, todosHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	journal, _, err := parseTodosSection(context.Background(), todosSection, format, arena)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse todos section: %w", shiftLines(err, strings.Count(before, "\n")))
+	}
+
+	return journal, nil
+}
+
+// EditJournalFile parses the TODOS section of the journal file at path, lets edit
+// change the journal and writes it back with the section replaced, keeping the
+// content outside the section as it was. The section is read and written in the
+// layout of opts, so a journal edit leaves unchanged is written back unchanged.
+//
+// The file is replaced atomically and only if its content changed: if edit
+// returns an error, or parsing or writing fails, the file keeps its previous
+// content and the error is returned.
+func EditJournalFile(path string, edit func(*TodoJournal) error, opts ...Option) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	content := string(data)
+
+	journal, err := NewParser(opts...).ParseJournal(content)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if err := edit(journal); err != nil {
+		return err
+	}
+
+	updated, err := NewRenderer(opts...).ReplaceJournal(content, journal)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if updated == content {
+		return nil
+	}
+	return storage.WriteFileAtomic(path, strings.NewReader(updated), info.Mode().Perm())
+}
+
+// PostponedItem describes a todo item rescheduled by PostponeItems.
+type PostponedItem struct {
+	Text string // Item text before any annotation was added
+	From string // Date of the day section the item was found in
+	To   string // Date the item was postponed to
+}
+
+// PostponeItems reschedules every item selected by match by days days, relative to
+// the date of its day section, or of an earlier postpone annotation. Undated items
+// are treated as belonging to undatedDate.
+// Items nested inside a selected item are postponed together with it. Items are
+// stamped with their created date, see StampCreated, since their day changes.
+//
+// By default items are moved under the day section of their new date, which is
+// created when necessary. With annotate, items stay in place and their text is
+// annotated with the new date instead; an earlier annotation is replaced.
+func PostponeItems(journal *TodoJournal, match func(*TodoItem) bool, days int, undatedDate string, annotate bool) ([]PostponedItem, error) {
+	var locations []ItemLocation
+	var walk func(day *DaySection, parent *TodoItem, items []*TodoItem)
+	walk = func(day *DaySection, parent *TodoItem, items []*TodoItem) {
+		for _, item := range items {
+			if item == nil {
+				continue
+			}
+			if match(item) {
+				locations = append(locations, ItemLocation{Day: day, Parent: parent, Item: item})
+				continue
+			}
+			walk(day, item, item.SubItems)
+		}
+	}
+	for _, day := range journal.Days {
+		if day != nil {
+			walk(day, nil, day.Items)
+		}
+	}
+
+	// Record when the items were written down before their day changes
+	created := CreatedDates(journal, undatedDate)
+	postponed := make([]PostponedItem, 0, len(locations))
+	for _, loc := range locations {
+		from := loc.Day.Date
+		if from == "" {
+			from = undatedDate
+		}
+		// An item postponed in place before counts from the date it was postponed to
+		if m := PostponedToRegex.FindStringSubmatch(loc.Item.Text); m != nil {
+			from = m[1]
+		}
+		fromTime, err := time.Parse(DateFormat, from)
+		if err != nil {
+			return nil, fmt.Errorf("cannot postpone '%s': invalid date %q", loc.Item.Text, from)
+		}
+		to := fromTime.AddDate(0, 0, days).Format(DateFormat)
+
+		text := PostponedToRegex.ReplaceAllString(loc.Item.Text, "")
+		postponed = append(postponed, PostponedItem{Text: text, From: from, To: to})
+
+		StampCreated(loc.Item, created[loc.Item])
+		if annotate {
+			loc.Item.Text = PostponedToRegex.ReplaceAllString(loc.Item.Text, "") + " (" + fmt.Sprintf(PostponedToTemplate, to) + ")"
+			continue
+		}
+
+		RemoveItem(loc)
+		target := EnsureDay(journal, to)
+		target.Items = append(target.Items, loc.Item)
+	}
+
+	if !annotate {
+		RemoveEmptyDays(journal)
+	}
+	return postponed, nil
+}
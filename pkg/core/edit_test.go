@@ -0,0 +1,429 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindItemsAndRemoveItem(t *testing.T) {
+	journal, err := ParseTodosSection(`- [[2025-06-20]]
+  - [ ] Write report
+    - [ ] Collect report numbers
+  - [x] Report sent #2025-06-20
+- [[2025-06-21]]
+  - [ ] Call Bob`)
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error: %v", err)
+	}
+
+	matches := FindItems(journal, MatchText("REPORT", true))
+	if len(matches) != 2 {
+		t.Fatalf("FindItems() returned %d matches, want 2", len(matches))
+	}
+	if matches[0].Parent != nil || matches[0].Item.Text != "Write report" {
+		t.Errorf("first match = %+v, want top-level 'Write report'", matches[0])
+	}
+	if matches[1].Parent != matches[0].Item || matches[1].Item.Text != "Collect report numbers" {
+		t.Errorf("second match = %+v, want subitem of 'Write report'", matches[1])
+	}
+
+	if all := FindItems(journal, MatchText("report", false)); len(all) != 3 {
+		t.Errorf("FindItems() with completed items returned %d matches, want 3", len(all))
+	}
+
+	if !RemoveItem(matches[1]) {
+		t.Fatal("RemoveItem() on subitem returned false")
+	}
+	if len(matches[0].Item.SubItems) != 0 {
+		t.Errorf("subitem still present after RemoveItem()")
+	}
+	if RemoveItem(matches[1]) {
+		t.Error("RemoveItem() on already removed item returned true")
+	}
+
+	bob := FindItems(journal, MatchText("bob", true))
+	if len(bob) != 1 || !RemoveItem(bob[0]) {
+		t.Fatal("failed to remove 'Call Bob'")
+	}
+	RemoveEmptyDays(journal)
+	if len(journal.Days) != 1 || journal.Days[0].Date != "2025-06-20" {
+		t.Errorf("RemoveEmptyDays() left days %+v, want only 2025-06-20", journal.Days)
+	}
+}
+
+func TestEnsureDay(t *testing.T) {
+	journal := &TodoJournal{Days: []*DaySection{
+		{Date: "", Items: []*TodoItem{}},
+		{Date: "2025-06-20", Items: []*TodoItem{}},
+		{Date: "2025-06-25", Items: []*TodoItem{}},
+	}}
+
+	if day := EnsureDay(journal, "2025-06-20"); day != journal.Days[1] {
+		t.Error("EnsureDay() did not return the existing section")
+	}
+
+	EnsureDay(journal, "2025-06-22")
+	EnsureDay(journal, "2025-06-01")
+	EnsureDay(journal, "2025-07-01")
+
+	want := []string{"", "2025-06-01", "2025-06-20", "2025-06-22", "2025-06-25", "2025-07-01"}
+	if len(journal.Days) != len(want) {
+		t.Fatalf("journal has %d days, want %d", len(journal.Days), len(want))
+	}
+	for i, date := range want {
+		if journal.Days[i].Date != date {
+			t.Errorf("day %d = %q, want %q", i, journal.Days[i].Date, date)
+		}
+	}
+}
+
+func TestReplaceTodosSection(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		todos    string
+		expected string
+	}{
+		{
+			name:     "section followed by another section",
+			content:  "# Title\n\n## Todos\n\n- [ ] Old\n\n## Notes\n\nNotes",
+			todos:    "- [ ] New",
+			expected: "# Title\n\n## Todos\n\n- [ ] New\n\n## Notes\n\nNotes",
+		},
+		{
+			name:     "last section",
+			content:  "# Title\n\n## Todos\n\n- [ ] Old\n",
+			todos:    "- [ ] New",
+			expected: "# Title\n\n## Todos\n\n- [ ] New\n",
+		},
+		{
+			name:     "emptied section",
+			content:  "## Todos\n\n- [ ] Old\n\n## Notes\n",
+			todos:    "",
+			expected: "## Todos\n\n## Notes\n",
+		},
+		{
+			name:     "filling an empty section",
+			content:  "## Todos\n\n## Notes\n",
+			todos:    "- [ ] New",
+			expected: "## Todos\n\n- [ ] New\n\n## Notes\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ReplaceTodosSection(tt.content, TodosHeader, tt.todos)
+			if err != nil {
+				t.Fatalf("ReplaceTodosSection() error: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("ReplaceTodosSection() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+
+	if _, err := ReplaceTodosSection("# No todos here\n", TodosHeader, "- [ ] New"); err == nil {
+		t.Error("ReplaceTodosSection() expected error for content without a TODOS section")
+	}
+}
+
+func TestRenameTodosHeader(t *testing.T) {
+	content := "# TODOS of the week\n\n## TODOS\r\n\n- [ ] Task\n\n## Notes\n"
+	result, err := RenameTodosHeader(content, "## TODOS", "## Tasks")
+	if err != nil {
+		t.Fatalf("RenameTodosHeader() error: %v", err)
+	}
+	if expected := "# TODOS of the week\n\n## Tasks\r\n\n- [ ] Task\n\n## Notes\n"; result != expected {
+		t.Errorf("RenameTodosHeader() = %q, want %q", result, expected)
+	}
+
+	if _, err := RenameTodosHeader("## TODOS later\n", "## TODOS", "## Tasks"); err == nil {
+		t.Error("RenameTodosHeader() expected error when the header is only a prefix of a line")
+	}
+}
+
+func TestMergeTodosSections(t *testing.T) {
+	tests := []struct {
+		name, content, want string
+	}{
+		{
+			name:    "single section",
+			content: "# Day\n\n## Todos\n\n- [ ] Task\n\n## Todos of the week\n",
+			want:    "# Day\n\n## Todos\n\n- [ ] Task\n\n## Todos of the week\n",
+		},
+		{
+			name:    "empty duplicate",
+			content: "# Day\n\n## Todos\n\n- [ ] Task\n\n## Notes\n\n## Todos\n\n",
+			want:    "# Day\n\n## Todos\n\n- [ ] Task\n\n## Notes\n",
+		},
+		{
+			name:    "todos in the second section",
+			content: "# Day\n\n## Todos\n\n## Notes\n\nText\n\n## Todos\n\n- [ ] Task\n",
+			want:    "# Day\n\n## Todos\n\n- [ ] Task\n\n## Notes\n\nText\n",
+		},
+		{
+			name:    "same todos twice",
+			content: "## Todos\n\n- [ ] Task\n\n## Todos\n\n- [ ] Task\n",
+			want:    "## Todos\n\n- [ ] Task\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergeTodosSections(tt.content, TodosHeader)
+			if err != nil {
+				t.Fatalf("MergeTodosSections() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("MergeTodosSections() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	_, err := MergeTodosSections("## Todos\n\n- [ ] Task\n\n## Todos\n\n- [ ] Other\n", TodosHeader)
+	if err == nil || !strings.Contains(err.Error(), "lines 1, 5") {
+		t.Errorf("MergeTodosSections() error = %v, want error naming lines 1, 5", err)
+	}
+}
+
+func TestReindentTodosSection(t *testing.T) {
+	content := "# Title\n\n## Todos\n\n- [[2025-06-20]]\n  - [ ] Task\n    - [ ] Subtask\n     odd continuation\n\t- [x] Tabbed\n\n## Notes\n\n  - kept as is\n"
+	expected := "# Title\n\n## Todos\n\n- [[2025-06-20]]\n    - [ ] Task\n        - [ ] Subtask\n         odd continuation\n    - [x] Tabbed\n\n## Notes\n\n  - kept as is\n"
+
+	result, err := ReindentTodosSection(content, TodosHeader, 2, 4)
+	if err != nil {
+		t.Fatalf("ReindentTodosSection() error: %v", err)
+	}
+	if result != expected {
+		t.Errorf("ReindentTodosSection() = %q, want %q", result, expected)
+	}
+
+	back, err := ReindentTodosSection(result, TodosHeader, 4, 2)
+	if err != nil {
+		t.Fatalf("ReindentTodosSection() back error: %v", err)
+	}
+	if back != strings.Replace(content, "\t", "  ", 1) {
+		t.Errorf("ReindentTodosSection() back = %q", back)
+	}
+
+	if _, err := ReindentTodosSection(content, TodosHeader, 0, 4); err == nil {
+		t.Error("ReindentTodosSection() expected error for zero indentation")
+	}
+}
+
+func TestMatchers(t *testing.T) {
+	item := &TodoItem{Text: "Buy stamps #errand [#A]"}
+
+	tests := []struct {
+		name  string
+		match func(*TodoItem) bool
+		want  bool
+	}{
+		{"tag with hash", MatchTag("#errand"), true},
+		{"tag without hash", MatchTag("ERRAND"), true},
+		{"tag prefix only", MatchTag("err"), false},
+		{"other tag", MatchTag("work"), false},
+		{"priority", MatchPriority("a"), true},
+		{"priority marker", MatchPriority("[#A]"), true},
+		{"other priority", MatchPriority("B"), false},
+		{"all match", MatchAll(MatchOpen, MatchTag("errand"), MatchPriority("A")), true},
+		{"one fails", MatchAll(MatchOpen, MatchTag("work")), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.match(item); got != tt.want {
+				t.Errorf("matcher returned %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostponeItems(t *testing.T) {
+	input := `- [ ] Undated #errand
+- [[2025-06-20]]
+  - [ ] Buy stamps #errand
+    - [ ] Nested #errand
+  - [x] Done #errand #2025-06-20
+  - [ ] Write report
+- [[2025-06-21]]
+  - [ ] Post letter #errand`
+
+	t.Run("move", func(t *testing.T) {
+		journal, err := ParseTodosSection(input)
+		if err != nil {
+			t.Fatalf("ParseTodosSection() error: %v", err)
+		}
+
+		postponed, err := PostponeItems(journal, MatchAll(MatchOpen, MatchTag("errand")), 2, "2025-06-19", false)
+		if err != nil {
+			t.Fatalf("PostponeItems() error: %v", err)
+		}
+		if len(postponed) != 3 {
+			t.Fatalf("PostponeItems() postponed %d items, want 3: %+v", len(postponed), postponed)
+		}
+
+		expected := `- [[2025-06-20]]
+  - [x] Done #errand #2025-06-20
+  - [ ] Write report
+- [[2025-06-21]]
+  - [ ] Undated #errand created::[[2025-06-19]]
+- [[2025-06-22]]
+  - [ ] Buy stamps #errand created::[[2025-06-19]]
+    - [ ] Nested #errand
+- [[2025-06-23]]
+  - [ ] Post letter #errand created::[[2025-06-19]]`
+		if got := JournalToString(journal); got != expected {
+			t.Errorf("PostponeItems() result mismatch.\nExpected:\n%s\nGot:\n%s", expected, got)
+		}
+	})
+
+	t.Run("annotate", func(t *testing.T) {
+		journal, err := ParseTodosSection("- [[2025-06-20]]\n  - [ ] Buy stamps #errand\n  - [ ] Write report")
+		if err != nil {
+			t.Fatalf("ParseTodosSection() error: %v", err)
+		}
+
+		match := MatchAll(MatchOpen, MatchTag("errand"))
+		if _, err := PostponeItems(journal, match, 2, "", true); err != nil {
+			t.Fatalf("PostponeItems() error: %v", err)
+		}
+		// Postponing again counts from the annotated date and replaces the annotation
+		postponed, err := PostponeItems(journal, match, 5, "", true)
+		if err != nil {
+			t.Fatalf("PostponeItems() error: %v", err)
+		}
+		if postponed[0].From != "2025-06-22" || postponed[0].To != "2025-06-27" {
+			t.Errorf("PostponedItem dates = %s -> %s, want 2025-06-22 -> 2025-06-27", postponed[0].From, postponed[0].To)
+		}
+		if postponed[0].Text != "Buy stamps #errand created::[[2025-06-20]]" {
+			t.Errorf("PostponedItem.Text = %q, want text without annotation", postponed[0].Text)
+		}
+
+		expected := "- [[2025-06-20]]\n  - [ ] Buy stamps #errand created::[[2025-06-20]] (postponed to [[2025-06-27]])\n  - [ ] Write report"
+		if got := JournalToString(journal); got != expected {
+			t.Errorf("PostponeItems() result mismatch.\nExpected:\n%s\nGot:\n%s", expected, got)
+		}
+	})
+
+	t.Run("invalid undated date", func(t *testing.T) {
+		journal, err := ParseTodosSection("- [ ] Undated task")
+		if err != nil {
+			t.Fatalf("ParseTodosSection() error: %v", err)
+		}
+		if _, err := PostponeItems(journal, MatchOpen, 1, "", false); err == nil {
+			t.Error("PostponeItems() expected error for undated item without a date")
+		}
+	})
+}
+
+func TestAddNote(t *testing.T) {
+	input := "- [ ] Undated task\n- [[2025-06-20]]\n  - [ ] Parent\n    - [ ] Child"
+	journal, err := ParseTodosSection(input)
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error: %v", err)
+	}
+
+	for _, text := range []string{"undated", "parent", "child"} {
+		matches := FindItems(journal, MatchText(text, true))
+		if len(matches) != 1 {
+			t.Fatalf("FindItems(%q) returned %d matches, want 1", text, len(matches))
+		}
+		AddNote(matches[0], "note for "+text)
+	}
+
+	output := JournalToString(journal)
+	expected := "- [ ] Undated task\n  - note for undated\n- [[2025-06-20]]\n  - [ ] Parent\n    - note for parent\n    - [ ] Child\n      - note for child"
+	if output != expected {
+		t.Errorf("AddNote() result mismatch.\nExpected:\n%s\nGot:\n%s", expected, output)
+	}
+
+	// The notes must be stored exactly as the parser reads them back
+	reparsed, err := ParseTodosSection(output)
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error: %v", err)
+	}
+	if !reflect.DeepEqual(reparsed, journal) {
+		t.Error("journal with added notes does not round trip")
+	}
+}
+
+func TestEditJournalFile(t *testing.T) {
+	content := `---
+title: 2025-06-21
+---
+# Friday
+
+## Tasks
+
+- [[2025-06-20]]
+    - [ ] Write report
+    - [ ] Call Bob
+
+## Notes
+Keep *this* as it is.
+`
+	opts := []Option{WithHeader("## Tasks"), WithIndent(4)}
+	path := filepath.Join(t.TempDir(), "2025-06-21.md")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	read := func() string {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	// A failing edit leaves the file alone, even after changing the journal
+	errEdit := errors.New("edit failed")
+	err := EditJournalFile(path, func(j *TodoJournal) error {
+		j.Days[0].Items = nil
+		return errEdit
+	}, opts...)
+	if !errors.Is(err, errEdit) {
+		t.Fatalf("EditJournalFile() error = %v, want %v", err, errEdit)
+	}
+	if got := read(); got != content {
+		t.Errorf("file changed by failing edit:\n%s", got)
+	}
+
+	// An edit changing nothing writes the same content back
+	if err := EditJournalFile(path, func(*TodoJournal) error { return nil }, opts...); err != nil {
+		t.Fatalf("EditJournalFile() error: %v", err)
+	}
+	if got := read(); got != content {
+		t.Errorf("file changed by empty edit:\n%s", got)
+	}
+
+	err = EditJournalFile(path, func(j *TodoJournal) error {
+		loc := FindItems(j, MatchText("call bob", true))
+		if len(loc) != 1 {
+			return errors.New("task not found")
+		}
+		loc[0].Item.Completed = true
+		return nil
+	}, opts...)
+	if err != nil {
+		t.Fatalf("EditJournalFile() error: %v", err)
+	}
+	want := strings.Replace(content, "- [ ] Call Bob", "- [x] Call Bob", 1)
+	if got := read(); got != want {
+		t.Errorf("EditJournalFile() wrote:\n%s\nwant:\n%s", got, want)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("file mode after edit = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+
+	if err := EditJournalFile(filepath.Join(t.TempDir(), "missing.md"), func(*TodoJournal) error { return nil }); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("EditJournalFile() on a missing file error = %v, want os.ErrNotExist", err)
+	}
+	if err := EditJournalFile(path, func(*TodoJournal) error { return nil }); err == nil {
+		t.Error("EditJournalFile() without the header of the file succeeded")
+	}
+}
@@ -0,0 +1,132 @@
+// Package core provides structured journal errors for the todoer application.
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrorKind classifies a JournalError. The kinds are errors themselves, so
+// errors.Is(err, KindDate) reports whether err is an invalid date in a journal.
+type ErrorKind string
+
+// Error returns the description of the kind.
+func (k ErrorKind) Error() string {
+	return string(k)
+}
+
+// Kinds of journal errors
+const (
+	KindDate        ErrorKind = "invalid date"             // A day header holds an invalid date
+	KindFrontmatter ErrorKind = "invalid frontmatter date" // The frontmatter date is invalid
+	KindLimit       ErrorKind = "limit exceeded"           // The journal exceeds one of the Limits of the parser
+)
+
+// JournalError is an error in the content of a journal, with its position.
+// Line and Col count from 1 and are 0 when unknown; Line counts from the start
+// of the file when the error passed through a function that knows where the todos
+// section starts, and from the start of the section otherwise.
+type JournalError struct {
+	File   string    // Location of the journal, empty if unknown
+	Line   int       // Line of the error
+	Col    int       // Column of the error, in bytes
+	Kind   ErrorKind // What went wrong
+	Source string    // The offending line, for showing context
+	Err    error     // Detailed error
+}
+
+// Error returns the error prefixed with its position, e.g.
+// "2025/06/2025-06-20.md:12:3: invalid date in day header: ...", or
+// "line 12, column 3: invalid date in day header: ..." if the file is unknown.
+func (e *JournalError) Error() string {
+	var b strings.Builder
+	switch {
+	case e.File != "" && e.Line > 0 && e.Col > 0:
+		fmt.Fprintf(&b, "%s:%d:%d: ", e.File, e.Line, e.Col)
+	case e.File != "" && e.Line > 0:
+		fmt.Fprintf(&b, "%s:%d: ", e.File, e.Line)
+	case e.File != "":
+		fmt.Fprintf(&b, "%s: ", e.File)
+	case e.Line > 0 && e.Col > 0:
+		fmt.Fprintf(&b, "line %d, column %d: ", e.Line, e.Col)
+	case e.Line > 0:
+		fmt.Fprintf(&b, "line %d: ", e.Line)
+	}
+	if e.Err != nil {
+		b.WriteString(e.Err.Error())
+	} else {
+		b.WriteString(e.Kind.Error())
+	}
+	return b.String()
+}
+
+// Unwrap returns the detailed error.
+func (e *JournalError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the kind of the error.
+func (e *JournalError) Is(target error) bool {
+	kind, ok := target.(ErrorKind)
+	return ok && kind == e.Kind
+}
+
+// Context returns the offending line with a caret under the column of the error,
+// prefixed with the line number, or an empty string if the line is unknown.
+func (e *JournalError) Context() string {
+	if e.Source == "" || e.Line <= 0 {
+		return ""
+	}
+	prefix := fmt.Sprintf("%5d | ", e.Line)
+	var b strings.Builder
+	b.WriteString(prefix)
+	b.WriteString(e.Source)
+	b.WriteString("\n")
+	b.WriteString(strings.Repeat(" ", len(prefix)-2))
+	b.WriteString("| ")
+	if e.Col > 1 {
+		// Keep tabs so the caret lines up with the line above
+		for _, r := range e.Source[:min(e.Col-1, len(e.Source))] {
+			if r == '\t' {
+				b.WriteRune('\t')
+			} else {
+				b.WriteRune(' ')
+			}
+		}
+	}
+	b.WriteString("^")
+	return b.String()
+}
+
+// WithFile returns the JournalError in err with file recorded as its location if it
+// has none. The JournalError says where and what went wrong, so the messages of
+// errors wrapping it are dropped. Other errors are returned prefixed with file.
+func WithFile(err error, file string) error {
+	if err == nil {
+		return nil
+	}
+	var jerr *JournalError
+	if errors.As(err, &jerr) {
+		if jerr.File == "" {
+			jerr.File = file
+		}
+		return jerr
+	}
+	return fmt.Errorf("%s: %w", file, err)
+}
+
+// shiftLines moves the line of a JournalError in err down by lines, for errors found
+// in a part of a file that starts lines lines into it. It returns err.
+func shiftLines(err error, lines int) error {
+	var jerr *JournalError
+	if errors.As(err, &jerr) && jerr.Line > 0 {
+		jerr.Line += lines
+	}
+	return err
+}
+
+// columnOf returns the column of the first non-blank character of line.
+func columnOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t")) + 1
+}
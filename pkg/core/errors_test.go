@@ -0,0 +1,46 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestJournalError(t *testing.T) {
+	content := "---\ntitle: 2025-06-20\n---\n\n## Todos\n\n- [[2025-06-20]]\n  - [ ] Fine\n- [[2025-02-30]]\n"
+
+	_, err := ParseTodosSectionFromContentFormat(content, TodosHeader, DefaultFormat)
+	if !errors.Is(err, KindDate) || errors.Is(err, KindLimit) {
+		t.Fatalf("ParseTodosSectionFromContentFormat() error = %v, want an invalid date", err)
+	}
+	var jerr *JournalError
+	if !errors.As(err, &jerr) {
+		t.Fatalf("error %v is not a *JournalError", err)
+	}
+	if jerr.Line != 9 || jerr.Col != 1 || jerr.Source != "- [[2025-02-30]]" {
+		t.Errorf("JournalError = %+v, want line 9, column 1", jerr)
+	}
+	if got, want := jerr.Context(), "    9 | - [[2025-02-30]]\n      | ^"; got != want {
+		t.Errorf("Context() = %q, want %q", got, want)
+	}
+
+	if err := WithFile(err, "2025-06-20.md"); !strings.Contains(err.Error(), "2025-06-20.md:9:1: invalid date in day header: ") {
+		t.Errorf("WithFile() error = %v", err)
+	}
+	if err := WithFile(errors.New("boom"), "x.md"); err.Error() != "x.md: boom" {
+		t.Errorf("WithFile() on another error = %v", err)
+	}
+}
+
+func TestJournalError_Dates(t *testing.T) {
+	_, err := ParseTodosSection("- [[2025-06-20]]\n  - [ ] Task\n- [[2025-02-30]]")
+	var jerr *JournalError
+	if !errors.Is(err, KindDate) || !errors.As(err, &jerr) || jerr.Line != 3 {
+		t.Errorf("ParseTodosSection() error = %v, want an invalid date on line 3", err)
+	}
+
+	_, err = ExtractDateFromFrontmatter("---\ntags: [daily]\ntitle: 2025-13-01\n---\n", "title")
+	if !errors.Is(err, KindFrontmatter) || !errors.As(err, &jerr) || jerr.Line != 3 || jerr.Col != 8 {
+		t.Errorf("ExtractDateFromFrontmatter() error = %v (%+v), want line 3, column 8", err, jerr)
+	}
+}
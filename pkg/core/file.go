@@ -2,11 +2,15 @@
 package core
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"regexp"
 	"strings"
 	"text/template"
 	"time"
+
+	"github.com/inful/todoer/pkg/storage"
 )
 
 // Constants for file processing
@@ -15,6 +19,12 @@ const (
 	BlankLineSeparator = "\n\n"
 	// MovedToTemplate is the template for moved todos message
 	MovedToTemplate = "Moved to [[%s]]"
+	// MovedFromTemplate is the annotation appended to a task moved between journals
+	MovedFromTemplate = "moved from [[%s]]"
+	// PostponedToTemplate is the annotation appended to a task postponed in place
+	PostponedToTemplate = "postponed to [[%s]]"
+	// CarriedFromTemplate is the annotation appended to a task collapsed under a later date
+	CarriedFromTemplate = "from [[%s]]"
 )
 
 // Pre-compiled regex for better performance
@@ -31,17 +41,30 @@ func ExtractDateFromFrontmatter(content string, dateKey string) (string, error)
 
 	// Use dynamic regex for the configured key
 	regex := BuildFrontmatterDateRegex(dateKey)
-	matches := regex.FindStringSubmatch(content)
+	matches := regex.FindStringSubmatchIndex(content)
 
-	if len(matches) < 2 {
+	if len(matches) < 4 {
 		// If no date found in frontmatter, use today's date
 		return time.Now().Format(DateFormat), nil
 	}
 
 	// Validate the extracted date
-	extractedDate := matches[1]
+	extractedDate := content[matches[2]:matches[3]]
 	if err := ValidateDate(extractedDate); err != nil {
-		return "", fmt.Errorf("invalid date in frontmatter: %w", err)
+		lineStart := strings.LastIndex(content[:matches[2]], "\n") + 1
+		lineEnd := strings.IndexByte(content[matches[2]:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(content)
+		} else {
+			lineEnd += matches[2]
+		}
+		return "", &JournalError{
+			Line:   strings.Count(content[:matches[2]], "\n") + 1,
+			Col:    matches[2] - lineStart + 1,
+			Kind:   KindFrontmatter,
+			Source: content[lineStart:lineEnd],
+			Err:    fmt.Errorf("invalid date in frontmatter: %w", err),
+		}
 	}
 
 	return extractedDate, nil
@@ -81,7 +104,12 @@ func ExtractTodosSection(content string) (string, string, string, error) {
 
 	// Find the next section header (if any)
 	afterHeaderContent := content[beforeTodosEnd:]
-	nextSectionMatch := NextSectionRegex.FindStringIndex(afterHeaderContent)
+	if strings.HasPrefix(afterHeaderContent, "## ") || strings.HasPrefix(afterHeaderContent, "[^") && footnoteDefRegex.MatchString(afterHeaderContent) {
+		// The section is empty and directly followed by the next section, which
+		// starts after; the blank line above it belongs to before
+		return beforeTodos, "", afterHeaderContent, nil
+	}
+	nextSectionMatch := nextSectionIndex(afterHeaderContent)
 
 	var todosSection string
 	var afterTodos string
@@ -134,7 +162,12 @@ func ExtractTodosSectionWithHeader(content string, todosHeader string) (string,
 
 	// Find the next section header (if any)
 	afterHeaderContent := content[beforeTodosEnd:]
-	nextSectionMatch := NextSectionRegex.FindStringIndex(afterHeaderContent)
+	if strings.HasPrefix(afterHeaderContent, "## ") || strings.HasPrefix(afterHeaderContent, "[^") && footnoteDefRegex.MatchString(afterHeaderContent) {
+		// The section is empty and directly followed by the next section, which
+		// starts after; the blank line above it belongs to before
+		return beforeTodos, "", afterHeaderContent, nil
+	}
+	nextSectionMatch := nextSectionIndex(afterHeaderContent)
 
 	var todosSection string
 	var afterTodos string
@@ -153,6 +186,18 @@ func ExtractTodosSectionWithHeader(content string, todosHeader string) (string,
 	return beforeTodos, strings.TrimSpace(todosSection), afterTodos, nil
 }
 
+// nextSectionIndex returns the location of the blank line ending the TODOS section
+// in the content after its header, like NextSectionRegex does, or nil if the
+// section runs to the end of the content. Footnote definitions in the first column
+// end the section as well, so they can follow the todos at the end of a journal.
+func nextSectionIndex(content string) []int {
+	next := NextSectionRegex.FindStringIndex(content)
+	if m := footnotesStartRegex.FindStringIndex(content); m != nil && (next == nil || m[0] < next[0]) {
+		return m
+	}
+	return next
+}
+
 // ProcessTodosSection processes the TODOS section and returns completed and uncompleted sections.
 // It parses todos, splits them, adds date tags, and converts back to strings.
 // If there are no completed tasks, it returns a "Moved to [[date]]" message for the completed section.
@@ -173,8 +218,14 @@ func ProcessTodosSection(todosSection string, originalDate string, currentDate s
 		return "", "", fmt.Errorf("failed to parse todos section: %w", err)
 	}
 
-	// Move undated todos to the original date (the date from the file frontmatter)
-	journal = MoveUndatedTodosToCurrentDate(journal, originalDate)
+	// A section without day headers is a single day, that of the file frontmatter,
+	// and is written back without them. Otherwise undated todos are moved to that day.
+	headerless := journal.IsHeaderless()
+	if headerless {
+		journal.Days[0].Date = originalDate
+	} else {
+		journal = MoveUndatedTodosToCurrentDate(journal, originalDate)
+	}
 
 	// Split the journal into completed and uncompleted tasks
 	completedJournal, uncompletedJournal := SplitJournal(journal)
@@ -185,6 +236,11 @@ func ProcessTodosSection(todosSection string, originalDate string, currentDate s
 	// Add date tags to completed subtasks in uncompleted tasks
 	TagCompletedSubitems(uncompletedJournal, originalDate)
 
+	if headerless {
+		completedJournal = RemoveDayHeaders(completedJournal)
+		uncompletedJournal = RemoveDayHeaders(uncompletedJournal)
+	}
+
 	// Convert back to string format
 	completedTodos := JournalToString(completedJournal)
 	uncompletedTodos := JournalToString(uncompletedJournal)
@@ -227,17 +283,25 @@ func validateTemplateInputs(templateContent, currentDate string) error {
 
 // executeTemplate parses and executes a Go template with the provided data
 func executeTemplate(templateContent string, data TemplateData) (string, error) {
-	tmpl, err := template.New("journal").Funcs(CreateTemplateFunctions()).Parse(templateContent)
+	var result strings.Builder
+	if err := executeTemplateTo(&result, templateContent, data, CreateTemplateFunctions()); err != nil {
+		return "", err
+	}
+	return result.String(), nil
+}
+
+// executeTemplateTo parses a Go template with funcs and streams its output for data to w
+func executeTemplateTo(w io.Writer, templateContent string, data TemplateData, funcs template.FuncMap) error {
+	tmpl, err := template.New("journal").Funcs(funcs).Parse(templateContent)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse template: %w", err)
+		return fmt.Errorf("failed to parse template: %w", err)
 	}
 
-	var result strings.Builder
-	if err := tmpl.Execute(&result, data); err != nil {
-		return "", fmt.Errorf("failed to execute template: %w", err)
+	if err := tmpl.Execute(w, data); err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
 	}
 
-	return result.String(), nil
+	return nil
 }
 
 // cleanExcessiveBlankLines removes sequences of 3 or more newlines and replaces them with 2 newlines.
@@ -246,6 +310,35 @@ func cleanExcessiveBlankLines(content string) string {
 	return excessiveBlankLinesRegex.ReplaceAllString(content, BlankLineSeparator)
 }
 
+// blankLineWriter is the streaming counterpart of cleanExcessiveBlankLines.
+// It drops every newline beyond the second in a run of consecutive newlines,
+// even when the run is split across several Write calls.
+type blankLineWriter struct {
+	w        io.Writer
+	newlines int // Length of the newline run at the end of the previous write
+}
+
+func (b *blankLineWriter) Write(p []byte) (int, error) {
+	start := 0
+	for i, c := range p {
+		if c != '\n' {
+			b.newlines = 0
+			continue
+		}
+		b.newlines++
+		if b.newlines > 2 {
+			if _, err := b.w.Write(p[start:i]); err != nil {
+				return 0, err
+			}
+			start = i + 1
+		}
+	}
+	if _, err := b.w.Write(p[start:]); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
 // TemplateOptions contains all options for template creation.
 // This provides a flexible interface for template rendering with optional features.
 type TemplateOptions struct {
@@ -258,21 +351,69 @@ type TemplateOptions struct {
 	PreviousDate string                 // Previous journal date (optional)
 	Journal      *TodoJournal           // Journal for statistics calculation (optional)
 	CustomVars   map[string]interface{} // Custom template variables (optional)
+	Streaks      StreakStats            // Completion streaks across the journal tree (optional)
+	Sprig        bool                   // Also offer the Sprig-compatible functions, see TemplateFunctions (optional)
+	Calendar     Calendar               // Holidays skipped by nextWorkday and friends (optional)
+	Notes        storage.Storage        // Journal tree whose notes noteField reads (optional)
+	Previous     *Document              // Previous journal, whose sections PreviousSection returns (optional)
+	// Seed shuffle and shuffleLines with CurrentDate, so the output is the same
+	// every time the template is rendered for a date (optional)
+	Deterministic bool
 }
 
 // CreateFromTemplate creates file content from template using the options pattern.
 // This is the unified function that supports all template features: date formatting,
 // todo statistics, and custom variables. Use TemplateOptions to specify what features to enable.
 func CreateFromTemplate(opts TemplateOptions) (string, error) {
+	var output strings.Builder
+	if err := WriteFromTemplate(&output, opts); err != nil {
+		return "", err
+	}
+	return output.String(), nil
+}
+
+// WriteFromTemplate renders the template like CreateFromTemplate but streams the output to w
+// instead of building it in memory. Output may already have been written to w when an
+// execution error is returned.
+func WriteFromTemplate(w io.Writer, opts TemplateOptions) error {
+	data, err := buildTemplateData(opts)
+	if err != nil {
+		return err
+	}
+
+	// Clean up extra blank lines when TODOS is empty
+	if strings.TrimSpace(opts.TodosContent) == "" {
+		w = &blankLineWriter{w: w}
+	}
+
+	// Parse and execute the Go template
+	funcs := TemplateFunctions(opts.Sprig)
+	for name, fn := range opts.Calendar.Functions() {
+		funcs[name] = fn
+	}
+	for name, fn := range seededFunctions(opts.CurrentDate, opts.Deterministic) {
+		funcs[name] = fn
+	}
+	for name, fn := range journalFunctions(opts.Journal) {
+		funcs[name] = fn
+	}
+	for name, fn := range noteFunctions(opts.Notes) {
+		funcs[name] = fn
+	}
+	return executeTemplateTo(w, opts.Content, data, funcs)
+}
+
+// buildTemplateData validates the options and assembles the data passed to templates
+func buildTemplateData(opts TemplateOptions) (TemplateData, error) {
 	// Validate inputs
 	if err := validateTemplateInputs(opts.Content, opts.CurrentDate); err != nil {
-		return "", err
+		return TemplateData{}, err
 	}
 
 	// Validate custom variables if present
 	if opts.CustomVars != nil {
 		if err := ValidateCustomVariables(opts.CustomVars); err != nil {
-			return "", fmt.Errorf("invalid custom variables: %w", err)
+			return TemplateData{}, fmt.Errorf("invalid custom variables: %w", err)
 		}
 	}
 
@@ -322,6 +463,17 @@ func CreateFromTemplate(opts TemplateOptions) (string, error) {
 		TodoDates:                todoStats.TodoDates,
 		OldestTodoDate:           todoStats.OldestTodoDate,
 		TodoDaysSpan:             todoStats.TodoDaysSpan,
+		Goals:                    todoStats.Goals,
+		TopTodos:                 todoStats.TopTodos,
+
+		// Completion streaks (zero values if not provided)
+		CurrentStreak:   opts.Streaks.CurrentStreak,
+		LongestStreak:   opts.Streaks.LongestStreak,
+		WeeklyVelocity:  opts.Streaks.WeeklyVelocity,
+		CompletionHours: opts.Streaks.CompletionHours,
+
+		journal:  opts.Journal,
+		previous: opts.Previous,
 	}
 
 	// Merge custom variables if provided
@@ -329,18 +481,7 @@ func CreateFromTemplate(opts TemplateOptions) (string, error) {
 		MergeCustomVariables(&data, opts.CustomVars)
 	}
 
-	// Parse and execute the Go template
-	output, err := executeTemplate(opts.Content, data)
-	if err != nil {
-		return "", err
-	}
-
-	// Clean up extra blank lines when TODOS is empty
-	if strings.TrimSpace(opts.TodosContent) == "" {
-		output = cleanExcessiveBlankLines(output)
-	}
-
-	return output, nil
+	return data, nil
 }
 
 // CreateFromTemplateContentWithStats creates file content from template content using Go template syntax with todo statistics.
@@ -358,45 +499,184 @@ func CreateFromTemplateContentWithStats(templateContent, todosContent, currentDa
 // ProcessTodosSectionWithStats processes the Todos section and returns completed/uncompleted sections plus parsed journal.
 // Similar to ProcessTodosSection but also returns the original parsed journal for statistics calculation.
 func ProcessTodosSectionWithStats(todosSection string, originalDate string, currentDate string) (string, string, *TodoJournal, error) {
+	return ProcessTodosSectionWithStatsContext(context.Background(), todosSection, originalDate, currentDate)
+}
+
+// ProcessTodosSectionWithStatsContext is like ProcessTodosSectionWithStats but aborts with the
+// context's error if ctx is cancelled or its deadline expires while parsing.
+func ProcessTodosSectionWithStatsContext(ctx context.Context, todosSection string, originalDate string, currentDate string) (string, string, *TodoJournal, error) {
+	result, err := ProcessTodosSectionWithOptions(ctx, todosSection, originalDate, currentDate, ProcessOptions{Format: DefaultFormat})
+	if err != nil {
+		return "", "", nil, err
+	}
+	return result.Completed, result.Uncompleted, result.Journal, nil
+}
+
+// ProcessOptions configures ProcessTodosSectionWithOptions
+type ProcessOptions struct {
+	Format          Format // Layout of the Todos section, used for parsing and for both results
+	CollapseCarried bool   // Merge the uncompleted todos into one day section under the current date
+	AnnotateCarried bool   // With CollapseCarried, annotate carried todos with their original date
+	MaxCarry        int    // Carry at most this many top-level todos, see LimitCarried; 0 carries all
+	LineOffset      int    // Lines of the file before the todos section, added to the line of a JournalError
+
+	CascadeComplete    bool // Complete the subitems of completed todos, see CascadeCompleted
+	AutoCompleteParent bool // Complete todos whose subitems are all completed, see CompleteParents
+
+	Days     DayRange // Only day sections in the range are processed; the others stay as they are
+	Assignee string   // Only carry the open todos assigned to this person, see MatchAssignee; the others stay as they are
+
+	CompletionTime time.Time // Stamp date tags added on its day with its time of day, see CompletionStamp; zero stamps dates only
+
+	MovedMessage     string // Left in the todos section when no completed todos remain; empty means MovedToTemplate with the current date
+	OmitMovedMessage bool   // Leave the todos section empty instead of writing a moved message
+}
+
+// movedMessage returns the text left in the todos section of a processed journal
+// without completed todos, which were all moved to the journal of currentDate.
+func (opts ProcessOptions) movedMessage(currentDate string) string {
+	switch {
+	case opts.OmitMovedMessage:
+		return ""
+	case opts.MovedMessage != "":
+		return opts.MovedMessage
+	default:
+		return fmt.Sprintf(MovedToTemplate, currentDate)
+	}
+}
+
+// ProcessedTodos is the result of ProcessTodosSectionWithOptions
+type ProcessedTodos struct {
+	Completed   string       // Todos section left in the processed journal
+	Uncompleted string       // Todos section carried to the new journal
+	Overflow    *TodoJournal // Uncompleted todos beyond MaxCarry, with their original days
+	Journal     *TodoJournal // The parsed day sections in ProcessOptions.Days, for statistics
+	Warnings    []Warning    // Problems found while parsing the section
+	Done        *TodoJournal // Completed todos left in the processed journal, tagged with their date
+	Carried     *TodoJournal // Uncompleted todos carried over, with their original days
+}
+
+// ProcessTodosSectionWithOptions is like ProcessTodosSectionWithStatsContext, with the
+// layout of the section and the carrying of uncompleted todos configured by opts.
+func ProcessTodosSectionWithOptions(ctx context.Context, todosSection string, originalDate string, currentDate string, opts ProcessOptions) (*ProcessedTodos, error) {
+	format := opts.Format
 	// Validate inputs
 	if err := validateProcessInputs(originalDate, currentDate); err != nil {
-		return "", "", nil, err
+		return nil, err
 	}
 
 	// Handle empty todos section
 	if strings.TrimSpace(todosSection) == "" {
-		return fmt.Sprintf(MovedToTemplate, currentDate), "", &TodoJournal{}, nil
+		return &ProcessedTodos{
+			Completed: opts.movedMessage(currentDate),
+			Overflow:  &TodoJournal{Days: []*DaySection{}},
+			Journal:   &TodoJournal{},
+			Done:      &TodoJournal{Days: []*DaySection{}},
+			Carried:   &TodoJournal{Days: []*DaySection{}},
+		}, nil
 	}
 
 	// Parse the Todos section into a structured format
-	journal, err := ParseTodosSection(todosSection)
+	journal, warnings, err := ParseTodosSectionWarnings(ctx, todosSection, format)
 	if err != nil {
-		return "", "", nil, fmt.Errorf("failed to parse todos section: %w", err)
+		return nil, fmt.Errorf("failed to parse todos section: %w", shiftLines(err, opts.LineOffset))
 	}
 
-	// Move undated todos to the original date (the date from the file frontmatter)
-	journal = MoveUndatedTodosToCurrentDate(journal, originalDate)
+	// A section without day headers is a single day, that of the file frontmatter,
+	// and is written back without them. Otherwise undated todos are moved to that day.
+	headerless := journal.IsHeaderless()
+	if headerless {
+		journal.Days[0].Date = originalDate
+	} else {
+		journal = MoveUndatedTodosToCurrentDate(journal, originalDate)
+	}
 
-	// Split the journal into completed and uncompleted tasks
-	completedJournal, uncompletedJournal := SplitJournal(journal)
+	// Day sections outside the range are left alone
+	parsed := journal
+	journal, untouched := SplitDays(journal, opts.Days)
+
+	// Apply the completion rules before deciding what is done
+	if opts.CascadeComplete {
+		CascadeCompleted(journal)
+	}
+	if opts.AutoCompleteParent {
+		CompleteParents(journal)
+	}
+
+	// Split the journal into completed and uncompleted tasks, keeping or dropping
+	// the tasks with a carry directive, and keeping those of other assignees
+	var carry func(*TodoItem) bool
+	if opts.Assignee != "" {
+		carry = MatchAssignee(opts.Assignee)
+	}
+	completedJournal, uncompletedJournal := splitCarried(journal, carry)
 
 	// Add date tags to completed tasks
-	TagCompletedItems(completedJournal, originalDate)
+	stamp := CompletionStamp(originalDate, opts.CompletionTime)
+	TagCompletedItems(completedJournal, stamp)
 
 	// Add date tags to completed subtasks in uncompleted tasks
-	TagCompletedSubitems(uncompletedJournal, originalDate)
+	TagCompletedSubitems(uncompletedJournal, stamp)
+
+	// Kept tasks stay in the processed journal but are not done
+	doneJournal, _ := SplitJournal(completedJournal)
+
+	// Limit before collapsing, which loses the days the selection depends on
+	uncompletedJournal, overflow := LimitCarried(uncompletedJournal, opts.MaxCarry)
+	carriedJournal := uncompletedJournal
+
+	// Count the carry before the annotation, which has to stay at the end of the text
+	CountCarries(uncompletedJournal, format)
+
+	// Record when the carried todos were written down, which their day headers
+	// stop telling once collapsed or postponed
+	created := CreatedDates(uncompletedJournal, originalDate)
+	for _, day := range uncompletedJournal.Days {
+		for _, item := range day.Items {
+			StampCreated(item, created[item])
+		}
+	}
+
+	if opts.CollapseCarried {
+		uncompletedJournal = CollapseDays(uncompletedJournal, currentDate, opts.AnnotateCarried)
+	}
+
+	// Tasks waiting for another open task go below the others
+	uncompletedJournal, blockedJournal := SplitBlocked(uncompletedJournal)
+
+	// Put the untouched day sections back where they were
+	if !untouched.IsEmpty() {
+		completedJournal = restoreDays(parsed, completedJournal, opts.Days)
+	}
+
+	if headerless {
+		completedJournal = RemoveDayHeaders(completedJournal)
+		uncompletedJournal = RemoveDayHeaders(uncompletedJournal)
+		blockedJournal = RemoveDayHeaders(blockedJournal)
+	}
 
 	// Convert back to string format
-	completedSection := JournalToString(completedJournal)
-	uncompletedSection := JournalToString(uncompletedJournal)
+	completedSection := JournalToStringFormat(completedJournal, format)
+	uncompletedSection := JournalToStringFormat(uncompletedJournal, format)
+	if blockedSection := JournalToStringFormat(blockedJournal, format); blockedSection != "" {
+		uncompletedSection = strings.TrimLeft(uncompletedSection+"\n\n"+BlockedHeader+"\n"+blockedSection, "\n")
+	}
 
 	// If no completed tasks, provide moved message
 	if strings.TrimSpace(completedSection) == "" {
-		completedSection = fmt.Sprintf(MovedToTemplate, currentDate)
+		completedSection = opts.movedMessage(currentDate)
 	}
 
 	// Return original journal for statistics calculation
-	return completedSection, uncompletedSection, journal, nil
+	return &ProcessedTodos{
+		Completed:   completedSection,
+		Uncompleted: uncompletedSection,
+		Overflow:    overflow,
+		Journal:     journal,
+		Warnings:    shiftWarnings(warnings, opts.LineOffset),
+		Done:        doneJournal,
+		Carried:     carriedJournal,
+	}, nil
 }
 
 // CreateFromTemplateContentWithCustom creates template output with comprehensive data including custom variables.
@@ -433,5 +713,10 @@ func CreateTemplateFunctions() template.FuncMap {
 		result[k] = v
 	}
 
+	// Merge workday functions, without holidays
+	for k, v := range (Calendar{}).Functions() {
+		result[k] = v
+	}
+
 	return result
 }
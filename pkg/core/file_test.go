@@ -155,8 +155,8 @@ Some notes here`,
 
 Some notes here`,
 			expectedBefore: "# Title\n\n## Todos\n\n",
-			expectedTodos:  "## Notes\n\nSome notes here", // This is what actually gets extracted
-			expectedAfter:  "",
+			expectedTodos:  "",
+			expectedAfter:  "## Notes\n\nSome notes here",
 			expectError:    false,
 		},
 		{
@@ -274,6 +274,17 @@ func TestProcessTodosSection(t *testing.T) {
 			expectedUncomp:    "- [[2025-06-18]]\n  - [ ] Uncompleted task 1\n  - [ ] Uncompleted task 2",
 			expectError:       false,
 		},
+		{
+			name: "todos without day headers should stay without them",
+			todosSection: `- [x] Completed task
+- [ ] Uncompleted task
+  - [x] Completed subtask`,
+			originalDate:      "2025-06-18",
+			currentDate:       "2025-06-19",
+			expectedCompleted: "- [x] Completed task #2025-06-18",
+			expectedUncomp:    "- [ ] Uncompleted task\n  - [x] Completed subtask #2025-06-18",
+			expectError:       false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -914,6 +925,60 @@ func TestCleanExcessiveBlankLines(t *testing.T) {
 	}
 }
 
+func TestBlankLineWriter(t *testing.T) {
+	inputs := []string{
+		"Line 1\n\nLine 2",
+		"Line 1\n\n\nLine 2",
+		"Line 1\n\n\n\n\n\nLine 2",
+		"Line 1\n\n\nLine 2\n\n\n\nLine 3",
+		"",
+		"\n\n\n\n",
+	}
+
+	for _, input := range inputs {
+		expected := cleanExcessiveBlankLines(input)
+
+		// Write the input one byte at a time so newline runs span several writes
+		var out strings.Builder
+		writer := &blankLineWriter{w: &out}
+		for i := 0; i < len(input); i++ {
+			if _, err := writer.Write([]byte{input[i]}); err != nil {
+				t.Fatalf("Write() unexpected error: %v", err)
+			}
+		}
+
+		if out.String() != expected {
+			t.Errorf("blankLineWriter(%q) = %q, expected %q", input, out.String(), expected)
+		}
+	}
+}
+
+func TestWriteFromTemplate(t *testing.T) {
+	opts := TemplateOptions{
+		Content:      "# {{.Date}}\n\n\n\n{{.TODOS}}\n\n\n\nEnd",
+		TodosContent: "",
+		CurrentDate:  "2024-01-15",
+	}
+
+	expected, err := CreateFromTemplate(opts)
+	if err != nil {
+		t.Fatalf("CreateFromTemplate() unexpected error: %v", err)
+	}
+
+	var out strings.Builder
+	if err := WriteFromTemplate(&out, opts); err != nil {
+		t.Fatalf("WriteFromTemplate() unexpected error: %v", err)
+	}
+	if out.String() != expected {
+		t.Errorf("WriteFromTemplate() = %q, expected %q", out.String(), expected)
+	}
+
+	opts.CurrentDate = "invalid"
+	if err := WriteFromTemplate(&out, opts); err == nil {
+		t.Error("WriteFromTemplate() expected error for invalid date")
+	}
+}
+
 // Test constants
 func TestFileConstants(t *testing.T) {
 	if BlankLineSeparator != "\n\n" {
@@ -0,0 +1,107 @@
+// Package core provides the footnotes of carried todos for the todoer application.
+package core
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// footnoteRefRegex matches a footnote reference such as [^1] or [^source].
+	// Captures: (label)
+	footnoteRefRegex = regexp.MustCompile(`\[\^([^\]\s]+)\]`)
+
+	// footnoteDefRegex matches the first line of a footnote definition.
+	// Captures: (label)
+	footnoteDefRegex = regexp.MustCompile(`^ {0,3}\[\^([^\]\s]+)\]:`)
+
+	// footnotesStartRegex matches a blank line followed by a footnote definition
+	// in the first column, which ends the TODOS section
+	footnotesStartRegex = regexp.MustCompile(`\n\n\[\^[^\]\s]+\]:`)
+)
+
+// Footnote is a footnote definition in a journal file.
+type Footnote struct {
+	Label string // Label of the footnote, e.g. "1" for [^1]
+	Text  string // Lines of the definition, starting with "[^1]:", without the final line break
+}
+
+// FootnoteRefs returns the labels of the footnotes text refers to, in the order
+// of their first reference. References in code spans and links are left out,
+// and so are the labels of definitions.
+func FootnoteRefs(text string) []string {
+	var labels []string
+	seen := make(map[string]bool)
+	for _, m := range findInProse(footnoteRefRegex, text) {
+		lineStart := strings.LastIndexByte(text[:m[0]], '\n') + 1
+		if strings.HasPrefix(text[m[1]:], ":") && strings.TrimLeft(text[lineStart:m[0]], " ") == "" {
+			continue
+		}
+		if label := text[m[2]:m[3]]; !seen[label] {
+			seen[label] = true
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+// FootnoteDefinitions returns the footnote definitions of content in file order.
+// A definition continues over the indented lines after it, including blank lines
+// followed by more indented lines.
+func FootnoteDefinitions(content string) []Footnote {
+	var footnotes []Footnote
+	lines := strings.Split(content, "\n")
+	for i := 0; i < len(lines); i++ {
+		m := footnoteDefRegex.FindStringSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+		end := i + 1
+		for next := end; next < len(lines); next++ {
+			line := strings.TrimRight(lines[next], "\r")
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+				break
+			}
+			end = next + 1
+		}
+		footnotes = append(footnotes, Footnote{Label: m[1], Text: strings.TrimRight(strings.Join(lines[i:end], "\n"), "\r")})
+		i = end - 1
+	}
+	return footnotes
+}
+
+// CarryFootnotes returns content with the definitions of the footnotes referred
+// to by todos appended, as found in source, so that todos carried or moved from
+// source into content do not lose their footnotes. Footnotes content already
+// defines, and those source does not define, are left alone.
+func CarryFootnotes(content, todos, source string) string {
+	refs := FootnoteRefs(todos)
+	if len(refs) == 0 {
+		return content
+	}
+
+	defined := make(map[string]bool)
+	for _, footnote := range FootnoteDefinitions(content) {
+		defined[footnote.Label] = true
+	}
+	definitions := make(map[string]string)
+	for _, footnote := range FootnoteDefinitions(source) {
+		if _, ok := definitions[footnote.Label]; !ok {
+			definitions[footnote.Label] = footnote.Text
+		}
+	}
+
+	var carried []string
+	for _, label := range refs {
+		if text, ok := definitions[label]; ok && !defined[label] {
+			carried = append(carried, text)
+		}
+	}
+	if len(carried) == 0 {
+		return content
+	}
+	return strings.TrimRight(content, "\n") + BlankLineSeparator + strings.Join(carried, "\n") + "\n"
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFootnoteRefs(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Read the paper[^1] and the book[^book][^1]", []string{"1", "book"}},
+		{"- [ ] Task[^a]\n  - note[^b]", []string{"a", "b"}},
+		{"[^def]: a definition\n  [^ind]: indented definition", nil},
+		{"Code `[^1]` is no reference", nil},
+		{"No [^ spaced] label", nil},
+		{"Ends with a colon[^c]: still a reference", []string{"c"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := FootnoteRefs(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("FootnoteRefs(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestFootnoteDefinitions(t *testing.T) {
+	content := "# Notes\n\n[^1]: First\n[^long]: Long note\n    second line\n\n\tthird paragraph\n\nNot part of it\n   [^2]: Indented\n    [^code]: Too indented\n"
+	want := []Footnote{
+		{Label: "1", Text: "[^1]: First"},
+		{Label: "long", Text: "[^long]: Long note\n    second line\n\n\tthird paragraph"},
+		{Label: "2", Text: "   [^2]: Indented\n    [^code]: Too indented"},
+	}
+	if got := FootnoteDefinitions(content); !reflect.DeepEqual(got, want) {
+		t.Errorf("FootnoteDefinitions() = %q, want %q", got, want)
+	}
+}
+
+func TestCarryFootnotes(t *testing.T) {
+	source := "## Todos\n\n- [ ] Task[^a]\n\n[^a]: Note A\n[^b]: Note B\n[^c]: Note C\n"
+	tests := []struct {
+		name    string
+		content string
+		todos   string
+		want    string
+	}{
+		{"appended in order of reference", "## Todos\n\n- [ ] Task[^b] and[^a]\n", "- [ ] Task[^b] and[^a]", "## Todos\n\n- [ ] Task[^b] and[^a]\n\n[^b]: Note B\n[^a]: Note A\n"},
+		{"already defined", "## Todos\n\n- [ ] Task[^a]\n\n[^a]: Mine\n", "- [ ] Task[^a]", "## Todos\n\n- [ ] Task[^a]\n\n[^a]: Mine\n"},
+		{"not defined in source", "## Todos\n\n- [ ] Task[^z]\n", "- [ ] Task[^z]", "## Todos\n\n- [ ] Task[^z]\n"},
+		{"no references", "## Todos\n\n- [ ] Task\n", "- [ ] Task", "## Todos\n\n- [ ] Task\n"},
+	}
+	for _, tt := range tests {
+		if got := CarryFootnotes(tt.content, tt.todos, source); got != tt.want {
+			t.Errorf("%s: CarryFootnotes() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExtractTodosSectionBeforeFootnotes(t *testing.T) {
+	tests := []struct {
+		name                  string
+		content               string
+		before, section, rest string
+	}{
+		{"last section", "## Todos\n\n- [ ] Task[^1]\n\n[^1]: Note\n", "## Todos\n\n", "- [ ] Task[^1]", "\n\n[^1]: Note\n"},
+		{"before next section", "## Todos\n\n- [ ] Task[^1]\n\n[^1]: Note\n\n## Notes\n", "## Todos\n\n", "- [ ] Task[^1]", "\n\n[^1]: Note\n\n## Notes\n"},
+		{"empty section", "## Todos\n\n[^1]: Note\n", "## Todos\n\n", "", "[^1]: Note\n"},
+		{"indented definition stays", "## Todos\n\n- [ ] Task\n\n  [^1]: Note\n", "## Todos\n\n", "- [ ] Task\n\n  [^1]: Note", ""},
+	}
+	for _, tt := range tests {
+		before, section, rest, err := ExtractTodosSectionWithHeader(tt.content, TodosHeader)
+		if err != nil {
+			t.Errorf("%s: error: %v", tt.name, err)
+			continue
+		}
+		if before != tt.before || section != tt.section || rest != tt.rest {
+			t.Errorf("%s: got %q, %q, %q; want %q, %q, %q", tt.name, before, section, rest, tt.before, tt.section, tt.rest)
+		}
+	}
+
+	journal := "## Todos\n\n- [[2025-06-20]]\n  - [ ] Task[^1]\n\n[^1]: Note\n"
+	parsed, err := ParseTodosSectionFromContent(journal, TodosHeader)
+	if err != nil {
+		t.Fatalf("ParseTodosSectionFromContent() error: %v", err)
+	}
+	if got, err := NewRenderer().ReplaceJournal(journal, parsed); err != nil || got != journal {
+		t.Errorf("ReplaceJournal() = %q, %v; want the journal unchanged", got, err)
+	}
+}
@@ -0,0 +1,410 @@
+// Package core provides configurable TODOS section layout for the todoer application.
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// MaxIndentSpaces is the widest supported indentation level
+	MaxIndentSpaces = 8
+	// DefaultDayHeader is the time layout of the day headers todoer writes by default
+	DefaultDayHeader = "- [[2006-01-02]]"
+	// DefaultDateTag is the time layout of the completion date tags todoer writes by default
+	DefaultDateTag = "#2006-01-02"
+)
+
+// Format describes how the lines of a TODOS section are laid out.
+//
+// Parsed journals always hold bullet lines and date tags in the layout of
+// DefaultFormat, so code working on a TodoJournal does not depend on the format;
+// only parsing and writing do.
+type Format struct {
+	IndentSpaces int    // Spaces per indentation level, also the width of a tab; 0 means IndentSpaces
+	UseTabs      bool   // Indent with one tab per level instead of spaces
+	DayHeader    string // Time layout of day header lines, e.g. "### 2006-01-02"; empty means DefaultDayHeader
+	OpenMarkers  string // Checkbox characters of open todos, the first is written; empty means UncompletedMarker
+	DoneMarkers  string // Checkbox characters of completed todos, the first is written; empty means CompletedMarker
+	Keywords     bool   // Mark todos with TODO and DONE keywords instead of checkboxes, as Logseq does
+	GFM          bool   // Also read notes with * and + bullets; checkbox todos are read in the task list variations of GitHub either way
+	DateTag      string // Time layout of completion date tags, e.g. "✅ 2006-01-02"; empty means DefaultDateTag
+	CarryMarker  string // Layout of the carry count of todos with one %d, e.g. "↪×%d"; empty disables counting
+	MovedMessage string // Go template of the note left in processed journals, not warned about when parsing; empty means MovedToTemplate
+	Limits       Limits // Bounds of the input accepted when parsing; the zero value means the defaults
+}
+
+// Keywords marking todos in a Format with Keywords set, as Logseq writes them. The
+// first of each is written.
+var (
+	openKeywords = []string{"TODO", "LATER", "NOW", "DOING", "WAITING"}
+	doneKeywords = []string{"DONE"}
+)
+
+// DefaultFormat is the layout todoer writes unless configured otherwise.
+var DefaultFormat = Format{IndentSpaces: IndentSpaces}
+
+// Validate checks that the format can be used for parsing and writing.
+func (f Format) Validate() error {
+	if f.IndentSpaces < 0 || f.IndentSpaces > MaxIndentSpaces {
+		return fmt.Errorf("indentation must be between 1 and %d spaces, got %d", MaxIndentSpaces, f.IndentSpaces)
+	}
+	if _, err := f.dayHeaders(); err != nil {
+		return err
+	}
+	if err := f.validateMarkers(); err != nil {
+		return err
+	}
+	if _, err := f.carryMarker(); err != nil {
+		return err
+	}
+	_, err := f.dateTag()
+	return err
+}
+
+// markers returns the checkbox characters of open and of completed todos.
+func (f Format) markers() (string, string) {
+	open, done := f.OpenMarkers, f.DoneMarkers
+	if open == "" {
+		open = UncompletedMarker
+	}
+	if done == "" {
+		done = CompletedMarker
+	}
+	return open, done
+}
+
+// readMarkers returns the checkbox characters read for open and for completed
+// todos: those of markers, and also the other case of the completed ones not
+// marking open todos, as GitHub reads [X] like [x].
+func (f Format) readMarkers() (string, string) {
+	open, done := f.markers()
+	for _, r := range done {
+		for _, other := range []rune{unicode.ToUpper(r), unicode.ToLower(r)} {
+			if !strings.ContainsRune(open+done, other) {
+				done += string(other)
+			}
+		}
+	}
+	return open, done
+}
+
+// validateMarkers checks that the checkbox characters can be told apart.
+func (f Format) validateMarkers() error {
+	open, done := f.markers()
+	for _, r := range open + done {
+		if r == ']' || r == '\n' || r == '\r' {
+			return fmt.Errorf("checkbox marker %q is not allowed", r)
+		}
+	}
+	for _, r := range open {
+		if strings.ContainsRune(done, r) {
+			return fmt.Errorf("checkbox marker %q marks both open and completed todos", r)
+		}
+	}
+	return nil
+}
+
+// checkbox returns the checkbox, or with Keywords the keyword, written for a todo.
+func (f Format) checkbox(completed bool) string {
+	if f.Keywords {
+		if completed {
+			return doneKeywords[0]
+		}
+		return openKeywords[0]
+	}
+	return "[" + f.marker(completed) + "]"
+}
+
+// marker returns the checkbox character written for a todo.
+func (f Format) marker(completed bool) string {
+	open, done := f.markers()
+	if completed {
+		open = done
+	}
+	r, _ := utf8.DecodeRuneInString(open)
+	return string(r)
+}
+
+// isDone reports whether the checkbox character, or with Keywords the keyword,
+// marker marks a completed todo.
+func (f Format) isDone(marker string) bool {
+	if f.Keywords {
+		return slices.Contains(doneKeywords, marker)
+	}
+	_, done := f.readMarkers()
+	return marker != "" && strings.Contains(done, marker)
+}
+
+// dateTag returns the date tag layout of the format, or nil for DefaultDateTag,
+// whose tags need no conversion.
+func (f Format) dateTag() (*dateTag, error) {
+	if f.DateTag == "" || f.DateTag == DefaultDateTag {
+		return nil, nil
+	}
+	return newDateTag(f.DateTag)
+}
+
+// carryMarker returns the carry count marker of the format, or nil if carries are
+// not counted.
+func (f Format) carryMarker() (*carryMarker, error) {
+	if f.CarryMarker == "" {
+		return nil, nil
+	}
+	return newCarryMarker(f.CarryMarker)
+}
+
+// width returns the number of columns of one indentation level
+func (f Format) width() int {
+	if f.IndentSpaces <= 0 {
+		return IndentSpaces
+	}
+	return f.IndentSpaces
+}
+
+// indentWidth returns the width in columns of the leading indentation of line,
+// counting a tab as one level.
+func (f Format) indentWidth(line string) int {
+	columns := 0
+	for _, char := range line {
+		switch char {
+		case ' ':
+			columns++
+		case '\t':
+			columns += f.width()
+		default:
+			return columns
+		}
+	}
+	return columns
+}
+
+// indent returns the indentation of a line written at depth.
+func (f Format) indent(depth int) string {
+	return f.columns(depth * f.width())
+}
+
+// columns returns indentation spanning n columns, using tabs for whole levels if configured.
+func (f Format) columns(n int) string {
+	if f.UseTabs {
+		return strings.Repeat("\t", n/f.width()) + strings.Repeat(" ", n%f.width())
+	}
+	return strings.Repeat(" ", n)
+}
+
+// canonicalLine converts the leading indentation of line from f to DefaultFormat,
+// indenting it by outdent more levels. Indentation that is not a whole number of
+// levels keeps at most one extra space, which is enough to tell it apart from the
+// level below when written again.
+func (f Format) canonicalLine(line string, outdent int) string {
+	text := strings.TrimLeft(line, " \t")
+	columns := f.indentWidth(line)
+	rem := min(columns%f.width(), IndentSpaces-1)
+	return strings.Repeat(" ", (columns/f.width()+outdent)*IndentSpaces+rem) + text
+}
+
+// formatLine converts the leading indentation of a canonical line to f, removing
+// outdent levels of indentation.
+func (f Format) formatLine(line string, outdent int) string {
+	text := strings.TrimLeft(line, " \t")
+	columns := max(GetIndentLevel(line)-outdent*IndentSpaces, 0)
+	return f.columns(columns/IndentSpaces*f.width()+columns%IndentSpaces) + text
+}
+
+// dayHeaders returns the day headers the format reads, the one it writes first.
+// Journals with DefaultDayHeader headers stay readable after the layout is changed.
+func (f Format) dayHeaders() ([]*dayHeader, error) {
+	layout := f.DayHeader
+	if layout == "" {
+		layout = DefaultDayHeader
+	}
+	header, err := newDayHeader(layout)
+	if err != nil {
+		return nil, err
+	}
+	if layout == DefaultDayHeader {
+		return []*dayHeader{header}, nil
+	}
+	return []*dayHeader{header, defaultDayHeader}, nil
+}
+
+// defaultDayHeader reads and writes DefaultDayHeader lines
+var defaultDayHeader, _ = newDayHeader(DefaultDayHeader)
+
+// layoutElements maps the date elements of a time layout to the text they match,
+// longest element first
+var layoutElements = []struct{ element, pattern string }{
+	{"January", `[A-Z][a-z]+`},
+	{"Jan", `[A-Z][a-z]{2}`},
+	{"Monday", `[A-Z][a-z]+`},
+	{"Mon", `[A-Z][a-z]{2}`},
+	{"2006", `\d{4}`},
+	{"002", `\d{3}`},
+	{"_2", `[ \d]\d`},
+	{"01", `\d{2}`},
+	{"02", `\d{2}`},
+	{"06", `\d{2}`},
+	{"1", `\d{1,2}`},
+	{"2", `\d{1,2}`},
+}
+
+// dayHeaderCheckDates are formatted and parsed again to validate a layout. Their
+// year, month and day differ so that a layout omitting one of them is noticed.
+var dayHeaderCheckDates = []time.Time{
+	time.Date(2025, 6, 21, 0, 0, 0, 0, time.UTC),
+	time.Date(1999, 11, 3, 0, 0, 0, 0, time.UTC),
+}
+
+// layoutPattern returns a regular expression matching the dates formatted with layout.
+func layoutPattern(layout string) string {
+	var pattern strings.Builder
+	for rest := layout; rest != ""; {
+		matched := false
+		for _, e := range layoutElements {
+			if strings.HasPrefix(rest, e.element) {
+				pattern.WriteString(e.pattern)
+				rest = rest[len(e.element):]
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			_, size := utf8.DecodeRuneInString(rest)
+			pattern.WriteString(regexp.QuoteMeta(rest[:size]))
+			rest = rest[size:]
+		}
+	}
+	return pattern.String()
+}
+
+// dayHeader reads and writes day header lines of one time layout
+type dayHeader struct {
+	layout  string         // Time layout of the trimmed header line
+	pattern *regexp.Regexp // Matches a trimmed line consisting of a header only
+}
+
+// newDayHeader creates a dayHeader for layout, which must contain the year, month
+// and day of the date and no time of day.
+func newDayHeader(layout string) (*dayHeader, error) {
+	if strings.TrimSpace(layout) != layout || strings.Contains(layout, "\n") {
+		return nil, fmt.Errorf("day header %q must be a single line without surrounding space", layout)
+	}
+
+	header := &dayHeader{layout: layout, pattern: regexp.MustCompile("^" + layoutPattern(layout) + "$")}
+	for _, date := range dayHeaderCheckDates {
+		line := date.Format(layout)
+		if parsed, ok, err := header.parse(line); !ok || err != nil || parsed != date.Format(DateFormat) {
+			return nil, fmt.Errorf("day header %q must contain the year, month and day of the date and nothing else that changes with it", layout)
+		}
+	}
+	return header, nil
+}
+
+// parse returns the date of a trimmed day header line in DateFormat and whether
+// the line is a header at all. An error is returned for a header with an invalid date.
+func (h *dayHeader) parse(line string) (string, bool, error) {
+	if !h.pattern.MatchString(line) {
+		return "", false, nil
+	}
+	date, err := time.Parse(h.layout, line)
+	if err != nil {
+		return "", true, fmt.Errorf("invalid date '%s', expected %s", line, h.layout)
+	}
+	return date.Format(DateFormat), true, nil
+}
+
+// format returns the header line of a date in DateFormat. Dates that cannot be
+// parsed are written as DefaultDayHeader, keeping them readable.
+func (h *dayHeader) format(date string) string {
+	parsed, err := time.Parse(DateFormat, date)
+	if err != nil {
+		return "- [[" + date + "]]"
+	}
+	return parsed.Format(h.layout)
+}
+
+// outdent returns how many levels less than DefaultDayHeader the items of a day are
+// indented: headers that are not list items do not nest the items below them.
+func (h *dayHeader) outdent() int {
+	if strings.HasPrefix(h.layout, "- ") {
+		return 0
+	}
+	return 1
+}
+
+// dateTag converts completion date tags between a time layout and DefaultDateTag
+type dateTag struct {
+	layout      string         // Time layout of the tags
+	pattern     *regexp.Regexp // Matches a tag anywhere in a todo text
+	timeLayout  string         // Time layout of the tags with a time of day
+	timePattern *regexp.Regexp // Matches a tag with a time of day anywhere in a todo text
+}
+
+// newDateTag creates a dateTag for layout, which must contain the year, month and
+// day of the date and no time of day. Tags with a time of day write it after the
+// day, e.g. "✅ 2025-06-21 14:32", or at the end if the layout has no ISO date.
+func newDateTag(layout string) (*dateTag, error) {
+	if strings.TrimSpace(layout) != layout || strings.Contains(layout, "\n") {
+		return nil, fmt.Errorf("date tag %q must be a single line without surrounding space", layout)
+	}
+	if r, _ := utf8.DecodeRuneInString(layout); unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+		// Without a marker, every date in a todo text would be read as a tag
+		return nil, fmt.Errorf("date tag %q must start with a marker such as # or ✅", layout)
+	}
+	end := len(layout)
+	if i := strings.Index(layout, DateFormat); i >= 0 {
+		end = i + len(DateFormat)
+	}
+	tag := &dateTag{
+		layout:      layout,
+		pattern:     regexp.MustCompile(layoutPattern(layout)),
+		timeLayout:  layout[:end] + " " + CompletionTimeFormat + layout[end:],
+		timePattern: regexp.MustCompile(layoutPattern(layout[:end]) + ` \d{2}:\d{2}` + layoutPattern(layout[end:])),
+	}
+	for _, date := range dayHeaderCheckDates {
+		text := "Task " + date.Format(layout)
+		if canonical := tag.canonical(text); canonical != "Task #"+date.Format(DateFormat) {
+			return nil, fmt.Errorf("date tag %q must contain the year, month and day of the date and nothing else that changes with it", layout)
+		}
+	}
+	return tag, nil
+}
+
+// canonical returns text with its tags converted to DefaultDateTag, keeping their
+// time of day as in "#2025-06-21T14:32". Matches that are no valid date or lie in
+// code spans and links are left alone.
+func (t *dateTag) canonical(text string) string {
+	text = replaceInProse(t.timePattern, text, func(match string) string {
+		stamp, err := time.Parse(t.timeLayout, match)
+		if err != nil {
+			return match
+		}
+		return stamp.Format(completionTagLayout)
+	})
+	return replaceInProse(t.pattern, text, func(match string) string {
+		date, err := time.Parse(t.layout, match)
+		if err != nil {
+			return match
+		}
+		return date.Format(DefaultDateTag)
+	})
+}
+
+// format returns text with its DefaultDateTag tags converted to the layout.
+func (t *dateTag) format(text string) string {
+	return replaceInProse(completionTagRegex, text, func(match string) string {
+		if stamp, err := time.Parse(completionTagLayout, match); err == nil {
+			return stamp.Format(t.timeLayout)
+		}
+		date, err := time.Parse(DefaultDateTag, match[:len(DefaultDateTag)])
+		if err != nil {
+			return match
+		}
+		return date.Format(t.layout) + match[len(DefaultDateTag):]
+	})
+}
@@ -0,0 +1,240 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestFormatRoundTrip tests parsing and writing a section in each supported indentation style
+func TestFormatRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		input  string
+	}{
+		{
+			name:   "two spaces",
+			format: DefaultFormat,
+			input:  "- [[2025-06-20]]\n  - [ ] Parent\n    - note\n      nested note\n    - [x] Child\n      - child note",
+		},
+		{
+			name:   "four spaces",
+			format: Format{IndentSpaces: 4},
+			input:  "- [[2025-06-20]]\n    - [ ] Parent\n        - note\n            nested note\n        - [x] Child\n            - child note",
+		},
+		{
+			name:   "tabs",
+			format: Format{IndentSpaces: 2, UseTabs: true},
+			input:  "- [[2025-06-20]]\n\t- [ ] Parent\n\t\t- note\n\t\t\tnested note\n\t\t- [x] Child\n\t\t\t- child note",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			journal, err := ParseTodosSectionFormat(context.Background(), tt.input, tt.format)
+			if err != nil {
+				t.Fatalf("ParseTodosSectionFormat() error = %v", err)
+			}
+
+			// Every format parses to the same journal
+			parent := journal.Days[0].Items[0]
+			if parent.Text != "Parent" || len(parent.SubItems) != 1 || parent.SubItems[0].Text != "Child" {
+				t.Fatalf("unexpected structure: %+v", parent)
+			}
+			wantBullets := []string{"    - note", "      nested note"}
+			if len(parent.BulletLines) != len(wantBullets) {
+				t.Fatalf("BulletLines = %q, want %q", parent.BulletLines, wantBullets)
+			}
+			for i, line := range wantBullets {
+				if parent.BulletLines[i] != line {
+					t.Errorf("BulletLines[%d] = %q, want %q", i, parent.BulletLines[i], line)
+				}
+			}
+
+			if got := JournalToStringFormat(journal, tt.format); got != tt.input {
+				t.Errorf("JournalToStringFormat() = %q, want %q", got, tt.input)
+			}
+		})
+	}
+}
+
+// TestFormatKeywords tests reading and writing todos marked with Logseq keywords
+func TestFormatKeywords(t *testing.T) {
+	format := Format{IndentSpaces: 2, UseTabs: true, Keywords: true}
+	input := "- [[2025-06-20]]\n\t- LATER Parent\n\t\tcollapsed:: true\n\t\t- NOW Child\n\t\t- DONE Finished #2025-06-20\n\t\t- [ ] Checkbox is a note"
+
+	journal, err := ParseTodosSectionFormat(context.Background(), input, format)
+	if err != nil {
+		t.Fatalf("ParseTodosSectionFormat() error = %v", err)
+	}
+	parent := journal.Days[0].Items[0]
+	if parent.Text != "Parent" || parent.Completed || len(parent.SubItems) != 2 {
+		t.Fatalf("unexpected structure: %+v", parent)
+	}
+	if child, done := parent.SubItems[0], parent.SubItems[1]; child.Text != "Child" || child.Completed || done.Text != "Finished #2025-06-20" || !done.Completed {
+		t.Errorf("unexpected subtasks: %+v, %+v", child, done)
+	}
+
+	// Checkboxes are notes of the task above, like any other bullet
+	want := "- [[2025-06-20]]\n\t- TODO Parent\n\t\tcollapsed:: true\n\t\t- [ ] Checkbox is a note\n\t\t- TODO Child\n\t\t- DONE Finished #2025-06-20"
+	if got := JournalToStringFormat(journal, format); got != want {
+		t.Errorf("JournalToStringFormat() = %q, want %q", got, want)
+	}
+}
+
+func TestFormatGFM(t *testing.T) {
+	format := Format{IndentSpaces: 2, GFM: true}
+	input := "- [[2025-06-20]]\n  * [ ] Star bullet\n    + [X] Plus bullet, upper case\n  - [ x ] Spaces inside\n  - [  ] Open with spaces\n  - [ ] Footnote[^1] and [link](https://example.com)\n  * plain note"
+
+	journal, err := ParseTodosSectionFormat(context.Background(), input, format)
+	if err != nil {
+		t.Fatalf("ParseTodosSectionFormat() error = %v", err)
+	}
+	items := journal.Days[0].Items
+	if len(items) != 4 || len(items[0].SubItems) != 1 || !items[0].SubItems[0].Completed || !items[1].Completed || items[2].Completed {
+		t.Fatalf("unexpected structure: %+v", items)
+	}
+
+	want := "- [[2025-06-20]]\n  - [ ] Star bullet\n    - [x] Plus bullet, upper case\n  - [x] Spaces inside\n  - [ ] Open with spaces\n  - [ ] Footnote[^1] and [link](https://example.com)\n    * plain note"
+	if got := JournalToStringFormat(journal, format); got != want {
+		t.Errorf("JournalToStringFormat() = %q, want %q", got, want)
+	}
+
+	// Without GFM the todos are read all the same, but not notes with other bullets
+	journal, err = ParseTodosSectionFormat(context.Background(), strings.Replace(input, "  * plain note", "  - plain note", 1), Format{IndentSpaces: 2})
+	if err != nil {
+		t.Fatalf("ParseTodosSectionFormat() without GFM error = %v", err)
+	}
+	if got := JournalToStringFormat(journal, format); got != strings.Replace(want, "    * plain note", "    - plain note", 1) {
+		t.Errorf("JournalToStringFormat() without GFM = %q", got)
+	}
+	if _, warnings, err := ParseTodosSectionWarnings(context.Background(), "- [[2025-06-20]]\n* Star note", DefaultFormat); err != nil || len(warnings) != 1 {
+		t.Errorf("ParseTodosSectionWarnings() of a * note without GFM = %v, %v; want it dropped with a warning", warnings, err)
+	}
+}
+
+// TestFormatValidate tests the accepted range of indentation widths
+func TestFormatValidate(t *testing.T) {
+	tests := []struct {
+		format  Format
+		wantErr bool
+	}{
+		{Format{}, false},
+		{Format{IndentSpaces: 1}, false},
+		{Format{IndentSpaces: MaxIndentSpaces, UseTabs: true}, false},
+		{Format{IndentSpaces: -1}, true},
+		{Format{IndentSpaces: MaxIndentSpaces + 1}, true},
+		{Format{OpenMarkers: " /", DoneMarkers: "xX-"}, false},
+		{Format{OpenMarkers: " x"}, true},
+		{Format{DoneMarkers: "]"}, true},
+		{Format{DateTag: "✅ 2006-01-02"}, false},
+		{Format{DateTag: "@done(2006-01-02)"}, false},
+		{Format{DateTag: "2006-01-02"}, true},
+		{Format{DateTag: "#2006-01"}, true},
+		{Format{CarryMarker: "↪×%d"}, false},
+		{Format{CarryMarker: "(carried %d)"}, false},
+		{Format{CarryMarker: "↪×"}, true},
+		{Format{CarryMarker: "%d×"}, true},
+		{Format{CarryMarker: "↪%d/%d"}, true},
+		{Format{CarryMarker: "↪%s"}, true},
+	}
+
+	for _, tt := range tests {
+		if err := tt.format.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%+v.Validate() error = %v, wantErr %v", tt.format, err, tt.wantErr)
+		}
+	}
+
+	if _, err := ParseTodosSectionFormat(context.Background(), "", Format{IndentSpaces: 9}); err == nil {
+		t.Error("ParseTodosSectionFormat() accepted an invalid format")
+	}
+}
+
+// TestFormatDayHeader tests reading and writing day headers in configured layouts
+func TestFormatDayHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		layout string
+		output string
+	}{
+		{
+			name:   "default",
+			layout: "",
+			output: "- [[2025-06-21]]\n  - [ ] Task\n    - note\n    - [ ] Subtask",
+		},
+		{
+			name:   "heading",
+			layout: "### 2006-01-02",
+			output: "### 2025-06-21\n- [ ] Task\n  - note\n  - [ ] Subtask",
+		},
+		{
+			name:   "plain list item",
+			layout: "- 2006-01-02",
+			output: "- 2025-06-21\n  - [ ] Task\n    - note\n    - [ ] Subtask",
+		},
+		{
+			name:   "aliased wiki link",
+			layout: "[[2006-01-02|Mon 02 Jan]]",
+			output: "[[2025-06-21|Sat 21 Jun]]\n- [ ] Task\n  - note\n  - [ ] Subtask",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format := Format{DayHeader: tt.layout}
+			journal := &TodoJournal{Days: []*DaySection{{
+				Date: "2025-06-21",
+				Items: []*TodoItem{{
+					Text:        "Task",
+					BulletLines: []string{"    - note"},
+					SubItems:    []*TodoItem{{Text: "Subtask", SubItems: []*TodoItem{}, BulletLines: []string{}}},
+				}},
+			}}}
+
+			output := JournalToStringFormat(journal, format)
+			if output != tt.output {
+				t.Fatalf("JournalToStringFormat() = %q, want %q", output, tt.output)
+			}
+
+			parsed, err := ParseTodosSectionFormat(context.Background(), output, format)
+			if err != nil {
+				t.Fatalf("ParseTodosSectionFormat() error = %v", err)
+			}
+			if again := JournalToString(parsed); again != tests[0].output {
+				t.Errorf("parsed journal differs from the original, got:\n%s", again)
+			}
+
+			// Journals written before the layout was configured are still read
+			old, err := ParseTodosSectionFormat(context.Background(), tests[0].output, format)
+			if err != nil {
+				t.Fatalf("ParseTodosSectionFormat() of default headers error = %v", err)
+			}
+			if again := JournalToStringFormat(old, format); again != tt.output {
+				t.Errorf("default headers are not converted, got %q, want %q", again, tt.output)
+			}
+		})
+	}
+}
+
+// TestFormatDayHeaderErrors tests rejecting unusable layouts and invalid header dates
+func TestFormatDayHeaderErrors(t *testing.T) {
+	layouts := []string{
+		"### 01-02",                // no year
+		"### 2006-01",              // no day
+		"### 2006-01-02 15:04",     // time of day
+		" ### 2006-01-02",          // surrounding space
+		"### 2006-01-02\n- [ ] x",  // more than one line
+		"Standup on Jan 2, 2006 3", // hour
+	}
+	for _, layout := range layouts {
+		if err := (Format{DayHeader: layout}).Validate(); err == nil {
+			t.Errorf("Validate() accepted day header %q", layout)
+		}
+	}
+
+	format := Format{DayHeader: "### 2006-01-02"}
+	if _, err := ParseTodosSectionFormat(context.Background(), "### 2025-13-45\n- [ ] Task", format); err == nil {
+		t.Error("ParseTodosSectionFormat() accepted an invalid date in a day header")
+	}
+}
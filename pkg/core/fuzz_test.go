@@ -0,0 +1,117 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+// FuzzParseTodosSection checks that arbitrary input never panics the parser
+// and that serializing a parsed journal is a fixed point: parsing and
+// serializing todoer's own output yields the same output again.
+func FuzzParseTodosSection(f *testing.F) {
+	seeds := []string{
+		"",
+		"- [[2025-06-20]]\n  - [ ] Task\n  - [x] Done #2025-06-20",
+		"- [ ] Undated task\n- [x] Undated done",
+		"- [[2025-06-20]]\n  - [ ] Parent\n    - [ ] Child\n      - [x] Grandchild\n    Continuation\n    - bullet",
+		"- [[2025-06-20]]\n\t- [ ] Tab indented\n\t\t- [ ] Deeper",
+		"- [[2025-13-45]]\n  - [ ] Invalid date",
+		"- [[2025-06-20]]\nunparseable line",
+		"- [[2025-06-20]]\n      - [ ] Over-indented first item\n  - [ ] Back out",
+		"- [[2025-06-20]]\n  - [ ] Ünïcödé täsk 🎉\n    　full-width space",
+		"  - bullet before any day\n- [[2025-06-20]]",
+		"- [[2025-06-20]]\n  - [ ] Task\n> [!todo] Focus\n> - [ ] Quoted\n>   - note\n> text\n  - [ ] After",
+		"> [!todo] Inbox\n> - [x] Undated quoted\n>\n> > - [ ] Nested quote",
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, content string) {
+		journal, err := ParseTodosSection(content)
+		if err != nil {
+			return
+		}
+		if journal == nil {
+			t.Fatal("ParseTodosSection returned nil journal without error")
+		}
+		_ = CalculateTodoStatistics(journal, "2025-06-21")
+
+		output := JournalToString(journal)
+		reparsed, err := ParseTodosSection(output)
+		if err != nil {
+			t.Fatalf("failed to parse serialized journal %q: %v", output, err)
+		}
+		if again := JournalToString(reparsed); again != output {
+			t.Fatalf("round trip is not idempotent:\ninput:  %q\nfirst:  %q\nsecond: %q", content, output, again)
+		}
+	})
+}
+
+// FuzzExtractTodosSection checks that section extraction never panics and that
+// the returned pieces always come from the original content in order.
+func FuzzExtractTodosSection(f *testing.F) {
+	seeds := []string{
+		"## Todos\n\n- [ ] Task",
+		"# Title\n\n## Todos\n\n- [ ] Task\n\n## Notes\n\nNotes",
+		"## Todos",
+		"## Todos\n",
+		"## Todos\n\n",
+		"## Todos no blank line\n- [ ] Task",
+		"text ## Todos\n\n\n\n## Next",
+		"## Todos\n\n## Todos\n\n## Todos",
+	}
+	for _, seed := range seeds {
+		f.Add(seed, TodosHeader)
+	}
+	f.Add("### Tasks\n\n- [ ] Task", "### Tasks")
+
+	f.Fuzz(func(t *testing.T, content, header string) {
+		if header == "" {
+			return
+		}
+		before, section, after, err := ExtractTodosSectionWithHeader(content, header)
+		if err != nil {
+			return
+		}
+		if !strings.HasPrefix(content, before) {
+			t.Fatalf("before section %q is not a prefix of content %q", before, content)
+		}
+		if !strings.HasSuffix(content, after) {
+			t.Fatalf("after section %q is not a suffix of content %q", after, content)
+		}
+		if len(before)+len(after) > len(content) {
+			t.Fatalf("before %q and after %q overlap in %q", before, after, content)
+		}
+		if strings.TrimSpace(content[len(before):len(content)-len(after)]) != section {
+			t.Fatalf("todos section %q is not what lies between before and after in %q", section, content)
+		}
+	})
+}
+
+// FuzzExtractDateFromFrontmatter checks that frontmatter parsing never panics
+// and only ever returns valid dates.
+func FuzzExtractDateFromFrontmatter(f *testing.F) {
+	seeds := []string{
+		"---\ntitle: 2025-06-20\n---\n",
+		"---\ntitle: 2025-13-45\n---\n",
+		"---\ndate: 2025-06-20\ntitle: Something\n---\n",
+		"---\ntitle: 2025-06-20",
+		"no frontmatter at all",
+		"------",
+	}
+	for _, seed := range seeds {
+		f.Add(seed, "title")
+	}
+	f.Add("---\nweird.key*: 2025-06-20\n---\n", "weird.key*")
+
+	f.Fuzz(func(t *testing.T, content, key string) {
+		date, err := ExtractDateFromFrontmatter(content, key)
+		if err != nil {
+			return
+		}
+		if err := ValidateDate(date); err != nil {
+			t.Fatalf("ExtractDateFromFrontmatter returned invalid date %q: %v", date, err)
+		}
+	})
+}
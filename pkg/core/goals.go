@@ -0,0 +1,92 @@
+// Package core provides goal tracking functionality for the todoer application.
+package core
+
+import (
+	"regexp"
+	"sort"
+)
+
+// GoalRegex matches a goal annotation such as goal::[[Q3 Objectives#Ship v2]]
+//
+// Deprecated: goals are parsed with the other annotations, see ParseAnnotations.
+var GoalRegex = regexp.MustCompile(`goal::\[\[([^\[\]]+)\]\]`)
+
+// GoalProgress counts the completed and open todos linked to a goal.
+type GoalProgress struct {
+	Goal      string // Goal link target, e.g. "Q3 Objectives#Ship v2"
+	Completed int    // Number of completed todos linked to the goal
+	Open      int    // Number of open todos linked to the goal
+}
+
+// Total returns the number of todos linked to the goal.
+func (g GoalProgress) Total() int {
+	return g.Completed + g.Open
+}
+
+// Percent returns the share of completed todos as a whole percentage.
+func (g GoalProgress) Percent() int {
+	if g.Total() == 0 {
+		return 0
+	}
+	return g.Completed * 100 / g.Total()
+}
+
+// ExtractGoal returns the goal a todo text is linked to, or an empty string.
+// If the text contains several goal annotations, the first one wins.
+func ExtractGoal(text string) string {
+	return annotationValue(text, "goal")
+}
+
+// CalculateGoalProgress counts the completed and open todos per goal across journals.
+// Subitems without their own goal annotation count towards their parent's goal.
+// Todos not linked to any goal are ignored. The result is sorted by goal name.
+func CalculateGoalProgress(journals ...*TodoJournal) []GoalProgress {
+	progress := make(map[string]*GoalProgress)
+
+	var walk func(items []*TodoItem, inherited string)
+	walk = func(items []*TodoItem, inherited string) {
+		for _, item := range items {
+			if item == nil {
+				continue
+			}
+			goal := ExtractGoal(item.Text)
+			if goal == "" {
+				goal = inherited
+			}
+			if goal != "" {
+				entry, ok := progress[goal]
+				if !ok {
+					entry = &GoalProgress{Goal: goal}
+					progress[goal] = entry
+				}
+				if item.Completed {
+					entry.Completed++
+				} else {
+					entry.Open++
+				}
+			}
+			walk(item.SubItems, goal)
+		}
+	}
+
+	for _, journal := range journals {
+		if journal == nil {
+			continue
+		}
+		for _, day := range journal.Days {
+			if day != nil {
+				walk(day.Items, "")
+			}
+		}
+	}
+
+	result := make([]GoalProgress, 0, len(progress))
+	for _, entry := range progress {
+		result = append(result, *entry)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Goal < result[j].Goal
+	})
+
+	return result
+}
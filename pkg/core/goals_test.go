@@ -0,0 +1,64 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractGoal(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Write spec goal::[[Q3 Objectives#Ship v2]]", "Q3 Objectives#Ship v2"},
+		{"goal::[[Hiring]] and goal::[[Other]]", "Hiring"},
+		{"Link to [[Q3 Objectives]] without goal", ""},
+		{"goal::[[]] empty", ""},
+		{"Plain task", ""},
+	}
+
+	for _, tt := range tests {
+		if got := ExtractGoal(tt.text); got != tt.want {
+			t.Errorf("ExtractGoal(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestCalculateGoalProgress(t *testing.T) {
+	first, err := ParseTodosSection(`- [[2025-06-20]]
+  - [x] Write spec goal::[[Q3#Ship v2]] #2025-06-20
+  - [ ] Implement goal::[[Q3#Ship v2]]
+    - [x] Parser
+    - [ ] Docs goal::[[Docs]]
+  - [ ] Unrelated task`)
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error: %v", err)
+	}
+	second, err := ParseTodosSection(`- [[2025-06-21]]
+  - [x] Interview goal::[[Hiring]]`)
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error: %v", err)
+	}
+
+	got := CalculateGoalProgress(first, nil, second)
+	want := []GoalProgress{
+		{Goal: "Docs", Completed: 0, Open: 1},
+		{Goal: "Hiring", Completed: 1, Open: 0},
+		{Goal: "Q3#Ship v2", Completed: 2, Open: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CalculateGoalProgress() = %+v, want %+v", got, want)
+	}
+
+	if got[2].Total() != 3 || got[2].Percent() != 66 {
+		t.Errorf("Q3 progress = %d total, %d%%, want 3 total, 66%%", got[2].Total(), got[2].Percent())
+	}
+	if (GoalProgress{}).Percent() != 0 {
+		t.Error("Percent() of an empty goal should be 0")
+	}
+
+	stats := CalculateTodoStatistics(first, "2025-06-21")
+	if len(stats.Goals) != 2 {
+		t.Errorf("CalculateTodoStatistics() Goals = %+v, want 2 goals", stats.Goals)
+	}
+}
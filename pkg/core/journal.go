@@ -18,6 +18,54 @@ const (
 // associated bullet points, and another containing only uncompleted items.
 // Days with no items of the respective type are omitted from the result.
 func SplitJournal(journal *TodoJournal) (*TodoJournal, *TodoJournal) {
+	return splitJournal(journal, func(item *TodoItem) splitSide {
+		if IsCompleted(item) {
+			return sideCompleted
+		}
+		return sideUncompleted
+	})
+}
+
+// SplitCarried splits the journal like SplitJournal, following the carry directives
+// of uncompleted top-level items: items to keep (see CarryKeep) go with the completed
+// items and stay in the processed journal, items to drop (see CarryDrop) are left
+// out of both journals.
+func SplitCarried(journal *TodoJournal) (*TodoJournal, *TodoJournal) {
+	return splitCarried(journal, nil)
+}
+
+// splitCarried is SplitCarried keeping the uncompleted top-level items carry does
+// not accept in the processed journal, like CarryKeep. A nil carry accepts all.
+func splitCarried(journal *TodoJournal, carry func(*TodoItem) bool) (*TodoJournal, *TodoJournal) {
+	return splitJournal(journal, func(item *TodoItem) splitSide {
+		if IsCompleted(item) {
+			return sideCompleted
+		}
+		if carry != nil && !carry(item) {
+			return sideCompleted
+		}
+		switch CarryDirective(item.Text) {
+		case CarryKeep:
+			return sideCompleted
+		case CarryDrop:
+			return sideDropped
+		}
+		return sideUncompleted
+	})
+}
+
+// splitSide is where splitJournal puts a top-level item
+type splitSide int
+
+const (
+	sideCompleted splitSide = iota
+	sideUncompleted
+	sideDropped
+)
+
+// splitJournal copies the top-level items of journal into a journal of completed
+// and one of uncompleted items as side says, dropping the items it drops.
+func splitJournal(journal *TodoJournal, side func(*TodoItem) splitSide) (*TodoJournal, *TodoJournal) {
 	if journal == nil {
 		return &TodoJournal{Days: []*DaySection{}}, &TodoJournal{Days: []*DaySection{}}
 	}
@@ -48,18 +96,25 @@ func SplitJournal(journal *TodoJournal) (*TodoJournal, *TodoJournal) {
 
 		hasCompletedItems := false
 		hasUncompletedItems := false
+		var callout []string // Callout of the blockquote the item is in
 
 		for _, item := range day.Items {
-			if IsCompleted(item) {
+			if item != nil && (item.Quote == "" || len(item.Callout) > 0) {
+				callout = item.Callout
+			}
+			switch side(item) {
+			case sideCompleted:
 				hasCompletedItems = true
 				// Create a deep copy of the item for the completed journal
 				if copiedItem := DeepCopyItem(item); copiedItem != nil {
+					inheritCallout(copiedItem, callout, completedDay)
 					completedDay.Items = append(completedDay.Items, copiedItem)
 				}
-			} else {
+			case sideUncompleted:
 				hasUncompletedItems = true
 				// Create a deep copy of the item for the uncompleted journal
 				if copiedItem := DeepCopyItem(item); copiedItem != nil {
+					inheritCallout(copiedItem, callout, uncompletedDay)
 					uncompletedDay.Items = append(uncompletedDay.Items, copiedItem)
 				}
 			}
@@ -77,8 +132,71 @@ func SplitJournal(journal *TodoJournal) (*TodoJournal, *TodoJournal) {
 	return completedJournal, uncompletedJournal
 }
 
+// CascadeCompleted marks all subitems of completed items in the journal completed,
+// so that checking off a parent completes its whole subtree. Without it, a completed
+// parent with open subitems stays open, see IsCompleted.
+func CascadeCompleted(journal *TodoJournal) {
+	if journal == nil {
+		return
+	}
+
+	var cascade func(items []*TodoItem, completed bool)
+	cascade = func(items []*TodoItem, completed bool) {
+		for _, item := range items {
+			if item == nil {
+				continue
+			}
+			if completed {
+				item.Completed = true
+			}
+			cascade(item.SubItems, item.Completed)
+		}
+	}
+	for _, day := range journal.Days {
+		if day != nil {
+			cascade(day.Items, false)
+		}
+	}
+}
+
+// CompleteParents marks items in the journal completed once all their subitems are,
+// including the subitems' own subitems. Nested parents are completed first, so a
+// parent whose last open child is completed this way is completed as well. Items
+// without subitems are left alone.
+func CompleteParents(journal *TodoJournal) {
+	if journal == nil {
+		return
+	}
+
+	var complete func(item *TodoItem)
+	complete = func(item *TodoItem) {
+		if item == nil || len(item.SubItems) == 0 {
+			return
+		}
+		done := true
+		for _, subItem := range item.SubItems {
+			complete(subItem)
+			if subItem != nil && !IsCompleted(subItem) {
+				done = false
+			}
+		}
+		if done {
+			item.Completed = true
+		}
+	}
+	for _, day := range journal.Days {
+		if day == nil {
+			continue
+		}
+		for _, item := range day.Items {
+			complete(item)
+		}
+	}
+}
+
 // TagCompletedItems adds date tags to completed items in the journal.
 // It appends a date tag (e.g., "#2025-06-18") to completed items that don't already have one.
+// currentDate may carry a time of day as returned by CompletionStamp.
 // This function processes both top-level items and all nested subitems recursively.
 func TagCompletedItems(journal *TodoJournal, currentDate string) {
 	if journal == nil || currentDate == "" {
@@ -136,10 +254,24 @@ func tagCompletedItemsRecursive(item *TodoItem, date string) {
 // It formats the journal as a markdown-style todo list with day headers in the format "- [[YYYY-MM-DD]]".
 // Returns an empty string if the journal is nil or has no days.
 func JournalToString(journal *TodoJournal) string {
+	return JournalToStringFormat(journal, DefaultFormat)
+}
+
+// JournalToStringFormat converts a journal to string format like JournalToString,
+// writing day headers and indenting nested lines as configured by format. An invalid
+// day header layout in format is replaced by DefaultDayHeader.
+func JournalToStringFormat(journal *TodoJournal, format Format) string {
 	if journal == nil || len(journal.Days) == 0 {
 		return ""
 	}
 
+	header := defaultDayHeader
+	if headers, err := format.dayHeaders(); err == nil {
+		header = headers[0]
+	}
+	w := itemWriter{format: format}
+	w.dateTag, _ = format.dateTag()
+
 	var builder strings.Builder
 	// Pre-allocate some capacity to reduce reallocations
 	builder.Grow(1024)
@@ -149,56 +281,106 @@ func JournalToString(journal *TodoJournal) string {
 			continue
 		}
 
-		builder.WriteString("- [[")
-		builder.WriteString(day.Date)
-		builder.WriteString("]]\n")
+		// Undated todos are written without a day header at the top level,
+		// which is how the parser reads them back
+		depth, outdent := 0, 0
+		if day.Date != "" {
+			builder.WriteString(header.format(day.Date))
+			builder.WriteString("\n")
+			depth, outdent = 1, header.outdent()
+		}
 
 		for _, item := range day.Items {
-			writeItemToString(&builder, item, 1)
+			w.write(&builder, item, depth, outdent)
 		}
 
 		// No extra newlines between day sections in compact format
-		// The writeItemToString already adds a newline after each item
+		// The item writer already adds a newline after each item
 	}
 
 	return strings.TrimRight(builder.String(), "\n")
 }
 
-// writeItemToString writes a todo item to a string builder with proper indentation.
+// itemWriter writes todo items in a Format
+type itemWriter struct {
+	format  Format
+	dateTag *dateTag // Converts date tags to the format, nil for DefaultDateTag
+}
+
+// write writes a todo item to a string builder with proper indentation.
 // It recursively writes subitems and preserves the original formatting of bullet lines.
-func writeItemToString(builder *strings.Builder, item *TodoItem, depth int) {
+// The item is written outdent levels less indented than its depth.
+func (w itemWriter) write(builder *strings.Builder, item *TodoItem, depth, outdent int) {
 	if item == nil {
 		return
 	}
 
-	// Add indentation
-	for i := 0; i < depth; i++ {
-		builder.WriteString("  ")
+	// Callout lines come before the item, and quoted items keep their quote
+	// prefix in place of the indentation
+	for _, line := range item.Callout {
+		builder.WriteString(line)
+		builder.WriteString("\n")
 	}
-
-	// Write the item marker
-	builder.WriteString("- [")
-	if item.Completed {
-		builder.WriteString("x")
+	if item.Quote != "" {
+		builder.WriteString(item.Quote)
 	} else {
-		builder.WriteString(" ")
+		builder.WriteString(w.format.indent(depth - outdent))
 	}
-	builder.WriteString("] ")
+
+	// Write the item marker
+	builder.WriteString("- ")
+	builder.WriteString(w.format.checkbox(item.Completed))
+	builder.WriteString(" ")
 
 	// Write the text
-	builder.WriteString(item.Text)
+	if w.dateTag != nil {
+		builder.WriteString(w.dateTag.format(item.Text))
+	} else {
+		builder.WriteString(item.Text)
+	}
 	builder.WriteString("\n")
 
-	// Write bullet lines (preserve original indentation)
+	// Write bullet lines (preserve original indentation unless it would
+	// attach the line to a different item when parsed again)
 	for _, bulletLine := range item.BulletLines {
-		builder.WriteString(bulletLine)
+		if isQuotedLine(item, bulletLine) {
+			builder.WriteString(bulletLine)
+		} else {
+			builder.WriteString(w.format.formatLine(indentBulletLine(bulletLine, depth), outdent))
+		}
 		builder.WriteString("\n")
 	}
 
 	// Write subitems
 	for _, subItem := range item.SubItems {
-		writeItemToString(builder, subItem, depth+1)
+		w.write(builder, subItem, depth+1, outdent)
+	}
+}
+
+// indentBulletLine returns a bullet line of an item written at depth, re-indented
+// to one level below the item if it is not indented deeper than the item itself.
+// Such lines come from sloppily indented input and would otherwise be attached
+// to a parent item when the output is parsed again.
+func indentBulletLine(line string, depth int) string {
+	if GetIndentLevel(line) > depth*IndentSpaces {
+		return line
+	}
+	return strings.Repeat(" ", (depth+1)*IndentSpaces) + strings.TrimLeft(line, " \t")
+}
+
+// RemoveDayHeaders returns journal with the items of all its day sections in a
+// single undated section, which is written without a day header.
+func RemoveDayHeaders(journal *TodoJournal) *TodoJournal {
+	if journal.IsEmpty() {
+		return journal
+	}
+	undated := &DaySection{Items: []*TodoItem{}}
+	for _, day := range journal.Days {
+		if day != nil {
+			undated.Items = append(undated.Items, day.Items...)
+		}
 	}
+	return &TodoJournal{Days: []*DaySection{undated}}
 }
 
 // MoveUndatedTodosToCurrentDate moves incomplete todos that don't have a date (empty date string)
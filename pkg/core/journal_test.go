@@ -2,6 +2,8 @@
 package core
 
 import (
+	"context"
+	"regexp"
 	"strings"
 	"testing"
 )
@@ -509,7 +511,7 @@ func TestJournalToString(t *testing.T) {
 func TestWriteItemToString(t *testing.T) {
 	t.Run("nil item should not write anything", func(t *testing.T) {
 		var builder strings.Builder
-		writeItemToString(&builder, nil, 1)
+		itemWriter{format: DefaultFormat}.write(&builder, nil, 1, 0)
 
 		if builder.String() != "" {
 			t.Error("Expected empty string for nil item")
@@ -519,7 +521,7 @@ func TestWriteItemToString(t *testing.T) {
 	t.Run("simple completed item should format correctly", func(t *testing.T) {
 		var builder strings.Builder
 		item := createTestTodoItem("Task 1", true)
-		writeItemToString(&builder, item, 1)
+		itemWriter{format: DefaultFormat}.write(&builder, item, 1, 0)
 
 		expected := "  - [x] Task 1\n"
 		if builder.String() != expected {
@@ -530,7 +532,7 @@ func TestWriteItemToString(t *testing.T) {
 	t.Run("simple uncompleted item should format correctly", func(t *testing.T) {
 		var builder strings.Builder
 		item := createTestTodoItem("Task 1", false)
-		writeItemToString(&builder, item, 1)
+		itemWriter{format: DefaultFormat}.write(&builder, item, 1, 0)
 
 		expected := "  - [ ] Task 1\n"
 		if builder.String() != expected {
@@ -541,7 +543,7 @@ func TestWriteItemToString(t *testing.T) {
 	t.Run("item with zero depth should have no indentation", func(t *testing.T) {
 		var builder strings.Builder
 		item := createTestTodoItem("Task 1", true)
-		writeItemToString(&builder, item, 0)
+		itemWriter{format: DefaultFormat}.write(&builder, item, 0, 0)
 
 		expected := "- [x] Task 1\n"
 		if builder.String() != expected {
@@ -552,7 +554,7 @@ func TestWriteItemToString(t *testing.T) {
 	t.Run("item with multiple depth levels should indent correctly", func(t *testing.T) {
 		var builder strings.Builder
 		item := createTestTodoItem("Task 1", true)
-		writeItemToString(&builder, item, 3)
+		itemWriter{format: DefaultFormat}.write(&builder, item, 3, 0)
 
 		expected := "      - [x] Task 1\n"
 		if builder.String() != expected {
@@ -564,7 +566,7 @@ func TestWriteItemToString(t *testing.T) {
 		var builder strings.Builder
 		bulletLines := []string{"    * Detail 1", "    * Detail 2"}
 		item := createTestTodoItemWithBullets("Task 1", true, bulletLines)
-		writeItemToString(&builder, item, 1)
+		itemWriter{format: DefaultFormat}.write(&builder, item, 1, 0)
 
 		expected := "  - [x] Task 1\n    * Detail 1\n    * Detail 2\n"
 		if builder.String() != expected {
@@ -576,7 +578,7 @@ func TestWriteItemToString(t *testing.T) {
 		var builder strings.Builder
 		subitem := createTestTodoItem("Subtask", false)
 		item := createTestTodoItem("Parent Task", true, subitem)
-		writeItemToString(&builder, item, 1)
+		itemWriter{format: DefaultFormat}.write(&builder, item, 1, 0)
 
 		expected := "  - [x] Parent Task\n    - [ ] Subtask\n"
 		if builder.String() != expected {
@@ -593,7 +595,7 @@ func TestWriteItemToString(t *testing.T) {
 		subitem := createTestTodoItemWithBullets("Middle Task", false, bulletLines, deepSubitem)
 		item := createTestTodoItem("Top Task", true, subitem)
 
-		writeItemToString(&builder, item, 1)
+		itemWriter{format: DefaultFormat}.write(&builder, item, 1, 0)
 
 		expected := "  - [x] Top Task\n    - [ ] Middle Task\n      * Some detail\n      - [x] Deep Task\n"
 		if builder.String() != expected {
@@ -679,3 +681,118 @@ func TestJournalConstants(t *testing.T) {
 		}
 	})
 }
+
+// TestCompletionRules tests every combination of CascadeComplete and
+// AutoCompleteParent when processing a todos section
+func TestCompletionRules(t *testing.T) {
+	input := `- [[2025-06-18]]
+  - [x] Parent done, child open
+    - [ ] Child
+      - [ ] Grandchild
+  - [ ] Parent open, children done
+    - [x] Child one
+    - [x] Child two
+  - [ ] Parent open, nested done
+    - [ ] Middle
+      - [x] Leaf
+  - [ ] Parent open, child open
+    - [x] Done child
+    - [ ] Open child
+  - [ ] Plain open`
+
+	cascaded := `  - [x] Parent done, child open #2025-06-18
+    - [x] Child #2025-06-18
+      - [x] Grandchild #2025-06-18`
+	carriedParent := `  - [x] Parent done, child open
+    - [ ] Child
+      - [ ] Grandchild`
+	childrenDone := `  - [%s] Parent open, children done%s
+    - [x] Child one #2025-06-18
+    - [x] Child two #2025-06-18`
+	nestedDone := `  - [%s] Parent open, nested done%s
+    - [%s] Middle%s
+      - [x] Leaf #2025-06-18`
+	rest := `  - [ ] Parent open, child open
+    - [x] Done child #2025-06-18
+    - [ ] Open child
+  - [ ] Plain open`
+	// The parents of childrenDone and nestedDone are written open or completed and tagged
+	open := func(s string) string { return strings.NewReplacer("[%s]", "[ ]", "%s", "").Replace(s) }
+	done := func(s string) string { return strings.NewReplacer("[%s]", "[x]", "%s", " #2025-06-18").Replace(s) }
+	day := "- [[2025-06-18]]\n"
+
+	tests := []struct {
+		name          string
+		cascade       bool
+		autoParent    bool
+		wantCompleted string
+		wantCarried   string
+	}{
+		{
+			name:          "neither",
+			wantCompleted: "Moved to [[2025-06-19]]",
+			wantCarried:   day + strings.Join([]string{carriedParent, open(childrenDone), open(nestedDone), rest}, "\n"),
+		},
+		{
+			name:          "cascade_complete",
+			cascade:       true,
+			wantCompleted: day + cascaded,
+			wantCarried:   day + strings.Join([]string{open(childrenDone), open(nestedDone), rest}, "\n"),
+		},
+		{
+			name:          "auto_complete_parent",
+			autoParent:    true,
+			wantCompleted: day + strings.Join([]string{done(childrenDone), done(nestedDone)}, "\n"),
+			wantCarried:   day + strings.Join([]string{carriedParent, rest}, "\n"),
+		},
+		{
+			name:          "both",
+			cascade:       true,
+			autoParent:    true,
+			wantCompleted: day + strings.Join([]string{cascaded, done(childrenDone), done(nestedDone)}, "\n"),
+			wantCarried:   day + rest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ProcessTodosSectionWithOptions(context.Background(), input, "2025-06-18", "2025-06-19", ProcessOptions{
+				CascadeComplete:    tt.cascade,
+				AutoCompleteParent: tt.autoParent,
+			})
+			if err != nil {
+				t.Fatalf("ProcessTodosSectionWithOptions() error: %v", err)
+			}
+			if result.Completed != tt.wantCompleted {
+				t.Errorf("completed section =\n%s\nwant:\n%s", result.Completed, tt.wantCompleted)
+			}
+			// The carried top-level todos are stamped with their created date
+			wantCarried := regexp.MustCompile(`(?m)^  - .*$`).ReplaceAllString(tt.wantCarried, "$0 created::[[2025-06-18]]")
+			if result.Uncompleted != wantCarried {
+				t.Errorf("carried section =\n%s\nwant:\n%s", result.Uncompleted, wantCarried)
+			}
+		})
+	}
+}
+
+func TestCompleteParents(t *testing.T) {
+	journal := createTestJournal(createTestDaySection("2025-06-18",
+		createTestTodoItem("Empty parent", false),
+		createTestTodoItem("Open subtree", false, createTestTodoItem("Done", true, createTestTodoItem("Open", false))),
+	))
+	CompleteParents(journal)
+	items := journal.Days[0].Items
+	if items[0].Completed {
+		t.Error("CompleteParents() completed an item without subitems")
+	}
+	if items[1].Completed {
+		t.Error("CompleteParents() completed a parent whose completed child has an open subitem")
+	}
+
+	CascadeCompleted(journal)
+	if !items[1].SubItems[0].SubItems[0].Completed || items[1].Completed {
+		t.Error("CascadeCompleted() did not complete exactly the subtree of the completed item")
+	}
+	CascadeCompleted(nil)
+	CompleteParents(nil)
+}
@@ -0,0 +1,83 @@
+// Package core provides input limits of the parser for the todoer application.
+package core
+
+import "fmt"
+
+// Default limits of the input accepted by the parser, see Limits
+const (
+	DefaultMaxLineLength = 64 << 10 // Bytes of the longest line of a todos section
+	DefaultMaxDepth      = 64       // Levels of nested todos
+	DefaultMaxFileSize   = 16 << 20 // Bytes of a journal
+)
+
+// Limits bounds the input the parser accepts, so that a corrupted or adversarial
+// journal fails with a clear error instead of taking unbounded time and memory
+// when matched and nested. A zero field means its default; a negative one
+// disables the limit.
+type Limits struct {
+	MaxLineLength int // Longest line of a todos section in bytes, see DefaultMaxLineLength
+	MaxDepth      int // Deepest nesting of todos, top-level todos being at depth 1, see DefaultMaxDepth
+	MaxFileSize   int // Largest journal, or todos section if parsed alone, in bytes, see DefaultMaxFileSize
+}
+
+// limit returns value, or def if it is zero, or 0 if the limit is disabled.
+func limit(value, def int) int {
+	switch {
+	case value == 0:
+		return def
+	case value < 0:
+		return 0
+	default:
+		return value
+	}
+}
+
+// maxLineLength returns the longest line accepted in bytes, 0 if unlimited.
+func (l Limits) maxLineLength() int {
+	return limit(l.MaxLineLength, DefaultMaxLineLength)
+}
+
+// maxDepth returns the deepest nesting of todos accepted, 0 if unlimited.
+func (l Limits) maxDepth() int {
+	return limit(l.MaxDepth, DefaultMaxDepth)
+}
+
+// CheckFileSize returns a JournalError of kind KindLimit if a journal of size
+// bytes is larger than the limit.
+func (l Limits) CheckFileSize(size int) error {
+	if max := limit(l.MaxFileSize, DefaultMaxFileSize); max > 0 && size > max {
+		return &JournalError{
+			Kind: KindLimit,
+			Err:  fmt.Errorf("journal is %d bytes, larger than the limit of %d bytes", size, max),
+		}
+	}
+	return nil
+}
+
+// checkLine returns a JournalError of kind KindLimit if line lineNum is longer
+// than the limit. The line itself is left out of the error, as it may be huge.
+func (l Limits) checkLine(line string, lineNum int) error {
+	if max := l.maxLineLength(); max > 0 && len(line) > max {
+		return &JournalError{
+			Line: lineNum,
+			Kind: KindLimit,
+			Err:  fmt.Errorf("line is %d bytes long, longer than the limit of %d bytes", len(line), max),
+		}
+	}
+	return nil
+}
+
+// checkDepth returns a JournalError of kind KindLimit if a todo on line lineNum,
+// nested depth levels deep, is deeper than the limit.
+func (l Limits) checkDepth(depth int, line string, lineNum int) error {
+	if max := l.maxDepth(); max > 0 && depth > max {
+		return &JournalError{
+			Line:   lineNum,
+			Col:    columnOf(line),
+			Kind:   KindLimit,
+			Source: line,
+			Err:    fmt.Errorf("todo is nested %d levels deep, deeper than the limit of %d", depth, max),
+		}
+	}
+	return nil
+}
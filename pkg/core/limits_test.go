@@ -0,0 +1,51 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLimits(t *testing.T) {
+	deep := "- [[2025-06-20]]\n"
+	for depth := 1; depth <= 4; depth++ {
+		deep += strings.Repeat("  ", depth) + "- [ ] Level\n"
+	}
+
+	tests := []struct {
+		name     string
+		content  string
+		limits   Limits
+		wantLine int
+	}{
+		{"defaults", deep, Limits{}, -1},
+		{"too deep", deep, Limits{MaxDepth: 3}, 5},
+		{"depth unlimited", deep, Limits{MaxDepth: -1}, -1},
+		{"long line", "- [[2025-06-20]]\n  - [ ] " + strings.Repeat("a", 100), Limits{MaxLineLength: 50}, 2},
+		{"large section", deep, Limits{MaxFileSize: 20}, 0},
+		{"long line under default", "- [[2025-06-20]]\n  - [ ] " + strings.Repeat("a", 1000), Limits{}, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := NewParser(WithLimits(tt.limits)).Parse(context.Background(), tt.content)
+			if tt.wantLine < 0 {
+				if err != nil {
+					t.Fatalf("Parse() error = %v", err)
+				}
+				return
+			}
+			var journalErr *JournalError
+			if !errors.As(err, &journalErr) || !errors.Is(err, KindLimit) {
+				t.Fatalf("Parse() error = %v, want a %s error", err, KindLimit)
+			}
+			if journalErr.Line != tt.wantLine {
+				t.Errorf("error line = %d, want %d", journalErr.Line, tt.wantLine)
+			}
+		})
+	}
+
+	if _, err := NewParser(WithLimits(Limits{MaxFileSize: 10})).ParseJournal("## Todos\n\n- [ ] Task\n"); !errors.Is(err, KindLimit) {
+		t.Errorf("ParseJournal() error = %v, want a %s error", err, KindLimit)
+	}
+}
@@ -0,0 +1,123 @@
+// Package core provides markdown aware matching in todo texts for the todoer application.
+package core
+
+import (
+	"regexp"
+	"strings"
+)
+
+// inlineMarkupRegex matches inline markup holding text that is not prose: a wiki
+// link, a markdown link destination, an autolink and a bare URL. Only the target
+// of a wiki link is markup; its alias is prose.
+// Captures: (wiki link target) (link destination)
+var inlineMarkupRegex = regexp.MustCompile(`\[\[([^\[\]|]*)(?:\|[^\[\]]*)?\]\]|\]\(([^()\s]*)\)|<[A-Za-z][A-Za-z0-9+.-]*:[^\s<>]*>|[A-Za-z][A-Za-z0-9+.-]*://\S+`)
+
+// markup holds the byte ranges of a text that are markup rather than prose, such
+// as code spans and URLs. A date tag or annotation in them is part of the markup.
+type markup [][2]int
+
+// findMarkup returns the code spans, link targets and URLs of text.
+func findMarkup(text string) markup {
+	if !strings.ContainsAny(text, "`[]<:") {
+		return nil
+	}
+
+	var spans markup
+	// A code span runs from a run of backticks to the next run of the same length;
+	// a run without one is literal text
+	for i := 0; i < len(text); {
+		if text[i] != '`' {
+			i++
+			continue
+		}
+		n := backtickRun(text, i)
+		end := -1
+		for j := i + n; j < len(text); {
+			if text[j] != '`' {
+				j++
+				continue
+			}
+			m := backtickRun(text, j)
+			if m == n {
+				end = j + m
+				break
+			}
+			j += m
+		}
+		if end < 0 {
+			i += n
+			continue
+		}
+		spans = append(spans, [2]int{i, end})
+		i = end
+	}
+
+	for _, m := range inlineMarkupRegex.FindAllStringSubmatchIndex(text, -1) {
+		switch {
+		case m[2] >= 0:
+			spans = append(spans, [2]int{m[2], m[3]})
+		case m[4] >= 0:
+			spans = append(spans, [2]int{m[4], m[5]})
+		default:
+			spans = append(spans, [2]int{m[0], m[1]})
+		}
+	}
+	return spans
+}
+
+// backtickRun returns the number of backticks starting at offset i of text.
+func backtickRun(text string, i int) int {
+	n := 0
+	for i+n < len(text) && text[i+n] == '`' {
+		n++
+	}
+	return n
+}
+
+// contains reports whether the byte offset i lies in markup.
+func (m markup) contains(i int) bool {
+	for _, span := range m {
+		if i >= span[0] && i < span[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// findInProse returns the matches of re in text like FindAllStringSubmatchIndex,
+// leaving out those starting in markup.
+func findInProse(re *regexp.Regexp, text string) [][]int {
+	matches := re.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+	spans := findMarkup(text)
+	if len(spans) == 0 {
+		return matches
+	}
+	prose := matches[:0]
+	for _, m := range matches {
+		if !spans.contains(m[0]) {
+			prose = append(prose, m)
+		}
+	}
+	return prose
+}
+
+// replaceInProse returns text with the matches of re that do not start in markup
+// replaced by the result of repl, like ReplaceAllStringFunc.
+func replaceInProse(re *regexp.Regexp, text string, repl func(string) string) string {
+	matches := findInProse(re, text)
+	if len(matches) == 0 {
+		return text
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(text[last:m[0]])
+		b.WriteString(repl(text[m[0]:m[1]]))
+		last = m[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHasDateTagIgnoresMarkup(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"Ship release #2025-01-01", true},
+		{"Grep for `#2025-01-01` in the logs", false},
+		{"Read ``code with ` and #2025-01-01``", false},
+		{"Unclosed ` before #2025-01-01", true},
+		{"See https://example.com/log#2025-01-01", false},
+		{"See <https://example.com/log#2025-01-01>", false},
+		{"Read [the log](https://example.com/#2025-01-01)", false},
+		{"Read [[Daily#2025-01-01]]", false},
+		{"Read [[Daily|notes]] #2025-01-01", true},
+		{"`code` #2025-01-01", true},
+	}
+	for _, tt := range tests {
+		if got := HasDateTag(tt.text); got != tt.want {
+			t.Errorf("HasDateTag(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTagCompletedItemsInCode(t *testing.T) {
+	item := &TodoItem{Text: "Check `#2024-12-31` entries", Completed: true}
+	TagCompletedItems(&TodoJournal{Days: []*DaySection{{Date: "2025-01-01", Items: []*TodoItem{item}}}}, "2025-01-01")
+	if want := "Check `#2024-12-31` entries #2025-01-01"; item.Text != want {
+		t.Errorf("Text = %q, want %q", item.Text, want)
+	}
+}
+
+func TestMarkupSkipsTagsAndAnnotations(t *testing.T) {
+	text := "Fix `#bug` in https://example.com/#home and `due::2025-01-01` #work due::[[2025-06-30]]"
+	if got, want := ExtractTags(text), []string{"#work"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractTags() = %q, want %q", got, want)
+	}
+	if got, want := ParseMeta(text), map[string]string{"due": "2025-06-30"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseMeta() = %v, want %v", got, want)
+	}
+	if got, want := StripDateTags("Grep `#2025-01-01` #2025-01-02"), "Grep `#2025-01-01`"; got != want {
+		t.Errorf("StripDateTags() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,91 @@
+// Package core provides journal merging functionality for the todoer application.
+package core
+
+import (
+	"strings"
+)
+
+// MergeJournals merges the todo items of others into base and returns base.
+//
+// Days are matched by date and items by their text, ignoring the checkbox: an item
+// that is completed in any version is completed in the result. Items, subitems and
+// bullet lines that only exist in another version are appended after the ones of
+// base, in the order they appear there. Without a common ancestor deletions cannot
+// be told apart from additions, so an item removed in one version but still present
+// in another is kept.
+func MergeJournals(base *TodoJournal, others ...*TodoJournal) *TodoJournal {
+	if base == nil {
+		base = &TodoJournal{}
+	}
+
+	for _, other := range others {
+		if other == nil {
+			continue
+		}
+		for _, day := range other.Days {
+			if day == nil {
+				continue
+			}
+			target := EnsureDay(base, day.Date)
+			target.Items = mergeItems(target.Items, day.Items)
+		}
+	}
+
+	return base
+}
+
+// mergeItems merges the items of other into items. Each item of items is matched
+// at most once, so repeated items with the same text are kept apart.
+func mergeItems(items, other []*TodoItem) []*TodoItem {
+	matched := make(map[*TodoItem]bool)
+
+	for _, item := range other {
+		if item == nil {
+			continue
+		}
+
+		var match *TodoItem
+		for _, candidate := range items {
+			if candidate != nil && !matched[candidate] && mergeKey(candidate) == mergeKey(item) {
+				match = candidate
+				break
+			}
+		}
+
+		if match == nil {
+			items = append(items, item)
+			matched[item] = true
+			continue
+		}
+
+		matched[match] = true
+		match.Completed = match.Completed || item.Completed
+		match.SubItems = mergeItems(match.SubItems, item.SubItems)
+		match.BulletLines = mergeLines(match.BulletLines, item.BulletLines)
+	}
+
+	return items
+}
+
+// mergeKey identifies an item across versions of a journal
+func mergeKey(item *TodoItem) string {
+	return strings.TrimSpace(item.Text)
+}
+
+// mergeLines appends the lines of other that are missing from lines.
+func mergeLines(lines, other []string) []string {
+	present := make(map[string]bool, len(lines))
+	for _, line := range lines {
+		present[strings.TrimSpace(line)] = true
+	}
+
+	for _, line := range other {
+		key := strings.TrimSpace(line)
+		if !present[key] {
+			lines = append(lines, line)
+			present[key] = true
+		}
+	}
+
+	return lines
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestMergeJournals(t *testing.T) {
+	base, err := ParseTodosSection(`- [ ] Undated task
+- [[2025-06-20]]
+  - [ ] Write report
+    - [ ] Outline
+    - Draft in shared folder
+  - [ ] Call Alice
+  - [ ] Call Alice`)
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error: %v", err)
+	}
+	conflict, err := ParseTodosSection(`- [[2025-06-20]]
+  - [ ] Write report
+    - [x] Outline
+    - [ ] Review
+    - Draft in shared folder
+    - Ask Bob for numbers
+  - [x] Call Alice
+  - [ ] Buy milk
+- [[2025-06-21]]
+  - [ ] Plan sprint`)
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error: %v", err)
+	}
+
+	got := JournalToString(MergeJournals(base, conflict, nil))
+	want := `- [ ] Undated task
+- [[2025-06-20]]
+  - [ ] Write report
+    - Draft in shared folder
+    - Ask Bob for numbers
+    - [x] Outline
+    - [ ] Review
+  - [x] Call Alice
+  - [ ] Call Alice
+  - [ ] Buy milk
+- [[2025-06-21]]
+  - [ ] Plan sprint`
+	if got != want {
+		t.Errorf("MergeJournals() =\n%s\nwant\n%s", got, want)
+	}
+
+	// Merging the same version again changes nothing
+	again := JournalToString(MergeJournals(base, conflict))
+	if again != want {
+		t.Errorf("MergeJournals() is not idempotent:\n%s", again)
+	}
+
+	if got := MergeJournals(nil, conflict); got.DayCount() != 2 {
+		t.Errorf("MergeJournals(nil) has %d days, want 2", got.DayCount())
+	}
+}
@@ -0,0 +1,183 @@
+// Package core provides inline task metadata parsing for the todoer application.
+package core
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// AnnotationRegex matches a key::value annotation at the start of a word, the
+	// value being a [[link]] or running up to the next whitespace. A single colon
+	// is accepted before a [[link]] value, as in depends:[[write-spec]].
+	// Captures: (key, link value, plain value)
+	AnnotationRegex = regexp.MustCompile(`(?:^|\s)([A-Za-z][\w-]*)(?:::?\[\[([^\[\]]*)\]\]|::([^\s\[\]]+))`)
+
+	// EmojiAnnotationRegex matches an emoji annotation as written by the Obsidian
+	// Tasks plugin, such as 📅 2025-06-30 or 🔁 every week.
+	// Captures: (emoji, date value, recurrence value)
+	EmojiAnnotationRegex = regexp.MustCompile(`(📅|⏳|🛫|➕|✅|❌|🔁)\x{FE0F}?\s*(?:(\d{4}-\d{2}-\d{2})|([^📅⏳🛫➕✅❌🔁]+))`)
+)
+
+// EmojiAnnotationKeys maps the emoji of emoji annotations to their metadata keys
+var EmojiAnnotationKeys = map[string]string{
+	"📅": "due",
+	"⏳": "scheduled",
+	"🛫": "start",
+	"➕": "created",
+	"✅": "done",
+	"❌": "cancelled",
+	"🔁": "recur",
+}
+
+// Annotation is a key/value annotation in a todo text.
+type Annotation struct {
+	Key   string // Metadata key, e.g. "due" for both due::[[2025-06-30]] and 📅 2025-06-30
+	Value string // Value without [[ ]] brackets or surrounding whitespace
+	Start int    // Byte offset of the annotation in the text
+	End   int    // Byte offset just past the annotation
+}
+
+// ParseAnnotations returns the key::value and emoji annotations of text in order.
+// Emoji annotations other than 🔁 only count with a date value. Annotations in code
+// spans and URLs are part of them, not of the task.
+func ParseAnnotations(text string) []Annotation {
+	var annotations []Annotation
+	for _, m := range findInProse(AnnotationRegex, text) {
+		a := Annotation{Key: text[m[2]:m[3]], Start: m[2], End: m[1]}
+		if m[4] >= 0 {
+			a.Value = strings.TrimSpace(text[m[4]:m[5]])
+		} else {
+			a.Value = text[m[6]:m[7]]
+		}
+		annotations = append(annotations, a)
+	}
+	for _, m := range findInProse(EmojiAnnotationRegex, text) {
+		key := EmojiAnnotationKeys[text[m[2]:m[3]]]
+		a := Annotation{Key: key, Start: m[0], End: m[1]}
+		switch {
+		case m[4] >= 0:
+			a.Value = text[m[4]:m[5]]
+		case key == "recur":
+			a.Value = strings.TrimSpace(text[m[6]:m[7]])
+			a.End = m[6] + len(strings.TrimRight(text[m[6]:m[7]], " \t"))
+		default:
+			continue
+		}
+		annotations = append(annotations, a)
+	}
+	sort.SliceStable(annotations, func(i, j int) bool {
+		return annotations[i].Start < annotations[j].Start
+	})
+	return annotations
+}
+
+// ParseMeta returns the metadata of text by key, or nil if it has no annotations.
+// If a key is annotated several times, the first value wins.
+func ParseMeta(text string) map[string]string {
+	var meta map[string]string
+	for _, a := range ParseAnnotations(text) {
+		if meta == nil {
+			meta = make(map[string]string)
+		}
+		if _, ok := meta[a.Key]; !ok {
+			meta[a.Key] = a.Value
+		}
+	}
+	return meta
+}
+
+// annotationValue returns the first non-empty value text is annotated with for key.
+func annotationValue(text, key string) string {
+	for _, a := range ParseAnnotations(text) {
+		if a.Key == key && a.Value != "" {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// annotationValues returns the non-empty values text is annotated with for any of keys, in order.
+func annotationValues(text string, keys ...string) []string {
+	var values []string
+	for _, a := range ParseAnnotations(text) {
+		if a.Value == "" {
+			continue
+		}
+		for _, key := range keys {
+			if a.Key == key {
+				values = append(values, a.Value)
+				break
+			}
+		}
+	}
+	return values
+}
+
+// removeAnnotations removes the annotations of text with any of keys and tidies
+// up the whitespace left behind.
+func removeAnnotations(text string, keys ...string) string {
+	annotations := ParseAnnotations(text)
+	for i := len(annotations) - 1; i >= 0; i-- {
+		a := annotations[i]
+		for _, key := range keys {
+			if a.Key == key {
+				text = text[:a.Start] + text[a.End:]
+				break
+			}
+		}
+	}
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// FormatAnnotation returns the key::value annotation for key and value. Dates and
+// values containing whitespace are written as links, e.g. due::[[2025-06-30]].
+func FormatAnnotation(key, value string) string {
+	if value == "" || strings.ContainsAny(value, " \t") || ValidateDate(value) == nil {
+		return key + "::[[" + value + "]]"
+	}
+	return key + "::" + value
+}
+
+// MetaValue returns the value of the item's metadata for key, or an empty string.
+func (t *TodoItem) MetaValue(key string) string {
+	if t == nil {
+		return ""
+	}
+	return t.Meta[key]
+}
+
+// SetMeta sets the item's metadata for key to value. The first annotation of key
+// in the text is rewritten in place, keeping its emoji form if it has one; without
+// one, a key::value annotation is appended to the text.
+func (t *TodoItem) SetMeta(key, value string) {
+	if t == nil {
+		return
+	}
+	for _, a := range ParseAnnotations(t.Text) {
+		if a.Key != key {
+			continue
+		}
+		replacement := FormatAnnotation(key, value)
+		for emoji, k := range EmojiAnnotationKeys {
+			if k == key && strings.HasPrefix(t.Text[a.Start:], emoji) {
+				replacement = emoji + " " + value
+			}
+		}
+		t.Text = t.Text[:a.Start] + replacement + t.Text[a.End:]
+		t.Meta = ParseMeta(t.Text)
+		return
+	}
+	t.Text = strings.TrimRight(t.Text, " ") + " " + FormatAnnotation(key, value)
+	t.Meta = ParseMeta(t.Text)
+}
+
+// DeleteMeta removes every annotation of key from the item's text and metadata.
+func (t *TodoItem) DeleteMeta(key string) {
+	if t == nil {
+		return
+	}
+	t.Text = removeAnnotations(t.Text, key)
+	t.Meta = ParseMeta(t.Text)
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAnnotations(t *testing.T) {
+	text := "Ship it id::ship due::[[2025-06-30]] goal::[[Q3 Objectives#Ship v2]] depends:[[build]] ⏳ 2025-06-28 🔁 every week"
+	got := ParseAnnotations(text)
+	want := []Annotation{
+		{Key: "id", Value: "ship", Start: 8, End: 16},
+		{Key: "due", Value: "2025-06-30", Start: 17, End: 36},
+		{Key: "goal", Value: "Q3 Objectives#Ship v2", Start: 37, End: 68},
+		{Key: "depends", Value: "build", Start: 69, End: 86},
+		{Key: "scheduled", Value: "2025-06-28", Start: 87, End: 101},
+		{Key: "recur", Value: "every week", Start: 102, End: len(text)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseAnnotations() = %+v, want %+v", got, want)
+	}
+
+	if got := ParseAnnotations("Read std::vector docs at 10:30, see http://example.com ✅ soon"); len(got) != 1 || got[0].Key != "std" {
+		t.Errorf("ParseAnnotations() = %+v, want only std", got)
+	}
+}
+
+func TestParseMeta(t *testing.T) {
+	got := ParseMeta("Pay rent 📅 2025-06-30 due::[[2025-07-01]] owner::kim")
+	want := map[string]string{"due": "2025-06-30", "owner": "kim"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseMeta() = %v, want %v", got, want)
+	}
+	if got := ParseMeta("Plain task"); got != nil {
+		t.Errorf("ParseMeta() = %v, want nil", got)
+	}
+	if got := ExtractDue("Pay rent 📅 2025-06-30"); got != "2025-06-30" {
+		t.Errorf("ExtractDue() = %q, want the emoji due date", got)
+	}
+}
+
+func TestParsedItemMeta(t *testing.T) {
+	journal, err := ParseTodosSection("- [[2025-06-20]]\n  - [ ] Review id::review due::[[2025-06-30]]\n    - [ ] Notes")
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error: %v", err)
+	}
+	item := journal.Days[0].Items[0]
+	if got := item.MetaValue("due"); got != "2025-06-30" {
+		t.Errorf("MetaValue(due) = %q", got)
+	}
+	if item.SubItems[0].Meta != nil {
+		t.Errorf("Meta of an item without annotations = %v, want nil", item.SubItems[0].Meta)
+	}
+	if copied := DeepCopyItem(item); !reflect.DeepEqual(copied.Meta, item.Meta) {
+		t.Errorf("DeepCopyItem() Meta = %v, want %v", copied.Meta, item.Meta)
+	}
+
+	// Edits go through the text, which is written unchanged
+	item.SetMeta("due", "2025-07-04")
+	item.SetMeta("owner", "kim")
+	item.DeleteMeta("id")
+	if want := "Review due::[[2025-07-04]] owner::kim"; item.Text != want {
+		t.Errorf("Text = %q, want %q", item.Text, want)
+	}
+	if want := map[string]string{"due": "2025-07-04", "owner": "kim"}; !reflect.DeepEqual(item.Meta, want) {
+		t.Errorf("Meta = %v, want %v", item.Meta, want)
+	}
+	if got := JournalToString(journal); got != "- [[2025-06-20]]\n  - [ ] Review due::[[2025-07-04]] owner::kim\n    - [ ] Notes" {
+		t.Errorf("JournalToString() = %q", got)
+	}
+
+	emoji := &TodoItem{Text: "Pay rent 📅 2025-06-30 #home"}
+	emoji.SetMeta("due", "2025-07-01")
+	if emoji.Text != "Pay rent 📅 2025-07-01 #home" {
+		t.Errorf("SetMeta() on an emoji annotation = %q", emoji.Text)
+	}
+}
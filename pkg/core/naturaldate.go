@@ -0,0 +1,362 @@
+// Package core provides natural language date parsing for the todoer application.
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DateParser reads dates written in natural language, such as "tomorrow",
+// "next friday" or "2 weeks ago", relative to a given day.
+//
+// Besides YYYY-MM-DD dates and offsets such as +3d, -2w, +1m or +1y, a parser
+// understands, in the words of its locale:
+//
+//   - today, tomorrow and yesterday, and the days after tomorrow and before yesterday
+//   - a weekday, optionally preceded by "on": the first such day after today
+//   - this, next or last with a weekday: the day in the current or the next week,
+//     or the latest such day before today
+//   - this, next or last with day, week, month or year: the first day of the
+//     current, next or previous week, month or year, or today, tomorrow and yesterday
+//   - in N units, N units from now and N units ago, with N in digits or a word
+//     for one, as in "a week ago"
+//
+// Weeks begin on WeekStart. Months are added without overflowing into the month
+// after, so a month after January 31 is the last day of February.
+//
+// The zero DateParser reads English with weeks beginning on Sunday; NewDateParser
+// returns a parser with the week start of its locale.
+type DateParser struct {
+	Locale    string       // Language of the words, one of DateLocales; English if empty
+	WeekStart time.Weekday // First day of the week
+}
+
+// dateUnit is a unit of relative dates
+type dateUnit int
+
+const (
+	unitDay dateUnit = iota
+	unitWeek
+	unitMonth
+	unitYear
+)
+
+// dateDirection is a way of writing a relative date in a locale, such as
+// "in N units" or "N units ago", as the words before and after "N units"
+type dateDirection struct {
+	sign   int
+	prefix []string
+	suffix []string
+}
+
+// dateWords holds the words natural language dates are written with in one locale.
+// All words are lower case.
+type dateWords struct {
+	weekStart  time.Weekday
+	days       map[string]int          // Whole phrases naming a day relative to today
+	on         []string                // Optional words before a weekday
+	modifiers  map[string]int          // this 0, next 1, last -1
+	weekdays   map[string]time.Weekday // Weekday names and abbreviations
+	units      map[string]dateUnit     // Unit names in singular and plural
+	numbers    map[string]int          // Words for one, as in "a week ago"
+	directions []dateDirection
+}
+
+var englishDateWords = dateWords{
+	weekStart: time.Monday,
+	days: map[string]int{
+		"today": 0, "now": 0, "tomorrow": 1, "yesterday": -1,
+		"day after tomorrow": 2, "the day after tomorrow": 2,
+		"day before yesterday": -2, "the day before yesterday": -2,
+	},
+	on:        []string{"on"},
+	modifiers: map[string]int{"this": 0, "next": 1, "last": -1},
+	weekdays: map[string]time.Weekday{
+		"monday": time.Monday, "mon": time.Monday,
+		"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+		"wednesday": time.Wednesday, "wed": time.Wednesday,
+		"thursday": time.Thursday, "thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday,
+		"friday": time.Friday, "fri": time.Friday,
+		"saturday": time.Saturday, "sat": time.Saturday,
+		"sunday": time.Sunday, "sun": time.Sunday,
+	},
+	units: map[string]dateUnit{
+		"day": unitDay, "days": unitDay,
+		"week": unitWeek, "weeks": unitWeek,
+		"month": unitMonth, "months": unitMonth,
+		"year": unitYear, "years": unitYear,
+	},
+	numbers: map[string]int{"a": 1, "an": 1},
+	directions: []dateDirection{
+		{sign: 1, prefix: []string{"in"}},
+		{sign: 1, suffix: []string{"from", "now"}},
+		{sign: -1, suffix: []string{"ago"}},
+	},
+}
+
+var germanDateWords = dateWords{
+	weekStart: time.Monday,
+	days: map[string]int{
+		"heute": 0, "morgen": 1, "gestern": -1, "übermorgen": 2, "vorgestern": -2,
+	},
+	on: []string{"am"},
+	modifiers: map[string]int{
+		"diese": 0, "diesen": 0, "dieser": 0, "dieses": 0,
+		"nächste": 1, "nächsten": 1, "nächster": 1, "nächstes": 1,
+		"letzte": -1, "letzten": -1, "letzter": -1, "letztes": -1,
+	},
+	weekdays: map[string]time.Weekday{
+		"montag": time.Monday, "mo": time.Monday,
+		"dienstag": time.Tuesday, "di": time.Tuesday,
+		"mittwoch": time.Wednesday, "mi": time.Wednesday,
+		"donnerstag": time.Thursday, "do": time.Thursday,
+		"freitag": time.Friday, "fr": time.Friday,
+		"samstag": time.Saturday, "sonnabend": time.Saturday, "sa": time.Saturday,
+		"sonntag": time.Sunday, "so": time.Sunday,
+	},
+	units: map[string]dateUnit{
+		"tag": unitDay, "tage": unitDay, "tagen": unitDay,
+		"woche": unitWeek, "wochen": unitWeek,
+		"monat": unitMonth, "monate": unitMonth, "monaten": unitMonth,
+		"jahr": unitYear, "jahre": unitYear, "jahren": unitYear,
+	},
+	numbers: map[string]int{"ein": 1, "eine": 1, "einem": 1, "einer": 1, "einen": 1},
+	directions: []dateDirection{
+		{sign: 1, prefix: []string{"in"}},
+		{sign: -1, prefix: []string{"vor"}},
+	},
+}
+
+var norwegianDateWords = dateWords{
+	weekStart: time.Monday,
+	days: map[string]int{
+		"i dag": 0, "idag": 0, "i morgen": 1, "imorgen": 1, "i går": -1, "igår": -1,
+		"i overmorgen": 2, "overmorgen": 2, "i forgårs": -2, "forgårs": -2,
+	},
+	on: []string{"på"},
+	modifiers: map[string]int{
+		"denne": 0, "dette": 0,
+		"neste":   1,
+		"forrige": -1, "sist": -1, "siste": -1,
+	},
+	weekdays: map[string]time.Weekday{
+		"mandag": time.Monday, "man": time.Monday,
+		"tirsdag": time.Tuesday, "tir": time.Tuesday,
+		"onsdag": time.Wednesday, "ons": time.Wednesday,
+		"torsdag": time.Thursday, "tor": time.Thursday,
+		"fredag": time.Friday, "fre": time.Friday,
+		"lørdag": time.Saturday, "lør": time.Saturday,
+		"søndag": time.Sunday, "søn": time.Sunday,
+	},
+	units: map[string]dateUnit{
+		"dag": unitDay, "dager": unitDay, "dagen": unitDay,
+		"uke": unitWeek, "uker": unitWeek, "uka": unitWeek, "uken": unitWeek,
+		"måned": unitMonth, "måneder": unitMonth, "måneden": unitMonth,
+		"år": unitYear, "året": unitYear,
+	},
+	numbers: map[string]int{"en": 1, "ei": 1, "ett": 1, "et": 1},
+	directions: []dateDirection{
+		{sign: 1, prefix: []string{"om"}},
+		{sign: -1, prefix: []string{"for"}, suffix: []string{"siden"}},
+	},
+}
+
+// dateLocales are the locales a DateParser understands, by name
+var dateLocales = map[string]*dateWords{
+	"en":    &englishDateWords,
+	"en-us": withWeekStart(englishDateWords, time.Sunday),
+	"de":    &germanDateWords,
+	"nb":    &norwegianDateWords,
+	"no":    &norwegianDateWords,
+}
+
+// withWeekStart returns a copy of words whose weeks begin on weekStart.
+func withWeekStart(words dateWords, weekStart time.Weekday) *dateWords {
+	words.weekStart = weekStart
+	return &words
+}
+
+// offsetRegex matches offsets such as +3d or -2w
+var offsetRegex = regexp.MustCompile(`^([+-])(\d+)([dwmy])$`)
+
+// offsetUnits are the units of offsets by their letter
+var offsetUnits = map[string]dateUnit{"d": unitDay, "w": unitWeek, "m": unitMonth, "y": unitYear}
+
+// DateLocales returns the names of the locales a DateParser understands, sorted.
+func DateLocales() []string {
+	names := make([]string, 0, len(dateLocales))
+	for name := range dateLocales {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewDateParser returns a parser of dates written in locale, one of DateLocales,
+// with the weeks beginning on the locale's first day of the week. Locale names
+// are not case-sensitive; an empty locale is English.
+func NewDateParser(locale string) (DateParser, error) {
+	words, ok := lookupDateWords(locale)
+	if !ok {
+		return DateParser{}, fmt.Errorf("unknown date locale %q (use one of %s)", locale, strings.Join(DateLocales(), ", "))
+	}
+	return DateParser{Locale: locale, WeekStart: words.weekStart}, nil
+}
+
+// lookupDateWords returns the words of locale.
+func lookupDateWords(locale string) (*dateWords, bool) {
+	if locale == "" {
+		return &englishDateWords, true
+	}
+	words, ok := dateLocales[strings.ToLower(locale)]
+	return words, ok
+}
+
+// ParseWeekday returns the weekday named by its English name, such as "monday",
+// regardless of case.
+func ParseWeekday(name string) (time.Weekday, error) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(name, day.String()) {
+			return day, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid weekday %q", name)
+}
+
+// Parse returns the date text refers to, at midnight of today's location, relative
+// to today. Case and surrounding or repeated spaces are ignored.
+func (p DateParser) Parse(text string, today time.Time) (time.Time, error) {
+	words, ok := lookupDateWords(p.Locale)
+	if !ok {
+		return time.Time{}, fmt.Errorf("unknown date locale %q", p.Locale)
+	}
+	day := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, today.Location())
+
+	tokens := strings.Fields(strings.ToLower(text))
+	phrase := strings.Join(tokens, " ")
+	if t, err := time.ParseInLocation(DateFormat, phrase, today.Location()); err == nil {
+		return t, nil
+	}
+	if offset, ok := words.days[phrase]; ok {
+		return day.AddDate(0, 0, offset), nil
+	}
+	if m := offsetRegex.FindStringSubmatch(phrase); m != nil {
+		n, err := strconv.Atoi(m[2])
+		if err == nil {
+			if m[1] == "-" {
+				n = -n
+			}
+			return addDateUnits(day, offsetUnits[m[3]], n), nil
+		}
+	}
+
+	if len(tokens) == 2 && slices.Contains(words.on, tokens[0]) {
+		tokens = tokens[1:]
+	}
+	if len(tokens) == 1 {
+		if weekday, ok := words.weekdays[tokens[0]]; ok {
+			return day.AddDate(0, 0, daysUntil(day.Weekday(), weekday)), nil
+		}
+	}
+	if len(tokens) == 2 {
+		if modifier, ok := words.modifiers[tokens[0]]; ok {
+			if weekday, ok := words.weekdays[tokens[1]]; ok {
+				return p.relativeWeekday(day, modifier, weekday), nil
+			}
+			if unit, ok := words.units[tokens[1]]; ok {
+				return p.relativePeriod(day, modifier, unit), nil
+			}
+		}
+	}
+	for _, direction := range words.directions {
+		if n, unit, ok := words.amount(tokens, direction); ok {
+			return addDateUnits(day, unit, direction.sign*n), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date %q (use YYYY-MM-DD, +3d, or e.g. tomorrow, next friday, 2 weeks ago)", text)
+}
+
+// amount returns the number and unit of tokens written in direction, as "N units"
+// between the prefix and suffix of direction.
+func (w *dateWords) amount(tokens []string, direction dateDirection) (int, dateUnit, bool) {
+	if len(tokens) != len(direction.prefix)+2+len(direction.suffix) {
+		return 0, 0, false
+	}
+	for i, word := range direction.prefix {
+		if tokens[i] != word {
+			return 0, 0, false
+		}
+	}
+	for i, word := range direction.suffix {
+		if tokens[len(direction.prefix)+2+i] != word {
+			return 0, 0, false
+		}
+	}
+	number, name := tokens[len(direction.prefix)], tokens[len(direction.prefix)+1]
+	n, ok := w.numbers[number]
+	if !ok {
+		var err error
+		if n, err = strconv.Atoi(number); err != nil || n < 0 {
+			return 0, 0, false
+		}
+	}
+	unit, ok := w.units[name]
+	return n, unit, ok
+}
+
+// relativeWeekday returns weekday in the week of day for modifier 0, in the week
+// after for 1, and the latest weekday before day for -1.
+func (p DateParser) relativeWeekday(day time.Time, modifier int, weekday time.Weekday) time.Time {
+	if modifier < 0 {
+		return day.AddDate(0, 0, -daysUntil(weekday, day.Weekday()))
+	}
+	start := p.weekStart(day).AddDate(0, 0, 7*modifier)
+	return start.AddDate(0, 0, (int(weekday)-int(p.WeekStart)+7)%7)
+}
+
+// relativePeriod returns the first day of the current, next or previous unit of day
+// for modifier 0, 1 and -1.
+func (p DateParser) relativePeriod(day time.Time, modifier int, unit dateUnit) time.Time {
+	switch unit {
+	case unitWeek:
+		return p.weekStart(day).AddDate(0, 0, 7*modifier)
+	case unitMonth:
+		return time.Date(day.Year(), day.Month()+time.Month(modifier), 1, 0, 0, 0, 0, day.Location())
+	case unitYear:
+		return time.Date(day.Year()+modifier, time.January, 1, 0, 0, 0, 0, day.Location())
+	}
+	return day.AddDate(0, 0, modifier)
+}
+
+// weekStart returns the first day of the week of day.
+func (p DateParser) weekStart(day time.Time) time.Time {
+	return day.AddDate(0, 0, -((int(day.Weekday()) - int(p.WeekStart) + 7) % 7))
+}
+
+// daysUntil returns the days from weekday from to the next weekday to, 1 to 7.
+func daysUntil(from, to time.Weekday) int {
+	return (int(to)-int(from)+6)%7 + 1
+}
+
+// addDateUnits returns day moved by n units. Months and years that would overflow
+// into the next month end on the last day of the month instead.
+func addDateUnits(day time.Time, unit dateUnit, n int) time.Time {
+	switch unit {
+	case unitWeek:
+		return day.AddDate(0, 0, 7*n)
+	case unitMonth, unitYear:
+		months := n
+		if unit == unitYear {
+			months = 12 * n
+		}
+		first := time.Date(day.Year(), day.Month()+time.Month(months), 1, 0, 0, 0, 0, day.Location())
+		last := first.AddDate(0, 1, -1).Day()
+		return first.AddDate(0, 0, min(day.Day(), last)-1)
+	}
+	return day.AddDate(0, 0, n)
+}
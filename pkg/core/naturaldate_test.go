@@ -0,0 +1,245 @@
+package core
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDateParser(t *testing.T) {
+	wednesday := time.Date(2025, 6, 18, 15, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		locale string
+		text   string
+		want   string
+	}{
+		// Dates and offsets
+		{"en", "2025-07-01", "2025-07-01"},
+		{"en", "+3d", "2025-06-21"},
+		{"en", "-2w", "2025-06-04"},
+		{"en", "+1m", "2025-07-18"},
+		{"en", "+1y", "2026-06-18"},
+		{"en", "-0d", "2025-06-18"},
+		{"de", "+3d", "2025-06-21"},
+
+		// Days relative to today
+		{"en", "today", "2025-06-18"},
+		{"en", "  Today ", "2025-06-18"},
+		{"en", "now", "2025-06-18"},
+		{"en", "tomorrow", "2025-06-19"},
+		{"en", "yesterday", "2025-06-17"},
+		{"en", "day after tomorrow", "2025-06-20"},
+		{"en", "the day  before yesterday", "2025-06-16"},
+
+		// Weekdays are the first such day after today
+		{"en", "friday", "2025-06-20"},
+		{"en", "Fri", "2025-06-20"},
+		{"en", "on monday", "2025-06-23"},
+		{"en", "tuesday", "2025-06-24"},
+		{"en", "wednesday", "2025-06-25"},
+		{"en", "thurs", "2025-06-19"},
+
+		// Weekdays of this, next and last week
+		{"en", "this friday", "2025-06-20"},
+		{"en", "this monday", "2025-06-16"},
+		{"en", "this sunday", "2025-06-22"},
+		{"en", "next friday", "2025-06-27"},
+		{"en", "next monday", "2025-06-23"},
+		{"en", "next sunday", "2025-06-29"},
+		{"en", "last friday", "2025-06-13"},
+		{"en", "last tuesday", "2025-06-17"},
+		{"en", "last wednesday", "2025-06-11"},
+
+		// Periods begin on their first day
+		{"en", "this week", "2025-06-16"},
+		{"en", "next week", "2025-06-23"},
+		{"en", "last week", "2025-06-09"},
+		{"en", "this month", "2025-06-01"},
+		{"en", "next month", "2025-07-01"},
+		{"en", "last month", "2025-05-01"},
+		{"en", "this year", "2025-01-01"},
+		{"en", "next year", "2026-01-01"},
+		{"en", "last year", "2024-01-01"},
+		{"en", "next day", "2025-06-19"},
+		{"en", "last day", "2025-06-17"},
+
+		// Amounts of units
+		{"en", "in 3 days", "2025-06-21"},
+		{"en", "in a week", "2025-06-25"},
+		{"en", "in 0 days", "2025-06-18"},
+		{"en", "3 days from now", "2025-06-21"},
+		{"en", "2 weeks ago", "2025-06-04"},
+		{"en", "1 month ago", "2025-05-18"},
+		{"en", "a year ago", "2024-06-18"},
+		{"en", "in 2 years", "2027-06-18"},
+
+		// Weeks beginning on Sunday
+		{"en-US", "this monday", "2025-06-16"},
+		{"en-US", "this sunday", "2025-06-15"},
+		{"en-US", "next sunday", "2025-06-22"},
+		{"en-US", "next saturday", "2025-06-28"},
+		{"en-US", "this week", "2025-06-15"},
+		{"en-US", "next week", "2025-06-22"},
+		{"en-US", "last week", "2025-06-08"},
+		{"en-US", "last sunday", "2025-06-15"},
+
+		// German
+		{"de", "heute", "2025-06-18"},
+		{"de", "Morgen", "2025-06-19"},
+		{"de", "gestern", "2025-06-17"},
+		{"de", "übermorgen", "2025-06-20"},
+		{"de", "vorgestern", "2025-06-16"},
+		{"de", "Freitag", "2025-06-20"},
+		{"de", "am Fr", "2025-06-20"},
+		{"de", "nächsten Freitag", "2025-06-27"},
+		{"de", "letzten Freitag", "2025-06-13"},
+		{"de", "diesen Sonntag", "2025-06-22"},
+		{"de", "diese Woche", "2025-06-16"},
+		{"de", "nächste Woche", "2025-06-23"},
+		{"de", "nächsten Monat", "2025-07-01"},
+		{"de", "letztes Jahr", "2024-01-01"},
+		{"de", "in 3 Tagen", "2025-06-21"},
+		{"de", "vor 2 Wochen", "2025-06-04"},
+		{"de", "vor einem Monat", "2025-05-18"},
+		{"de", "in einem Jahr", "2026-06-18"},
+
+		// Norwegian
+		{"nb", "i dag", "2025-06-18"},
+		{"nb", "I  morgen", "2025-06-19"},
+		{"nb", "i går", "2025-06-17"},
+		{"nb", "overmorgen", "2025-06-20"},
+		{"nb", "i forgårs", "2025-06-16"},
+		{"nb", "fredag", "2025-06-20"},
+		{"nb", "på fredag", "2025-06-20"},
+		{"nb", "neste fredag", "2025-06-27"},
+		{"nb", "forrige fredag", "2025-06-13"},
+		{"nb", "neste uke", "2025-06-23"},
+		{"nb", "denne måneden", "2025-06-01"},
+		{"nb", "om 3 dager", "2025-06-21"},
+		{"nb", "for 2 uker siden", "2025-06-04"},
+		{"nb", "for en måned siden", "2025-05-18"},
+		{"no", "om ett år", "2026-06-18"},
+	}
+	for _, tt := range tests {
+		p, err := NewDateParser(tt.locale)
+		if err != nil {
+			t.Fatalf("NewDateParser(%q) error: %v", tt.locale, err)
+		}
+		got, err := p.Parse(tt.text, wednesday)
+		if err != nil {
+			t.Errorf("%s: Parse(%q) error: %v", tt.locale, tt.text, err)
+			continue
+		}
+		if got.Format(DateFormat) != tt.want {
+			t.Errorf("%s: Parse(%q) = %s, want %s", tt.locale, tt.text, got.Format(DateFormat), tt.want)
+		}
+		if got.Hour() != 0 || got.Minute() != 0 || got.Location() != time.UTC {
+			t.Errorf("%s: Parse(%q) = %v, want midnight in the location of today", tt.locale, tt.text, got)
+		}
+	}
+}
+
+func TestDateParser_Months(t *testing.T) {
+	p := DateParser{}
+	tests := []struct {
+		today, text, want string
+	}{
+		{"2025-01-31", "+1m", "2025-02-28"},
+		{"2025-01-31", "in 1 month", "2025-02-28"},
+		{"2024-01-31", "in a month", "2024-02-29"},
+		{"2025-03-31", "1 month ago", "2025-02-28"},
+		{"2024-02-29", "+1y", "2025-02-28"},
+		{"2025-01-15", "last month", "2024-12-01"},
+		{"2025-12-15", "next month", "2026-01-01"},
+		{"2025-12-31", "+2m", "2026-02-28"},
+	}
+	for _, tt := range tests {
+		today, _ := time.Parse(DateFormat, tt.today)
+		got, err := p.Parse(tt.text, today)
+		if err != nil {
+			t.Errorf("Parse(%q) on %s error: %v", tt.text, tt.today, err)
+			continue
+		}
+		if got.Format(DateFormat) != tt.want {
+			t.Errorf("Parse(%q) on %s = %s, want %s", tt.text, tt.today, got.Format(DateFormat), tt.want)
+		}
+	}
+}
+
+func TestDateParser_Invalid(t *testing.T) {
+	today := time.Date(2025, 6, 18, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		locale, text string
+	}{
+		{"en", ""},
+		{"en", "someday"},
+		{"en", "next"},
+		{"en", "in days"},
+		{"en", "3 days"},
+		{"en", "in -3 days"},
+		{"en", "an hour ago"},
+		{"en", "friday next"},
+		{"en", "on next friday"},
+		{"en", "+3h"},
+		{"en", "3d"},
+		{"en", "2025-13-01"},
+		{"en", "morgen"},
+		{"de", "tomorrow"},
+		{"de", "2 weeks ago"},
+		{"nb", "for 2 uker"},
+	}
+	for _, tt := range tests {
+		p, _ := NewDateParser(tt.locale)
+		if got, err := p.Parse(tt.text, today); err == nil {
+			t.Errorf("%s: Parse(%q) = %s, want an error", tt.locale, tt.text, got.Format(DateFormat))
+		}
+	}
+
+	if _, err := (DateParser{Locale: "fr"}).Parse("today", today); err == nil {
+		t.Error("Parse() with an unknown locale succeeded")
+	}
+}
+
+func TestNewDateParser(t *testing.T) {
+	tests := []struct {
+		locale    string
+		weekStart time.Weekday
+	}{
+		{"", time.Monday},
+		{"en", time.Monday},
+		{"EN-US", time.Sunday},
+		{"de", time.Monday},
+		{"nb", time.Monday},
+	}
+	for _, tt := range tests {
+		p, err := NewDateParser(tt.locale)
+		if err != nil {
+			t.Errorf("NewDateParser(%q) error: %v", tt.locale, err)
+			continue
+		}
+		if p.WeekStart != tt.weekStart {
+			t.Errorf("NewDateParser(%q).WeekStart = %v, want %v", tt.locale, p.WeekStart, tt.weekStart)
+		}
+	}
+
+	if _, err := NewDateParser("fr"); err == nil {
+		t.Error("NewDateParser(\"fr\") succeeded")
+	}
+	if locales := DateLocales(); !slices.IsSorted(locales) || !slices.Contains(locales, "en") {
+		t.Errorf("DateLocales() = %v, want sorted locales including en", locales)
+	}
+}
+
+func TestParseWeekday(t *testing.T) {
+	for name, want := range map[string]time.Weekday{"monday": time.Monday, "Sunday": time.Sunday, "SATURDAY": time.Saturday} {
+		if got, err := ParseWeekday(name); err != nil || got != want {
+			t.Errorf("ParseWeekday(%q) = %v, %v; want %v", name, got, err, want)
+		}
+	}
+	for _, name := range []string{"", "mon", "montag"} {
+		if _, err := ParseWeekday(name); err == nil {
+			t.Errorf("ParseWeekday(%q) succeeded", name)
+		}
+	}
+}
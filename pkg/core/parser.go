@@ -2,7 +2,9 @@
 package core
 
 import (
+	"context"
 	"fmt"
+	"regexp"
 	"strings"
 	"time"
 )
@@ -18,47 +20,125 @@ func ValidateDate(dateStr string) error {
 
 // parserState holds the state during parsing to reduce parameter passing
 type parserState struct {
-	currentDay         *DaySection // The current day being parsed
-	currentIndentStack []int       // A stack of indentation levels for the current hierarchy of todo items
-	currentItemStack   []*TodoItem // A stack of todo items corresponding to the indent stack
+	currentDay         *DaySection     // The current day being parsed
+	currentIndentStack []int           // A stack of indentation levels for the current hierarchy of todo items
+	currentItemStack   []*TodoItem     // A stack of todo items corresponding to the indent stack
+	format             Format          // Layout of the section being parsed
+	dayHeaders         []*dayHeader    // Day headers recognised in the section
+	lines              *lineClassifier // Tells todo item, bullet and continuation lines of the format apart
+	dateTag            *dateTag        // Converts date tags of the format, nil for DefaultDateTag
+	carryMarker        *carryMarker    // Reads carry counts, nil if the format does not count carries
+	movedTo            *regexp.Regexp  // Matches the moved message left in processed journals
+	arena              *Arena          // Shares the memory of the parsed journal, nil for none
+	outdent            int             // Levels the items of the current day are indented less than canonical
+	callout            []string        // Quoted lines waiting for the next todo item, see processQuotedLine
+	calloutLine        int             // Line number of the first waiting quoted line
+	seenDates          map[string]bool
+	warnings           []Warning
 }
 
-// newParserState creates a new parser state
-func newParserState() *parserState {
+// newParserState creates a new parser state allocating from arena, which may be
+// nil. The format must be valid.
+func newParserState(format Format, arena *Arena) *parserState {
+	dayHeaders, err := format.dayHeaders()
+	if err != nil {
+		dayHeaders = []*dayHeader{defaultDayHeader}
+	}
+	tag, _ := format.dateTag()
+	marker, _ := format.carryMarker()
 	return &parserState{
 		currentDay:         nil,
 		currentIndentStack: []int{},
 		currentItemStack:   []*TodoItem{},
+		format:             format,
+		dayHeaders:         dayHeaders,
+		lines:              newLineClassifier(format),
+		dateTag:            tag,
+		carryMarker:        marker,
+		movedTo:            movedToRegex(format.MovedMessage),
+		arena:              arena,
+		seenDates:          make(map[string]bool),
 	}
 }
 
+// warn records a parse warning for the line lineNum.
+func (ps *parserState) warn(lineNum int, kind, format string, args ...interface{}) {
+	ps.warnings = append(ps.warnings, Warning{Line: lineNum, Kind: kind, Message: fmt.Sprintf(format, args...)})
+}
+
 // reset resets the parser state for a new day, clearing the indentation and item stacks.
 func (ps *parserState) reset() {
 	ps.currentIndentStack = []int{}
 	ps.currentItemStack = []*TodoItem{}
 }
 
+// contextCheckInterval is the number of lines parsed between context cancellation checks
+const contextCheckInterval = 256
+
 // ParseTodosSection parses the Todos section into a structured format
 func ParseTodosSection(content string) (*TodoJournal, error) {
+	return ParseTodosSectionContext(context.Background(), content)
+}
+
+// ParseTodosSectionContext parses the Todos section like ParseTodosSection but stops
+// early and returns the context's error if ctx is cancelled or its deadline expires.
+func ParseTodosSectionContext(ctx context.Context, content string) (*TodoJournal, error) {
+	return ParseTodosSectionFormat(ctx, content, DefaultFormat)
+}
+
+// ParseTodosSectionFormat parses a Todos section laid out in format, like
+// ParseTodosSectionContext. Bullet lines are converted to the indentation of
+// DefaultFormat.
+func ParseTodosSectionFormat(ctx context.Context, content string, format Format) (*TodoJournal, error) {
+	journal, _, err := ParseTodosSectionWarnings(ctx, content, format)
+	return journal, err
+}
+
+// ParseTodosSectionWarnings parses a Todos section like ParseTodosSectionFormat and
+// also returns the problems that did not stop parsing, see Warning.
+func ParseTodosSectionWarnings(ctx context.Context, content string, format Format) (*TodoJournal, []Warning, error) {
+	return parseTodosSection(ctx, content, format, nil)
+}
+
+// parseTodosSection parses a Todos section like ParseTodosSectionWarnings,
+// allocating from arena unless it is nil.
+func parseTodosSection(ctx context.Context, content string, format Format, arena *Arena) (*TodoJournal, []Warning, error) {
+	if err := format.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if err := format.Limits.CheckFileSize(len(content)); err != nil {
+		return nil, nil, err
+	}
+	state := newParserState(format, arena)
+
 	journal := &TodoJournal{
 		Days: []*DaySection{},
 	}
 
 	lines := strings.Split(content, "\n")
-	state := newParserState()
 
 	for lineNum, line := range lines {
+		if lineNum%contextCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, nil, err
+			}
+		}
+		if err := format.Limits.checkLine(line, lineNum+1); err != nil {
+			return nil, nil, err
+		}
 		if err := processLine(journal, state, line, lineNum+1); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
+	state.flushCallout()
+
 	// Add the last day if it exists
 	if state.currentDay != nil {
 		journal.Days = append(journal.Days, state.currentDay)
 	}
 
-	return journal, nil
+	return journal, state.warnings, nil
 }
 
 // processLine processes a single line of the Todos section
@@ -68,13 +148,48 @@ func processLine(journal *TodoJournal, state *parserState, line string, lineNum
 		return nil
 	}
 
+	// Todos and lines in blockquotes and callouts keep their quote prefix
+	if handled, err := processQuotedLine(journal, state, line, lineNum); handled {
+		return err
+	}
+	todoMatch := state.lines.todoItem(line)
+	if todoMatch == nil {
+		state.flushCallout()
+	}
+
+	// The blocked tasks written below the others are read back into their days
+	if trimmedLine == BlockedHeader {
+		if state.currentDay != nil {
+			journal.Days = append(journal.Days, state.currentDay)
+			state.currentDay = nil
+		}
+		state.reset()
+		// The blocked tasks repeat the days they come from
+		state.seenDates = make(map[string]bool)
+		return nil
+	}
+
 	// Check for day header
-	if dateMatch := DayHeaderRegex.FindStringSubmatch(trimmedLine); dateMatch != nil {
-		return processDayHeader(journal, state, dateMatch[1])
+	for _, header := range state.dayHeaders {
+		date, ok, err := header.parse(trimmedLine)
+		if err != nil {
+			return dateError(line, lineNum, err)
+		}
+		if ok {
+			state.outdent = header.outdent()
+			if err := processDayHeader(journal, state, state.arena.intern(date)); err != nil {
+				return dateError(line, lineNum, err)
+			}
+			if state.seenDates[date] {
+				state.warn(lineNum, WarnDuplicateDay, "day %s appears more than once", date)
+			}
+			state.seenDates[date] = true
+			return nil
+		}
 	}
 
 	// Check for todo item first
-	if todoMatch := TodoItemRegex.FindStringSubmatch(line); todoMatch != nil {
+	if todoMatch != nil {
 		// If we don't have a current day, create an undated section
 		if state.currentDay == nil {
 			state.currentDay = &DaySection{
@@ -82,42 +197,63 @@ func processLine(journal *TodoJournal, state *parserState, line string, lineNum
 				Items: []*TodoItem{},
 			}
 		}
-		return processTodoItem(state, todoMatch)
+		if n := len(state.currentIndentStack); n > 0 {
+			indent := state.format.indentWidth(todoMatch[1])
+			if levels := (indent - state.currentIndentStack[n-1]) / state.format.width(); levels > 1 {
+				state.warn(lineNum, WarnIndentJump, "%q is indented %d levels deeper than the todo above", todoMatch[3], levels)
+			}
+		}
+		return processTodoItem(state, todoMatch, lineNum)
 	}
 
 	// Check for bullet entry (- something that's not a todo)
-	if bulletMatch := BulletEntryRegex.FindStringSubmatch(line); bulletMatch != nil {
+	if bulletMatch := state.lines.bulletEntry(line); bulletMatch != nil {
 		// Only process if we have a current day (otherwise skip)
 		if state.currentDay != nil {
-			return processAssociatedLine(state, line, bulletMatch)
+			return processAssociatedLine(state, line, bulletMatch, lineNum)
 		}
+		state.warn(lineNum, WarnIgnoredLine, "%q comes before the first day header and is dropped", trimmedLine)
 		return nil
 	}
 
 	// Check for continuation line (indented text that's part of a bullet or todo)
-	if contMatch := ContinuationRegex.FindStringSubmatch(line); contMatch != nil {
+	if contMatch := continuation(line); contMatch != nil {
 		// Only process if we have a current day (otherwise skip)
 		if state.currentDay != nil {
-			return processAssociatedLine(state, line, contMatch)
+			return processAssociatedLine(state, line, contMatch, lineNum)
 		}
+		state.warn(lineNum, WarnIgnoredLine, "%q comes before the first day header and is dropped", trimmedLine)
 		return nil
 	}
 
-	// If we have a current day and the line is not empty but doesn't match any pattern,
-	// it's an unparseable line.
+	// Any other line of a day is kept verbatim under the todo above it
 	if state.currentDay != nil {
-		return fmt.Errorf("unparseable line %d: %q", lineNum, line)
+		return processUnknownLine(state, line, lineNum)
 	}
 
 	// If we don't have a current day yet, skip this line (it's before any todos)
+	if !state.movedTo.MatchString(trimmedLine) {
+		state.warn(lineNum, WarnIgnoredLine, "%q comes before the first day header and is dropped", trimmedLine)
+	}
 	return nil
 }
 
+// dateError returns the JournalError for a day header line with an invalid date.
+func dateError(line string, lineNum int, err error) error {
+	return &JournalError{
+		Line:   lineNum,
+		Col:    columnOf(line),
+		Kind:   KindDate,
+		Source: line,
+		Err:    fmt.Errorf("invalid date in day header: %w", err),
+	}
+}
+
 // processDayHeader processes a day header line
 func processDayHeader(journal *TodoJournal, state *parserState, dateStr string) error {
 	// Validate the date format
 	if err := ValidateDate(dateStr); err != nil {
-		return fmt.Errorf("invalid date in day header: %w", err)
+		return err
 	}
 
 	state.currentDay = createNewDaySection(journal, state.currentDay, dateStr)
@@ -126,25 +262,47 @@ func processDayHeader(journal *TodoJournal, state *parserState, dateStr string)
 }
 
 // processTodoItem processes a todo item line
-func processTodoItem(state *parserState, todoMatch []string) error {
-	item := createTodoItem(todoMatch)
-	indentLevel := GetIndentLevel(todoMatch[1])
+func processTodoItem(state *parserState, todoMatch []string, lineNum int) error {
+	if state.dateTag != nil {
+		todoMatch[3] = state.dateTag.canonical(todoMatch[3])
+	}
+	item := createTodoItem(todoMatch, state.format, state.arena)
+	item.CarryCount = state.carryMarker.count(item.Text)
+	item.Callout = state.takeCallout()
+	indentLevel := state.format.indentWidth(todoMatch[1])
 	state.currentIndentStack, state.currentItemStack = addItemToHierarchy(
 		state.currentDay, item, indentLevel, state.currentIndentStack, state.currentItemStack)
+	return state.format.Limits.checkDepth(len(state.currentItemStack), todoMatch[0], lineNum)
+}
+
+// processUnknownLine keeps a line of a day that is neither a todo, a bullet nor a
+// continuation line, such as a todo without its bullet, with the todo above it and
+// warns about it. A line before the first todo of its day is dropped with a warning.
+func processUnknownLine(state *parserState, line string, lineNum int) error {
+	last := state.lastItem()
+	if last == nil {
+		state.warn(lineNum, WarnIgnoredLine, "%q comes before the first todo of its day and is dropped", strings.TrimSpace(line))
+		return nil
+	}
+	state.warn(lineNum, WarnUnknownLine, "%q is not a todo or note and is kept with the todo above", strings.TrimSpace(line))
+	last.BulletLines = append(last.BulletLines, state.arena.intern(line))
 	return nil
 }
 
 // processAssociatedLine processes a line that is associated with a todo item,
 // like a bullet point or a continuation line. It finds the correct parent todo item
-// based on indentation and appends the line to its BulletLines.
-func processAssociatedLine(state *parserState, line string, matches []string) error {
-	if len(state.currentItemStack) > 0 {
-		normalizedLine := NormalizeIndentation(line)
-		indent := GetIndentLevel(matches[1])
-		targetItem := findTargetItemForBullet(state.currentItemStack, state.currentIndentStack, indent)
-		if targetItem != nil {
-			targetItem.BulletLines = append(targetItem.BulletLines, normalizedLine)
-		}
+// based on indentation and appends the line to its BulletLines. A line before the
+// first todo of its day has no todo to belong to and is dropped with a warning.
+func processAssociatedLine(state *parserState, line string, matches []string, lineNum int) error {
+	if len(state.currentItemStack) == 0 {
+		state.warn(lineNum, WarnIgnoredLine, "%q comes before the first todo of its day and is dropped", strings.TrimSpace(line))
+		return nil
+	}
+	normalizedLine := state.format.canonicalLine(line, state.outdent)
+	indent := state.format.indentWidth(matches[1])
+	targetItem := findTargetItemForBullet(state.currentItemStack, state.currentIndentStack, indent)
+	if targetItem != nil {
+		targetItem.BulletLines = append(targetItem.BulletLines, state.arena.intern(normalizedLine))
 	}
 	return nil
 }
@@ -192,14 +350,14 @@ func createNewDaySection(journal *TodoJournal, currentDay *DaySection, date stri
 	}
 }
 
-// createTodoItem creates a TodoItem from regex matches
-func createTodoItem(matches []string) *TodoItem {
-	return &TodoItem{
-		Completed:   matches[2] == "x",
-		Text:        matches[3],
-		SubItems:    []*TodoItem{},
-		BulletLines: []string{},
-	}
+// createTodoItem creates a TodoItem from the matches of a todo item line in
+// format, allocating from arena unless it is nil
+func createTodoItem(matches []string, format Format, arena *Arena) *TodoItem {
+	item := arena.newItem()
+	item.Completed = format.isDone(matches[2])
+	item.Text = arena.intern(matches[3])
+	item.Meta = ParseMeta(item.Text)
+	return item
 }
 
 // addItemToHierarchy adds a todo item to the correct position in the hierarchy
@@ -2,8 +2,12 @@
 package core
 
 import (
+	"context"
+	"errors"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 // Test helper functions
@@ -56,7 +60,7 @@ func TestValidateDate(t *testing.T) {
 
 func TestNewParserState(t *testing.T) {
 	t.Run("should create parser state with correct initial values", func(t *testing.T) {
-		state := newParserState()
+		state := newParserState(DefaultFormat, nil)
 
 		if state == nil {
 			t.Fatal("Expected non-nil parser state")
@@ -75,7 +79,7 @@ func TestNewParserState(t *testing.T) {
 
 func TestParserStateReset(t *testing.T) {
 	t.Run("should reset stacks but preserve currentDay", func(t *testing.T) {
-		state := newParserState()
+		state := newParserState(DefaultFormat, nil)
 		state.currentDay = createTestDaySectionForParser("2023-01-01")
 		state.currentIndentStack = []int{0, 2, 4}
 		state.currentItemStack = []*TodoItem{
@@ -278,17 +282,27 @@ func TestParseTaskSection(t *testing.T) {
 		}
 	})
 
-	t.Run("unparseable line should return error", func(t *testing.T) {
+	t.Run("unknown line should be kept with a warning", func(t *testing.T) {
 		content := `- [[2023-01-01]]
   - [ ] Valid task
-unparseable line`
+    - [ ] Subtask
+[ ] Missing bullet
+  - [ ] Next task`
 
-		_, err := ParseTodosSection(content)
-		if err == nil {
-			t.Error("Expected error for unparseable line")
+		journal, warnings, err := ParseTodosSectionWarnings(context.Background(), content, DefaultFormat)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
 		}
-		if !strings.Contains(err.Error(), "unparseable line") {
-			t.Errorf("Expected error message to contain 'unparseable line', got: %v", err)
+		want := []Warning{{Line: 4, Kind: WarnUnknownLine, Message: `"[ ] Missing bullet" is not a todo or note and is kept with the todo above`}}
+		if !reflect.DeepEqual(warnings, want) {
+			t.Errorf("warnings = %+v, want %+v", warnings, want)
+		}
+		items := journal.Days[0].Items
+		if len(items) != 2 || !reflect.DeepEqual(items[0].SubItems[0].BulletLines, []string{"[ ] Missing bullet"}) {
+			t.Fatalf("Expected the line kept with the subtask, got %+v", items)
+		}
+		if got, want := JournalToString(journal), "- [[2023-01-01]]\n  - [ ] Valid task\n    - [ ] Subtask\n      [ ] Missing bullet\n  - [ ] Next task"; got != want {
+			t.Errorf("JournalToString() = %q, want %q", got, want)
 		}
 	})
 
@@ -328,10 +342,47 @@ unparseable line`
 	})
 }
 
+func TestParseTodosSectionContext(t *testing.T) {
+	content := "- [[2023-01-01]]\n  - [ ] Task 1\n  - [x] Task 2"
+
+	t.Run("active context should parse normally", func(t *testing.T) {
+		journal, err := ParseTodosSectionContext(context.Background(), content)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if len(journal.Days) != 1 || len(journal.Days[0].Items) != 2 {
+			t.Errorf("Expected 1 day with 2 items, got: %+v", journal.Days)
+		}
+	})
+
+	t.Run("cancelled context should stop parsing", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		journal, err := ParseTodosSectionContext(ctx, content)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got: %v", err)
+		}
+		if journal != nil {
+			t.Error("Expected nil journal for cancelled context")
+		}
+	})
+
+	t.Run("expired deadline should stop processing", func(t *testing.T) {
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+
+		_, _, _, err := ProcessTodosSectionWithStatsContext(ctx, content, "2023-01-01", "2023-01-02")
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected context.DeadlineExceeded, got: %v", err)
+		}
+	})
+}
+
 func TestProcessLine(t *testing.T) {
 	t.Run("empty line should be ignored", func(t *testing.T) {
 		journal := &TodoJournal{Days: []*DaySection{}}
-		state := newParserState()
+		state := newParserState(DefaultFormat, nil)
 
 		err := processLine(journal, state, "", 1)
 		if err != nil {
@@ -341,7 +392,7 @@ func TestProcessLine(t *testing.T) {
 
 	t.Run("whitespace-only line should be ignored", func(t *testing.T) {
 		journal := &TodoJournal{Days: []*DaySection{}}
-		state := newParserState()
+		state := newParserState(DefaultFormat, nil)
 
 		err := processLine(journal, state, "   \t  ", 1)
 		if err != nil {
@@ -351,7 +402,7 @@ func TestProcessLine(t *testing.T) {
 
 	t.Run("day header should create new day", func(t *testing.T) {
 		journal := &TodoJournal{Days: []*DaySection{}}
-		state := newParserState()
+		state := newParserState(DefaultFormat, nil)
 
 		err := processLine(journal, state, "- [[2023-01-01]]", 1)
 		if err != nil {
@@ -368,7 +419,7 @@ func TestProcessLine(t *testing.T) {
 
 	t.Run("todo item without current day should be ignored", func(t *testing.T) {
 		journal := &TodoJournal{Days: []*DaySection{}}
-		state := newParserState()
+		state := newParserState(DefaultFormat, nil)
 
 		err := processLine(journal, state, "  - [ ] Task", 1)
 		if err != nil {
@@ -380,17 +431,16 @@ func TestProcessLine(t *testing.T) {
 		}
 	})
 
-	t.Run("unparseable line with current day should return error", func(t *testing.T) {
+	t.Run("unknown line before the first todo of a day should be dropped", func(t *testing.T) {
 		journal := &TodoJournal{Days: []*DaySection{}}
-		state := newParserState()
+		state := newParserState(DefaultFormat, nil)
 		state.currentDay = createTestDaySectionForParser("2023-01-01")
 
-		err := processLine(journal, state, "some unparseable text", 5)
-		if err == nil {
-			t.Error("Expected error for unparseable line")
+		if err := processLine(journal, state, "some unknown text", 5); err != nil {
+			t.Errorf("Unexpected error: %v", err)
 		}
-		if !strings.Contains(err.Error(), "line 5") {
-			t.Errorf("Expected error to reference line number 5, got: %v", err)
+		if len(state.warnings) != 1 || state.warnings[0].Line != 5 || state.warnings[0].Kind != WarnIgnoredLine {
+			t.Errorf("Expected an ignored line warning for line 5, got %+v", state.warnings)
 		}
 	})
 }
@@ -398,7 +448,7 @@ func TestProcessLine(t *testing.T) {
 func TestProcessDayHeader(t *testing.T) {
 	t.Run("valid date should create new day section", func(t *testing.T) {
 		journal := &TodoJournal{Days: []*DaySection{}}
-		state := newParserState()
+		state := newParserState(DefaultFormat, nil)
 
 		err := processDayHeader(journal, state, "2023-01-01")
 		if err != nil {
@@ -415,7 +465,7 @@ func TestProcessDayHeader(t *testing.T) {
 
 	t.Run("invalid date should return error", func(t *testing.T) {
 		journal := &TodoJournal{Days: []*DaySection{}}
-		state := newParserState()
+		state := newParserState(DefaultFormat, nil)
 
 		err := processDayHeader(journal, state, "invalid-date")
 		if err == nil {
@@ -428,7 +478,7 @@ func TestProcessDayHeader(t *testing.T) {
 
 	t.Run("should reset parser state", func(t *testing.T) {
 		journal := &TodoJournal{Days: []*DaySection{}}
-		state := newParserState()
+		state := newParserState(DefaultFormat, nil)
 		state.currentIndentStack = []int{0, 2}
 		state.currentItemStack = []*TodoItem{createTestTodoItemForParser("Test", false)}
 
@@ -447,7 +497,7 @@ func TestProcessDayHeader(t *testing.T) {
 
 	t.Run("should append previous day to journal", func(t *testing.T) {
 		journal := &TodoJournal{Days: []*DaySection{}}
-		state := newParserState()
+		state := newParserState(DefaultFormat, nil)
 		previousDay := createTestDaySectionForParser("2023-01-01")
 		state.currentDay = previousDay
 
@@ -467,11 +517,11 @@ func TestProcessDayHeader(t *testing.T) {
 
 func TestProcessTodoItem(t *testing.T) {
 	t.Run("should create todo item and add to hierarchy", func(t *testing.T) {
-		state := newParserState()
+		state := newParserState(DefaultFormat, nil)
 		state.currentDay = createTestDaySectionForParser("2023-01-01")
 		todoMatch := []string{"  - [ ] Task", "  ", " ", "Task"}
 
-		err := processTodoItem(state, todoMatch)
+		err := processTodoItem(state, todoMatch, 1)
 		if err != nil {
 			t.Errorf("Expected no error, got: %v", err)
 		}
@@ -493,11 +543,11 @@ func TestProcessTodoItem(t *testing.T) {
 	})
 
 	t.Run("should handle completed todo item", func(t *testing.T) {
-		state := newParserState()
+		state := newParserState(DefaultFormat, nil)
 		state.currentDay = createTestDaySectionForParser("2023-01-01")
 		todoMatch := []string{"  - [x] Completed", "  ", "x", "Completed"}
 
-		err := processTodoItem(state, todoMatch)
+		err := processTodoItem(state, todoMatch, 1)
 		if err != nil {
 			t.Errorf("Expected no error, got: %v", err)
 		}
@@ -511,7 +561,7 @@ func TestProcessTodoItem(t *testing.T) {
 
 func TestProcessAssociatedLine(t *testing.T) {
 	t.Run("should attach bullet line to appropriate todo item", func(t *testing.T) {
-		state := newParserState()
+		state := newParserState(DefaultFormat, nil)
 		state.currentDay = createTestDaySectionForParser("2023-01-01")
 
 		// Set up a todo item in the stack
@@ -520,7 +570,7 @@ func TestProcessAssociatedLine(t *testing.T) {
 		state.currentIndentStack = []int{2}
 
 		matches := []string{"    - Detail", "    ", "Detail"}
-		err := processAssociatedLine(state, "    - Detail", matches)
+		err := processAssociatedLine(state, "    - Detail", matches, 1)
 		if err != nil {
 			t.Errorf("Expected no error, got: %v", err)
 		}
@@ -534,18 +584,21 @@ func TestProcessAssociatedLine(t *testing.T) {
 	})
 
 	t.Run("should handle empty item stack gracefully", func(t *testing.T) {
-		state := newParserState()
+		state := newParserState(DefaultFormat, nil)
 		state.currentDay = createTestDaySectionForParser("2023-01-01")
 
 		matches := []string{"    - Detail", "    ", "Detail"}
-		err := processAssociatedLine(state, "    - Detail", matches)
+		err := processAssociatedLine(state, "    - Detail", matches, 1)
 		if err != nil {
 			t.Errorf("Expected no error for empty stack, got: %v", err)
 		}
+		if len(state.warnings) != 1 || state.warnings[0].Kind != WarnIgnoredLine {
+			t.Errorf("Expected an ignored-line warning for empty stack, got: %+v", state.warnings)
+		}
 	})
 
 	t.Run("should normalize indentation in bullet lines", func(t *testing.T) {
-		state := newParserState()
+		state := newParserState(DefaultFormat, nil)
 		state.currentDay = createTestDaySectionForParser("2023-01-01")
 
 		item := createTestTodoItemForParser("Main task", false)
@@ -555,7 +608,7 @@ func TestProcessAssociatedLine(t *testing.T) {
 		// Line with tabs that should be normalized
 		line := "\t\t- Detail with tabs"
 		matches := []string{line, "\t\t", "Detail with tabs"}
-		err := processAssociatedLine(state, line, matches)
+		err := processAssociatedLine(state, line, matches, 1)
 		if err != nil {
 			t.Errorf("Expected no error, got: %v", err)
 		}
@@ -679,7 +732,7 @@ func TestCreateTodoItem(t *testing.T) {
 	t.Run("should create uncompleted todo item", func(t *testing.T) {
 		matches := []string{"  - [ ] Task", "  ", " ", "Task"}
 
-		item := createTodoItem(matches)
+		item := createTodoItem(matches, DefaultFormat, nil)
 
 		if item == nil {
 			t.Fatal("Expected non-nil todo item")
@@ -701,7 +754,7 @@ func TestCreateTodoItem(t *testing.T) {
 	t.Run("should create completed todo item", func(t *testing.T) {
 		matches := []string{"  - [x] Completed Task", "  ", "x", "Completed Task"}
 
-		item := createTodoItem(matches)
+		item := createTodoItem(matches, DefaultFormat, nil)
 
 		if !item.Completed {
 			t.Error("Expected item to be completed")
@@ -717,7 +770,7 @@ func TestCreateTodoItem(t *testing.T) {
 			expected bool
 		}{
 			{"x", true},
-			{"X", false}, // only lowercase x is treated as completed
+			{"X", true}, // upper case X is read like x, as GitHub does
 			{" ", false},
 			{"-", false},
 			{"o", false},
@@ -725,7 +778,7 @@ func TestCreateTodoItem(t *testing.T) {
 
 		for _, tc := range testCases {
 			matches := []string{"  - [" + tc.marker + "] Task", "  ", tc.marker, "Task"}
-			item := createTodoItem(matches)
+			item := createTodoItem(matches, DefaultFormat, nil)
 
 			if item.Completed != tc.expected {
 				t.Errorf("Expected completed=%v for marker '%s', got %v", tc.expected, tc.marker, item.Completed)
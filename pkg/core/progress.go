@@ -0,0 +1,33 @@
+// Package core provides progress reporting of batch operations for the todoer application.
+package core
+
+// Stages of a journal reported to a Progress
+const (
+	StageRead   = "read"   // Reading the journal file
+	StageParse  = "parse"  // Parsing its TODOS section
+	StageCheck  = "check"  // Checking it for problems
+	StageCreate = "create" // Creating it from the journal before it
+)
+
+// Progress receives the progress of an operation over many journals, such as
+// collecting statistics or exporting a journal tree, so that long runs can be
+// reported. The methods are called from a single goroutine: Start once, then
+// Stage and Done for every journal in turn, then Finish once, also when the
+// operation fails.
+type Progress interface {
+	Start(total int)             // The operation begins with total journals
+	Stage(journal, stage string) // The journal enters stage, e.g. StageParse
+	Done(journal string)         // The journal is finished, whether or not it succeeded
+	Finish()                     // The operation ended
+}
+
+// NoProgress is a Progress that reports nothing.
+var NoProgress Progress = noProgress{}
+
+// noProgress implements NoProgress
+type noProgress struct{}
+
+func (noProgress) Start(int)            {}
+func (noProgress) Stage(string, string) {}
+func (noProgress) Done(string)          {}
+func (noProgress) Finish()              {}
@@ -0,0 +1,68 @@
+// Package core provides journal queries for conditional template sections in the todoer application.
+package core
+
+import "strings"
+
+// Overdue returns the open todos, at any depth, whose due date lies before the
+// current date, most overdue first, e.g. {{range .Overdue}}- {{.Text}} ({{.Days}}d){{end}}.
+func (d TemplateData) Overdue() []TaskAlert {
+	alerts, err := FindAlerts(d.journal, d.Date, 0, 0, "")
+	if err != nil {
+		return nil
+	}
+	return alerts
+}
+
+// HasOverdue reports whether any open todo is overdue, see Overdue, e.g.
+// {{if .HasOverdue}}## Overdue{{end}}.
+func (d TemplateData) HasOverdue() bool {
+	return len(d.Overdue()) > 0
+}
+
+// OpenByTag returns the texts of the open todos, at any depth, tagged with tag, in
+// journal order, e.g. {{range .OpenByTag "#work"}}. The leading '#' of tag is
+// optional and case is ignored.
+func (d TemplateData) OpenByTag(tag string) []string {
+	var texts []string
+	for _, loc := range FindItems(d.journal, MatchAll(MatchOpen, MatchTag(tag))) {
+		texts = append(texts, loc.Item.Text)
+	}
+	return texts
+}
+
+// CompletedOn returns the texts of the todos completed on date, without their date
+// tag, e.g. {{range .CompletedOn .PreviousDate}}. Completed todos without a date
+// tag count on the previous date, which processing tags them with. A todo listed
+// under several days is returned once.
+func (d TemplateData) CompletedOn(date string) []string {
+	var texts []string
+	seen := make(map[string]bool)
+	for _, c := range CollectCompletions(d.journal, d.PreviousDate) {
+		if c.Date != date || seen[c.Text] {
+			continue
+		}
+		seen[c.Text] = true
+		texts = append(texts, StripDateTags(c.Text))
+	}
+	return texts
+}
+
+// PreviousSection returns the content of the section of the previous journal with
+// header, without the header and the blank lines around it, e.g.
+// {{.PreviousSection "## Notes"}} to quote yesterday's notes. A header without
+// leading '#' is taken as a "## " header. Only "## " sections can be found; deeper
+// headers are part of the section they appear in. It is empty without a previous
+// journal or such a section.
+func (d TemplateData) PreviousSection(header string) string {
+	if d.previous == nil {
+		return ""
+	}
+	if !strings.HasPrefix(header, "#") {
+		header = "## " + header
+	}
+	section := d.previous.Section(header)
+	if section == nil {
+		return ""
+	}
+	return strings.Trim(section.Body, "\r\n")
+}
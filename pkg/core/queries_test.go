@@ -0,0 +1,111 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTemplateDataQueries(t *testing.T) {
+	journal, err := ParseTodosSection(`- [[2025-06-18]]
+  - [ ] Pay invoice due::[[2025-06-15]] #work
+  - [x] Send report #work #2025-06-19
+  - [ ] Plan trip due::[[2025-06-30]]
+    - [ ] Book hotel #WORK
+    - [x] Renew passport
+- [[2025-06-19]]
+  - [x] Send report #work #2025-06-19
+  - [ ] File taxes 📅 2025-06-10`)
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error: %v", err)
+	}
+	data, err := buildTemplateData(TemplateOptions{Content: "x", CurrentDate: "2025-06-20", PreviousDate: "2025-06-19", Journal: journal})
+	if err != nil {
+		t.Fatalf("buildTemplateData() error: %v", err)
+	}
+
+	if !data.HasOverdue() {
+		t.Error("HasOverdue() = false, want true")
+	}
+	want := []TaskAlert{
+		{Text: "File taxes 📅 2025-06-10", Kind: AlertOverdue, Date: "2025-06-10", Days: 10},
+		{Text: "Pay invoice due::[[2025-06-15]] #work", Kind: AlertOverdue, Date: "2025-06-15", Days: 5},
+	}
+	if got := data.Overdue(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Overdue() = %+v, want %+v", got, want)
+	}
+	if got, want := data.OpenByTag("work"), []string{"Pay invoice due::[[2025-06-15]] #work", "Book hotel #WORK"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("OpenByTag() = %q, want %q", got, want)
+	}
+	if got, want := data.CompletedOn("2025-06-19"), []string{"Send report #work", "Renew passport"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CompletedOn() = %q, want %q", got, want)
+	}
+	if got := data.CompletedOn("2025-06-18"); len(got) != 0 {
+		t.Errorf("CompletedOn(2025-06-18) = %q, want none", got)
+	}
+
+	// Without a journal the queries find nothing
+	empty := TemplateData{Date: "2025-06-20"}
+	if empty.HasOverdue() || len(empty.OpenByTag("work")) != 0 || len(empty.CompletedOn("2025-06-20")) != 0 {
+		t.Error("queries without a journal found todos")
+	}
+}
+
+func TestTemplateDataQueriesTemplate(t *testing.T) {
+	journal, err := ParseTodosSection(`- [[2025-06-19]]
+  - [ ] Pay invoice due::[[2025-06-15]]
+  - [ ] Prepare slides #work
+  - [x] Ship release`)
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error: %v", err)
+	}
+	content := `{{if .HasOverdue}}Overdue:{{range .Overdue}} {{.}} ({{.Days}}d){{end}}
+{{end}}{{with .OpenByTag "#work"}}Work:{{range .}} {{.}}{{end}}
+{{end}}{{with .OpenByTag "#home"}}Home{{end}}Done:{{range .CompletedOn .PreviousDate}} {{.}}{{end}}`
+	if err := CheckTemplate(content, nil); err != nil {
+		t.Fatalf("CheckTemplate() error: %v", err)
+	}
+	got, err := CreateFromTemplate(TemplateOptions{Content: content, CurrentDate: "2025-06-20", PreviousDate: "2025-06-19", Journal: journal})
+	if err != nil {
+		t.Fatalf("CreateFromTemplate() error: %v", err)
+	}
+	want := "Overdue: Pay invoice due::[[2025-06-15]] (5d)\nWork: Prepare slides #work\nDone: Ship release"
+	if got != want {
+		t.Errorf("CreateFromTemplate() = %q, want %q", got, want)
+	}
+}
+
+func TestPreviousSection(t *testing.T) {
+	previous := ParseDocument(`---
+title: 2025-06-19
+---
+
+## Todos
+
+- [ ] Task
+
+## Notes
+
+Met with Bob.
+### Follow-up
+- Send slides
+
+## Standup
+`)
+	rendered, err := CreateFromTemplate(TemplateOptions{
+		Content:     `{{.PreviousSection "## Notes"}}|{{.PreviousSection "Standup"}}|{{.PreviousSection "## Missing"}}`,
+		CurrentDate: "2025-06-20",
+		Previous:    previous,
+	})
+	if err != nil {
+		t.Fatalf("CreateFromTemplate() error: %v", err)
+	}
+	if want := "Met with Bob.\n### Follow-up\n- Send slides||"; rendered != want {
+		t.Errorf("PreviousSection() rendered %q, want %q", rendered, want)
+	}
+	if got := (TemplateData{}).PreviousSection("## Notes"); got != "" {
+		t.Errorf("PreviousSection() without a previous journal = %q, want empty", got)
+	}
+	if err := CheckTemplate(`{{.PreviousSection "## Notes"}}`, nil); err != nil {
+		t.Errorf("CheckTemplate() error = %v", err)
+	}
+}
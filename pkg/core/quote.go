@@ -0,0 +1,117 @@
+// Package core provides parsing of todos in blockquotes and callouts for the todoer application.
+package core
+
+import (
+	"regexp"
+	"strings"
+)
+
+// quoteRegex matches a line in a blockquote, such as the lines of an Obsidian
+// callout. Captures: (indentation and quote markers, rest of the line)
+var quoteRegex = regexp.MustCompile(`^([ \t]*>(?:[ ]?>)*[ ]?)(.*)$`)
+
+// processQuotedLine processes a line of the Todos section inside a blockquote and
+// reports whether it did. Quoted todos become items keeping their quote prefix.
+// Other quoted lines belong to the quoted item above them when they are bullets
+// or indented, and else to the next item, as the title line of a callout does.
+// Indented quotes below an item that is not quoted are left to be read as its
+// continuation lines.
+func processQuotedLine(journal *TodoJournal, state *parserState, line string, lineNum int) (bool, error) {
+	m := quoteRegex.FindStringSubmatch(line)
+	if m == nil {
+		return false, nil
+	}
+	quote, rest := m[1], m[2]
+	lead := quote[:len(quote)-len(strings.TrimLeft(quote, " \t"))]
+
+	if todoMatch := state.lines.todoItem(rest); todoMatch != nil {
+		if state.currentDay == nil {
+			state.currentDay = &DaySection{Date: "", Items: []*TodoItem{}}
+		}
+		prefix := quote + todoMatch[1]
+		todoMatch[0], todoMatch[1] = line, strings.Repeat(" ", state.quoteIndent(lead, todoMatch[1]))
+		if err := processTodoItem(state, todoMatch, lineNum); err != nil {
+			return true, err
+		}
+		state.currentItemStack[len(state.currentItemStack)-1].Quote = state.arena.intern(prefix)
+		return true, nil
+	}
+
+	last := state.lastItem()
+	quotedItem := last != nil && last.Quote != ""
+	if lead != "" && last != nil && !quotedItem {
+		return false, nil
+	}
+	if quotedItem && (state.lines.bulletEntry(rest) != nil || strings.TrimLeft(rest, " \t") != rest) {
+		indent := state.quoteIndent(lead, rest)
+		if target := findTargetItemForBullet(state.currentItemStack, state.currentIndentStack, indent); target != nil {
+			target.BulletLines = append(target.BulletLines, state.arena.intern(line))
+		}
+		return true, nil
+	}
+	if len(state.callout) == 0 {
+		state.calloutLine = lineNum
+	}
+	state.callout = append(state.callout, state.arena.intern(line))
+	return true, nil
+}
+
+// quoteIndent returns the indentation in columns of a quoted line whose quote is
+// indented by lead and whose text by inner. A quote in the first column holds
+// the top-level items of the current day, like the bullets of the day would.
+func (ps *parserState) quoteIndent(lead, inner string) int {
+	indent := ps.format.indentWidth(lead) + ps.format.indentWidth(inner)
+	if lead == "" && ps.currentDay != nil && ps.currentDay.Date != "" {
+		indent += max(1-ps.outdent, 0) * ps.format.width()
+	}
+	return indent
+}
+
+// lastItem returns the todo item parsed last in the current day, nil if there is none.
+func (ps *parserState) lastItem() *TodoItem {
+	if n := len(ps.currentItemStack); n > 0 {
+		return ps.currentItemStack[n-1]
+	}
+	return nil
+}
+
+// takeCallout returns the quoted lines waiting for the next todo item and clears them.
+func (ps *parserState) takeCallout() []string {
+	callout := ps.callout
+	ps.callout = nil
+	return callout
+}
+
+// flushCallout gives the quoted lines waiting for a todo item that did not come
+// to the item parsed last, or drops them with a warning if there is none.
+func (ps *parserState) flushCallout() {
+	callout := ps.takeCallout()
+	if len(callout) == 0 {
+		return
+	}
+	if last := ps.lastItem(); last != nil {
+		last.BulletLines = append(last.BulletLines, callout...)
+		return
+	}
+	ps.warn(ps.calloutLine, WarnIgnoredLine, "%q is quoted without a todo to belong to and is dropped", strings.TrimSpace(callout[0]))
+}
+
+// isQuotedLine reports whether a bullet line of item is written as it was read:
+// lines starting a blockquote in the first column, and the quoted lines of
+// quoted items.
+func isQuotedLine(item *TodoItem, line string) bool {
+	return strings.HasPrefix(line, ">") || (item.Quote != "" && quoteRegex.MatchString(line))
+}
+
+// inheritCallout gives item, copied from a day section to dest, the callout of the
+// blockquote it is in when that callout stays with an item before it in the
+// source, so that both parts of a split blockquote keep their callout.
+func inheritCallout(item *TodoItem, callout []string, dest *DaySection) {
+	if item.Quote == "" || len(item.Callout) > 0 || len(callout) == 0 {
+		return
+	}
+	if n := len(dest.Items); n > 0 && dest.Items[n-1].Quote != "" {
+		return
+	}
+	item.Callout = append([]string(nil), callout...)
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestParseQuotedTodos(t *testing.T) {
+	content := `- [[2025-06-20]]
+  - [ ] Normal
+> [!todo] Focus
+> - [x] Done A
+>   - note on A
+> - [ ] Open B
+>   - [ ] Sub B
+> trailing text
+  - [ ] After
+    > quoted note`
+
+	journal, warnings, err := ParseTodosSectionWarnings(context.Background(), content, DefaultFormat)
+	if err != nil {
+		t.Fatalf("ParseTodosSectionWarnings() error: %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+	items := journal.Days[0].Items
+	if len(items) != 4 {
+		t.Fatalf("got %d top-level items, want 4", len(items))
+	}
+
+	doneA, openB, after := items[1], items[2], items[3]
+	if doneA.Quote != "> " || !reflect.DeepEqual(doneA.Callout, []string{"> [!todo] Focus"}) || !doneA.Completed {
+		t.Errorf("Done A = %+v, want a completed quoted item with the callout", doneA)
+	}
+	if !reflect.DeepEqual(doneA.BulletLines, []string{">   - note on A"}) {
+		t.Errorf("Done A bullet lines = %q", doneA.BulletLines)
+	}
+	if openB.Text != "Open B" || openB.Quote != "> " || len(openB.Callout) != 0 {
+		t.Errorf("Open B = %+v", openB)
+	}
+	if len(openB.SubItems) != 1 || openB.SubItems[0].Quote != ">   " {
+		t.Fatalf("Open B subitems = %+v, want Sub B quoted", openB.SubItems)
+	}
+	if after.Quote != "" || !reflect.DeepEqual(after.Callout, []string{"> trailing text"}) {
+		t.Errorf("After = %+v, want an unquoted item after the trailing quoted text", after)
+	}
+	if !reflect.DeepEqual(after.BulletLines, []string{"    > quoted note"}) {
+		t.Errorf("After bullet lines = %q, want the indented quote as continuation line", after.BulletLines)
+	}
+
+	if got := JournalToString(journal); got != content {
+		t.Errorf("JournalToString() =\n%s\nwant\n%s", got, content)
+	}
+}
+
+func TestQuotedTodosRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		format  Format
+	}{
+		{"undated callout", "> [!todo] Inbox\n> - [ ] Task\n>\n> - [x] Done", DefaultFormat},
+		{"nested quotes", "- [[2025-06-20]]\n> > - [ ] Deep\n> >   - [ ] Deeper", DefaultFormat},
+		{"indented quote", "- [[2025-06-20]]\n  > [!todo]\n  > - [ ] Task\n  >   - note", DefaultFormat},
+		{"heading days", "## 2025-06-20\n> [!todo] Today\n> - [ ] Task\n- [ ] Other", Format{DayHeader: "## 2006-01-02"}},
+		{"tabs", "- [[2025-06-20]]\n\t- [ ] Task\n> - [ ] Quoted\n>\t- [ ] Quoted sub", Format{UseTabs: true}},
+	}
+	for _, tt := range tests {
+		journal, err := ParseTodosSectionFormat(context.Background(), tt.content, tt.format)
+		if err != nil {
+			t.Errorf("%s: parse error: %v", tt.name, err)
+			continue
+		}
+		if got := JournalToStringFormat(journal, tt.format); got != tt.content {
+			t.Errorf("%s: round trip =\n%s\nwant\n%s", tt.name, got, tt.content)
+		}
+	}
+}
+
+func TestQuotedLineAfterLastTodo(t *testing.T) {
+	journal, err := ParseTodosSection("- [[2025-06-20]]\n> - [ ] Task\n>   - [ ] Sub\n> closing text\n- [[2025-06-21]]")
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error: %v", err)
+	}
+	sub := journal.Days[0].Items[0].SubItems[0]
+	if !reflect.DeepEqual(sub.BulletLines, []string{"> closing text"}) {
+		t.Errorf("Sub bullet lines = %q, want the quoted text after it", sub.BulletLines)
+	}
+}
+
+func TestQuotedLineWithoutTodo(t *testing.T) {
+	_, warnings, err := ParseTodosSectionWarnings(context.Background(), "- [[2025-06-20]]\n> just a quote\n- [[2025-06-21]]\n  - [ ] Task", DefaultFormat)
+	if err != nil {
+		t.Fatalf("ParseTodosSectionWarnings() error: %v", err)
+	}
+	if len(warnings) != 1 || warnings[0].Kind != WarnIgnoredLine || warnings[0].Line != 2 {
+		t.Errorf("warnings = %v, want the quote on line 2 ignored", warnings)
+	}
+}
+
+func TestSplitJournalKeepsCallouts(t *testing.T) {
+	journal, err := ParseTodosSection(`- [[2025-06-20]]
+> [!todo] Focus
+> - [x] Done A
+> - [ ] Open B
+> - [ ] Open C
+  - [ ] Normal`)
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error: %v", err)
+	}
+
+	completed, uncompleted := SplitJournal(journal)
+	if got, want := JournalToString(completed), "- [[2025-06-20]]\n> [!todo] Focus\n> - [x] Done A"; got != want {
+		t.Errorf("completed =\n%s\nwant\n%s", got, want)
+	}
+	if got, want := JournalToString(uncompleted), "- [[2025-06-20]]\n> [!todo] Focus\n> - [ ] Open B\n> - [ ] Open C\n  - [ ] Normal"; got != want {
+		t.Errorf("uncompleted =\n%s\nwant\n%s", got, want)
+	}
+	if len(journal.Days[0].Items[1].Callout) != 0 {
+		t.Error("SplitJournal() changed the callout of the source journal")
+	}
+}
@@ -0,0 +1,156 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// roundTripWords is the vocabulary for generated todo text. It avoids words that
+// would change how a line is classified (checkbox markers, day headers).
+var roundTripWords = []string{
+	"Review", "code", "changes", "#2025-06-19", "write", "tests", "🎉", "ünïcödé",
+	"[[2025-06-20]]", "`inline`", "**bold**", "(parenthesised)", "x", "-", "[link](url)",
+}
+
+// randomText returns a non-empty line of text made from roundTripWords
+func randomText(r *rand.Rand) string {
+	words := make([]string, 1+r.Intn(5))
+	for i := range words {
+		words[i] = roundTripWords[r.Intn(len(roundTripWords))]
+	}
+	return strings.Join(words, " ")
+}
+
+// randomItem generates a todo item in the shape todoer writes it when placed at depth
+func randomItem(r *rand.Rand, depth, maxDepth int) *TodoItem {
+	item := &TodoItem{
+		Completed:   r.Intn(2) == 0,
+		Text:        randomText(r),
+		SubItems:    []*TodoItem{},
+		BulletLines: []string{},
+	}
+
+	indent := strings.Repeat(" ", (depth+1)*IndentSpaces)
+	for i := r.Intn(3); i > 0; i-- {
+		text := randomText(r)
+		if r.Intn(2) == 0 {
+			text = "- " + text
+		}
+		// A line holding nothing but a date link is a day header by definition
+		if DayHeaderLineRegex.MatchString(text) {
+			text += " notes"
+		}
+		item.BulletLines = append(item.BulletLines, indent+text)
+	}
+
+	if depth < maxDepth {
+		for i := r.Intn(3); i > 0; i-- {
+			item.SubItems = append(item.SubItems, randomItem(r, depth+1, maxDepth))
+		}
+	}
+
+	return item
+}
+
+// randomJournal generates a journal as todoer itself would produce it
+func randomJournal(r *rand.Rand) *TodoJournal {
+	journal := &TodoJournal{Days: []*DaySection{}}
+	date := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	// The parser only produces an undated section before the first day header
+	if r.Intn(4) == 0 {
+		undated := &DaySection{Date: "", Items: []*TodoItem{}}
+		for i := 1 + r.Intn(3); i > 0; i-- {
+			undated.Items = append(undated.Items, randomItem(r, 0, 3))
+		}
+		journal.Days = append(journal.Days, undated)
+	}
+
+	for d := r.Intn(5); d > 0; d-- {
+		date = date.AddDate(0, 0, 1+r.Intn(3))
+		day := &DaySection{Date: date.Format(DateFormat), Items: []*TodoItem{}}
+		for i := r.Intn(4); i > 0; i-- {
+			day.Items = append(day.Items, randomItem(r, 1, 4))
+		}
+		journal.Days = append(journal.Days, day)
+	}
+
+	return journal
+}
+
+// roundTripFormats are the layouts the round trip tests run with
+var roundTripFormats = []Format{
+	DefaultFormat,
+	{IndentSpaces: 4},
+	{IndentSpaces: 3},
+	{IndentSpaces: 2, UseTabs: true},
+	{IndentSpaces: 4, UseTabs: true},
+	{DayHeader: "### 2006-01-02"},
+	{IndentSpaces: 4, DayHeader: "- 2006-01-02"},
+	{UseTabs: true, DayHeader: "[[2006-01-02|Mon 02 Jan]]"},
+}
+
+// TestJournalRoundTrip verifies that parsing todoer's own output reproduces the
+// journal exactly and that serializing it again yields identical text, in every format.
+func TestJournalRoundTrip(t *testing.T) {
+	for _, format := range roundTripFormats {
+		t.Run(fmt.Sprintf("%+v", format), func(t *testing.T) {
+			r := rand.New(rand.NewSource(20250620))
+
+			for i := 0; i < 2000; i++ {
+				journal := randomJournal(r)
+				output := JournalToStringFormat(journal, format)
+
+				parsed, err := ParseTodosSectionFormat(context.Background(), output, format)
+				if err != nil {
+					t.Fatalf("iteration %d: failed to parse serialized journal: %v\n%s", i, err, output)
+				}
+
+				if !reflect.DeepEqual(parsed, journal) {
+					t.Fatalf("iteration %d: parsed journal differs from the original\n%s", i, output)
+				}
+
+				if again := JournalToStringFormat(parsed, format); again != output {
+					t.Fatalf("iteration %d: round trip is not idempotent\nfirst:\n%s\nsecond:\n%s", i, output, again)
+				}
+			}
+		})
+	}
+}
+
+// TestJournalRoundTripNormalizesInput verifies that arbitrary hand-written input
+// reaches a fixed point after a single parse/serialize pass in every format.
+func TestJournalRoundTripNormalizesInput(t *testing.T) {
+	inputs := []string{
+		"- [ ] Undated task\n  - [x] Undated subtask\n- [[2025-06-20]]\n  - [ ] Dated task",
+		"- [[2025-06-20]]\n    - [ ] Four-space item\n        - [x] Four-space child\n        note under child\n    - note under parent",
+		"- [[2025-06-20]]\n\t- [ ] Tab item\n\t\t- [ ] Tab child\n\t\tcontinuation",
+		"- [[2025-06-20]]\n      - [x] Over-indented\n       - [x] Child\n   under-indented note",
+		"- [[2025-06-20]]\n- [[2025-06-21]]\n  - [ ] Only the second day has items",
+	}
+
+	for _, format := range roundTripFormats {
+		for i, input := range inputs {
+			t.Run(fmt.Sprintf("%+v/input %d", format, i), func(t *testing.T) {
+				journal, err := ParseTodosSectionFormat(context.Background(), input, format)
+				if err != nil {
+					t.Fatalf("failed to parse input: %v", err)
+				}
+				first := JournalToStringFormat(journal, format)
+
+				reparsed, err := ParseTodosSectionFormat(context.Background(), first, format)
+				if err != nil {
+					t.Fatalf("failed to parse serialized journal: %v\n%s", err, first)
+				}
+				if second := JournalToStringFormat(reparsed, format); second != first {
+					t.Errorf("round trip is not idempotent\nfirst:\n%s\nsecond:\n%s", first, second)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,195 @@
+// Package core provides protection of secret custom variables for the todoer application.
+package core
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// SecretMask is shown instead of the value of a secret custom variable
+const SecretMask = "•••"
+
+// ErrSecretVariable is returned by CheckTemplateSecrets for templates using secret variables
+var ErrSecretVariable = errors.New("template uses secret variables")
+
+// MaskSecrets returns a copy of customVars with the values of the variables named
+// in secrets replaced by SecretMask, for showing rendered templates without the
+// secrets. customVars itself is left unchanged.
+func MaskSecrets(customVars map[string]interface{}, secrets []string) map[string]interface{} {
+	if customVars == nil {
+		return nil
+	}
+	masked := make(map[string]interface{}, len(customVars))
+	for name, value := range customVars {
+		if slices.Contains(secrets, name) {
+			value = SecretMask
+		}
+		masked[name] = value
+	}
+	return masked
+}
+
+// CheckTemplateSecrets parses the template content, rendered with funcs, and
+// returns an error wrapping ErrSecretVariable with the line and column of every
+// use of a custom variable named in secrets, such as .Custom.Token or
+// (index .Custom "Token"). Uses of the whole .Custom map, e.g. in range, with or
+// toJson, are reported too, since they expose every variable, as are uses of the
+// whole template data as $ outside of template calls. Syntax errors are returned
+// as reported by text/template.
+//
+// The check cannot see every way a template reaches the data, such as {{.}} at
+// the top level, so renderers also pass the variables through MaskSecrets.
+func CheckTemplateSecrets(content string, secrets []string, funcs template.FuncMap) error {
+	if len(secrets) == 0 {
+		return nil
+	}
+	tmpl, err := template.New("secrets").Funcs(funcs).Parse(content)
+	if err != nil {
+		return err
+	}
+
+	c := &secretChecker{content: content, secrets: secrets}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			c.walk(t.Tree.Root)
+		}
+	}
+	if len(c.uses) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrSecretVariable, strings.Join(c.uses, ", "))
+}
+
+// secretChecker walks a template tree, recording the uses of secret variables.
+type secretChecker struct {
+	content string
+	secrets []string
+	uses    []string // Uses as "line 3, column 5: .Custom.Token"
+}
+
+// walk records the uses of secret variables below node.
+func (c *secretChecker) walk(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			c.walk(child)
+		}
+	case *parse.ActionNode:
+		c.walk(n.Pipe)
+	case *parse.IfNode:
+		c.walk(n.Pipe)
+		c.walk(n.List)
+		c.walk(n.ElseList)
+	case *parse.RangeNode:
+		c.walk(n.Pipe)
+		c.walk(n.List)
+		c.walk(n.ElseList)
+	case *parse.WithNode:
+		c.walk(n.Pipe)
+		c.walk(n.List)
+		c.walk(n.ElseList)
+	case *parse.TemplateNode:
+		// Passing the data on to a defined template exposes only what it uses
+		if !passesData(n.Pipe) {
+			c.walk(n.Pipe)
+		}
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			c.command(cmd)
+		}
+	case *parse.ChainNode:
+		c.walk(n.Node)
+		c.fields(n, n.Field)
+	case *parse.FieldNode:
+		c.fields(n, n.Ident)
+	case *parse.VariableNode:
+		if len(n.Ident) == 1 && n.Ident[0] == "$" {
+			c.record(n, "$")
+			return
+		}
+		c.fields(n, n.Ident)
+	}
+}
+
+// command records the uses of secret variables in the arguments of cmd. Looking
+// up a variable that is not secret with index does not expose the others.
+func (c *secretChecker) command(cmd *parse.CommandNode) {
+	args := cmd.Args
+	if len(args) == 3 {
+		ident, isIndex := args[0].(*parse.IdentifierNode)
+		key, isKey := args[2].(*parse.StringNode)
+		if isIndex && isKey && ident.Ident == "index" && isCustomMap(args[1]) {
+			if slices.Contains(c.secrets, key.Text) {
+				c.record(args[1], ".Custom."+key.Text)
+			}
+			return
+		}
+	}
+	for _, arg := range args {
+		c.walk(arg)
+	}
+}
+
+// fields records a use if the field names idents, looked up from node, are the
+// whole Custom map or a secret variable in it.
+func (c *secretChecker) fields(node parse.Node, idents []string) {
+	i := slices.Index(idents, "Custom")
+	switch {
+	case i < 0:
+	case i == len(idents)-1:
+		c.record(node, ".Custom")
+	case slices.Contains(c.secrets, idents[i+1]):
+		c.record(node, ".Custom."+idents[i+1])
+	}
+}
+
+// record adds the use of name at node.
+func (c *secretChecker) record(node parse.Node, name string) {
+	line, col := nodePosition(c.content, node)
+	c.uses = append(c.uses, fmt.Sprintf("line %d, column %d: %s", line, col, name))
+}
+
+// isCustomMap reports whether node is a field reference ending in Custom, such
+// as .Custom or $.Custom.
+func isCustomMap(node parse.Node) bool {
+	var idents []string
+	switch n := node.(type) {
+	case *parse.FieldNode:
+		idents = n.Ident
+	case *parse.VariableNode:
+		idents = n.Ident
+	}
+	return len(idents) > 0 && idents[len(idents)-1] == "Custom"
+}
+
+// passesData reports whether pipe is just the data, as . or $.
+func passesData(pipe *parse.PipeNode) bool {
+	if pipe == nil || len(pipe.Decl) > 0 || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return false
+	}
+	switch n := pipe.Cmds[0].Args[0].(type) {
+	case *parse.DotNode:
+		return true
+	case *parse.VariableNode:
+		return len(n.Ident) == 1 && n.Ident[0] == "$"
+	}
+	return false
+}
+
+// nodePosition returns the line and column, counting from 1, of node in content.
+func nodePosition(content string, node parse.Node) (int, int) {
+	pos := min(int(node.Position()), len(content))
+	line := 1 + strings.Count(content[:pos], "\n")
+	col := pos - strings.LastIndex(content[:pos], "\n")
+	return line, col
+}
@@ -0,0 +1,62 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckTemplateSecrets(t *testing.T) {
+	secrets := []string{"Token"}
+	tests := []struct {
+		content string
+		want    string // Use reported, empty for none
+	}{
+		{"{{.Custom.Project}} {{index .Custom \"Project\"}}", ""},
+		{"Key: {{.Custom.Token}}", "line 1, column 15: .Custom.Token"},
+		{"\n{{$.Custom.Token | upper}}", "line 2, column 4: .Custom.Token"},
+		{"{{index .Custom \"Token\"}}", "line 1, column 9: .Custom.Token"},
+		{"{{range $k, $v := .Custom}}{{$v}}{{end}}", "line 1, column 19: .Custom"},
+		{"{{define \"x\"}}{{.Custom.Token}}{{end}}{{template \"x\" .}}", ".Custom.Token"},
+		{"{{if .Custom.Token}}set{{end}}", ".Custom.Token"},
+		{"{{printf \"%v\" $}}", "line 1, column 15: $"},
+		{"{{range .TodoDates}}{{$}}{{end}}", "line 1, column 23: $"},
+		{"{{define \"x\"}}{{.Date}}{{end}}{{template \"x\" $}}{{$.Date}}", ""},
+	}
+	for _, tt := range tests {
+		err := CheckTemplateSecrets(tt.content, secrets, CreateTemplateFunctions())
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("CheckTemplateSecrets(%q) error: %v", tt.content, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrSecretVariable) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("CheckTemplateSecrets(%q) error = %v, want %q", tt.content, err, tt.want)
+		}
+	}
+
+	if err := CheckTemplateSecrets("{{.Custom.Token}}", nil, CreateTemplateFunctions()); err != nil {
+		t.Errorf("CheckTemplateSecrets() without secrets error: %v", err)
+	}
+	if err := CheckTemplateSecrets("{{.Custom.Token", secrets, CreateTemplateFunctions()); err == nil || errors.Is(err, ErrSecretVariable) {
+		t.Errorf("CheckTemplateSecrets() of a broken template error = %v, want the syntax error", err)
+	}
+}
+
+func TestMaskSecrets(t *testing.T) {
+	custom := map[string]interface{}{"Token": "s3cr3t", "Project": "todoer"}
+	masked := MaskSecrets(custom, []string{"Token", "Missing"})
+	if want := map[string]interface{}{"Token": SecretMask, "Project": "todoer"}; !reflect.DeepEqual(masked, want) {
+		t.Errorf("MaskSecrets() = %v, want %v", masked, want)
+	}
+	if custom["Token"] != "s3cr3t" {
+		t.Error("MaskSecrets() changed the custom variables")
+	}
+
+	output, err := CreateFromTemplate(TemplateOptions{Content: "Key: {{.Custom.Token}}", CurrentDate: "2025-06-21", CustomVars: masked})
+	if err != nil || output != "Key: •••" {
+		t.Errorf("rendered masked template = %q, %v", output, err)
+	}
+}
@@ -185,3 +185,46 @@ Today's random task order:
 		}
 	})
 }
+
+func TestSeededShuffle(t *testing.T) {
+	const lines = "a\nb\nc\nd\ne\nf\ng\nh"
+	render := func(content, date string, deterministic bool) string {
+		t.Helper()
+		output, err := CreateFromTemplate(TemplateOptions{Content: content, CurrentDate: date, Deterministic: deterministic})
+		if err != nil {
+			t.Fatalf("CreateFromTemplate() error: %v", err)
+		}
+		return output
+	}
+
+	t.Run("shuffleSeeded", func(t *testing.T) {
+		content := `{{shuffleSeeded "` + strings.ReplaceAll(lines, "\n", `\n`) + `"}}`
+		first := render(content, "2025-06-20", false)
+		for i := 0; i < 5; i++ {
+			if got := render(content, "2025-06-20", false); got != first {
+				t.Fatalf("shuffleSeeded gave %q, then %q for the same date", first, got)
+			}
+		}
+		if got := strings.Split(first, "\n"); len(got) != 8 {
+			t.Errorf("shuffleSeeded = %q, want the 8 lines", first)
+		}
+
+		differs := false
+		for _, date := range []string{"2025-06-21", "2025-06-22", "2025-06-23"} {
+			differs = differs || render(content, date, false) != first
+		}
+		if !differs {
+			t.Error("shuffleSeeded gave the same order on every date")
+		}
+	})
+
+	t.Run("deterministic", func(t *testing.T) {
+		content := `{{shuffle "` + strings.ReplaceAll(lines, "\n", `\n`) + `"}}|{{join "," (shuffleLines (split "\n" "` + strings.ReplaceAll(lines, "\n", `\n`) + `"))}}`
+		first := render(content, "2025-06-20", true)
+		for i := 0; i < 5; i++ {
+			if got := render(content, "2025-06-20", true); got != first {
+				t.Fatalf("deterministic rendering gave %q, then %q", first, got)
+			}
+		}
+	})
+}
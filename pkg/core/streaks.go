@@ -0,0 +1,140 @@
+// Package core provides completion streak statistics for the todoer application.
+package core
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// VelocityWeeks is the number of weeks WeeklyVelocity averages over
+const VelocityWeeks = 4
+
+// Completion is a completed todo and the day it was completed on.
+type Completion struct {
+	Date string `json:"date"`           // Completion date in YYYY-MM-DD format
+	Time string `json:"time,omitempty"` // Completion time of day in HH:MM format, empty if not stamped
+	Text string `json:"text"`           // Todo text identifying the completion across journals
+
+	// Date the todo was written down, see CreatedDate; subitems have that of
+	// their top-level todo unless annotated with their own
+	Created string `json:"created,omitempty"`
+}
+
+// StreakStats summarises the days on which todos were completed.
+type StreakStats struct {
+	CurrentStreak  int     // Consecutive days with a completed todo up to today, or yesterday if none yet today
+	LongestStreak  int     // Longest run of consecutive days with a completed todo
+	WeeklyVelocity float64 // Average completed todos per week over the last VelocityWeeks weeks
+	CompletedTodos int     // Number of completed todos
+	ActiveDays     int     // Number of days with a completed todo
+
+	// Completed todos per hour of the day, of those stamped with a time of day.
+	// CalculateStreaks leaves it zero; callers fill it with CompletionHours.
+	CompletionHours [24]int
+
+	// Median days from the creation to the completion of completed todos, over
+	// TrackedTodos todos. CalculateStreaks leaves both zero; callers fill them with
+	// DaysToComplete.
+	MedianDaysToComplete float64
+	TrackedTodos         int
+}
+
+// CollectCompletions returns the completed todos of journal, including nested
+// subitems. A todo counts as completed on the date of its date tag, e.g.
+// "#2025-06-18", at the time of day the tag may carry, e.g. "#2025-06-18T14:32";
+// completed todos without a tag count on undatedDate, or are skipped if
+// undatedDate is empty. undatedDate is also the journal date todos without a
+// created annotation count as created on, see CreatedDates.
+func CollectCompletions(journal *TodoJournal, undatedDate string) []Completion {
+	var completions []Completion
+	if journal == nil {
+		return completions
+	}
+
+	created := CreatedDates(journal, undatedDate)
+	for _, loc := range FindItems(journal, func(item *TodoItem) bool { return item.Completed }) {
+		date, clock := undatedDate, ""
+		if tags := findInProse(completionTagRegex, loc.Item.Text); len(tags) > 0 {
+			date, clock = parseCompletionTag(loc.Item.Text, tags[len(tags)-1])
+		}
+		if date != "" {
+			completions = append(completions, Completion{Date: date, Time: clock, Text: strings.TrimSpace(loc.Item.Text), Created: created[loc.Item]})
+		}
+	}
+	return completions
+}
+
+// CountCompletions returns the number of completed todos per date. Completions
+// with the same date and text are counted once, since completed subitems are
+// carried along with their open parent and appear in several journals.
+func CountCompletions(completions []Completion) map[string]int {
+	seen := make(map[Completion]bool, len(completions))
+	counts := make(map[string]int)
+	for _, c := range completions {
+		if seen[c] {
+			continue
+		}
+		seen[c] = true
+		counts[c.Date]++
+	}
+	return counts
+}
+
+// CalculateStreaks computes streak statistics from the number of completed todos
+// per date, as returned by CountCompletions, as of today. Dates after today and
+// invalid dates are ignored.
+func CalculateStreaks(counts map[string]int, today string) StreakStats {
+	var stats StreakStats
+	todayTime, err := time.Parse(DateFormat, today)
+	if err != nil {
+		return stats
+	}
+
+	var days []time.Time
+	for date, n := range counts {
+		t, err := time.Parse(DateFormat, date)
+		if err != nil || n <= 0 || t.After(todayTime) {
+			continue
+		}
+		days = append(days, t)
+		stats.CompletedTodos += n
+	}
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Before(days[j])
+	})
+	stats.ActiveDays = len(days)
+
+	run := 0
+	for i, day := range days {
+		if i > 0 && days[i-1].AddDate(0, 0, 1).Equal(day) {
+			run++
+		} else {
+			run = 1
+		}
+		if run > stats.LongestStreak {
+			stats.LongestStreak = run
+		}
+	}
+
+	// A streak is not broken until the day is over
+	day := todayTime
+	if counts[today] <= 0 {
+		day = day.AddDate(0, 0, -1)
+	}
+	for counts[day.Format(DateFormat)] > 0 {
+		stats.CurrentStreak++
+		day = day.AddDate(0, 0, -1)
+	}
+
+	since := todayTime.AddDate(0, 0, -7*VelocityWeeks)
+	recent := 0
+	for date, n := range counts {
+		if t, err := time.Parse(DateFormat, date); err == nil && t.After(since) && !t.After(todayTime) && n > 0 {
+			recent += n
+		}
+	}
+	stats.WeeklyVelocity = float64(recent) / VelocityWeeks
+
+	return stats
+}
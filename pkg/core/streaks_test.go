@@ -0,0 +1,105 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCollectCompletions(t *testing.T) {
+	journal, err := ParseTodosSection(`- [[2025-06-18]]
+  - [x] Tagged #2025-06-18
+  - [ ] Open parent
+    - [x] Done subtask #2025-06-19
+    - [ ] Open subtask
+- [[2025-06-20]]
+  - [x] Untagged`)
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error: %v", err)
+	}
+
+	got := CollectCompletions(journal, "2025-06-20")
+	want := []Completion{
+		{Date: "2025-06-18", Text: "Tagged #2025-06-18", Created: "2025-06-18"},
+		{Date: "2025-06-19", Text: "Done subtask #2025-06-19", Created: "2025-06-18"},
+		{Date: "2025-06-20", Text: "Untagged", Created: "2025-06-20"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CollectCompletions() = %+v, want %+v", got, want)
+	}
+
+	if got := CollectCompletions(journal, ""); len(got) != 2 {
+		t.Errorf("CollectCompletions() without undated date = %+v, want the 2 tagged completions", got)
+	}
+	if got := CollectCompletions(nil, "2025-06-20"); len(got) != 0 {
+		t.Errorf("CollectCompletions(nil) = %+v, want none", got)
+	}
+}
+
+func TestCountCompletions(t *testing.T) {
+	got := CountCompletions([]Completion{
+		{Date: "2025-06-19", Text: "Done subtask #2025-06-19"},
+		{Date: "2025-06-19", Text: "Other"},
+		// The same subtask carried along to the next journal
+		{Date: "2025-06-19", Text: "Done subtask #2025-06-19"},
+		{Date: "2025-06-20", Text: "Other"},
+	})
+	want := map[string]int{"2025-06-19": 2, "2025-06-20": 1}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CountCompletions() = %v, want %v", got, want)
+	}
+}
+
+func TestCalculateStreaks(t *testing.T) {
+	counts := map[string]int{
+		"2025-05-01": 3,
+		"2025-05-02": 1,
+		"2025-05-03": 2,
+		"2025-05-04": 1,
+		"2025-06-10": 2,
+		"2025-06-18": 1,
+		"2025-06-19": 4,
+		"2025-06-20": 1,
+		"2025-06-25": 9, // after today
+		"invalid":    5,
+	}
+
+	tests := []struct {
+		name  string
+		today string
+		want  StreakStats
+	}{
+		{
+			name:  "streak including today",
+			today: "2025-06-20",
+			want:  StreakStats{CurrentStreak: 3, LongestStreak: 4, WeeklyVelocity: 2, CompletedTodos: 15, ActiveDays: 8},
+		},
+		{
+			name:  "nothing completed yet today",
+			today: "2025-06-21",
+			want:  StreakStats{CurrentStreak: 3, LongestStreak: 4, WeeklyVelocity: 2, CompletedTodos: 15, ActiveDays: 8},
+		},
+		{
+			name:  "broken streak",
+			today: "2025-06-22",
+			want:  StreakStats{CurrentStreak: 0, LongestStreak: 4, WeeklyVelocity: 2, CompletedTodos: 15, ActiveDays: 8},
+		},
+		{
+			name:  "velocity window",
+			today: "2025-05-04",
+			want:  StreakStats{CurrentStreak: 4, LongestStreak: 4, WeeklyVelocity: 1.75, CompletedTodos: 7, ActiveDays: 4},
+		},
+		{
+			name:  "invalid today",
+			today: "not-a-date",
+			want:  StreakStats{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CalculateStreaks(counts, tt.today); got != tt.want {
+				t.Errorf("CalculateStreaks(%s) = %+v, want %+v", tt.today, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+// Package core provides per-tag todo counts for the todoer application.
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TagRegex matches a tag such as #errand or #work/project-x in a todo text. Date
+// tags match too and are skipped by ExtractTags.
+// Captures: (tag without '#')
+var TagRegex = regexp.MustCompile(`(?:^|\s)#([\p{L}\p{N}_/-]+)`)
+
+// TagCount counts the open and completed todos carrying a tag.
+type TagCount struct {
+	Tag       string // Tag in lower case with its leading '#', e.g. "#errand"
+	Open      int    // Number of open todos carrying the tag
+	Completed int    // Number of completed todos carrying the tag
+}
+
+// Total returns the number of todos carrying the tag.
+func (t TagCount) Total() int {
+	return t.Open + t.Completed
+}
+
+// ExtractTags returns the distinct tags of a todo text in lower case with their
+// leading '#', in the order they first appear. Date tags are not included, nor are
+// tags in code spans, URLs and link targets.
+func ExtractTags(text string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, m := range findInProse(TagRegex, text) {
+		tag := "#" + strings.ToLower(text[m[2]:m[3]])
+		if seen[tag] || taggedDateRegex.MatchString(tag) {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// CountTags counts the open and completed todos per tag across journals, at any
+// depth. A todo carrying several tags counts towards each of them. The result is
+// sorted by tag.
+func CountTags(journals ...*TodoJournal) []TagCount {
+	counts := make(map[string]*TagCount)
+	for _, journal := range journals {
+		for _, loc := range FindItems(journal, func(*TodoItem) bool { return true }) {
+			for _, tag := range ExtractTags(loc.Item.Text) {
+				entry, ok := counts[tag]
+				if !ok {
+					entry = &TagCount{Tag: tag}
+					counts[tag] = entry
+				}
+				if loc.Item.Completed {
+					entry.Completed++
+				} else {
+					entry.Open++
+				}
+			}
+		}
+	}
+
+	result := make([]TagCount, 0, len(counts))
+	for _, entry := range counts {
+		result = append(result, *entry)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Tag < result[j].Tag
+	})
+	return result
+}
+
+// TagTable formats counts as a markdown table with a row per tag and columns for
+// the open, completed and total todos. The columns are padded so the table lines
+// up in plain text too. An empty string is returned when counts is empty.
+func TagTable(counts []TagCount) string {
+	if len(counts) == 0 {
+		return ""
+	}
+	rows := [][]string{{"Tag", "Open", "Closed", "Total"}}
+	for _, c := range counts {
+		rows = append(rows, []string{c.Tag, strconv.Itoa(c.Open), strconv.Itoa(c.Completed), strconv.Itoa(c.Total())})
+	}
+
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], DisplayWidth(cell), 3)
+		}
+	}
+
+	var b strings.Builder
+	line := func(cells []string) {
+		for i, cell := range cells {
+			if i == 0 {
+				cell = PadRight(widths[i], cell)
+			} else {
+				cell = strings.Repeat(" ", widths[i]-DisplayWidth(cell)) + cell
+			}
+			fmt.Fprintf(&b, "| %s ", cell)
+		}
+		b.WriteString("|\n")
+	}
+	line(rows[0])
+	separator := []string{strings.Repeat("-", widths[0])}
+	for _, w := range widths[1:] {
+		separator = append(separator, strings.Repeat("-", w-1)+":")
+	}
+	line(separator)
+	for _, row := range rows[1:] {
+		line(row)
+	}
+	return b.String()
+}
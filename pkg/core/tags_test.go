@@ -0,0 +1,102 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractTags(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Buy milk #errand", []string{"#errand"}},
+		{"#Work review #work #home", []string{"#work", "#home"}},
+		{"Ship #work/project-x", []string{"#work/project-x"}},
+		{"Done task #2025-06-20", nil},
+		{"Urgent [#A] task", nil},
+		{"Issue a#b and #", nil},
+		{"Café #café", []string{"#café"}},
+	}
+	for _, tt := range tests {
+		if got := ExtractTags(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ExtractTags(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestCountTags(t *testing.T) {
+	first, err := ParseTodosSection(`- [[2025-06-20]]
+  - [x] Buy milk #errand #2025-06-20
+  - [ ] Post letter #errand
+    - [ ] Find stamps #errand #home
+  - [ ] Untagged task`)
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error: %v", err)
+	}
+	second, err := ParseTodosSection(`- [[2025-06-21]]
+  - [x] Review PR #Work`)
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error: %v", err)
+	}
+
+	got := CountTags(first, nil, second)
+	want := []TagCount{
+		{Tag: "#errand", Open: 2, Completed: 1},
+		{Tag: "#home", Open: 1},
+		{Tag: "#work", Completed: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CountTags() = %+v, want %+v", got, want)
+	}
+	if got[0].Total() != 3 {
+		t.Errorf("Total() = %d, want 3", got[0].Total())
+	}
+}
+
+func TestTagTable(t *testing.T) {
+	if got := TagTable(nil); got != "" {
+		t.Errorf("TagTable(nil) = %q, want empty", got)
+	}
+
+	got := TagTable([]TagCount{
+		{Tag: "#errand", Open: 2, Completed: 1},
+		{Tag: "#日本", Open: 12},
+	})
+	want := "| Tag     | Open | Closed | Total |\n" +
+		"| ------- | ---: | -----: | ----: |\n" +
+		"| #errand |    2 |      1 |     3 |\n" +
+		"| #日本   |   12 |      0 |    12 |\n"
+	if got != want {
+		t.Errorf("TagTable() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestStatsTableTemplateFunction(t *testing.T) {
+	journal, err := ParseTodosSection(`- [[2025-06-20]]
+  - [x] Buy milk #errand
+  - [ ] Post letter #errand`)
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error: %v", err)
+	}
+
+	output, err := CreateFromTemplate(TemplateOptions{
+		Content:     "{{statsTable}}",
+		CurrentDate: "2025-06-21",
+		Journal:     journal,
+	})
+	if err != nil {
+		t.Fatalf("CreateFromTemplate() error: %v", err)
+	}
+	if want := TagTable(CountTags(journal)); output != want {
+		t.Errorf("output = %q, want %q", output, want)
+	}
+
+	output, err = CreateFromTemplate(TemplateOptions{Content: "[{{statsTable}}]", CurrentDate: "2025-06-21"})
+	if err != nil {
+		t.Fatalf("CreateFromTemplate() error: %v", err)
+	}
+	if output != "[]" {
+		t.Errorf("output without journal = %q, want %q", output, "[]")
+	}
+}
@@ -0,0 +1,180 @@
+// Package core provides matching of tasks by their text for the todoer application.
+package core
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// MatchKind tells how the text of a task matched a pattern, from the loosest to
+// the closest match.
+type MatchKind int
+
+// Kinds of task matches
+const (
+	MatchFuzzy    MatchKind = iota // The words of the text resemble those of the pattern
+	MatchContains                  // The normalized text contains the normalized pattern
+	MatchEqual                     // The normalized text equals the normalized pattern
+)
+
+// FuzzyThreshold is the score a task needs to match a pattern fuzzily
+const FuzzyThreshold = 0.8
+
+// TaskMatch is a task found by MatchTasks.
+type TaskMatch struct {
+	ItemLocation
+	Kind  MatchKind // How the text matched
+	Score float64   // How close the match is, from 0 to 1
+}
+
+// NormalizeText returns text reduced for matching: lower case, without emoji and
+// other symbols, and with runs of white space collapsed into single spaces.
+func NormalizeText(text string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsSpace(r):
+			space = b.Len() > 0
+			continue
+		case unicode.In(r, unicode.So, unicode.Sk, unicode.Cf, unicode.Variation_Selector):
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// MatchExact returns a matcher for FindItems that selects items whose text is
+// text, apart from surrounding white space. If openOnly is true, completed items
+// never match.
+func MatchExact(text string, openOnly bool) func(*TodoItem) bool {
+	text = strings.TrimSpace(text)
+	return func(item *TodoItem) bool {
+		if openOnly && item.Completed {
+			return false
+		}
+		return strings.TrimSpace(item.Text) == text
+	}
+}
+
+// MatchTaskID returns a matcher for FindItems that selects items annotated with
+// id::id. If openOnly is true, completed items never match.
+func MatchTaskID(id string, openOnly bool) func(*TodoItem) bool {
+	return func(item *TodoItem) bool {
+		if openOnly && item.Completed {
+			return false
+		}
+		return id != "" && ExtractTaskID(item.Text) == id
+	}
+}
+
+// MatchTasks returns the items of journal matching pattern, closest first. Only
+// the closest kind of match found is returned: the items whose normalized text
+// equals the normalized pattern if there are any, else those containing it, else
+// those whose words resemble the words of the pattern, allowing for typos, with
+// a score of at least FuzzyThreshold. If openOnly is true, completed items never
+// match.
+func MatchTasks(journal *TodoJournal, pattern string, openOnly bool) []TaskMatch {
+	needle := NormalizeText(pattern)
+	if needle == "" {
+		return nil
+	}
+	words := matchWords(needle)
+
+	byKind := make(map[MatchKind][]TaskMatch)
+	for _, loc := range FindItems(journal, func(item *TodoItem) bool { return !openOnly || !item.Completed }) {
+		text := NormalizeText(loc.Item.Text)
+		switch {
+		case text == needle:
+			byKind[MatchEqual] = append(byKind[MatchEqual], TaskMatch{ItemLocation: loc, Kind: MatchEqual, Score: 1})
+		case strings.Contains(text, needle):
+			score := float64(len(needle)) / float64(len(text))
+			byKind[MatchContains] = append(byKind[MatchContains], TaskMatch{ItemLocation: loc, Kind: MatchContains, Score: score})
+		default:
+			if score := fuzzyScore(words, matchWords(text)); score >= FuzzyThreshold {
+				byKind[MatchFuzzy] = append(byKind[MatchFuzzy], TaskMatch{ItemLocation: loc, Kind: MatchFuzzy, Score: score})
+			}
+		}
+	}
+
+	for _, kind := range []MatchKind{MatchEqual, MatchContains, MatchFuzzy} {
+		if matches := byKind[kind]; len(matches) > 0 {
+			sort.SliceStable(matches, func(i, j int) bool {
+				return matches[i].Score > matches[j].Score
+			})
+			return matches
+		}
+	}
+	return nil
+}
+
+// matchWords splits normalized text into its words of letters and digits.
+func matchWords(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// fuzzyScore returns how well the words of a text cover the words of a pattern:
+// the mean similarity of every pattern word to its most similar text word.
+func fuzzyScore(pattern, text []string) float64 {
+	if len(pattern) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, p := range pattern {
+		best := 0.0
+		for _, t := range text {
+			best = max(best, wordSimilarity(p, t))
+		}
+		total += best
+	}
+	return total / float64(len(pattern))
+}
+
+// wordSimilarity returns the similarity of the pattern word p to the text word t,
+// from 0 to 1: 1 if they are equal, 0.9 if t starts with a p of three or more
+// letters, and otherwise one minus their typo distance relative to the longer word.
+func wordSimilarity(p, t string) float64 {
+	if p == t {
+		return 1
+	}
+	pr, tr := []rune(p), []rune(t)
+	if len(pr) >= 3 && strings.HasPrefix(t, p) {
+		return 0.9
+	}
+	return 1 - float64(typoDistance(pr, tr))/float64(max(len(pr), len(tr)))
+}
+
+// typoDistance returns the number of insertions, deletions, substitutions and
+// transpositions of adjacent runes turning a into b. Unlike editDistance, a
+// swapped pair of letters counts as one typo.
+func typoDistance(a, b []rune) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
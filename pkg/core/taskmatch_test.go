@@ -0,0 +1,126 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Write Report", "write report"},
+		{"  Write \t report  ", "write report"},
+		{"🚀 Launch  rocket ✅", "launch rocket"},
+		{"Call ☎️ Alice", "call alice"},
+		{"Team 👩‍💻 sync", "team sync"},
+		{"Ünïcödé Straße", "ünïcödé straße"},
+		{"Pay rent due::[[2025-06-18]] #home", "pay rent due::[[2025-06-18]] #home"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeText(tt.text); got != tt.want {
+			t.Errorf("NormalizeText(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestMatchTasks(t *testing.T) {
+	journal, err := ParseTodosSection(`- [[2025-06-20]]
+  - [ ] 🚀 Write  report
+    - [ ] Collect numbers for the report
+  - [ ] Write report draft
+  - [ ] Review PR #42
+  - [ ] Renew passport id::passport
+  - [x] Send invoice #2025-06-20`)
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		pattern  string
+		openOnly bool
+		want     []string
+		kind     MatchKind
+	}{
+		{"equal wins over containing", "write REPORT", true, []string{"🚀 Write  report"}, MatchEqual},
+		{"containing, tightest first", "report", true, []string{"🚀 Write  report", "Write report draft", "Collect numbers for the report"}, MatchContains},
+		{"emoji and spaces ignored", "🚀write   report draft", true, []string{"Write report draft"}, MatchEqual},
+		{"typo", "reveiw pr", true, []string{"Review PR #42"}, MatchFuzzy},
+		{"word prefix", "renew pass", true, []string{"Renew passport id::passport"}, MatchContains},
+		{"fuzzy words in any order", "pasport renew", true, []string{"Renew passport id::passport"}, MatchFuzzy},
+		{"completed skipped", "send invoice", true, nil, 0},
+		{"completed included", "send invoice", false, []string{"Send invoice #2025-06-20"}, MatchContains},
+		{"no match", "water plants", true, nil, 0},
+		{"short words need to be close", "done", true, nil, 0},
+		{"empty pattern", " 🚀 ", true, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := MatchTasks(journal, tt.pattern, tt.openOnly)
+			var texts []string
+			for _, m := range matches {
+				texts = append(texts, m.Item.Text)
+				if m.Kind != tt.kind {
+					t.Errorf("match %q kind = %v, want %v", m.Item.Text, m.Kind, tt.kind)
+				}
+				if m.Score <= 0 || m.Score > 1 {
+					t.Errorf("match %q score = %v, want (0, 1]", m.Item.Text, m.Score)
+				}
+			}
+			if !reflect.DeepEqual(texts, tt.want) {
+				t.Errorf("MatchTasks(%q) = %q, want %q", tt.pattern, texts, tt.want)
+			}
+		})
+	}
+}
+
+func TestTaskMatchers(t *testing.T) {
+	open := &TodoItem{Text: "Renew passport id::passport "}
+	done := &TodoItem{Text: "Send invoice id::invoice", Completed: true}
+
+	tests := []struct {
+		name  string
+		match func(*TodoItem) bool
+		item  *TodoItem
+		want  bool
+	}{
+		{"exact", MatchExact(" Renew passport id::passport", true), open, true},
+		{"exact is case-sensitive", MatchExact("renew passport id::passport", true), open, false},
+		{"exact needs the whole text", MatchExact("Renew passport", true), open, false},
+		{"exact completed", MatchExact("Send invoice id::invoice", true), done, false},
+		{"exact completed allowed", MatchExact("Send invoice id::invoice", false), done, true},
+		{"id", MatchTaskID("passport", true), open, true},
+		{"other id", MatchTaskID("pass", true), open, false},
+		{"empty id", MatchTaskID("", false), &TodoItem{Text: "No id"}, false},
+		{"id completed", MatchTaskID("invoice", true), done, false},
+		{"id completed allowed", MatchTaskID("invoice", false), done, true},
+	}
+	for _, tt := range tests {
+		if got := tt.match(tt.item); got != tt.want {
+			t.Errorf("%s: matcher returned %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTypoDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"report", "report", 0},
+		{"report", "reprot", 1},
+		{"report", "repor", 1},
+		{"report", "rapport", 2},
+		{"", "abc", 3},
+		{"straße", "strasse", 2},
+	}
+	for _, tt := range tests {
+		if got := typoDistance([]rune(tt.a), []rune(tt.b)); got != tt.want {
+			t.Errorf("typoDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,505 @@
+// Package core provides Sprig-compatible template functions for the todoer application.
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// createSprigFunctions returns a curated, side-effect free subset of the Sprig
+// template function library (github.com/Masterminds/sprig), with Sprig's names and
+// argument order, so templates written for Sprig work unchanged. Functions reading
+// the environment, the network or the file system are left out. Sprig functions
+// todoer already defines, such as default, join or title, are not part of the subset;
+// see TemplateFunctions for how names are resolved.
+func createSprigFunctions() template.FuncMap {
+	return template.FuncMap{
+		// Dates
+		"now": time.Now,
+		"date": func(layout string, date interface{}) (string, error) {
+			t, err := sprigTime(date)
+			if err != nil {
+				return "", err
+			}
+			return t.Format(layout), nil
+		},
+		"toDate": func(layout, value string) (time.Time, error) {
+			return time.ParseInLocation(layout, value, time.Local)
+		},
+		"dateModify": func(modification string, date time.Time) (time.Time, error) {
+			d, err := time.ParseDuration(modification)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return date.Add(d), nil
+		},
+		"unixEpoch": func(date time.Time) string {
+			return strconv.FormatInt(date.Unix(), 10)
+		},
+
+		// Strings
+		"trimPrefix": func(prefix, s string) string {
+			return strings.TrimPrefix(s, prefix)
+		},
+		"trimSuffix": func(suffix, s string) string {
+			return strings.TrimSuffix(s, suffix)
+		},
+		"trimAll": func(cutset, s string) string {
+			return strings.Trim(s, cutset)
+		},
+		"nospace": func(s string) string {
+			return strings.Join(strings.Fields(s), "")
+		},
+		"trunc": func(length int, s string) string {
+			if length < 0 && len(s)+length > 0 {
+				return s[len(s)+length:]
+			}
+			if length >= 0 && len(s) > length {
+				return s[:length]
+			}
+			return s
+		},
+		"abbrev": func(width int, s string) string {
+			if width < 4 || len(s) <= width {
+				return s
+			}
+			return s[:width-3] + "..."
+		},
+		"substr": func(start, end int, s string) string {
+			if start < 0 {
+				start = 0
+			}
+			if end < 0 || end > len(s) {
+				end = len(s)
+			}
+			if start > end {
+				return ""
+			}
+			return s[start:end]
+		},
+		"quote": func(values ...interface{}) string {
+			quoted := make([]string, 0, len(values))
+			for _, v := range values {
+				if v != nil {
+					quoted = append(quoted, strconv.Quote(sprigString(v)))
+				}
+			}
+			return strings.Join(quoted, " ")
+		},
+		"squote": func(values ...interface{}) string {
+			quoted := make([]string, 0, len(values))
+			for _, v := range values {
+				if v != nil {
+					quoted = append(quoted, "'"+sprigString(v)+"'")
+				}
+			}
+			return strings.Join(quoted, " ")
+		},
+		"cat": func(values ...interface{}) string {
+			parts := make([]string, 0, len(values))
+			for _, v := range values {
+				if v != nil {
+					parts = append(parts, sprigString(v))
+				}
+			}
+			return strings.Join(parts, " ")
+		},
+		"indent": sprigIndent,
+		"nindent": func(spaces int, s string) string {
+			return "\n" + sprigIndent(spaces, s)
+		},
+		"plural": func(one, many string, count int) string {
+			if count == 1 {
+				return one
+			}
+			return many
+		},
+		"splitList": func(sep, s string) []string {
+			return strings.Split(s, sep)
+		},
+		"regexMatch": func(expr, s string) (bool, error) {
+			return regexp.MatchString(expr, s)
+		},
+		"regexFind": func(expr, s string) (string, error) {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return "", err
+			}
+			return re.FindString(s), nil
+		},
+		"regexReplaceAll": func(expr, s, repl string) (string, error) {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return "", err
+			}
+			return re.ReplaceAllString(s, repl), nil
+		},
+
+		// Defaults and conversions
+		"ternary": func(whenTrue, whenFalse interface{}, condition bool) interface{} {
+			if condition {
+				return whenTrue
+			}
+			return whenFalse
+		},
+		"coalesce": func(values ...interface{}) interface{} {
+			for _, v := range values {
+				if !sprigEmpty(v) {
+					return v
+				}
+			}
+			return nil
+		},
+		"toString": sprigString,
+		"atoi": func(s string) int {
+			i, _ := strconv.Atoi(s)
+			return i
+		},
+		"int": func(v interface{}) int {
+			return int(sprigFloat(v))
+		},
+		"float64": sprigFloat,
+		"toJson": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+		"toPrettyJson": func(v interface{}) (string, error) {
+			b, err := json.MarshalIndent(v, "", "  ")
+			return string(b), err
+		},
+		"fromJson": func(s string) (interface{}, error) {
+			var v interface{}
+			err := json.Unmarshal([]byte(s), &v)
+			return v, err
+		},
+
+		// Lists
+		"list": func(values ...interface{}) []interface{} {
+			return values
+		},
+		"first": func(list interface{}) (interface{}, error) {
+			l, err := sprigList(list)
+			if err != nil || len(l) == 0 {
+				return nil, err
+			}
+			return l[0], nil
+		},
+		"last": func(list interface{}) (interface{}, error) {
+			l, err := sprigList(list)
+			if err != nil || len(l) == 0 {
+				return nil, err
+			}
+			return l[len(l)-1], nil
+		},
+		"rest": func(list interface{}) ([]interface{}, error) {
+			l, err := sprigList(list)
+			if err != nil || len(l) == 0 {
+				return nil, err
+			}
+			return l[1:], nil
+		},
+		"initial": func(list interface{}) ([]interface{}, error) {
+			l, err := sprigList(list)
+			if err != nil || len(l) == 0 {
+				return nil, err
+			}
+			return l[:len(l)-1], nil
+		},
+		"append": func(list interface{}, v interface{}) ([]interface{}, error) {
+			l, err := sprigList(list)
+			if err != nil {
+				return nil, err
+			}
+			return append(l, v), nil
+		},
+		"prepend": func(list interface{}, v interface{}) ([]interface{}, error) {
+			l, err := sprigList(list)
+			if err != nil {
+				return nil, err
+			}
+			return append([]interface{}{v}, l...), nil
+		},
+		"concat": func(lists ...interface{}) ([]interface{}, error) {
+			var result []interface{}
+			for _, list := range lists {
+				l, err := sprigList(list)
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, l...)
+			}
+			return result, nil
+		},
+		"reverse": func(list interface{}) ([]interface{}, error) {
+			l, err := sprigList(list)
+			if err != nil {
+				return nil, err
+			}
+			reversed := make([]interface{}, len(l))
+			for i, v := range l {
+				reversed[len(l)-1-i] = v
+			}
+			return reversed, nil
+		},
+		"uniq": func(list interface{}) ([]interface{}, error) {
+			l, err := sprigList(list)
+			if err != nil {
+				return nil, err
+			}
+			var result []interface{}
+			for _, v := range l {
+				if !sprigContains(result, v) {
+					result = append(result, v)
+				}
+			}
+			return result, nil
+		},
+		"compact": func(list interface{}) ([]interface{}, error) {
+			l, err := sprigList(list)
+			if err != nil {
+				return nil, err
+			}
+			var result []interface{}
+			for _, v := range l {
+				if !sprigEmpty(v) {
+					result = append(result, v)
+				}
+			}
+			return result, nil
+		},
+		"without": func(list interface{}, omit ...interface{}) ([]interface{}, error) {
+			l, err := sprigList(list)
+			if err != nil {
+				return nil, err
+			}
+			var result []interface{}
+			for _, v := range l {
+				if !sprigContains(omit, v) {
+					result = append(result, v)
+				}
+			}
+			return result, nil
+		},
+		"has": func(needle interface{}, haystack interface{}) (bool, error) {
+			l, err := sprigList(haystack)
+			if err != nil {
+				return false, err
+			}
+			return sprigContains(l, needle), nil
+		},
+		"sortAlpha": func(list interface{}) ([]string, error) {
+			l, err := sprigList(list)
+			if err != nil {
+				return nil, err
+			}
+			sorted := make([]string, len(l))
+			for i, v := range l {
+				sorted[i] = sprigString(v)
+			}
+			sort.Strings(sorted)
+			return sorted, nil
+		},
+
+		// Dictionaries
+		"get": func(d map[string]interface{}, key string) interface{} {
+			if v, ok := d[key]; ok {
+				return v
+			}
+			return ""
+		},
+		"set": func(d map[string]interface{}, key string, value interface{}) map[string]interface{} {
+			d[key] = value
+			return d
+		},
+		"unset": func(d map[string]interface{}, key string) map[string]interface{} {
+			delete(d, key)
+			return d
+		},
+		"hasKey": func(d map[string]interface{}, key string) bool {
+			_, ok := d[key]
+			return ok
+		},
+		"keys": func(dicts ...map[string]interface{}) []string {
+			var keys []string
+			for _, d := range dicts {
+				for key := range d {
+					keys = append(keys, key)
+				}
+			}
+			// Sorted, unlike Sprig, so rendered journals do not change between runs
+			sort.Strings(keys)
+			return keys
+		},
+
+		// Arithmetic
+		"add1": func(v interface{}) int64 {
+			return int64(sprigFloat(v)) + 1
+		},
+		"mod": func(a, b interface{}) int64 {
+			if d := int64(sprigFloat(b)); d != 0 {
+				return int64(sprigFloat(a)) % d
+			}
+			return 0 // Prevent division by zero
+		},
+		"max": func(a interface{}, values ...interface{}) int64 {
+			result := int64(sprigFloat(a))
+			for _, v := range values {
+				result = max(result, int64(sprigFloat(v)))
+			}
+			return result
+		},
+		"min": func(a interface{}, values ...interface{}) int64 {
+			result := int64(sprigFloat(a))
+			for _, v := range values {
+				result = min(result, int64(sprigFloat(v)))
+			}
+			return result
+		},
+
+		// Encoding
+		"b64enc": func(s string) string {
+			return base64.StdEncoding.EncodeToString([]byte(s))
+		},
+		"b64dec": func(s string) (string, error) {
+			b, err := base64.StdEncoding.DecodeString(s)
+			return string(b), err
+		},
+		"sha256sum": func(s string) string {
+			sum := sha256.Sum256([]byte(s))
+			return hex.EncodeToString(sum[:])
+		},
+	}
+}
+
+// TemplateFunctions returns the functions of CreateTemplateFunctions and, if sprig
+// is set, the Sprig-compatible functions of the todoer subset of Sprig. A Sprig
+// function never replaces a todoer function of the same name, so templates render
+// the same whether or not the Sprig functions are enabled.
+func TemplateFunctions(sprig bool) template.FuncMap {
+	result := CreateTemplateFunctions()
+	if !sprig {
+		return result
+	}
+	for name, fn := range createSprigFunctions() {
+		if _, ok := result[name]; !ok {
+			result[name] = fn
+		}
+	}
+	return result
+}
+
+// sprigTime converts the date argument of the date function to a time.
+func sprigTime(date interface{}) (time.Time, error) {
+	switch d := date.(type) {
+	case time.Time:
+		return d, nil
+	case *time.Time:
+		if d != nil {
+			return *d, nil
+		}
+	case int64:
+		return time.Unix(d, 0), nil
+	case int:
+		return time.Unix(int64(d), 0), nil
+	case string:
+		// The YYYY-MM-DD dates of the template data
+		return time.ParseInLocation(DateFormat, d, time.Local)
+	}
+	return time.Time{}, fmt.Errorf("date: unsupported value %v of type %T", date, date)
+}
+
+// sprigString converts v to text the way Sprig's toString does.
+func sprigString(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	case error:
+		return s.Error()
+	case fmt.Stringer:
+		return s.String()
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// sprigFloat converts a number, or text holding one, to a float64, returning 0 for
+// anything else.
+func sprigFloat(v interface{}) float64 {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.Bool:
+		if rv.Bool() {
+			return 1
+		}
+	case reflect.String:
+		f, _ := strconv.ParseFloat(strings.TrimSpace(rv.String()), 64)
+		return f
+	}
+	return 0
+}
+
+// sprigList converts a slice or array of any element type to a list. nil is
+// the empty list.
+func sprigList(list interface{}) ([]interface{}, error) {
+	if list == nil {
+		return nil, nil
+	}
+	rv := reflect.ValueOf(list)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("cannot use %T as a list", list)
+	}
+	result := make([]interface{}, rv.Len())
+	for i := range result {
+		result[i] = rv.Index(i).Interface()
+	}
+	return result, nil
+}
+
+// sprigContains reports whether list holds a value deeply equal to v.
+func sprigContains(list []interface{}, v interface{}) bool {
+	for _, item := range list {
+		if reflect.DeepEqual(item, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// sprigEmpty reports whether v is nil or the zero value of its type, or an empty
+// slice, map or string, as Sprig's empty does.
+func sprigEmpty(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return true
+	}
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return rv.IsNil()
+	}
+	return rv.IsZero()
+}
+
+// sprigIndent indents every line of s by spaces spaces.
+func sprigIndent(spaces int, s string) string {
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
@@ -49,5 +49,10 @@ func createStringFunctions() template.FuncMap {
 		"join": func(sep string, strs []string) string {
 			return strings.Join(strs, sep)
 		},
+
+		// Column formatting, by display width rather than bytes
+		"padRight": PadRight,
+		"truncate": Truncate,
+		"wrap":     Wrap,
 	}
 }
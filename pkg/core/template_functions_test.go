@@ -1,9 +1,14 @@
 package core
 
 import (
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"text/template"
+
+	"github.com/inful/todoer/pkg/storage"
 )
 
 func TestTemplateFunctions(t *testing.T) {
@@ -629,3 +634,126 @@ Today is {{$today}}. {{if isWeekend .Date}}Enjoy your weekend!{{else}}Have a pro
 		}
 	}
 }
+
+func TestSprigFunctions(t *testing.T) {
+	funcMap := TemplateFunctions(true)
+	data := TemplateData{Date: "2025-01-15", TodoDates: []string{"2025-01-14", "2025-01-10"}, TotalTodos: 2}
+
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{"date from YYYY-MM-DD", `{{date "Jan 2, 2006" .Date}}`, "Jan 15, 2025"},
+		{"toDate and dateModify", `{{toDate "2006-01-02" .Date | dateModify "-24h" | date "2006-01-02"}}`, "2025-01-14"},
+		{"trimPrefix", `{{trimPrefix "2025-" .Date}}`, "01-15"},
+		{"trunc negative", `{{trunc -2 .Date}}`, "15"},
+		{"abbrev", `{{abbrev 7 "Wednesday"}}`, "Wedn..."},
+		{"quote", `{{quote "a" "b"}}`, `"a" "b"`},
+		{"indent", `{{indent 2 "a\nb"}}`, "  a\n  b"},
+		{"plural", `{{plural "todo" "todos" .TotalTodos}}`, "todos"},
+		{"regexReplaceAll", `{{regexReplaceAll "-" .Date "/"}}`, "2025/01/15"},
+		{"ternary", `{{ternary "yes" "no" (eq .TotalTodos 2)}}`, "yes"},
+		{"coalesce", `{{coalesce .OldestTodoDate "" "none"}}`, "none"},
+		{"toJson", `{{toJson .TodoDates}}`, `["2025-01-14","2025-01-10"]`},
+		{"fromJson", `{{(fromJson "{\"n\": 3}").n}}`, "3"},
+		{"list functions", `{{first .TodoDates}} {{last .TodoDates}} {{rest (list 1 2 3)}}`, "2025-01-14 2025-01-10 [2 3]"},
+		{"uniq and sortAlpha", `{{sortAlpha (uniq (list "b" "a" "b"))}}`, "[a b]"},
+		{"has", `{{has "2025-01-10" .TodoDates}}`, "true"},
+		{"keys are sorted", `{{keys (dict "b" 1 "a" 2)}}`, "[a b]"},
+		{"hasKey and get", `{{$d := dict "a" 1}}{{hasKey $d "a"}} {{get $d "a"}} [{{get $d "b"}}]`, "true 1 []"},
+		{"max and mod", `{{max 1 .TotalTodos 3}} {{mod 7 0}}`, "3 0"},
+		{"b64", `{{b64enc "todoer" | b64dec}}`, "todoer"},
+		{"sha256sum", `{{sha256sum "todoer" | trunc 8}}`, "774e41c7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := template.New("test").Funcs(funcMap).Parse(tt.template)
+			if err != nil {
+				t.Fatalf("Failed to parse template: %v", err)
+			}
+			var result strings.Builder
+			if err := tmpl.Execute(&result, data); err != nil {
+				t.Fatalf("Failed to execute template: %v", err)
+			}
+			if result.String() != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result.String())
+			}
+		})
+	}
+
+	t.Run("todoer functions win name collisions", func(t *testing.T) {
+		todoer := CreateTemplateFunctions()
+		for name, fn := range todoer {
+			if reflect.ValueOf(funcMap[name]).Pointer() != reflect.ValueOf(fn).Pointer() {
+				t.Errorf("Sprig function replaced todoer function %s", name)
+			}
+		}
+		if len(funcMap) != len(todoer)+len(createSprigFunctions()) {
+			t.Errorf("Sprig subset shares names with todoer functions")
+		}
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		if _, ok := TemplateFunctions(false)["toJson"]; ok {
+			t.Error("TemplateFunctions(false) includes Sprig functions")
+		}
+		if _, err := CreateFromTemplate(TemplateOptions{Content: "{{toJson .Date}}", CurrentDate: "2025-01-15"}); err == nil {
+			t.Error("template using Sprig functions rendered without Sprig enabled")
+		}
+		got, err := CreateFromTemplate(TemplateOptions{Content: "{{toJson .Date}}", CurrentDate: "2025-01-15", Sprig: true})
+		if err != nil || got != `"2025-01-15"` {
+			t.Errorf("CreateFromTemplate() with Sprig = %q, %v", got, err)
+		}
+	})
+
+	t.Run("invalid arguments fail", func(t *testing.T) {
+		for _, text := range []string{`{{regexMatch "[" .Date}}`, `{{first .Date}}`, `{{b64dec "!"}}`, `{{date "2006" true}}`} {
+			tmpl := template.Must(template.New("test").Funcs(funcMap).Parse(text))
+			if err := tmpl.Execute(&strings.Builder{}, data); err == nil {
+				t.Errorf("%s did not fail", text)
+			}
+		}
+	})
+}
+
+func TestNoteField(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "projects"), 0755); err != nil {
+		t.Fatalf("MkdirAll() error: %v", err)
+	}
+	note := "---\ntitle: Project X\nstatus: \"in progress\"\n---\n\n# Project X\n"
+	if err := os.WriteFile(filepath.Join(dir, "projects", "ProjectX.md"), []byte(note), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	notes := storage.NewLocal(dir)
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{"field", `{{noteField "projects/ProjectX.md" "status"}}`, "in progress", false},
+		{"missing field", `{{noteField "projects/ProjectX.md" "owner" | default "none"}}`, "none", false},
+		{"missing note", `[{{noteField "projects/Other.md" "status"}}]`, "[]", false},
+		{"parent directory", `{{noteField "../secret.md" "status"}}`, "", true},
+		{"absolute path", `{{noteField "/etc/passwd" "root"}}`, "", true},
+		{"backslashes", `{{noteField "projects\\..\\..\\secret.md" "status"}}`, "", true},
+	}
+	for _, tt := range tests {
+		got, err := CreateFromTemplate(TemplateOptions{Content: tt.template, CurrentDate: "2025-06-21", Notes: notes})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: CreateFromTemplate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("%s: CreateFromTemplate() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if got, err := CreateFromTemplate(TemplateOptions{Content: `[{{noteField "projects/ProjectX.md" "status"}}]`, CurrentDate: "2025-06-21"}); err != nil || got != "[]" {
+		t.Errorf("noteField without notes = %q, %v; want it empty", got, err)
+	}
+}
@@ -2,10 +2,16 @@
 package core
 
 import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
 	"math/rand"
 	"strings"
 	"text/template"
 	"time"
+
+	"github.com/inful/todoer/pkg/storage"
 )
 
 // createUtilityFunctions returns a map of utility template functions.
@@ -82,52 +88,22 @@ func createUtilityFunctions() template.FuncMap {
 
 		// Shuffling functions
 		"shuffle": func(text string) string {
-			// Split the text into lines, filter out empty lines
-			lines := strings.Split(strings.TrimSpace(text), "\n")
-			var nonEmptyLines []string
-			for _, line := range lines {
-				if trimmed := strings.TrimSpace(line); trimmed != "" {
-					nonEmptyLines = append(nonEmptyLines, line)
-				}
-			}
-
-			// If we have no lines or only one line, return as-is
-			if len(nonEmptyLines) <= 1 {
-				return text
-			}
-
-			// Create a copy for shuffling
-			shuffled := make([]string, len(nonEmptyLines))
-			copy(shuffled, nonEmptyLines)
-
-			// Shuffle using Fisher-Yates algorithm
-			r := rand.New(rand.NewSource(time.Now().UnixNano()))
-			for i := len(shuffled) - 1; i > 0; i-- {
-				j := r.Intn(i + 1)
-				shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
-			}
-
-			return strings.Join(shuffled, "\n")
+			return shuffleText(text, rand.New(rand.NewSource(time.Now().UnixNano())))
 		},
 		"shuffleLines": func(lines []string) []string {
-			// Create a copy for shuffling
-			if len(lines) <= 1 {
-				return lines
-			}
-
-			shuffled := make([]string, len(lines))
-			copy(shuffled, lines)
-
-			// Shuffle using Fisher-Yates algorithm
-			r := rand.New(rand.NewSource(time.Now().UnixNano()))
-			for i := len(shuffled) - 1; i > 0; i-- {
-				j := r.Intn(i + 1)
-				shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
-			}
+			return shuffleList(lines, rand.New(rand.NewSource(time.Now().UnixNano())))
+		},
+		"shuffleSeeded": seededFunctions("", false)["shuffleSeeded"],
 
-			return shuffled
+		// Journal statistics
+		"statsTable": journalFunctions(nil)["statsTable"],
+		"sortTodos": func(todos []NextAction, keys string) (NextActionList, error) {
+			return SortNextActions(todos, keys)
 		},
 
+		// Other notes
+		"noteField": noteFunctions(nil)["noteField"],
+
 		// Arithmetic functions
 		"add": func(a, b int) int {
 			return a + b
@@ -146,3 +122,112 @@ func createUtilityFunctions() template.FuncMap {
 		},
 	}
 }
+
+// seededFunctions returns the shuffling functions that depend on the template date.
+// shuffleSeeded shuffles the lines of a text in an order fixed by date, the same in
+// every call. With deterministic, shuffle and shuffleLines draw from a random source
+// seeded with date too, so a template renders the same way every time for a date.
+func seededFunctions(date string, deterministic bool) template.FuncMap {
+	funcs := template.FuncMap{
+		"shuffleSeeded": func(text string) string {
+			return shuffleText(text, rand.New(rand.NewSource(DateSeed(date))))
+		},
+	}
+	if deterministic {
+		r := rand.New(rand.NewSource(DateSeed(date)))
+		funcs["shuffle"] = func(text string) string {
+			return shuffleText(text, r)
+		}
+		funcs["shuffleLines"] = func(lines []string) []string {
+			return shuffleList(lines, r)
+		}
+	}
+	return funcs
+}
+
+// journalFunctions returns the template functions that summarize journal, the
+// source journal of the render. statsTable formats its per-tag counts as a
+// markdown table, see TagTable, and is empty without a journal or tags.
+func journalFunctions(journal *TodoJournal) template.FuncMap {
+	return template.FuncMap{
+		"statsTable": func() string {
+			if journal == nil {
+				return ""
+			}
+			return TagTable(CountTags(journal))
+		},
+	}
+}
+
+// noteFunctions returns the template functions that read other notes of notes,
+// the journal tree of the render. noteField returns a frontmatter field of a note
+// given by its slash-separated path from the root of the tree, see
+// Document.FrontmatterValue. It is empty without notes and for missing notes and
+// fields; paths leaving the tree are an error.
+func noteFunctions(notes storage.Storage) template.FuncMap {
+	return template.FuncMap{
+		"noteField": func(name, key string) (string, error) {
+			if !fs.ValidPath(name) || strings.ContainsAny(name, `\:`) {
+				return "", fmt.Errorf("noteField: %q is not a path below the journal root", name)
+			}
+			if notes == nil {
+				return "", nil
+			}
+			content, err := notes.Read(name)
+			if errors.Is(err, storage.ErrNotExist) {
+				return "", nil
+			}
+			if err != nil {
+				return "", fmt.Errorf("noteField: %w", err)
+			}
+			value, _ := ParseDocument(string(content)).FrontmatterValue(key)
+			return value, nil
+		},
+	}
+}
+
+// DateSeed returns the seed the seeded template functions use for date.
+func DateSeed(date string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(date))
+	return int64(h.Sum64())
+}
+
+// shuffleText returns the non-empty lines of text in the order r shuffles them into.
+// A text with less than two such lines is returned as is.
+func shuffleText(text string, r *rand.Rand) string {
+	// Split the text into lines, filter out empty lines
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	var nonEmptyLines []string
+	for _, line := range lines {
+		if trimmed := strings.TrimSpace(line); trimmed != "" {
+			nonEmptyLines = append(nonEmptyLines, line)
+		}
+	}
+
+	// If we have no lines or only one line, return as-is
+	if len(nonEmptyLines) <= 1 {
+		return text
+	}
+
+	return strings.Join(shuffleList(nonEmptyLines, r), "\n")
+}
+
+// shuffleList returns a copy of lines in the order r shuffles them into.
+func shuffleList(lines []string, r *rand.Rand) []string {
+	if len(lines) <= 1 {
+		return lines
+	}
+
+	// Create a copy for shuffling
+	shuffled := make([]string, len(lines))
+	copy(shuffled, lines)
+
+	// Shuffle using Fisher-Yates algorithm
+	for i := len(shuffled) - 1; i > 0; i-- {
+		j := r.Intn(i + 1)
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	}
+
+	return shuffled
+}
@@ -0,0 +1,314 @@
+// Package core provides strict checking of template fields for the todoer application.
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// UnknownField is a field a template refers to that its data does not have.
+type UnknownField struct {
+	Line       int    // Line of the reference, counting from 1
+	Col        int    // Column of the unknown part of the reference, in bytes
+	Name       string // Field as written, e.g. ".Datee" or ".Custom.Projct"
+	Suggestion string // Closest existing field, empty if none is close
+}
+
+// String returns the field with its position and suggestion, e.g.
+// `line 3, column 5: .Datee (did you mean .Date?)`.
+func (f UnknownField) String() string {
+	s := fmt.Sprintf("line %d, column %d: %s", f.Line, f.Col, f.Name)
+	if f.Suggestion != "" {
+		s += fmt.Sprintf(" (did you mean %s?)", f.Suggestion)
+	}
+	return s
+}
+
+// TemplateFieldError lists the unknown fields found by CheckTemplate.
+type TemplateFieldError struct {
+	Fields []UnknownField // In the order they appear in the template
+}
+
+// Error returns all unknown fields, one per line after the first.
+func (e *TemplateFieldError) Error() string {
+	lines := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		lines[i] = f.String()
+	}
+	if len(lines) == 1 {
+		return "unknown template field at " + lines[0]
+	}
+	return fmt.Sprintf("%d unknown template fields:\n  %s", len(lines), strings.Join(lines, "\n  "))
+}
+
+// CheckTemplate parses the template content and reports every field it refers to
+// that TemplateData, or customVars for .Custom fields, does not have, with its
+// line and column. Such fields otherwise only fail, or print "<no value>", when the
+// template is rendered. Fields are followed into range and with blocks as far as
+// their types are known; references through variables other than $ and values of
+// unknown type are not checked. Syntax errors and unknown functions are reported
+// as returned by text/template.
+func CheckTemplate(content string, customVars map[string]interface{}) error {
+	return CheckTemplateFunctions(content, customVars, CreateTemplateFunctions())
+}
+
+// CheckTemplateFunctions checks the template content like CheckTemplate, for templates
+// rendered with funcs, e.g. TemplateFunctions(true) when the Sprig functions are enabled.
+func CheckTemplateFunctions(content string, customVars map[string]interface{}, funcs template.FuncMap) error {
+	tmpl, err := template.New("check").Funcs(funcs).Parse(content)
+	if err != nil {
+		return err
+	}
+
+	root := reflect.ValueOf(TemplateData{Custom: customVars})
+	c := &templateChecker{content: content, root: root, funcs: funcs}
+	for _, t := range tmpl.Templates() {
+		if t.Tree == nil || t.Tree.Root == nil {
+			continue
+		}
+		// Templates made with define are executed with whatever data they are given
+		dot := reflect.Value{}
+		if t.Name() == tmpl.Name() {
+			dot = root
+		}
+		c.walk(t.Tree.Root, dot)
+	}
+	if len(c.fields) == 0 {
+		return nil
+	}
+	sort.SliceStable(c.fields, func(i, j int) bool {
+		if c.fields[i].Line != c.fields[j].Line {
+			return c.fields[i].Line < c.fields[j].Line
+		}
+		return c.fields[i].Col < c.fields[j].Col
+	})
+	return &TemplateFieldError{Fields: c.fields}
+}
+
+// templateChecker walks a template tree, tracking the value dot refers to. An
+// invalid value means the type of dot is unknown and its fields are not checked.
+type templateChecker struct {
+	content string
+	root    reflect.Value
+	funcs   template.FuncMap
+	fields  []UnknownField
+}
+
+// walk checks the fields referred to by node, executed with dot.
+func (c *templateChecker) walk(node parse.Node, dot reflect.Value) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			c.walk(child, dot)
+		}
+	case *parse.ActionNode:
+		c.pipe(n.Pipe, dot)
+	case *parse.IfNode:
+		c.pipe(n.Pipe, dot)
+		c.walk(n.List, dot)
+		c.walk(n.ElseList, dot)
+	case *parse.RangeNode:
+		c.walk(n.List, elemValue(c.pipe(n.Pipe, dot)))
+		c.walk(n.ElseList, dot)
+	case *parse.WithNode:
+		c.walk(n.List, c.pipe(n.Pipe, dot))
+		c.walk(n.ElseList, dot)
+	case *parse.TemplateNode:
+		c.pipe(n.Pipe, dot)
+	}
+}
+
+// pipe checks the commands of pipe and returns the value it evaluates to.
+func (c *templateChecker) pipe(pipe *parse.PipeNode, dot reflect.Value) reflect.Value {
+	if pipe == nil {
+		return reflect.Value{}
+	}
+	var result reflect.Value
+	for _, cmd := range pipe.Cmds {
+		result = c.command(cmd, dot)
+	}
+	return result
+}
+
+// command checks the arguments of cmd and returns the value it evaluates to.
+func (c *templateChecker) command(cmd *parse.CommandNode, dot reflect.Value) reflect.Value {
+	var result reflect.Value
+	for i, arg := range cmd.Args {
+		v := c.arg(arg, dot)
+		if i == 0 {
+			result = v
+		}
+	}
+	if len(cmd.Args) > 0 {
+		if ident, ok := cmd.Args[0].(*parse.IdentifierNode); ok {
+			result = reflect.Value{}
+			if fn, ok := c.funcs[ident.Ident]; ok {
+				if t := reflect.TypeOf(fn); t.NumOut() > 0 {
+					result = reflect.Zero(t.Out(0))
+				}
+			}
+		}
+	}
+	return result
+}
+
+// arg checks the fields of the command argument node and returns its value.
+func (c *templateChecker) arg(node parse.Node, dot reflect.Value) reflect.Value {
+	switch n := node.(type) {
+	case *parse.DotNode:
+		return dot
+	case *parse.FieldNode:
+		return c.field(n, dot, n.Ident, "")
+	case *parse.VariableNode:
+		if n.Ident[0] != "$" {
+			return reflect.Value{}
+		}
+		return c.field(n, c.root, n.Ident[1:], "")
+	case *parse.ChainNode:
+		return c.field(n, c.arg(n.Node, dot), n.Field, "(...)")
+	case *parse.PipeNode:
+		return c.pipe(n, dot)
+	}
+	return reflect.Value{}
+}
+
+// field looks up the chain of field names idents in v, recording the first one
+// that does not exist, and returns the value found. prefix is written before the
+// field names when recording.
+func (c *templateChecker) field(node parse.Node, v reflect.Value, idents []string, prefix string) reflect.Value {
+	name := prefix
+	for _, ident := range idents {
+		v = indirect(v)
+		if !v.IsValid() {
+			return v
+		}
+		name += "." + ident
+
+		if method := v.MethodByName(ident); method.IsValid() {
+			if t := method.Type(); t.NumOut() > 0 {
+				v = reflect.Zero(t.Out(0))
+				continue
+			}
+			return reflect.Value{}
+		}
+		switch v.Kind() {
+		case reflect.Struct:
+			f, ok := v.Type().FieldByName(ident)
+			if ok && f.IsExported() {
+				v = v.FieldByIndex(f.Index)
+				continue
+			}
+			c.unknown(node, name, name[:len(name)-len(ident)], ident, structFields(v.Type()))
+			return reflect.Value{}
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return reflect.Value{}
+			}
+			if value := v.MapIndex(reflect.ValueOf(ident)); value.IsValid() {
+				v = value
+				continue
+			}
+			keys := make([]string, 0, v.Len())
+			for _, key := range v.MapKeys() {
+				keys = append(keys, key.String())
+			}
+			c.unknown(node, name, name[:len(name)-len(ident)], ident, keys)
+			return reflect.Value{}
+		default:
+			return reflect.Value{}
+		}
+	}
+	return v
+}
+
+// unknown records the unknown field name at node, suggesting the closest of the
+// candidate names in parent.
+func (c *templateChecker) unknown(node parse.Node, name, parent, ident string, candidates []string) {
+	line, col := nodePosition(c.content, node)
+
+	suggestion := ""
+	best := 3 // Suggest only names within two edits
+	for _, candidate := range candidates {
+		if d := editDistance(strings.ToLower(ident), strings.ToLower(candidate)); d < best {
+			best, suggestion = d, parent+candidate
+		}
+	}
+	c.fields = append(c.fields, UnknownField{Line: line, Col: col, Name: name, Suggestion: suggestion})
+}
+
+// indirect returns the value v points to or holds, or an invalid value if that
+// is nil and its type therefore unknown.
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer) {
+		if v.IsNil() {
+			if v.Kind() == reflect.Pointer {
+				return reflect.Zero(v.Type().Elem())
+			}
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// elemValue returns the value dot has inside a range over v.
+func elemValue(v reflect.Value) reflect.Value {
+	v = indirect(v)
+	if !v.IsValid() {
+		return v
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		if v.Len() > 0 {
+			return v.Index(0)
+		}
+		return reflect.Zero(v.Type().Elem())
+	case reflect.Map:
+		return reflect.Zero(v.Type().Elem())
+	case reflect.Int:
+		return reflect.Zero(v.Type())
+	}
+	return reflect.Value{}
+}
+
+// structFields returns the names of the exported fields and methods of t.
+func structFields(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			names = append(names, t.Field(i).Name)
+		}
+	}
+	for i := 0; i < t.NumMethod(); i++ {
+		names = append(names, t.Method(i).Name)
+	}
+	return names
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCheckTemplate(t *testing.T) {
+	custom := map[string]interface{}{"Project": "todoer", "Tags": []interface{}{"a", "b"}}
+	tests := []struct {
+		name    string
+		content string
+		want    []UnknownField
+	}{
+		{
+			name:    "known fields",
+			content: "{{.Date}} {{.Custom.Project}} {{range .TopTodos}}{{.Text}} {{$.DayName}}{{end}}\n{{range .Goals}}{{.Total}}{{end}} {{with .NextActions 3}}{{len .}}{{end}}",
+		},
+		{
+			name:    "misspelled field",
+			content: "# {{.Date}}\n\nWeek {{.WeekNumbr}}\n",
+			want:    []UnknownField{{Line: 3, Col: 8, Name: ".WeekNumbr", Suggestion: ".WeekNumber"}},
+		},
+		{
+			name:    "unknown custom variable",
+			content: "{{.Custom.Projct}}",
+			want:    []UnknownField{{Line: 1, Col: 10, Name: ".Custom.Projct", Suggestion: ".Custom.Project"}},
+		},
+		{
+			name:    "field of range element",
+			content: "{{range .Goals}}\n  {{.Gaol}} {{.Date}}\n{{end}}",
+			want: []UnknownField{
+				{Line: 2, Col: 5, Name: ".Gaol", Suggestion: ".Goal"},
+				{Line: 2, Col: 15, Name: ".Date"},
+			},
+		},
+		{
+			name:    "field through $ and pipelines",
+			content: "{{range .TodoDates}}{{$.Datee}}{{end}}{{.Nothing | printf \"%s\"}}",
+			want: []UnknownField{
+				{Line: 1, Col: 24, Name: ".Datee", Suggestion: ".Date"},
+				{Line: 1, Col: 41, Name: ".Nothing"},
+			},
+		},
+		{
+			name:    "values of unknown type are not checked",
+			content: "{{range $i, $x := .Custom.Tags}}{{$x.Whatever}}{{end}}{{with default 1 .Date}}{{.Anything}}{{end}}{{define \"d\"}}{{.Any}}{{end}}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckTemplate(tt.content, custom)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("CheckTemplate() error = %v", err)
+				}
+				return
+			}
+			var fieldErr *TemplateFieldError
+			if !errors.As(err, &fieldErr) {
+				t.Fatalf("CheckTemplate() error = %v, want a TemplateFieldError", err)
+			}
+			if !reflect.DeepEqual(fieldErr.Fields, tt.want) {
+				t.Errorf("CheckTemplate() fields = %+v, want %+v", fieldErr.Fields, tt.want)
+			}
+		})
+	}
+
+	if err := CheckTemplate("{{nosuchfunc .Date}}", nil); err == nil {
+		t.Error("CheckTemplate() accepted an unknown function")
+	}
+	err := CheckTemplate("{{.Custom.Project}}", nil)
+	if err == nil || err.Error() != "unknown template field at line 1, column 10: .Custom.Project" {
+		t.Errorf("CheckTemplate() without custom variables error = %v", err)
+	}
+}
@@ -0,0 +1,283 @@
+// Package core provides the documentation of template variables and functions for the todoer application.
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// VariableDoc describes a variable available to templates, see DescribeTemplateData.
+type VariableDoc struct {
+	Name        string // As written in templates, e.g. ".Date" or ".NextActions 3"
+	Type        string // Go type of the value, e.g. "string" or "[]core.GoalProgress"
+	Description string // What the variable holds
+	Example     string // Output of the variable for the described data
+}
+
+// FunctionDoc describes a template function, see DescribeTemplateData.
+type FunctionDoc struct {
+	Name        string // Name of the function, e.g. "addDays"
+	Signature   string // Parameter and result types, e.g. "addDays(string, int) string"
+	Description string // What the function does
+	Example     string // Template calling the function, e.g. {{addDays .Date 7}}
+	Output      string // Output of Example for the described data
+}
+
+// callDoc documents a template function or a method of TemplateData.
+type callDoc struct {
+	description string
+	example     string
+}
+
+// functionDocs documents the functions of CreateTemplateFunctions.
+var functionDocs = map[string]callDoc{
+	"addDays":       {"Date a number of days after a YYYY-MM-DD date", `{{addDays .Date 7}}`},
+	"subDays":       {"Date a number of days before a YYYY-MM-DD date", `{{subDays .Date 1}}`},
+	"addWeeks":      {"Date a number of weeks after a YYYY-MM-DD date", `{{addWeeks .Date 2}}`},
+	"addMonths":     {"Date a number of months after a YYYY-MM-DD date", `{{addMonths .Date 1}}`},
+	"daysDiff":      {"Days from the first to the second YYYY-MM-DD date", `{{daysDiff "2025-01-01" .Date}}`},
+	"formatDate":    {"YYYY-MM-DD date in a Go time layout", `{{formatDate .Date "Monday, January 02"}}`},
+	"weekday":       {"Weekday name of a YYYY-MM-DD date", `{{weekday .Date}}`},
+	"isWeekend":     {"Whether a YYYY-MM-DD date is a Saturday or Sunday", `{{isWeekend .Date}}`},
+	"isWorkday":     {"Whether a YYYY-MM-DD date is a Monday to Friday that is not a holiday", `{{isWorkday .Date}}`},
+	"nextWorkday":   {"First workday after a YYYY-MM-DD date, skipping weekends and holidays", `{{nextWorkday .Date}}`},
+	"prevWorkday":   {"Last workday before a YYYY-MM-DD date, skipping weekends and holidays", `{{prevWorkday .Date}}`},
+	"isMonday":      {"Whether a YYYY-MM-DD date is a Monday", `{{isMonday .Date}}`},
+	"isTuesday":     {"Whether a YYYY-MM-DD date is a Tuesday", `{{isTuesday .Date}}`},
+	"isWednesday":   {"Whether a YYYY-MM-DD date is a Wednesday", `{{isWednesday .Date}}`},
+	"isThursday":    {"Whether a YYYY-MM-DD date is a Thursday", `{{isThursday .Date}}`},
+	"isFriday":      {"Whether a YYYY-MM-DD date is a Friday", `{{isFriday .Date}}`},
+	"isSaturday":    {"Whether a YYYY-MM-DD date is a Saturday", `{{isSaturday .Date}}`},
+	"isSunday":      {"Whether a YYYY-MM-DD date is a Sunday", `{{isSunday .Date}}`},
+	"upper":         {"Text in upper case", `{{upper .DayName}}`},
+	"lower":         {"Text in lower case", `{{lower .DayName}}`},
+	"title":         {"Text with every word capitalized", `{{title "weekly review"}}`},
+	"trim":          {"Text without leading and trailing white space", `{{trim "  spaced  "}}`},
+	"replace":       {"Text with every occurrence of old replaced by new", `{{replace "-" "/" .Date}}`},
+	"repeat":        {"Text repeated a number of times", `{{repeat "=" 10}}`},
+	"len":           {"Length of a text in bytes", `{{len .DayName}}`},
+	"contains":      {"Whether a text contains another", `{{contains .DayName "day"}}`},
+	"hasPrefix":     {"Whether a text starts with another", `{{hasPrefix .Date "20"}}`},
+	"hasSuffix":     {"Whether a text ends with another", `{{hasSuffix .DayName "day"}}`},
+	"split":         {"Parts of a text around a separator", `{{split "-" .Date}}`},
+	"join":          {"List of texts joined with a separator", `{{join ", " (split "-" .Date)}}`},
+	"padRight":      {"Text padded with spaces to a display width", `{{padRight 12 .DayName}}|`},
+	"truncate":      {"Text cut to a display width, ending in … when cut", `{{truncate 5 .DayName}}`},
+	"wrap":          {"Text broken at spaces into lines of a display width", `{{wrap 4 "one two three"}}`},
+	"default":       {"Value, or the fallback if the value is empty", `{{default "none" .OldestTodoDate}}`},
+	"empty":         {"Whether a value is empty", `{{empty .TodoDates}}`},
+	"notEmpty":      {"Whether a value is not empty", `{{notEmpty .TodoDates}}`},
+	"seq":           {"Numbers from start to end", `{{seq 1 3}}`},
+	"dict":          {"Map of key and value pairs", `{{dict "key" .Date}}`},
+	"shuffle":       {"Lines of a text in random order", `{{shuffle "a\nb\nc"}}`},
+	"shuffleLines":  {"List of texts in random order", `{{shuffleLines (split "-" .Date)}}`},
+	"shuffleSeeded": {"Lines of a text in an order fixed by the template date", `{{shuffleSeeded "a\nb\nc"}}`},
+	"statsTable":    {"Markdown table of open and closed todos per tag in the source journal", `{{statsTable}}`},
+	"sortTodos":     {"Open tasks sorted by comma-separated keys: priority, age, date, tag, assignee or text, '-' reversing one", `{{sortTodos .TopTodos "priority,-age,tag"}}`},
+	"noteField":     {"Frontmatter field of another note below the journal root, empty if missing", `{{noteField "projects/ProjectX.md" "status"}}`},
+	"add":           {"Sum of two numbers", `{{add .TotalTodos 1}}`},
+	"sub":           {"Difference of two numbers", `{{sub .TotalTodos 1}}`},
+	"mul":           {"Product of two numbers", `{{mul .TotalTodos 2}}`},
+	"div":           {"Integer quotient of two numbers, 0 when dividing by 0", `{{div .TotalTodos 2}}`},
+}
+
+// sprigFunctionDocs documents the functions of createSprigFunctions.
+var sprigFunctionDocs = map[string]callDoc{
+	"now":             {"Current local time", `{{now | date "2006"}}`},
+	"date":            {"Time, Unix seconds or YYYY-MM-DD date in a Go time layout", `{{date "Jan 2" .Date}}`},
+	"toDate":          {"Time parsed from a text in a Go time layout", `{{toDate "2006-01-02" .Date | date "Monday"}}`},
+	"dateModify":      {"Time moved by a duration such as -24h", `{{toDate "2006-01-02" .Date | dateModify "48h" | date "2006-01-02"}}`},
+	"unixEpoch":       {"Unix seconds of a time", `{{toDate "2006-01-02" .Date | unixEpoch}}`},
+	"trimPrefix":      {"Text without a leading prefix", `{{trimPrefix "20" .Date}}`},
+	"trimSuffix":      {"Text without a trailing suffix", `{{trimSuffix "day" .DayName}}`},
+	"trimAll":         {"Text without leading and trailing characters of a set", `{{trimAll "-" "--x--"}}`},
+	"nospace":         {"Text without white space", `{{nospace "a b c"}}`},
+	"trunc":           {"First n bytes of a text, or the last -n for negative n", `{{trunc 4 .Date}}`},
+	"abbrev":          {"Text shortened to a width with an ellipsis", `{{abbrev 6 .DayName}}`},
+	"substr":          {"Bytes from start up to end of a text", `{{substr 5 7 .Date}}`},
+	"quote":           {"Values in double quotes, separated by spaces", `{{quote .DayName}}`},
+	"squote":          {"Values in single quotes, separated by spaces", `{{squote .DayName}}`},
+	"cat":             {"Values joined with spaces", `{{cat .DayName .Date}}`},
+	"indent":          {"Text with every line indented by n spaces", `{{indent 2 "a\nb"}}`},
+	"nindent":         {"Like indent, starting with a newline", `{{nindent 2 "a"}}`},
+	"plural":          {"First text for a count of 1, second text otherwise", `{{plural "todo" "todos" .TotalTodos}}`},
+	"splitList":       {"Parts of a text around a separator", `{{splitList "-" .Date}}`},
+	"regexMatch":      {"Whether a text matches a regular expression", `{{regexMatch "^[0-9-]+$" .Date}}`},
+	"regexFind":       {"First match of a regular expression in a text", `{{regexFind "[0-9]+" .Date}}`},
+	"regexReplaceAll": {"Text with every match of a regular expression replaced", `{{regexReplaceAll "-" .Date "."}}`},
+	"ternary":         {"First value if the condition is true, second otherwise", `{{ternary "weekend" "weekday" (isWeekend .Date)}}`},
+	"coalesce":        {"First value that is not empty", `{{coalesce .OldestTodoDate "none"}}`},
+	"toString":        {"Value as text", `{{toString .TotalTodos}}`},
+	"atoi":            {"Integer in a text, 0 if there is none", `{{atoi "42"}}`},
+	"int":             {"Number or numeric text as an integer", `{{int "7"}}`},
+	"float64":         {"Number or numeric text as a float", `{{float64 "1.5"}}`},
+	"toJson":          {"Value encoded as JSON", `{{toJson .TodoDates}}`},
+	"toPrettyJson":    {"Value encoded as indented JSON", `{{toPrettyJson (dict "date" .Date)}}`},
+	"fromJson":        {"Value decoded from JSON", `{{(fromJson "{\"a\": 1}").a}}`},
+	"list":            {"List of the values", `{{list 1 2 3}}`},
+	"first":           {"First element of a list", `{{first (splitList "-" .Date)}}`},
+	"last":            {"Last element of a list", `{{last (splitList "-" .Date)}}`},
+	"rest":            {"List without its first element", `{{rest (list 1 2 3)}}`},
+	"initial":         {"List without its last element", `{{initial (list 1 2 3)}}`},
+	"append":          {"List with a value added at the end", `{{append (list 1 2) 3}}`},
+	"prepend":         {"List with a value added at the start", `{{prepend (list 2 3) 1}}`},
+	"concat":          {"Lists joined into one", `{{concat (list 1) (list 2 3)}}`},
+	"reverse":         {"List in reverse order", `{{reverse (list 1 2 3)}}`},
+	"uniq":            {"List without repeated values", `{{uniq (list 1 1 2)}}`},
+	"compact":         {"List without empty values", `{{compact (list "a" "" "b")}}`},
+	"without":         {"List without the given values", `{{without (list 1 2 3) 2}}`},
+	"has":             {"Whether a list contains a value", `{{has "a" (list "a" "b")}}`},
+	"sortAlpha":       {"List of texts sorted alphabetically", `{{sortAlpha (list "b" "a")}}`},
+	"get":             {"Value of a key in a map, empty if missing", `{{get (dict "a" 1) "a"}}`},
+	"set":             {"Map with a key set to a value", `{{set (dict) "a" 1}}`},
+	"unset":           {"Map without a key", `{{unset (dict "a" 1 "b" 2) "a"}}`},
+	"hasKey":          {"Whether a map has a key", `{{hasKey (dict "a" 1) "a"}}`},
+	"keys":            {"Sorted keys of the maps", `{{keys (dict "b" 1 "a" 2)}}`},
+	"add1":            {"Number plus one", `{{add1 .TotalTodos}}`},
+	"mod":             {"Remainder of an integer division, 0 when dividing by 0", `{{mod 7 3}}`},
+	"max":             {"Largest of the integers", `{{max 1 5 3}}`},
+	"min":             {"Smallest of the integers", `{{min 1 5 3}}`},
+	"b64enc":          {"Text encoded as base64", `{{b64enc "todoer"}}`},
+	"b64dec":          {"Text decoded from base64", `{{b64dec "dG9kb2Vy"}}`},
+	"sha256sum":       {"Hex SHA-256 checksum of a text", `{{sha256sum .Date | trunc 8}}`},
+}
+
+// methodDocs documents the methods of TemplateData templates can call.
+var methodDocs = map[string]callDoc{
+	"NextActions":     {"The first n entries of .TopTodos", `{{.NextActions 3}}`},
+	"Overdue":         {"Open todos whose due date has passed, most overdue first", `{{.Overdue}}`},
+	"HasOverdue":      {"Whether an open todo's due date has passed", `{{.HasOverdue}}`},
+	"OpenByTag":       {"Texts of the open todos with a tag", `{{.OpenByTag "#work"}}`},
+	"CompletedOn":     {"Texts of the todos completed on a date", `{{.CompletedOn .PreviousDate}}`},
+	"PreviousSection": {"Content of a section of the previous journal", `{{.PreviousSection "## Notes"}}`},
+}
+
+// DescribeTemplateData returns the variables and registered functions available to
+// templates rendered with opts, with example output for its date and todos. The
+// variables are the fields of TemplateData, described by their doc tags, its methods,
+// and one entry per custom variable. Functions are sorted by name and include the
+// Sprig-compatible functions if opts.Sprig is set.
+func DescribeTemplateData(opts TemplateOptions) ([]VariableDoc, []FunctionDoc, error) {
+	data, err := buildTemplateData(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	funcs := TemplateFunctions(opts.Sprig)
+	for name, fn := range seededFunctions(opts.CurrentDate, opts.Deterministic) {
+		funcs[name] = fn
+	}
+	for name, fn := range journalFunctions(opts.Journal) {
+		funcs[name] = fn
+	}
+
+	var variables []VariableDoc
+	t := reflect.TypeOf(data)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Name == "Custom" && len(data.Custom) > 0 {
+			names := make([]string, 0, len(data.Custom))
+			for name := range data.Custom {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				variables = append(variables, VariableDoc{
+					Name:        ".Custom." + name,
+					Type:        fmt.Sprintf("%T", data.Custom[name]),
+					Description: field.Tag.Get("doc"),
+					Example:     renderExample("{{.Custom."+name+"}}", data, funcs),
+				})
+			}
+			continue
+		}
+		variables = append(variables, VariableDoc{
+			Name:        "." + field.Name,
+			Type:        field.Type.String(),
+			Description: field.Tag.Get("doc"),
+			Example:     renderExample("{{."+field.Name+"}}", data, funcs),
+		})
+	}
+	for i := 0; i < t.NumMethod(); i++ {
+		method := t.Method(i)
+		doc, ok := methodDocs[method.Name]
+		if !ok {
+			continue
+		}
+		example := strings.TrimSuffix(strings.TrimPrefix(doc.example, "{{"), "}}")
+		variables = append(variables, VariableDoc{
+			Name:        example,
+			Type:        signature(method.Name, method.Type, 1),
+			Description: doc.description,
+			Example:     renderExample(doc.example, data, funcs),
+		})
+	}
+
+	names := make([]string, 0, len(funcs))
+	for name := range funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	functions := make([]FunctionDoc, 0, len(names))
+	for _, name := range names {
+		doc, ok := functionDocs[name]
+		if !ok {
+			doc = sprigFunctionDocs[name]
+		}
+		functions = append(functions, FunctionDoc{
+			Name:        name,
+			Signature:   signature(name, reflect.TypeOf(funcs[name]), 0),
+			Description: doc.description,
+			Example:     doc.example,
+			Output:      renderExample(doc.example, data, funcs),
+		})
+	}
+	return variables, functions, nil
+}
+
+// signature formats the function type fn as name(params) results, skipping the
+// first skip parameters, like the receiver of a method.
+func signature(name string, fn reflect.Type, skip int) string {
+	var params, results []string
+	for i := skip; i < fn.NumIn(); i++ {
+		param := fn.In(i).String()
+		if fn.IsVariadic() && i == fn.NumIn()-1 {
+			param = "..." + fn.In(i).Elem().String()
+		}
+		params = append(params, param)
+	}
+	for i := 0; i < fn.NumOut(); i++ {
+		results = append(results, fn.Out(i).String())
+	}
+	s := name + "(" + strings.Join(params, ", ") + ")"
+	switch len(results) {
+	case 0:
+	case 1:
+		s += " " + results[0]
+	default:
+		s += " (" + strings.Join(results, ", ") + ")"
+	}
+	return s
+}
+
+// renderExample executes the template example with data, returning the error
+// text instead of the output if that fails.
+func renderExample(example string, data TemplateData, funcs template.FuncMap) string {
+	if example == "" {
+		return ""
+	}
+	tmpl, err := template.New("example").Funcs(funcs).Parse(example)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "error: " + err.Error()
+	}
+	return b.String()
+}
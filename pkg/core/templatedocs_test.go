@@ -0,0 +1,112 @@
+package core
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDescribeTemplateData(t *testing.T) {
+	journal, err := ParseTodosSection("- [[2025-06-19]]\n  - [ ] Open task\n  - [x] Done task")
+	if err != nil {
+		t.Fatalf("ParseTodosSection() error = %v", err)
+	}
+	variables, functions, err := DescribeTemplateData(TemplateOptions{
+		Content:      "{{.TODOS}}",
+		TodosContent: "- [ ] Open task",
+		CurrentDate:  "2025-06-20",
+		Journal:      journal,
+		CustomVars:   map[string]interface{}{"Project": "todoer", "Sprint": 7},
+	})
+	if err != nil {
+		t.Fatalf("DescribeTemplateData() error = %v", err)
+	}
+
+	byName := make(map[string]VariableDoc)
+	for _, v := range variables {
+		if v.Description == "" {
+			t.Errorf("variable %s has no description", v.Name)
+		}
+		if strings.HasPrefix(v.Example, "error: ") {
+			t.Errorf("variable %s example failed: %s", v.Name, v.Example)
+		}
+		byName[v.Name] = v
+	}
+	want := map[string]VariableDoc{
+		".Date":            {Name: ".Date", Type: "string", Description: "Current date in YYYY-MM-DD format", Example: "2025-06-20"},
+		".DayName":         {Name: ".DayName", Type: "string", Description: "Weekday name of the current date", Example: "Friday"},
+		".TotalTodos":      {Name: ".TotalTodos", Type: "int", Description: "Number of incomplete todos being carried over", Example: "1"},
+		".Custom.Project":  {Name: ".Custom.Project", Type: "string", Description: "Custom variable from the configuration", Example: "todoer"},
+		".Custom.Sprint":   {Name: ".Custom.Sprint", Type: "int", Description: "Custom variable from the configuration", Example: "7"},
+		".NextActions 3":   {Name: ".NextActions 3", Type: "NextActions(int) []core.NextAction", Description: "The first n entries of .TopTodos", Example: "[Open task]"},
+		".WeeklyVelocity":  {Name: ".WeeklyVelocity", Type: "float64", Description: "Average completed todos per week over the last four weeks", Example: "0"},
+		".PreviousDayName": {Name: ".PreviousDayName", Type: "string", Description: "Weekday name of the previous date", Example: ""},
+	}
+	for name, w := range want {
+		if got := byName[name]; got != w {
+			t.Errorf("variable %s = %+v, want %+v", name, got, w)
+		}
+	}
+	if _, ok := byName[".Custom"]; ok {
+		t.Error("custom variables are listed as .Custom instead of one by one")
+	}
+
+	// Every registered function is documented, and every documented function registered
+	registered := CreateTemplateFunctions()
+	if len(functions) != len(registered) {
+		t.Errorf("described %d functions, registered %d", len(functions), len(registered))
+	}
+	for _, f := range functions {
+		if f.Description == "" || f.Example == "" {
+			t.Errorf("function %s is not documented", f.Name)
+		}
+		if strings.HasPrefix(f.Output, "error: ") {
+			t.Errorf("function %s example failed: %s", f.Name, f.Output)
+		}
+		if f.Name == "addDays" && (f.Signature != "addDays(string, int) string" || f.Output != "2025-06-27") {
+			t.Errorf("addDays = %+v", f)
+		}
+		if f.Name == "dict" && f.Signature != "dict(...interface {}) map[string]interface {}" {
+			t.Errorf("dict signature = %q", f.Signature)
+		}
+	}
+	for name := range functionDocs {
+		if _, ok := registered[name]; !ok {
+			t.Errorf("documented function %s is not registered", name)
+		}
+	}
+
+	// Every Sprig-compatible function is documented, with an example that renders
+	_, functions, err = DescribeTemplateData(TemplateOptions{Content: "x", CurrentDate: "2025-06-20", Journal: journal, Sprig: true})
+	if err != nil {
+		t.Fatalf("DescribeTemplateData() with Sprig error = %v", err)
+	}
+	if want := len(TemplateFunctions(true)); len(functions) != want {
+		t.Errorf("described %d functions with Sprig, registered %d", len(functions), want)
+	}
+	for _, f := range functions {
+		if f.Description == "" || f.Example == "" {
+			t.Errorf("function %s is not documented", f.Name)
+		}
+		if strings.HasPrefix(f.Output, "error: ") {
+			t.Errorf("function %s example failed: %s", f.Name, f.Output)
+		}
+	}
+	for name := range sprigFunctionDocs {
+		if _, ok := createSprigFunctions()[name]; !ok {
+			t.Errorf("documented Sprig function %s is not registered", name)
+		}
+	}
+
+	// Every method templates can call is documented
+	typ := reflect.TypeOf(TemplateData{})
+	for i := 0; i < typ.NumMethod(); i++ {
+		if _, ok := methodDocs[typ.Method(i).Name]; !ok {
+			t.Errorf("method %s is not documented", typ.Method(i).Name)
+		}
+	}
+
+	if _, _, err := DescribeTemplateData(TemplateOptions{Content: "x", CurrentDate: "20-06-2025"}); err == nil {
+		t.Error("DescribeTemplateData() accepted an invalid date")
+	}
+}
@@ -15,6 +15,8 @@ const (
 	CompletedMarker = "x"
 	// UncompletedMarker is the character used to mark uncompleted todos
 	UncompletedMarker = " "
+	// VersionKey is the frontmatter key holding the version of todoer that wrote a journal
+	VersionKey = "todoer_version"
 )
 
 // Compiled regex patterns for better performance
@@ -30,6 +32,10 @@ var (
 	// DayHeaderRegex matches day headers in the format "- [[YYYY-MM-DD]]"
 	DayHeaderRegex = regexp.MustCompile(`- \[\[(\d{4}-\d{2}-\d{2})\]\]`)
 
+	// DayHeaderLineRegex matches a trimmed line that consists of a day header only.
+	// The parser uses it so that todos and bullets linking to a date are not mistaken for headers
+	DayHeaderLineRegex = regexp.MustCompile(`^- \[\[(\d{4}-\d{2}-\d{2})\]\]$`)
+
 	// TodoItemRegex matches todo items: "  - [x] Task text" or "  - [ ] Task text"
 	// Captures: (indentation, completion_status, text)
 	TodoItemRegex = regexp.MustCompile(`^(\s*)- \[([ x])\] (.+)$`)
@@ -53,6 +59,14 @@ type TodoItem struct {
 	Text        string      // The main text of the todo item
 	SubItems    []*TodoItem // Nested todo items (hierarchical structure)
 	BulletLines []string    // Non-todo bullet entries and multiline content associated with this item
+	CarryCount  int         // Times the item was carried to a new journal, from the carry marker of its text
+	Quote       string      // Blockquote prefix of the item line up to its bullet, e.g. "> ", empty outside blockquotes
+	Callout     []string    // Quoted lines without todos written before the item, like "> [!todo] Today"
+
+	// Meta holds the key::value and emoji annotations of Text by key, as parsed by
+	// ParseMeta. Text stays the source of truth and is written as is, so change the
+	// annotations with SetMeta and DeleteMeta to keep both in sync.
+	Meta map[string]string
 }
 
 // IsEmpty returns true if the todo item has no meaningful content
@@ -101,6 +115,20 @@ func (j *TodoJournal) IsEmpty() bool {
 	return j == nil || len(j.Days) == 0
 }
 
+// IsHeaderless returns true if the journal has todos but no day headers, as a
+// todos section written as a plain checklist
+func (j *TodoJournal) IsHeaderless() bool {
+	if j.IsEmpty() {
+		return false
+	}
+	for _, day := range j.Days {
+		if day != nil && day.Date != "" {
+			return false
+		}
+	}
+	return true
+}
+
 // DayCount returns the number of day sections in the journal
 func (j *TodoJournal) DayCount() int {
 	if j == nil {
@@ -111,40 +139,52 @@ func (j *TodoJournal) DayCount() int {
 
 // TemplateData holds the data to be passed to Go templates when generating journal files.
 // It provides comprehensive variables for flexible template rendering including date formatting and todo statistics.
+// The doc tag of each field describes it for DescribeTemplateData.
 type TemplateData struct {
-	Date         string // Current date in YYYY-MM-DD format
-	TODOS        string // Formatted todos content to be inserted into the template
-	PreviousDate string // Date of the previous journal that todos came from (YYYY-MM-DD format, empty if no previous journal)
+	Date         string `doc:"Current date in YYYY-MM-DD format"`
+	TODOS        string `doc:"Formatted todos carried over, inserted under the todos header"`
+	PreviousDate string `doc:"Date of the previous journal in YYYY-MM-DD format, empty if there is none"`
 
 	// Current date formatting variants
-	DateShort  string // 06/20/25
-	DateLong   string // June 20, 2025
-	Year       string // 2025
-	Month      string // 06
-	MonthName  string // June
-	Day        string // 20
-	DayName    string // Friday
-	WeekNumber int    // 25 (week of year)
+	DateShort  string `doc:"Current date in short format, e.g. 06/20/25"`
+	DateLong   string `doc:"Current date in long format, e.g. June 20, 2025"`
+	Year       string `doc:"Year of the current date"`
+	Month      string `doc:"Month number of the current date, with a leading zero"`
+	MonthName  string `doc:"Month name of the current date"`
+	Day        string `doc:"Day of month of the current date, with a leading zero"`
+	DayName    string `doc:"Weekday name of the current date"`
+	WeekNumber int    `doc:"ISO week number of the current date"`
 
 	// Previous date formatting variants (empty if no previous journal)
-	PreviousDateShort  string // 06/19/25
-	PreviousDateLong   string // June 19, 2025
-	PreviousYear       string // 2025
-	PreviousMonth      string // 06
-	PreviousMonthName  string // June
-	PreviousDay        string // 19
-	PreviousDayName    string // Thursday
-	PreviousWeekNumber int    // 25 (week of year)
+	PreviousDateShort  string `doc:"Previous date in short format"`
+	PreviousDateLong   string `doc:"Previous date in long format"`
+	PreviousYear       string `doc:"Year of the previous date"`
+	PreviousMonth      string `doc:"Month number of the previous date"`
+	PreviousMonthName  string `doc:"Month name of the previous date"`
+	PreviousDay        string `doc:"Day of month of the previous date"`
+	PreviousDayName    string `doc:"Weekday name of the previous date"`
+	PreviousWeekNumber int    `doc:"ISO week number of the previous date"`
 
 	// Todo statistics
-	TotalTodos               int      // Total number of incomplete todos being carried over
-	CompletedTodos           int      // Number of completed todos found in source journal
-	UncompletedTodos         int      // Number of uncompleted todos found in source journal
-	UncompletedTopLevelTodos int      // Number of uncompleted top-level todos
-	TodoDates                []string // List of unique dates that todos came from (YYYY-MM-DD format)
-	OldestTodoDate           string   // Date of the oldest incomplete todo (YYYY-MM-DD format, empty if no todos)
-	TodoDaysSpan             int      // Number of days spanned by todos (from oldest to current date)
+	TotalTodos               int            `doc:"Number of incomplete todos being carried over"`
+	CompletedTodos           int            `doc:"Number of completed todos in the source journal"`
+	UncompletedTodos         int            `doc:"Number of uncompleted todos in the source journal"`
+	UncompletedTopLevelTodos int            `doc:"Number of uncompleted top-level todos"`
+	TodoDates                []string       `doc:"Unique dates the todos came from, in YYYY-MM-DD format"`
+	OldestTodoDate           string         `doc:"Date of the oldest incomplete todo, empty if there are none"`
+	TodoDaysSpan             int            `doc:"Days from the oldest incomplete todo to the current date"`
+	Goals                    []GoalProgress `doc:"Completed and open todos per linked goal, sorted by goal"`
+	TopTodos                 []NextAction   `doc:"Open top-level todos by priority and age, without blocked ones"`
+
+	// Completion streaks across the journal tree (zero unless provided)
+	CurrentStreak   int     `doc:"Consecutive days with a completed todo up to the current date"`
+	LongestStreak   int     `doc:"Longest run of consecutive days with a completed todo"`
+	WeeklyVelocity  float64 `doc:"Average completed todos per week over the last four weeks"`
+	CompletionHours [24]int `doc:"Completed todos per hour of the day, 0 to 23, of those stamped with a time"`
 
 	// Custom variables (user-defined via config)
-	Custom map[string]interface{} // Custom template variables from configuration
+	Custom map[string]interface{} `doc:"Custom variable from the configuration"`
+
+	journal  *TodoJournal // Parsed source journal queried by methods such as OpenByTag (nil if not provided)
+	previous *Document    // Previous journal read by PreviousSection (nil if not provided)
 }
@@ -375,6 +375,27 @@ func TestTodoJournal_DayCount(t *testing.T) {
 	}
 }
 
+func TestTodoJournal_IsHeaderless(t *testing.T) {
+	tests := []struct {
+		name     string
+		journal  *TodoJournal
+		expected bool
+	}{
+		{"nil journal", nil, false},
+		{"empty journal", &TodoJournal{}, false},
+		{"undated section only", &TodoJournal{Days: []*DaySection{{Items: []*TodoItem{{Text: "Task"}}}}}, true},
+		{"undated and dated sections", &TodoJournal{Days: []*DaySection{{}, {Date: "2025-06-19"}}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := tt.journal.IsHeaderless(); result != tt.expected {
+				t.Errorf("IsHeaderless() = %v, expected %v", result, tt.expected)
+			}
+		})
+	}
+}
+
 // Test regex patterns
 func TestRegexPatterns(t *testing.T) {
 	t.Run("FrontmatterDateRegex", func(t *testing.T) {
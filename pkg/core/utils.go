@@ -6,6 +6,7 @@ import (
 	"regexp"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // Constants for indentation handling
@@ -58,6 +59,19 @@ func DeepCopyItem(item *TodoItem) *TodoItem {
 		Text:        item.Text,
 		SubItems:    make([]*TodoItem, 0, len(item.SubItems)),
 		BulletLines: make([]string, 0, len(item.BulletLines)),
+		CarryCount:  item.CarryCount,
+		Quote:       item.Quote,
+	}
+
+	if len(item.Callout) > 0 {
+		copy.Callout = append([]string(nil), item.Callout...)
+	}
+
+	if item.Meta != nil {
+		copy.Meta = make(map[string]string, len(item.Meta))
+		for key, value := range item.Meta {
+			copy.Meta[key] = value
+		}
 	}
 
 	// Copy bullet lines efficiently
@@ -94,12 +108,18 @@ func IsCompleted(item *TodoItem) bool {
 }
 
 // HasDateTag checks if text already contains a date tag in the format #YYYY-MM-DD.
-// Returns false for empty strings.
+// Dates in code spans, URLs and link targets are not tags. Returns false for empty strings.
 func HasDateTag(text string) bool {
 	if text == "" {
 		return false
 	}
-	return DateTagRegex.MatchString(text)
+	return len(findInProse(DateTagRegex, text)) > 0
+}
+
+// StripDateTags returns text without its date tags, including their time of day,
+// and surrounding whitespace. Dates in code spans, URLs and link targets are kept.
+func StripDateTags(text string) string {
+	return strings.TrimSpace(replaceInProse(completionTagRegex, text, func(string) string { return "" }))
 }
 
 // CountTotalItems recursively counts all todo items in a slice, including nested subitems.
@@ -211,13 +231,15 @@ func FormatDateVariables(dateStr string) DateVariables {
 
 // TodoStatistics holds calculated statistics about todos for template usage
 type TodoStatistics struct {
-	TotalTodos               int      // Total number of incomplete todos
-	CompletedTodos           int      // Number of completed todos
-	UncompletedTodos         int      // Number of uncompleted todos
-	TodoDates                []string // Unique dates that todos came from
-	OldestTodoDate           string   // Date of the oldest incomplete todo
-	TodoDaysSpan             int      // Number of days spanned by todos
-	UncompletedTopLevelTodos int      // Number of uncompleted top-level todos
+	TotalTodos               int            // Total number of incomplete todos
+	CompletedTodos           int            // Number of completed todos
+	UncompletedTodos         int            // Number of uncompleted todos
+	TodoDates                []string       // Unique dates that todos came from
+	OldestTodoDate           string         // Date of the oldest incomplete todo
+	TodoDaysSpan             int            // Number of days spanned by todos
+	UncompletedTopLevelTodos int            // Number of uncompleted top-level todos
+	Goals                    []GoalProgress // Completed and open todos per linked goal
+	TopTodos                 []NextAction   // Open top-level todos not blocked by another, see RankNextActions
 }
 
 // CalculateTodoStatistics analyzes a journal and calculates statistics for template usage.
@@ -288,6 +310,8 @@ func CalculateTodoStatistics(journal *TodoJournal, currentDate string) TodoStati
 	}
 
 	stats.OldestTodoDate = oldestDate
+	stats.Goals = CalculateGoalProgress(journal)
+	stats.TopTodos = RankNextActions(journal)
 
 	// Calculate days span if we have an oldest date
 	if oldestDate != "" && currentDate != "" {
@@ -373,7 +397,7 @@ func ValidateCustomVariables(customVars map[string]interface{}) error {
 		"PreviousMonth": true, "PreviousMonthName": true, "PreviousDay": true,
 		"PreviousDayName": true, "PreviousWeekNumber": true,
 		"TotalTodos": true, "CompletedTodos": true, "TodoDates": true,
-		"OldestTodoDate": true, "TodoDaysSpan": true, "Custom": true,
+		"OldestTodoDate": true, "TodoDaysSpan": true, "Goals": true, "TopTodos": true, "NextActions": true, "Custom": true,
 	}
 
 	for name, value := range customVars {
@@ -443,9 +467,10 @@ func isSupportedVariableType(value interface{}) bool {
 }
 
 // BuildFrontmatterDateRegex returns a compiled regex for extracting a date from frontmatter using the given key.
+// Invalid UTF-8 in the key is replaced with U+FFFD, which is also how the regex engine reads invalid input bytes.
 func BuildFrontmatterDateRegex(key string) *regexp.Regexp {
-	// Escape the key for regex
-	key = regexp.QuoteMeta(key)
+	// Escape the key for regex; regexp rejects patterns that are not valid UTF-8
+	key = regexp.QuoteMeta(strings.ToValidUTF8(key, string(utf8.RuneError)))
 	pattern := `(?s)---.*?` + key + `:\s*(\d{4}-\d{2}-\d{2}).*?---`
 	return regexp.MustCompile(pattern)
 }
@@ -0,0 +1,68 @@
+// Package core provides parse warnings for the todoer application.
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Kinds of parse warnings
+const (
+	WarnIgnoredLine  = "ignored-line"  // A line before the first day header or first todo of a day is not a todo and is dropped
+	WarnDuplicateDay = "duplicate-day" // A day header repeats the date of an earlier one
+	WarnIndentJump   = "indent-jump"   // A todo is indented more than one level deeper than the todo above
+	WarnUnknownLine  = "unknown-line"  // A line of a day is not a todo, note or continuation and is kept with the todo above
+)
+
+// movedToPattern matches the default note left in the todos section of a processed journal
+const movedToPattern = `Moved to \[\[\d{4}-\d{2}-\d{2}\]\]`
+
+// defaultMovedTo matches the lines of the default note
+var defaultMovedTo = regexp.MustCompile(`^` + movedToPattern + `$`)
+
+// templateAction matches the actions of a Go template
+var templateAction = regexp.MustCompile(`\{\{.*?\}\}`)
+
+// movedToRegex returns the regexp matching the lines of the note left in the todos
+// section of a journal processed with message, a Go template as Format.MovedMessage.
+// Actions match any text. The default note is matched too, so that journals
+// processed before the message was configured stay quiet.
+func movedToRegex(message string) *regexp.Regexp {
+	if message == "" {
+		return defaultMovedTo
+	}
+	patterns := []string{movedToPattern}
+	for _, line := range strings.Split(message, "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		literals := templateAction.Split(line, -1)
+		for i, literal := range literals {
+			literals[i] = regexp.QuoteMeta(literal)
+		}
+		patterns = append(patterns, strings.Join(literals, ".*"))
+	}
+	return regexp.MustCompile(`^(?:` + strings.Join(patterns, "|") + `)$`)
+}
+
+// Warning is a problem found while parsing a todos section that does not stop
+// processing but may change the journal in ways the user did not intend.
+type Warning struct {
+	Line    int    // Line of the problem, counted like JournalError.Line
+	Kind    string // WarnIgnoredLine, WarnDuplicateDay, WarnIndentJump or WarnUnknownLine
+	Message string // Description of the problem
+}
+
+// String returns the warning prefixed with its line.
+func (w Warning) String() string {
+	return fmt.Sprintf("line %d: %s", w.Line, w.Message)
+}
+
+// shiftWarnings moves the lines of warnings down by lines, see shiftLines.
+func shiftWarnings(warnings []Warning, lines int) []Warning {
+	for i := range warnings {
+		warnings[i].Line += lines
+	}
+	return warnings
+}
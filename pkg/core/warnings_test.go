@@ -0,0 +1,70 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestParseTodosSectionWarnings(t *testing.T) {
+	section := `Moved to [[2025-06-21]]
+Loose note
+- [[2025-06-19]]
+  - Day note
+  - [ ] Parent
+        - [ ] Too deep
+  - [ ] Sibling
+- [[2025-06-19]]
+  - [ ] Again
+
+#### Blocked
+- [[2025-06-19]]
+  - [ ] Waiting depends::[[x]]`
+
+	_, warnings, err := ParseTodosSectionWarnings(context.Background(), section, DefaultFormat)
+	if err != nil {
+		t.Fatalf("ParseTodosSectionWarnings() error: %v", err)
+	}
+	want := []Warning{
+		{Line: 2, Kind: WarnIgnoredLine, Message: `"Loose note" comes before the first day header and is dropped`},
+		{Line: 4, Kind: WarnIgnoredLine, Message: `"- Day note" comes before the first todo of its day and is dropped`},
+		{Line: 6, Kind: WarnIndentJump, Message: `"Too deep" is indented 3 levels deeper than the todo above`},
+		{Line: 8, Kind: WarnDuplicateDay, Message: "day 2025-06-19 appears more than once"},
+	}
+	if !reflect.DeepEqual(warnings, want) {
+		t.Errorf("warnings = %+v, want %+v", warnings, want)
+	}
+	if got := want[0].String(); got != `line 2: "Loose note" comes before the first day header and is dropped` {
+		t.Errorf("String() = %q", got)
+	}
+
+	result, err := ProcessTodosSectionWithOptions(context.Background(), section, "2025-06-19", "2025-06-20", ProcessOptions{Format: DefaultFormat, LineOffset: 10})
+	if err != nil {
+		t.Fatalf("ProcessTodosSectionWithOptions() error: %v", err)
+	}
+	if len(result.Warnings) != 4 || result.Warnings[0].Line != 12 {
+		t.Errorf("ProcessTodosSectionWithOptions() warnings = %+v, want them 10 lines down", result.Warnings)
+	}
+}
+
+func TestParseTodosSectionWarnings_MovedMessage(t *testing.T) {
+	format := DefaultFormat
+	format.MovedMessage = "→ continued in [[{{.Name}}|{{weekday .Date}}]]\n({{.Path}})"
+	section := `→ continued in [[2025-06-21|Saturday]]
+(2025/06/2025-06-21.md)
+Moved to [[2025-06-20]]
+→ continued elsewhere
+- [[2025-06-19]]
+  - [ ] Task`
+
+	_, warnings, err := ParseTodosSectionWarnings(context.Background(), section, format)
+	if err != nil {
+		t.Fatalf("ParseTodosSectionWarnings() error: %v", err)
+	}
+	want := []Warning{
+		{Line: 4, Kind: WarnIgnoredLine, Message: `"→ continued elsewhere" comes before the first day header and is dropped`},
+	}
+	if !reflect.DeepEqual(warnings, want) {
+		t.Errorf("warnings = %+v, want %+v", warnings, want)
+	}
+}
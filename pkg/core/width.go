@@ -0,0 +1,166 @@
+// Package core provides display width aware text helpers for the todoer application.
+package core
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/width"
+)
+
+// Runes that join or modify the character before them
+const (
+	zeroWidthJoiner    = '\u200d'
+	variationSelector  = '\ufe0f' // Asks for the emoji presentation of the character before it
+	skinToneFirst      = '\U0001F3FB'
+	skinToneLast       = '\U0001F3FF'
+	regionalIndicatorA = '\U0001F1E6'
+	regionalIndicatorZ = '\U0001F1FF'
+)
+
+// cluster is a user-perceived character: a base rune with the marks, modifiers
+// and joined runes that follow it, and the number of terminal columns it takes.
+type cluster struct {
+	text  string
+	width int
+}
+
+// splitClusters splits s into clusters. Combining marks, variation selectors and
+// skin tones attach to the character before them, a zero width joiner joins the
+// characters around it into one emoji, and two regional indicators form a flag.
+// East Asian wide and fullwidth characters and emoji take two columns.
+func splitClusters(s string) []cluster {
+	var clusters []cluster
+	joined := false // The previous rune was a zero width joiner
+	for _, r := range s {
+		attach := len(clusters) > 0
+		last := len(clusters) - 1
+		switch {
+		case attach && (r == variationSelector || (isRegionalIndicator(r) && isLoneRegionalIndicator(clusters[last].text))):
+			clusters[last].width = 2
+		case attach && (joined || r == zeroWidthJoiner || isZeroWidth(r) || (r >= skinToneFirst && r <= skinToneLast)):
+		default:
+			clusters = append(clusters, cluster{width: runeWidth(r)})
+			last = len(clusters) - 1
+		}
+		clusters[last].text += string(r)
+		joined = r == zeroWidthJoiner
+	}
+	return clusters
+}
+
+// isZeroWidth reports whether r takes no column of its own.
+func isZeroWidth(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf, unicode.Cc)
+}
+
+// isRegionalIndicator reports whether r is one of the letters flags are made of.
+func isRegionalIndicator(r rune) bool {
+	return r >= regionalIndicatorA && r <= regionalIndicatorZ
+}
+
+// isLoneRegionalIndicator reports whether s is a regional indicator waiting for the
+// second letter of its flag.
+func isLoneRegionalIndicator(s string) bool {
+	runes := []rune(s)
+	return len(runes) == 1 && isRegionalIndicator(runes[0])
+}
+
+// runeWidth returns the number of columns r takes on its own.
+func runeWidth(r rune) int {
+	if isZeroWidth(r) {
+		return 0
+	}
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	}
+	return 1
+}
+
+// DisplayWidth returns the number of terminal columns s takes, counting East
+// Asian wide characters and emoji as two columns and combining marks as none.
+// Newlines and other control characters take no columns.
+func DisplayWidth(s string) int {
+	total := 0
+	for _, c := range splitClusters(s) {
+		total += c.width
+	}
+	return total
+}
+
+// PadRight appends spaces to s until it takes n columns. Text already as wide
+// as n is returned unchanged.
+func PadRight(n int, s string) string {
+	if pad := n - DisplayWidth(s); pad > 0 {
+		return s + strings.Repeat(" ", pad)
+	}
+	return s
+}
+
+// Truncate shortens s to at most n columns, ending it with "…" when anything
+// was cut. Characters are never split.
+func Truncate(n int, s string) string {
+	if DisplayWidth(s) <= n {
+		return s
+	}
+	if n <= 0 {
+		return ""
+	}
+	var b strings.Builder
+	used := 0
+	for _, c := range splitClusters(s) {
+		if used+c.width > n-1 {
+			break
+		}
+		b.WriteString(c.text)
+		used += c.width
+	}
+	return b.String() + "…"
+}
+
+// Wrap breaks the lines of s at spaces so that none takes more than n columns.
+// Words wider than n are split between characters. Spaces between words are
+// collapsed to one; existing line breaks are kept.
+func Wrap(n int, s string) string {
+	if n <= 0 {
+		return s
+	}
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = wrapLine(n, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// wrapLine wraps a single line for Wrap.
+func wrapLine(n int, line string) string {
+	var out []string
+	current, used := "", 0
+	flush := func() {
+		out = append(out, current)
+		current, used = "", 0
+	}
+	for _, word := range strings.Fields(line) {
+		w := DisplayWidth(word)
+		if used > 0 && used+1+w <= n {
+			current += " " + word
+			used += 1 + w
+			continue
+		}
+		if used > 0 {
+			flush()
+		}
+		for _, c := range splitClusters(word) {
+			if used+c.width > n && used > 0 {
+				flush()
+			}
+			current += c.text
+			used += c.width
+		}
+	}
+	if used > 0 || len(out) == 0 {
+		out = append(out, current)
+	}
+	return strings.Join(out, "\n")
+}
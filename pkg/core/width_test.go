@@ -0,0 +1,123 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDisplayWidth(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want int
+	}{
+		{name: "ascii", s: "Call Kim", want: 8},
+		{name: "empty", s: "", want: 0},
+		{name: "accented", s: "Café", want: 4},
+		{name: "combining mark", s: "Café", want: 4},
+		{name: "cjk", s: "日本語", want: 6},
+		{name: "fullwidth", s: "ＡＢ", want: 4},
+		{name: "emoji", s: "✅ done", want: 7},
+		{name: "variation selector", s: "❤️", want: 2},
+		{name: "skin tone", s: "👍🏽", want: 2},
+		{name: "zwj sequence", s: "👩‍💻", want: 2},
+		{name: "flag", s: "🇳🇴", want: 2},
+		{name: "two flags", s: "🇳🇴🇸🇪", want: 4},
+		{name: "control characters", s: "a\tb\n", want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DisplayWidth(tt.s); got != tt.want {
+				t.Errorf("DisplayWidth(%q) = %d, want %d", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPadRight(t *testing.T) {
+	tests := []struct {
+		n    int
+		s    string
+		want string
+	}{
+		{n: 6, s: "abc", want: "abc   "},
+		{n: 6, s: "日本", want: "日本  "},
+		{n: 4, s: "✅ ok", want: "✅ ok"},
+		{n: 2, s: "abc", want: "abc"},
+		{n: 5, s: "👩‍💻", want: "👩‍💻   "},
+	}
+	for _, tt := range tests {
+		if got := PadRight(tt.n, tt.s); got != tt.want {
+			t.Errorf("PadRight(%d, %q) = %q, want %q", tt.n, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		n    int
+		s    string
+		want string
+	}{
+		{n: 10, s: "short", want: "short"},
+		{n: 5, s: "Call the bank", want: "Call…"},
+		{n: 4, s: "日本語", want: "日…"},
+		{n: 5, s: "日本語", want: "日本…"},
+		{n: 3, s: "👍🏽👍🏽", want: "👍🏽…"},
+		{n: 1, s: "abc", want: "…"},
+		{n: 0, s: "abc", want: ""},
+	}
+	for _, tt := range tests {
+		got := Truncate(tt.n, tt.s)
+		if got != tt.want {
+			t.Errorf("Truncate(%d, %q) = %q, want %q", tt.n, tt.s, got, tt.want)
+		}
+		if DisplayWidth(got) > tt.n && tt.n >= 0 {
+			t.Errorf("Truncate(%d, %q) = %q is wider than %d", tt.n, tt.s, got, tt.n)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		name string
+		n    int
+		s    string
+		want string
+	}{
+		{name: "fits", n: 20, s: "Call the bank", want: "Call the bank"},
+		{name: "words", n: 9, s: "Call the bank about the loan", want: "Call the\nbank\nabout the\nloan"},
+		{name: "wide characters", n: 6, s: "日本語 日本", want: "日本語\n日本"},
+		{name: "long word", n: 4, s: "abcdefghij", want: "abcd\nefgh\nij"},
+		{name: "long wide word", n: 3, s: "日本語", want: "日\n本\n語"},
+		{name: "line breaks kept", n: 10, s: "one\n\ntwo three", want: "one\n\ntwo three"},
+		{name: "spaces collapsed", n: 20, s: "a   b", want: "a b"},
+		{name: "no width", n: 0, s: "a b", want: "a b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Wrap(tt.n, tt.s)
+			if got != tt.want {
+				t.Errorf("Wrap(%d, %q) = %q, want %q", tt.n, tt.s, got, tt.want)
+			}
+			for _, line := range strings.Split(got, "\n") {
+				if tt.n > 0 && DisplayWidth(line) > tt.n {
+					t.Errorf("Wrap(%d, %q) line %q is too wide", tt.n, tt.s, line)
+				}
+			}
+		})
+	}
+}
+
+func TestColumnTemplateFunctions(t *testing.T) {
+	output, err := CreateFromTemplate(TemplateOptions{
+		Content:     "|{{padRight 10 .DayName}}|{{truncate 4 .MonthName}}|\n{{wrap 7 \"🇳🇴 Oslo trip\"}}",
+		CurrentDate: "2025-09-20",
+	})
+	if err != nil {
+		t.Fatalf("CreateFromTemplate() error: %v", err)
+	}
+	if want := "|Saturday  |Sep…|\n🇳🇴 Oslo\ntrip"; output != want {
+		t.Errorf("output = %q, want %q", output, want)
+	}
+}
@@ -2,13 +2,18 @@
 package generator
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/inful/todoer/pkg/core"
+	"github.com/inful/todoer/pkg/storage"
 )
 
 // Generator instances are safe for concurrent use by multiple goroutines as they
@@ -20,6 +25,28 @@ type Generator struct {
 	customVars         map[string]interface{} // Custom template variables
 	frontmatterDateKey string                 // Frontmatter date key
 	todosHeader        string                 // TODOS section header
+	format             core.Format            // Indentation of the TODOS section
+	collapseCarried    bool                   // Merge carried todos under the template date
+	annotateCarried    bool                   // Annotate collapsed todos with their original date
+	maxCarry           int                    // Maximum number of top-level todos carried; 0 for no limit
+	cascadeComplete    bool                   // Complete the subitems of completed todos
+	autoCompleteParent bool                   // Complete todos whose subitems are all completed
+	days               core.DayRange          // Day sections to process; the others are left alone
+	assignee           string                 // Only carry the open todos assigned to this person; empty for all
+	completionTime     time.Time              // Time of day stamped on date tags added on its day; zero for none
+	streaks            core.StreakStats       // Completion streaks exposed to the template
+	codec              Codec                  // Codec for journal files read by ProcessFile (nil for plain text)
+	outputs            []Output               // Extra artifacts rendered by Process
+	strictTemplates    bool                   // Reject templates referring to unknown fields
+	secretVars         []string               // Custom variables templates must not use
+	sprigFunctions     bool                   // Offer the Sprig-compatible template functions
+	calendar           core.Calendar          // Holidays for the workday template functions
+	notes              storage.Storage        // Journal tree read by the noteField template function (nil for none)
+	deterministic      bool                   // Seed the random template functions with the template date
+	movedMessage       string                 // Left in the processed journal without completed todos; empty for the default
+	omitMovedMessage   bool                   // Leave the todos section of the processed journal empty instead
+	retry              storage.RetryPolicy    // Retries of ProcessFile reads failing with transient errors
+	versionStamp       string                 // Version stamped into the frontmatter of new files; empty for none
 }
 
 // NewGeneratorWithOptions creates a new Generator with flexible configuration options.
@@ -29,6 +56,7 @@ func NewGeneratorWithOptions(templateContent, templateDate string, opts ...Optio
 	// Set up default configuration
 	config := &options{
 		todosHeader: core.TodosHeader, // Default to core.TodosHeader
+		format:      core.DefaultFormat,
 	}
 
 	// Apply options
@@ -48,6 +76,18 @@ func NewGeneratorWithOptions(templateContent, templateDate string, opts ...Optio
 		}
 	}
 
+	if err := config.format.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid format: %w", err)
+	}
+
+	if err := config.days.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid day range: %w", err)
+	}
+
+	if config.assignee != "" && !core.ValidAssignee(config.assignee) {
+		return nil, fmt.Errorf("invalid assignee %q", config.assignee)
+	}
+
 	g := &Generator{
 		templateContent:    templateContent,
 		templateDate:       templateDate,
@@ -55,12 +95,37 @@ func NewGeneratorWithOptions(templateContent, templateDate string, opts ...Optio
 		customVars:         config.customVars,
 		frontmatterDateKey: config.frontmatterDateKey,
 		todosHeader:        config.todosHeader, // Always set
+		format:             config.format,
+		collapseCarried:    config.collapseCarried,
+		annotateCarried:    config.annotateCarried,
+		maxCarry:           config.maxCarry,
+		cascadeComplete:    config.cascadeComplete,
+		autoCompleteParent: config.autoCompleteParent,
+		days:               config.days,
+		assignee:           config.assignee,
+		completionTime:     config.completionTime,
+		streaks:            config.streaks,
+		codec:              config.codec,
+		outputs:            config.outputs,
+		strictTemplates:    config.strictTemplates,
+		secretVars:         config.secretVars,
+		sprigFunctions:     config.sprigFunctions,
+		calendar:           config.calendar,
+		notes:              config.notes,
+		deterministic:      config.deterministic,
+		movedMessage:       config.movedMessage,
+		omitMovedMessage:   config.omitMovedMessage,
+		retry:              config.retry,
+		versionStamp:       config.versionStamp,
 	}
 
 	// Validate template syntax
 	if err := g.validateTemplate(); err != nil {
 		return nil, err
 	}
+	if err := validateOutputs(g.outputs, g.templateFunctions()); err != nil {
+		return nil, err
+	}
 
 	return g, nil
 }
@@ -77,19 +142,144 @@ func NewGeneratorFromFileWithOptions(templateFile, templateDate string, opts ...
 	return NewGeneratorWithOptions(string(templateBytes), templateDate, opts...)
 }
 
+// Warning is a non-fatal problem found in a processed journal, see core.Warning.
+type Warning = core.Warning
+
 // ProcessResult holds readers for the modified original and new file.
+// Stats describes the todos found in the processed journal.
 type ProcessResult struct {
 	ModifiedOriginal io.Reader
 	NewFile          io.Reader
+	Stats            core.TodoStatistics
+	Overflow         *core.TodoJournal // Uncompleted todos not carried because of WithMaxCarry
+	Warnings         []Warning         // Problems in the journal that did not stop processing
+	Outputs          []OutputResult    // Artifacts rendered for WithOutputs, in the order given
 }
 
 // Process processes journal content and returns a ProcessResult.
 // It returns an error if parsing or processing fails.
 func (g *Generator) Process(originalContent string) (*ProcessResult, error) {
+	return g.ProcessContext(context.Background(), originalContent)
+}
+
+// ProcessContext processes journal content like Process but honours cancellation and
+// deadlines of ctx. If ctx is done before processing completes, the context's error is returned.
+func (g *Generator) ProcessContext(ctx context.Context, originalContent string) (*ProcessResult, error) {
+	parts, err := g.split(ctx, originalContent)
+	if err != nil {
+		return nil, err
+	}
+
+	// Render the new file eagerly so template errors surface here rather than on read
+	newFile := &bytes.Buffer{}
+	if err := g.writeNewFile(newFile, parts); err != nil {
+		return nil, err
+	}
+	outputs, err := g.renderOutputs(parts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProcessResult{
+		ModifiedOriginal: parts.modifiedOriginalReader(),
+		NewFile:          newFile,
+		Stats:            parts.stats(g.templateDate),
+		Overflow:         parts.overflow,
+		Warnings:         parts.warnings,
+		Outputs:          outputs,
+	}, nil
+}
+
+// ProcessTo processes journal content and writes the modified original and the
+// new file content to the given writers instead of returning them in a ProcessResult.
+// The modified original is copied from the original content without joining its
+// pieces, but the new file is rendered in memory first: repeated TODOS sections are
+// merged, footnotes carried and the version stamped on the whole rendered file.
+// It returns statistics about the processed todos. Outputs set with WithOutputs are
+// not rendered; use Process for them.
+func (g *Generator) ProcessTo(originalContent string, modifiedOriginal, newFile io.Writer) (core.TodoStatistics, error) {
+	return g.ProcessToContext(context.Background(), originalContent, modifiedOriginal, newFile)
+}
+
+// ProcessToContext is like ProcessTo but honours cancellation and deadlines of ctx.
+// Output may already have been written to either writer when an error is returned.
+func (g *Generator) ProcessToContext(ctx context.Context, originalContent string, modifiedOriginal, newFile io.Writer) (core.TodoStatistics, error) {
+	parts, err := g.split(ctx, originalContent)
+	if err != nil {
+		return core.TodoStatistics{}, err
+	}
+
+	newFileWriter := bufio.NewWriter(newFile)
+	if err := g.writeNewFile(newFileWriter, parts); err != nil {
+		return core.TodoStatistics{}, err
+	}
+	if err := newFileWriter.Flush(); err != nil {
+		return core.TodoStatistics{}, fmt.Errorf("failed to write new file content: %w", err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return core.TodoStatistics{}, err
+	}
+
+	if _, err := io.Copy(modifiedOriginal, parts.modifiedOriginalReader()); err != nil {
+		return core.TodoStatistics{}, fmt.Errorf("failed to write modified original content: %w", err)
+	}
+
+	return parts.stats(g.templateDate), nil
+}
+
+// journalParts holds the pieces of a processed journal. The before/after strings are
+// slices of the original content, so no copy of the untouched sections is made.
+type journalParts struct {
+	date             string // Date of the processed journal
+	beforeTodos      string
+	completedTodos   string
+	afterTodos       string
+	uncompletedTodos string
+	journal          *core.TodoJournal
+	overflow         *core.TodoJournal
+	warnings         []Warning
+	done             *core.TodoJournal
+	carried          *core.TodoJournal
+	previous         *core.Document // Original content, whose sections templates can quote
+}
+
+// modifiedOriginalReader returns the original content with the processed TODOS section
+// without concatenating the pieces.
+func (p *journalParts) modifiedOriginalReader() io.Reader {
+	after := p.afterTodos
+	if p.completedTodos == "" {
+		// An empty section keeps only the blank line below its header
+		after = strings.TrimLeft(after, "\n")
+	} else if after != "" && !strings.HasPrefix(after, "\n") {
+		// An empty section directly followed by the next one left no blank line
+		// for after the todos
+		after = core.BlankLineSeparator + after
+	}
+	return io.MultiReader(
+		strings.NewReader(p.beforeTodos),
+		strings.NewReader(p.completedTodos),
+		strings.NewReader(after),
+	)
+}
+
+// stats calculates todo statistics for the processed journal
+func (p *journalParts) stats(templateDate string) core.TodoStatistics {
+	return core.CalculateTodoStatistics(p.journal, templateDate)
+}
+
+// split extracts and processes the TODOS section of the original content
+func (g *Generator) split(ctx context.Context, originalContent string) (*journalParts, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	// Empty content is invalid; require at least some frontmatter/body
 	if strings.TrimSpace(originalContent) == "" {
 		return nil, fmt.Errorf("original content cannot be empty")
 	}
+	if err := g.format.Limits.CheckFileSize(len(originalContent)); err != nil {
+		return nil, err
+	}
 	// Extract the date from frontmatter using the configured key
 	date, err := core.ExtractDateFromFrontmatter(originalContent, g.frontmatterDateKey)
 	if err != nil {
@@ -106,46 +296,162 @@ func (g *Generator) Process(originalContent string) (*ProcessResult, error) {
 	}
 
 	// Process the TODOS section with statistics
-	completedTodos, uncompletedTodos, journal, err := core.ProcessTodosSectionWithStats(todosSection, date, g.templateDate)
+	processed, err := core.ProcessTodosSectionWithOptions(ctx, todosSection, date, g.templateDate, core.ProcessOptions{
+		Format:          g.format,
+		CollapseCarried: g.collapseCarried,
+		AnnotateCarried: g.annotateCarried,
+		MaxCarry:        g.maxCarry,
+		LineOffset:      strings.Count(beforeTodos, "\n"),
+
+		CascadeComplete:    g.cascadeComplete,
+		AutoCompleteParent: g.autoCompleteParent,
+		Days:               g.days,
+		Assignee:           g.assignee,
+		CompletionTime:     g.completionTime,
+
+		MovedMessage:     g.movedMessage,
+		OmitMovedMessage: g.omitMovedMessage,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to process TODOS section: %w", err)
 	}
 
-	// Create the completed file content
-	completedFileContent := beforeTodos + completedTodos + afterTodos
-
-	// Create the uncompleted file content using the template with statistics and custom variables
-	uncompletedFileContent, err := g.createFromTemplateWithCustom(uncompletedTodos, g.templateDate, journal)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create content from template: %w", err)
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
-	return &ProcessResult{
-		ModifiedOriginal: strings.NewReader(completedFileContent),
-		NewFile:          strings.NewReader(uncompletedFileContent),
+	return &journalParts{
+		date:             date,
+		beforeTodos:      beforeTodos,
+		completedTodos:   processed.Completed,
+		afterTodos:       afterTodos,
+		uncompletedTodos: processed.Uncompleted,
+		journal:          processed.Journal,
+		overflow:         processed.Overflow,
+		warnings:         processed.Warnings,
+		done:             processed.Done,
+		carried:          processed.Carried,
+		previous:         core.ParseDocument(originalContent),
 	}, nil
 }
 
+// writeNewFile renders the template for the uncompleted todos to w. The file is
+// rendered into a buffer, which the passes after the template need whole.
+func (g *Generator) writeNewFile(w io.Writer, parts *journalParts) error {
+	var buf strings.Builder
+	if err := g.writeFromTemplateWithCustom(&buf, parts.uncompletedTodos, g.templateDate, parts.journal, parts.previous); err != nil {
+		return fmt.Errorf("failed to create content from template: %w", err)
+	}
+	// A template writing the TODOS header itself can end up with two sections
+	content, err := core.MergeTodosSections(buf.String(), g.todosHeader)
+	if err != nil {
+		return fmt.Errorf("failed to create content from template: %w", err)
+	}
+	// Carried todos keep the footnotes they refer to
+	content = core.CarryFootnotes(content, parts.uncompletedTodos, parts.beforeTodos+parts.afterTodos)
+	if g.versionStamp != "" {
+		doc := core.ParseDocument(content)
+		if doc.Frontmatter != "" {
+			doc.SetFrontmatterValue(core.VersionKey, g.versionStamp)
+			content = doc.String()
+		}
+	}
+	_, err = io.WriteString(w, content)
+	return err
+}
+
 // ProcessFile processes a journal file and returns a ProcessResult.
 // It returns an error if the file cannot be read or processing fails.
 func (g *Generator) ProcessFile(filename string) (*ProcessResult, error) {
-	content, err := os.ReadFile(filename)
+	return g.ProcessFileContext(context.Background(), filename)
+}
+
+// ProcessFileContext processes a journal file like ProcessFile but honours cancellation
+// and deadlines of ctx while reading and processing the file.
+func (g *Generator) ProcessFileContext(ctx context.Context, filename string) (*ProcessResult, error) {
+	var content string
+	err := g.retry.Do(ctx, func() (err error) {
+		content, err = readFileContext(ctx, filename)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file '%s': %w", filename, err)
 	}
 
-	return g.Process(string(content))
+	if g.codec != nil {
+		decoded, err := g.codec.Decode([]byte(content))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode file '%s': %w", filename, err)
+		}
+		content = string(decoded)
+	}
+
+	result, err := g.ProcessContext(ctx, content)
+	if err != nil {
+		return nil, core.WithFile(err, filename)
+	}
+	return result, nil
 }
 
-// createFromTemplateWithCustom renders the template using todos, dates, journal stats, and custom variables.
-func (g *Generator) createFromTemplateWithCustom(todosContent string, dateToUse string, journal *core.TodoJournal) (string, error) {
-	return core.CreateFromTemplate(core.TemplateOptions{
-		Content:      g.templateContent,
-		TodosContent: todosContent,
-		CurrentDate:  dateToUse,
-		PreviousDate: g.previousDate,
-		Journal:      journal,
-		CustomVars:   g.customVars,
+// readFileContext reads a whole file into a string, checking ctx between reads.
+// The content is read straight into the string's buffer to avoid a []byte to string copy.
+func readFileContext(ctx context.Context, filename string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	var content strings.Builder
+	if info, err := file.Stat(); err == nil {
+		content.Grow(int(info.Size()))
+	}
+	if _, err := io.Copy(&content, &contextReader{ctx: ctx, r: file}); err != nil {
+		return "", err
+	}
+	return content.String(), nil
+}
+
+// contextReader wraps an io.Reader and fails reads once its context is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
+
+// renderVars returns the custom variables templates are rendered with, the secret
+// ones masked. Templates using them are rejected, but not every way of reaching
+// them, such as printing the whole data with {{.}}, can be told from the template.
+func (g *Generator) renderVars() map[string]interface{} {
+	return core.MaskSecrets(g.customVars, g.secretVars)
+}
+
+// writeFromTemplateWithCustom renders the template using todos, dates, journal stats, custom variables
+// and the sections of the previous journal.
+func (g *Generator) writeFromTemplateWithCustom(w io.Writer, todosContent string, dateToUse string, journal *core.TodoJournal, previous *core.Document) error {
+	return core.WriteFromTemplate(w, core.TemplateOptions{
+		Content:       g.templateContent,
+		TodosContent:  todosContent,
+		CurrentDate:   dateToUse,
+		PreviousDate:  g.previousDate,
+		Journal:       journal,
+		CustomVars:    g.renderVars(),
+		Streaks:       g.streaks,
+		Sprig:         g.sprigFunctions,
+		Calendar:      g.calendar,
+		Notes:         g.notes,
+		Previous:      previous,
+		Deterministic: g.deterministic,
 	})
 }
 
@@ -154,13 +460,40 @@ func ExtractDateFromFrontmatter(content string, dateKey string) (string, error)
 	return core.ExtractDateFromFrontmatter(content, dateKey)
 }
 
+// templateFunctions returns the functions templates are rendered with.
+func (g *Generator) templateFunctions() template.FuncMap {
+	return core.TemplateFunctions(g.sprigFunctions)
+}
+
 // validateTemplate validates the template syntax to catch errors early
 func (g *Generator) validateTemplate() error {
 	// Try parsing the template with the same functions used during execution
-	_, err := template.New("validation").Funcs(core.CreateTemplateFunctions()).Parse(g.templateContent)
+	funcs := g.templateFunctions()
+	_, err := template.New("validation").Funcs(funcs).Parse(g.templateContent)
 	if err != nil {
 		return fmt.Errorf("invalid template syntax: %w", err)
 	}
+	if g.strictTemplates {
+		if err := core.CheckTemplateFunctions(g.templateContent, g.customVars, funcs); err != nil {
+			return fmt.Errorf("invalid template: %w", err)
+		}
+		for _, o := range g.outputs {
+			if o.Template == "" {
+				continue
+			}
+			if err := core.CheckTemplateFunctions(o.Template, g.customVars, funcs); err != nil {
+				return fmt.Errorf("invalid output %q: invalid template: %w", o.Name, err)
+			}
+		}
+	}
+	if err := core.CheckTemplateSecrets(g.templateContent, g.secretVars, funcs); err != nil {
+		return fmt.Errorf("invalid template: %w", err)
+	}
+	for _, o := range g.outputs {
+		if err := core.CheckTemplateSecrets(o.Template, g.secretVars, funcs); err != nil {
+			return fmt.Errorf("invalid output %q: invalid template: %w", o.Name, err)
+		}
+	}
 	return nil
 }
 
@@ -173,6 +506,28 @@ type options struct {
 	customVars         map[string]interface{}
 	frontmatterDateKey string
 	todosHeader        string
+	format             core.Format
+	collapseCarried    bool
+	annotateCarried    bool
+	maxCarry           int
+	cascadeComplete    bool
+	autoCompleteParent bool
+	days               core.DayRange
+	assignee           string
+	completionTime     time.Time
+	streaks            core.StreakStats
+	codec              Codec
+	outputs            []Output
+	strictTemplates    bool
+	secretVars         []string
+	sprigFunctions     bool
+	calendar           core.Calendar
+	notes              storage.Storage
+	deterministic      bool
+	movedMessage       string
+	omitMovedMessage   bool
+	retry              storage.RetryPolicy
+	versionStamp       string
 }
 
 // WithPreviousDate sets the previous journal date for the generator
@@ -196,10 +551,209 @@ func WithFrontmatterDateKey(key string) Option {
 	}
 }
 
-// WithTodosHeader sets the TODOS section header for the generator
+// WithTodosHeader sets the TODOS section header for the generator.
+// An empty header keeps the default core.TodosHeader.
 func WithTodosHeader(header string) Option {
 	return func(config *options) {
-		config.todosHeader = header
+		if header != "" {
+			config.todosHeader = header
+		}
+	}
+}
+
+// WithFormat sets the indentation the generator reads and writes TODOS sections in.
+// Without it, core.DefaultFormat is used.
+func WithFormat(format core.Format) Option {
+	return func(config *options) {
+		config.format = format
+	}
+}
+
+// WithCollapseCarried merges the uncompleted todos of all days into a single day
+// section under the template date instead of keeping their original days. With
+// annotate, each todo carried from an earlier day is annotated with that day's
+// date, e.g. "(from [[2024-01-14]])".
+func WithCollapseCarried(annotate bool) Option {
+	return func(config *options) {
+		config.collapseCarried = true
+		config.annotateCarried = annotate
+	}
+}
+
+// WithMaxCarry limits the new file to n top-level uncompleted todos, chosen by
+// core.LimitCarried. The todos left over are returned in ProcessResult.Overflow
+// by Process and ProcessFile; the other methods drop them. Zero carries all todos.
+func WithMaxCarry(n int) Option {
+	return func(config *options) {
+		config.maxCarry = n
+	}
+}
+
+// WithCascadeComplete treats the subitems of a completed todo as completed, so the
+// whole subtree is left in the processed journal instead of being carried. Without
+// it, a completed todo with open subitems is carried with them.
+func WithCascadeComplete() Option {
+	return func(config *options) {
+		config.cascadeComplete = true
+	}
+}
+
+// WithAutoCompleteParent completes todos whose subitems are all completed, so they
+// are left in the processed journal like todos checked off by hand.
+func WithAutoCompleteParent() Option {
+	return func(config *options) {
+		config.autoCompleteParent = true
+	}
+}
+
+// WithDayRange processes only the day sections with a date in r. The other sections
+// are left in the processed journal as they are and nothing is carried from them,
+// which keeps processing a journal with years of history in one file cheap.
+func WithDayRange(r core.DayRange) Option {
+	return func(config *options) {
+		config.days = r
+	}
+}
+
+// WithAssignee carries only the open todos assigned to name with a mention such
+// as @alice, see core.MatchAssignee. The open todos of others stay in the
+// processed journal, as if they had a keep directive, which lets each person of a
+// shared journal carry their own todos.
+func WithAssignee(name string) Option {
+	return func(config *options) {
+		config.assignee = name
+	}
+}
+
+// WithCompletionTime stamps the date tags added to todos completed on the day of at
+// with its time of day, e.g. "#2025-06-21T14:32", see core.CompletionStamp.
+func WithCompletionTime(at time.Time) Option {
+	return func(config *options) {
+		config.completionTime = at
+	}
+}
+
+// WithStreaks sets the completion streaks exposed to the template as CurrentStreak,
+// LongestStreak, WeeklyVelocity and CompletionHours. They describe the whole journal tree, which the
+// generator does not see, so callers compute them with core.CalculateStreaks.
+func WithStreaks(stats core.StreakStats) Option {
+	return func(config *options) {
+		config.streaks = stats
+	}
+}
+
+// WithStrictTemplates makes the generator check when it is created that the template,
+// and the templates of its outputs, only refer to fields of core.TemplateData and to
+// custom variables that exist, see core.CheckTemplate. Without it, a misspelled
+// field fails or prints "<no value>" only when the journal is rendered.
+func WithStrictTemplates() Option {
+	return func(config *options) {
+		config.strictTemplates = true
+	}
+}
+
+// WithSecretVariables rejects templates, including those of the outputs, that
+// use the custom variables named in names, such as API tokens, so that their
+// values do not end up in journals. See core.CheckTemplateSecrets.
+func WithSecretVariables(names ...string) Option {
+	return func(config *options) {
+		config.secretVars = names
+	}
+}
+
+// WithSprigFunctions makes the Sprig-compatible functions of core.TemplateFunctions,
+// such as toJson, ternary and now, available to the template and the templates of
+// the outputs. Functions of core.CreateTemplateFunctions keep their meaning when a
+// Sprig function has the same name.
+func WithSprigFunctions() Option {
+	return func(config *options) {
+		config.sprigFunctions = true
+	}
+}
+
+// WithCalendar sets the holidays the workday template functions nextWorkday,
+// prevWorkday and isWorkday skip besides weekends.
+func WithCalendar(calendar core.Calendar) Option {
+	return func(config *options) {
+		config.calendar = calendar
+	}
+}
+
+// WithNotes lets the template read the frontmatter of other notes in notes, the
+// journal tree, with noteField, e.g. {{noteField "projects/ProjectX.md" "status"}}.
+// Without it, noteField is empty.
+func WithNotes(notes storage.Storage) Option {
+	return func(config *options) {
+		config.notes = notes
+	}
+}
+
+// WithDeterministicTemplates seeds the random template functions shuffle and
+// shuffleLines with the template date, so rendering the template and the templates
+// of the outputs gives the same result every time for a date.
+func WithDeterministicTemplates() Option {
+	return func(config *options) {
+		config.deterministic = true
+	}
+}
+
+// WithMovedMessage sets the text left in the todos section of a processed journal
+// whose todos were all carried, instead of core.MovedToTemplate. An empty message
+// keeps the default.
+func WithMovedMessage(message string) Option {
+	return func(config *options) {
+		config.movedMessage = message
+	}
+}
+
+// WithoutMovedMessage leaves the todos section of a processed journal whose todos
+// were all carried empty.
+func WithoutMovedMessage() Option {
+	return func(config *options) {
+		config.omitMovedMessage = true
+	}
+}
+
+// Codec converts journal files between their stored and plain text form,
+// e.g. to decrypt journals when reading and encrypt them again when writing.
+type Codec interface {
+	// Decode converts stored file content into plain journal text.
+	Decode(data []byte) ([]byte, error)
+	// Encode converts plain journal text into its stored form.
+	Encode(data []byte) ([]byte, error)
+}
+
+// WithCodec sets the codec ProcessFile uses to decode the journal file it reads.
+// Callers writing the results back use the same codec's Encode.
+func WithCodec(codec Codec) Option {
+	return func(config *options) {
+		config.codec = codec
+	}
+}
+
+// WithRetry makes ProcessFile try reading its journal file again when it fails
+// with a transient error, as files on network filesystems do now and then.
+func WithRetry(policy storage.RetryPolicy) Option {
+	return func(config *options) {
+		config.retry = policy
+	}
+}
+
+// WithVersionStamp stamps the frontmatter of the new file with version as
+// core.VersionKey, e.g. "todoer_version: v1.4.0", so tools can tell which version
+// wrote a journal. New files without frontmatter are left alone.
+func WithVersionStamp(version string) Option {
+	return func(config *options) {
+		config.versionStamp = version
+	}
+}
+
+// WithOutputs sets extra artifacts Process renders from the processed journal next to
+// the new file, such as an archive of the completed todos. Each output renders its own
+// template with the todos it selects as {{.TODOS}}.
+func WithOutputs(outputs ...Output) Option {
+	return func(config *options) {
+		config.outputs = outputs
 	}
 }
 
@@ -208,8 +762,32 @@ func WithTodosHeader(header string) Option {
 func (g *Generator) WithOptions(opts ...Option) (*Generator, error) {
 	// Set up configuration with current values
 	config := &options{
-		previousDate: g.previousDate,
-		customVars:   g.customVars,
+		previousDate:       g.previousDate,
+		customVars:         g.customVars,
+		frontmatterDateKey: g.frontmatterDateKey,
+		todosHeader:        g.todosHeader,
+		format:             g.format,
+		collapseCarried:    g.collapseCarried,
+		annotateCarried:    g.annotateCarried,
+		maxCarry:           g.maxCarry,
+		cascadeComplete:    g.cascadeComplete,
+		autoCompleteParent: g.autoCompleteParent,
+		days:               g.days,
+		assignee:           g.assignee,
+		completionTime:     g.completionTime,
+		streaks:            g.streaks,
+		codec:              g.codec,
+		outputs:            g.outputs,
+		strictTemplates:    g.strictTemplates,
+		secretVars:         g.secretVars,
+		sprigFunctions:     g.sprigFunctions,
+		calendar:           g.calendar,
+		notes:              g.notes,
+		deterministic:      g.deterministic,
+		movedMessage:       g.movedMessage,
+		omitMovedMessage:   g.omitMovedMessage,
+		retry:              g.retry,
+		versionStamp:       g.versionStamp,
 	}
 
 	// Apply new options
@@ -224,6 +802,18 @@ func (g *Generator) WithOptions(opts ...Option) (*Generator, error) {
 		}
 	}
 
+	if err := config.format.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid format: %w", err)
+	}
+
+	if err := config.days.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid day range: %w", err)
+	}
+
+	if config.assignee != "" && !core.ValidAssignee(config.assignee) {
+		return nil, fmt.Errorf("invalid assignee %q", config.assignee)
+	}
+
 	// Create new generator with updated configuration
 	newGen := &Generator{
 		templateContent:    g.templateContent,
@@ -232,12 +822,37 @@ func (g *Generator) WithOptions(opts ...Option) (*Generator, error) {
 		customVars:         config.customVars,
 		frontmatterDateKey: config.frontmatterDateKey,
 		todosHeader:        config.todosHeader, // Always set
+		format:             config.format,
+		collapseCarried:    config.collapseCarried,
+		annotateCarried:    config.annotateCarried,
+		maxCarry:           config.maxCarry,
+		cascadeComplete:    config.cascadeComplete,
+		autoCompleteParent: config.autoCompleteParent,
+		days:               config.days,
+		assignee:           config.assignee,
+		completionTime:     config.completionTime,
+		streaks:            config.streaks,
+		codec:              config.codec,
+		outputs:            config.outputs,
+		strictTemplates:    config.strictTemplates,
+		secretVars:         config.secretVars,
+		sprigFunctions:     config.sprigFunctions,
+		calendar:           config.calendar,
+		notes:              config.notes,
+		deterministic:      config.deterministic,
+		movedMessage:       config.movedMessage,
+		omitMovedMessage:   config.omitMovedMessage,
+		retry:              config.retry,
+		versionStamp:       config.versionStamp,
 	}
 
 	// Validate template syntax (should pass since original was valid, but safety first)
 	if err := newGen.validateTemplate(); err != nil {
 		return nil, err
 	}
+	if err := validateOutputs(newGen.outputs, newGen.templateFunctions()); err != nil {
+		return nil, err
+	}
 
 	return newGen, nil
 }
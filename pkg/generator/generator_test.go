@@ -1,11 +1,16 @@
 package generator
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/inful/todoer/pkg/core"
 )
@@ -285,6 +290,94 @@ title: 2024-01-15
 	}
 }
 
+// TestGeneratorProcessContext tests cancellation and deadline handling of the context-aware methods
+func TestGeneratorProcessContext(t *testing.T) {
+	gen, err := NewGeneratorWithOptions("# {{.Date}}\n\n## Todos\n\n{{.TODOS}}\n", "2024-01-16")
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+
+	content := "---\ntitle: 2024-01-15\n---\n\n## Todos\n\n- [ ] Task 1\n- [x] Task 2"
+
+	tmpDir := t.TempDir()
+	inputFile := filepath.Join(tmpDir, "input.md")
+	if err := os.WriteFile(inputFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	t.Run("active context", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		if _, err := gen.ProcessContext(ctx, content); err != nil {
+			t.Errorf("ProcessContext() unexpected error: %v", err)
+		}
+		if _, err := gen.ProcessFileContext(ctx, inputFile); err != nil {
+			t.Errorf("ProcessFileContext() unexpected error: %v", err)
+		}
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if _, err := gen.ProcessContext(ctx, content); !errors.Is(err, context.Canceled) {
+			t.Errorf("ProcessContext() error = %v, want context.Canceled", err)
+		}
+		if _, err := gen.ProcessFileContext(ctx, inputFile); !errors.Is(err, context.Canceled) {
+			t.Errorf("ProcessFileContext() error = %v, want context.Canceled", err)
+		}
+	})
+
+	t.Run("expired deadline", func(t *testing.T) {
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+
+		if _, err := gen.ProcessContext(ctx, content); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("ProcessContext() error = %v, want context.DeadlineExceeded", err)
+		}
+		if _, err := gen.ProcessFileContext(ctx, inputFile); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("ProcessFileContext() error = %v, want context.DeadlineExceeded", err)
+		}
+	})
+}
+
+// TestGeneratorProcessTo tests that the output written by ProcessTo matches the ProcessResult
+func TestGeneratorProcessTo(t *testing.T) {
+	gen, err := NewGeneratorWithOptions("# {{.Date}}\n\n## Todos\n\n{{.TODOS}}\n", "2024-01-16")
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+
+	content := "---\ntitle: 2024-01-15\n---\n\n## Todos\n\n- [[2024-01-15]]\n  - [ ] Task 1\n  - [x] Task 2\n\n## Notes\n\nKeep me."
+
+	result, err := gen.Process(content)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	wantModified, _ := io.ReadAll(result.ModifiedOriginal)
+	wantNew, _ := io.ReadAll(result.NewFile)
+
+	var modified, newFile strings.Builder
+	stats, err := gen.ProcessTo(content, &modified, &newFile)
+	if err != nil {
+		t.Fatalf("ProcessTo() error = %v", err)
+	}
+
+	if modified.String() != string(wantModified) {
+		t.Errorf("ProcessTo() modified = %q, want %q", modified.String(), wantModified)
+	}
+	if newFile.String() != string(wantNew) {
+		t.Errorf("ProcessTo() new file = %q, want %q", newFile.String(), wantNew)
+	}
+	if stats.UncompletedTodos != 1 || stats.CompletedTodos != 1 {
+		t.Errorf("ProcessTo() stats = %+v, want 1 uncompleted and 1 completed", stats)
+	}
+	if !strings.Contains(modified.String(), "Keep me.") {
+		t.Error("ProcessTo() should preserve content after the TODOS section")
+	}
+}
+
 // TestGeneratorWithOptions tests the WithOptions method for reconfiguration
 func TestGeneratorWithOptions(t *testing.T) {
 	template := "# {{.Date}}\n{{if .PreviousDate}}Previous: {{.PreviousDate}}{{end}}\n{{.TODOS}}\n"
@@ -340,6 +433,222 @@ title: 2024-01-14
 	}
 }
 
+// reverseCodec is a test Codec that stores content byte-reversed
+type reverseCodec struct {
+	failDecode bool
+}
+
+func (c reverseCodec) Decode(data []byte) ([]byte, error) {
+	if c.failDecode {
+		return nil, errors.New("bad key")
+	}
+	return reverseBytes(data), nil
+}
+
+func (c reverseCodec) Encode(data []byte) ([]byte, error) {
+	return reverseBytes(data), nil
+}
+
+func reverseBytes(data []byte) []byte {
+	reversed := make([]byte, len(data))
+	for i, b := range data {
+		reversed[len(data)-1-i] = b
+	}
+	return reversed
+}
+
+// TestGeneratorWithCodec tests that ProcessFile decodes files through the configured codec
+func TestGeneratorWithCodec(t *testing.T) {
+	content := "---\ntitle: 2024-01-14\n---\n\n## Tasks\n\n- [[2024-01-14]]\n  - [ ] Secret task\n"
+	file := filepath.Join(t.TempDir(), "2024-01-14.md.rev")
+	if err := os.WriteFile(file, reverseBytes([]byte(content)), 0644); err != nil {
+		t.Fatalf("Failed to write encoded file: %v", err)
+	}
+
+	gen, err := NewGeneratorWithOptions("{{.TODOS}}", "2024-01-15", WithTodosHeader("## Tasks"))
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+	// WithOptions keeps earlier settings such as the TODOS header
+	gen, err = gen.WithOptions(WithCodec(reverseCodec{}))
+	if err != nil {
+		t.Fatalf("WithOptions() error = %v", err)
+	}
+
+	result, err := gen.ProcessFile(file)
+	if err != nil {
+		t.Fatalf("ProcessFile() error = %v", err)
+	}
+	newFile, err := io.ReadAll(result.NewFile)
+	if err != nil {
+		t.Fatalf("Failed to read new file: %v", err)
+	}
+	if !strings.Contains(string(newFile), "Secret task") {
+		t.Errorf("ProcessFile() did not decode the file, got:\n%s", newFile)
+	}
+
+	failing, err := gen.WithOptions(WithCodec(reverseCodec{failDecode: true}))
+	if err != nil {
+		t.Fatalf("WithOptions() error = %v", err)
+	}
+	if _, err := failing.ProcessFile(file); err == nil || !strings.Contains(err.Error(), "bad key") {
+		t.Errorf("ProcessFile() error = %v, want decode error", err)
+	}
+}
+
+// TestGeneratorWithFormat tests reading and writing TODOS sections with configured indentation
+func TestGeneratorWithFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		format core.Format
+		indent string
+	}{
+		{"four spaces", core.Format{IndentSpaces: 4}, "    "},
+		{"tabs", core.Format{IndentSpaces: 2, UseTabs: true}, "\t"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := func(depth int) string { return strings.Repeat(tt.indent, depth) }
+			content := "---\ntitle: 2024-01-14\n---\n\n## Todos\n\n" +
+				"- [[2024-01-14]]\n" +
+				in(1) + "- [ ] Parent\n" +
+				in(2) + "- Note\n" +
+				in(2) + "- [x] Done child\n" +
+				in(1) + "- [x] Finished\n"
+
+			gen, err := NewGeneratorWithOptions("{{.TODOS}}", "2024-01-15", WithFormat(tt.format))
+			if err != nil {
+				t.Fatalf("Failed to create generator: %v", err)
+			}
+			result, err := gen.Process(content)
+			if err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+
+			newFile, err := io.ReadAll(result.NewFile)
+			if err != nil {
+				t.Fatalf("Failed to read new file: %v", err)
+			}
+			wantNew := "- [[2024-01-14]]\n" +
+				in(1) + "- [ ] Parent created::[[2024-01-14]]\n" +
+				in(2) + "- Note\n" +
+				in(2) + "- [x] Done child #2024-01-14"
+			if string(newFile) != wantNew {
+				t.Errorf("new file = %q, want %q", newFile, wantNew)
+			}
+
+			original, err := io.ReadAll(result.ModifiedOriginal)
+			if err != nil {
+				t.Fatalf("Failed to read modified original: %v", err)
+			}
+			if want := in(1) + "- [x] Finished #2024-01-14"; !strings.Contains(string(original), want) {
+				t.Errorf("modified original missing %q, got:\n%s", want, original)
+			}
+		})
+	}
+
+	if _, err := NewGeneratorWithOptions("{{.TODOS}}", "2024-01-15", WithFormat(core.Format{IndentSpaces: 9})); err == nil {
+		t.Error("NewGeneratorWithOptions() accepted an indentation of 9 spaces")
+	}
+}
+
+// TestGeneratorWithCollapseCarried tests merging carried todos under the template date
+func TestGeneratorWithCollapseCarried(t *testing.T) {
+	content := "---\ntitle: 2024-01-14\n---\n\n## Todos\n\n" +
+		"- [[2024-01-10]]\n  - [ ] Old task\n  - [x] Done\n" +
+		"- [[2024-01-14]]\n  - [ ] Recent task\n"
+
+	gen, err := NewGeneratorWithOptions("{{.TODOS}}", "2024-01-15", WithCollapseCarried(true))
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+	result, err := gen.Process(content)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	newFile, err := io.ReadAll(result.NewFile)
+	if err != nil {
+		t.Fatalf("Failed to read new file: %v", err)
+	}
+	want := "- [[2024-01-15]]\n  - [ ] Old task created::[[2024-01-10]] (from [[2024-01-10]])\n  - [ ] Recent task created::[[2024-01-14]] (from [[2024-01-14]])"
+	if string(newFile) != want {
+		t.Errorf("new file = %q, want %q", newFile, want)
+	}
+	// Statistics still see the original days
+	if result.Stats.OldestTodoDate != "2024-01-10" {
+		t.Errorf("OldestTodoDate = %q, want 2024-01-10", result.Stats.OldestTodoDate)
+	}
+
+	// WithOptions can turn the annotations off
+	plain, err := gen.WithOptions(WithCollapseCarried(false))
+	if err != nil {
+		t.Fatalf("WithOptions() error = %v", err)
+	}
+	result, err = plain.Process(content)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	newFile, err = io.ReadAll(result.NewFile)
+	if err != nil {
+		t.Fatalf("Failed to read new file: %v", err)
+	}
+	want = "- [[2024-01-15]]\n  - [ ] Old task created::[[2024-01-10]]\n  - [ ] Recent task created::[[2024-01-14]]"
+	if string(newFile) != want {
+		t.Errorf("new file without annotations = %q, want %q", newFile, want)
+	}
+}
+
+// TestGeneratorWithMaxCarry tests that todos beyond the carry limit are returned as overflow
+func TestGeneratorWithMaxCarry(t *testing.T) {
+	content := "---\ntitle: 2024-01-14\n---\n\n## Todos\n\n" +
+		"- [[2024-01-10]]\n  - [ ] Old task\n  - [ ] Important [#A]\n" +
+		"- [[2024-01-14]]\n  - [ ] Recent task\n  - [x] Done\n"
+
+	gen, err := NewGeneratorWithOptions("{{.TODOS}}", "2024-01-15", WithMaxCarry(2))
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+	result, err := gen.Process(content)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	newFile, err := io.ReadAll(result.NewFile)
+	if err != nil {
+		t.Fatalf("Failed to read new file: %v", err)
+	}
+	want := "- [[2024-01-10]]\n  - [ ] Important [#A] created::[[2024-01-10]]\n- [[2024-01-14]]\n  - [ ] Recent task created::[[2024-01-14]]"
+	if string(newFile) != want {
+		t.Errorf("new file = %q, want %q", newFile, want)
+	}
+	if got, want := core.JournalToString(result.Overflow), "- [[2024-01-10]]\n  - [ ] Old task"; got != want {
+		t.Errorf("Overflow = %q, want %q", got, want)
+	}
+}
+
+// TestGeneratorWithStreaks tests that completion streaks reach the template
+func TestGeneratorWithStreaks(t *testing.T) {
+	content := "---\ntitle: 2024-01-14\n---\n\n## Todos\n\n- [ ] Task\n"
+	streaks := core.StreakStats{CurrentStreak: 3, LongestStreak: 10, WeeklyVelocity: 4.5}
+
+	gen, err := NewGeneratorWithOptions("{{.CurrentStreak}}/{{.LongestStreak}} {{printf \"%.1f\" .WeeklyVelocity}}", "2024-01-15", WithStreaks(streaks))
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+	result, err := gen.Process(content)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	newFile, err := io.ReadAll(result.NewFile)
+	if err != nil {
+		t.Fatalf("Failed to read new file: %v", err)
+	}
+	if string(newFile) != "3/10 4.5" {
+		t.Errorf("new file = %q, want %q", newFile, "3/10 4.5")
+	}
+}
+
 // TestGeneratorEdgeCases tests edge cases and error conditions
 func TestGeneratorEdgeCases(t *testing.T) {
 	template := "# {{.Date}}\n{{.TODOS}}\n"
@@ -533,3 +842,690 @@ title: 2024-01-15
 		}
 	}
 }
+
+// largeJournal builds a journal with the given number of days of todos
+func largeJournal(days int) string {
+	var builder strings.Builder
+	builder.WriteString("---\ntitle: 2024-01-15\n---\n\n# Daily Journal\n\n## Todos\n\n")
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for d := 0; d < days; d++ {
+		fmt.Fprintf(&builder, "- [[%s]]\n", start.AddDate(0, 0, d).Format(core.DateFormat))
+		for i := 0; i < 20; i++ {
+			fmt.Fprintf(&builder, "  - [ ] Open task %d with some descriptive text to pad the line\n", i)
+			fmt.Fprintf(&builder, "    - [x] Done subtask %d\n", i)
+			builder.WriteString("      Continuation line with notes\n")
+		}
+	}
+	builder.WriteString("\n## Notes\n\nSome notes.\n")
+	return builder.String()
+}
+
+// BenchmarkProcessLargeJournal reports allocations when buffering a multi-megabyte journal
+func BenchmarkProcessLargeJournal(b *testing.B) {
+	gen, err := NewGeneratorWithOptions("# {{.Date}}\n\n## Todos\n\n{{.TODOS}}\n", "2024-01-16")
+	if err != nil {
+		b.Fatalf("Failed to create generator: %v", err)
+	}
+	content := largeJournal(500)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(content)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		result, err := gen.Process(content)
+		if err != nil {
+			b.Fatalf("Processing failed: %v", err)
+		}
+		if _, err := io.Copy(io.Discard, result.ModifiedOriginal); err != nil {
+			b.Fatalf("Failed to read modified original: %v", err)
+		}
+		if _, err := io.Copy(io.Discard, result.NewFile); err != nil {
+			b.Fatalf("Failed to read new file: %v", err)
+		}
+	}
+}
+
+// BenchmarkPipeline benchmarks processing whole journals of growing size, from
+// the frontmatter to the rendered template
+func BenchmarkPipeline(b *testing.B) {
+	gen, err := NewGeneratorWithOptions("# {{.Date}}\n\n## Todos\n\n{{.TODOS}}\n\n{{.TotalTodos}} todos\n", "2024-01-16")
+	if err != nil {
+		b.Fatalf("Failed to create generator: %v", err)
+	}
+	for _, corpus := range []struct {
+		name string
+		days int
+	}{{"small", 1}, {"medium", 30}, {"huge", 1000}} {
+		content := largeJournal(corpus.days)
+		b.Run(corpus.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(content)))
+			for i := 0; i < b.N; i++ {
+				if _, err := gen.ProcessTo(content, io.Discard, io.Discard); err != nil {
+					b.Fatalf("Processing failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestGeneratorProcessFile_JournalError(t *testing.T) {
+	inputFile := filepath.Join(t.TempDir(), "2024-01-15.md")
+	content := "---\ntitle: 2024-01-15\n---\n\n## Todos\n\n- [[2024-01-15]]\n  - [ ] Task\n- [[2024-01-32]]\n"
+	if err := os.WriteFile(inputFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write input file: %v", err)
+	}
+
+	gen, err := NewGeneratorWithOptions("## Todos\n\n{{.TODOS}}\n", "2024-01-16")
+	if err != nil {
+		t.Fatalf("NewGeneratorWithOptions() error = %v", err)
+	}
+	_, err = gen.ProcessFile(inputFile)
+	var jerr *core.JournalError
+	if !errors.As(err, &jerr) || !errors.Is(err, core.KindDate) {
+		t.Fatalf("ProcessFile() error = %v, want a *core.JournalError", err)
+	}
+	if jerr.File != inputFile || jerr.Line != 9 || jerr.Col != 1 {
+		t.Errorf("JournalError = %+v, want %s line 9, column 1", jerr, inputFile)
+	}
+}
+
+func TestGeneratorProcess_Warnings(t *testing.T) {
+	gen, err := NewGeneratorWithOptions("## Todos\n\n{{.TODOS}}\n", "2024-01-16")
+	if err != nil {
+		t.Fatalf("NewGeneratorWithOptions() error = %v", err)
+	}
+	content := "---\ntitle: 2024-01-15\n---\n\n## Todos\n\n- [[2024-01-15]]\n  - [ ] Task\n- [[2024-01-15]]\n  - [ ] Other\n"
+	result, err := gen.Process(content)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	want := []Warning{{Line: 9, Kind: core.WarnDuplicateDay, Message: "day 2024-01-15 appears more than once"}}
+	if !reflect.DeepEqual(result.Warnings, want) {
+		t.Errorf("Warnings = %+v, want %+v", result.Warnings, want)
+	}
+}
+
+func TestGeneratorProcess_Outputs(t *testing.T) {
+	outputs := []Output{
+		{Name: "archive", Template: "# Done {{.Date}}\n\n{{.TODOS}}\n", Select: SelectCompleted},
+		{Name: "open", Select: SelectOpen},
+		{Name: "stale", Select: SelectStale, StaleDays: 7},
+	}
+	gen, err := NewGeneratorWithOptions("## Todos\n\n{{.TODOS}}\n", "2024-01-16", WithOutputs(outputs...))
+	if err != nil {
+		t.Fatalf("NewGeneratorWithOptions() error = %v", err)
+	}
+	content := "---\ntitle: 2024-01-15\n---\n\n## Todos\n\n- [[2024-01-02]]\n  - [ ] Old task\n- [[2024-01-15]]\n  - [ ] New task\n  - [x] Done task\n"
+	result, err := gen.Process(content)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	want := []struct {
+		name    string
+		content string
+		todos   int
+	}{
+		{"archive", "# Done 2024-01-16\n\n- [[2024-01-15]]\n  - [x] Done task #2024-01-15\n", 1},
+		{"open", "- [[2024-01-02]]\n  - [ ] Old task created::[[2024-01-02]]\n- [[2024-01-15]]\n  - [ ] New task created::[[2024-01-15]]\n", 2},
+		{"stale", "- [[2024-01-02]]\n  - [ ] Old task created::[[2024-01-02]]\n", 1},
+	}
+	if len(result.Outputs) != len(want) {
+		t.Fatalf("Outputs = %d, want %d", len(result.Outputs), len(want))
+	}
+	for i, w := range want {
+		got := result.Outputs[i]
+		data, _ := io.ReadAll(got.Content)
+		if got.Name != w.name || string(data) != w.content || got.Todos != w.todos {
+			t.Errorf("Outputs[%d] = %s %q (%d todos), want %s %q (%d todos)", i, got.Name, data, got.Todos, w.name, w.content, w.todos)
+		}
+	}
+
+	invalid := [][]Output{
+		{{Name: "", Select: SelectOpen}},
+		{{Name: "a", Select: SelectOpen}, {Name: "a", Select: SelectCompleted}},
+		{{Name: "a", Select: "overdue"}},
+		{{Name: "a", Select: SelectStale}},
+		{{Name: "a", Select: SelectOpen, Template: "{{.TODOS"}},
+	}
+	for _, outputs := range invalid {
+		if _, err := NewGeneratorWithOptions("{{.TODOS}}", "2024-01-16", WithOutputs(outputs...)); err == nil {
+			t.Errorf("NewGeneratorWithOptions(WithOutputs(%+v)) expected error", outputs)
+		}
+	}
+}
+
+func TestGeneratorAssignee(t *testing.T) {
+	outputs := []Output{{Name: "mine", Select: SelectOpen, SplitByAssignee: true}}
+	gen, err := NewGeneratorWithOptions("## Todos\n\n{{.TODOS}}\n", "2024-01-16", WithOutputs(outputs...))
+	if err != nil {
+		t.Fatalf("NewGeneratorWithOptions() error = %v", err)
+	}
+	content := "---\ntitle: 2024-01-15\n---\n\n## Todos\n\n- [[2024-01-15]]\n  - [ ] Review PR @alice\n  - [ ] Deploy @bob @alice\n  - [ ] Unassigned\n"
+	result, err := gen.Process(content)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	want := map[string]string{
+		"alice": "- [[2024-01-15]]\n  - [ ] Review PR @alice created::[[2024-01-15]]\n  - [ ] Deploy @bob @alice created::[[2024-01-15]]\n",
+		"bob":   "- [[2024-01-15]]\n  - [ ] Deploy @bob @alice created::[[2024-01-15]]\n",
+	}
+	if len(result.Outputs) != len(want) {
+		t.Fatalf("Outputs = %d, want %d", len(result.Outputs), len(want))
+	}
+	for _, got := range result.Outputs {
+		data, _ := io.ReadAll(got.Content)
+		if got.Name != "mine" || string(data) != want[got.Assignee] {
+			t.Errorf("output of %q = %s %q, want mine %q", got.Assignee, got.Name, data, want[got.Assignee])
+		}
+	}
+
+	gen, err = gen.WithOptions(WithAssignee("@alice"))
+	if err != nil {
+		t.Fatalf("WithOptions(WithAssignee()) error = %v", err)
+	}
+	result, err = gen.Process(content)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	newFile, _ := io.ReadAll(result.NewFile)
+	if strings.Contains(string(newFile), "Unassigned") || !strings.Contains(string(newFile), "Review PR @alice") {
+		t.Errorf("new file carries the todos of others:\n%s", newFile)
+	}
+	modified, _ := io.ReadAll(result.ModifiedOriginal)
+	if !strings.Contains(string(modified), "  - [ ] Unassigned") || strings.Contains(string(modified), "Review PR") {
+		t.Errorf("processed journal does not keep the todos of others:\n%s", modified)
+	}
+
+	if _, err := gen.WithOptions(WithAssignee("bob smith")); err == nil {
+		t.Error("WithOptions(WithAssignee(\"bob smith\")) expected error")
+	}
+}
+
+func TestGeneratorPreviousSection(t *testing.T) {
+	gen, err := NewGeneratorWithOptions("## Yesterday\n\n{{.PreviousSection \"## Notes\"}}\n\n## Todos\n\n{{.TODOS}}\n", "2024-01-16")
+	if err != nil {
+		t.Fatalf("NewGeneratorWithOptions() error = %v", err)
+	}
+	content := "---\ntitle: 2024-01-15\n---\n\n## Todos\n\n- [ ] Open task\n\n## Notes\n\nShipped the release.\n"
+	result, err := gen.Process(content)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	newFile, _ := io.ReadAll(result.NewFile)
+	if !strings.HasPrefix(string(newFile), "## Yesterday\n\nShipped the release.\n\n## Todos") {
+		t.Errorf("new file does not quote the previous notes:\n%s", newFile)
+	}
+}
+
+func TestGeneratorStrictTemplates(t *testing.T) {
+	template := "# {{.Datee}}\n\n## Todos\n\n{{.TODOS}}\n"
+	if _, err := NewGeneratorWithOptions(template, "2024-01-16"); err != nil {
+		t.Fatalf("NewGeneratorWithOptions() without strict templates error = %v", err)
+	}
+
+	_, err := NewGeneratorWithOptions(template, "2024-01-16", WithStrictTemplates())
+	var fieldErr *core.TemplateFieldError
+	if !errors.As(err, &fieldErr) {
+		t.Fatalf("NewGeneratorWithOptions() error = %v, want a TemplateFieldError", err)
+	}
+	if len(fieldErr.Fields) != 1 || fieldErr.Fields[0].Line != 1 || fieldErr.Fields[0].Suggestion != ".Date" {
+		t.Errorf("Fields = %+v", fieldErr.Fields)
+	}
+
+	custom := map[string]interface{}{"Project": "todoer"}
+	if _, err := NewGeneratorWithOptions("{{.Custom.Project}} {{.TODOS}}", "2024-01-16", WithCustomVariables(custom), WithStrictTemplates()); err != nil {
+		t.Errorf("NewGeneratorWithOptions() with known custom variable error = %v", err)
+	}
+
+	gen, err := NewGeneratorWithOptions("{{.TODOS}}", "2024-01-16", WithStrictTemplates())
+	if err != nil {
+		t.Fatalf("NewGeneratorWithOptions() error = %v", err)
+	}
+	if _, err := gen.WithOptions(WithOutputs(Output{Name: "archive", Template: "{{.TODO}}", Select: SelectCompleted})); err == nil || !strings.Contains(err.Error(), `output "archive"`) {
+		t.Errorf("WithOptions() with unknown field in output error = %v", err)
+	}
+}
+
+func TestGeneratorSecretVariables(t *testing.T) {
+	custom := map[string]interface{}{"Project": "todoer", "Token": "s3cr3t"}
+	template := "# {{.Custom.Project}} {{.Custom.Token}}\n\n## Todos\n\n{{.TODOS}}\n"
+	if _, err := NewGeneratorWithOptions(template, "2024-01-16", WithCustomVariables(custom)); err != nil {
+		t.Fatalf("NewGeneratorWithOptions() without secret variables error = %v", err)
+	}
+
+	_, err := NewGeneratorWithOptions(template, "2024-01-16", WithCustomVariables(custom), WithSecretVariables("Token"))
+	if !errors.Is(err, core.ErrSecretVariable) {
+		t.Fatalf("NewGeneratorWithOptions() error = %v, want ErrSecretVariable", err)
+	}
+
+	gen, err := NewGeneratorWithOptions("{{.Custom.Project}} {{.TODOS}}", "2024-01-16", WithCustomVariables(custom), WithSecretVariables("Token"))
+	if err != nil {
+		t.Fatalf("NewGeneratorWithOptions() error = %v", err)
+	}
+	if _, err := gen.WithOptions(WithOutputs(Output{Name: "share", Template: "{{.Custom.Token}}", Select: SelectCompleted})); !errors.Is(err, core.ErrSecretVariable) || !strings.Contains(err.Error(), `output "share"`) {
+		t.Errorf("WithOptions() with secret variable in output error = %v", err)
+	}
+
+	// The whole data exposes the variables without naming them
+	gen, err = NewGeneratorWithOptions("{{.}}\n{{.TODOS}}", "2024-01-16", WithCustomVariables(custom), WithSecretVariables("Token"),
+		WithOutputs(Output{Name: "share", Template: "{{.}}", Select: SelectCompleted}))
+	if err != nil {
+		t.Fatalf("NewGeneratorWithOptions() error = %v", err)
+	}
+	result, err := gen.Process("# 2024-01-15\n\n## Todos\n\n- [[2024-01-15]]\n  - [ ] Open\n  - [x] Done\n")
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	newFile, _ := io.ReadAll(result.NewFile)
+	output, _ := io.ReadAll(result.Outputs[0].Content)
+	for _, content := range []string{string(newFile), string(output)} {
+		if strings.Contains(content, "s3cr3t") || !strings.Contains(content, "todoer") {
+			t.Errorf("rendered {{.}} = %q, want the secret masked", content)
+		}
+	}
+}
+
+func TestGeneratorSprigFunctions(t *testing.T) {
+	template := "# {{.Date | trimPrefix \"2024-\"}}\n\n## Todos\n\n{{.TODOS}}\n"
+	if _, err := NewGeneratorWithOptions(template, "2024-01-16"); err == nil {
+		t.Fatal("NewGeneratorWithOptions() accepted a Sprig function without WithSprigFunctions")
+	}
+
+	output := Output{Name: "archive", Template: "{{toJson .Date}}", Select: SelectCompleted}
+	gen, err := NewGeneratorWithOptions(template, "2024-01-16", WithSprigFunctions(), WithStrictTemplates(), WithOutputs(output))
+	if err != nil {
+		t.Fatalf("NewGeneratorWithOptions() error = %v", err)
+	}
+	result, err := gen.Process("# 2024-01-15\n\n## Todos\n\n- [[2024-01-15]]\n  - [ ] Open\n  - [x] Done\n")
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	newFile, _ := io.ReadAll(result.NewFile)
+	if !strings.HasPrefix(string(newFile), "# 01-16\n") {
+		t.Errorf("new file = %q", newFile)
+	}
+	if len(result.Outputs) != 1 {
+		t.Fatalf("Outputs = %+v", result.Outputs)
+	}
+	archive, _ := io.ReadAll(result.Outputs[0].Content)
+	if string(archive) != `"2024-01-16"` {
+		t.Errorf("archive = %q", archive)
+	}
+
+	// Reconfiguring keeps the Sprig functions
+	if _, err := gen.WithOptions(WithMaxCarry(1)); err != nil {
+		t.Errorf("WithOptions() error = %v", err)
+	}
+}
+
+// BenchmarkProcessToLargeJournal reports allocations when processing a multi-megabyte
+// journal to writers; the new file is rendered in memory, the modified original is not
+func BenchmarkProcessToLargeJournal(b *testing.B) {
+	gen, err := NewGeneratorWithOptions("# {{.Date}}\n\n## Todos\n\n{{.TODOS}}\n", "2024-01-16")
+	if err != nil {
+		b.Fatalf("Failed to create generator: %v", err)
+	}
+	content := largeJournal(500)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(content)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := gen.ProcessTo(content, io.Discard, io.Discard); err != nil {
+			b.Fatalf("Processing failed: %v", err)
+		}
+	}
+}
+
+func TestGeneratorCalendar(t *testing.T) {
+	calendar, err := core.NewCalendar("2024-01-19")
+	if err != nil {
+		t.Fatalf("NewCalendar() error = %v", err)
+	}
+	template := "# {{.Date}}\n\nNext: {{nextWorkday .Date}}\n\n## Todos\n\n{{.TODOS}}\n"
+	gen, err := NewGeneratorWithOptions(template, "2024-01-18", WithCalendar(calendar))
+	if err != nil {
+		t.Fatalf("NewGeneratorWithOptions() error = %v", err)
+	}
+	result, err := gen.Process("# 2024-01-17\n\n## Todos\n\n- [[2024-01-17]]\n  - [ ] Open\n")
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	newFile, _ := io.ReadAll(result.NewFile)
+	if !strings.Contains(string(newFile), "Next: 2024-01-22\n") {
+		t.Errorf("new file = %q, want the holiday and weekend skipped", newFile)
+	}
+}
+
+// TestGeneratorCompletionRules tests that the completion rules reach the splitter
+func TestGeneratorCompletionRules(t *testing.T) {
+	content := "---\ntitle: 2024-01-14\n---\n\n## Todos\n\n" +
+		"- [[2024-01-14]]\n  - [x] Checked parent\n    - [ ] Open child\n  - [ ] Open parent\n    - [x] Done child\n"
+
+	gen, err := NewGeneratorWithOptions("{{.TODOS}}", "2024-01-15", WithCascadeComplete(), WithAutoCompleteParent())
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+	result, err := gen.Process(content)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	newFile, err := io.ReadAll(result.NewFile)
+	if err != nil {
+		t.Fatalf("Failed to read new file: %v", err)
+	}
+	if len(newFile) != 0 {
+		t.Errorf("new file = %q, want nothing carried", newFile)
+	}
+	modified, err := io.ReadAll(result.ModifiedOriginal)
+	if err != nil {
+		t.Fatalf("Failed to read modified original: %v", err)
+	}
+	for _, want := range []string{"  - [x] Checked parent #2024-01-14\n    - [x] Open child #2024-01-14", "  - [x] Open parent #2024-01-14\n    - [x] Done child #2024-01-14"} {
+		if !strings.Contains(string(modified), want) {
+			t.Errorf("modified original = %q, want it to contain %q", modified, want)
+		}
+	}
+}
+
+// TestGeneratorWithDayRange tests that day sections outside the range are left alone
+func TestGeneratorWithDayRange(t *testing.T) {
+	content := "---\ntitle: 2024-01-14\n---\n\n## Todos\n\n" +
+		"- [[2023-01-10]]\n  - [ ] Old task\n- [[2024-01-14]]\n  - [ ] Recent task\n"
+
+	gen, err := NewGeneratorWithOptions("{{.TODOS}}", "2024-01-15", WithDayRange(core.DayRange{From: "2024-01-01"}))
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+	result, err := gen.Process(content)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	newFile, _ := io.ReadAll(result.NewFile)
+	if want := "- [[2024-01-14]]\n  - [ ] Recent task created::[[2024-01-14]]"; string(newFile) != want {
+		t.Errorf("new file = %q, want %q", newFile, want)
+	}
+	modified, _ := io.ReadAll(result.ModifiedOriginal)
+	if !strings.Contains(string(modified), "- [[2023-01-10]]\n  - [ ] Old task") {
+		t.Errorf("modified original = %q, want the old section kept", modified)
+	}
+
+	if _, err := NewGeneratorWithOptions("{{.TODOS}}", "2024-01-15", WithDayRange(core.DayRange{From: "2024-02-01", To: "2024-01-01"})); err == nil {
+		t.Error("NewGeneratorWithOptions() accepted a reversed day range")
+	}
+}
+
+// TestGeneratorDeterministicTemplates tests that shuffling is reproducible per date
+func TestGeneratorDeterministicTemplates(t *testing.T) {
+	template := `{{shuffle "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight"}}`
+	render := func() string {
+		gen, err := NewGeneratorWithOptions(template, "2024-01-15", WithDeterministicTemplates())
+		if err != nil {
+			t.Fatalf("Failed to create generator: %v", err)
+		}
+		result, err := gen.Process("## Todos\n\n")
+		if err != nil {
+			t.Fatalf("Process() error = %v", err)
+		}
+		newFile, err := io.ReadAll(result.NewFile)
+		if err != nil {
+			t.Fatalf("Failed to read new file: %v", err)
+		}
+		return string(newFile)
+	}
+
+	first := render()
+	for i := 0; i < 5; i++ {
+		if got := render(); got != first {
+			t.Fatalf("new file = %q, then %q for the same date", first, got)
+		}
+	}
+}
+
+// TestGeneratorMovedMessage tests the text left in a journal whose todos were all carried
+func TestGeneratorMovedMessage(t *testing.T) {
+	content := "---\ntitle: 2024-01-14\n---\n\n## Todos\n\n- [[2024-01-14]]\n  - [ ] Open task\n\n## Notes\n"
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"default", nil, "## Todos\n\nMoved to [[2024-01-15]]\n\n## Notes\n"},
+		{"custom", []Option{WithMovedMessage("→ continued in [[2024/01/2024-01-15|Monday]]")}, "## Todos\n\n→ continued in [[2024/01/2024-01-15|Monday]]\n\n## Notes\n"},
+		{"omitted", []Option{WithoutMovedMessage()}, "## Todos\n\n## Notes\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gen, err := NewGeneratorWithOptions("{{.TODOS}}", "2024-01-15", tt.opts...)
+			if err != nil {
+				t.Fatalf("Failed to create generator: %v", err)
+			}
+			result, err := gen.Process(content)
+			if err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+			modified, err := io.ReadAll(result.ModifiedOriginal)
+			if err != nil {
+				t.Fatalf("Failed to read modified original: %v", err)
+			}
+			if got := strings.TrimPrefix(string(modified), "---\ntitle: 2024-01-14\n---\n\n"); got != tt.want {
+				t.Errorf("modified original = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestWithTodosHeaderEmpty tests that an empty header, as an unset configuration
+// passes it, keeps the default header instead of matching at the start of every file
+func TestWithTodosHeaderEmpty(t *testing.T) {
+	gen, err := NewGeneratorWithOptions("{{.TODOS}}", "2024-01-15", WithTodosHeader(""))
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+	if gen.todosHeader != core.TodosHeader {
+		t.Errorf("todosHeader = %q, want %q", gen.todosHeader, core.TodosHeader)
+	}
+	result, err := gen.Process("---\ntitle: 2024-01-14\n---\n\n## Todos\n\n- [[2024-01-14]]\n  - [ ] Open task\n")
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	newFile, err := io.ReadAll(result.NewFile)
+	if err != nil {
+		t.Fatalf("Failed to read new file: %v", err)
+	}
+	if !strings.Contains(string(newFile), "- [ ] Open task") {
+		t.Errorf("new file = %q, want the open task carried", newFile)
+	}
+}
+
+// TestGeneratorEmptySectionBeforeNextSection tests the moved message written into
+// an empty todos section directly followed by the next section
+func TestGeneratorEmptySectionBeforeNextSection(t *testing.T) {
+	gen, err := NewGeneratorWithOptions("{{.TODOS}}", "2024-01-15")
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+	result, err := gen.Process("---\ntitle: 2024-01-14\n---\n\n## Todos\n\n## Notes\n")
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	modified, err := io.ReadAll(result.ModifiedOriginal)
+	if err != nil {
+		t.Fatalf("Failed to read modified original: %v", err)
+	}
+	if want := "---\ntitle: 2024-01-14\n---\n\n## Todos\n\nMoved to [[2024-01-15]]\n\n## Notes\n"; string(modified) != want {
+		t.Errorf("modified original = %q, want %q", modified, want)
+	}
+}
+
+func TestGeneratorVersionStamp(t *testing.T) {
+	content := "---\ntitle: 2024-01-14\n---\n\n## Todos\n\n- [[2024-01-14]]\n  - [ ] Open task\n"
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"added", "---\ntitle: {{.Date}}\n---\n\n{{.TODOS}}\n", "---\ntitle: 2024-01-15\ntodoer_version: v1.4.0\n---\n\n"},
+		{"replaced", "---\ntitle: {{.Date}}\ntodoer_version: v0.9.0\n---\n\n{{.TODOS}}\n", "---\ntitle: 2024-01-15\ntodoer_version: v1.4.0\n---\n\n"},
+		{"no frontmatter", "# {{.Date}}\n\n{{.TODOS}}\n", "# 2024-01-15\n\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gen, err := NewGeneratorWithOptions(tt.template, "2024-01-15", WithVersionStamp("v1.4.0"))
+			if err != nil {
+				t.Fatalf("Failed to create generator: %v", err)
+			}
+			result, err := gen.Process(content)
+			if err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+			newFile, err := io.ReadAll(result.NewFile)
+			if err != nil {
+				t.Fatalf("Failed to read new file: %v", err)
+			}
+			if !strings.HasPrefix(string(newFile), tt.want) || !strings.Contains(string(newFile), "- [ ] Open task") {
+				t.Errorf("new file = %q, want it to start with %q", newFile, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeneratorDuplicateTodosSections(t *testing.T) {
+	content := "## Todos\n\n- [[2024-01-14]]\n  - [ ] Open task\n"
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{"empty literal section", "## Todos\n\n## Notes\n\n## Todos\n\n{{.TODOS}}\n", "## Todos\n\n- [[2024-01-14]]\n  - [ ] Open task created::[[2024-01-14]]\n\n## Notes\n", false},
+		{"repeated todos", "## Todos\n\n{{.TODOS}}\n\n## Todos\n\n{{.TODOS}}\n", "## Todos\n\n- [[2024-01-14]]\n  - [ ] Open task created::[[2024-01-14]]\n", false},
+		{"different todos", "## Todos\n\n- [ ] Fixed task\n\n## Todos\n\n{{.TODOS}}\n", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gen, err := NewGeneratorWithOptions(tt.template, "2024-01-15")
+			if err != nil {
+				t.Fatalf("Failed to create generator: %v", err)
+			}
+			result, err := gen.Process(content)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Process() expected error for different todos sections")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+			output, err := io.ReadAll(result.NewFile)
+			if err != nil {
+				t.Fatalf("Failed to read new file: %v", err)
+			}
+			if string(output) != tt.want {
+				t.Errorf("new file = %q, want %q", output, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeneratorHeaderlessTodos(t *testing.T) {
+	content := "---\ntitle: 2024-01-14\n---\n\n## Todos\n\n- [x] Done task\n- [ ] Open task\n  - [ ] Subtask\n\n## Notes\n"
+	gen, err := NewGeneratorWithOptions("## Todos\n\n{{.TODOS}}\n", "2024-01-15")
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+	result, err := gen.Process(content)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	modified, err := io.ReadAll(result.ModifiedOriginal)
+	if err != nil {
+		t.Fatalf("Failed to read modified original: %v", err)
+	}
+	if want := "---\ntitle: 2024-01-14\n---\n\n## Todos\n\n- [x] Done task #2024-01-14\n\n## Notes\n"; string(modified) != want {
+		t.Errorf("modified original = %q, want %q", modified, want)
+	}
+
+	output, err := io.ReadAll(result.NewFile)
+	if err != nil {
+		t.Fatalf("Failed to read new file: %v", err)
+	}
+	if want := "## Todos\n\n- [ ] Open task created::[[2024-01-14]]\n  - [ ] Subtask\n"; string(output) != want {
+		t.Errorf("new file = %q, want %q", output, want)
+	}
+}
+
+func TestGeneratorCarriesFootnotes(t *testing.T) {
+	content := "## Todos\n\n- [[2024-01-14]]\n  - [ ] Read the paper[^paper]\n  - [x] Done with a note[^done]\n\n## Notes\n\nSee [^other].\n\n[^paper]: Smith et al., 2023\n    with an indented second line\n[^done]: Only for the done task\n[^other]: Not referenced by todos\n"
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"appended", "## Todos\n\n{{.TODOS}}\n", "## Todos\n\n- [[2024-01-14]]\n  - [ ] Read the paper[^paper] created::[[2024-01-14]]\n\n[^paper]: Smith et al., 2023\n    with an indented second line\n"},
+		{"already defined", "## Todos\n\n{{.TODOS}}\n\n[^paper]: From the template\n", "## Todos\n\n- [[2024-01-14]]\n  - [ ] Read the paper[^paper] created::[[2024-01-14]]\n\n[^paper]: From the template\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkCarriedFootnotes(t, tt.template, content, tt.want)
+		})
+	}
+
+	// Footnotes may also follow the todos when they are the last section
+	checkCarriedFootnotes(t, "## Todos\n\n{{.TODOS}}\n", "## Todos\n\n- [[2024-01-14]]\n  - [ ] Read the paper[^paper]\n\n[^paper]: Smith et al., 2023\n",
+		"## Todos\n\n- [[2024-01-14]]\n  - [ ] Read the paper[^paper] created::[[2024-01-14]]\n\n[^paper]: Smith et al., 2023\n")
+}
+
+// checkCarriedFootnotes processes content with template and checks the new file is
+// want and the footnotes of the original are kept.
+func checkCarriedFootnotes(t *testing.T, template, content, want string) {
+	t.Helper()
+	gen, err := NewGeneratorWithOptions(template, "2024-01-15")
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+	result, err := gen.Process(content)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	output, err := io.ReadAll(result.NewFile)
+	if err != nil {
+		t.Fatalf("Failed to read new file: %v", err)
+	}
+	if string(output) != want {
+		t.Errorf("new file = %q, want %q", output, want)
+	}
+	modified, err := io.ReadAll(result.ModifiedOriginal)
+	if err != nil {
+		t.Fatalf("Failed to read modified original: %v", err)
+	}
+	if !strings.Contains(string(modified), "[^paper]: Smith et al., 2023") {
+		t.Errorf("modified original lost the footnote of the carried todo:\n%s", modified)
+	}
+}
+
+func TestGeneratorAnnotateCreated(t *testing.T) {
+	outputs := []Output{{Name: "archive", Select: SelectCompleted, AnnotateCreated: true}}
+	gen, err := NewGeneratorWithOptions("## Todos\n\n{{.TODOS}}\n", "2024-01-16", WithOutputs(outputs...))
+	if err != nil {
+		t.Fatalf("NewGeneratorWithOptions() error = %v", err)
+	}
+	content := "---\ntitle: 2024-01-15\n---\n\n## Todos\n\n- [[2024-01-12]]\n  - [x] Pay rent #2024-01-15\n  - [ ] Call bank\n"
+	result, err := gen.Process(content)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	data, _ := io.ReadAll(result.Outputs[0].Content)
+	if want := "- [[2024-01-12]]\n  - [x] Pay rent #2024-01-15 created::[[2024-01-12]]\n"; string(data) != want {
+		t.Errorf("archive output = %q, want %q", data, want)
+	}
+	modified, _ := io.ReadAll(result.ModifiedOriginal)
+	if strings.Contains(string(modified), "created::") {
+		t.Errorf("processed journal was annotated:\n%s", modified)
+	}
+}
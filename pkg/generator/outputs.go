@@ -0,0 +1,157 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"text/template"
+
+	"github.com/inful/todoer/pkg/core"
+)
+
+// Selection chooses the todos of a processed journal that an Output renders.
+type Selection string
+
+// Selections of todos for outputs
+const (
+	SelectOpen      Selection = "open"      // Uncompleted todos carried to the new journal
+	SelectCompleted Selection = "completed" // Completed todos left in the processed journal
+	SelectStale     Selection = "stale"     // Carried todos open for at least Output.StaleDays days
+)
+
+// DefaultOutputTemplate renders only the selected todos. It is used by outputs
+// without a template of their own.
+const DefaultOutputTemplate = "{{.TODOS}}\n"
+
+// Output is an extra artifact rendered from a processed journal, see WithOutputs.
+type Output struct {
+	Name      string    // Identifies the output in ProcessResult.Outputs
+	Template  string    // Template content; empty for DefaultOutputTemplate
+	Select    Selection // Todos passed to the template
+	StaleDays int       // Minimum age of stale todos in days, for SelectStale
+
+	// Render a result per person the selected todos are assigned to, with only
+	// their todos, see core.Assignees. Unassigned todos are left out.
+	SplitByAssignee bool
+
+	// Annotate the selected top-level todos with the date they were written down,
+	// as created::[[2025-06-18]], see core.AnnotateCreated. The journals are not
+	// changed.
+	AnnotateCreated bool
+}
+
+// OutputResult is the rendered content of an Output.
+type OutputResult struct {
+	Name     string    // Name of the output
+	Assignee string    // Person the todos are assigned to, for outputs split by assignee
+	Content  io.Reader // Rendered template
+	Todos    int       // Number of selected top-level todos
+}
+
+// validateOutputs checks the names, selections and template syntax of outputs,
+// parsing the templates with funcs.
+func validateOutputs(outputs []Output, funcs template.FuncMap) error {
+	names := make(map[string]bool, len(outputs))
+	for _, o := range outputs {
+		if o.Name == "" {
+			return fmt.Errorf("invalid output: name cannot be empty")
+		}
+		if names[o.Name] {
+			return fmt.Errorf("invalid output %q: duplicate name", o.Name)
+		}
+		names[o.Name] = true
+
+		switch o.Select {
+		case SelectOpen, SelectCompleted:
+		case SelectStale:
+			if o.StaleDays < 1 {
+				return fmt.Errorf("invalid output %q: stale days must be at least 1, got %d", o.Name, o.StaleDays)
+			}
+		default:
+			return fmt.Errorf("invalid output %q: selection must be %s, %s or %s, got %q", o.Name, SelectOpen, SelectCompleted, SelectStale, o.Select)
+		}
+
+		if _, err := template.New(o.Name).Funcs(funcs).Parse(o.Template); err != nil {
+			return fmt.Errorf("invalid output %q: invalid template syntax: %w", o.Name, err)
+		}
+	}
+	return nil
+}
+
+// selectTodos returns the todos of parts that o renders.
+func (g *Generator) selectTodos(o Output, parts *journalParts) (*core.TodoJournal, error) {
+	switch o.Select {
+	case SelectCompleted:
+		return parts.done, nil
+	case SelectStale:
+		return core.FilterStale(parts.carried, g.templateDate, o.StaleDays, g.templateDate)
+	default:
+		return parts.carried, nil
+	}
+}
+
+// renderOutputs renders the outputs of the generator for parts.
+func (g *Generator) renderOutputs(parts *journalParts) ([]OutputResult, error) {
+	var results []OutputResult
+	for _, o := range g.outputs {
+		selected, err := g.selectTodos(o, parts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to select todos for output %q: %w", o.Name, err)
+		}
+		if o.AnnotateCreated {
+			selected = core.AnnotateCreated(selected, parts.date)
+		}
+
+		if !o.SplitByAssignee {
+			result, err := g.renderOutput(o, selected, parts.previous)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, result)
+			continue
+		}
+		for _, name := range core.Assignees(selected) {
+			result, err := g.renderOutput(o, core.FilterAssigned(selected, name), parts.previous)
+			if err != nil {
+				return nil, err
+			}
+			result.Assignee = name
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// renderOutput renders the template of o for the selected todos of the previous journal.
+func (g *Generator) renderOutput(o Output, selected *core.TodoJournal, previous *core.Document) (OutputResult, error) {
+	content := o.Template
+	if content == "" {
+		content = DefaultOutputTemplate
+	}
+	var buf bytes.Buffer
+	err := core.WriteFromTemplate(&buf, core.TemplateOptions{
+		Content:       content,
+		TodosContent:  core.JournalToStringFormat(selected, g.format),
+		CurrentDate:   g.templateDate,
+		PreviousDate:  g.previousDate,
+		Journal:       selected,
+		CustomVars:    g.renderVars(),
+		Streaks:       g.streaks,
+		Sprig:         g.sprigFunctions,
+		Calendar:      g.calendar,
+		Notes:         g.notes,
+		Previous:      previous,
+		Deterministic: g.deterministic,
+	})
+	if err != nil {
+		return OutputResult{}, fmt.Errorf("failed to render output %q: %w", o.Name, err)
+	}
+
+	todos := 0
+	if selected != nil {
+		for _, day := range selected.Days {
+			todos += day.ItemCount()
+		}
+	}
+	return OutputResult{Name: o.Name, Content: &buf, Todos: todos}, nil
+}
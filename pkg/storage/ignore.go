@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Ignored is a Storage whose listings leave out the files and directories
+// matching ignore patterns, so that walks over a journal tree never see them.
+// Files named directly are still read and written.
+type Ignored struct {
+	Storage
+	patterns []string
+}
+
+// Ignore returns s with the files and directories matching patterns left out of
+// its listings, see MatchPattern. Without patterns s is returned as is.
+func Ignore(s Storage, patterns ...string) Storage {
+	if len(patterns) == 0 {
+		return s
+	}
+	return &Ignored{Storage: s, patterns: patterns}
+}
+
+// List returns the entries directly inside dir that match none of the patterns.
+func (i *Ignored) List(dir string) ([]FileInfo, error) {
+	entries, err := i.Storage.List(dir)
+	if err != nil {
+		return nil, err
+	}
+	kept := entries[:0]
+	for _, entry := range entries {
+		if !i.ignores(entry.Name, entry.IsDir) {
+			kept = append(kept, entry)
+		}
+	}
+	return kept, nil
+}
+
+// ignores reports whether name, a directory if isDir, matches one of the patterns.
+func (i *Ignored) ignores(name string, isDir bool) bool {
+	for _, pattern := range i.patterns {
+		if MatchPattern(pattern, name, isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// Unwrap returns the storage whose listings are filtered.
+func (i *Ignored) Unwrap() Storage {
+	return i.Storage
+}
+
+// ValidatePattern checks that pattern is a valid ignore pattern.
+func ValidatePattern(pattern string) error {
+	trimmed := strings.Trim(pattern, "/")
+	if trimmed == "" {
+		return fmt.Errorf("empty ignore pattern %q", pattern)
+	}
+	for _, segment := range strings.Split(trimmed, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return fmt.Errorf("invalid ignore pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// MatchPattern reports whether the slash-separated name, a directory if isDir,
+// matches an ignore pattern. Patterns work like those of .gitignore files without
+// negation: segments are matched with path.Match and "**" matches any number of
+// segments. A pattern without a slash matches the base name at any depth, others
+// match from the root. A pattern ending in a slash only matches directories.
+// Ignoring a directory ignores everything below it.
+func MatchPattern(pattern, name string, isDir bool) bool {
+	if strings.HasSuffix(pattern, "/") && !isDir {
+		return false
+	}
+	anchored := strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	pattern = strings.Trim(pattern, "/")
+	if pattern == "" {
+		return false
+	}
+	if !anchored {
+		pattern = "**/" + pattern
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(cleanName(name), "/"))
+}
+
+// matchSegments reports whether the segments of a name match those of a pattern.
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
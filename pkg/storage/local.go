@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// FilePermissions are the default permissions of files written by Local.
+const FilePermissions = 0644
+
+// Local stores files on local disk below Root.
+type Local struct {
+	Root         string      // Root directory; empty means names are used as given
+	SkipSymlinks bool        // Leave symbolic links out of listings instead of describing their targets
+	Retry        RetryPolicy // Retries of operations failing with transient errors
+	Mode         fs.FileMode // Permissions of written files; zero means FilePermissions
+}
+
+// DirMode returns the permissions of directories holding files with the
+// permissions mode: those who may read the files may also enter the directory,
+// so 0644 gives 0755 and 0600 gives 0700.
+func DirMode(mode fs.FileMode) fs.FileMode {
+	mode &= fs.ModePerm
+	return mode | (mode&0444)>>2
+}
+
+// fileMode returns the permissions of the files l writes.
+func (l *Local) fileMode() fs.FileMode {
+	if l.Mode == 0 {
+		return FilePermissions
+	}
+	return l.Mode
+}
+
+// NewLocal returns a Storage for the directory root on local disk.
+// With an empty root, names are interpreted as ordinary file paths.
+func NewLocal(root string) *Local {
+	return &Local{Root: root}
+}
+
+// path converts a storage name to a path on disk
+func (l *Local) path(name string) string {
+	if l.Root == "" {
+		if name == "" {
+			return "."
+		}
+		return filepath.FromSlash(name)
+	}
+	return filepath.Join(l.Root, filepath.FromSlash(name))
+}
+
+// Read returns the content of the named file.
+func (l *Local) Read(name string) ([]byte, error) {
+	var content []byte
+	err := l.Retry.Do(context.Background(), func() (err error) {
+		content, err = os.ReadFile(l.path(name))
+		return err
+	})
+	return content, err
+}
+
+// Write atomically replaces the named file using a temporary file and rename.
+// With retries, the content of r is held in memory to be written again.
+func (l *Local) Write(name string, r io.Reader) error {
+	if l.Retry.Attempts < 2 {
+		return WriteFileAtomic(l.path(name), r, l.fileMode())
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return l.Retry.Do(context.Background(), func() error {
+		return WriteFileAtomic(l.path(name), bytes.NewReader(content), l.fileMode())
+	})
+}
+
+// MkdirAll creates the named directory and any missing parents.
+func (l *Local) MkdirAll(dir string) error {
+	return l.Retry.Do(context.Background(), func() error {
+		return os.MkdirAll(l.path(dir), DirMode(l.fileMode()))
+	})
+}
+
+// Remove deletes the named file.
+func (l *Local) Remove(name string) error {
+	return l.Retry.Do(context.Background(), func() error {
+		return os.Remove(l.path(name))
+	})
+}
+
+// List returns the entries directly inside the named directory.
+func (l *Local) List(dir string) ([]FileInfo, error) {
+	var entries []os.DirEntry
+	err := l.Retry.Do(context.Background(), func() (err error) {
+		entries, err = os.ReadDir(l.path(dir))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info := FileInfo{Name: path.Join(filepath.ToSlash(dir), entry.Name()), IsDir: entry.IsDir()}
+		// Junctions on Windows are irregular files
+		if entry.Type()&(fs.ModeSymlink|fs.ModeIrregular) != 0 {
+			fi, err := os.Stat(l.path(info.Name))
+			if l.SkipSymlinks || err != nil {
+				// Skipped, or a broken link
+				continue
+			}
+			info.Link, info.IsDir, info.ModTime = true, fi.IsDir(), fi.ModTime()
+			if !fi.IsDir() {
+				info.Size = fi.Size()
+			}
+		} else if fi, err := entry.Info(); err == nil {
+			info.ModTime = fi.ModTime()
+			if !entry.IsDir() {
+				info.Size = fi.Size()
+			}
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// Stat describes the named file or directory.
+func (l *Local) Stat(name string) (FileInfo, error) {
+	var fi os.FileInfo
+	err := l.Retry.Do(context.Background(), func() (err error) {
+		fi, err = os.Stat(l.path(name))
+		return err
+	})
+	if err != nil {
+		return FileInfo{}, err
+	}
+	info := FileInfo{Name: name, ModTime: fi.ModTime(), IsDir: fi.IsDir()}
+	if !fi.IsDir() {
+		info.Size = fi.Size()
+	}
+	return info, nil
+}
+
+// realPath returns the absolute path of the named file on disk with all symbolic
+// links resolved.
+func (l *Local) realPath(name string) (string, error) {
+	real, err := filepath.EvalSymlinks(l.path(name))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(real)
+}
+
+// Location returns the path of the named file on disk.
+func (l *Local) Location(name string) string {
+	return l.path(name)
+}
+
+// rename moves a staged temporary file into place; tests replace it to simulate
+// a run interrupted between renames
+var rename = os.Rename
+
+// WriteFileAtomic streams the content of r to filename using a temp file in the
+// same directory and a rename, so readers never see a partially written file.
+func WriteFileAtomic(filename string, r io.Reader, perm os.FileMode) error {
+	tmpName, err := stageFile(filename, r, perm)
+	if err != nil {
+		return err
+	}
+	if err := rename(tmpName, filename); err != nil {
+		_ = os.Remove(tmpName)
+		return fmt.Errorf("failed to move temporary file to target: %w", err)
+	}
+	return nil
+}
+
+// stageFile writes the content of r to a new temporary file next to filename, with
+// the permissions perm, and returns its name. The file is synced to disk, so only
+// a rename is left to put it in place. Nothing is left behind on failure.
+func stageFile(filename string, r io.Reader, perm os.FileMode) (string, error) {
+	dir := filepath.Dir(filename)
+	tmpFile, err := os.CreateTemp(dir, filepath.Base(filename)+".tmp.*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+
+	fail := func(format string, err error) (string, error) {
+		_ = tmpFile.Close()
+		_ = os.Remove(tmpFile.Name())
+		return "", fmt.Errorf(format, err)
+	}
+
+	writer := bufio.NewWriter(tmpFile)
+	if _, err := io.Copy(writer, r); err != nil {
+		return fail("failed to write to temporary file: %w", err)
+	}
+	if err := writer.Flush(); err != nil {
+		return fail("failed to write to temporary file: %w", err)
+	}
+
+	// Set the permissions before the rename, so the file never appears with the
+	// restrictive permissions of a temporary file
+	if err := tmpFile.Chmod(perm); err != nil {
+		return fail("failed to set file permissions: %w", err)
+	}
+
+	if err := tmpFile.Sync(); err != nil {
+		return fail("failed to sync temporary file: %w", err)
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		_ = os.Remove(tmpFile.Name())
+		return "", fmt.Errorf("failed to close temporary file: %w", err)
+	}
+
+	return tmpFile.Name(), nil
+}
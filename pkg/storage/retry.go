@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy retries file operations failing with transient errors, which
+// network filesystems such as NFS, SMB and cloud sync mounts report now and then.
+// The zero value tries once.
+type RetryPolicy struct {
+	Attempts int           // Tries of an operation; fewer than two try once
+	Backoff  time.Duration // Wait before the second try, doubled before every further one
+}
+
+// Do runs op until it succeeds, fails with an error that is not transient, the
+// attempts are used up or ctx is done. The error of the last try is returned,
+// saying how often op was tried if that was more than once.
+func (p RetryPolicy) Do(ctx context.Context, op func() error) error {
+	wait := p.Backoff
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil || !IsTransient(err) {
+			return err
+		}
+		if attempt >= p.Attempts {
+			if attempt > 1 {
+				return fmt.Errorf("%w (gave up after %d attempts)", err, attempt)
+			}
+			return err
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w (gave up after %d attempts: %v)", err, attempt, ctx.Err())
+		case <-timer.C:
+		}
+		wait *= 2
+	}
+}
+
+// transientErrors are the system errors worth trying again
+var transientErrors = []error{
+	syscall.EAGAIN, syscall.EINTR, syscall.EBUSY, syscall.EIO, syscall.ESTALE,
+	syscall.ETIMEDOUT, syscall.ECONNRESET, syscall.ECONNABORTED,
+	syscall.ENETDOWN, syscall.ENETUNREACH, syscall.EHOSTUNREACH,
+	os.ErrDeadlineExceeded,
+}
+
+// IsTransient reports whether err is a failure that may go away when the
+// operation is tried again, such as a stale NFS handle or a network timeout.
+func IsTransient(err error) bool {
+	for _, transient := range transientErrors {
+		if errors.Is(err, transient) {
+			return true
+		}
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,412 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Config holds the connection settings of an S3 bucket.
+type S3Config struct {
+	Endpoint     string // Server URL, e.g. http://localhost:9000 for MinIO; empty means AWS
+	Region       string // Region of the bucket; empty means us-east-1
+	AccessKey    string // Access key ID; empty sends unsigned requests
+	SecretKey    string // Secret access key
+	SessionToken string // Session token of temporary credentials, if any
+}
+
+// S3ConfigFromEnv returns the S3Config of the AWS environment variables
+// AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL, AWS_REGION or AWS_DEFAULT_REGION,
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+func S3ConfigFromEnv() S3Config {
+	return S3Config{
+		Endpoint:     firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"),
+		Region:       firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"),
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+// firstEnv returns the first of the named environment variables that is set
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// S3 stores files as objects of an S3 bucket below a key prefix. S3 has no
+// directories: MkdirAll writes an empty object named after the directory with
+// a trailing slash, as the AWS console does for folders, and a directory exists
+// while any object has its name as prefix.
+type S3 struct {
+	bucket string
+	prefix string  // Key prefix of the root, "" or ending in a slash
+	base   url.URL // URL of the bucket, ending in a slash
+	config S3Config
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewS3 returns a Storage for the objects of bucket below prefix. A nil client
+// uses http.DefaultClient. AWS buckets are addressed by host name, buckets of
+// other endpoints by path.
+func NewS3(bucket, prefix string, config S3Config, client *http.Client) (*S3, error) {
+	if bucket == "" {
+		return nil, errors.New("s3 storage needs a bucket")
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+
+	var base *url.URL
+	if config.Endpoint == "" {
+		base = &url.URL{Scheme: "https", Host: bucket + ".s3." + config.Region + ".amazonaws.com", Path: "/"}
+	} else {
+		endpoint, err := url.Parse(config.Endpoint)
+		if err != nil || endpoint.Host == "" {
+			return nil, fmt.Errorf("invalid s3 endpoint %q", config.Endpoint)
+		}
+		base = endpoint
+		base.Path = strings.TrimSuffix(base.Path, "/") + "/" + bucket + "/"
+	}
+
+	prefix = cleanName(prefix)
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &S3{bucket: bucket, prefix: prefix, base: *base, config: config, client: client, now: time.Now}, nil
+}
+
+// key returns the object key of the named file
+func (s *S3) key(name string) string {
+	return s.prefix + cleanName(name)
+}
+
+// dirKey returns the prefix of the objects inside the named directory
+func (s *S3) dirKey(dir string) string {
+	if dir = cleanName(dir); dir == "" {
+		return s.prefix
+	}
+	return s.prefix + dir + "/"
+}
+
+// do sends a signed request for the object key, or for the bucket with an
+// empty key, with the query parameters and body.
+func (s *S3) do(method, key string, query url.Values, body []byte) (*http.Response, error) {
+	u := s.base
+	u.Path = s.base.Path + key
+	u.RawPath = s.base.EscapedPath() + s3Escape(key, false)
+	u.RawQuery = s3Query(query)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if s.config.AccessKey != "" {
+		s.sign(req, &u, body)
+	}
+	return s.client.Do(req)
+}
+
+// sign adds an AWS Signature Version 4 to req for the request URL u.
+func (s *S3) sign(req *http.Request, u *url.URL, body []byte) {
+	now := s.now().UTC()
+	date, stamp := now.Format("20060102"), now.Format("20060102T150405Z")
+	payload := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(payload[:])
+
+	req.Header.Set("X-Amz-Date", stamp)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	signed := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	headers := "host:" + u.Host + "\nx-amz-content-sha256:" + payloadHash + "\nx-amz-date:" + stamp + "\n"
+	if s.config.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.config.SessionToken)
+		signed = append(signed, "x-amz-security-token")
+		headers += "x-amz-security-token:" + s.config.SessionToken + "\n"
+	}
+
+	canonical := strings.Join([]string{req.Method, u.EscapedPath(), u.RawQuery, headers, strings.Join(signed, ";"), payloadHash}, "\n")
+	scope := date + "/" + s.config.Region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := []byte("AWS4" + s.config.SecretKey)
+	for _, part := range []string{date, s.config.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKey, scope, strings.Join(signed, ";"), signature))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape percent-encodes s the way AWS signatures expect: everything but
+// unreserved characters, and slashes unless escapeSlash is false.
+func s3Escape(s string, escapeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !escapeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// s3Query returns query encoded with sorted keys, as AWS signatures expect
+func s3Query(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, key := range keys {
+		for _, value := range query[key] {
+			parts = append(parts, s3Escape(key, true)+"="+s3Escape(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// Read returns the content of the named file.
+func (s *S3) Read(name string) ([]byte, error) {
+	resp, err := s.do(http.MethodGet, s.key(name), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError("GET", name, resp)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// Write uploads the content of r to the named file. S3 replaces the previous
+// content only once the upload is complete.
+func (s *S3) Write(name string, r io.Reader) error {
+	if dir := path.Dir(cleanName(name)); dir != "." {
+		info, err := s.Stat(dir)
+		if err != nil && !errors.Is(err, ErrNotExist) {
+			return err
+		}
+		if err != nil || !info.IsDir {
+			return fmt.Errorf("PUT %s: parent directory: %w", name, ErrNotExist)
+		}
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(http.MethodPut, s.key(name), nil, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return statusError("PUT", name, resp)
+	}
+	return nil
+}
+
+// MkdirAll writes the folder objects of the named directory and its parents.
+func (s *S3) MkdirAll(dir string) error {
+	dir = cleanName(dir)
+	if dir == "" {
+		return nil
+	}
+
+	current := ""
+	for _, part := range strings.Split(dir, "/") {
+		current = path.Join(current, part)
+		resp, err := s.do(http.MethodPut, s.dirKey(current), nil, nil)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return statusError("PUT", current+"/", resp)
+		}
+	}
+	return nil
+}
+
+// Remove deletes the named file. S3 reports deleting a missing object as a
+// success, so the object is looked up first.
+func (s *S3) Remove(name string) error {
+	if _, err := s.head(name); err != nil {
+		return err
+	}
+	resp, err := s.do(http.MethodDelete, s.key(name), nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		return nil
+	default:
+		return statusError("DELETE", name, resp)
+	}
+}
+
+// Stat describes the named file or directory.
+func (s *S3) Stat(name string) (FileInfo, error) {
+	name = cleanName(name)
+	if name == "" {
+		return FileInfo{IsDir: true}, nil
+	}
+	info, err := s.head(name)
+	if !errors.Is(err, ErrNotExist) {
+		return info, err
+	}
+	// A directory has no object of its own, only objects below it
+	result, listErr := s.list(s.dirKey(name), "", 1, "")
+	if listErr != nil {
+		return FileInfo{}, listErr
+	}
+	if len(result.Contents) == 0 && len(result.CommonPrefixes) == 0 {
+		return FileInfo{}, err
+	}
+	return FileInfo{Name: name, IsDir: true}, nil
+}
+
+// head describes the object of the named file
+func (s *S3) head(name string) (FileInfo, error) {
+	resp, err := s.do(http.MethodHead, s.key(name), nil, nil)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return FileInfo{}, statusError("HEAD", name, resp)
+	}
+	info := FileInfo{Name: cleanName(name), Size: resp.ContentLength}
+	if modTime, err := time.Parse(http.TimeFormat, resp.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = modTime
+	}
+	return info, nil
+}
+
+// List returns the entries directly inside the named directory.
+func (s *S3) List(dir string) ([]FileInfo, error) {
+	prefix := s.dirKey(dir)
+	var entries []FileInfo
+	found := cleanName(dir) == ""
+	token := ""
+	for {
+		result, err := s.list(prefix, "/", 0, token)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range result.Contents {
+			found = true
+			// The folder object of the directory itself
+			if object.Key == prefix {
+				continue
+			}
+			info := FileInfo{Name: strings.TrimPrefix(object.Key, s.prefix), Size: object.Size}
+			if modTime, err := time.Parse(time.RFC3339, object.LastModified); err == nil {
+				info.ModTime = modTime
+			}
+			entries = append(entries, info)
+		}
+		for _, common := range result.CommonPrefixes {
+			found = true
+			entries = append(entries, FileInfo{Name: strings.TrimSuffix(strings.TrimPrefix(common.Prefix, s.prefix), "/"), IsDir: true})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	if !found {
+		return nil, fmt.Errorf("LIST %s: %w", dir, ErrNotExist)
+	}
+	return entries, nil
+}
+
+// Location returns the s3:// URL of the named file.
+func (s *S3) Location(name string) string {
+	return "s3://" + s.bucket + "/" + s.key(name)
+}
+
+// s3ListResult is the body of a ListObjectsV2 response
+type s3ListResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string `xml:"Key"`
+		Size         int64  `xml:"Size"`
+		LastModified string `xml:"LastModified"`
+	} `xml:"Contents"`
+	CommonPrefixes []struct {
+		Prefix string `xml:"Prefix"`
+	} `xml:"CommonPrefixes"`
+}
+
+// list lists the objects with prefix, grouped by delimiter unless it is empty,
+// at most maxKeys of them unless it is 0, continuing after token.
+func (s *S3) list(prefix, delimiter string, maxKeys int, token string) (*s3ListResult, error) {
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	if delimiter != "" {
+		query.Set("delimiter", delimiter)
+	}
+	if maxKeys > 0 {
+		query.Set("max-keys", strconv.Itoa(maxKeys))
+	}
+	if token != "" {
+		query.Set("continuation-token", token)
+	}
+
+	resp, err := s.do(http.MethodGet, "", query, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError("LIST", prefix, resp)
+	}
+	var result s3ListResult
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("LIST %s: invalid response: %w", prefix, err)
+	}
+	return &result, nil
+}
@@ -0,0 +1,899 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// testStorageContract exercises the behaviour every Storage must provide
+func testStorageContract(t *testing.T, s Storage) {
+	t.Helper()
+
+	if _, err := s.Read("2025/06/2025-06-20.md"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Read() of missing file error = %v, want ErrNotExist", err)
+	}
+	if _, err := s.Stat("2025/06/2025-06-20.md"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat() of missing file error = %v, want ErrNotExist", err)
+	}
+	if err := s.Write("2025/06/2025-06-20.md", strings.NewReader("x")); err == nil {
+		t.Error("Write() into a missing directory should fail")
+	}
+
+	if err := s.MkdirAll("2025/06"); err != nil {
+		t.Fatalf("MkdirAll() error: %v", err)
+	}
+	if err := s.MkdirAll("2025/06"); err != nil {
+		t.Fatalf("MkdirAll() of existing directory error: %v", err)
+	}
+	if err := s.MkdirAll("2025/07"); err != nil {
+		t.Fatalf("MkdirAll() error: %v", err)
+	}
+
+	files := map[string]string{
+		"2025/06/2025-06-20.md": "## Todos\n\n- [ ] Task\n",
+		"2025/06/2025-06-21.md": "## Todos\n\n",
+		"2025/07/2025-07-01.md": "## Todos\n\n- [x] Done\n",
+		"notes.md":              "notes",
+	}
+	for name, content := range files {
+		if err := s.Write(name, strings.NewReader(content)); err != nil {
+			t.Fatalf("Write(%s) error: %v", name, err)
+		}
+	}
+	// Overwriting replaces the content
+	if err := s.Write("notes.md", strings.NewReader("new notes")); err != nil {
+		t.Fatalf("Write() overwrite error: %v", err)
+	}
+	files["notes.md"] = "new notes"
+
+	for name, content := range files {
+		data, err := s.Read(name)
+		if err != nil {
+			t.Fatalf("Read(%s) error: %v", name, err)
+		}
+		if string(data) != content {
+			t.Errorf("Read(%s) = %q, want %q", name, data, content)
+		}
+	}
+
+	info, err := s.Stat("2025/06/2025-06-20.md")
+	if err != nil {
+		t.Fatalf("Stat() error: %v", err)
+	}
+	if info.IsDir || info.Size != int64(len(files["2025/06/2025-06-20.md"])) {
+		t.Errorf("Stat() = %+v", info)
+	}
+	if info, err := s.Stat("2025"); err != nil || !info.IsDir {
+		t.Errorf("Stat() of directory = %+v, %v", info, err)
+	}
+
+	entries, err := s.List("")
+	if err != nil {
+		t.Fatalf("List() error: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, fmt.Sprintf("%s:%v", entry.Name, entry.IsDir))
+	}
+	sort.Strings(names)
+	if strings.Join(names, " ") != "2025:true notes.md:false" {
+		t.Errorf("List() = %v", names)
+	}
+
+	if err := s.Write("2025/07/2025-07-02.md", strings.NewReader("x")); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+	if err := s.Remove("2025/07/2025-07-02.md"); err != nil {
+		t.Fatalf("Remove() error: %v", err)
+	}
+	if _, err := s.Stat("2025/07/2025-07-02.md"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat() after Remove() error = %v, want ErrNotExist", err)
+	}
+	if err := s.Remove("2025/07/2025-07-02.md"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Remove() of missing file error = %v, want ErrNotExist", err)
+	}
+
+	var walked []string
+	err = Walk(s, "", func(info FileInfo) error {
+		walked = append(walked, info.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk() error: %v", err)
+	}
+	want := "2025/06/2025-06-20.md 2025/06/2025-06-21.md 2025/07/2025-07-01.md notes.md"
+	if strings.Join(walked, " ") != want {
+		t.Errorf("Walk() = %v, want %s", walked, want)
+	}
+}
+
+func TestLocal(t *testing.T) {
+	root := t.TempDir()
+	s := NewLocal(root)
+	testStorageContract(t, s)
+
+	if got := s.Location("2025/06/2025-06-20.md"); got != path.Join(root, "2025/06/2025-06-20.md") {
+		t.Errorf("Location() = %q", got)
+	}
+}
+
+func TestWebDAV(t *testing.T) {
+	server := httptest.NewServer(newFakeDAV("/dav/", "user", "secret"))
+	defer server.Close()
+
+	base, err := url.Parse(server.URL + "/dav/journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	base.User = url.UserPassword("user", "secret")
+
+	if err := NewWebDAV(mustParse(t, server.URL+"/dav/"), server.Client()).MkdirAll("journal"); err == nil {
+		t.Error("MkdirAll() without credentials should fail")
+	}
+
+	s := NewWebDAV(base, server.Client())
+	// The journal collection must exist before files can be stored in it
+	root := *base
+	root.Path = "/dav/"
+	if err := NewWebDAV(&root, server.Client()).MkdirAll("journal"); err != nil {
+		t.Fatalf("MkdirAll() error: %v", err)
+	}
+
+	testStorageContract(t, s)
+
+	if got := s.Location("notes.md"); got != server.URL+"/dav/journal/notes.md" {
+		t.Errorf("Location() = %q, want URL without credentials", got)
+	}
+}
+
+func TestS3(t *testing.T) {
+	fake := newFakeS3("journals", "key", "secret")
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	config := S3Config{Endpoint: server.URL, Region: "eu-north-1", AccessKey: "key", SecretKey: "secret", SessionToken: "token"}
+	s, err := NewS3("journals", "/me/journal/", config, server.Client())
+	if err != nil {
+		t.Fatalf("NewS3() error: %v", err)
+	}
+	testStorageContract(t, s)
+
+	// Keys are escaped the same way for the request and its signature
+	if err := s.Write("2025/06/notes 1+2 ä.md", strings.NewReader("x")); err != nil {
+		t.Fatalf("Write() of escaped name error: %v", err)
+	}
+	if data, err := s.Read("2025/06/notes 1+2 ä.md"); err != nil || string(data) != "x" {
+		t.Errorf("Read() of escaped name = %q, %v", data, err)
+	}
+	if _, ok := fake.objects["me/journal/2025/06/"]; !ok {
+		t.Error("MkdirAll() did not write a folder object")
+	}
+	if got := s.Location("notes.md"); got != "s3://journals/me/journal/notes.md" {
+		t.Errorf("Location() = %q", got)
+	}
+
+	// Only a missing object is looked for as a directory
+	fake.headStatus = http.StatusInternalServerError
+	if info, err := s.Stat("2025"); err == nil || errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat() with a failing HEAD = %+v, %v; want the error", info, err)
+	}
+	fake.headStatus = 0
+
+	config.SecretKey = "wrong"
+	wrong, _ := NewS3("journals", "me/journal", config, server.Client())
+	if _, err := wrong.Read("notes.md"); err == nil || errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Read() with a wrong secret error = %v, want a failed request", err)
+	}
+
+	if _, err := NewS3("", "journal", S3Config{}, nil); err == nil {
+		t.Error("NewS3() without a bucket should fail")
+	}
+	aws, _ := NewS3("journals", "", S3Config{Region: "eu-north-1"}, nil)
+	if got := aws.base.String(); got != "https://journals.s3.eu-north-1.amazonaws.com/" {
+		t.Errorf("NewS3() without endpoint URL = %q", got)
+	}
+}
+
+// faultStorage wraps a Storage and fails the write numbered failWrite (counting
+// from 1) with err, as a full disk or an interrupted run would.
+type faultStorage struct {
+	Storage
+	failWrite int
+	err       error
+	writes    int
+}
+
+func (f *faultStorage) Write(name string, r io.Reader) error {
+	f.writes++
+	if f.writes == f.failWrite {
+		return f.err
+	}
+	return f.Storage.Write(name, r)
+}
+
+// readFiles returns the content of the files below root in s, by name.
+func readFiles(t *testing.T, s Storage) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := Walk(s, "", func(info FileInfo) error {
+		data, err := s.Read(info.Name)
+		files[info.Name] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Walk() error: %v", err)
+	}
+	return files
+}
+
+func TestTransaction(t *testing.T) {
+	before := map[string]string{"source.md": "old source"}
+	after := map[string]string{"source.md": "new source", "source.md.bak": "old source", "target.md": "new target"}
+	writeAll := func(s Storage) error {
+		tx := NewTransaction(s)
+		tx.Write("target.md", []byte("new target"))
+		tx.Write("source.md.bak", []byte("old source"))
+		tx.Write("source.md", []byte("new source"))
+		return tx.Commit()
+	}
+
+	tests := []struct {
+		name    string
+		storage func(Storage) Storage
+		fail    func(t *testing.T) // Sets up a fault on local disk, undone with t.Cleanup
+		want    map[string]string
+		wantErr error
+	}{
+		{name: "local", want: after},
+		{name: "generic", storage: func(s Storage) Storage { return &faultStorage{Storage: s} }, want: after},
+		{
+			name:    "generic disk full",
+			storage: func(s Storage) Storage { return &faultStorage{Storage: s, failWrite: 3, err: syscall.ENOSPC} },
+			want:    before,
+			wantErr: syscall.ENOSPC,
+		},
+		{
+			name: "local interrupted between renames",
+			fail: func(t *testing.T) {
+				renames := 0
+				rename = func(from, to string) error {
+					if renames++; renames == 2 {
+						return syscall.EIO
+					}
+					return os.Rename(from, to)
+				}
+				t.Cleanup(func() { rename = os.Rename })
+			},
+			want:    before,
+			wantErr: syscall.EIO,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			local := NewLocal(t.TempDir())
+			if err := local.Write("source.md", strings.NewReader("old source")); err != nil {
+				t.Fatal(err)
+			}
+			if tt.fail != nil {
+				tt.fail(t)
+			}
+			var s Storage = local
+			if tt.storage != nil {
+				s = tt.storage(local)
+			}
+
+			err := writeAll(s)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Commit() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), local.Location("")) {
+				t.Errorf("Commit() error = %v, want the location of the failed file", err)
+			}
+			if got := readFiles(t, local); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("files after Commit() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransaction_StagingFailure(t *testing.T) {
+	s := NewLocal(t.TempDir())
+	if err := s.Write("source.md", strings.NewReader("old source")); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := NewTransaction(s)
+	tx.Write("source.md", []byte("new source"))
+	tx.Write("missing/target.md", []byte("new target"))
+	if err := tx.Commit(); err == nil {
+		t.Fatal("Commit() into a missing directory should fail")
+	}
+	// The failure is found while staging, before any file is replaced
+	if got := readFiles(t, s); !reflect.DeepEqual(got, map[string]string{"source.md": "old source"}) {
+		t.Errorf("files after failed Commit() = %v", got)
+	}
+}
+
+func TestTransaction_StagingThroughIgnore(t *testing.T) {
+	local := NewLocal(t.TempDir())
+	if err := local.Write("source.md", strings.NewReader("old source")); err != nil {
+		t.Fatal(err)
+	}
+	renames := 0
+	rename = func(from, to string) error {
+		renames++
+		return os.Rename(from, to)
+	}
+	t.Cleanup(func() { rename = os.Rename })
+
+	// Journal trees with ignore patterns are staged on local disk as well
+	tx := NewTransaction(Ignore(local, "drafts/"))
+	tx.Write("source.md", []byte("new source"))
+	tx.Write("missing/target.md", []byte("new target"))
+	if err := tx.Commit(); err == nil {
+		t.Fatal("Commit() into a missing directory should fail")
+	}
+	if renames != 0 {
+		t.Errorf("Commit() replaced %d files before staging failed", renames)
+	}
+	if got := readFiles(t, local); !reflect.DeepEqual(got, map[string]string{"source.md": "old source"}) {
+		t.Errorf("files after failed Commit() = %v", got)
+	}
+}
+
+func TestWriteFileAtomic_Permissions(t *testing.T) {
+	name := path.Join(t.TempDir(), "journal.md")
+	if err := WriteFileAtomic(name, strings.NewReader("content"), 0600); err != nil {
+		t.Fatalf("WriteFileAtomic() error: %v", err)
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("permissions = %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestLocal_Mode(t *testing.T) {
+	root := t.TempDir()
+	s := &Local{Root: root, Mode: 0600}
+	if err := s.MkdirAll("2025/06"); err != nil {
+		t.Fatalf("MkdirAll() error: %v", err)
+	}
+	if err := s.Write("2025/06/a.md", strings.NewReader("a")); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+	tx := NewTransaction(s)
+	tx.Write("2025/06/b.md", []byte("b"))
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit() error: %v", err)
+	}
+
+	for name, want := range map[string]os.FileMode{"2025": 0700, "2025/06/a.md": 0600, "2025/06/b.md": 0600} {
+		info, err := os.Stat(path.Join(root, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != want {
+			t.Errorf("permissions of %s = %v, want %v", name, info.Mode().Perm(), want)
+		}
+	}
+	if got := DirMode(0644); got != 0755 {
+		t.Errorf("DirMode(0644) = %v, want 0755", got)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	tests := []struct {
+		root    string
+		local   bool
+		wantErr bool
+	}{
+		{root: "/home/user/journal", local: true},
+		{root: "journal", local: true},
+		{root: "file:///home/user/journal", local: true},
+		{root: "https://dav.example.com/journal"},
+		{root: "s3://bucket/journal"},
+		{root: "s3:///journal", wantErr: true},
+		{root: "ftp://example.com/journal", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		s, err := Open(tt.root)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Open(%q) expected error", tt.root)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Open(%q) error: %v", tt.root, err)
+			continue
+		}
+		if IsLocal(s) != tt.local {
+			t.Errorf("Open(%q) local = %v, want %v", tt.root, IsLocal(s), tt.local)
+		}
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		isDir   bool
+		want    bool
+	}{
+		{"templates/**", "templates", true, true},
+		{"templates/**", "templates/daily.md", false, true},
+		{"templates/**", "notes/templates/daily.md", false, false},
+		{"archive/**", "archive/2024/01/2024-01-09.md", false, true},
+		{"*.excalidraw.md", "2025/06/drawing.excalidraw.md", false, true},
+		{"*.excalidraw.md", "2025/06/2025-06-20.md", false, false},
+		{"attachments/", "assets/attachments", true, true},
+		{"attachments/", "attachments", false, false},
+		{"/inbox.md", "inbox.md", false, true},
+		{"/inbox.md", "notes/inbox.md", false, false},
+		{"2025/**/draft-*.md", "2025/06/draft-1.md", false, true},
+		{"2025/**/draft-*.md", "2024/06/draft-1.md", false, false},
+	}
+	for _, tt := range tests {
+		if got := MatchPattern(tt.pattern, tt.name, tt.isDir); got != tt.want {
+			t.Errorf("MatchPattern(%q, %q, %v) = %v, want %v", tt.pattern, tt.name, tt.isDir, got, tt.want)
+		}
+	}
+
+	for _, pattern := range []string{"", "/", "[a-"} {
+		if err := ValidatePattern(pattern); err == nil {
+			t.Errorf("ValidatePattern(%q) expected error", pattern)
+		}
+	}
+	if err := ValidatePattern("templates/**"); err != nil {
+		t.Errorf("ValidatePattern() error: %v", err)
+	}
+}
+
+func TestIgnore(t *testing.T) {
+	local := NewLocal(t.TempDir())
+	for _, name := range []string{"2025/06/2025-06-20.md", "templates/daily.md", "2025/06/drawing.excalidraw.md"} {
+		if err := local.MkdirAll(path.Dir(name)); err != nil {
+			t.Fatal(err)
+		}
+		if err := local.Write(name, strings.NewReader("x")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if s := Ignore(local); s != Storage(local) {
+		t.Errorf("Ignore() without patterns = %T, want the storage itself", s)
+	}
+	s := Ignore(local, "templates/**", "*.excalidraw.md")
+	if !IsLocal(s) {
+		t.Error("IsLocal() of ignoring local storage = false")
+	}
+	var names []string
+	if err := Walk(s, "", func(info FileInfo) error {
+		names = append(names, info.Name)
+		return nil
+	}); err != nil {
+		t.Fatalf("Walk() error: %v", err)
+	}
+	if want := []string{"2025/06/2025-06-20.md"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Walk() = %q, want %q", names, want)
+	}
+	if _, err := s.Read("templates/daily.md"); err != nil {
+		t.Errorf("Read() of an ignored file error: %v", err)
+	}
+}
+
+func TestWalk_Symlinks(t *testing.T) {
+	dir := t.TempDir()
+	root, outside := dir+"/journal", dir+"/outside"
+	for _, name := range []string{root + "/2025/06/2025-06-20.md", outside + "/2024/2024-01-01.md"} {
+		if err := os.MkdirAll(path.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		root + "/linked":     root + "/2025",                  // Inside the tree
+		root + "/loop":       root,                            // Cycle
+		root + "/alias.md":   root + "/2025/06/2025-06-20.md", // File inside the tree
+		root + "/ext.md":     outside + "/2024/2024-01-01.md", // File outside the tree
+		root + "/external":   outside,                         // Folder outside the tree
+		root + "/broken.md":  dir + "/missing.md",             // Broken
+		outside + "/back":    root,                            // Back into the tree
+		outside + "/self":    outside,                         // Cycle outside the tree
+		outside + "/2024/up": dir,                             // Above both
+	}
+	walk := func(s Storage) []string {
+		var names []string
+		if err := Walk(s, "", func(info FileInfo) error {
+			names = append(names, info.Name)
+			return nil
+		}); err != nil {
+			t.Fatalf("Walk() error: %v", err)
+		}
+		return names
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Skipf("symbolic links not supported: %v", err)
+		}
+	}
+
+	// The outside journal is found once, through the first link to it
+	if got, want := walk(NewLocal(root)), []string{"2025/06/2025-06-20.md", "ext.md"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Walk() following links = %q, want %q", got, want)
+	}
+	if got, want := walk(&Local{Root: root, SkipSymlinks: true}), []string{"2025/06/2025-06-20.md"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Walk() skipping links = %q, want %q", got, want)
+	}
+
+	// Without the file link, the journal is found through the folder
+	if err := os.Remove(root + "/ext.md"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := walk(NewLocal(root)), []string{"2025/06/2025-06-20.md", "external/2024/2024-01-01.md"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Walk() through a linked folder = %q, want %q", got, want)
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	policy := RetryPolicy{Attempts: 3, Backoff: time.Millisecond}
+	failing := func(errs ...error) (func() error, *int) {
+		tries := 0
+		return func() error {
+			tries++
+			if tries <= len(errs) {
+				return errs[tries-1]
+			}
+			return nil
+		}, &tries
+	}
+
+	op, tries := failing(syscall.ESTALE, &fs.PathError{Op: "open", Path: "x", Err: syscall.EIO})
+	if err := policy.Do(context.Background(), op); err != nil || *tries != 3 {
+		t.Errorf("Do() of a recovering operation = %v after %d tries, want success after 3", err, *tries)
+	}
+
+	op, tries = failing(fs.ErrNotExist)
+	if err := policy.Do(context.Background(), op); !errors.Is(err, fs.ErrNotExist) || *tries != 1 {
+		t.Errorf("Do() of a lasting failure = %v after %d tries, want ErrNotExist after 1", err, *tries)
+	}
+
+	op, tries = failing(syscall.ETIMEDOUT, syscall.ETIMEDOUT, syscall.ETIMEDOUT, syscall.ETIMEDOUT)
+	err := policy.Do(context.Background(), op)
+	if !errors.Is(err, syscall.ETIMEDOUT) || !strings.Contains(err.Error(), "gave up after 3 attempts") || *tries != 3 {
+		t.Errorf("Do() of a failing operation = %v after %d tries, want to give up after 3", err, *tries)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	op, tries = failing(syscall.EAGAIN, syscall.EAGAIN)
+	if err := (RetryPolicy{Attempts: 3, Backoff: time.Hour}).Do(ctx, op); !errors.Is(err, syscall.EAGAIN) || *tries != 1 {
+		t.Errorf("Do() with a cancelled context = %v after %d tries, want EAGAIN after 1", err, *tries)
+	}
+
+	op, tries = failing(syscall.EAGAIN)
+	if err := (RetryPolicy{}).Do(context.Background(), op); !errors.Is(err, syscall.EAGAIN) || *tries != 1 {
+		t.Errorf("Do() of the zero policy = %v after %d tries, want 1 try", err, *tries)
+	}
+}
+
+func mustParse(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+// fakeDAV is a minimal in-memory WebDAV server for tests
+type fakeDAV struct {
+	mu       sync.Mutex
+	prefix   string
+	user     string
+	password string
+	files    map[string][]byte
+	dirs     map[string]bool
+}
+
+func newFakeDAV(prefix, user, password string) *fakeDAV {
+	return &fakeDAV{
+		prefix:   prefix,
+		user:     user,
+		password: password,
+		files:    map[string][]byte{},
+		dirs:     map[string]bool{"": true},
+	}
+}
+
+func (d *fakeDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, password, ok := r.BasicAuth(); !ok || user != d.user || password != d.password {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, d.prefix), "/")
+	parent := path.Dir(name)
+	if parent == "." {
+		parent = ""
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		data, ok := d.files[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(data)
+	case http.MethodPut:
+		if !d.dirs[parent] {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		_, existed := d.files[name]
+		d.files[name] = data
+		if existed {
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			w.WriteHeader(http.StatusCreated)
+		}
+	case http.MethodDelete:
+		if _, ok := d.files[name]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(d.files, name)
+		w.WriteHeader(http.StatusNoContent)
+	case "MKCOL":
+		if d.dirs[name] {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if !d.dirs[parent] {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		d.dirs[name] = true
+		w.WriteHeader(http.StatusCreated)
+	case "PROPFIND":
+		d.propfind(w, name, r.Header.Get("Depth"))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (d *fakeDAV) propfind(w http.ResponseWriter, name, depth string) {
+	var entries []string
+	if _, ok := d.files[name]; ok {
+		entries = append(entries, name)
+	} else if d.dirs[name] {
+		entries = append(entries, name)
+		if depth == "1" {
+			for child := range d.dirs {
+				if child != name && path.Dir("/"+child) == path.Clean("/"+name) {
+					entries = append(entries, child)
+				}
+			}
+			for child := range d.files {
+				if path.Dir("/"+child) == path.Clean("/"+name) {
+					entries = append(entries, child)
+				}
+			}
+		}
+	} else {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var body strings.Builder
+	body.WriteString(`<?xml version="1.0" encoding="utf-8"?><D:multistatus xmlns:D="DAV:">`)
+	for _, entry := range entries {
+		href := path.Join(d.prefix, entry)
+		props := fmt.Sprintf("<D:resourcetype/><D:getcontentlength>%d</D:getcontentlength>", len(d.files[entry]))
+		if d.dirs[entry] {
+			href += "/"
+			props = "<D:resourcetype><D:collection/></D:resourcetype>"
+		}
+		props += "<D:getlastmodified>Fri, 20 Jun 2025 09:00:00 GMT</D:getlastmodified>"
+		fmt.Fprintf(&body, "<D:response><D:href>%s</D:href><D:propstat><D:prop>%s</D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>",
+			(&url.URL{Path: href}).EscapedPath(), props)
+	}
+	body.WriteString("</D:multistatus>")
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	_, _ = io.WriteString(w, body.String())
+}
+
+// fakeS3 is a minimal in-memory S3 server for tests, checking the signature of
+// every request. Listings are returned two keys per page.
+type fakeS3 struct {
+	mu         sync.Mutex
+	bucket     string
+	accessKey  string
+	secretKey  string
+	objects    map[string][]byte
+	headStatus int // Status of every HEAD request unless 0
+}
+
+func newFakeS3(bucket, accessKey, secretKey string) *fakeS3 {
+	return &fakeS3{bucket: bucket, accessKey: accessKey, secretKey: secretKey, objects: map[string][]byte{}}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !f.signed(r) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+f.bucket+"/")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if key == "" && r.URL.Query().Get("list-type") == "2" {
+			f.list(w, r.URL.Query())
+			return
+		}
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(data)
+	case http.MethodHead:
+		if f.headStatus != 0 {
+			w.WriteHeader(f.headStatus)
+			return
+		}
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		w.Header().Set("Last-Modified", "Fri, 20 Jun 2025 09:00:00 GMT")
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[key] = data
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// signed reports whether r carries a valid signature, computed from the request
+// as the server received it.
+func (f *fakeS3) signed(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	stamp := r.Header.Get("X-Amz-Date")
+	if len(stamp) < 8 {
+		return false
+	}
+	var scope, signedHeaders, signature string
+	for _, field := range strings.Split(strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 "), ", ") {
+		name, value, _ := strings.Cut(field, "=")
+		switch name {
+		case "Credential":
+			credential, rest, _ := strings.Cut(value, "/")
+			if credential != f.accessKey {
+				return false
+			}
+			scope = rest
+		case "SignedHeaders":
+			signedHeaders = value
+		case "Signature":
+			signature = value
+		}
+	}
+
+	var headers strings.Builder
+	for _, name := range strings.Split(signedHeaders, ";") {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		fmt.Fprintf(&headers, "%s:%s\n", name, value)
+	}
+	body, _ := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	payload := sha256.Sum256(body)
+	if hex.EncodeToString(payload[:]) != r.Header.Get("X-Amz-Content-Sha256") {
+		return false
+	}
+
+	canonical := strings.Join([]string{r.Method, r.URL.EscapedPath(), r.URL.RawQuery, headers.String(), signedHeaders, r.Header.Get("X-Amz-Content-Sha256")}, "\n")
+	hash := sha256.Sum256([]byte(canonical))
+	key := []byte("AWS4" + f.secretKey)
+	for _, part := range strings.Split(scope, "/") {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(hash[:])))
+	return hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(signature))
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, query url.Values) {
+	prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+	var keys []string
+	seen := map[string]bool{}
+	for key := range f.objects {
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok {
+			continue
+		}
+		if i := strings.Index(rest, delimiter); delimiter != "" && i >= 0 {
+			key = prefix + rest[:i+1]
+		}
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	start := 0
+	if token := query.Get("continuation-token"); token != "" {
+		start = sort.SearchStrings(keys, token)
+	}
+	end := min(start+2, len(keys))
+	if query.Get("max-keys") == "1" {
+		end = min(start+1, len(keys))
+	}
+
+	var body strings.Builder
+	body.WriteString(`<?xml version="1.0" encoding="UTF-8"?><ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">`)
+	for _, key := range keys[start:end] {
+		if delimiter != "" && strings.HasSuffix(key, delimiter) && key != prefix {
+			fmt.Fprintf(&body, "<CommonPrefixes><Prefix>%s</Prefix></CommonPrefixes>", key)
+			continue
+		}
+		fmt.Fprintf(&body, "<Contents><Key>%s</Key><Size>%d</Size><LastModified>2025-06-20T09:00:00.000Z</LastModified></Contents>", key, len(f.objects[key]))
+	}
+	if end < len(keys) {
+		fmt.Fprintf(&body, "<IsTruncated>true</IsTruncated><NextContinuationToken>%s</NextContinuationToken>", keys[end])
+	}
+	body.WriteString("</ListBucketResult>")
+	_, _ = io.WriteString(w, body.String())
+}
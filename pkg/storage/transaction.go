@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// Transaction writes several files of a Storage as one unit: either all of them
+// get their new content or, as far as the storage allows, none of them does.
+//
+// On local disk every file is first staged in a temporary file next to it, so a
+// full disk or another write error is found before any file is replaced. The
+// staged files are then renamed into place. Other storages write the files one
+// after the other. Either way, files already replaced when a write fails are
+// restored to their previous content, and files that did not exist are removed.
+type Transaction struct {
+	s      Storage
+	writes []pendingFile
+}
+
+// pendingFile is a file waiting to be written by a Transaction.
+type pendingFile struct {
+	name     string
+	data     []byte
+	original []byte // Content before the commit, nil if the file did not exist
+	staged   string // Temporary file holding data on local disk
+}
+
+// NewTransaction returns an empty Transaction writing to s.
+func NewTransaction(s Storage) *Transaction {
+	return &Transaction{s: s}
+}
+
+// Write adds the named file with content data to the transaction. Nothing is
+// written before Commit. A later write of the same name is written after it.
+func (t *Transaction) Write(name string, data []byte) {
+	t.writes = append(t.writes, pendingFile{name: name, data: data})
+}
+
+// Commit writes all files of the transaction in the order they were added. If
+// a file cannot be written, the files written before it are rolled back and
+// the error names the file that failed.
+func (t *Transaction) Commit() error {
+	for i := range t.writes {
+		w := &t.writes[i]
+		original, err := t.s.Read(w.name)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to read %s: %w", t.s.Location(w.name), err)
+		}
+		w.original = original
+	}
+
+	local, _ := local(t.s)
+	if local != nil {
+		for i := range t.writes {
+			w := &t.writes[i]
+			staged, err := stageFile(local.path(w.name), bytes.NewReader(w.data), local.fileMode())
+			if err != nil {
+				t.discard()
+				return fmt.Errorf("failed to write %s: %w", t.s.Location(w.name), err)
+			}
+			w.staged = staged
+		}
+	}
+
+	for i := range t.writes {
+		w := &t.writes[i]
+		var err error
+		if local != nil {
+			if err = rename(w.staged, local.path(w.name)); err == nil {
+				w.staged = ""
+			}
+		} else {
+			err = t.s.Write(w.name, bytes.NewReader(w.data))
+		}
+		if err != nil {
+			t.discard()
+			t.rollback(i)
+			return fmt.Errorf("failed to write %s: %w", t.s.Location(w.name), err)
+		}
+	}
+	return nil
+}
+
+// discard removes the staged files that were not renamed into place.
+func (t *Transaction) discard() {
+	for i := range t.writes {
+		if staged := t.writes[i].staged; staged != "" {
+			_ = os.Remove(staged)
+			t.writes[i].staged = ""
+		}
+	}
+}
+
+// rollback restores the files written before the write at index failed, latest
+// first, so a file written twice ends up with the content it had before.
+func (t *Transaction) rollback(failed int) {
+	for i := failed - 1; i >= 0; i-- {
+		w := t.writes[i]
+		if w.original == nil {
+			_ = t.s.Remove(w.name)
+			continue
+		}
+		_ = t.s.Write(w.name, bytes.NewReader(w.original))
+	}
+}
@@ -0,0 +1,244 @@
+package storage
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// propfindBody requests the properties WebDAV needs to describe files
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<propfind xmlns="DAV:"><prop><resourcetype/><getcontentlength/><getlastmodified/></prop></propfind>`
+
+// WebDAV stores files on a WebDAV server below a base URL.
+type WebDAV struct {
+	base   *url.URL
+	client *http.Client
+}
+
+// NewWebDAV returns a Storage for the collection at base. Credentials in the URL
+// are sent using basic authentication. A nil client uses http.DefaultClient.
+func NewWebDAV(base *url.URL, client *http.Client) *WebDAV {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	root := *base
+	if !strings.HasSuffix(root.Path, "/") {
+		root.Path += "/"
+	}
+	return &WebDAV{base: &root, client: client}
+}
+
+// url returns the URL of the named resource without credentials
+func (w *WebDAV) url(name string) *url.URL {
+	u := *w.base
+	u.User = nil
+	u.Path = w.base.Path + cleanName(name)
+	return &u
+}
+
+// do sends a request for the named resource
+func (w *WebDAV) do(method string, u *url.URL, body io.Reader, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if user := w.base.User; user != nil {
+		password, _ := user.Password()
+		req.SetBasicAuth(user.Username(), password)
+	}
+	return w.client.Do(req)
+}
+
+// statusError converts an unexpected response into an error
+func statusError(method, name string, resp *http.Response) error {
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s %s: %w", method, name, ErrNotExist)
+	}
+	return fmt.Errorf("%s %s: unexpected status %s", method, name, resp.Status)
+}
+
+// Read returns the content of the named file.
+func (w *WebDAV) Read(name string) ([]byte, error) {
+	resp, err := w.do(http.MethodGet, w.url(name), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError("GET", name, resp)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// Write uploads the content of r to the named file. WebDAV servers replace
+// the previous content only once the upload is complete.
+func (w *WebDAV) Write(name string, r io.Reader) error {
+	resp, err := w.do(http.MethodPut, w.url(name), r, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+		return nil
+	case http.StatusConflict:
+		return fmt.Errorf("PUT %s: parent directory: %w", name, ErrNotExist)
+	default:
+		return statusError("PUT", name, resp)
+	}
+}
+
+// MkdirAll creates the named collection and any missing parents.
+func (w *WebDAV) MkdirAll(dir string) error {
+	dir = cleanName(dir)
+	if dir == "" {
+		return nil
+	}
+
+	current := ""
+	for _, part := range strings.Split(dir, "/") {
+		current = path.Join(current, part)
+		u := w.url(current)
+		u.Path += "/"
+
+		resp, err := w.do("MKCOL", u, nil, nil)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		// 405 Method Not Allowed means the collection already exists
+		if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusMethodNotAllowed {
+			return statusError("MKCOL", current, resp)
+		}
+	}
+	return nil
+}
+
+// Remove deletes the named file.
+func (w *WebDAV) Remove(name string) error {
+	resp, err := w.do(http.MethodDelete, w.url(name), nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusAccepted, http.StatusNoContent:
+		return nil
+	default:
+		return statusError("DELETE", name, resp)
+	}
+}
+
+// Stat describes the named file or directory.
+func (w *WebDAV) Stat(name string) (FileInfo, error) {
+	infos, err := w.propfind(name, "0")
+	if err != nil {
+		return FileInfo{}, err
+	}
+	if len(infos) == 0 {
+		return FileInfo{}, fmt.Errorf("PROPFIND %s: %w", name, ErrNotExist)
+	}
+	return infos[0], nil
+}
+
+// List returns the entries directly inside the named collection.
+func (w *WebDAV) List(dir string) ([]FileInfo, error) {
+	infos, err := w.propfind(dir, "1")
+	if err != nil {
+		return nil, err
+	}
+
+	self := cleanName(dir)
+	entries := make([]FileInfo, 0, len(infos))
+	for _, info := range infos {
+		if info.Name != self {
+			entries = append(entries, info)
+		}
+	}
+	return entries, nil
+}
+
+// Location returns the URL of the named file without credentials.
+func (w *WebDAV) Location(name string) string {
+	return w.url(name).String()
+}
+
+// davMultistatus is the body of a PROPFIND response
+type davMultistatus struct {
+	Responses []struct {
+		Href      string `xml:"href"`
+		Propstats []struct {
+			Status string `xml:"status"`
+			Prop   struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+				ContentLength int64  `xml:"getcontentlength"`
+				LastModified  string `xml:"getlastmodified"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// propfind lists the named resource and, with depth "1", its children
+func (w *WebDAV) propfind(name, depth string) ([]FileInfo, error) {
+	header := http.Header{}
+	header.Set("Depth", depth)
+	header.Set("Content-Type", "application/xml; charset=utf-8")
+
+	resp, err := w.do("PROPFIND", w.url(name), strings.NewReader(propfindBody), header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, statusError("PROPFIND", name, resp)
+	}
+
+	var status davMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, fmt.Errorf("PROPFIND %s: invalid response: %w", name, err)
+	}
+
+	infos := make([]FileInfo, 0, len(status.Responses))
+	for _, response := range status.Responses {
+		href, err := url.Parse(response.Href)
+		if err != nil {
+			continue
+		}
+		info := FileInfo{
+			Name: strings.Trim(strings.TrimPrefix(href.Path, w.base.Path), "/"),
+		}
+		// The collection itself may be reported without its trailing slash
+		if href.Path+"/" == w.base.Path {
+			info.Name = ""
+		}
+		for _, propstat := range response.Propstats {
+			if !strings.Contains(propstat.Status, " 200") {
+				continue
+			}
+			prop := propstat.Prop
+			info.IsDir = prop.ResourceType.Collection != nil
+			info.Size = prop.ContentLength
+			if modTime, err := time.Parse(http.TimeFormat, prop.LastModified); err == nil {
+				info.ModTime = modTime
+			}
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}